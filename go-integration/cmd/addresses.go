@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/address"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// AddressResponse describes who a wallet address belongs to
+type AddressResponse struct {
+	Address     string    `json:"address"`
+	UserID      int32     `json:"user_id"`
+	Role        string    `json:"role"`
+	Label       string    `json:"label,omitempty"`
+	DisplayName string    `json:"display_name"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+type SetAddressLabelRequest struct {
+	Label string `json:"label"`
+}
+
+func newAddressResponse(entry *database.AddressBookEntry) AddressResponse {
+	response := AddressResponse{
+		Address:     entry.Address,
+		UserID:      entry.UserID,
+		Role:        entry.Role,
+		DisplayName: entry.DisplayName(),
+		FirstSeenAt: entry.FirstSeenAt,
+		LastSeenAt:  entry.LastSeenAt,
+	}
+	if entry.Label != nil {
+		response.Label = *entry.Label
+	}
+	return response
+}
+
+// parseAddressPath reads the {addr} path value in canonical form
+func parseAddressPath(r *http.Request) (string, bool) {
+	canonical, err := address.Canonical(r.PathValue("addr"))
+	return canonical, err == nil
+}
+
+// recordParties adds the client and freelancer of a job to the address book
+func (pg *PaymentGateway) recordParties(ctx context.Context, details *database.ApplicationPaymentDetails) {
+	parties := []struct {
+		address *string
+		userID  int32
+		role    string
+	}{
+		{details.PosterWalletAddress, details.PosterUserID, database.RoleClient},
+		{details.ApplicantWalletAddress, details.ApplicantUserID, database.RoleFreelancer},
+	}
+
+	for _, party := range parties {
+		if party.address == nil || !common.IsHexAddress(*party.address) {
+			continue
+		}
+		address := common.HexToAddress(*party.address).Hex()
+		if err := pg.db.RecordAddress(ctx, address, party.userID, party.role); err != nil {
+			log.Printf("Warning: Failed to record %s address in address book: %v", party.role, err)
+		}
+	}
+}
+
+// GET /addresses/{addr} - Look up who a wallet address belongs to
+func (pg *PaymentGateway) getAddressHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := parseAddressPath(r)
+	if !ok {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	entry, err := pg.db.GetAddress(ctx, address)
+	if err != nil {
+		writeServerError(w, "Failed to get address", err)
+		return
+	}
+	if entry == nil {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAddressResponse(entry))
+}
+
+// PUT /addresses/{addr} - Set the label shown for a known address
+func (pg *PaymentGateway) setAddressLabelHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := parseAddressPath(r)
+	if !ok {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	var req SetAddressLabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	found, err := pg.db.SetAddressLabel(ctx, address, req.Label)
+	if err != nil {
+		writeServerError(w, "Failed to update address", err)
+		return
+	}
+	if !found {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	pg.getAddressHandler(w, r)
+}
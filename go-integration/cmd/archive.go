@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/archive"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// maxArchiveBatchesPerRun stops one run from exporting the whole backlog at
+// once after archival is first enabled; the next run carries on
+const maxArchiveBatchesPerRun = 20
+
+// maxArchivedLedgerTransactions bounds the ledger postings read for one job
+const maxArchivedLedgerTransactions = 1000
+
+// ArchivedJobRecord is one line of an archive batch: a settled job with its
+// payment events, transaction receipts and ledger postings
+type ArchivedJobRecord struct {
+	ApplicationID     int32                       `json:"application_id"`
+	JobID             int32                       `json:"job_id"`
+	ApplicantUserID   int32                       `json:"applicant_user_id"`
+	PosterUserID      int32                       `json:"poster_user_id"`
+	USDAmount         *int32                      `json:"usd_amount"`
+	PaymentStatus     paymentstatus.Status        `json:"payment_status"`
+	ApplicationStatus string                      `json:"application_status"`
+	EscrowJobID       *int32                      `json:"escrow_job_id,omitempty"`
+	FreelancerAddress *string                     `json:"freelancer_address,omitempty"`
+	ClientAddress     *string                     `json:"client_address,omitempty"`
+	TxHashDeposit     *string                     `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease     *string                     `json:"tx_hash_release,omitempty"`
+	TxHashRefund      *string                     `json:"tx_hash_refund,omitempty"`
+	Events            []ArchivedEvent             `json:"events"`
+	Receipts          []ArchivedReceipt           `json:"receipts"`
+	Ledger            []LedgerTransactionResponse `json:"ledger"`
+	ArchivedAt        time.Time                   `json:"archived_at"`
+}
+
+// ArchivedEvent is one payment status transition of an archived job
+type ArchivedEvent struct {
+	Status      string    `json:"status"`
+	TxHash      *string   `json:"tx_hash,omitempty"`
+	BlockNumber *int64    `json:"block_number,omitempty"`
+	Actor       string    `json:"actor"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ArchivedReceipt is the gas paid by one mined transaction of an archived job
+type ArchivedReceipt struct {
+	Operation         string    `json:"operation"`
+	TxHash            string    `json:"tx_hash"`
+	GasUsed           uint64    `json:"gas_used"`
+	EffectiveGasPrice string    `json:"effective_gas_price"`
+	CostWei           string    `json:"cost_wei"`
+	ETHUSDPrice       *string   `json:"eth_usd_price,omitempty"`
+	CostUSD           *string   `json:"cost_usd,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ArchiveListResponse lists the archived batches, oldest first
+type ArchiveListResponse struct {
+	Bucket    string              `json:"bucket"`
+	Retention string              `json:"retention"` // "0s" keeps batches forever
+	Batches   []*archive.Manifest `json:"batches"`
+}
+
+// RestoreArchiveResponse counts what a restore put back. Rows still in the
+// database are left alone and not counted.
+type RestoreArchiveResponse struct {
+	Batch              string `json:"batch"`
+	Jobs               int    `json:"jobs"`
+	Events             int    `json:"events"`
+	Receipts           int    `json:"receipts"`
+	LedgerTransactions int    `json:"ledger_transactions"`
+}
+
+// runArchiver exports settled jobs and prunes expired batches every
+// ARCHIVE_INTERVAL while ARCHIVE_BUCKET_URL is set
+func (pg *PaymentGateway) runArchiver(ctx context.Context) {
+	if pg.archive == nil || pg.config.ArchiveInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.ArchiveInterval)
+	defer ticker.Stop()
+
+	for {
+		pg.archiveSettledJobs(ctx, time.Now())
+		pg.markWorkerRun("archiver", pg.config.ArchiveInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveSettledJobs writes released and refunded jobs whose last event is
+// older than ARCHIVE_AFTER to the bucket in batches, then deletes the batches
+// that have expired. It returns the manifests it wrote.
+func (pg *PaymentGateway) archiveSettledJobs(ctx context.Context, now time.Time) []*archive.Manifest {
+	var written []*archive.Manifest
+	for len(written) < maxArchiveBatchesPerRun {
+		manifest, err := pg.archiveBatch(ctx, now)
+		if err != nil {
+			log.Printf("Failed to archive settled jobs: %v", err)
+			break
+		}
+		if manifest == nil {
+			break
+		}
+		log.Printf("Archived %d jobs to %s", manifest.Records, manifest.Object)
+		written = append(written, manifest)
+	}
+
+	// Each manifest carries the expiry ARCHIVE_RETENTION gave it when written
+	pruneCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	pruned, err := archive.Prune(pruneCtx, pg.archive, now)
+	cancel()
+	for _, manifest := range pruned {
+		log.Printf("Pruned archive batch %s created %s", manifest.Batch, manifest.CreatedAt.Format(time.RFC3339))
+	}
+	if err != nil {
+		log.Printf("Failed to prune archive: %v", err)
+	}
+	return written
+}
+
+// archiveBatch exports the next batch of settled jobs, or returns nil when none are due
+func (pg *PaymentGateway) archiveBatch(ctx context.Context, now time.Time) (*archive.Manifest, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	applications, err := pg.db.ListArchivableApplications(ctx, now.Add(-pg.config.ArchiveAfter), pg.config.ArchiveBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(applications) == 0 {
+		return nil, nil
+	}
+
+	records := make([]ArchivedJobRecord, 0, len(applications))
+	ids := make([]int32, 0, len(applications))
+	for _, details := range applications {
+		record, err := pg.archivedJobRecord(ctx, details, now)
+		if err != nil {
+			return nil, fmt.Errorf("application %d: %w", details.ApplicationID, err)
+		}
+		records = append(records, *record)
+		ids = append(ids, details.ApplicationID)
+	}
+
+	manifest := archive.NewManifest(now, pg.config.ArchiveRetention, ids)
+	if err := archive.Write(ctx, pg.archive, manifest, records); err != nil {
+		return nil, err
+	}
+	// Marked only once the manifest is stored; a failure here exports the
+	// jobs again in a new batch rather than losing them
+	if err := pg.db.RecordArchivedApplications(ctx, manifest.Batch, manifest.Object, ids); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (pg *PaymentGateway) archivedJobRecord(ctx context.Context, details *database.ApplicationPaymentDetails, now time.Time) (*ArchivedJobRecord, error) {
+	record := &ArchivedJobRecord{
+		ApplicationID:     details.ApplicationID,
+		JobID:             details.JobID,
+		ApplicantUserID:   details.ApplicantUserID,
+		PosterUserID:      details.PosterUserID,
+		USDAmount:         details.AgreedUSDAmount,
+		PaymentStatus:     details.PaymentStatus,
+		ApplicationStatus: details.ApplicationStatus,
+		EscrowJobID:       details.EscrowJobID,
+		FreelancerAddress: details.ApplicantWalletAddress,
+		ClientAddress:     details.PosterWalletAddress,
+		TxHashDeposit:     details.EscrowTxHashDeposit,
+		TxHashRelease:     details.EscrowTxHashRelease,
+		TxHashRefund:      details.EscrowTxHashRefund,
+		Events:            []ArchivedEvent{},
+		Receipts:          []ArchivedReceipt{},
+		Ledger:            []LedgerTransactionResponse{},
+		ArchivedAt:        now.UTC(),
+	}
+
+	events, err := pg.db.GetPaymentEvents(ctx, details.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		record.Events = append(record.Events, ArchivedEvent{
+			Status:      event.Status,
+			TxHash:      event.TxHash,
+			BlockNumber: event.BlockNumber,
+			Actor:       event.Actor,
+			CreatedAt:   event.CreatedAt,
+		})
+	}
+
+	costs, err := pg.db.ListTransactionCosts(ctx, details.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, cost := range costs {
+		record.Receipts = append(record.Receipts, ArchivedReceipt{
+			Operation:         cost.Operation,
+			TxHash:            cost.TxHash,
+			GasUsed:           cost.GasUsed,
+			EffectiveGasPrice: cost.EffectiveGasPrice,
+			CostWei:           cost.CostWei,
+			ETHUSDPrice:       cost.ETHUSDPrice,
+			CostUSD:           cost.CostUSD,
+			CreatedAt:         cost.CreatedAt,
+		})
+	}
+
+	transactions, err := pg.db.FindLedgerTransactions(ctx, database.LedgerFilter{ApplicationID: details.ApplicationID, Limit: maxArchivedLedgerTransactions})
+	if err != nil {
+		return nil, err
+	}
+	// Oldest first, like the events and receipts
+	for i := len(transactions) - 1; i >= 0; i-- {
+		record.Ledger = append(record.Ledger, newLedgerTransactionResponse(transactions[i]))
+	}
+	return record, nil
+}
+
+// archivedJob converts an archive record back into the rows it was exported from
+func archivedJob(record ArchivedJobRecord) (database.ArchivedJob, error) {
+	job := database.ArchivedJob{ApplicationID: record.ApplicationID}
+	for _, event := range record.Events {
+		job.Events = append(job.Events, database.PaymentEvent{
+			ApplicationID: record.ApplicationID,
+			Status:        event.Status,
+			TxHash:        event.TxHash,
+			BlockNumber:   event.BlockNumber,
+			Actor:         event.Actor,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+	for _, receipt := range record.Receipts {
+		job.Costs = append(job.Costs, database.TransactionCost{
+			ApplicationID:     record.ApplicationID,
+			Operation:         receipt.Operation,
+			TxHash:            receipt.TxHash,
+			GasUsed:           receipt.GasUsed,
+			EffectiveGasPrice: receipt.EffectiveGasPrice,
+			CostWei:           receipt.CostWei,
+			ETHUSDPrice:       receipt.ETHUSDPrice,
+			CostUSD:           receipt.CostUSD,
+			CreatedAt:         receipt.CreatedAt,
+		})
+	}
+	for _, posted := range record.Ledger {
+		t := &database.LedgerTransaction{
+			Kind:          posted.Kind,
+			Reference:     posted.Reference,
+			ApplicationID: posted.ApplicationID,
+			Memo:          posted.Memo,
+			Actor:         posted.Actor,
+			CreatedAt:     posted.CreatedAt,
+		}
+		if posted.Counterparty != "" {
+			counterparty := posted.Counterparty
+			t.Counterparty = &counterparty
+		}
+
+		accounts := make([]string, 0, len(posted.Entries))
+		for account := range posted.Entries {
+			accounts = append(accounts, account)
+		}
+		sort.Strings(accounts)
+		sum := new(big.Int)
+		for _, account := range accounts {
+			amount, ok := new(big.Int).SetString(posted.Entries[account], 10)
+			if !ok {
+				return job, fmt.Errorf("ledger transaction %s %s has an invalid amount for %s", posted.Kind, posted.Reference, account)
+			}
+			sum.Add(sum, amount)
+			t.Entries = append(t.Entries, database.LedgerEntry{Account: account, AmountWei: amount.String()})
+		}
+		if sum.Sign() != 0 {
+			return job, fmt.Errorf("ledger transaction %s %s does not balance", posted.Kind, posted.Reference)
+		}
+		job.Ledger = append(job.Ledger, t)
+	}
+	return job, nil
+}
+
+// requireArchive writes 403 and returns false when archival is not configured
+func (pg *PaymentGateway) requireArchive(w http.ResponseWriter) bool {
+	if pg.archive == nil {
+		http.Error(w, "Archival is disabled: ARCHIVE_BUCKET_URL is not set", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// GET /admin/archives - Manifests of the archived batches
+func (pg *PaymentGateway) listArchivesHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireArchive(w) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	manifests, err := archive.Manifests(ctx, pg.archive)
+	if err != nil {
+		writeServerError(w, "Failed to list archive batches", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ArchiveListResponse{
+		Bucket:    pg.config.ArchiveBucketURL,
+		Retention: pg.config.ArchiveRetention.String(),
+		Batches:   manifests,
+	})
+}
+
+// POST /admin/archives/{batch}/restore - Put an archived batch's missing rows back
+func (pg *PaymentGateway) restoreArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireArchive(w) {
+		return
+	}
+
+	var only int32 // restore a single job of the batch
+	if raw := r.URL.Query().Get("application_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid application_id", http.StatusBadRequest)
+			return
+		}
+		only = int32(id)
+	}
+
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	batch := r.PathValue("batch")
+	manifest, err := archive.GetManifest(ctx, pg.archive, batch)
+	if errors.Is(err, archive.ErrNotFound) {
+		http.Error(w, "Archive batch not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeServerError(w, "Failed to read archive manifest", err)
+		return
+	}
+	records, err := archive.Read[ArchivedJobRecord](ctx, pg.archive, manifest)
+	if err != nil {
+		writeServerError(w, "Failed to read archive batch", err)
+		return
+	}
+
+	response := RestoreArchiveResponse{Batch: manifest.Batch}
+	for _, record := range records {
+		if only != 0 && record.ApplicationID != only {
+			continue
+		}
+		job, err := archivedJob(record)
+		if err != nil {
+			writeServerError(w, fmt.Sprintf("Invalid archive record for application %d", record.ApplicationID), err)
+			return
+		}
+		restored, err := pg.db.RestoreArchivedJob(ctx, job)
+		if err != nil {
+			writeServerError(w, fmt.Sprintf("Failed to restore application %d", record.ApplicationID), err)
+			return
+		}
+		response.Jobs++
+		response.Events += restored.Events
+		response.Receipts += restored.Costs
+		response.LedgerTransactions += restored.LedgerTransactions
+	}
+	if only != 0 && response.Jobs == 0 {
+		http.Error(w, "Application is not in this archive batch", http.StatusNotFound)
+		return
+	}
+	log.Printf("Restored archive batch %s for %s: %d jobs, %d events, %d receipts, %d ledger transactions",
+		manifest.Batch, actor, response.Jobs, response.Events, response.Receipts, response.LedgerTransactions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
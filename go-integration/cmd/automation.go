@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/automation"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/trace"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/velocity"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/workpool"
+)
+
+// automationBatch is the most status transitions one pass acts on
+const automationBatch = 100
+
+// runAutomation applies AUTOMATION_RULES to the application status
+// transitions found every AUTOMATION_POLL_INTERVAL, and at once when a
+// platform event reports a status change
+func (pg *PaymentGateway) runAutomation(ctx context.Context) {
+	if len(pg.automation) == 0 || pg.config.AutomationPollInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.AutomationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-pg.automationWake:
+		}
+		pg.automateTransitions(ctx)
+		pg.markWorkerRun("automation", pg.config.AutomationPollInterval)
+	}
+}
+
+// wakeAutomation checks application statuses without waiting for the next tick
+func (pg *PaymentGateway) wakeAutomation() {
+	select {
+	case pg.automationWake <- struct{}{}:
+	default:
+	}
+}
+
+// automateTransitions acts on the applications whose status moved since the
+// last pass. Each transition is recorded before its rule runs, so it is acted
+// on once however many gateways are running; one whose action fails is
+// logged and left to an operator. Nothing is recorded during maintenance or
+// while signing is halted, so those transitions are acted on afterwards.
+func (pg *PaymentGateway) automateTransitions(ctx context.Context) {
+	if pg.maintenance.Load() != nil || pg.signingHalt.Load() != nil {
+		return
+	}
+
+	transitions, err := pg.db.ListApplicationTransitions(ctx, automationBatch)
+	if err != nil {
+		log.Printf("Failed to list application status transitions: %v", err)
+		return
+	}
+	for _, t := range transitions {
+		rule, matched := pg.automation.Match(t.Tenant, t.PreviousStatus, t.Status)
+		recorded, err := pg.db.RecordTransition(ctx, t.ApplicationID, t.PreviousStatus, t.Status)
+		if err != nil {
+			log.Printf("Failed to record status transition of application %d: %v", t.ApplicationID, err)
+			return
+		}
+		if !recorded || !matched {
+			continue
+		}
+		if stop := pg.applyAutomationRule(t, rule); stop != "" {
+			log.Printf("Status automation stopped at application %d, %s", t.ApplicationID, stop)
+			return
+		}
+	}
+}
+
+// applyAutomationRule takes a rule's actions for one transition in order. It
+// returns why later transitions should wait for the next pass, or "" to carry
+// on.
+func (pg *PaymentGateway) applyAutomationRule(t database.ApplicationTransition, rule automation.Rule) string {
+	traceID := trace.NewID()
+	log.Printf("%sApplication %d moved from %q to %q: %v", tracePrefix(traceID), t.ApplicationID, t.PreviousStatus, t.Status, rule.Actions)
+
+	for _, action := range rule.Actions {
+		var stop string
+		switch action {
+		case automation.Release:
+			if t.PaymentStatus != paymentstatus.Deposited {
+				log.Printf("Not releasing application %d on %q: payment status is '%s', expected 'deposited'", t.ApplicationID, t.Status, t.PaymentStatus)
+				continue
+			}
+			stop = pg.autoRelease(t.ApplicationID, database.ActorPlatform)
+		case automation.Refund:
+			if t.PaymentStatus != paymentstatus.Deposited {
+				log.Printf("Not refunding application %d on %q: payment status is '%s', expected 'deposited'", t.ApplicationID, t.Status, t.PaymentStatus)
+				continue
+			}
+			stop = pg.autoRefund(t.ApplicationID, rule.RefundReason, traceID)
+		case automation.Notify:
+			actions := make([]string, len(rule.Actions))
+			for i, a := range rule.Actions {
+				actions[i] = string(a)
+			}
+			pg.notifyJob(t.ApplicationID, traceID, events.ApplicationStatusChanged, events.ApplicationStatus{
+				ApplicationID:  t.ApplicationID,
+				Tenant:         t.Tenant,
+				PreviousStatus: t.PreviousStatus,
+				Status:         t.Status,
+				PaymentStatus:  string(t.PaymentStatus),
+				Actions:        actions,
+			})
+		}
+		if stop != "" {
+			return stop
+		}
+	}
+	return ""
+}
+
+// autoRefund refunds a deposited job the way /cancel-job would, velocity
+// limits included, and logs the outcome. It returns why refunds after it
+// should not be attempted, or "" to carry on.
+func (pg *PaymentGateway) autoRefund(applicationID int32, reason payment.RefundReason, traceID string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		log.Printf("Failed to get application %d to refund: %v", applicationID, err)
+		return ""
+	}
+	if details.PaymentStatus != paymentstatus.Deposited {
+		log.Printf("Not refunding application %d: payment status is '%s', expected 'deposited'", applicationID, details.PaymentStatus)
+		return ""
+	}
+	pending, err := pg.db.GetPendingDeferredOperation(ctx, applicationID)
+	if err != nil {
+		log.Printf("Failed to check deferred operations of application %d: %v", applicationID, err)
+		return ""
+	}
+	if pending != nil {
+		log.Printf("Not refunding application %d: operation %s is already deferred until %s", applicationID, pending.Operation, pending.Deadline.Format(time.RFC3339))
+		return ""
+	}
+
+	params := database.OperationParams{JobID: uint64(applicationID), RefundReason: string(reason), TraceID: traceID}
+	violations, err := pg.checkRefundLimits(ctx, details)
+	switch {
+	case err != nil:
+		log.Printf("Failed to check velocity limits of application %d: %v", applicationID, err)
+		return ""
+	case velocity.Blocked(violations):
+		log.Printf("Blocked refund of application %d: %+v", applicationID, violations)
+		return ""
+	case len(violations) > 0:
+		if _, err := pg.createReview(ctx, &database.Review{ApplicationID: applicationID, Operation: opCancelJob, Params: params, PreviousStatus: details.PaymentStatus}, violations); err != nil {
+			log.Printf("Failed to hold refund of application %d for review: %v", applicationID, err)
+		}
+		return ""
+	}
+
+	_, err = pg.submitOperation(ctx, applicationID, opCancelJob, params)
+	if err == nil {
+		return ""
+	}
+	op, queued, dbErr := pg.queueRetryable(ctx, applicationID, opCancelJob, params, err)
+	switch {
+	case queued && dbErr != nil:
+		log.Printf("Failed to refund application %d: %s and queueing failed: %v", applicationID, payment.ClassifyError(err).Message, dbErr)
+	case queued:
+		log.Printf("Refund of application %d deferred as operation %d", applicationID, op.ID)
+	case errors.Is(err, workpool.ErrQueueFull), errors.Is(err, errSigningHalted):
+		log.Printf("Failed to refund application %d: %v", applicationID, err)
+		return "not attempted: " + err.Error()
+	default:
+		log.Printf("Failed to refund application %d: %v", applicationID, payment.ClassifyError(err))
+	}
+	return ""
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// opCancelTransaction is the operation the gas of a cancel transaction is recorded under
+const opCancelTransaction = "cancel_transaction"
+
+// broadcastResolveInterval is how often signed transactions are settled
+// against the nonces mined since
+const broadcastResolveInterval = time.Minute
+
+// cancelledStatuses is where a cancelled transaction leaves its application:
+// a deposit that never happened can be sent again, and a release or refund
+// leaves the escrow as it was
+var cancelledStatuses = map[string]paymentstatus.Status{"deposit": paymentstatus.DepositFailed, "release": paymentstatus.Deposited, "refund": paymentstatus.Deposited}
+
+// trackBroadcasts returns a context whose transactions are recorded as
+// signed for operation on escrow job jobID before they are sent
+func (pg *PaymentGateway) trackBroadcasts(ctx context.Context, applicationID int32, operation string, jobID uint64) context.Context {
+	return pg.trackBroadcast(ctx, database.Broadcast{ApplicationID: applicationID, IntentKey: database.IntentKey(operation, jobID), Operation: operation})
+}
+
+// trackBroadcast records each transaction signed under ctx as intent. It
+// runs on its own context so a send is recorded however little of the
+// caller's is left.
+func (pg *PaymentGateway) trackBroadcast(ctx context.Context, intent database.Broadcast) context.Context {
+	return payment.WithSignedFunc(ctx, func(tx *types.Transaction) {
+		from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			log.Printf("Warning: Failed to recover the signer of %s: %v", tx.Hash().Hex(), err)
+			return
+		}
+		b := intent
+		b.TxHash, b.Address, b.Nonce = tx.Hash().Hex(), from.Hex(), tx.Nonce()
+
+		recordCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := pg.db.RecordBroadcast(recordCtx, b); err != nil {
+			log.Printf("Warning: Failed to record %s transaction %s; its gas is only counted if the gateway waits for it: %v", b.Operation, b.TxHash, err)
+		}
+	})
+}
+
+// POST /admin/transactions/{hash}/cancel - Replace a stuck transaction with an empty transfer at its nonce
+func (pg *PaymentGateway) cancelTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	raw, err := hexutil.Decode(r.PathValue("hash"))
+	if err != nil || len(raw) != common.HashLength {
+		http.Error(w, "Invalid transaction hash", http.StatusBadRequest)
+		return
+	}
+	hash := common.BytesToHash(raw)
+
+	stuck, err := pg.db.GetBroadcast(r.Context(), hash.Hex())
+	if err != nil {
+		writeServerError(w, "Failed to get transaction", err)
+		return
+	}
+	switch {
+	case stuck == nil:
+		http.Error(w, "Transaction not found among those the gateway signed for operations", http.StatusNotFound)
+		return
+	case stuck.Outcome != nil:
+		http.Error(w, fmt.Sprintf("Transaction is already settled as %s", *stuck.Outcome), http.StatusConflict)
+		return
+	case stuck.Cancels != nil:
+		http.Error(w, "Transaction is itself a cancel transaction", http.StatusConflict)
+		return
+	}
+
+	ctx := pg.trackBroadcast(r.Context(), database.Broadcast{
+		ApplicationID: stuck.ApplicationID,
+		IntentKey:     stuck.IntentKey,
+		Operation:     opCancelTransaction,
+		Cancels:       &stuck.TxHash,
+	})
+	result, err := pg.client.CancelTransaction(ctx, hash)
+	if errors.Is(err, payment.ErrNotPending) {
+		http.Error(w, fmt.Sprintf("Failed to cancel transaction: %v", err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		pg.writeChainError(w, "Failed to cancel transaction", result, err)
+		return
+	}
+	log.Printf("Cancel transaction %s sent in place of %s transaction %s of application %d", result.TxHash, stuck.Operation, stuck.TxHash, stuck.ApplicationID)
+	pg.writeTransactionResponse(w, result)
+}
+
+// runBroadcastResolver settles the gateway's signed transactions once their
+// nonces are mined, at startup and then every minute
+func (pg *PaymentGateway) runBroadcastResolver(ctx context.Context) {
+	ticker := time.NewTicker(broadcastResolveInterval)
+	defer ticker.Stop()
+
+	for {
+		pg.resolveBroadcasts(ctx)
+		pg.markWorkerRun("broadcast_resolver", broadcastResolveInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (pg *PaymentGateway) resolveBroadcasts(ctx context.Context) {
+	accounts := []common.Address{pg.client.Address()}
+	if admin := pg.client.AdminAddress(); admin != (common.Address{}) && admin != accounts[0] {
+		accounts = append(accounts, admin)
+	}
+	for _, account := range accounts {
+		if err := pg.resolveSignerBroadcasts(ctx, account); err != nil {
+			log.Printf("Failed to settle transactions of %s: %v", account.Hex(), err)
+		}
+	}
+}
+
+// resolveSignerBroadcasts settles the transactions address signed at nonces
+// it has since mined. Exactly one transaction is mined at each, so the
+// others at the nonce were replaced, or dropped if none of the gateway's was
+// mined there.
+func (pg *PaymentGateway) resolveSignerBroadcasts(ctx context.Context, address common.Address) error {
+	mined, err := pg.client.NonceAt(ctx, address)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+	broadcasts, err := pg.db.ListUnresolvedBroadcasts(ctx, address.Hex(), mined)
+	if err != nil || len(broadcasts) == 0 {
+		return err
+	}
+
+	hashes := make([]common.Hash, len(broadcasts))
+	for i, b := range broadcasts {
+		hashes[i] = common.HexToHash(b.TxHash)
+	}
+	receipts, err := pg.client.GetReceiptStatuses(ctx, hashes)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(broadcasts); {
+		end := start + 1
+		for end < len(broadcasts) && broadcasts[end].Nonce == broadcasts[start].Nonce {
+			end++
+		}
+		pg.resolveNonce(ctx, broadcasts[start:end], receipts)
+		start = end
+	}
+	return nil
+}
+
+// resolveNonce settles the transactions signed at one mined nonce. The one
+// that was mined is costed under its operation whether or not the gateway
+// waited for it, and a cancel transaction's gas is posted as waste. Nothing
+// is settled until the mined one has REQUIRED_CONFIRMATIONS.
+func (pg *PaymentGateway) resolveNonce(ctx context.Context, group []*database.Broadcast, receipts map[common.Hash]*payment.ReceiptStatus) {
+	var winner *database.Broadcast
+	var receipt *payment.ReceiptStatus
+	for _, b := range group {
+		status := receipts[common.HexToHash(b.TxHash)]
+		if status == nil || status.Err != nil {
+			return // looked up again next time
+		}
+		if status.Mined {
+			winner, receipt = b, status
+		}
+	}
+	if winner != nil && receipt.Confirmations < pg.config.RequiredConfirmations {
+		return
+	}
+
+	if winner != nil {
+		outcome := database.BroadcastMined
+		result := &payment.TransactionResult{
+			TxHash:            winner.TxHash,
+			BlockNumber:       receipt.BlockNumber,
+			GasUsed:           receipt.GasUsed,
+			EffectiveGasPrice: receipt.EffectiveGasPrice,
+			Success:           receipt.Success,
+		}
+		pg.recordGasCost(ctx, winner.ApplicationID, winner.Operation, result)
+		if winner.Cancels != nil {
+			outcome = database.BroadcastCancelled
+			pg.recordGasWaste(ctx, winner.ApplicationID, winner.Operation, winner.TxHash, result.GasCost(), ledger.WasteCancelled)
+		}
+		pg.followMinedBroadcast(ctx, winner, group)
+		if err := pg.db.ResolveBroadcast(ctx, winner.TxHash, outcome); err != nil {
+			log.Printf("Failed to settle transaction %s: %v", winner.TxHash, err)
+			return
+		}
+	}
+
+	for _, b := range group {
+		if b == winner {
+			continue
+		}
+		outcome := database.BroadcastDropped
+		if winner != nil {
+			outcome = database.BroadcastReplaced
+		}
+		if err := pg.db.ResolveBroadcast(ctx, b.TxHash, outcome); err != nil {
+			log.Printf("Failed to settle transaction %s: %v", b.TxHash, err)
+		}
+	}
+}
+
+// followMinedBroadcast points an application still waiting on a replaced
+// transaction of a post, release or refund at what was mined instead: the
+// other signing of the operation, for the status poller to settle, or a
+// cancel transaction, which leaves the payment where it was before the
+// operation was sent
+func (pg *PaymentGateway) followMinedBroadcast(ctx context.Context, winner *database.Broadcast, group []*database.Broadcast) {
+	operation, hash := winner.Operation, winner.TxHash
+	if winner.Cancels != nil {
+		operation = ""
+		for _, b := range group {
+			if b.TxHash == *winner.Cancels {
+				operation = b.Operation
+			}
+		}
+	}
+	effect, ok := intentEffects[operation]
+	if !ok {
+		return
+	}
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, winner.ApplicationID)
+	if err != nil {
+		log.Printf("Failed to get application %d to follow transaction %s: %v", winner.ApplicationID, hash, err)
+		return
+	}
+	recorded := map[string]*string{
+		"deposit": details.EscrowTxHashDeposit,
+		"release": details.EscrowTxHashRelease,
+		"refund":  details.EscrowTxHashRefund,
+	}[effect.txType]
+	if details.PaymentStatus != effect.status || recorded == nil || *recorded == hash {
+		return
+	}
+	replaced := false
+	for _, b := range group {
+		replaced = replaced || b.TxHash == *recorded
+	}
+	if !replaced {
+		return
+	}
+
+	change := database.StatusChange{
+		ApplicationID: winner.ApplicationID,
+		Status:        effect.status,
+		TxHash:        &hash,
+		TxType:        effect.txType,
+		Actor:         database.ActorReconciler,
+		FromStatuses:  []paymentstatus.Status{effect.status},
+	}
+	if winner.Cancels != nil {
+		change.Status, change.TxHash = cancelledStatuses[effect.txType], nil
+	}
+	if err := pg.db.ApplyStatusChange(ctx, change); err != nil {
+		log.Printf("Failed to move application %d off replaced transaction %s: %v", winner.ApplicationID, *recorded, err)
+		return
+	}
+	if winner.Cancels != nil {
+		log.Printf("Application %d: %s transaction %s was cancelled by %s; %s -> %s", winner.ApplicationID, operation, *recorded, hash, effect.status, change.Status)
+		return
+	}
+	log.Printf("Application %d: %s transaction %s was replaced by %s, which was mined", winner.ApplicationID, operation, *recorded, hash)
+	pg.wakePoller()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Canary modes of CANARY_MODE
+const (
+	canaryEstimate = "estimate" // sign and estimate a self-transfer without sending it
+	canaryTransfer = "transfer" // also send it and wait until it is mined
+	canaryOff      = "off"
+)
+
+// Canary outcomes
+const (
+	canaryPassed = "passed"
+	canaryFailed = "failed"
+)
+
+// CanaryHealth is the latest startup canary: whether the gateway could read
+// the contract, what nonce state its account is in, and whether it could sign
+// a transfer the node accepts. The gateway is unready until one passes.
+type CanaryHealth struct {
+	Status       string    `json:"status"` // "passed" or "failed"
+	Mode         string    `json:"mode"`
+	Account      string    `json:"account"`
+	Nonce        uint64    `json:"nonce"`         // mined transactions of the account
+	PendingNonce uint64    `json:"pending_nonce"` // including those in the node's pending pool
+	GasEstimate  uint64    `json:"gas_estimate,omitempty"`
+	TxHash       string    `json:"tx_hash,omitempty"` // of the self-transfer, sent only in transfer mode
+	Sent         bool      `json:"sent"`
+	Error        string    `json:"error,omitempty"`
+	Attempts     int       `json:"attempts"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// canaryEnabled reports whether startup waits for a canary
+func (pg *PaymentGateway) canaryEnabled() bool {
+	return pg.config.CanaryMode != "" && pg.config.CanaryMode != canaryOff
+}
+
+// runCanary repeats the canary every CANARY_RETRY_INTERVAL until one passes,
+// so a broken key or node keeps the instance out of rotation instead of
+// failing its first real payment
+func (pg *PaymentGateway) runCanary(ctx context.Context) {
+	if !pg.canaryEnabled() {
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		health := pg.checkCanary(ctx)
+		health.Attempts = attempt
+		pg.canary.Store(health)
+		if health.Status == canaryPassed {
+			log.Printf("Canary passed for %s at nonce %d (%s mode)", health.Account, health.PendingNonce, health.Mode)
+			return
+		}
+		log.Printf("Warning: Canary failed, staying unready: %s", health.Error)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pg.config.CanaryRetryInterval):
+		}
+	}
+}
+
+// checkCanary runs one canary: a contract view, the account's nonces, and a
+// signed self-transfer that is estimated and, in transfer mode, sent
+func (pg *PaymentGateway) checkCanary(ctx context.Context) *CanaryHealth {
+	mode := pg.config.CanaryMode
+	account := pg.client.Address()
+	health := &CanaryHealth{Status: canaryFailed, Mode: mode, Account: account.Hex(), CheckedAt: time.Now()}
+	fail := func(format string, args ...any) *CanaryHealth {
+		health.Error = fmt.Sprintf(format, args...)
+		return health
+	}
+
+	// Mining the transfer can take a few blocks
+	timeout := 30 * time.Second
+	if mode == canaryTransfer {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if _, err := pg.client.GetContractConfig(ctx); err != nil {
+		return fail("failed to read the contract: %v", err)
+	}
+
+	mined, err := pg.client.NonceAt(ctx, account)
+	if err != nil {
+		return fail("failed to get the account's nonce: %v", err)
+	}
+	pending, err := pg.client.PendingNonceAt(ctx, account)
+	if err != nil {
+		return fail("failed to get the account's pending nonce: %v", err)
+	}
+	health.Nonce, health.PendingNonce = mined, pending
+	switch {
+	case pending < mined:
+		return fail("node reports pending nonce %d below the mined nonce %d", pending, mined)
+	case mode == canaryTransfer && pending > mined:
+		return fail("%d transactions of %s are still pending; not sending the canary behind them", pending-mined, account.Hex())
+	}
+
+	result, err := pg.client.Canary(ctx, mode == canaryTransfer)
+	if result != nil {
+		health.GasEstimate, health.TxHash, health.Sent = result.GasEstimate, result.TxHash, result.Sent != nil
+	}
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	if mode == canaryTransfer {
+		after, err := pg.client.NonceAt(ctx, account)
+		if err != nil {
+			return fail("failed to get the account's nonce after the canary: %v", err)
+		}
+		if after <= result.Nonce {
+			return fail("nonce is still %d after the canary at nonce %d was mined", after, result.Nonce)
+		}
+	}
+
+	health.Status, health.Error = canaryPassed, ""
+	return health
+}
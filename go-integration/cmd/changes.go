@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// ChangesResponse is one page of the payment status change feed
+type ChangesResponse struct {
+	Changes    []ChangeEntry `json:"changes"`
+	NextCursor string        `json:"next_cursor"` // pass as since_cursor for the next page; unchanged when empty
+	HasMore    bool          `json:"has_more"`
+}
+
+// ChangeEntry is one payment status transition
+type ChangeEntry struct {
+	Cursor        string    `json:"cursor"`
+	JobID         uint64    `json:"job_id"`
+	ApplicationID int32     `json:"application_id"`
+	Status        string    `json:"status"`
+	TxHash        string    `json:"tx_hash,omitempty"`
+	TxURL         string    `json:"tx_url,omitempty"`
+	BlockNumber   *int64    `json:"block_number,omitempty"`
+	Actor         string    `json:"actor"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// GET /changes?since_cursor=X&limit=100&status[in]=released,refunded - Payment status changes in commit order
+func (pg *PaymentGateway) getChangesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("sort") || query.Has("cursor") {
+		http.Error(w, "The change feed is always in commit order: page it with since_cursor", http.StatusBadRequest)
+		return
+	}
+	params, err := database.ChangeFeedSpec.Parse(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := params.Limit
+
+	sinceCursor := query.Get("since_cursor")
+	cursor, err := database.ParseChangeCursor(sinceCursor)
+	if err != nil {
+		http.Error(w, "Invalid since_cursor: use a next_cursor returned by /changes", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// One extra row tells whether another page follows
+	changes, err := pg.db.ListPaymentChanges(ctx, cursor, params.Conditions, limit+1)
+	if err != nil {
+		writeServerError(w, "Failed to list payment changes", err)
+		return
+	}
+
+	response := ChangesResponse{Changes: make([]ChangeEntry, 0, min(len(changes), limit)), NextCursor: sinceCursor}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		response.HasMore = true
+	}
+	for _, change := range changes {
+		entry := ChangeEntry{
+			Cursor:        change.Cursor.String(),
+			JobID:         uint64(change.ApplicationID),
+			ApplicationID: change.ApplicationID,
+			Status:        change.Status,
+			BlockNumber:   change.BlockNumber,
+			Actor:         change.Actor,
+			Timestamp:     change.CreatedAt,
+		}
+		if change.TxHash != nil {
+			entry.TxHash = *change.TxHash
+			entry.TxURL = pg.explorer.Tx(*change.TxHash)
+		}
+		response.Changes = append(response.Changes, entry)
+		response.NextCursor = entry.Cursor
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/chaos"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// The chaos decorators wrap the gateway's dependencies when FAULT_INJECTION
+// is enabled. Each embeds the real dependency and overrides only the calls on
+// the payment path; everything else passes straight through.
+
+// mainnetChainID is Ethereum mainnet, where fault injection is refused
+const mainnetChainID = 1
+
+// chaosChain injects RPC timeouts and delayed receipts
+type chaosChain struct {
+	ChainClient
+	faults *chaos.Injector
+}
+
+func (c chaosChain) PostJob(ctx context.Context, jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address) (*payment.TransactionResult, error) {
+	if err := c.faults.RPCTimeout("PostJob"); err != nil {
+		return nil, err
+	}
+	return c.ChainClient.PostJob(ctx, jobID, freelancer, usdAmount, client)
+}
+
+func (c chaosChain) MarkJobCompleted(ctx context.Context, jobID uint64) (*payment.TransactionResult, error) {
+	if err := c.faults.RPCTimeout("MarkJobCompleted"); err != nil {
+		return nil, err
+	}
+	return c.ChainClient.MarkJobCompleted(ctx, jobID)
+}
+
+func (c chaosChain) CancelJob(ctx context.Context, jobID uint64) (*payment.TransactionResult, error) {
+	if err := c.faults.RPCTimeout("CancelJob"); err != nil {
+		return nil, err
+	}
+	return c.ChainClient.CancelJob(ctx, jobID)
+}
+
+func (c chaosChain) GetJobDetails(ctx context.Context, jobID uint64) (*payment.JobDetails, error) {
+	if err := c.faults.RPCTimeout("GetJobDetails"); err != nil {
+		return nil, err
+	}
+	return c.ChainClient.GetJobDetails(ctx, jobID)
+}
+
+func (c chaosChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	if err := c.faults.RPCTimeout("SuggestGasPrice"); err != nil {
+		return nil, err
+	}
+	return c.ChainClient.SuggestGasPrice(ctx)
+}
+
+// GetReceiptStatuses may time out as a whole, or report individual mined
+// receipts as still pending so the poller sees them late
+func (c chaosChain) GetReceiptStatuses(ctx context.Context, hashes []common.Hash) (map[common.Hash]*payment.ReceiptStatus, error) {
+	if err := c.faults.RPCTimeout("GetReceiptStatuses"); err != nil {
+		return nil, err
+	}
+	statuses, err := c.ChainClient.GetReceiptStatuses(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	for hash, status := range statuses {
+		if status.Mined && c.faults.DelayReceipt("GetReceiptStatuses") {
+			statuses[hash] = &payment.ReceiptStatus{TxHash: hash}
+		}
+	}
+	return statuses, nil
+}
+
+// chaosOracle injects RPC timeouts into price reads
+type chaosOracle struct {
+	PriceOracle
+	faults *chaos.Injector
+}
+
+func (o chaosOracle) GetETHUSDPrice(ctx context.Context) (*big.Int, error) {
+	if err := o.faults.RPCTimeout("GetETHUSDPrice"); err != nil {
+		return nil, err
+	}
+	return o.PriceOracle.GetETHUSDPrice(ctx)
+}
+
+func (o chaosOracle) LatestPriceRound(ctx context.Context) (*oracle.RoundData, error) {
+	if err := o.faults.RPCTimeout("LatestPriceRound"); err != nil {
+		return nil, err
+	}
+	return o.PriceOracle.LatestPriceRound(ctx)
+}
+
+// chaosStore injects errors into application and payment status queries
+type chaosStore struct {
+	Store
+	faults *chaos.Injector
+}
+
+func (s chaosStore) GetApplicationPaymentDetails(ctx context.Context, applicationID int32) (*database.ApplicationPaymentDetails, error) {
+	if err := s.faults.DBError("GetApplicationPaymentDetails"); err != nil {
+		return nil, err
+	}
+	return s.Store.GetApplicationPaymentDetails(ctx, applicationID)
+}
+
+func (s chaosStore) ValidateApplicationForBlockchain(ctx context.Context, applicationID int32) error {
+	if err := s.faults.DBError("ValidateApplicationForBlockchain"); err != nil {
+		return err
+	}
+	return s.Store.ValidateApplicationForBlockchain(ctx, applicationID)
+}
+
+func (s chaosStore) UpdatePaymentStatus(ctx context.Context, applicationID int32, status paymentstatus.Status, txHash *string, txType string) error {
+	if err := s.faults.DBError("UpdatePaymentStatus"); err != nil {
+		return err
+	}
+	return s.Store.UpdatePaymentStatus(ctx, applicationID, status, txHash, txType)
+}
+
+func (s chaosStore) ApplyStatusChange(ctx context.Context, change database.StatusChange) error {
+	if err := s.faults.DBError("ApplyStatusChange"); err != nil {
+		return err
+	}
+	return s.Store.ApplyStatusChange(ctx, change)
+}
+
+func (s chaosStore) GetPaymentEvents(ctx context.Context, applicationID int32) ([]database.PaymentEvent, error) {
+	if err := s.faults.DBError("GetPaymentEvents"); err != nil {
+		return nil, err
+	}
+	return s.Store.GetPaymentEvents(ctx, applicationID)
+}
+
+func (s chaosStore) ListInitiatedTransactions(ctx context.Context) ([]database.InitiatedTransaction, error) {
+	if err := s.faults.DBError("ListInitiatedTransactions"); err != nil {
+		return nil, err
+	}
+	return s.Store.ListInitiatedTransactions(ctx)
+}
+
+func (s chaosStore) ListDueDeferredOperations(ctx context.Context) ([]*database.DeferredOperation, error) {
+	if err := s.faults.DBError("ListDueDeferredOperations"); err != nil {
+		return nil, err
+	}
+	return s.Store.ListDueDeferredOperations(ctx)
+}
+
+// chaosNotifier injects webhook delivery failures
+type chaosNotifier struct {
+	Notifier
+	faults *chaos.Injector
+}
+
+func (n chaosNotifier) Notify(ctx context.Context, event *events.Envelope) error {
+	if !n.Enabled() {
+		return nil
+	}
+	if err := n.faults.WebhookFailure("Notify"); err != nil {
+		return err
+	}
+	return n.Notifier.Notify(ctx, event)
+}
+
+func (n chaosNotifier) NotifyURL(ctx context.Context, url string, event *events.Envelope) error {
+	if err := n.faults.WebhookFailure("NotifyURL"); err != nil {
+		return err
+	}
+	return n.Notifier.NotifyURL(ctx, url, event)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/clientlimit"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// ClientLimitResponse is returned with 403 when a client limit blocks an escrow
+type ClientLimitResponse struct {
+	Error      string                  `json:"error"`
+	Code       string                  `json:"code"` // "client_limit"
+	Violations []clientlimit.Violation `json:"violations"`
+}
+
+// ClientLimitStatusResponse is a client's limit and how much of it open
+// escrows use. Without a configured limit the client is unlimited.
+type ClientLimitStatusResponse struct {
+	Scope             clientlimit.Scope `json:"scope"`
+	Subject           string            `json:"subject"`
+	Limited           bool              `json:"limited"`
+	MaxOpenUSD        *int64            `json:"max_open_usd"`
+	MaxTransactionUSD *int64            `json:"max_transaction_usd"`
+	OpenUSD           int64             `json:"open_usd"`
+	RemainingOpenUSD  *int64            `json:"remaining_open_usd"`
+}
+
+// SetClientLimitRequest sets a client's maximums; an omitted maximum is not
+// checked
+type SetClientLimitRequest struct {
+	MaxOpenUSD        *int64 `json:"max_open_usd"`
+	MaxTransactionUSD *int64 `json:"max_transaction_usd"`
+	Reason            string `json:"reason"`
+}
+
+// clientSubject reads the {scope} and {subject} path values, writing an error
+// if either is invalid
+func clientSubject(w http.ResponseWriter, r *http.Request) (clientlimit.Scope, string, bool) {
+	scope, err := clientlimit.ParseScope(r.PathValue("scope"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return "", "", false
+	}
+	subject, err := clientlimit.NormalizeSubject(scope, r.PathValue("subject"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", "", false
+	}
+	return scope, subject, true
+}
+
+// checkClientLimits writes a 403 and returns false when escrowing usdAmount
+// would exceed a limit of the poster's wallet or of tenant, if set. The
+// application's own earlier attempts are kept out of its client's open total.
+func (pg *PaymentGateway) checkClientLimits(ctx context.Context, w http.ResponseWriter, details *database.ApplicationPaymentDetails, tenant string, usdAmount int64) bool {
+	var wallet string
+	if details.PosterWalletAddress != nil {
+		wallet, _ = clientlimit.NormalizeSubject(clientlimit.Wallet, *details.PosterWalletAddress)
+	}
+	if wallet == "" && tenant == "" {
+		return true
+	}
+
+	limits, err := pg.db.GetClientLimits(ctx, wallet, tenant)
+	if err != nil {
+		writeServerError(w, "Failed to check client limits", err)
+		return false
+	}
+
+	var violations []clientlimit.Violation
+	for _, limit := range limits {
+		var openUSD int64
+		if limit.MaxOpenUSD != nil {
+			openUSD, err = pg.db.GetClientOpenUSD(ctx, limit.Scope, limit.Subject, details.ApplicationID)
+			if err != nil {
+				writeServerError(w, "Failed to check client limits", err)
+				return false
+			}
+		}
+		violations = append(violations, limit.Check(openUSD, usdAmount)...)
+	}
+	if len(violations) == 0 {
+		return true
+	}
+
+	log.Printf("Blocked %s for application %d: %+v", opPostJob, details.ApplicationID, violations)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(ClientLimitResponse{
+		Error:      "Escrow exceeds client limits",
+		Code:       "client_limit",
+		Violations: violations,
+	})
+	return false
+}
+
+// GET /client-limits?scope=tenant&sort=-updated_at - Configured client limits
+func (pg *PaymentGateway) listClientLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := database.ClientLimitListSpec.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	limits, next, err := pg.db.FindClientLimits(ctx, params)
+	if err != nil {
+		writeServerError(w, "Failed to list client limits", err)
+		return
+	}
+	if limits == nil {
+		limits = []clientlimit.Limit{}
+	}
+
+	writeListPage(w, limits, next)
+}
+
+// GET /client-limits/{scope}/{subject} - A client's limit and open escrows
+func (pg *PaymentGateway) getClientLimitHandler(w http.ResponseWriter, r *http.Request) {
+	scope, subject, ok := clientSubject(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var wallet, tenant string
+	if scope == clientlimit.Wallet {
+		wallet = subject
+	} else {
+		tenant = subject
+	}
+	limits, err := pg.db.GetClientLimits(ctx, wallet, tenant)
+	if err != nil {
+		writeServerError(w, "Failed to get client limit", err)
+		return
+	}
+	openUSD, err := pg.db.GetClientOpenUSD(ctx, scope, subject, 0)
+	if err != nil {
+		writeServerError(w, "Failed to get client open escrows", err)
+		return
+	}
+
+	response := ClientLimitStatusResponse{Scope: scope, Subject: subject, OpenUSD: openUSD}
+	if len(limits) > 0 {
+		limit := limits[0]
+		response.Limited = true
+		response.MaxOpenUSD = limit.MaxOpenUSD
+		response.MaxTransactionUSD = limit.MaxTransactionUSD
+		response.RemainingOpenUSD = limit.RemainingUSD(openUSD)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PUT /client-limits/{scope}/{subject} - Set a client's limit
+func (pg *PaymentGateway) setClientLimitHandler(w http.ResponseWriter, r *http.Request) {
+	scope, subject, ok := clientSubject(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetClientLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.MaxOpenUSD == nil && req.MaxTransactionUSD == nil {
+		http.Error(w, "max_open_usd or max_transaction_usd is required", http.StatusBadRequest)
+		return
+	}
+	for name, value := range map[string]*int64{"max_open_usd": req.MaxOpenUSD, "max_transaction_usd": req.MaxTransactionUSD} {
+		if value != nil && *value < 0 {
+			http.Error(w, fmt.Sprintf("%s must not be negative", name), http.StatusBadRequest)
+			return
+		}
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	limit := clientlimit.Limit{Scope: scope, Subject: subject, MaxOpenUSD: req.MaxOpenUSD, MaxTransactionUSD: req.MaxTransactionUSD}
+	if err := pg.db.SetClientLimit(ctx, limit, actor, req.Reason); err != nil {
+		writeServerError(w, "Failed to set client limit", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limit)
+}
+
+// DELETE /client-limits/{scope}/{subject}?reason= - Remove a client's limit
+func (pg *PaymentGateway) deleteClientLimitHandler(w http.ResponseWriter, r *http.Request) {
+	scope, subject, ok := clientSubject(w, r)
+	if !ok {
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	deleted, err := pg.db.DeleteClientLimit(ctx, scope, subject, actor, r.URL.Query().Get("reason"))
+	if err != nil {
+		writeServerError(w, "Failed to delete client limit", err)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Client limit not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// CloneJobRequest is the optional body of POST /jobs/{id}/clone
+type CloneJobRequest struct {
+	USDAmount int32 `json:"usd_amount,omitempty"` // agreed amount of the new engagement; the previous one's when omitted
+}
+
+// CloneJobResponse is a new engagement copied from a previous one and what
+// funding it costs at the current rate
+type CloneJobResponse struct {
+	JobID             uint64                      `json:"job_id"`
+	ApplicationID     int32                       `json:"application_id"`
+	ClonedFrom        uint64                      `json:"cloned_from"`
+	USDAmount         int32                       `json:"usd_amount"`
+	ClientAddress     string                      `json:"client_address"`
+	FreelancerAddress string                      `json:"freelancer_address"`
+	PaymentStatus     paymentstatus.Status        `json:"payment_status"`
+	Tags              []string                    `json:"tags"`
+	DisplayCurrencies *database.DisplayCurrencies `json:"display_currencies,omitempty"`
+	WebhookURL        string                      `json:"webhook_url,omitempty"`
+	Quote             QuoteResponse               `json:"quote"`
+}
+
+// POST /jobs/{id}/clone - Start a repeat engagement between a job's client
+// and freelancer. The new application is on the same job, agreed at the
+// previous amount unless usd_amount is given, and keeps the job's tags,
+// display currencies and webhook; it is left in pending_deposit with a spot
+// quote for funding it through /post-job.
+func (pg *PaymentGateway) cloneJobHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.JobClones) {
+		return
+	}
+
+	sourceJobID, sourceID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req CloneJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.USDAmount < 0 {
+		http.Error(w, "usd_amount must be positive", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	source, err := pg.db.GetApplicationPaymentDetails(ctx, sourceID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	if source.PosterWalletAddress == nil || *source.PosterWalletAddress == "" || source.ApplicantWalletAddress == nil || *source.ApplicantWalletAddress == "" {
+		http.Error(w, "The job's client and freelancer must both have a wallet address", http.StatusConflict)
+		return
+	}
+	usdAmount := req.USDAmount
+	if usdAmount == 0 {
+		if source.AgreedUSDAmount == nil || *source.AgreedUSDAmount <= 0 {
+			http.Error(w, "The job has no agreed USD amount to copy; give usd_amount", http.StatusBadRequest)
+			return
+		}
+		usdAmount = *source.AgreedUSDAmount
+	}
+
+	// Priced before anything is written, so a failing feed leaves no clone behind
+	price, err := pg.oracle.GetETHUSDPrice(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get ETH price", err)
+		return
+	}
+
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+	applicationID, err := pg.db.CloneApplication(ctx, sourceID, usdAmount, actor)
+	if err != nil {
+		writeServerError(w, "Failed to clone job", err)
+		return
+	}
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	tags, err := pg.db.GetJobTags(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get job tags", err)
+		return
+	}
+	currencies, err := pg.db.GetDisplayCurrencies(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get display currencies", err)
+		return
+	}
+	webhook, err := pg.db.GetJobWebhook(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get job webhook", err)
+		return
+	}
+
+	response := CloneJobResponse{
+		JobID:             uint64(applicationID),
+		ApplicationID:     applicationID,
+		ClonedFrom:        sourceJobID,
+		USDAmount:         usdAmount,
+		ClientAddress:     *source.PosterWalletAddress,
+		FreelancerAddress: *source.ApplicantWalletAddress,
+		PaymentStatus:     details.PaymentStatus,
+		Tags:              tags,
+		DisplayCurrencies: currencies,
+		WebhookURL:        webhook,
+		Quote:             pg.newQuote(localeFor(r), pricingSpot, big.NewInt(int64(usdAmount)), price),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// confirmation is the transition a legacy confirm endpoint or an indexed
+// escrow event records
+type confirmation struct {
+	name   string                 // "deposit", "release" or "refund"
+	status paymentstatus.Status   // status the confirmation sets
+	from   []paymentstatus.Status // statuses it can be applied from
+	done   []paymentstatus.Status // statuses at or past it, answered without a change
+}
+
+var (
+	depositConfirmation = confirmation{
+		name:   "deposit",
+		status: paymentstatus.Deposited,
+		from:   []paymentstatus.Status{paymentstatus.PendingDeposit, paymentstatus.DepositInitiated},
+		done:   []paymentstatus.Status{paymentstatus.Deposited, paymentstatus.ReleaseInitiated, paymentstatus.Released, paymentstatus.ReleaseFailed, paymentstatus.RefundInitiated, paymentstatus.Refunded, paymentstatus.RefundFailed},
+	}
+	releaseConfirmation = confirmation{
+		name:   "release",
+		status: paymentstatus.Released,
+		from:   []paymentstatus.Status{paymentstatus.Deposited, paymentstatus.ReleaseInitiated},
+		done:   []paymentstatus.Status{paymentstatus.Released},
+	}
+	refundConfirmation = confirmation{
+		name:   "refund",
+		status: paymentstatus.Refunded,
+		from:   []paymentstatus.Status{paymentstatus.RefundInitiated},
+		done:   []paymentstatus.Status{paymentstatus.Refunded},
+	}
+)
+
+// ConfirmResponse is the payment status after a confirm call
+type ConfirmResponse struct {
+	Success       bool                 `json:"success"`
+	PaymentStatus paymentstatus.Status `json:"payment_status"`
+	Changed       bool                 `json:"changed"` // false when the call repeated an earlier confirmation
+}
+
+// StatusConflictResponse explains why a confirmation can't be applied, e.g.
+// a release confirmed before its deposit
+type StatusConflictResponse struct {
+	Error           string                 `json:"error"`
+	Code            string                 `json:"code"` // always "out_of_order"
+	PaymentStatus   paymentstatus.Status   `json:"payment_status"`
+	AllowedStatuses []paymentstatus.Status `json:"allowed_statuses"` // statuses the confirmation can be applied from
+}
+
+// confirmStatus applies a platform confirmation once. Repeats answer with the
+// current status, and a confirmation that doesn't follow from the current
+// status gets a 409 with a StatusConflictResponse.
+func (pg *PaymentGateway) confirmStatus(ctx context.Context, w http.ResponseWriter, applicationID int32, c confirmation) {
+	for attempt := 0; ; attempt++ {
+		details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+		if err != nil {
+			writeServerError(w, "Failed to get application details", err)
+			return
+		}
+
+		current := details.PaymentStatus
+		if slices.Contains(c.done, current) {
+			writeConfirmResponse(w, ConfirmResponse{Success: true, PaymentStatus: current})
+			return
+		}
+		if !slices.Contains(c.from, current) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(StatusConflictResponse{
+				Error:           fmt.Sprintf("Cannot confirm %s: payment status is '%s'", c.name, current),
+				Code:            "out_of_order",
+				PaymentStatus:   current,
+				AllowedStatuses: c.from,
+			})
+			return
+		}
+
+		change := database.StatusChange{ApplicationID: applicationID, Status: c.status, Actor: database.ActorPlatform, FromStatuses: c.from}
+		err = pg.db.ApplyStatusChange(ctx, change)
+		// Another call moved the status first; answer from the new status
+		if errors.Is(err, database.ErrStatusConflict) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			writeServerError(w, "Failed to update payment status", err)
+			return
+		}
+
+		writeConfirmResponse(w, ConfirmResponse{Success: true, PaymentStatus: c.status, Changed: true})
+		return
+	}
+}
+
+func writeConfirmResponse(w http.ResponseWriter, response ConfirmResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
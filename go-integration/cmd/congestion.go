@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recent inclusion times are kept for inclusionWindow, and at most
+// maxInclusionSamples of them
+const (
+	inclusionWindow     = 30 * time.Minute
+	maxInclusionSamples = 50
+)
+
+// congestionHeader pre-warns callers of mutating endpoints that their
+// transactions are likely to be slow to mine
+const congestionHeader = "X-Network-Congestion"
+
+// CongestionHealth is the latest congestion check: how full and expensive
+// the chain's blocks are, how busy the node's pending pool is, and how long
+// the gateway's own transactions recently took to be mined
+type CongestionHealth struct {
+	Congested              bool      `json:"congested"`
+	Reasons                []string  `json:"reasons,omitempty"`
+	Block                  uint64    `json:"block"`
+	BaseFeeWei             string    `json:"base_fee_wei,omitempty"` // absent on networks without EIP-1559
+	GasUsedPercent         float64   `json:"gas_used_percent"`
+	PendingTransactions    *uint     `json:"pending_transactions,omitempty"` // absent when the node won't say
+	RecentInclusions       int       `json:"recent_inclusions"`
+	MedianInclusionSeconds *float64  `json:"median_inclusion_seconds,omitempty"` // absent without recent inclusions
+	CheckedAt              time.Time `json:"checked_at"`
+}
+
+// inclusionTimes remembers how long the gateway's recent transactions took
+// from sending until they were mined
+type inclusionTimes struct {
+	mu      sync.Mutex
+	samples []inclusionSample
+}
+
+type inclusionSample struct {
+	at   time.Time
+	took time.Duration
+}
+
+func (t *inclusionTimes) record(at time.Time, took time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, inclusionSample{at: at, took: took})
+	if len(t.samples) > maxInclusionSamples {
+		t.samples = slices.Delete(t.samples, 0, len(t.samples)-maxInclusionSamples)
+	}
+}
+
+// median returns the median of the samples within inclusionWindow of now
+// and how many there were
+func (t *inclusionTimes) median(now time.Time) (time.Duration, int) {
+	t.mu.Lock()
+	var recent []time.Duration
+	for _, sample := range t.samples {
+		if now.Sub(sample.at) <= inclusionWindow {
+			recent = append(recent, sample.took)
+		}
+	}
+	t.mu.Unlock()
+
+	if len(recent) == 0 {
+		return 0, 0
+	}
+	slices.Sort(recent)
+	if len(recent)%2 == 1 {
+		return recent[len(recent)/2], len(recent)
+	}
+	return (recent[len(recent)/2-1] + recent[len(recent)/2]) / 2, len(recent)
+}
+
+// runCongestionMonitor checks the network every CONGESTION_SAMPLE_INTERVAL
+func (pg *PaymentGateway) runCongestionMonitor(ctx context.Context) {
+	if pg.config.CongestionSampleInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.CongestionSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		if health := pg.checkCongestion(ctx); health != nil {
+			previous := pg.congestion.Swap(health)
+			switch {
+			case health.Congested && (previous == nil || !previous.Congested):
+				log.Printf("Warning: Network congested: %s", strings.Join(health.Reasons, "; "))
+			case !health.Congested && previous != nil && previous.Congested:
+				log.Printf("Network congestion cleared")
+			}
+		}
+		pg.markWorkerRun("congestion_monitor", pg.config.CongestionSampleInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkCongestion reads the latest block and the pending pool and compares
+// them and the recent inclusion times with the CONGESTION_* thresholds. It
+// returns nil when the latest block can't be read.
+func (pg *PaymentGateway) checkCongestion(ctx context.Context) *CongestionHealth {
+	rpcCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	block, err := pg.client.LatestBlockGas(rpcCtx)
+	if err != nil {
+		log.Printf("Warning: Failed to get the latest block for congestion: %v", err)
+		return nil
+	}
+	now := time.Now()
+	health := &CongestionHealth{Block: block.Number, CheckedAt: now}
+	congested := func(format string, args ...any) {
+		health.Congested = true
+		health.Reasons = append(health.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	if block.BaseFee != nil {
+		health.BaseFeeWei = block.BaseFee.String()
+		if pg.config.CongestionBaseFee > 0 && block.BaseFee.Cmp(new(big.Int).Mul(big.NewInt(pg.config.CongestionBaseFee), big.NewInt(1e9))) > 0 {
+			congested("base fee %s gwei is above %d gwei", formatGwei(block.BaseFee), pg.config.CongestionBaseFee)
+		}
+	}
+	if block.GasLimit > 0 {
+		health.GasUsedPercent = 100 * float64(block.GasUsed) / float64(block.GasLimit)
+		if pg.config.CongestionGasUsedPercent > 0 && health.GasUsedPercent >= float64(pg.config.CongestionGasUsedPercent) {
+			congested("block %d used %.0f%% of its gas limit", block.Number, health.GasUsedPercent)
+		}
+	}
+
+	if pending, err := pg.client.PendingTransactionCount(rpcCtx); err != nil {
+		log.Printf("Warning: Failed to get the pending transaction count: %v", err)
+	} else {
+		health.PendingTransactions = &pending
+		if pg.config.CongestionPendingTxs > 0 && int64(pending) > pg.config.CongestionPendingTxs {
+			congested("%d transactions pending, above %d", pending, pg.config.CongestionPendingTxs)
+		}
+	}
+
+	if median, n := pg.inclusions.median(now); n > 0 {
+		seconds := median.Seconds()
+		health.RecentInclusions, health.MedianInclusionSeconds = n, &seconds
+		if pg.config.CongestionInclusionTime > 0 && median > pg.config.CongestionInclusionTime {
+			congested("recent transactions took %s to be mined, above %s", median.Round(time.Second), pg.config.CongestionInclusionTime)
+		}
+	}
+	return health
+}
+
+// formatGwei renders wei as Gwei with two decimals
+func formatGwei(wei *big.Int) string {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e9)).Float64()
+	return fmt.Sprintf("%.2f", gwei)
+}
+
+// recordInclusion remembers how long a mined transaction took for the
+// congestion check
+func (pg *PaymentGateway) recordInclusion(took time.Duration) {
+	if took > 0 {
+		pg.inclusions.record(time.Now(), took)
+	}
+}
+
+// warnOfCongestion sets X-Network-Congestion on mutating requests while the
+// latest check found the network congested, so callers can expect slow
+// transactions before they wait on one
+func (pg *PaymentGateway) warnOfCongestion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if health := pg.congestion.Load(); health != nil && health.Congested {
+				w.Header().Set(congestionHeader, "slow; "+strings.Join(health.Reasons, "; "))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
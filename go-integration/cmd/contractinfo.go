@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ContractConfigResponse is what the escrow contract the gateway sends to is
+// configured with, read from the chain, next to what the gateway expects
+type ContractConfigResponse struct {
+	NetworkID     int64  `json:"network_id"`
+	Address       string `json:"address"`
+	BlockNumber   uint64 `json:"block_number"` // every value was read at this block
+	Owner         string `json:"owner"`
+	Signer        string `json:"signer"`                 // the gateway's hot key
+	AdminSigner   string `json:"admin_signer,omitempty"` // the hardware signer, when ADMIN_SIGNER is set
+	SignerIsOwner bool   `json:"signer_is_owner"`        // the owner is one of the gateway's signers
+	FeePercent    int64  `json:"fee_percent"`
+
+	PriceFeed           string `json:"price_feed"`            // the Chainlink feed the contract converts with
+	ConfiguredPriceFeed string `json:"configured_price_feed"` // ETH_USD_PRICE_FEED
+	PriceFeedMatches    bool   `json:"price_feed_matches"`
+
+	Pausable bool  `json:"pausable"`
+	Paused   *bool `json:"paused"` // null when the contract cannot be paused
+
+	CodeHash string `json:"code_hash"` // keccak256 of the runtime bytecode
+	CodeSize int    `json:"code_size"`
+
+	Proxy                  bool   `json:"proxy"`
+	Implementation         string `json:"implementation,omitempty"`
+	ImplementationCodeHash string `json:"implementation_code_hash,omitempty"`
+	ProxyAdmin             string `json:"proxy_admin,omitempty"`
+	Beacon                 string `json:"beacon,omitempty"`
+	ExpectedImplementation *bool  `json:"expected_implementation,omitempty"` // against EXPECTED_IMPLEMENTATION_ADDRESS, when set
+
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// GET /contract-info - The escrow contract's on-chain configuration
+func (pg *PaymentGateway) contractInfoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	info, err := pg.client.GetContractConfig(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to read contract configuration", err)
+		return
+	}
+
+	response := ContractConfigResponse{
+		NetworkID:           pg.config.NetworkID,
+		Address:             info.Address.Hex(),
+		BlockNumber:         info.BlockNumber,
+		Owner:               info.Owner.Hex(),
+		Signer:              pg.client.Address().Hex(),
+		SignerIsOwner:       info.Owner == pg.client.Address(),
+		FeePercent:          info.FeePercent.Int64(),
+		PriceFeed:           info.PriceFeed.Hex(),
+		ConfiguredPriceFeed: common.HexToAddress(pg.config.ETHUSDPriceFeed).Hex(),
+		PriceFeedMatches:    info.PriceFeed == common.HexToAddress(pg.config.ETHUSDPriceFeed),
+		Pausable:            info.Paused != nil,
+		Paused:              info.Paused,
+		CodeHash:            info.CodeHash.Hex(),
+		CodeSize:            info.CodeSize,
+		CheckedAt:           time.Now(),
+	}
+	if admin := pg.client.AdminAddress(); admin != (common.Address{}) {
+		response.AdminSigner = admin.Hex()
+		response.SignerIsOwner = response.SignerIsOwner || info.Owner == admin
+	}
+
+	if proxy := info.Proxy; proxy != nil && proxy.IsProxy() {
+		response.Proxy = true
+		response.Implementation = proxy.Implementation.Hex()
+		if info.ImplementationCodeHash != nil {
+			response.ImplementationCodeHash = info.ImplementationCodeHash.Hex()
+		}
+		if proxy.Admin != (common.Address{}) {
+			response.ProxyAdmin = proxy.Admin.Hex()
+		}
+		if proxy.Beacon != (common.Address{}) {
+			response.Beacon = proxy.Beacon.Hex()
+		}
+		if pg.config.ExpectedImplementation != "" {
+			expected := proxy.Implementation == common.HexToAddress(pg.config.ExpectedImplementation)
+			response.ExpectedImplementation = &expected
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/contractupdate"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+)
+
+// ContractUpdateResponse is the escrow contract the gateway moved onto
+type ContractUpdateResponse struct {
+	ContractAddress string    `json:"contract_address"`
+	PreviousAddress string    `json:"previous_address"`
+	IssuedAt        time.Time `json:"issued_at"`
+	AppliedAt       time.Time `json:"applied_at"`
+}
+
+// POST /admin/contract - Move onto a redeployed escrow contract from a signed update
+func (pg *PaymentGateway) updateContractHandler(w http.ResponseWriter, r *http.Request) {
+	if pg.contractUpdates == nil {
+		http.Error(w, "Contract updates are disabled: CONTRACT_UPDATE_SIGNER is not set", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodyBytes))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	update, err := pg.contractUpdates.Verify(body, r.Header.Get(contractupdate.SignatureHeader), time.Now())
+	switch {
+	case errors.Is(err, contractupdate.ErrMissing), errors.Is(err, contractupdate.ErrBadSignature):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A signed update can't be replayed to move back onto an older contract
+	latest, err := pg.db.LatestContractUpdate(ctx, pg.config.NetworkID)
+	if err != nil {
+		writeServerError(w, "Failed to get contract updates", err)
+		return
+	}
+	if latest != nil && !update.IssuedAt.After(latest.IssuedAt) {
+		http.Error(w, contractupdate.ErrSuperseded.Error(), http.StatusConflict)
+		return
+	}
+
+	address := common.HexToAddress(update.ContractAddress)
+	if address == pg.client.ContractAddress() {
+		http.Error(w, fmt.Sprintf("Contract %s is already in use", address.Hex()), http.StatusConflict)
+		return
+	}
+
+	previous, err := pg.client.SwapContract(ctx, address, update.ABI)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Contract update rejected: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	record := &database.ContractUpdate{
+		NetworkID:       update.NetworkID,
+		ContractAddress: address.Hex(),
+		PreviousAddress: previous.Hex(),
+		Payload:         body,
+		Signature:       r.Header.Get(contractupdate.SignatureHeader),
+		IssuedAt:        update.IssuedAt,
+		Actor:           actor,
+	}
+	if err := pg.db.RecordContractUpdate(ctx, record, update.Reason); err != nil {
+		// Without a record the update would be lost on restart, so undo it
+		if _, swapErr := pg.client.SwapContract(ctx, previous, []byte(contracts.EthJobEscrowMetaData.ABI)); swapErr != nil {
+			log.Printf("ALERT: Failed to restore contract %s after an unrecorded update to %s: %v", previous.Hex(), address.Hex(), swapErr)
+		}
+		writeServerError(w, "Failed to record contract update", err)
+		return
+	}
+	log.Printf("Contract updated from %s to %s by %s: %s", previous.Hex(), address.Hex(), actor, update.Reason)
+
+	// Refresh the proxy info /health reports for the new address
+	var alerted common.Address
+	pg.checkProxy(ctx, &alerted)
+
+	pg.notify(events.ContractUpdated, events.ContractUpdate{
+		ContractAddress: address.Hex(),
+		PreviousAddress: previous.Hex(),
+		IssuedAt:        update.IssuedAt,
+		Actor:           actor,
+		Reason:          update.Reason,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ContractUpdateResponse{
+		ContractAddress: address.Hex(),
+		PreviousAddress: previous.Hex(),
+		IssuedAt:        update.IssuedAt,
+		AppliedAt:       record.CreatedAt,
+	})
+}
+
+// applyStoredContractUpdate reapplies the last contract update at startup so
+// a restart doesn't move escrows back onto the contract it replaced. The
+// update only applies while CONTRACT_ADDRESS still names that contract; once
+// the configuration has been rolled forward, the configured address wins.
+func (pg *PaymentGateway) applyStoredContractUpdate(ctx context.Context) error {
+	if pg.contractUpdates == nil {
+		return nil
+	}
+
+	latest, err := pg.db.LatestContractUpdate(ctx, pg.config.NetworkID)
+	if err != nil {
+		return fmt.Errorf("failed to get contract updates: %w", err)
+	}
+	if latest == nil || common.HexToAddress(latest.PreviousAddress) != pg.client.ContractAddress() {
+		return nil
+	}
+
+	update, err := pg.contractUpdates.VerifyStored(latest.Payload, latest.Signature)
+	if err != nil {
+		return fmt.Errorf("stored contract update %d no longer verifies: %w", latest.ID, err)
+	}
+	if _, err := pg.client.SwapContract(ctx, common.HexToAddress(update.ContractAddress), update.ABI); err != nil {
+		return fmt.Errorf("failed to reapply contract update %d: %w", latest.ID, err)
+	}
+	log.Printf("Reapplied contract update %d: using %s instead of CONTRACT_ADDRESS %s", latest.ID, latest.ContractAddress, latest.PreviousAddress)
+	return nil
+}
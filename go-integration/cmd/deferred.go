@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/explorer"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/workpool"
+)
+
+// DeferredOperationResponse describes an operation queued until gas prices drop
+// or a transient RPC failure clears
+type DeferredOperationResponse struct {
+	OperationID   int64      `json:"operation_id"`
+	ApplicationID int32      `json:"application_id"`
+	Operation     string     `json:"operation"`
+	Status        string     `json:"status"`
+	Deadline      time.Time  `json:"deadline"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // not submitted before then
+	Attempts      int        `json:"attempts"`
+	TxHash        string     `json:"tx_hash,omitempty"`
+	TxURL         string     `json:"tx_url,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	TraceID       string     `json:"trace_id,omitempty"` // of the request that asked for the operation
+}
+
+func newDeferredOperationResponse(op *database.DeferredOperation, links explorer.Links) DeferredOperationResponse {
+	response := DeferredOperationResponse{
+		OperationID:   op.ID,
+		ApplicationID: op.ApplicationID,
+		Operation:     op.Operation,
+		Status:        op.Status,
+		Deadline:      op.Deadline,
+		NextAttemptAt: op.NextAttemptAt,
+		Attempts:      op.Attempts,
+		TraceID:       op.Params.TraceID,
+	}
+	if op.TxHash != nil {
+		response.TxHash = *op.TxHash
+		response.TxURL = links.Tx(*op.TxHash)
+	}
+	if op.LastError != nil {
+		response.Error = *op.LastError
+	}
+	return response
+}
+
+// checkNoDeferredOperation rejects a new mutation while an earlier one is still queued.
+// It returns false after writing an error response.
+func (pg *PaymentGateway) checkNoDeferredOperation(ctx context.Context, w http.ResponseWriter, applicationID int32) bool {
+	op, err := pg.db.GetPendingDeferredOperation(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to check deferred operations", err)
+		return false
+	}
+	if op != nil {
+		http.Error(w, fmt.Sprintf("Operation %s is already deferred until %s", op.Operation, op.Deadline.Format(time.RFC3339)), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// queueIfRetryable queues the operation when err is transient and the matching
+// queueing mode is enabled: gas ceiling violations are deferred when
+// DEFER_ON_HIGH_GAS is set, other retryable RPC failures when
+// RETRY_FAILED_OPERATIONS is set. It returns true if a response has been written.
+func (pg *PaymentGateway) queueIfRetryable(ctx context.Context, w http.ResponseWriter, applicationID int32, operation string, params database.OperationParams, err error) bool {
+	op, queued, dbErr := pg.queueRetryable(ctx, applicationID, operation, params, err)
+	if !queued {
+		return false
+	}
+	if dbErr != nil {
+		http.Error(w, fmt.Sprintf("%s and queueing failed: %v", payment.ClassifyError(err).Message, dbErr), http.StatusInternalServerError)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newDeferredOperationResponse(op, pg.explorer))
+	return true
+}
+
+// queueRetryable is queueIfRetryable without the response. queued is false
+// when err is not one the enabled queueing modes take; dbErr is set when it
+// is but the operation could not be stored.
+func (pg *PaymentGateway) queueRetryable(ctx context.Context, applicationID int32, operation string, params database.OperationParams, err error) (op *database.DeferredOperation, queued bool, dbErr error) {
+	// A saturated pool is shed back to the caller; queueing would only add to the
+	// load. A halt lasts until an admin re-arms signing, not a retry window. An
+	// unresolved intent is settled by recovery, which requeues it if need be.
+	if errors.Is(err, workpool.ErrQueueFull) || errors.Is(err, errSigningHalted) || errors.Is(err, database.ErrIntentPending) {
+		return nil, false, nil
+	}
+
+	classified := payment.ClassifyError(err)
+	if !classified.Retryable() {
+		return nil, false, nil
+	}
+
+	var deadline time.Time
+	switch {
+	case classified.Reason == payment.ReasonGasPriceTooHigh && pg.config.DeferOnHighGas:
+		deadline = time.Now().Add(pg.config.DeferDeadline)
+	case classified.Reason != payment.ReasonGasPriceTooHigh && pg.config.RetryFailedOperations:
+		deadline = time.Now().Add(pg.retryWindow())
+	default:
+		return nil, false, nil
+	}
+
+	op, dbErr = pg.db.CreateDeferredOperation(ctx, applicationID, operation, params, deadline, classified.Error())
+	if dbErr != nil {
+		return nil, true, dbErr
+	}
+
+	log.Printf("%sQueued %s for application %d: %v", tracePrefix(params.TraceID), operation, applicationID, classified)
+	pg.notifyJob(op.ApplicationID, op.Params.TraceID, events.OperationDeferred, operationEvent(op, pg.explorer))
+	return op, true, nil
+}
+
+// retryWindow is the longest a retried operation can stay queued with exponential backoff
+func (pg *PaymentGateway) retryWindow() time.Duration {
+	window := time.Duration(0)
+	for attempt := 0; attempt < pg.config.MaxOperationAttempts; attempt++ {
+		window += pg.config.RetryBackoff << attempt
+	}
+	return window + pg.config.DeferredPollInterval
+}
+
+// ContractErrorResponse is returned with 422 when the escrow contract rejects
+// an operation
+type ContractErrorResponse struct {
+	Error         string `json:"error"`
+	Code          string `json:"code"`                     // e.g. job_already_completed; contract_error when unrecognised
+	ContractError string `json:"contract_error,omitempty"` // the Solidity error, e.g. JobAlreadyCompleted
+	Reason        string `json:"reason,omitempty"`         // the revert reason string, for Error
+}
+
+// writeChainError reports a failed chain operation with a status code and
+// actionable message derived from its classification
+func (pg *PaymentGateway) writeChainError(w http.ResponseWriter, prefix string, result *payment.TransactionResult, err error) {
+	if errors.Is(err, workpool.ErrQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, fmt.Sprintf("%s: gateway is busy submitting other transactions", prefix), http.StatusServiceUnavailable)
+		return
+	}
+
+	if errors.Is(err, errReleaseNotAuthorized) {
+		http.Error(w, fmt.Sprintf("%s: %v", prefix, err), http.StatusConflict)
+		return
+	}
+
+	if errors.Is(err, errSigningHalted) {
+		http.Error(w, fmt.Sprintf("%s: %v", prefix, err), http.StatusServiceUnavailable)
+		return
+	}
+
+	if errors.Is(err, database.ErrIntentPending) {
+		http.Error(w, fmt.Sprintf("%s: %v", prefix, err), http.StatusConflict)
+		return
+	}
+
+	classified := payment.ClassifyError(err)
+
+	switch classified.Reason {
+	case payment.ReasonPending:
+		// The transaction is on its way; report it rather than an error so callers don't resubmit
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(TransactionResponse{
+			TxHash:  result.TxHash,
+			TxURL:   pg.explorer.Tx(result.TxHash),
+			Success: false,
+			Error:   classified.Message,
+		})
+		return
+	case payment.ReasonReverted:
+		response := ContractErrorResponse{Error: fmt.Sprintf("%s: %s", prefix, classified), Code: payment.CodeContractError}
+		if classified.Contract != nil {
+			response.Code = classified.Contract.Code
+			response.ContractError = classified.Contract.Name
+			response.Reason = classified.Contract.Reason
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(response)
+	case payment.ReasonJobConflict, payment.ReasonPriceDeviation:
+		http.Error(w, fmt.Sprintf("%s: %s", prefix, classified), http.StatusConflict)
+	case payment.ReasonInvalidParams:
+		http.Error(w, fmt.Sprintf("%s: %s", prefix, classified), http.StatusBadRequest)
+	case payment.ReasonInsufficientFunds:
+		http.Error(w, fmt.Sprintf("%s: %s", prefix, classified), http.StatusServiceUnavailable)
+	default:
+		if classified.Retryable() {
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, fmt.Sprintf("%s: %s", prefix, classified), http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, fmt.Sprintf("%s: %s", prefix, classified), http.StatusInternalServerError)
+	}
+}
+
+// runDeferredOperations periodically submits queued operations once gas is back
+// under the ceiling or their retry backoff has elapsed, first lapsing release
+// authorizations that expired so their queued releases are dropped
+func (pg *PaymentGateway) runDeferredOperations(ctx context.Context) {
+	ticker := time.NewTicker(pg.config.DeferredPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pg.lapseReleaseAuthorizations(ctx)
+			pg.processDeferredOperations(ctx)
+			pg.markWorkerRun("deferred_operations", pg.config.DeferredPollInterval)
+		}
+	}
+}
+
+func (pg *PaymentGateway) processDeferredOperations(ctx context.Context) {
+	if pg.maintenance.Load() != nil || pg.signingHalt.Load() != nil {
+		return
+	}
+
+	ops, err := pg.db.ListDueDeferredOperations(ctx)
+	if err != nil {
+		log.Printf("Failed to list deferred operations: %v", err)
+		return
+	}
+	if len(ops) == 0 {
+		return
+	}
+
+	gasPrice, err := pg.client.SuggestGasPrice(ctx)
+	if err != nil {
+		log.Printf("Failed to get gas price for deferred operations: %v", err)
+		return
+	}
+	for _, op := range ops {
+		if time.Now().After(op.Deadline) {
+			pg.finishDeferredOperation(ctx, op, database.DeferredStatusExpired, nil, "operation could not be submitted before its deadline")
+			continue
+		}
+
+		if ceiling := pg.gasCeiling(op.Params); ceiling != nil && gasPrice.Cmp(ceiling) > 0 {
+			continue
+		}
+
+		opCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		result, err := pg.submitOperation(opCtx, op.ApplicationID, op.Operation, op.Params)
+		cancel()
+
+		if err == nil {
+			pg.finishDeferredOperation(ctx, op, database.DeferredStatusSubmitted, &result.TxHash, "")
+			continue
+		}
+		if errors.Is(err, workpool.ErrQueueFull) || errors.Is(err, errSigningHalted) {
+			// Live requests have the pool, or nothing may be signed; the rest of the batch waits for the next tick
+			break
+		}
+		if errors.Is(err, errReleaseNotAuthorized) {
+			pg.finishDeferredOperation(ctx, op, database.DeferredStatusExpired, nil, err.Error())
+			continue
+		}
+		if errors.Is(err, database.ErrIntentPending) {
+			// An earlier attempt may have been sent; wait for recovery to find out
+			continue
+		}
+
+		classified := payment.ClassifyError(err)
+		switch {
+		case classified.Reason == payment.ReasonPending:
+			// Broadcast but unconfirmed; the hash is recorded and must not be resent
+			pg.finishDeferredOperation(ctx, op, database.DeferredStatusSubmitted, &result.TxHash, classified.Message)
+		case classified.Reason == payment.ReasonGasPriceTooHigh:
+			// Gas rose again between the check and submission; try next tick
+		case classified.Reason == payment.ReasonPriceDeviation:
+			// The client decides whether to fund at the new rate
+			pg.finishDeferredOperation(ctx, op, database.DeferredStatusPaused, nil, classified.Error())
+		case classified.Retryable() && op.Attempts+1 < pg.config.MaxOperationAttempts:
+			attempts := op.Attempts + 1
+			next := time.Now().Add(pg.config.RetryBackoff << op.Attempts)
+			if err := pg.db.RescheduleDeferredOperation(ctx, op.ID, attempts, next, classified.Error()); err != nil {
+				log.Printf("Failed to reschedule deferred operation %d: %v", op.ID, err)
+			}
+		default:
+			pg.finishDeferredOperation(ctx, op, database.DeferredStatusFailed, nil, classified.Error())
+		}
+	}
+}
+
+// finishDeferredOperation stores the final state of a deferred operation and notifies the platform
+func (pg *PaymentGateway) finishDeferredOperation(ctx context.Context, op *database.DeferredOperation, status string, txHash *string, errMsg string) {
+	var lastError *string
+	if errMsg != "" {
+		lastError = &errMsg
+	}
+
+	if err := pg.db.UpdateDeferredOperation(ctx, op.ID, status, txHash, lastError); err != nil {
+		log.Printf("Failed to update deferred operation %d: %v", op.ID, err)
+		return
+	}
+
+	op.Status = status
+	op.TxHash = txHash
+	op.LastError = lastError
+
+	eventType := events.OperationSubmitted
+	switch status {
+	case database.DeferredStatusExpired:
+		eventType = events.OperationExpired
+	case database.DeferredStatusFailed:
+		eventType = events.OperationFailed
+	case database.DeferredStatusPaused:
+		eventType = events.OperationPaused
+	}
+
+	log.Printf("%sDeferred operation %d (%s for application %d) is now %s", tracePrefix(op.Params.TraceID), op.ID, op.Operation, op.ApplicationID, status)
+	pg.notifyJob(op.ApplicationID, op.Params.TraceID, eventType, operationEvent(op, pg.explorer))
+}
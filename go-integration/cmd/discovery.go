@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/address"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/jobid"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/retainer"
+)
+
+// DiscoveredEscrowResponse is an escrow the gateway found on-chain without a
+// payment record of its own, and what matching it to an application did
+type DiscoveredEscrowResponse struct {
+	JobID             uint64               `json:"job_id"`
+	PaymentStatus     paymentstatus.Status `json:"payment_status"`
+	ClientAddress     string               `json:"client_address"`
+	FreelancerAddress string               `json:"freelancer_address"`
+	USDAmount         string               `json:"usd_amount"`
+	ETHAmountWei      string               `json:"eth_amount_wei"`
+	TxHashDeposit     string               `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease     string               `json:"tx_hash_release,omitempty"`
+	TxHashRefund      string               `json:"tx_hash_refund,omitempty"`
+	BlockNumber       int64                `json:"block_number"`
+	ApplicationID     *int32               `json:"application_id,omitempty"`
+	Outcome           string               `json:"outcome"` // linked, unmatched, mismatch or conflict
+	Reason            string               `json:"reason,omitempty"`
+	DiscoveredAt      *time.Time           `json:"discovered_at,omitempty"`
+	LinkedAt          *time.Time           `json:"linked_at,omitempty"`
+}
+
+// DiscoverEscrowsResponse is the result of scanning a block range for escrows
+type DiscoverEscrowsResponse struct {
+	FromBlock uint64                     `json:"from_block"`
+	ToBlock   uint64                     `json:"to_block"`
+	DryRun    bool                       `json:"dry_run"`
+	Events    int                        `json:"events"`  // escrow events in the range
+	Tracked   int                        `json:"tracked"` // escrows whose deposit the gateway already recorded
+	Outcomes  map[string]int             `json:"outcomes"`
+	Escrows   []DiscoveredEscrowResponse `json:"escrows"`
+}
+
+func newDiscoveredEscrowResponse(escrow database.DiscoveredEscrow) DiscoveredEscrowResponse {
+	response := DiscoveredEscrowResponse{
+		JobID:             escrow.JobID,
+		PaymentStatus:     escrow.PaymentStatus,
+		ClientAddress:     escrow.ClientAddress,
+		FreelancerAddress: escrow.FreelancerAddress,
+		USDAmount:         escrow.USDAmount,
+		ETHAmountWei:      escrow.ETHAmountWei,
+		TxHashDeposit:     derefString(escrow.DepositTxHash),
+		TxHashRelease:     derefString(escrow.ReleaseTxHash),
+		TxHashRefund:      derefString(escrow.RefundTxHash),
+		BlockNumber:       escrow.BlockNumber,
+		ApplicationID:     escrow.ApplicationID,
+		Outcome:           escrow.Outcome,
+		Reason:            escrow.Reason,
+		LinkedAt:          escrow.LinkedAt,
+	}
+	if !escrow.DiscoveredAt.IsZero() {
+		response.DiscoveredAt = &escrow.DiscoveredAt
+	}
+	return response
+}
+
+// POST /admin/escrows/discover?from_block=&to_block=&dry_run=true - Find escrows
+// funded outside the gateway and link them to applications by job ID
+func (pg *PaymentGateway) discoverEscrowsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	fromBlock := pg.config.ContractDeployBlock
+	if raw := query.Get("from_block"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid from_block", http.StatusBadRequest)
+			return
+		}
+		fromBlock = parsed
+	}
+	var toBlock uint64
+	if raw := query.Get("to_block"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || parsed < fromBlock {
+			http.Error(w, "Invalid to_block: must be a block number not before from_block", http.StatusBadRequest)
+			return
+		}
+		toBlock = parsed
+	}
+	dryRun := query.Get("dry_run") == "true"
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	// Scanning from the deploy block can take a while on a busy contract
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if toBlock == 0 {
+		latest, err := pg.client.BlockNumber(ctx)
+		if err != nil {
+			writeServerError(w, "Failed to get latest block", err)
+			return
+		}
+		toBlock = latest
+	}
+
+	events, err := pg.client.ScanEscrowEvents(ctx, fromBlock, toBlock)
+	if err != nil {
+		writeServerError(w, "Failed to scan escrow events", err)
+		return
+	}
+
+	response := DiscoverEscrowsResponse{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		DryRun:    dryRun,
+		Events:    len(events),
+		Outcomes:  make(map[string]int),
+		Escrows:   []DiscoveredEscrowResponse{},
+	}
+	for _, found := range payment.DiscoverEscrows(events) {
+		// Retainer periods and top-ups are escrows the gateway posted itself
+		if found.JobID >= retainer.JobIDOffset {
+			continue
+		}
+
+		escrow, tracked, err := pg.matchDiscoveredEscrow(ctx, found)
+		if err != nil {
+			writeServerError(w, fmt.Sprintf("Failed to match escrow for job %d", found.JobID), err)
+			return
+		}
+		if tracked {
+			response.Tracked++
+			continue
+		}
+
+		if !dryRun {
+			if escrow.Outcome == database.DiscoveryLinked {
+				err = pg.db.LinkDiscoveredEscrow(ctx, escrow, actor)
+				switch {
+				case errors.Is(err, database.ErrStatusConflict):
+					escrow.Outcome, escrow.Reason = database.DiscoveryConflict, "application left pending_deposit during discovery"
+					err = pg.db.SaveDiscoveredEscrow(ctx, escrow)
+				case errors.Is(err, database.ErrEscrowJobConflict):
+					escrow.Outcome, escrow.Reason = database.DiscoveryConflict, err.Error()
+					err = pg.db.SaveDiscoveredEscrow(ctx, escrow)
+				case err == nil:
+					log.Printf("Linked escrow for job %d (%s) to application %d by %s", escrow.JobID, escrow.PaymentStatus, escrow.JobID, actor)
+				}
+			} else {
+				err = pg.db.SaveDiscoveredEscrow(ctx, escrow)
+			}
+			if err != nil {
+				writeServerError(w, fmt.Sprintf("Failed to record escrow for job %d", found.JobID), err)
+				return
+			}
+		}
+
+		response.Outcomes[escrow.Outcome]++
+		response.Escrows = append(response.Escrows, newDiscoveredEscrowResponse(escrow))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// matchDiscoveredEscrow compares an escrow with the application sharing its
+// job ID. tracked is true when the gateway already recorded the escrow's
+// deposit; otherwise the returned escrow carries the outcome of the match.
+func (pg *PaymentGateway) matchDiscoveredEscrow(ctx context.Context, found payment.Escrow) (escrow database.DiscoveredEscrow, tracked bool, err error) {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	escrow = database.DiscoveredEscrow{
+		JobID:             found.JobID,
+		PaymentStatus:     found.Record.PaymentStatus,
+		ClientAddress:     found.Client.Hex(),
+		FreelancerAddress: found.Freelancer.Hex(),
+		USDAmount:         found.USDAmount.String(),
+		ETHAmountWei:      found.ETHAmount.String(),
+		DepositTxHash:     optional(found.Record.DepositTxHash),
+		ReleaseTxHash:     optional(found.Record.ReleaseTxHash),
+		RefundTxHash:      optional(found.Record.RefundTxHash),
+		BlockNumber:       int64(found.BlockNumber),
+	}
+
+	applicationID, err := jobid.ApplicationID(found.JobID)
+	if err != nil {
+		escrow.Outcome, escrow.Reason = database.DiscoveryUnmatched, err.Error()
+		return escrow, false, nil
+	}
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		escrow.Outcome, escrow.Reason = database.DiscoveryUnmatched, "no application with this ID"
+		return escrow, false, nil
+	}
+	if err != nil {
+		return escrow, false, err
+	}
+
+	if strings.EqualFold(derefString(details.EscrowTxHashDeposit), found.Record.DepositTxHash) {
+		return escrow, true, nil
+	}
+	escrow.ApplicationID = &applicationID
+
+	switch {
+	case details.PaymentStatus != paymentstatus.PendingDeposit:
+		escrow.Outcome = database.DiscoveryConflict
+		escrow.Reason = fmt.Sprintf("application is %s with another deposit; use POST /jobs/%d/resync", details.PaymentStatus, found.JobID)
+	case !address.Equal(derefString(details.PosterWalletAddress), escrow.ClientAddress):
+		escrow.Outcome = database.DiscoveryMismatch
+		escrow.Reason = fmt.Sprintf("escrow client %s is not the poster's wallet %q", escrow.ClientAddress, derefString(details.PosterWalletAddress))
+	case !address.Equal(derefString(details.ApplicantWalletAddress), escrow.FreelancerAddress):
+		escrow.Outcome = database.DiscoveryMismatch
+		escrow.Reason = fmt.Sprintf("escrow freelancer %s is not the applicant's wallet %q", escrow.FreelancerAddress, derefString(details.ApplicantWalletAddress))
+	case details.AgreedUSDAmount != nil && strconv.Itoa(int(*details.AgreedUSDAmount)) != escrow.USDAmount:
+		escrow.Outcome = database.DiscoveryMismatch
+		escrow.Reason = fmt.Sprintf("escrow holds $%s but the agreed amount is $%d", escrow.USDAmount, *details.AgreedUSDAmount)
+	default:
+		escrow.Outcome = database.DiscoveryLinked
+	}
+	return escrow, false, nil
+}
+
+// GET /admin/escrows/discovered?outcome[in]=unmatched,conflict&sort=-discovered_at - Escrows recorded by discovery
+func (pg *PaymentGateway) listDiscoveredEscrowsHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := database.DiscoveredEscrowListSpec.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// ?unlinked=true predates the outcome filter
+	if r.URL.Query().Get("unlinked") == "true" && !params.Filters("outcome") {
+		params.Conditions = append(params.Conditions, database.DiscoveredEscrowListSpec.Where("outcome", listquery.Ne, database.DiscoveryLinked))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	escrows, next, err := pg.db.FindDiscoveredEscrows(ctx, params)
+	if err != nil {
+		writeServerError(w, "Failed to list discovered escrows", err)
+		return
+	}
+
+	response := make([]DiscoveredEscrowResponse, len(escrows))
+	for i, escrow := range escrows {
+		response[i] = newDiscoveredEscrowResponse(escrow)
+	}
+
+	writeListPage(w, response, next)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math/big"
+	"net/http"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/format"
+)
+
+// localeFor picks the display locale for a request: ?locale= if given,
+// otherwise the Accept-Language header
+func localeFor(r *http.Request) format.Locale {
+	if tag := r.URL.Query().Get("locale"); tag != "" {
+		return format.Lookup(tag)
+	}
+	return format.FromAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// displayUSD formats a decimal dollar string such as a stored cost_usd, or
+// returns "" if it is not a number
+func displayUSD(l format.Locale, usd string) string {
+	amount, ok := new(big.Rat).SetString(usd)
+	if !ok {
+		return ""
+	}
+	return l.USD(amount)
+}
+
+// displayWei formats a decimal wei string in ether, or returns "" if it is not
+// an integer
+func displayWei(l format.Locale, wei string) string {
+	amount, ok := new(big.Int).SetString(wei, 10)
+	if !ok {
+		return ""
+	}
+	return l.ETH(amount)
+}
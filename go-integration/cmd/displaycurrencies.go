@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/format"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/fx"
+)
+
+// DisplayCurrenciesResponse is the currencies a job's parties see its amount in
+type DisplayCurrenciesResponse struct {
+	ApplicationID int32 `json:"application_id"`
+	database.DisplayCurrencies
+}
+
+// GET /jobs/{id}/display-currencies - A job's display currency preferences
+func (pg *PaymentGateway) getDisplayCurrenciesHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	currencies, err := pg.db.GetDisplayCurrencies(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get display currencies", err)
+		return
+	}
+	response := DisplayCurrenciesResponse{ApplicationID: applicationID}
+	if currencies != nil {
+		response.DisplayCurrencies = *currencies
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PUT /jobs/{id}/display-currencies - Set the currencies a job's client and
+// freelancer see its amount in. An empty code clears that party's preference.
+func (pg *PaymentGateway) setDisplayCurrenciesHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req database.DisplayCurrencies
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var currencies database.DisplayCurrencies
+	for _, field := range []struct {
+		name     string
+		code     string
+		currency *string
+	}{
+		{"client", req.Client, &currencies.Client},
+		{"freelancer", req.Freelancer, &currencies.Freelancer},
+	} {
+		if field.code == "" {
+			continue
+		}
+		currency, err := pg.checkDisplayCurrency(ctx, field.code)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s currency: %v", field.name, err), http.StatusBadRequest)
+			return
+		}
+		*field.currency = currency
+	}
+
+	if _, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID); err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+	if err := pg.db.SetDisplayCurrencies(ctx, applicationID, currencies, actor); err != nil {
+		writeServerError(w, "Failed to set display currencies", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DisplayCurrenciesResponse{ApplicationID: applicationID, DisplayCurrencies: currencies})
+}
+
+// checkDisplayCurrency normalizes code and checks there is a rate for it
+func (pg *PaymentGateway) checkDisplayCurrency(ctx context.Context, code string) (string, error) {
+	currency, err := fx.ParseCurrency(code)
+	if err != nil {
+		return "", err
+	}
+	if currency == "USD" {
+		return currency, nil
+	}
+	if pg.fx == nil {
+		return "", errors.New("no exchange rates are configured; set FX_RATES_URL or FX_RATES")
+	}
+	if _, err := pg.fx.Rate(ctx, currency); err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to get exchange rate: %v", err)
+	}
+	return currency, nil
+}
+
+// displayAmount converts a job's USD amount into currency
+func (pg *PaymentGateway) displayAmount(ctx context.Context, l format.Locale, currency string, usdAmount int32) (*events.DisplayAmount, error) {
+	rate, err := pg.fx.Rate(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	converted := rate.Convert(big.NewRat(int64(usdAmount), 1))
+	return &events.DisplayAmount{
+		USDAmount:  usdAmount,
+		Currency:   rate.Currency,
+		Amount:     amounts.Decimal(converted, format.USDPlaces, amounts.HalfUp),
+		Display:    l.Money(converted, rate.Currency),
+		RatePerUSD: strings.TrimRight(strings.TrimRight(rate.PerUSD.FloatString(6), "0"), "."),
+		RateAsOf:   rate.AsOf,
+	}, nil
+}
+
+// jobDisplayAmounts returns a job's amount in its client's and freelancer's
+// display currencies, nil for a party without one. It is best-effort: a
+// status or webhook goes out without the conversion rather than not at all.
+func (pg *PaymentGateway) jobDisplayAmounts(ctx context.Context, l format.Locale, applicationID, usdAmount int32) (client, freelancer *events.DisplayAmount) {
+	if pg.fx == nil {
+		return nil, nil
+	}
+	currencies, err := pg.db.GetDisplayCurrencies(ctx, applicationID)
+	if err != nil {
+		log.Printf("Warning: Failed to get display currencies of application %d: %v", applicationID, err)
+		return nil, nil
+	}
+	if currencies == nil {
+		return nil, nil
+	}
+	convert := func(currency string) *events.DisplayAmount {
+		if currency == "" {
+			return nil
+		}
+		amount, err := pg.displayAmount(ctx, l, currency, usdAmount)
+		if err != nil {
+			log.Printf("Warning: Failed to convert application %d's amount to %s: %v", applicationID, currency, err)
+			return nil
+		}
+		return amount
+	}
+	return convert(currencies.Client), convert(currencies.Freelancer)
+}
+
+// addDisplayAmounts adds the job's amount in its parties' display currencies
+// to a transaction webhook
+func (pg *PaymentGateway) addDisplayAmounts(ctx context.Context, event *events.Transaction) {
+	if pg.fx == nil {
+		return
+	}
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, event.ApplicationID)
+	if err != nil {
+		log.Printf("Warning: Failed to get application %d for display amounts: %v", event.ApplicationID, err)
+		return
+	}
+	if details.AgreedUSDAmount == nil {
+		return
+	}
+	event.ClientDisplay, event.FreelancerDisplay = pg.jobDisplayAmounts(ctx, format.DefaultLocale, event.ApplicationID, *details.AgreedUSDAmount)
+}
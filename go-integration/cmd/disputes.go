@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Kinds of dispute evidence
+var evidenceKinds = map[string]bool{
+	"deliverable":     true, // work the freelancer handed over
+	"chat_transcript": true, // messages between the parties
+	"agreement":       true, // the scope or terms the job was accepted on
+	"other":           true,
+}
+
+// maxEvidenceDescription bounds an evidence description, in bytes
+const maxEvidenceDescription = 2000
+
+// OpenDisputeRequest opens a dispute on a job
+type OpenDisputeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AddEvidenceRequest attaches a reference to evidence. At least one of URI
+// and SHA256 is required.
+type AddEvidenceRequest struct {
+	Kind        string `json:"kind"`
+	URI         string `json:"uri"`    // where the material is stored, e.g. https:// or ipfs://
+	SHA256      string `json:"sha256"` // hex digest of the material
+	Description string `json:"description"`
+}
+
+// ResolveDisputeRequest records the arbitrator's decision
+type ResolveDisputeRequest struct {
+	Outcome       string `json:"outcome"` // "release", "refund" or "withdrawn"
+	Justification string `json:"justification"`
+}
+
+// DisputeResponse is the arbitrator's view of a disputed job: the dispute,
+// the evidence in the order it was submitted, and the payment it concerns
+type DisputeResponse struct {
+	Dispute  *database.Dispute           `json:"dispute"`
+	Evidence []*database.DisputeEvidence `json:"evidence"`
+
+	ApplicationID     int32                `json:"application_id"`
+	PaymentStatus     paymentstatus.Status `json:"payment_status"`
+	USDAmount         *int32               `json:"usd_amount"`
+	ClientAddress     *string              `json:"client_address"`
+	FreelancerAddress *string              `json:"freelancer_address"`
+	TxURLDeposit      string               `json:"tx_url_deposit,omitempty"`
+	Timeline          []TimelineEntry      `json:"timeline"`
+}
+
+// validateEvidence normalizes an evidence reference
+func validateEvidence(req *AddEvidenceRequest) error {
+	if !evidenceKinds[req.Kind] {
+		return fmt.Errorf("invalid kind, expected deliverable, chat_transcript, agreement or other")
+	}
+	if req.URI == "" && req.SHA256 == "" {
+		return fmt.Errorf("uri or sha256 is required")
+	}
+	if req.URI != "" {
+		u, err := url.Parse(req.URI)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "" && u.Path == "") {
+			return fmt.Errorf("uri must be an absolute URI")
+		}
+	}
+	if req.SHA256 != "" {
+		digest := strings.ToLower(strings.TrimPrefix(req.SHA256, "0x"))
+		if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != 32 {
+			return fmt.Errorf("sha256 must be 64 hex characters")
+		}
+		req.SHA256 = digest
+	}
+	if len(req.Description) > maxEvidenceDescription {
+		return fmt.Errorf("description is longer than %d bytes", maxEvidenceDescription)
+	}
+	return nil
+}
+
+// POST /jobs/{id}/dispute - Open a dispute on a job
+func (pg *PaymentGateway) openDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.Disputes) {
+		return
+	}
+
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req OpenDisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID); err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	dispute, opened, err := pg.db.OpenDispute(ctx, applicationID, req.Reason, actor)
+	if err != nil {
+		writeServerError(w, "Failed to open dispute", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !opened {
+		w.WriteHeader(http.StatusConflict)
+	} else {
+		log.Printf("Dispute %d opened on application %d by %s", dispute.ID, applicationID, actor)
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(dispute)
+}
+
+// GET /jobs/{id}/dispute - The arbitrator's view of a job's dispute
+func (pg *PaymentGateway) getDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	dispute, err := pg.db.GetDispute(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get dispute", err)
+		return
+	}
+	if dispute == nil {
+		http.Error(w, "Job has not been disputed", http.StatusNotFound)
+		return
+	}
+	evidence, err := pg.db.ListDisputeEvidence(ctx, dispute.ID)
+	if err != nil {
+		writeServerError(w, "Failed to get dispute evidence", err)
+		return
+	}
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	events, err := pg.db.GetPaymentEvents(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get payment events", err)
+		return
+	}
+
+	response := DisputeResponse{
+		Dispute:           dispute,
+		Evidence:          evidence,
+		ApplicationID:     applicationID,
+		PaymentStatus:     details.PaymentStatus,
+		USDAmount:         details.AgreedUSDAmount,
+		ClientAddress:     details.PosterWalletAddress,
+		FreelancerAddress: details.ApplicantWalletAddress,
+		Timeline:          make([]TimelineEntry, 0, len(events)),
+	}
+	if response.Evidence == nil {
+		response.Evidence = []*database.DisputeEvidence{}
+	}
+	if details.EscrowTxHashDeposit != nil {
+		response.TxURLDeposit = pg.explorer.Tx(*details.EscrowTxHashDeposit)
+	}
+	for _, event := range events {
+		entry := TimelineEntry{
+			Status:      event.Status,
+			BlockNumber: event.BlockNumber,
+			Timestamp:   event.CreatedAt,
+			Actor:       event.Actor,
+		}
+		if event.TxHash != nil {
+			entry.TxHash = *event.TxHash
+			entry.TxURL = pg.explorer.Tx(*event.TxHash)
+		}
+		response.Timeline = append(response.Timeline, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /jobs/{id}/dispute/evidence - Attach an evidence reference to a job's open dispute
+func (pg *PaymentGateway) addDisputeEvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.Disputes) {
+		return
+	}
+
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req AddEvidenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := validateEvidence(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dispute, ok := pg.openDispute(ctx, w, applicationID)
+	if !ok {
+		return
+	}
+
+	evidence := database.DisputeEvidence{
+		DisputeID:   dispute.ID,
+		Kind:        req.Kind,
+		Description: req.Description,
+		SubmittedBy: actor,
+	}
+	if req.URI != "" {
+		evidence.URI = &req.URI
+	}
+	if req.SHA256 != "" {
+		evidence.SHA256 = &req.SHA256
+	}
+	recorded, err := pg.db.AddDisputeEvidence(ctx, evidence)
+	if errors.Is(err, database.ErrDisputeResolved) {
+		http.Error(w, "The dispute has been resolved", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeServerError(w, "Failed to add dispute evidence", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(recorded)
+}
+
+// POST /jobs/{id}/dispute/resolve - Record the arbitrator's decision and its justification
+func (pg *PaymentGateway) resolveDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.Disputes) {
+		return
+	}
+
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req ResolveDisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	switch req.Outcome {
+	case database.DisputeOutcomeRelease, database.DisputeOutcomeRefund, database.DisputeOutcomeWithdrawn:
+	default:
+		http.Error(w, "Invalid outcome, expected release, refund or withdrawn", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Justification) == "" {
+		http.Error(w, "justification is required", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dispute, ok := pg.openDispute(ctx, w, applicationID)
+	if !ok {
+		return
+	}
+
+	// A decision for one party has to rest on something on record
+	if req.Outcome != database.DisputeOutcomeWithdrawn {
+		evidence, err := pg.db.ListDisputeEvidence(ctx, dispute.ID)
+		if err != nil {
+			writeServerError(w, "Failed to get dispute evidence", err)
+			return
+		}
+		if len(evidence) == 0 {
+			http.Error(w, "Attach evidence before deciding the dispute", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	resolved, err := pg.db.ResolveDispute(ctx, dispute.ID, req.Outcome, req.Justification, actor)
+	if errors.Is(err, database.ErrDisputeResolved) {
+		http.Error(w, "The dispute has been resolved", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeServerError(w, "Failed to resolve dispute", err)
+		return
+	}
+	log.Printf("Dispute %d on application %d resolved by %s: %s", resolved.ID, applicationID, actor, req.Outcome)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resolved)
+}
+
+// openDispute returns the job's open dispute, answering 404 when it has none
+// and 409 when its dispute has been resolved
+func (pg *PaymentGateway) openDispute(ctx context.Context, w http.ResponseWriter, applicationID int32) (*database.Dispute, bool) {
+	dispute, err := pg.db.GetDispute(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get dispute", err)
+		return nil, false
+	}
+	if dispute == nil {
+		http.Error(w, "Job has not been disputed", http.StatusNotFound)
+		return nil, false
+	}
+	if dispute.ResolvedAt != nil {
+		http.Error(w, "The dispute has been resolved", http.StatusConflict)
+		return nil, false
+	}
+	return dispute, true
+}
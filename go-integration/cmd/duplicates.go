@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// existingPost checks the contract for a job before it is posted, since the
+// contract reverts on a reused job ID. It returns nil, nil when the ID is
+// free, the original deposit when a retried post matches the stored job, and
+// a *payment.JobConflictError when it does not.
+func (pg *PaymentGateway) existingPost(ctx context.Context, applicationID int32, jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address) (*payment.TransactionResult, error) {
+	job, err := pg.client.GetJobDetails(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for an existing job: %w", err)
+	}
+	// The contract deletes cancelled jobs, so their IDs can be posted again
+	if job.Client == (common.Address{}) {
+		return nil, nil
+	}
+	if err := payment.MatchExistingJob(jobID, job, freelancer, usdAmount, client); err != nil {
+		return nil, err
+	}
+
+	deposit, err := pg.client.GetJobDeposit(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the existing job's deposit: %w", err)
+	}
+	if deposit == nil {
+		return nil, &payment.JobConflictError{JobID: jobID, Reason: "its deposit transaction is not in the contract's event history"}
+	}
+	log.Printf("Job %d is already posted on-chain in %s; returning the original deposit", jobID, deposit.TxHash)
+
+	// Record the hash unless an earlier attempt already did, so a retry doesn't move a confirmed deposit back
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil || details.EscrowTxHashDeposit == nil || !strings.EqualFold(*details.EscrowTxHashDeposit, deposit.TxHash) {
+		change := database.StatusChange{
+			ApplicationID: applicationID,
+			Status:        paymentstatus.DepositInitiated,
+			TxHash:        &deposit.TxHash,
+			TxType:        "deposit",
+			Actor:         database.ActorGateway,
+		}
+		if err := pg.db.ApplyStatusChange(ctx, change); err != nil {
+			log.Printf("Warning: Failed to update payment status in database: %v", err)
+		}
+		pg.wakePoller()
+	}
+
+	return &payment.TransactionResult{TxHash: deposit.TxHash, Success: true}, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/jobid"
+)
+
+// writeServerError reports a failed dependency call. Timeouts become
+// 504 Gateway Timeout so clients can tell a slow database or node from a bug.
+func writeServerError(w http.ResponseWriter, prefix string, err error) {
+	status := http.StatusInternalServerError
+	if database.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, fmt.Sprintf("%s: %v", prefix, err), status)
+}
+
+// parseJobID reads a job ID parameter and maps it to its application,
+// answering 400 when it is malformed or outside the application ID range
+func parseJobID(w http.ResponseWriter, raw string) (uint64, int32, bool) {
+	jobID, applicationID, err := jobid.Parse(raw)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid job ID: %v", err), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return jobID, applicationID, true
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/explorer"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/retainer"
+)
+
+// notify publishes an event in the background, logging delivery failures
+func (pg *PaymentGateway) notify(eventType events.Type, payload interface{}) {
+	if !pg.notifier.Enabled() {
+		return
+	}
+	pg.notifyJob(0, "", eventType, payload)
+}
+
+// notifyJob publishes an event about an application to the global webhook and
+// to the callback URL registered for it at /post-job, if any, carrying the
+// trace of the request behind it. An applicationID of 0 publishes to the
+// global webhook only.
+func (pg *PaymentGateway) notifyJob(applicationID int32, traceID string, eventType events.Type, payload interface{}) {
+	event, err := events.New(eventType, payload)
+	if err != nil {
+		log.Printf("Warning: Failed to build %s event: %v", eventType, err)
+		return
+	}
+	event.TraceID = traceID
+
+	pg.goDelivery(15*time.Second, func(ctx context.Context) {
+		if pg.notifier.Enabled() {
+			if err := pg.notifier.Notify(ctx, event); err != nil {
+				log.Printf("Warning: Failed to deliver %s webhook: %v", eventType, err)
+			}
+		}
+		if applicationID == 0 {
+			return
+		}
+
+		url, err := pg.db.GetJobWebhook(ctx, applicationID)
+		if err != nil {
+			log.Printf("Warning: Failed to get webhook of application %d: %v", applicationID, err)
+			return
+		}
+		if url == "" {
+			return
+		}
+		if err := pg.notifier.NotifyURL(ctx, url, event); err != nil {
+			log.Printf("Warning: Failed to deliver %s webhook for application %d: %v", eventType, applicationID, err)
+		}
+	})
+}
+
+func operationEvent(op *database.DeferredOperation, links explorer.Links) events.Operation {
+	payload := events.Operation{
+		OperationID:   op.ID,
+		ApplicationID: op.ApplicationID,
+		Operation:     op.Operation,
+		Status:        op.Status,
+		Deadline:      op.Deadline,
+		Attempts:      op.Attempts,
+	}
+	if op.TxHash != nil {
+		payload.TxHash = *op.TxHash
+		payload.TxURL = links.Tx(*op.TxHash)
+	}
+	if op.LastError != nil {
+		payload.Error = *op.LastError
+	}
+	return payload
+}
+
+func retainerPeriodEvent(period *database.RetainerPeriod, links explorer.Links) events.RetainerPeriod {
+	payload := events.RetainerPeriod{
+		RetainerID:   period.RetainerID,
+		PeriodNumber: period.PeriodNumber,
+		EscrowJobID:  retainer.EscrowJobID(period.ID),
+		USDAmount:    period.USDAmount,
+		Status:       period.Status,
+		PeriodStart:  period.PeriodStart,
+	}
+	if period.TxHashDeposit != nil {
+		payload.TxHashDeposit = *period.TxHashDeposit
+		payload.TxURLDeposit = links.Tx(*period.TxHashDeposit)
+	}
+	if period.LastError != nil {
+		payload.Error = *period.LastError
+	}
+	payload.RequiredWei = derefString(period.RequiredWei)
+	payload.DepositedWei = derefString(period.DepositedWei)
+	payload.TopUpWei = derefString(period.TopUpWei)
+	payload.OverfundedWei = derefString(period.OverfundedWei)
+	return payload
+}
+
+func retainerEvent(r *database.Retainer) events.Retainer {
+	return events.Retainer{
+		RetainerID:     r.ID,
+		ApplicationID:  r.ApplicationID,
+		USDAmount:      r.USDAmount,
+		Interval:       r.Interval,
+		Mode:           r.Mode,
+		Status:         r.Status,
+		StartAt:        r.StartAt,
+		EndAt:          r.EndAt,
+		PeriodsCreated: r.PeriodsCreated,
+	}
+}
+
+// derefString returns the value of an optional column, or "" when it is NULL
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// escrowStatusIndexer names the checkpoint of the indexed escrow events
+// already applied to payment statuses
+const escrowStatusIndexer = "escrow_status"
+
+// eventConfirmations is the confirmation each kind of escrow event records
+var eventConfirmations = map[string]confirmation{
+	payment.JobEventPosted:    depositConfirmation,
+	payment.JobEventReleased:  releaseConfirmation,
+	payment.JobEventCancelled: chainRefundConfirmation,
+}
+
+// chainRefundConfirmation is refundConfirmation that also applies to a
+// deposited escrow, since the contract lets the client cancel one themselves
+var chainRefundConfirmation = confirmation{
+	name:   "refund",
+	status: paymentstatus.Refunded,
+	from:   []paymentstatus.Status{paymentstatus.Deposited, paymentstatus.RefundInitiated},
+	done:   []paymentstatus.Status{paymentstatus.Refunded},
+}
+
+// applyEscrowEvents settles payment statuses from the indexed escrow events
+// with REQUIRED_CONFIRMATIONS, from its own checkpoint up to the indexer's.
+// The checkpoint moves after each batch of blocks, so an event is applied
+// again after a failure, which the confirmations' done statuses make a no-op.
+func (pg *PaymentGateway) applyEscrowEvents(ctx context.Context) error {
+	indexed, err := pg.db.GetEventCheckpoint(ctx, escrowIndexer)
+	if err != nil || indexed == nil {
+		return err
+	}
+	confirmations := max(pg.config.RequiredConfirmations, 1)
+	if indexed.BlockNumber+1 < confirmations {
+		return nil
+	}
+	to := indexed.BlockNumber + 1 - confirmations
+
+	checkpoint, err := pg.db.GetEventCheckpoint(ctx, escrowStatusIndexer)
+	if err != nil {
+		return err
+	}
+	from := pg.config.ContractDeployBlock
+	if checkpoint != nil {
+		from = max(checkpoint.BlockNumber+1, from)
+	}
+
+	for start := from; start <= to; start += indexerBatchBlocks {
+		end := min(start+indexerBatchBlocks-1, to)
+		indexedEvents, err := pg.db.ListIndexedEvents(ctx, start, end)
+		if err != nil {
+			return err
+		}
+		for _, event := range indexedEvents {
+			if err := pg.applyEscrowEvent(ctx, event); err != nil {
+				return fmt.Errorf("failed to apply the %s event of job %d in transaction %s: %w", event.Kind, event.JobID, event.TxHash, err)
+			}
+		}
+
+		hash, err := pg.client.BlockHash(ctx, end)
+		if err != nil {
+			return err
+		}
+		if err := pg.db.SaveEventCheckpoint(ctx, database.EventCheckpoint{Name: escrowStatusIndexer, BlockNumber: end, BlockHash: hash}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEscrowEvent moves the application an escrow event belongs to on to the
+// status the event confirms. Events of jobs that aren't an application's are
+// skipped, and so are transactions the status poller is waiting on, since it
+// also records their gas cost. An escrow released or cancelled outside the
+// gateway, straight from deposited, has its top-ups settled here, and a
+// cancellation its refund recorded with reason other. An application whose
+// status the event can't follow from is left for an operator to resync.
+func (pg *PaymentGateway) applyEscrowEvent(ctx context.Context, event database.IndexedEvent) error {
+	c, ok := eventConfirmations[event.Kind]
+	if !ok {
+		return nil
+	}
+	applicationID, ok, err := pg.db.GetEscrowJobApplication(ctx, event.JobID)
+	if err != nil || !ok {
+		return err
+	}
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+	current := details.PaymentStatus
+	if slices.Contains(c.done, current) {
+		return nil
+	}
+	if !slices.Contains(c.from, current) {
+		log.Printf("Warning: Job %d was %s in transaction %s but application %d is '%s'; resync it from the chain", event.JobID, event.Kind, event.TxHash, applicationID, current)
+		return nil
+	}
+	if pg.config.StatusPolling && current.Initiated() {
+		if recorded := recordedTxHash(details, c.name); recorded != nil && strings.EqualFold(*recorded, event.TxHash) {
+			return nil
+		}
+	}
+
+	blockNumber := int64(event.BlockNumber)
+	change := database.StatusChange{
+		ApplicationID: applicationID,
+		Status:        c.status,
+		TxHash:        &event.TxHash,
+		TxType:        c.name,
+		BlockNumber:   &blockNumber,
+		Actor:         database.ActorIndexer,
+		FromStatuses:  c.from,
+	}
+	err = pg.db.ApplyStatusChange(ctx, change)
+	// Another confirmation got there first
+	if errors.Is(err, database.ErrStatusConflict) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.name == "release" {
+		pg.accrueReserve(ctx, applicationID, event.JobID, event.TxHash)
+	}
+	if current == paymentstatus.Deposited {
+		pg.settleOutsideGateway(ctx, details, c.name, event.TxHash)
+	}
+
+	log.Printf("Confirmed application %d from its %s event: %s -> %s (tx %s)", applicationID, event.Kind, current, c.status, event.TxHash)
+
+	notification := events.Transaction{
+		ApplicationID: applicationID,
+		TxHash:        event.TxHash,
+		TxURL:         pg.explorer.Tx(event.TxHash),
+		BlockNumber:   event.BlockNumber,
+		Status:        c.status,
+	}
+	pg.addDisplayAmounts(ctx, &notification)
+	pg.notifyJob(applicationID, "", events.TransactionConfirmed, notification)
+	return nil
+}
+
+// settleOutsideGateway does what sendOperation does after a release or refund
+// the gateway sent itself, for one the indexer found sent by someone else
+func (pg *PaymentGateway) settleOutsideGateway(ctx context.Context, details *database.ApplicationPaymentDetails, txType, txHash string) {
+	reason := string(payment.RefundReasonOther)
+	if err := pg.settleTopUps(ctx, details.ApplicationID, txType, reason); err != nil {
+		log.Printf("Warning: Failed to settle top-ups of application %d: %v", details.ApplicationID, err)
+	}
+	if txType != "refund" {
+		return
+	}
+	var usdAmount int32
+	if details.AgreedUSDAmount != nil {
+		usdAmount = *details.AgreedUSDAmount
+	}
+	if err := pg.db.RecordRefund(ctx, details.ApplicationID, reason, usdAmount, txHash); err != nil {
+		log.Printf("Warning: Failed to record refund reason in database: %v", err)
+	}
+}
+
+// recordedTxHash is the application's transaction of a type
+func recordedTxHash(details *database.ApplicationPaymentDetails, txType string) *string {
+	switch txType {
+	case "deposit":
+		return details.EscrowTxHashDeposit
+	case "release":
+		return details.EscrowTxHashRelease
+	case "refund":
+		return details.EscrowTxHashRefund
+	}
+	return nil
+}
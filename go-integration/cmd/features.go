@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+)
+
+// TenantHeader names the tenant a request is made for, matched against
+// tenant-specific feature flag rules
+const TenantHeader = "X-Tenant-ID"
+
+// featureRulesTTL bounds how long a rule change takes to reach other gateway
+// instances; changes made through this instance apply immediately
+const featureRulesTTL = 10 * time.Second
+
+// featureSet returns the flags evaluated from FEATURE_FLAGS and the stored
+// rules. If the rules cannot be read, the defaults apply alone so a database
+// problem degrades to the deployment's baseline rather than failing requests.
+func (pg *PaymentGateway) featureSet(ctx context.Context) *features.Set {
+	rules, err := pg.featureRules.Get(struct{}{}, func() ([]features.Rule, error) {
+		return pg.db.ListFeatureFlagRules(ctx)
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to load feature flag rules, using defaults: %v", err)
+	}
+	return features.NewSet(pg.featureDefaults, rules)
+}
+
+// requireFeature reports whether flag is enabled for the request's tenant on
+// this gateway's network, writing a 403 if it is not
+func (pg *PaymentGateway) requireFeature(w http.ResponseWriter, r *http.Request, flag features.Flag) bool {
+	return pg.requireTenantFeature(w, r, flag, r.Header.Get(TenantHeader))
+}
+
+// requireTenantFeature is requireFeature for a tenant known other than from
+// the request, such as the one a job's escrow was posted for
+func (pg *PaymentGateway) requireTenantFeature(w http.ResponseWriter, r *http.Request, flag features.Flag, tenant string) bool {
+	if pg.featureSet(r.Context()).Enabled(flag, tenant, pg.config.NetworkID) {
+		return true
+	}
+	http.Error(w, fmt.Sprintf("Feature %s is not enabled", flag), http.StatusForbidden)
+	return false
+}
+
+// FeatureFlagsResponse is every flag's state for one tenant and network, with
+// the rules that produced it
+type FeatureFlagsResponse struct {
+	Tenant    string                 `json:"tenant"`
+	NetworkID int64                  `json:"network_id"`
+	Flags     map[features.Flag]bool `json:"flags"`
+	Defaults  map[features.Flag]bool `json:"defaults"`
+	Rules     []features.Rule        `json:"rules"`
+}
+
+// SetFeatureFlagRequest enables or disables a flag; omitted tenant and
+// network_id apply the rule to every tenant or network
+type SetFeatureFlagRequest struct {
+	Tenant    string `json:"tenant"`
+	NetworkID int64  `json:"network_id"`
+	Enabled   *bool  `json:"enabled"`
+	Reason    string `json:"reason"`
+}
+
+// GET /feature-flags?tenant=X&network_id=N - Evaluated flags and stored rules
+func (pg *PaymentGateway) getFeatureFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	networkID := pg.config.NetworkID
+	if s := query.Get("network_id"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid network_id", http.StatusBadRequest)
+			return
+		}
+		networkID = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rules, err := pg.db.ListFeatureFlagRules(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get feature flags", err)
+		return
+	}
+	if rules == nil {
+		rules = []features.Rule{}
+	}
+
+	tenant := query.Get("tenant")
+	response := FeatureFlagsResponse{
+		Tenant:    tenant,
+		NetworkID: networkID,
+		Flags:     features.NewSet(pg.featureDefaults, rules).Evaluate(tenant, networkID),
+		Defaults:  pg.featureDefaults,
+		Rules:     rules,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PUT /feature-flags/{flag} - Enable or disable a flag for a tenant and/or network
+func (pg *PaymentGateway) setFeatureFlagHandler(w http.ResponseWriter, r *http.Request) {
+	flag := features.Flag(r.PathValue("flag"))
+	if !features.Known(flag) {
+		http.Error(w, "Unknown feature flag", http.StatusNotFound)
+		return
+	}
+
+	var req SetFeatureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Enabled == nil {
+		http.Error(w, "enabled is required", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rule := features.Rule{Flag: flag, Tenant: req.Tenant, NetworkID: req.NetworkID, Enabled: *req.Enabled}
+	if err := pg.db.SetFeatureFlagRule(ctx, rule, actor, req.Reason); err != nil {
+		writeServerError(w, "Failed to set feature flag", err)
+		return
+	}
+	pg.featureRules.Invalidate(struct{}{})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// DELETE /feature-flags/{flag}?tenant=X&network_id=N - Remove a rule
+func (pg *PaymentGateway) deleteFeatureFlagHandler(w http.ResponseWriter, r *http.Request) {
+	flag := features.Flag(r.PathValue("flag"))
+	if !features.Known(flag) {
+		http.Error(w, "Unknown feature flag", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	var networkID int64
+	if s := query.Get("network_id"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid network_id", http.StatusBadRequest)
+			return
+		}
+		networkID = n
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	deleted, err := pg.db.DeleteFeatureFlagRule(ctx, flag, query.Get("tenant"), networkID, actor, query.Get("reason"))
+	if err != nil {
+		writeServerError(w, "Failed to delete feature flag", err)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Feature flag rule not found", http.StatusNotFound)
+		return
+	}
+	pg.featureRules.Invalidate(struct{}{})
+
+	w.WriteHeader(http.StatusNoContent)
+}
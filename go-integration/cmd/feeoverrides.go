@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+// maxFeeOverrideReason bounds the reason recorded with a fee override
+const maxFeeOverrideReason = 500
+
+// parseFeeOverride checks the fee override of a /post-job request. An
+// override can only lower the fee, since the contract always takes
+// FEE_PERCENTAGE, and needs a reason and the X-Admin-Key of one of
+// FEE_OVERRIDE_APPROVERS, whose name is recorded as its approver. It returns
+// nil without an override, and false after writing an error.
+func (pg *PaymentGateway) parseFeeOverride(w http.ResponseWriter, r *http.Request, req PostJobRequest, applicationID int32) (*database.FeeOverride, bool) {
+	if req.PlatformFeeBPS == nil {
+		if req.FeeOverrideReason != "" {
+			http.Error(w, "fee_override_reason needs platform_fee_bps", http.StatusBadRequest)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	maxBPS := int64(pg.config.FeePercentage) * 100
+	if *req.PlatformFeeBPS < 0 || *req.PlatformFeeBPS > maxBPS {
+		http.Error(w, fmt.Sprintf("platform_fee_bps must be between 0 and %d, the contract's fee", maxBPS), http.StatusBadRequest)
+		return nil, false
+	}
+	if req.FeeOverrideReason == "" || len(req.FeeOverrideReason) > maxFeeOverrideReason {
+		http.Error(w, fmt.Sprintf("fee_override_reason is required, at most %d characters", maxFeeOverrideReason), http.StatusBadRequest)
+		return nil, false
+	}
+
+	approver := pg.adminKeyOf(r)
+	if approver == "" {
+		http.Error(w, "A valid "+AdminKeyHeader+" is required to override the platform fee", http.StatusUnauthorized)
+		return nil, false
+	}
+	if pg.feeApprovers != nil && !pg.feeApprovers[approver] {
+		http.Error(w, fmt.Sprintf("Admin key %s may not override platform fees", approver), http.StatusForbidden)
+		return nil, false
+	}
+
+	return &database.FeeOverride{
+		ApplicationID: applicationID,
+		FeeBPS:        *req.PlatformFeeBPS,
+		Reason:        req.FeeOverrideReason,
+		Approver:      approver,
+	}, true
+}
+
+// setFeeOverride stores a job's fee override and audits who approved it
+func (pg *PaymentGateway) setFeeOverride(ctx context.Context, override *database.FeeOverride) error {
+	if err := pg.db.SetFeeOverride(ctx, *override); err != nil {
+		return err
+	}
+
+	after, _ := json.Marshal(map[string]interface{}{
+		"platform_fee_bps": override.FeeBPS,
+		"contract_fee_bps": pg.config.FeePercentage * 100,
+	})
+	entry := database.AuditEntry{
+		Action:        "fee_override.set",
+		ApplicationID: &override.ApplicationID,
+		Actor:         override.Approver,
+		Reason:        override.Reason,
+		After:         after,
+	}
+	if err := pg.db.RecordAudit(ctx, entry); err != nil {
+		return err
+	}
+
+	log.Printf("Fee of application %d overridden to %d bps by admin key %s: %s", override.ApplicationID, override.FeeBPS, override.Approver, override.Reason)
+	return nil
+}
+
+// applyFeeOverride returns the platform's fee of a released job: the
+// contract's, or its override's, in which case the rest of the contract's fee
+// is posted to the ledger as owed back to the freelancer
+func (pg *PaymentGateway) applyFeeOverride(ctx context.Context, override *database.FeeOverride, job *payment.JobDetails, releaseTxHash string) *big.Int {
+	charged := amounts.Fee(job.ETHAmount, pg.config.FeePercentage)
+	if override == nil {
+		return charged
+	}
+
+	fee := amounts.FeeBasisPoints(job.ETHAmount, override.FeeBPS)
+	if fee.Cmp(charged) > 0 {
+		// FEE_PERCENTAGE was lowered below the override since it was set
+		fee = charged
+	}
+	t := ledger.FeeOverride(override.ApplicationID, releaseTxHash, job.Freelancer.Hex(), charged, fee, override.Reason, override.Approver)
+	if t == nil {
+		return fee
+	}
+	if _, err := pg.db.PostLedgerTransaction(ctx, t); err != nil {
+		log.Printf("Warning: Failed to post fee override for release %s: %v", releaseTxHash, err)
+	}
+	return fee
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/calldata"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/explorer"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/format"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// fundingLinkLateWindow is how long after expiry a link is still watched, so
+// a client who pays late is recorded rather than left unmatched
+const fundingLinkLateWindow = 7 * 24 * time.Hour
+
+// FundingLinkResponse is everything a client needs to fund a job's escrow
+// from their own wallet, ready to embed in an email
+type FundingLinkResponse struct {
+	LinkID          int64     `json:"link_id"`
+	JobID           uint64    `json:"job_id"`
+	Status          string    `json:"status"`
+	ChainID         int64     `json:"chain_id"`
+	ContractAddress string    `json:"contract_address"`
+	ClientAddress   string    `json:"client_address"` // the wallet the escrow is refundable to
+	PaymentURI      string    `json:"payment_uri"`    // EIP-681 request to call postJob
+	QRPayload       string    `json:"qr_payload"`     // the text to encode in a QR code
+	Calldata        string    `json:"calldata"`       // for wallets that can't read payment_uri
+	USDAmount       int32     `json:"usd_amount"`
+	ETHUSDPrice     string    `json:"eth_usd_price"` // 8 decimals, the rate value_wei was quoted at
+	ValueWei        string    `json:"value_wei"`     // send exactly this with the call
+	ValueETHDisplay string    `json:"value_eth_display"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	Actor           string    `json:"actor"`
+	CreatedAt       time.Time `json:"created_at"`
+
+	TxHash   string     `json:"tx_hash,omitempty"` // the deposit, once found
+	TxURL    string     `json:"tx_url,omitempty"`
+	FundedAt *time.Time `json:"funded_at,omitempty"`
+}
+
+func (pg *PaymentGateway) newFundingLinkResponse(l format.Locale, link *database.FundingLink, details *database.ApplicationPaymentDetails) (FundingLinkResponse, error) {
+	freelancer := common.HexToAddress(derefString(details.ApplicantWalletAddress))
+	client := common.HexToAddress(derefString(details.PosterWalletAddress))
+	data, err := calldata.PostJob(uint64(link.ApplicationID), freelancer, big.NewInt(int64(link.USDAmount)), client)
+	if err != nil {
+		return FundingLinkResponse{}, err
+	}
+
+	response := FundingLinkResponse{
+		LinkID:          link.ID,
+		JobID:           uint64(link.ApplicationID),
+		Status:          link.Status,
+		ChainID:         pg.config.NetworkID,
+		ContractAddress: pg.client.ContractAddress().Hex(),
+		ClientAddress:   client.Hex(),
+		PaymentURI:      link.URI,
+		QRPayload:       link.URI,
+		Calldata:        hexutil.Encode(data),
+		USDAmount:       link.USDAmount,
+		ETHUSDPrice:     link.ETHUSDPrice,
+		ValueWei:        link.ValueWei,
+		ValueETHDisplay: displayWei(l, link.ValueWei),
+		ExpiresAt:       link.ExpiresAt,
+		Actor:           link.Actor,
+		CreatedAt:       link.CreatedAt,
+		FundedAt:        link.FundedAt,
+	}
+	if link.TxHash != nil {
+		response.TxHash = *link.TxHash
+		response.TxURL = pg.explorer.Tx(*link.TxHash)
+	}
+	return response, nil
+}
+
+// fundingLinkValue is the wei a link asks for: what the contract requires at
+// price, or at a rate FUNDING_LINK_BUFFER_PERCENT lower so the deposit still
+// covers it if ETH falls before it is mined
+func (pg *PaymentGateway) fundingLinkValue(usdAmount, price *big.Int) *big.Int {
+	percent := pg.config.FundingLinkBufferPercent
+	if percent <= 0 || percent >= 100 {
+		return amounts.EscrowWei(usdAmount, price)
+	}
+	remaining := new(big.Rat).Sub(big.NewRat(100, 1), new(big.Rat).SetFloat64(percent))
+	lowest := amounts.Round(new(big.Rat).Mul(new(big.Rat).SetInt(price), remaining.Quo(remaining, big.NewRat(100, 1))), amounts.Down)
+	if lowest.Sign() <= 0 {
+		return amounts.EscrowWei(usdAmount, price)
+	}
+	return amounts.EscrowWei(usdAmount, lowest)
+}
+
+// POST /jobs/{id}/funding-link - Generate a funding package for the client to
+// deposit the escrow from their own wallet before it expires
+func (pg *PaymentGateway) createFundingLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.FundingLinks) {
+		return
+	}
+
+	jobID, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	if details.PaymentStatus != paymentstatus.PendingDeposit {
+		http.Error(w, fmt.Sprintf("Job is %s, only a pending_deposit job can be funded", details.PaymentStatus), http.StatusConflict)
+		return
+	}
+	if details.AgreedUSDAmount == nil || details.ApplicantWalletAddress == nil || details.PosterWalletAddress == nil {
+		http.Error(w, "Job has no agreed amount or wallet addresses", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Anyone can post a job ID, so make sure this one is still free
+	if job, err := pg.client.GetJobDetails(ctx, jobID); err != nil {
+		writeServerError(w, "Failed to get job from contract", err)
+		return
+	} else if job.Client != (common.Address{}) {
+		http.Error(w, fmt.Sprintf("Job %d is already posted on-chain", jobID), http.StatusConflict)
+		return
+	}
+
+	price, err := pg.oracle.GetETHUSDPrice(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get ETH price", err)
+		return
+	}
+	usdAmount := big.NewInt(int64(*details.AgreedUSDAmount))
+	value := pg.fundingLinkValue(usdAmount, price)
+	freelancer := common.HexToAddress(*details.ApplicantWalletAddress)
+	client := common.HexToAddress(*details.PosterWalletAddress)
+
+	link, err := pg.db.CreateFundingLink(ctx, database.FundingLink{
+		ApplicationID: applicationID,
+		USDAmount:     *details.AgreedUSDAmount,
+		ETHUSDPrice:   price.String(),
+		ValueWei:      value.String(),
+		URI:           payment.PostJobURI(pg.client.ContractAddress(), pg.config.NetworkID, jobID, freelancer, usdAmount, client, value),
+		Actor:         actor,
+		ExpiresAt:     time.Now().Add(pg.config.FundingLinkTTL).UTC(),
+	})
+	if err != nil {
+		writeServerError(w, "Failed to create funding link", err)
+		return
+	}
+	response, err := pg.newFundingLinkResponse(localeFor(r), link, details)
+	if err != nil {
+		writeServerError(w, "Failed to encode postJob call", err)
+		return
+	}
+	log.Printf("Funding link %d for job %d asks %s wei until %s", link.ID, jobID, link.ValueWei, link.ExpiresAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GET /jobs/{id}/funding-link - A job's latest funding link and whether it was funded
+func (pg *PaymentGateway) getFundingLinkHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	link, err := pg.db.GetFundingLink(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get funding link", err)
+		return
+	}
+	if link == nil {
+		http.Error(w, fmt.Sprintf("Job %d has no funding link", jobID), http.StatusNotFound)
+		return
+	}
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	response, err := pg.newFundingLinkResponse(localeFor(r), link, details)
+	if err != nil {
+		writeServerError(w, "Failed to encode postJob call", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// runFundingLinks checks every FUNDING_LINK_POLL_INTERVAL whether clients
+// have deposited through their funding links
+func (pg *PaymentGateway) runFundingLinks(ctx context.Context) {
+	if pg.config.FundingLinkPollInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.FundingLinkPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pg.checkFundingLinks(ctx, time.Now())
+			pg.markWorkerRun("funding_links", pg.config.FundingLinkPollInterval)
+		}
+	}
+}
+
+func (pg *PaymentGateway) checkFundingLinks(ctx context.Context, now time.Time) {
+	links, err := pg.db.ListWatchedFundingLinks(ctx, now.Add(-fundingLinkLateWindow))
+	if err != nil {
+		log.Printf("Failed to list funding links: %v", err)
+		return
+	}
+	for _, link := range links {
+		pg.checkFundingLink(ctx, link, now)
+	}
+}
+
+// checkFundingLink records the deposit a link asked for once it is on-chain,
+// and whether it was mined before the link expired. A link that expires
+// without one is reported and watched for a late deposit.
+func (pg *PaymentGateway) checkFundingLink(ctx context.Context, link *database.FundingLink, now time.Time) {
+	jobID := uint64(link.ApplicationID)
+	deposit, err := pg.client.GetJobDeposit(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to get deposit for funding link %d: %v", link.ID, err)
+		return
+	}
+	if deposit == nil {
+		if link.Status == database.FundingLinkOpen && !now.Before(link.ExpiresAt) {
+			if ok, err := pg.db.ResolveFundingLink(ctx, link.ID, database.FundingLinkExpired, nil, nil); err != nil {
+				log.Printf("Failed to expire funding link %d: %v", link.ID, err)
+			} else if ok {
+				link.Status = database.FundingLinkExpired
+				pg.notifyJob(link.ApplicationID, "", events.FundingLinkExpired, fundingLinkEvent(link, nil, pg.explorer))
+			}
+		}
+		return
+	}
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, link.ApplicationID)
+	if err != nil {
+		log.Printf("Failed to get application %d for funding link %d: %v", link.ApplicationID, link.ID, err)
+		return
+	}
+	job, err := pg.client.GetJobDetails(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to get job %d for funding link %d: %v", jobID, link.ID, err)
+		return
+	}
+	freelancer := common.HexToAddress(derefString(details.ApplicantWalletAddress))
+	client := common.HexToAddress(derefString(details.PosterWalletAddress))
+	if err := payment.MatchExistingJob(jobID, job, freelancer, big.NewInt(int64(link.USDAmount)), client); err != nil {
+		log.Printf("ALERT: job %d was posted on-chain with other terms than funding link %d: %v", jobID, link.ID, err)
+		return
+	}
+
+	fundedAt, err := pg.client.BlockTime(ctx, deposit.BlockNumber)
+	if err != nil {
+		log.Printf("Failed to date deposit of funding link %d: %v", link.ID, err)
+		return
+	}
+	// The deposit may already be recorded, by discovery or the platform
+	blockNumber := int64(deposit.BlockNumber)
+	err = pg.db.ApplyStatusChange(ctx, database.StatusChange{
+		ApplicationID: link.ApplicationID,
+		Status:        paymentstatus.Deposited,
+		TxHash:        &deposit.TxHash,
+		TxType:        "deposit",
+		BlockNumber:   &blockNumber,
+		Actor:         database.ActorClient,
+		FromStatuses:  []paymentstatus.Status{paymentstatus.PendingDeposit},
+	})
+	if err != nil && !errors.Is(err, database.ErrStatusConflict) {
+		log.Printf("Failed to record deposit %s of funding link %d: %v", deposit.TxHash, link.ID, err)
+		return
+	}
+
+	status := database.FundingLinkFunded
+	if fundedAt.After(link.ExpiresAt) {
+		status = database.FundingLinkFundedLate
+	}
+	ok, err := pg.db.ResolveFundingLink(ctx, link.ID, status, &deposit.TxHash, &fundedAt)
+	if err != nil {
+		log.Printf("Failed to resolve funding link %d: %v", link.ID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	link.Status, link.TxHash, link.FundedAt = status, &deposit.TxHash, &fundedAt
+
+	log.Printf("Funding link %d for job %d %s by %s", link.ID, jobID, status, deposit.TxHash)
+	pg.notifyJob(link.ApplicationID, "", events.FundingLinkFunded, fundingLinkEvent(link, deposit, pg.explorer))
+}
+
+func fundingLinkEvent(link *database.FundingLink, deposit *payment.Deposit, links explorer.Links) events.FundingLink {
+	event := events.FundingLink{
+		LinkID:        link.ID,
+		ApplicationID: link.ApplicationID,
+		USDAmount:     link.USDAmount,
+		ValueWei:      link.ValueWei,
+		ExpiresAt:     link.ExpiresAt,
+		Status:        link.Status,
+		FundedAt:      link.FundedAt,
+	}
+	if deposit != nil {
+		event.DepositedWei = deposit.Value.String()
+		event.TxHash = deposit.TxHash
+		event.TxURL = links.Tx(deposit.TxHash)
+	}
+	return event
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/gaswindow"
+)
+
+// Release modes of /complete-job and /release-batch
+const (
+	releaseModeImmediate  = "immediate"
+	releaseModeEconomical = "economical" // wait up to ECONOMICAL_MAX_DELAY for a historically cheap gas hour
+)
+
+// parseReleaseMode checks a requested release mode, immediate when empty
+func (pg *PaymentGateway) parseReleaseMode(mode string) (string, error) {
+	switch mode {
+	case "", releaseModeImmediate:
+		return releaseModeImmediate, nil
+	case releaseModeEconomical:
+		if pg.config.BaseFeeSampleInterval <= 0 {
+			return "", fmt.Errorf("economical releases are disabled; set BASE_FEE_SAMPLE_INTERVAL")
+		}
+		return releaseModeEconomical, nil
+	}
+	return "", fmt.Errorf("invalid mode %q: expected %s or %s", mode, releaseModeImmediate, releaseModeEconomical)
+}
+
+// runBaseFeeSampler records the latest block's base fee every
+// BASE_FEE_SAMPLE_INTERVAL, keeping BASE_FEE_HISTORY of samples to learn the
+// cheap hours of the day from
+func (pg *PaymentGateway) runBaseFeeSampler(ctx context.Context) {
+	if pg.config.BaseFeeSampleInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.BaseFeeSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		pg.sampleBaseFee(ctx)
+		pg.markWorkerRun("base_fee_sampler", pg.config.BaseFeeSampleInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (pg *PaymentGateway) sampleBaseFee(ctx context.Context) {
+	rpcCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	block, baseFee, err := pg.client.LatestBaseFee(rpcCtx)
+	cancel()
+	if err != nil {
+		log.Printf("Warning: Failed to get base fee: %v", err)
+		return
+	}
+	if err := pg.db.RecordBaseFee(ctx, block, baseFee, time.Now().Add(-pg.config.BaseFeeHistory)); err != nil {
+		log.Printf("Warning: Failed to record base fee of block %d: %v", block, err)
+	}
+}
+
+// economicalMaxDelay is ECONOMICAL_MAX_DELAY, shortened when the release
+// authorization given with the release would lapse before it
+func (pg *PaymentGateway) economicalMaxDelay() time.Duration {
+	delay := pg.config.EconomicalMaxDelay
+	if ttl := pg.config.ReleaseAuthorizationTTL; ttl > 0 {
+		delay = min(delay, ttl-pg.config.DeferredPollInterval)
+	}
+	return delay
+}
+
+// cheapestGasWindow returns the hour within ECONOMICAL_MAX_DELAY whose base
+// fee over BASE_FEE_HISTORY was lowest, and false when now is as cheap
+func (pg *PaymentGateway) cheapestGasWindow(ctx context.Context, now time.Time) (gaswindow.Window, bool, error) {
+	profile, err := pg.db.GetBaseFeeProfile(ctx, now.Add(-pg.config.BaseFeeHistory))
+	if err != nil {
+		return gaswindow.Window{}, false, err
+	}
+	window, ok := profile.Cheapest(now, pg.economicalMaxDelay())
+	return window, ok, nil
+}
+
+// scheduleEconomicalRelease queues a release for the cheapest gas hour ahead.
+// scheduled is false when the release should be sent now, because now is
+// already the cheapest hour or the base fee history can't be read.
+func (pg *PaymentGateway) scheduleEconomicalRelease(ctx context.Context, applicationID int32, params database.OperationParams) (op *database.DeferredOperation, scheduled bool, err error) {
+	window, ok, err := pg.cheapestGasWindow(ctx, time.Now())
+	if err != nil {
+		log.Printf("%sWarning: Failed to get base fee history, releasing application %d now: %v", tracePrefix(params.TraceID), applicationID, err)
+		return nil, false, nil
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	reason := fmt.Sprintf("economical release scheduled for %s, when the base fee averages %.2f gwei against %.2f gwei now",
+		window.Start.Format(time.RFC3339), window.AverageGwei, window.CurrentGwei)
+	op, err = pg.db.ScheduleDeferredOperation(ctx, applicationID, opCompleteJob, params, window.Start, window.Start.Add(pg.config.DeferDeadline), reason)
+	if err != nil {
+		return nil, true, err
+	}
+
+	log.Printf("%sScheduled release of application %d for %s to save %.0f%% of the base fee", tracePrefix(params.TraceID), applicationID, window.Start.Format(time.RFC3339), 100*window.Saving())
+	pg.notifyJob(op.ApplicationID, op.Params.TraceID, events.OperationDeferred, operationEvent(op, pg.explorer))
+	return op, true, nil
+}
+
+// scheduleIfEconomical schedules the release in economical mode. It returns
+// true if a response has been written.
+func (pg *PaymentGateway) scheduleIfEconomical(ctx context.Context, w http.ResponseWriter, applicationID int32, params database.OperationParams, mode string) bool {
+	if mode != releaseModeEconomical {
+		return false
+	}
+	op, scheduled, err := pg.scheduleEconomicalRelease(ctx, applicationID, params)
+	if !scheduled {
+		return false
+	}
+	if err != nil {
+		writeServerError(w, "Failed to schedule economical release", err)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newDeferredOperationResponse(op, pg.explorer))
+	return true
+}
+
+// GasWindowResponse is the hour an economical release sent now would wait for
+type GasWindowResponse struct {
+	Start         time.Time `json:"start"`
+	AverageGwei   float64   `json:"average_gwei"`
+	CurrentGwei   float64   `json:"current_gwei"` // the current hour's average
+	SavingPercent float64   `json:"saving_percent"`
+}
+
+// GasWindowsResponse is the average base fee by UTC hour of the day
+type GasWindowsResponse struct {
+	Since       time.Time          `json:"since"`
+	Hours       gaswindow.Profile  `json:"hours"`
+	BaseFeeGwei *float64           `json:"base_fee_gwei,omitempty"` // latest block's, nil when the node is unreachable
+	MaxDelay    string             `json:"max_delay"`
+	NextWindow  *GasWindowResponse `json:"next_window,omitempty"` // nil when an economical release would be sent now
+}
+
+// GET /gas-windows - Base fees by hour and where an economical release would go
+func (pg *PaymentGateway) gasWindowsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	response := GasWindowsResponse{Since: now.Add(-pg.config.BaseFeeHistory), MaxDelay: pg.economicalMaxDelay().String()}
+	profile, err := pg.db.GetBaseFeeProfile(ctx, response.Since)
+	if err != nil {
+		writeServerError(w, "Failed to get base fee history", err)
+		return
+	}
+	response.Hours = profile
+	if response.Hours == nil {
+		response.Hours = gaswindow.Profile{}
+	}
+	if window, ok := profile.Cheapest(now, pg.economicalMaxDelay()); ok {
+		response.NextWindow = &GasWindowResponse{
+			Start:         window.Start,
+			AverageGwei:   window.AverageGwei,
+			CurrentGwei:   window.CurrentGwei,
+			SavingPercent: 100 * window.Saving(),
+		}
+	}
+	if _, baseFee, err := pg.client.LatestBaseFee(ctx); err == nil {
+		gwei, _ := new(big.Rat).SetFrac(baseFee, big.NewInt(1e9)).Float64()
+		response.BaseFeeGwei = &gwei
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
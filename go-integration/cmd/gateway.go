@@ -0,0 +1,734 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/archive"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/automation"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/cache"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/chaos"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/clientlimit"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/contractupdate"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/explorer"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/fx"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/gaswindow"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/graphql"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/killswitch"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/kyc"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/replay"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/statustoken"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/velocity"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/webhook"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/workpool"
+)
+
+// ChainClient sends escrow transactions and reads contract state.
+// *payment.Client is the production implementation.
+type ChainClient interface {
+	PostJob(ctx context.Context, jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address) (*payment.TransactionResult, error)
+	MarkJobCompleted(ctx context.Context, jobID uint64) (*payment.TransactionResult, error)
+	CancelJob(ctx context.Context, jobID uint64) (*payment.TransactionResult, error)
+	CancelTransaction(ctx context.Context, hash common.Hash) (*payment.TransactionResult, error)
+
+	GetJobDetails(ctx context.Context, jobID uint64) (*payment.JobDetails, error)
+	GetJobHistory(ctx context.Context, jobID uint64) ([]payment.JobEvent, error)
+	ScanEscrowEvents(ctx context.Context, fromBlock, toBlock uint64) ([]payment.EscrowEvent, error)
+	GetJobDeposit(ctx context.Context, jobID uint64) (*payment.Deposit, error)
+	BlockTime(ctx context.Context, blockNumber uint64) (time.Time, error)
+	BlockHash(ctx context.Context, blockNumber uint64) (string, error)
+	GetReceiptStatuses(ctx context.Context, hashes []common.Hash) (map[common.Hash]*payment.ReceiptStatus, error)
+	GetProxyInfo(ctx context.Context) (*payment.ProxyInfo, error)
+	GetContractConfig(ctx context.Context) (*payment.ContractConfig, error)
+	ContractAddress() common.Address
+	SwapContract(ctx context.Context, address common.Address, abiJSON []byte) (common.Address, error)
+
+	EstimateGas(ctx context.Context, value *big.Int, method string, args ...interface{}) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	GasPriceCeiling() *big.Int
+	GetBalance(ctx context.Context, address common.Address) (*big.Int, error)
+	NonceAt(ctx context.Context, address common.Address) (uint64, error)
+	PendingNonceAt(ctx context.Context, address common.Address) (uint64, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	LatestBaseFee(ctx context.Context) (uint64, *big.Int, error)
+	LatestBlockGas(ctx context.Context) (*payment.BlockGas, error)
+	PendingTransactionCount(ctx context.Context) (uint, error)
+	Canary(ctx context.Context, send bool) (*payment.CanaryResult, error)
+	RPCProviders() []payment.RPCProviderStatus
+	ProbeRPCProviders(ctx context.Context)
+	Address() common.Address
+	AdminAddress() common.Address
+
+	Close()
+}
+
+// PriceOracle reports the ETH/USD rate the contract converts with
+type PriceOracle interface {
+	GetETHUSDPrice(ctx context.Context) (*big.Int, error)
+	LatestPriceRound(ctx context.Context) (*oracle.RoundData, error)
+	FallbackPriceRound(ctx context.Context) (*oracle.RoundData, error)
+	PriceRoundAtBlock(ctx context.Context, blockNumber uint64) (*oracle.RoundData, error)
+	PriceRoundAtTime(ctx context.Context, at time.Time) (*oracle.RoundData, error)
+	RecentPriceRounds(ctx context.Context, n int) ([]*oracle.RoundData, error)
+}
+
+// Notifier delivers outbound events such as webhooks
+type Notifier interface {
+	Enabled() bool
+	Notify(ctx context.Context, event *events.Envelope) error
+	NotifyURL(ctx context.Context, url string, event *events.Envelope) error
+}
+
+// Store persists the gateway's view of payments. *database.DB is the
+// production implementation.
+type Store interface {
+	// Applications and payment status
+	GetApplicationPaymentDetails(ctx context.Context, applicationID int32) (*database.ApplicationPaymentDetails, error)
+	ValidateApplicationForBlockchain(ctx context.Context, applicationID int32) error
+	ListReleasableApplications(ctx context.Context, applicantUserID int32, approvedStatus string) ([]*database.ApplicationPaymentDetails, error)
+	ListApprovedDeposits(ctx context.Context, approvedStatus string) ([]int32, error)
+	ListApplicationTransitions(ctx context.Context, limit int) ([]database.ApplicationTransition, error)
+	RecordTransition(ctx context.Context, applicationID int32, from, to string) (bool, error)
+	ListApplications(ctx context.Context, filter database.ApplicationFilter) ([]*database.ApplicationPaymentDetails, string, error)
+	UpdatePaymentStatus(ctx context.Context, applicationID int32, status paymentstatus.Status, txHash *string, txType string) error
+	ApplyStatusChange(ctx context.Context, change database.StatusChange) error
+	StatusChanged(applicationID int32) <-chan struct{}
+	GetPaymentEvents(ctx context.Context, applicationID int32) ([]database.PaymentEvent, error)
+	ListPaymentEvents(ctx context.Context, filter database.PaymentEventFilter) ([]database.PaymentEvent, string, error)
+	ListPaymentChanges(ctx context.Context, after database.ChangeCursor, filters []listquery.Condition, limit int) ([]database.PaymentChange, error)
+	ListInitiatedTransactions(ctx context.Context) ([]database.InitiatedTransaction, error)
+	OverwritePaymentRecord(ctx context.Context, applicationID int32, record database.PaymentRecord, actor, reason string) (*database.PaymentRecord, error)
+	RecordEscrowJob(ctx context.Context, applicationID int32, jobID uint64) error
+	CloneApplication(ctx context.Context, sourceID int32, usdAmount int32, actor string) (int32, error)
+
+	// Tags
+	GetJobTags(ctx context.Context, applicationID int32) ([]string, error)
+	SetJobTags(ctx context.Context, applicationID int32, tags []string, actor string) error
+
+	// Deferred operations
+	CreateDeferredOperation(ctx context.Context, applicationID int32, operation string, params database.OperationParams, deadline time.Time, reason string) (*database.DeferredOperation, error)
+	ScheduleDeferredOperation(ctx context.Context, applicationID int32, operation string, params database.OperationParams, at, deadline time.Time, reason string) (*database.DeferredOperation, error)
+	GetPendingDeferredOperation(ctx context.Context, applicationID int32) (*database.DeferredOperation, error)
+	ConfirmDeferredOperationPrice(ctx context.Context, id int64, quotedPrice string) (*database.DeferredOperation, error)
+	ListDueDeferredOperations(ctx context.Context) ([]*database.DeferredOperation, error)
+	UpdateDeferredOperation(ctx context.Context, id int64, status string, txHash *string, lastError *string) error
+	RescheduleDeferredOperation(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error
+
+	// Write-ahead chain intents
+	BeginChainIntent(ctx context.Context, applicationID int32, operation string, params database.OperationParams) (*database.ChainIntent, error)
+	ResolveChainIntent(ctx context.Context, id int64, outcome string, txHash *string, errMsg string) error
+	ListUnresolvedChainIntents(ctx context.Context, createdBefore time.Time) ([]*database.ChainIntent, error)
+
+	// Idempotency keys
+	ClaimIdempotencyKey(ctx context.Context, tenant, key, requestHash string, staleBefore, expireBefore time.Time) (*database.IdempotentResponse, error)
+	CompleteIdempotencyKey(ctx context.Context, tenant, key string, response database.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, tenant, key string) error
+
+	// Batched payouts
+	GetPayoutPreference(ctx context.Context, freelancerUserID int32) (*database.PayoutPreference, error)
+	SetPayoutPreference(ctx context.Context, pref database.PayoutPreference) error
+	DeletePayoutPreference(ctx context.Context, freelancerUserID int32, actor string) (bool, error)
+	HoldPayout(ctx context.Context, applicationID, freelancerUserID int32, usdAmount int64, operation string, params database.OperationParams, payoutAt, deadline time.Time, reason string) (*database.DeferredOperation, error)
+	ListHeldPayouts(ctx context.Context, freelancerUserID int32) ([]database.HeldPayout, error)
+	ReleaseHeldPayouts(ctx context.Context, freelancerUserID int32, reason string) (int64, error)
+
+	// Refunds and costs
+	RecordRefund(ctx context.Context, applicationID int32, reason string, usdAmount int32, txHash string) error
+	GetRefundReport(ctx context.Context, r listquery.Range, filters []listquery.Condition, tags []string) ([]database.RefundReportRow, error)
+	RecordTransactionCost(ctx context.Context, cost database.TransactionCost) error
+	GetJobGasCost(ctx context.Context, applicationID int32) (*database.JobGasCost, error)
+	GetGasCostReport(ctx context.Context, r listquery.Range, filters []listquery.Condition, tags []string) ([]database.GasCostReportRow, error)
+	GetGasWasteReport(ctx context.Context, r listquery.Range, filters []listquery.Condition, tags []string) ([]database.GasWasteReportRow, error)
+	GetOperationGasAverages(ctx context.Context, since time.Time) ([]database.OperationGasAverage, error)
+	ListTransactionCosts(ctx context.Context, applicationID int32) ([]database.TransactionCost, error)
+
+	// Address book
+	RecordAddress(ctx context.Context, address string, userID int32, role string) error
+	GetAddress(ctx context.Context, address string) (*database.AddressBookEntry, error)
+	SetAddressLabel(ctx context.Context, address string, label string) (bool, error)
+
+	// Retainers
+	CreateRetainer(ctx context.Context, retainer *database.Retainer) error
+	GetRetainer(ctx context.Context, id int64) (*database.Retainer, error)
+	ListApplicationRetainerIDs(ctx context.Context, applicationID int32) ([]int64, error)
+	ListDueRetainers(ctx context.Context, now time.Time) ([]*database.Retainer, error)
+	SetRetainerStatus(ctx context.Context, id int64, status string) error
+	StartRetainerPeriod(ctx context.Context, retainer *database.Retainer, periodStart, nextPeriodAt time.Time) (*database.RetainerPeriod, error)
+	GetRetainerPeriod(ctx context.Context, retainerID int64, periodNumber int) (*database.RetainerPeriod, error)
+	ListRetainerPeriods(ctx context.Context, retainerID int64) ([]*database.RetainerPeriod, error)
+	ListRetainerPeriodsByStatus(ctx context.Context, statuses ...string) ([]*database.RetainerPeriod, error)
+	UpdateRetainerPeriod(ctx context.Context, id int64, status string, txType string, txHash *string, lastError *string) error
+	QuoteRetainerPeriod(ctx context.Context, id int64, ethUSDPrice, requiredWei string) error
+	RecordRetainerPeriodDeposit(ctx context.Context, id int64, status string, txHash string, depositedWei string, topUpWei, overfundedWei *string) error
+	CreateTopUp(ctx context.Context, applicationID int32, usdAmount int32, reason, actor string) (*database.TopUp, error)
+	ListTopUps(ctx context.Context, applicationID int32) ([]*database.TopUp, error)
+	UpdateTopUp(ctx context.Context, id int64, status string, txType string, txHash *string, lastError *string) error
+	SetJobWebhook(ctx context.Context, applicationID int32, url string) error
+	GetJobWebhook(ctx context.Context, applicationID int32) (string, error)
+
+	// Velocity limits and review queue
+	GetClientEscrowUSD(ctx context.Context, posterUserID int32, excludeApplicationID int32, since time.Time) (int64, error)
+	CountFreelancerRefunds(ctx context.Context, applicantUserID int32, since time.Time) (int64, error)
+	CreateReview(ctx context.Context, review *database.Review) (*database.Review, error)
+	GetReview(ctx context.Context, id int64) (*database.Review, error)
+	FindReviews(ctx context.Context, params listquery.Params) ([]*database.Review, string, error)
+	ResolveReview(ctx context.Context, id int64, status, actor, reason string) (*database.Review, error)
+
+	// Reserve ledger
+	PostLedgerTransaction(ctx context.Context, t *ledger.Transaction) (bool, error)
+	PostReservePayout(ctx context.Context, t *ledger.Transaction, amount *big.Int) (bool, error)
+	GetLedgerBalances(ctx context.Context) (map[string]string, error)
+	ListUnbalancedLedgerTransactions(ctx context.Context) ([]int64, error)
+	ListLedgerTransactions(ctx context.Context, kind string, limit int) ([]*database.LedgerTransaction, error)
+	FindLedgerTransactions(ctx context.Context, filter database.LedgerFilter) ([]*database.LedgerTransaction, error)
+
+	// Fee overrides
+	SetFeeOverride(ctx context.Context, override database.FeeOverride) error
+	GetFeeOverride(ctx context.Context, applicationID int32) (*database.FeeOverride, error)
+
+	// Feature flags
+	ListFeatureFlagRules(ctx context.Context) ([]features.Rule, error)
+	SetFeatureFlagRule(ctx context.Context, rule features.Rule, actor, reason string) error
+	DeleteFeatureFlagRule(ctx context.Context, flag features.Flag, tenant string, networkID int64, actor, reason string) (bool, error)
+
+	// Contract upgrades
+	RecordContractImplementation(ctx context.Context, proxy, implementation string) (*string, error)
+	RecordContractUpdate(ctx context.Context, update *database.ContractUpdate, reason string) error
+	LatestContractUpdate(ctx context.Context, networkID int64) (*database.ContractUpdate, error)
+
+	// Platform notifications
+	Listen(ctx context.Context, channel string) (*database.Listener, error)
+
+	// Health history
+	Ping(ctx context.Context) error
+	PoolStats() database.PoolStats
+	RecordHealthSnapshots(ctx context.Context, snapshots []*database.HealthSnapshot, olderThan time.Time) error
+	FindHealthSnapshots(ctx context.Context, params listquery.Params) ([]*database.HealthSnapshot, string, error)
+	GetHealthSummary(ctx context.Context, since time.Time) (*database.HealthSummary, error)
+
+	// Archival
+	ListArchivableApplications(ctx context.Context, settledBefore time.Time, limit int) ([]*database.ApplicationPaymentDetails, error)
+	RecordArchivedApplications(ctx context.Context, batch, objectKey string, applicationIDs []int32) error
+	RestoreArchivedJob(ctx context.Context, job database.ArchivedJob) (*database.RestoreResult, error)
+
+	// Settlement summaries
+	BuildSettlementSummary(ctx context.Context, day time.Time) (*database.SettlementSummary, error)
+	GetSettlementSummary(ctx context.Context, day time.Time) (*database.SettlementSummary, error)
+	ListSettlementSummaries(ctx context.Context, from, to time.Time) ([]*database.SettlementSummary, error)
+	LatestSettlementDay(ctx context.Context) (*time.Time, error)
+
+	// Maintenance mode
+	StartMaintenance(ctx context.Context, reason, actor string, expectedEnd *time.Time) (*database.MaintenanceWindow, bool, error)
+	EndMaintenance(ctx context.Context, actor, reason string) (*database.MaintenanceWindow, error)
+	GetActiveMaintenance(ctx context.Context) (*database.MaintenanceWindow, error)
+
+	// Tax exports
+	ListFreelancerTaxSummaries(ctx context.Context, year int, userID int32, filters []listquery.Condition, tags []string) ([]*database.FreelancerTaxSummary, error)
+	RecordAudit(ctx context.Context, entry database.AuditEntry) error
+	VerifyAuditLog(ctx context.Context) (*database.AuditVerification, error)
+
+	// Signing kill switch and wallet activity
+	RecordSignedTransaction(ctx context.Context, address string, nonce uint64, txHash string) error
+	ListUnsignedNonces(ctx context.Context, address string, from, to uint64) ([]uint64, error)
+	RecordBroadcast(ctx context.Context, b database.Broadcast) error
+	GetBroadcast(ctx context.Context, txHash string) (*database.Broadcast, error)
+	ListUnresolvedBroadcasts(ctx context.Context, address string, belowNonce uint64) ([]*database.Broadcast, error)
+	ResolveBroadcast(ctx context.Context, txHash, outcome string) error
+	GetWalletWatermark(ctx context.Context, address string) (uint64, bool, error)
+	SetWalletWatermark(ctx context.Context, address string, nonce uint64) error
+	TripSigningHalt(ctx context.Context, reason, actor string, details json.RawMessage) (*database.SigningHalt, bool, error)
+	RearmSigning(ctx context.Context, actor, reason string, details json.RawMessage) (*database.SigningHalt, error)
+	ApproveKillSwitch(ctx context.Context, action, reason, actor string, expiresAt time.Time) (*database.KillSwitchApproval, *database.SigningHalt, error)
+	GetActiveSigningHalt(ctx context.Context) (*database.SigningHalt, error)
+
+	// Disputes
+	OpenDispute(ctx context.Context, applicationID int32, reason, actor string) (*database.Dispute, bool, error)
+	GetDispute(ctx context.Context, applicationID int32) (*database.Dispute, error)
+	AddDisputeEvidence(ctx context.Context, evidence database.DisputeEvidence) (*database.DisputeEvidence, error)
+	ListDisputeEvidence(ctx context.Context, disputeID int64) ([]*database.DisputeEvidence, error)
+	ResolveDispute(ctx context.Context, disputeID int64, outcome, justification, actor string) (*database.Dispute, error)
+
+	// Escrow discovery
+	SaveDiscoveredEscrow(ctx context.Context, escrow database.DiscoveredEscrow) error
+	LinkDiscoveredEscrow(ctx context.Context, escrow database.DiscoveredEscrow, actor string) error
+	FindDiscoveredEscrows(ctx context.Context, params listquery.Params) ([]database.DiscoveredEscrow, string, error)
+
+	// KYC holds
+	CreateKYCHold(ctx context.Context, hold *database.KYCHold) (*database.KYCHold, error)
+	GetKYCHold(ctx context.Context, id int64) (*database.KYCHold, error)
+	ListKYCHolds(ctx context.Context, status string) ([]*database.KYCHold, error)
+	FindKYCHolds(ctx context.Context, params listquery.Params) ([]*database.KYCHold, string, error)
+	RecordKYCCheck(ctx context.Context, id int64, kycStatus string, lastError *string) error
+	ResolveKYCHold(ctx context.Context, id int64, status, kycStatus, actor, reason string) (*database.KYCHold, error)
+	GetUserKYCStatus(ctx context.Context, userID int32, column string) (string, error)
+
+	// Escrow event indexing
+	GetEventCheckpoint(ctx context.Context, name string) (*database.EventCheckpoint, error)
+	IndexEvents(ctx context.Context, fromBlock uint64, events []database.IndexedEvent, checkpoint database.EventCheckpoint) (*database.IndexResult, error)
+	ListIndexedEvents(ctx context.Context, fromBlock, toBlock uint64) ([]database.IndexedEvent, error)
+	SaveEventCheckpoint(ctx context.Context, checkpoint database.EventCheckpoint) error
+	GetEscrowJobApplication(ctx context.Context, jobID uint64) (int32, bool, error)
+
+	// Client limits
+	FindClientLimits(ctx context.Context, params listquery.Params) ([]clientlimit.Limit, string, error)
+	GetClientLimits(ctx context.Context, wallet, tenant string) ([]clientlimit.Limit, error)
+	SetClientLimit(ctx context.Context, limit clientlimit.Limit, actor, reason string) error
+	DeleteClientLimit(ctx context.Context, scope clientlimit.Scope, subject, actor, reason string) (bool, error)
+	GetClientOpenUSD(ctx context.Context, scope clientlimit.Scope, subject string, excludeApplicationID int32) (int64, error)
+	RecordEscrowTenant(ctx context.Context, applicationID int32, tenant string) error
+	GetEscrowTenant(ctx context.Context, applicationID int32) (string, error)
+
+	// Client funding links
+	CreateFundingLink(ctx context.Context, link database.FundingLink) (*database.FundingLink, error)
+	GetFundingLink(ctx context.Context, applicationID int32) (*database.FundingLink, error)
+	ListWatchedFundingLinks(ctx context.Context, expiredSince time.Time) ([]*database.FundingLink, error)
+	ResolveFundingLink(ctx context.Context, id int64, status string, txHash *string, fundedAt *time.Time) (bool, error)
+
+	// Display currencies
+	GetDisplayCurrencies(ctx context.Context, applicationID int32) (*database.DisplayCurrencies, error)
+	SetDisplayCurrencies(ctx context.Context, applicationID int32, currencies database.DisplayCurrencies, actor string) error
+
+	// Base fee history
+	RecordBaseFee(ctx context.Context, blockNumber uint64, baseFee *big.Int, olderThan time.Time) error
+	GetBaseFeeProfile(ctx context.Context, since time.Time) (gaswindow.Profile, error)
+
+	// Release authorizations
+	CreateReleaseAuthorization(ctx context.Context, applicationID int32, actor string, expiresAt time.Time) (*database.ReleaseAuthorization, error)
+	GetReleaseAuthorization(ctx context.Context, applicationID int32) (*database.ReleaseAuthorization, error)
+	RecordReleaseAuthorizationTx(ctx context.Context, id int64, txHash string) error
+	LapseReleaseAuthorizations(ctx context.Context, now time.Time) ([]*database.ReleaseAuthorization, error)
+
+	Close()
+}
+
+var (
+	_ ChainClient = (*payment.Client)(nil)
+	_ PriceOracle = (*payment.Client)(nil)
+	_ Store       = (*database.DB)(nil)
+	_ Notifier    = (*webhook.Notifier)(nil)
+)
+
+// PaymentGateway serves the HTTP API and runs the background workers. Its
+// dependencies are fixed at construction and safe for concurrent use, so one
+// gateway serves every request.
+type PaymentGateway struct {
+	client   ChainClient
+	oracle   PriceOracle
+	config   *config.Config
+	db       Store
+	notifier Notifier
+	pollWake chan struct{} // wakes the receipt poller after a submission
+	life     *lifecycle    // the root context the background goroutines run under
+
+	submissions *workpool.Pool // bounds concurrent chain submissions
+	urgent      *workpool.Pool // the urgent lane, so bulk work can't delay it
+	connections *connTracker   // the API server's client connections, nil until it is built
+
+	statusTokens *statustoken.Signer // nil when public status links are disabled
+	replay       *replay.Guard       // nil when confirmation requests need no signature
+	adminKeys    []adminKey          // empty when admin-key endpoints are disabled
+	feeApprovers map[string]bool     // admin keys that may override a job's fee; nil for all of them
+
+	contractUpdates     *contractupdate.Verifier // nil when runtime contract updates are disabled
+	killSwitchApprovals *killswitch.Verifier     // nil when the kill switch needs two admin keys
+	fx                  fx.Provider              // nil when no exchange rates are configured
+	kyc                 kyc.Checker              // nil when releases don't wait for KYC
+	priceSources        []oracle.Source          // checked against the contract's rate for escrows of ORACLE_CONSENSUS_USD or more
+
+	contract    atomic.Pointer[ContractInfoResponse]       // latest proxy check, nil until the first
+	priceFeed   atomic.Pointer[PriceFeedHealth]            // latest heartbeat check, nil until the first
+	maintenance atomic.Pointer[database.MaintenanceWindow] // open window while read-only, nil otherwise
+	signingHalt atomic.Pointer[database.SigningHalt]       // halt in force while the kill switch is tripped, nil otherwise
+	congestion  atomic.Pointer[CongestionHealth]           // latest congestion check, nil until the first
+	canary      atomic.Pointer[CanaryHealth]               // latest startup canary, nil until the first
+	inclusions  inclusionTimes                             // how long recent transactions took to be mined
+	explorer    explorer.Links                             // block explorer for NETWORK_ID; builds no links when unknown
+
+	featureDefaults map[features.Flag]bool
+	featureRules    *cache.TTL[struct{}, []features.Rule]
+
+	velocity velocity.Limits // anti-fraud limits checked before escrows and refunds
+
+	automation     automation.Rules // application status transitions acted on; empty disables
+	automationWake chan struct{}    // wakes the automation worker after a platform status event
+
+	workers sync.Map // background worker name → workerRun, for health snapshots
+
+	schema *graphql.Schema // what /graphql serves
+
+	archive archive.Bucket // where settled jobs are exported; nil when archival is disabled
+}
+
+// Option replaces one of the gateway's default dependencies
+type Option func(*gatewayOptions)
+
+type gatewayOptions struct {
+	client   ChainClient
+	oracle   PriceOracle
+	store    Store
+	signer   payment.Signer
+	notifier Notifier
+	kyc      kyc.Checker
+	sources  []oracle.Source
+}
+
+// WithChainClient uses client instead of dialing ETHEREUM_RPC_URL
+func WithChainClient(client ChainClient) Option {
+	return func(o *gatewayOptions) { o.client = client }
+}
+
+// WithOracle reads prices from oracle instead of the chain client
+func WithOracle(oracle PriceOracle) Option {
+	return func(o *gatewayOptions) { o.oracle = oracle }
+}
+
+// WithStore uses store instead of connecting to and migrating the database
+func WithStore(store Store) Option {
+	return func(o *gatewayOptions) { o.store = store }
+}
+
+// WithSigner signs the default chain client's transactions with signer
+// instead of PRIVATE_KEY. It has no effect together with WithChainClient.
+func WithSigner(signer payment.Signer) Option {
+	return func(o *gatewayOptions) { o.signer = signer }
+}
+
+// WithNotifier publishes events through notifier instead of WEBHOOK_URL
+func WithNotifier(notifier Notifier) Option {
+	return func(o *gatewayOptions) { o.notifier = notifier }
+}
+
+// WithKYCChecker gates releases on checker instead of KYC_SOURCE
+func WithKYCChecker(checker kyc.Checker) Option {
+	return func(o *gatewayOptions) { o.kyc = checker }
+}
+
+// WithPriceSources checks large escrows' ETH/USD rate against sources instead
+// of ORACLE_FALLBACK_FEED and ORACLE_CONSENSUS_PRICE_URL
+func WithPriceSources(sources ...oracle.Source) Option {
+	return func(o *gatewayOptions) { o.sources = sources }
+}
+
+// NewPaymentGateway wires a gateway from cfg, building every dependency that
+// no option supplies
+func NewPaymentGateway(cfg *config.Config, opts ...Option) (*PaymentGateway, error) {
+	var options gatewayOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var statusTokens *statustoken.Signer
+	if cfg.StatusTokenSecret != "" {
+		signer, err := statustoken.NewSigner(cfg.StatusTokenSecret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid STATUS_TOKEN_SECRET: %v", err)
+		}
+		statusTokens = signer
+	}
+
+	var replayGuard *replay.Guard
+	if cfg.RequestSigningSecret != "" {
+		guard, err := replay.NewGuard(cfg.RequestSigningSecret, cfg.ReplayWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REQUEST_SIGNING_SECRET: %v", err)
+		}
+		replayGuard = guard
+	}
+
+	adminKeys, err := parseAdminKeys(cfg.AdminAPIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADMIN_API_KEYS: %v", err)
+	}
+	feeApprovers, err := parseKeyNames(cfg.FeeOverrideApprovers, adminKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FEE_OVERRIDE_APPROVERS: %v", err)
+	}
+
+	featureDefaults, err := features.ParseDefaults(cfg.FeatureFlags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FEATURE_FLAGS: %v", err)
+	}
+
+	velocityLimits, err := velocity.ParseLimits(cfg.VelocityLimits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VELOCITY_LIMITS: %v", err)
+	}
+	automationRules, err := automation.ParseRules(cfg.AutomationRules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTOMATION_RULES: %v", err)
+	}
+
+	explorerURLs, err := explorer.ParseURLs(cfg.ExplorerURLs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EXPLORER_URLS: %v", err)
+	}
+
+	switch {
+	case options.kyc != nil || cfg.KYCSource == "" || cfg.KYCSource == "platform":
+	case cfg.KYCSource != "provider":
+		return nil, fmt.Errorf("invalid KYC_SOURCE %q: expected platform or provider", cfg.KYCSource)
+	case cfg.KYCProviderURL == "":
+		return nil, fmt.Errorf("KYC_SOURCE=provider needs KYC_PROVIDER_URL")
+	}
+
+	if cfg.UrgentGasPricePercent != 0 && cfg.UrgentGasPricePercent < 100 {
+		return nil, fmt.Errorf("invalid URGENT_GAS_PRICE_PERCENT %d: urgent transactions can't offer less than the suggested gas price", cfg.UrgentGasPricePercent)
+	}
+	for name, strategy := range map[string]string{"FEE_STRATEGY": cfg.FeeStrategy, "RELEASE_FEE_STRATEGY": cfg.ReleaseFeeStrategy, "URGENT_FEE_STRATEGY": cfg.UrgentFeeStrategy} {
+		if _, err := payment.ParseFeeStrategy(strategy); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	if cfg.UrgentMaxGasPrice < 0 {
+		return nil, fmt.Errorf("invalid URGENT_MAX_GAS_PRICE %d", cfg.UrgentMaxGasPrice)
+	}
+	if cfg.CongestionBaseFee < 0 {
+		return nil, fmt.Errorf("invalid CONGESTION_BASE_FEE %d", cfg.CongestionBaseFee)
+	}
+	if cfg.CongestionGasUsedPercent < 0 || cfg.CongestionGasUsedPercent > 100 {
+		return nil, fmt.Errorf("invalid CONGESTION_GAS_USED_PERCENT %d: expected 0 to 100", cfg.CongestionGasUsedPercent)
+	}
+	if cfg.CongestionPendingTxs < 0 {
+		return nil, fmt.Errorf("invalid CONGESTION_PENDING_TXS %d", cfg.CongestionPendingTxs)
+	}
+	switch cfg.CanaryMode {
+	case "", canaryOff:
+	case canaryEstimate, canaryTransfer:
+		if cfg.CanaryRetryInterval <= 0 {
+			return nil, fmt.Errorf("invalid CANARY_RETRY_INTERVAL %s", cfg.CanaryRetryInterval)
+		}
+	default:
+		return nil, fmt.Errorf("invalid CANARY_MODE %q: expected %s, %s or %s", cfg.CanaryMode, canaryEstimate, canaryTransfer, canaryOff)
+	}
+
+	if cfg.ExpectedImplementation != "" && !common.IsHexAddress(cfg.ExpectedImplementation) {
+		return nil, fmt.Errorf("invalid EXPECTED_IMPLEMENTATION_ADDRESS %q", cfg.ExpectedImplementation)
+	}
+	if cfg.OracleFallbackFeed != "" && !common.IsHexAddress(cfg.OracleFallbackFeed) {
+		return nil, fmt.Errorf("invalid ORACLE_FALLBACK_FEED %q", cfg.OracleFallbackFeed)
+	}
+	if cfg.OracleConsensusUSD > 0 {
+		sources := len(options.sources)
+		if options.sources == nil {
+			for _, configured := range []string{cfg.OracleFallbackFeed, cfg.OracleConsensusPriceURL} {
+				if configured != "" {
+					sources++
+				}
+			}
+		}
+		switch {
+		case cfg.OracleConsensusTolerancePercent <= 0:
+			return nil, fmt.Errorf("invalid ORACLE_CONSENSUS_TOLERANCE_PERCENT %v", cfg.OracleConsensusTolerancePercent)
+		case cfg.OracleConsensusMinSources < 2:
+			return nil, fmt.Errorf("invalid ORACLE_CONSENSUS_MIN_SOURCES %d: consensus needs at least 2 sources", cfg.OracleConsensusMinSources)
+		case cfg.OracleConsensusMinSources > sources+1:
+			return nil, fmt.Errorf("ORACLE_CONSENSUS_MIN_SOURCES=%d needs more price sources than the contract's rate and %d others: set ORACLE_FALLBACK_FEED or ORACLE_CONSENSUS_PRICE_URL", cfg.OracleConsensusMinSources, sources)
+		}
+	}
+
+	var contractUpdates *contractupdate.Verifier
+	if cfg.ContractUpdateSigner != "" {
+		if !common.IsHexAddress(cfg.ContractUpdateSigner) {
+			return nil, fmt.Errorf("invalid CONTRACT_UPDATE_SIGNER %q", cfg.ContractUpdateSigner)
+		}
+		contractUpdates = &contractupdate.Verifier{
+			Signer:    common.HexToAddress(cfg.ContractUpdateSigner),
+			NetworkID: cfg.NetworkID,
+			MaxAge:    cfg.ContractUpdateMaxAge,
+		}
+	}
+
+	var killSwitchApprovals *killswitch.Verifier
+	if cfg.KillSwitchApprovers != "" {
+		approvers, err := killswitch.ParseApprovers(cfg.KillSwitchApprovers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KILL_SWITCH_APPROVERS: %v", err)
+		}
+		killSwitchApprovals = &killswitch.Verifier{
+			Approvers: approvers,
+			NetworkID: cfg.NetworkID,
+			MaxAge:    cfg.KillSwitchApprovalWindow,
+		}
+	}
+
+	var rates fx.Provider
+	switch {
+	case cfg.FXRatesURL != "":
+		rates = fx.NewHTTP(cfg.FXRatesURL, cfg.FXCacheTTL)
+	case cfg.FXRates != "":
+		static, err := fx.ParseStatic(cfg.FXRates)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FX_RATES: %v", err)
+		}
+		rates = static
+	}
+
+	var archiveBucket archive.Bucket
+	if cfg.ArchiveBucketURL != "" {
+		bucket, err := archive.Open(cfg.ArchiveBucketURL, archive.S3Options{
+			Endpoint:  cfg.ArchiveEndpoint,
+			Region:    cfg.ArchiveRegion,
+			AccessKey: cfg.ArchiveAccessKey,
+			SecretKey: cfg.ArchiveSecretKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARCHIVE_BUCKET_URL: %v", err)
+		}
+		archiveBucket = bucket
+	}
+
+	var faults *chaos.Injector
+	if cfg.FaultInjection {
+		if cfg.NetworkID == mainnetChainID {
+			return nil, fmt.Errorf("FAULT_INJECTION cannot be enabled on mainnet")
+		}
+		injector, err := chaos.NewInjector(chaos.Rates{
+			RPCTimeout:     cfg.FaultRPCTimeoutRate,
+			ReceiptDelay:   cfg.FaultReceiptDelayRate,
+			DBError:        cfg.FaultDBErrorRate,
+			WebhookFailure: cfg.FaultWebhookFailureRate,
+		}, cfg.FaultSeed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault injection rates: %v", err)
+		}
+		faults = injector
+	}
+
+	// Initialize blockchain client
+	client := options.client
+	if client == nil {
+		var clientOpts []payment.ClientOption
+		if options.signer != nil {
+			clientOpts = append(clientOpts, payment.WithSigner(options.signer))
+		}
+		paymentClient, err := payment.NewClient(cfg, clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		client = paymentClient
+	}
+
+	priceOracle := options.oracle
+	if priceOracle == nil {
+		var ok bool
+		if priceOracle, ok = client.(PriceOracle); !ok {
+			client.Close()
+			return nil, fmt.Errorf("chain client does not report prices; supply WithOracle")
+		}
+	}
+
+	// Initialize database connection
+	store := options.store
+	if store == nil {
+		db, err := database.NewDB(cfg.DatabaseURL)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to database: %v", err)
+		}
+		db.QueryTimeout = cfg.DBQueryTimeout
+		db.SetDetailsCacheTTL(cfg.DetailsCacheTTL)
+
+		// Create gateway-owned tables
+		if err := db.Migrate(context.Background()); err != nil {
+			client.Close()
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate database: %v", err)
+		}
+		store = db
+	}
+
+	kycChecker := options.kyc
+	switch {
+	case kycChecker != nil:
+	case cfg.KYCSource == "platform":
+		kycChecker = kyc.CheckerFunc(func(ctx context.Context, userID int32) (kyc.Status, error) {
+			raw, err := store.GetUserKYCStatus(ctx, userID, cfg.KYCPlatformColumn)
+			if err != nil {
+				return "", err
+			}
+			return kyc.ParseStatus(raw), nil
+		})
+	case cfg.KYCSource == "provider":
+		kycChecker = kyc.NewHTTP(cfg.KYCProviderURL, cfg.KYCProviderAPIKey)
+	}
+
+	notifier := options.notifier
+	if notifier == nil {
+		notifier = webhook.NewNotifier(cfg.WebhookURL, cfg.WebhookSecret)
+	}
+
+	if faults != nil {
+		log.Printf("Fault injection enabled: rpc_timeout=%v receipt_delay=%v db_error=%v webhook_failure=%v",
+			cfg.FaultRPCTimeoutRate, cfg.FaultReceiptDelayRate, cfg.FaultDBErrorRate, cfg.FaultWebhookFailureRate)
+		client = chaosChain{ChainClient: client, faults: faults}
+		priceOracle = chaosOracle{PriceOracle: priceOracle, faults: faults}
+		store = chaosStore{Store: store, faults: faults}
+		notifier = chaosNotifier{Notifier: notifier, faults: faults}
+	}
+
+	sources := options.sources
+	if sources == nil {
+		sources = priceSources(cfg, priceOracle)
+	}
+
+	pg := &PaymentGateway{
+		client:       client,
+		oracle:       priceOracle,
+		config:       cfg,
+		db:           store,
+		notifier:     notifier,
+		pollWake:     make(chan struct{}, 1),
+		life:         newLifecycle(),
+		submissions:  workpool.New(cfg.SubmissionWorkers, cfg.SubmissionQueueDepth),
+		urgent:       workpool.New(cfg.UrgentSubmissionWorkers, cfg.SubmissionQueueDepth),
+		statusTokens: statusTokens,
+		replay:       replayGuard,
+		adminKeys:    adminKeys,
+		feeApprovers: feeApprovers,
+
+		contractUpdates:     contractUpdates,
+		killSwitchApprovals: killSwitchApprovals,
+		fx:                  rates,
+		kyc:                 kycChecker,
+		priceSources:        sources,
+		explorer:            explorer.ForNetwork(cfg.NetworkID, explorerURLs),
+
+		featureDefaults: featureDefaults,
+		featureRules:    cache.NewTTL[struct{}, []features.Rule](featureRulesTTL),
+
+		velocity: velocityLimits,
+
+		automation:     automationRules,
+		automationWake: make(chan struct{}, 1),
+
+		archive: archiveBucket,
+	}
+	pg.client = signingGuard{ChainClient: client, pg: pg}
+	pg.schema = pg.graphqlSchema()
+	return pg, nil
+}
+
+// Close waits for submissions in flight, then releases the chain client and store
+func (pg *PaymentGateway) Close() {
+	pg.submissions.Close()
+	pg.urgent.Close()
+	pg.client.Close()
+	pg.db.Close()
+}
@@ -0,0 +1,5729 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/clientlimit"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/contractupdate"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/gaswindow"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/killswitch"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/kyc"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/replay"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/retainer"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/statustoken"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/trace"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/velocity"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/webhook"
+)
+
+// fakeStore serves applications from memory. Methods a test does not expect
+// to be called fall through to the nil embedded Store and panic.
+type fakeStore struct {
+	Store
+	details map[int32]*database.ApplicationPaymentDetails
+	events  map[int32][]database.PaymentEvent
+	changes []database.StatusChange
+
+	implementations  []string
+	flagRules        []features.Rule
+	gasAverages      []database.OperationGasAverage
+	topUps           []*database.TopUp
+	webhooks         map[int32]string
+	clientEscrowUSD  int64
+	reviews          []*database.Review
+	contractUpdates  []*database.ContractUpdate
+	deferred         []*database.DeferredOperation
+	healthSnapshots  []*database.HealthSnapshot
+	pingErr          error
+	ledger           []*database.LedgerTransaction
+	costs            map[int32][]database.TransactionCost
+	archived         map[int32]string // application → batch
+	restored         []database.ArchivedJob
+	discovered       map[uint64]database.DiscoveredEscrow
+	escrowJobs       map[int32]uint64
+	clientLimits     []clientlimit.Limit
+	clientOpenUSD    map[string]int64 // "scope:subject" → open USD
+	escrowTenants    map[int32]string
+	releaseAuths     []*database.ReleaseAuthorization
+	settlements      []*database.SettlementSummary
+	maintenance      []*database.MaintenanceWindow
+	taxSummaries     []*database.FreelancerTaxSummary
+	audit            []database.AuditEntry
+	jobTags          map[int32][]string
+	displayCurrency  map[int32]database.DisplayCurrencies
+	fundingLinks     []*database.FundingLink
+	disputes         []*database.Dispute
+	evidence         []*database.DisputeEvidence
+	signedNonces     map[string]bool // "address:nonce"
+	broadcasts       []*database.Broadcast
+	watermarks       map[string]uint64
+	signingHalts     []*database.SigningHalt
+	killSwitchVotes  []*database.KillSwitchApproval
+	baseFees         []uint64 // blocks sampled
+	baseFeeProfile   gaswindow.Profile
+	intents          []*database.ChainIntent
+	nextStatuses     map[int32][]paymentstatus.Status // applied one per StatusChanged call, waking that watch
+	payoutPrefs      map[int32]*database.PayoutPreference
+	payoutHolds      map[int32]int32 // application → freelancer
+	checkpoint       *database.EventCheckpoint
+	statusCheckpoint *database.EventCheckpoint
+	indexed          []database.IndexedEvent // ordered by block, as IndexEvents leaves them
+	kycHolds         []*database.KYCHold
+	idempotencyKeys  map[string]*fakeIdempotencyKey // "tenant/key"
+	feeOverrides     map[int32]database.FeeOverride
+	statusSeen       map[int32]string // application → status last acted on
+	refundReasons    map[int32]string
+}
+
+type fakeIdempotencyKey struct {
+	requestHash string
+	response    *database.IdempotentResponse
+}
+
+func (s *fakeStore) GetApplicationPaymentDetails(ctx context.Context, applicationID int32) (*database.ApplicationPaymentDetails, error) {
+	details, ok := s.details[applicationID]
+	if !ok {
+		return nil, fmt.Errorf("error querying application payment details: %w", pgx.ErrNoRows)
+	}
+	return details, nil
+}
+
+func (s *fakeStore) CloneApplication(ctx context.Context, sourceID int32, usdAmount int32, actor string) (int32, error) {
+	source, ok := s.details[sourceID]
+	if !ok {
+		return 0, fmt.Errorf("error cloning application: %w", pgx.ErrNoRows)
+	}
+	applicationID := slices.Max(slices.Collect(maps.Keys(s.details))) + 1
+	s.details[applicationID] = &database.ApplicationPaymentDetails{
+		ApplicationID:          applicationID,
+		JobID:                  source.JobID,
+		ApplicantUserID:        source.ApplicantUserID,
+		PosterUserID:           source.PosterUserID,
+		AgreedUSDAmount:        &usdAmount,
+		PaymentStatus:          "pending_deposit",
+		ApplicantWalletAddress: source.ApplicantWalletAddress,
+		PosterWalletAddress:    source.PosterWalletAddress,
+		ApplicationStatus:      database.ClonedApplicationStatus,
+	}
+	if tags, ok := s.jobTags[sourceID]; ok {
+		s.SetJobTags(ctx, applicationID, slices.Clone(tags), actor)
+	}
+	if currencies, ok := s.displayCurrency[sourceID]; ok {
+		s.SetDisplayCurrencies(ctx, applicationID, currencies, actor)
+	}
+	if url, ok := s.webhooks[sourceID]; ok {
+		s.SetJobWebhook(ctx, applicationID, url)
+	}
+	return applicationID, nil
+}
+
+func (s *fakeStore) ListReleasableApplications(ctx context.Context, applicantUserID int32, approvedStatus string) ([]*database.ApplicationPaymentDetails, error) {
+	var applications []*database.ApplicationPaymentDetails
+	for _, id := range slices.Sorted(maps.Keys(s.details)) {
+		details := s.details[id]
+		if details.ApplicantUserID == applicantUserID && details.PaymentStatus == "deposited" && details.ApplicationStatus == approvedStatus {
+			copied := *details
+			applications = append(applications, &copied)
+		}
+	}
+	return applications, nil
+}
+
+func (s *fakeStore) ListApplications(ctx context.Context, filter database.ApplicationFilter) ([]*database.ApplicationPaymentDetails, string, error) {
+	var applications []*database.ApplicationPaymentDetails
+	for _, id := range slices.Sorted(maps.Keys(s.details)) {
+		details := s.details[id]
+		tagged := true
+		for _, tag := range filter.Tags {
+			tagged = tagged && slices.Contains(s.jobTags[id], tag)
+		}
+		if (filter.PaymentStatus == "" || details.PaymentStatus == filter.PaymentStatus) && tagged {
+			applications = append(applications, details)
+		}
+	}
+	applications, next := fakeFind(applications, filter.Page, "", func(d *database.ApplicationPaymentDetails) (int64, string) {
+		return int64(d.ApplicationID), ""
+	})
+	return applications, next, nil
+}
+
+func (s *fakeStore) ListApprovedDeposits(ctx context.Context, approvedStatus string) ([]int32, error) {
+	var ids []int32
+	for _, id := range slices.Sorted(maps.Keys(s.details)) {
+		if details := s.details[id]; details.PaymentStatus == "deposited" && details.ApplicationStatus == approvedStatus {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *fakeStore) GetPaymentEvents(ctx context.Context, applicationID int32) ([]database.PaymentEvent, error) {
+	return s.events[applicationID], nil
+}
+
+// ListPaymentEvents serves every application's events, newest application
+// first, in ID order when the events have IDs
+func (s *fakeStore) ListPaymentEvents(ctx context.Context, filter database.PaymentEventFilter) ([]database.PaymentEvent, string, error) {
+	var events []database.PaymentEvent
+	for _, id := range slices.Sorted(maps.Keys(s.events)) {
+		for _, event := range s.events[id] {
+			event.ApplicationID = id
+			if filter.Status == "" || event.Status == filter.Status {
+				events = append(events, event)
+			}
+		}
+	}
+	events, next := fakeFind(events, filter.Page, "", func(e database.PaymentEvent) (int64, string) {
+		return e.ID, ""
+	})
+	return events, next, nil
+}
+
+func (s *fakeStore) FindLedgerTransactions(ctx context.Context, filter database.LedgerFilter) ([]*database.LedgerTransaction, error) {
+	var transactions []*database.LedgerTransaction
+	for _, t := range s.ledger {
+		if (filter.Kind == "" || t.Kind == filter.Kind) && (filter.ApplicationID == 0 || t.ApplicationID != nil && *t.ApplicationID == filter.ApplicationID) {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+func (s *fakeStore) PostLedgerTransaction(ctx context.Context, t *ledger.Transaction) (bool, error) {
+	if err := t.Validate(); err != nil {
+		return false, err
+	}
+	for _, posted := range s.ledger {
+		if t.Reference != "" && posted.Kind == t.Kind && posted.Reference == t.Reference {
+			return false, nil
+		}
+	}
+	posted := &database.LedgerTransaction{ID: int64(len(s.ledger) + 1), Kind: t.Kind, Reference: t.Reference, ApplicationID: t.ApplicationID, Memo: t.Memo, Actor: t.Actor}
+	if t.Counterparty != "" {
+		posted.Counterparty = &t.Counterparty
+	}
+	for _, entry := range t.Entries {
+		posted.Entries = append(posted.Entries, database.LedgerEntry{Account: entry.Account, AmountWei: entry.Amount.String()})
+	}
+	s.ledger = append(s.ledger, posted)
+	return true, nil
+}
+
+func (s *fakeStore) SetFeeOverride(ctx context.Context, override database.FeeOverride) error {
+	if s.feeOverrides == nil {
+		s.feeOverrides = make(map[int32]database.FeeOverride)
+	}
+	s.feeOverrides[override.ApplicationID] = override
+	return nil
+}
+
+func (s *fakeStore) GetFeeOverride(ctx context.Context, applicationID int32) (*database.FeeOverride, error) {
+	if override, ok := s.feeOverrides[applicationID]; ok {
+		return &override, nil
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) ApplyStatusChange(ctx context.Context, change database.StatusChange) error {
+	if details, ok := s.details[change.ApplicationID]; ok {
+		if len(change.FromStatuses) > 0 && !slices.Contains(change.FromStatuses, details.PaymentStatus) {
+			return database.ErrStatusConflict
+		}
+		details.PaymentStatus = change.Status
+	}
+	s.changes = append(s.changes, change)
+	if change.IntentID != 0 {
+		return s.ResolveChainIntent(ctx, change.IntentID, database.IntentSent, change.TxHash, "")
+	}
+	return nil
+}
+
+func (s *fakeStore) PoolStats() database.PoolStats {
+	return database.PoolStats{}
+}
+
+func (s *fakeStore) StatusChanged(applicationID int32) <-chan struct{} {
+	changed := make(chan struct{})
+	if next := s.nextStatuses[applicationID]; len(next) > 0 {
+		s.details[applicationID].PaymentStatus = next[0]
+		s.nextStatuses[applicationID] = next[1:]
+		close(changed)
+	}
+	return changed
+}
+
+func (s *fakeStore) BeginChainIntent(ctx context.Context, applicationID int32, operation string, params database.OperationParams) (*database.ChainIntent, error) {
+	key := database.IntentKey(operation, params.JobID)
+	for _, intent := range s.intents {
+		if intent.IdempotencyKey == key && intent.Outcome == nil {
+			return nil, fmt.Errorf("%w: %s", database.ErrIntentPending, key)
+		}
+	}
+	intent := &database.ChainIntent{ID: int64(len(s.intents) + 1), ApplicationID: applicationID, Operation: operation, Params: params, IdempotencyKey: key, CreatedAt: time.Now()}
+	s.intents = append(s.intents, intent)
+	return intent, nil
+}
+
+func (s *fakeStore) ResolveChainIntent(ctx context.Context, id int64, outcome string, txHash *string, errMsg string) error {
+	for _, intent := range s.intents {
+		if intent.ID == id && intent.Outcome == nil {
+			intent.Outcome, intent.TxHash = &outcome, txHash
+			if errMsg != "" {
+				intent.Error = &errMsg
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) ListUnresolvedChainIntents(ctx context.Context, createdBefore time.Time) ([]*database.ChainIntent, error) {
+	var unresolved []*database.ChainIntent
+	for _, intent := range s.intents {
+		if intent.Outcome == nil && intent.CreatedAt.Before(createdBefore) {
+			unresolved = append(unresolved, intent)
+		}
+	}
+	return unresolved, nil
+}
+
+func (s *fakeStore) ClaimIdempotencyKey(ctx context.Context, tenant, key, requestHash string, staleBefore, expireBefore time.Time) (*database.IdempotentResponse, error) {
+	if s.idempotencyKeys == nil {
+		s.idempotencyKeys = make(map[string]*fakeIdempotencyKey)
+	}
+	claimed, ok := s.idempotencyKeys[tenant+"/"+key]
+	switch {
+	case !ok:
+		s.idempotencyKeys[tenant+"/"+key] = &fakeIdempotencyKey{requestHash: requestHash}
+		return nil, nil
+	case claimed.requestHash != requestHash:
+		return nil, database.ErrIdempotencyKeyReused
+	case claimed.response == nil:
+		return nil, database.ErrIdempotencyKeyInFlight
+	}
+	return claimed.response, nil
+}
+
+func (s *fakeStore) CompleteIdempotencyKey(ctx context.Context, tenant, key string, response database.IdempotentResponse) error {
+	s.idempotencyKeys[tenant+"/"+key].response = &response
+	return nil
+}
+
+func (s *fakeStore) ReleaseIdempotencyKey(ctx context.Context, tenant, key string) error {
+	delete(s.idempotencyKeys, tenant+"/"+key)
+	return nil
+}
+
+func (s *fakeStore) GetJobGasCost(ctx context.Context, applicationID int32) (*database.JobGasCost, error) {
+	return &database.JobGasCost{}, nil
+}
+
+func (s *fakeStore) ListApplicationRetainerIDs(ctx context.Context, applicationID int32) ([]int64, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) GetPendingDeferredOperation(ctx context.Context, applicationID int32) (*database.DeferredOperation, error) {
+	for _, op := range s.deferred {
+		if op.ApplicationID == applicationID && (op.Status == database.DeferredStatusDeferred || op.Status == database.DeferredStatusPaused) {
+			return op, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) Ping(ctx context.Context) error {
+	return s.pingErr
+}
+
+func (s *fakeStore) RecordHealthSnapshots(ctx context.Context, snapshots []*database.HealthSnapshot, olderThan time.Time) error {
+	if s.pingErr != nil {
+		return s.pingErr
+	}
+	s.healthSnapshots = append(s.healthSnapshots, snapshots...)
+	return nil
+}
+
+// FindHealthSnapshots and GetHealthSummary expect healthSnapshots oldest
+// first, in ID order
+func (s *fakeStore) FindHealthSnapshots(ctx context.Context, params listquery.Params) ([]*database.HealthSnapshot, string, error) {
+	var snapshots []*database.HealthSnapshot
+	for _, snapshot := range s.healthSnapshots {
+		matches := fakeMatch(params.Conditions, "status", snapshot.Status)
+		for _, c := range params.Conditions {
+			if c.Field == "taken_at" {
+				at := c.Values[0].(time.Time)
+				matches = matches && (c.Op == listquery.Gte && !snapshot.TakenAt.Before(at) || c.Op == listquery.Lt && snapshot.TakenAt.Before(at))
+			}
+		}
+		if params.After != nil {
+			after := params.After[1].(int64)
+			matches = matches && (params.Sort.Desc && snapshot.ID < after || !params.Sort.Desc && snapshot.ID > after)
+		}
+		if matches {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	if params.Sort.Desc {
+		slices.Reverse(snapshots)
+	}
+	snapshots, next := listquery.Page(snapshots, params, func(snapshot *database.HealthSnapshot, field string) any {
+		if field == "taken_at" {
+			return snapshot.TakenAt
+		}
+		return snapshot.ID
+	})
+	return snapshots, next, nil
+}
+
+func (s *fakeStore) GetHealthSummary(ctx context.Context, since time.Time) (*database.HealthSummary, error) {
+	summary := &database.HealthSummary{}
+	for _, snapshot := range s.healthSnapshots {
+		if !snapshot.TakenAt.Before(since) {
+			summary.Total++
+			if snapshot.Status == database.HealthStatusOK {
+				summary.OK++
+			}
+		}
+		if snapshot.Status == database.HealthStatusOK {
+			summary.DegradedSince = nil
+		} else if summary.DegradedSince == nil {
+			summary.DegradedSince = &snapshot.TakenAt
+		}
+	}
+	return summary, nil
+}
+
+func (s *fakeStore) CreateDeferredOperation(ctx context.Context, applicationID int32, operation string, params database.OperationParams, deadline time.Time, reason string) (*database.DeferredOperation, error) {
+	op := &database.DeferredOperation{ID: int64(len(s.deferred) + 1), ApplicationID: applicationID, Operation: operation, Params: params, Status: database.DeferredStatusDeferred, Deadline: deadline, LastError: &reason}
+	s.deferred = append(s.deferred, op)
+	return op, nil
+}
+
+func (s *fakeStore) ScheduleDeferredOperation(ctx context.Context, applicationID int32, operation string, params database.OperationParams, at, deadline time.Time, reason string) (*database.DeferredOperation, error) {
+	op := &database.DeferredOperation{ID: int64(len(s.deferred) + 1), ApplicationID: applicationID, Operation: operation, Params: params, Status: database.DeferredStatusDeferred, Deadline: deadline, LastError: &reason, NextAttemptAt: &at}
+	s.deferred = append(s.deferred, op)
+	return op, nil
+}
+
+func (s *fakeStore) GetPayoutPreference(ctx context.Context, freelancerUserID int32) (*database.PayoutPreference, error) {
+	return s.payoutPrefs[freelancerUserID], nil
+}
+
+func (s *fakeStore) SetPayoutPreference(ctx context.Context, pref database.PayoutPreference) error {
+	if s.payoutPrefs == nil {
+		s.payoutPrefs = map[int32]*database.PayoutPreference{}
+	}
+	s.payoutPrefs[pref.FreelancerUserID] = &pref
+	return nil
+}
+
+func (s *fakeStore) DeletePayoutPreference(ctx context.Context, freelancerUserID int32, actor string) (bool, error) {
+	_, ok := s.payoutPrefs[freelancerUserID]
+	delete(s.payoutPrefs, freelancerUserID)
+	return ok, nil
+}
+
+func (s *fakeStore) HoldPayout(ctx context.Context, applicationID, freelancerUserID int32, usdAmount int64, operation string, params database.OperationParams, payoutAt, deadline time.Time, reason string) (*database.DeferredOperation, error) {
+	if s.payoutHolds == nil {
+		s.payoutHolds = map[int32]int32{}
+	}
+	s.payoutHolds[applicationID] = freelancerUserID
+	return s.ScheduleDeferredOperation(ctx, applicationID, operation, params, payoutAt, deadline, reason)
+}
+
+func (s *fakeStore) ListHeldPayouts(ctx context.Context, freelancerUserID int32) ([]database.HeldPayout, error) {
+	var held []database.HeldPayout
+	for _, op := range s.deferred {
+		if s.payoutHolds[op.ApplicationID] == freelancerUserID && op.Status == database.DeferredStatusDeferred {
+			held = append(held, database.HeldPayout{ApplicationID: op.ApplicationID, USDAmount: int64(*s.details[op.ApplicationID].AgreedUSDAmount), OperationID: op.ID, PayoutAt: *op.NextAttemptAt})
+		}
+	}
+	return held, nil
+}
+
+func (s *fakeStore) ReleaseHeldPayouts(ctx context.Context, freelancerUserID int32, reason string) (int64, error) {
+	var released int64
+	now := time.Now()
+	for _, op := range s.deferred {
+		if s.payoutHolds[op.ApplicationID] == freelancerUserID && op.Status == database.DeferredStatusDeferred && op.NextAttemptAt.After(now) {
+			op.NextAttemptAt, op.LastError = &now, &reason
+			released++
+		}
+	}
+	return released, nil
+}
+
+func (s *fakeStore) RecordBaseFee(ctx context.Context, blockNumber uint64, baseFee *big.Int, olderThan time.Time) error {
+	s.baseFees = append(s.baseFees, blockNumber)
+	return nil
+}
+
+func (s *fakeStore) GetBaseFeeProfile(ctx context.Context, since time.Time) (gaswindow.Profile, error) {
+	return s.baseFeeProfile, nil
+}
+
+func (s *fakeStore) ListDueDeferredOperations(ctx context.Context) ([]*database.DeferredOperation, error) {
+	var due []*database.DeferredOperation
+	for _, op := range s.deferred {
+		if op.Status == database.DeferredStatusDeferred {
+			due = append(due, op)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeStore) UpdateDeferredOperation(ctx context.Context, id int64, status string, txHash *string, lastError *string) error {
+	for _, op := range s.deferred {
+		if op.ID == id {
+			op.Status, op.TxHash, op.LastError = status, txHash, lastError
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) ConfirmDeferredOperationPrice(ctx context.Context, id int64, quotedPrice string) (*database.DeferredOperation, error) {
+	for _, op := range s.deferred {
+		if op.ID == id {
+			if op.Status != database.DeferredStatusPaused {
+				return nil, database.ErrNotPaused
+			}
+			op.Status, op.Params.QuotedETHUSDPrice = database.DeferredStatusDeferred, quotedPrice
+			return op, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) RecordContractUpdate(ctx context.Context, update *database.ContractUpdate, reason string) error {
+	update.ID = int64(len(s.contractUpdates) + 1)
+	update.CreatedAt = time.Now()
+	s.contractUpdates = append(s.contractUpdates, update)
+	return nil
+}
+
+func (s *fakeStore) LatestContractUpdate(ctx context.Context, networkID int64) (*database.ContractUpdate, error) {
+	for i := len(s.contractUpdates) - 1; i >= 0; i-- {
+		if s.contractUpdates[i].NetworkID == networkID {
+			return s.contractUpdates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) RecordContractImplementation(ctx context.Context, proxy, implementation string) (*string, error) {
+	var previous *string
+	if n := len(s.implementations); n > 0 && s.implementations[n-1] != implementation {
+		previous = &s.implementations[n-1]
+	}
+	s.implementations = append(s.implementations, implementation)
+	return previous, nil
+}
+
+func (s *fakeStore) ListFeatureFlagRules(ctx context.Context) ([]features.Rule, error) {
+	return s.flagRules, nil
+}
+
+func (s *fakeStore) ValidateApplicationForBlockchain(ctx context.Context, applicationID int32) error {
+	return nil
+}
+
+func (s *fakeStore) RecordAddress(ctx context.Context, address string, userID int32, role string) error {
+	return nil
+}
+
+func (s *fakeStore) GetOperationGasAverages(ctx context.Context, since time.Time) ([]database.OperationGasAverage, error) {
+	return s.gasAverages, nil
+}
+
+// ListPaymentChanges serves every recorded event as if each was its own committed transaction
+func (s *fakeStore) ListPaymentChanges(ctx context.Context, after database.ChangeCursor, filters []listquery.Condition, limit int) ([]database.PaymentChange, error) {
+	var changes []database.PaymentChange
+	for _, applicationID := range slices.Sorted(maps.Keys(s.events)) {
+		for i, event := range s.events[applicationID] {
+			id := int64(applicationID)*100 + int64(i) + 1
+			cursor := database.ChangeCursor{TxID: uint64(id), ID: id}
+			if cursor.TxID > after.TxID && len(changes) < limit && fakeMatch(filters, "status", event.Status) {
+				event.ID, event.ApplicationID = id, applicationID
+				changes = append(changes, database.PaymentChange{PaymentEvent: event, Cursor: cursor})
+			}
+		}
+	}
+	return changes, nil
+}
+
+func (s *fakeStore) CreateTopUp(ctx context.Context, applicationID int32, usdAmount int32, reason, actor string) (*database.TopUp, error) {
+	topUp := &database.TopUp{ID: int64(len(s.topUps) + 1), ApplicationID: applicationID, USDAmount: usdAmount, Status: database.TopUpStatusPending, Reason: reason, Actor: actor}
+	s.topUps = append(s.topUps, topUp)
+	copied := *topUp
+	return &copied, nil
+}
+
+func (s *fakeStore) ListTopUps(ctx context.Context, applicationID int32) ([]*database.TopUp, error) {
+	var topUps []*database.TopUp
+	for _, topUp := range s.topUps {
+		if topUp.ApplicationID == applicationID {
+			copied := *topUp
+			topUps = append(topUps, &copied)
+		}
+	}
+	return topUps, nil
+}
+
+func (s *fakeStore) UpdateTopUp(ctx context.Context, id int64, status string, txType string, txHash *string, lastError *string) error {
+	topUp := s.topUps[id-1]
+	topUp.Status, topUp.LastError = status, lastError
+	switch txType {
+	case "deposit":
+		topUp.TxHashDeposit = txHash
+	case "release":
+		topUp.TxHashRelease = txHash
+	case "refund":
+		topUp.TxHashRefund = txHash
+	}
+	return nil
+}
+
+func (s *fakeStore) GetJobTags(ctx context.Context, applicationID int32) ([]string, error) {
+	return append([]string{}, s.jobTags[applicationID]...), nil
+}
+
+func (s *fakeStore) SetJobTags(ctx context.Context, applicationID int32, tags []string, actor string) error {
+	if s.jobTags == nil {
+		s.jobTags = make(map[int32][]string)
+	}
+	s.jobTags[applicationID] = tags
+	return nil
+}
+
+func (s *fakeStore) GetDisplayCurrencies(ctx context.Context, applicationID int32) (*database.DisplayCurrencies, error) {
+	currencies, ok := s.displayCurrency[applicationID]
+	if !ok {
+		return nil, nil
+	}
+	return &currencies, nil
+}
+
+func (s *fakeStore) SetDisplayCurrencies(ctx context.Context, applicationID int32, currencies database.DisplayCurrencies, actor string) error {
+	if s.displayCurrency == nil {
+		s.displayCurrency = make(map[int32]database.DisplayCurrencies)
+	}
+	s.displayCurrency[applicationID] = currencies
+	return nil
+}
+
+func (s *fakeStore) CreateFundingLink(ctx context.Context, link database.FundingLink) (*database.FundingLink, error) {
+	for _, l := range s.fundingLinks {
+		if l.ApplicationID == link.ApplicationID && (l.Status == database.FundingLinkOpen || l.Status == database.FundingLinkExpired) {
+			l.Status = database.FundingLinkSuperseded
+		}
+	}
+	link.ID, link.Status, link.CreatedAt = int64(len(s.fundingLinks)+1), database.FundingLinkOpen, time.Now()
+	s.fundingLinks = append(s.fundingLinks, &link)
+	return &link, nil
+}
+
+func (s *fakeStore) GetFundingLink(ctx context.Context, applicationID int32) (*database.FundingLink, error) {
+	for _, link := range slices.Backward(s.fundingLinks) {
+		if link.ApplicationID == applicationID {
+			return link, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) ListWatchedFundingLinks(ctx context.Context, expiredSince time.Time) ([]*database.FundingLink, error) {
+	var links []*database.FundingLink
+	for _, link := range s.fundingLinks {
+		if link.Status == database.FundingLinkOpen || link.Status == database.FundingLinkExpired && !link.ExpiresAt.Before(expiredSince) {
+			copied := *link
+			links = append(links, &copied)
+		}
+	}
+	return links, nil
+}
+
+func (s *fakeStore) ResolveFundingLink(ctx context.Context, id int64, status string, txHash *string, fundedAt *time.Time) (bool, error) {
+	for _, link := range s.fundingLinks {
+		if link.ID == id && (link.Status == database.FundingLinkOpen || link.Status == database.FundingLinkExpired) {
+			link.Status, link.TxHash, link.FundedAt = status, txHash, fundedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeStore) OpenDispute(ctx context.Context, applicationID int32, reason, actor string) (*database.Dispute, bool, error) {
+	if open, _ := s.GetDispute(ctx, applicationID); open != nil && open.ResolvedAt == nil {
+		return open, false, nil
+	}
+	dispute := &database.Dispute{ID: int64(len(s.disputes) + 1), ApplicationID: applicationID, Reason: reason, OpenedBy: actor, OpenedAt: time.Now()}
+	s.disputes = append(s.disputes, dispute)
+	return dispute, true, nil
+}
+
+func (s *fakeStore) GetDispute(ctx context.Context, applicationID int32) (*database.Dispute, error) {
+	var latest *database.Dispute
+	for _, dispute := range s.disputes {
+		if dispute.ApplicationID == applicationID && (latest == nil || dispute.ResolvedAt == nil || latest.ResolvedAt != nil) {
+			latest = dispute
+		}
+	}
+	return latest, nil
+}
+
+func (s *fakeStore) AddDisputeEvidence(ctx context.Context, evidence database.DisputeEvidence) (*database.DisputeEvidence, error) {
+	evidence.ID = int64(len(s.evidence) + 1)
+	evidence.CreatedAt = time.Now()
+	s.evidence = append(s.evidence, &evidence)
+	return &evidence, nil
+}
+
+func (s *fakeStore) ListDisputeEvidence(ctx context.Context, disputeID int64) ([]*database.DisputeEvidence, error) {
+	var evidence []*database.DisputeEvidence
+	for _, e := range s.evidence {
+		if e.DisputeID == disputeID {
+			evidence = append(evidence, e)
+		}
+	}
+	return evidence, nil
+}
+
+func (s *fakeStore) ResolveDispute(ctx context.Context, disputeID int64, outcome, justification, actor string) (*database.Dispute, error) {
+	dispute := s.disputes[disputeID-1]
+	if dispute.ResolvedAt != nil {
+		return nil, database.ErrDisputeResolved
+	}
+	now := time.Now()
+	dispute.Outcome, dispute.Justification, dispute.ResolvedBy, dispute.ResolvedAt = &outcome, &justification, &actor, &now
+	return dispute, nil
+}
+
+func (s *fakeStore) SetJobWebhook(ctx context.Context, applicationID int32, url string) error {
+	if s.webhooks == nil {
+		s.webhooks = make(map[int32]string)
+	}
+	s.webhooks[applicationID] = url
+	return nil
+}
+
+func (s *fakeStore) RecordSignedTransaction(ctx context.Context, address string, nonce uint64, txHash string) error {
+	if s.signedNonces == nil {
+		s.signedNonces = make(map[string]bool)
+	}
+	s.signedNonces[fmt.Sprintf("%s:%d", address, nonce)] = true
+	return nil
+}
+
+func (s *fakeStore) ListUnsignedNonces(ctx context.Context, address string, from, to uint64) ([]uint64, error) {
+	var nonces []uint64
+	for nonce := from; nonce < to; nonce++ {
+		if !s.signedNonces[fmt.Sprintf("%s:%d", address, nonce)] {
+			nonces = append(nonces, nonce)
+		}
+	}
+	return nonces, nil
+}
+
+func (s *fakeStore) RecordBroadcast(ctx context.Context, b database.Broadcast) error {
+	if existing, _ := s.GetBroadcast(ctx, b.TxHash); existing == nil {
+		b.CreatedAt = time.Now()
+		s.broadcasts = append(s.broadcasts, &b)
+	}
+	return nil
+}
+
+func (s *fakeStore) GetBroadcast(ctx context.Context, txHash string) (*database.Broadcast, error) {
+	for _, b := range s.broadcasts {
+		if b.TxHash == txHash {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListUnresolvedBroadcasts keeps the order they were recorded in within a nonce
+func (s *fakeStore) ListUnresolvedBroadcasts(ctx context.Context, address string, belowNonce uint64) ([]*database.Broadcast, error) {
+	var broadcasts []*database.Broadcast
+	for _, b := range s.broadcasts {
+		if b.Address == address && b.Nonce < belowNonce && b.Outcome == nil {
+			broadcasts = append(broadcasts, b)
+		}
+	}
+	slices.SortStableFunc(broadcasts, func(a, b *database.Broadcast) int { return cmp.Compare(a.Nonce, b.Nonce) })
+	return broadcasts, nil
+}
+
+func (s *fakeStore) ResolveBroadcast(ctx context.Context, txHash, outcome string) error {
+	if b, _ := s.GetBroadcast(ctx, txHash); b != nil && b.Outcome == nil {
+		b.Outcome = &outcome
+	}
+	return nil
+}
+
+func (s *fakeStore) GetWalletWatermark(ctx context.Context, address string) (uint64, bool, error) {
+	nonce, ok := s.watermarks[address]
+	return nonce, ok, nil
+}
+
+func (s *fakeStore) SetWalletWatermark(ctx context.Context, address string, nonce uint64) error {
+	if s.watermarks == nil {
+		s.watermarks = make(map[string]uint64)
+	}
+	s.watermarks[address] = max(s.watermarks[address], nonce)
+	return nil
+}
+
+func (s *fakeStore) TripSigningHalt(ctx context.Context, reason, actor string, details json.RawMessage) (*database.SigningHalt, bool, error) {
+	if active, _ := s.GetActiveSigningHalt(ctx); active != nil {
+		return active, false, nil
+	}
+	halt := &database.SigningHalt{ID: int64(len(s.signingHalts) + 1), Reason: reason, TrippedBy: actor, TrippedAt: time.Now()}
+	s.signingHalts = append(s.signingHalts, halt)
+	return halt, true, nil
+}
+
+func (s *fakeStore) RearmSigning(ctx context.Context, actor, reason string, details json.RawMessage) (*database.SigningHalt, error) {
+	active, _ := s.GetActiveSigningHalt(ctx)
+	if active != nil {
+		now := time.Now()
+		active.RearmedBy, active.RearmedAt = &actor, &now
+	}
+	return active, nil
+}
+
+func (s *fakeStore) ApproveKillSwitch(ctx context.Context, action, reason, actor string, expiresAt time.Time) (*database.KillSwitchApproval, *database.SigningHalt, error) {
+	var proposal *database.KillSwitchApproval
+	for _, vote := range s.killSwitchVotes {
+		if vote.Action == action && vote.ApprovedBy == nil && vote.ExpiresAt.After(time.Now()) {
+			proposal = vote
+		}
+	}
+	if proposal == nil {
+		proposal = &database.KillSwitchApproval{ID: int64(len(s.killSwitchVotes) + 1), Action: action, Reason: reason, ProposedBy: actor, ProposedAt: time.Now(), ExpiresAt: expiresAt}
+		s.killSwitchVotes = append(s.killSwitchVotes, proposal)
+		return proposal, nil, nil
+	}
+	if proposal.ProposedBy == actor {
+		return nil, nil, database.ErrSameApprover
+	}
+	now := time.Now()
+	proposal.ApprovedBy, proposal.ApprovedAt = &actor, &now
+
+	approvers := proposal.ProposedBy + "," + actor
+	if action == database.KillSwitchActivate {
+		halt, _, err := s.TripSigningHalt(ctx, proposal.Reason, approvers, nil)
+		return proposal, halt, err
+	}
+	halt, err := s.RearmSigning(ctx, approvers, proposal.Reason, nil)
+	return proposal, halt, err
+}
+
+func (s *fakeStore) GetActiveSigningHalt(ctx context.Context) (*database.SigningHalt, error) {
+	for _, halt := range s.signingHalts {
+		if halt.RearmedAt == nil {
+			return halt, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) GetJobWebhook(ctx context.Context, applicationID int32) (string, error) {
+	return s.webhooks[applicationID], nil
+}
+
+func (s *fakeStore) GetClientEscrowUSD(ctx context.Context, posterUserID int32, excludeApplicationID int32, since time.Time) (int64, error) {
+	return s.clientEscrowUSD, nil
+}
+
+func (s *fakeStore) GetAddress(ctx context.Context, address string) (*database.AddressBookEntry, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) CreateReview(ctx context.Context, review *database.Review) (*database.Review, error) {
+	if review.PreviousStatus != "" {
+		details := s.details[review.ApplicationID]
+		if details.PaymentStatus != review.PreviousStatus {
+			return nil, database.ErrStatusConflict
+		}
+		details.PaymentStatus = paymentstatus.PendingReview
+	}
+	created := *review
+	created.ID = int64(len(s.reviews) + 1)
+	created.Status = database.ReviewStatusOpen
+	s.reviews = append(s.reviews, &created)
+	return &created, nil
+}
+
+func (s *fakeStore) GetReview(ctx context.Context, id int64) (*database.Review, error) {
+	if id < 1 || id > int64(len(s.reviews)) {
+		return nil, nil
+	}
+	return s.reviews[id-1], nil
+}
+
+func (s *fakeStore) ResolveReview(ctx context.Context, id int64, status, actor, reason string) (*database.Review, error) {
+	review := s.reviews[id-1]
+	if review.Status != database.ReviewStatusOpen {
+		return nil, database.ErrReviewResolved
+	}
+	review.Status = status
+	review.ResolvedBy = &actor
+	review.ResolutionReason = &reason
+	if review.PreviousStatus != "" {
+		s.details[review.ApplicationID].PaymentStatus = review.PreviousStatus
+	}
+	return review, nil
+}
+
+func (s *fakeStore) FindReviews(ctx context.Context, params listquery.Params) ([]*database.Review, string, error) {
+	reviews, next := fakeFind(s.reviews, params, "status", func(r *database.Review) (int64, string) { return r.ID, r.Status })
+	return reviews, next, nil
+}
+
+func (s *fakeStore) RecordTransactionCost(ctx context.Context, cost database.TransactionCost) error {
+	if s.costs == nil {
+		s.costs = make(map[int32][]database.TransactionCost)
+	}
+	for _, costs := range s.costs {
+		if slices.ContainsFunc(costs, func(c database.TransactionCost) bool { return c.TxHash == cost.TxHash }) {
+			return nil
+		}
+	}
+	cost.CreatedAt = time.Now()
+	s.costs[cost.ApplicationID] = append(s.costs[cost.ApplicationID], cost)
+	return nil
+}
+
+// GetGasWasteReport reports every period as the range's first and filters
+// only on reason
+func (s *fakeStore) GetGasWasteReport(ctx context.Context, r listquery.Range, filters []listquery.Condition, tags []string) ([]database.GasWasteReportRow, error) {
+	var report []database.GasWasteReportRow
+	for _, t := range s.ledger {
+		if t.Kind != ledger.KindGasWaste || !fakeMatch(filters, "reason", t.Memo) {
+			continue
+		}
+		for _, cost := range s.costs[*t.ApplicationID] {
+			if cost.TxHash == t.Reference && !cost.CreatedAt.Before(r.From) && cost.CreatedAt.Before(r.To) {
+				report = append(report, database.GasWasteReportRow{Period: r.From, Operation: cost.Operation, Reason: t.Memo, Transactions: 1, GasUsed: int64(cost.GasUsed), CostWei: cost.CostWei, CostUSD: *cost.CostUSD})
+			}
+		}
+	}
+	return report, nil
+}
+
+func (s *fakeStore) ListTransactionCosts(ctx context.Context, applicationID int32) ([]database.TransactionCost, error) {
+	return s.costs[applicationID], nil
+}
+
+func (s *fakeStore) ListArchivableApplications(ctx context.Context, settledBefore time.Time, limit int) ([]*database.ApplicationPaymentDetails, error) {
+	var applications []*database.ApplicationPaymentDetails
+	for _, id := range slices.Sorted(maps.Keys(s.details)) {
+		details := s.details[id]
+		if details.PaymentStatus != "released" && details.PaymentStatus != "refunded" || s.archived[id] != "" {
+			continue
+		}
+		if events := s.events[id]; len(events) > 0 && !events[len(events)-1].CreatedAt.Before(settledBefore) {
+			continue
+		}
+		if len(applications) < limit {
+			applications = append(applications, details)
+		}
+	}
+	return applications, nil
+}
+
+func (s *fakeStore) RecordArchivedApplications(ctx context.Context, batch, objectKey string, applicationIDs []int32) error {
+	if s.archived == nil {
+		s.archived = make(map[int32]string)
+	}
+	for _, id := range applicationIDs {
+		s.archived[id] = batch
+	}
+	return nil
+}
+
+func (s *fakeStore) RecordEscrowJob(ctx context.Context, applicationID int32, jobID uint64) error {
+	if s.escrowJobs == nil {
+		s.escrowJobs = make(map[int32]uint64)
+	}
+	for mappedApplication, mappedJob := range s.escrowJobs {
+		if (mappedApplication == applicationID) != (mappedJob == jobID) {
+			return fmt.Errorf("%w: application %d is mapped to job ID %d", database.ErrEscrowJobConflict, mappedApplication, mappedJob)
+		}
+	}
+	s.escrowJobs[applicationID] = jobID
+	return nil
+}
+
+// FindClientLimits numbers the limits in the order they were set
+func (s *fakeStore) FindClientLimits(ctx context.Context, params listquery.Params) ([]clientlimit.Limit, string, error) {
+	ids := make(map[string]int64)
+	for i, limit := range s.clientLimits {
+		ids[string(limit.Scope)+"/"+limit.Subject] = int64(i) + 1
+	}
+	limits, next := fakeFind(s.clientLimits, params, "scope", func(l clientlimit.Limit) (int64, string) {
+		return ids[string(l.Scope)+"/"+l.Subject], string(l.Scope)
+	})
+	return limits, next, nil
+}
+
+func (s *fakeStore) GetClientLimits(ctx context.Context, wallet, tenant string) ([]clientlimit.Limit, error) {
+	var limits []clientlimit.Limit
+	for _, limit := range s.clientLimits {
+		if (limit.Scope == clientlimit.Wallet && limit.Subject == wallet) || (limit.Scope == clientlimit.Tenant && limit.Subject == tenant) {
+			limits = append(limits, limit)
+		}
+	}
+	return limits, nil
+}
+
+func (s *fakeStore) SetClientLimit(ctx context.Context, limit clientlimit.Limit, actor, reason string) error {
+	s.DeleteClientLimit(ctx, limit.Scope, limit.Subject, actor, reason)
+	s.clientLimits = append(s.clientLimits, limit)
+	return nil
+}
+
+func (s *fakeStore) DeleteClientLimit(ctx context.Context, scope clientlimit.Scope, subject, actor, reason string) (bool, error) {
+	for i, limit := range s.clientLimits {
+		if limit.Scope == scope && limit.Subject == subject {
+			s.clientLimits = slices.Delete(s.clientLimits, i, i+1)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeStore) GetClientOpenUSD(ctx context.Context, scope clientlimit.Scope, subject string, excludeApplicationID int32) (int64, error) {
+	return s.clientOpenUSD[string(scope)+":"+subject], nil
+}
+
+func (s *fakeStore) RecordEscrowTenant(ctx context.Context, applicationID int32, tenant string) error {
+	if s.escrowTenants == nil {
+		s.escrowTenants = make(map[int32]string)
+	}
+	s.escrowTenants[applicationID] = tenant
+	return nil
+}
+
+func (s *fakeStore) GetEscrowTenant(ctx context.Context, applicationID int32) (string, error) {
+	return s.escrowTenants[applicationID], nil
+}
+
+func (s *fakeStore) RecordRefund(ctx context.Context, applicationID int32, reason string, usdAmount int32, txHash string) error {
+	if s.refundReasons == nil {
+		s.refundReasons = make(map[int32]string)
+	}
+	s.refundReasons[applicationID] = reason
+	return nil
+}
+
+func (s *fakeStore) ListApplicationTransitions(ctx context.Context, limit int) ([]database.ApplicationTransition, error) {
+	var transitions []database.ApplicationTransition
+	for _, id := range slices.Sorted(maps.Keys(s.details)) {
+		details := s.details[id]
+		_, mapped := s.escrowJobs[id]
+		if !mapped && details.EscrowTxHashDeposit == nil {
+			continue
+		}
+		seen, ok := s.statusSeen[id]
+		if details.ApplicationStatus == "" || seen == details.ApplicationStatus {
+			continue
+		}
+		if !ok && (details.PaymentStatus == paymentstatus.Released || details.PaymentStatus == paymentstatus.Refunded) {
+			continue
+		}
+		transitions = append(transitions, database.ApplicationTransition{
+			ApplicationID:  id,
+			Tenant:         s.escrowTenants[id],
+			PreviousStatus: seen,
+			Status:         details.ApplicationStatus,
+			PaymentStatus:  details.PaymentStatus,
+		})
+	}
+	if len(transitions) > limit {
+		transitions = transitions[:limit]
+	}
+	return transitions, nil
+}
+
+func (s *fakeStore) RecordTransition(ctx context.Context, applicationID int32, from, to string) (bool, error) {
+	if s.statusSeen == nil {
+		s.statusSeen = make(map[int32]string)
+	}
+	if seen, ok := s.statusSeen[applicationID]; ok && seen != from {
+		return false, nil
+	}
+	s.statusSeen[applicationID] = to
+	return true, nil
+}
+
+func (s *fakeStore) CreateReleaseAuthorization(ctx context.Context, applicationID int32, actor string, expiresAt time.Time) (*database.ReleaseAuthorization, error) {
+	for _, a := range s.releaseAuths {
+		if a.ApplicationID == applicationID && a.Status == database.ReleaseAuthorizationActive {
+			a.Status = database.ReleaseAuthorizationSuperseded
+		}
+	}
+	a := &database.ReleaseAuthorization{ID: int64(len(s.releaseAuths) + 1), ApplicationID: applicationID, Actor: actor, Status: database.ReleaseAuthorizationActive, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	s.releaseAuths = append(s.releaseAuths, a)
+	return a, nil
+}
+
+func (s *fakeStore) GetReleaseAuthorization(ctx context.Context, applicationID int32) (*database.ReleaseAuthorization, error) {
+	var latest *database.ReleaseAuthorization
+	for _, a := range s.releaseAuths {
+		if a.ApplicationID == applicationID {
+			latest = a
+		}
+	}
+	return latest, nil
+}
+
+func (s *fakeStore) RecordReleaseAuthorizationTx(ctx context.Context, id int64, txHash string) error {
+	for _, a := range s.releaseAuths {
+		if a.ID == id {
+			a.TxHash = &txHash
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) LapseReleaseAuthorizations(ctx context.Context, now time.Time) ([]*database.ReleaseAuthorization, error) {
+	var lapsed []*database.ReleaseAuthorization
+	for _, a := range s.releaseAuths {
+		if a.Expired(now) && s.details[a.ApplicationID].PaymentStatus != "release_initiated" {
+			a.Status = database.ReleaseAuthorizationLapsed
+			lapsed = append(lapsed, a)
+		}
+	}
+	return lapsed, nil
+}
+
+// BuildSettlementSummary replaces the day's summary with one counting a
+// single deposit
+func (s *fakeStore) BuildSettlementSummary(ctx context.Context, day time.Time) (*database.SettlementSummary, error) {
+	summary := &database.SettlementSummary{Day: day, DepositCount: 1, DepositUSD: 100, FeeWei: "0", ReserveWei: "0", ReservePaidWei: "0", GasTransactions: 1, GasWei: "2000000000000000", GasUSD: "5.000000", NetEscrowUSD: 100, NetTreasuryWei: "-2000000000000000", CreatedAt: time.Now()}
+	s.settlements = slices.DeleteFunc(s.settlements, func(existing *database.SettlementSummary) bool { return existing.Day.Equal(day) })
+	s.settlements = append(s.settlements, summary)
+	slices.SortFunc(s.settlements, func(a, b *database.SettlementSummary) int { return a.Day.Compare(b.Day) })
+	return summary, nil
+}
+
+func (s *fakeStore) ListSettlementSummaries(ctx context.Context, from, to time.Time) ([]*database.SettlementSummary, error) {
+	var summaries []*database.SettlementSummary
+	for _, summary := range s.settlements {
+		if !summary.Day.Before(from) && summary.Day.Before(to) {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries, nil
+}
+
+func (s *fakeStore) LatestSettlementDay(ctx context.Context) (*time.Time, error) {
+	if len(s.settlements) == 0 {
+		return nil, nil
+	}
+	return &s.settlements[len(s.settlements)-1].Day, nil
+}
+
+func (s *fakeStore) StartMaintenance(ctx context.Context, reason, actor string, expectedEnd *time.Time) (*database.MaintenanceWindow, bool, error) {
+	if active, _ := s.GetActiveMaintenance(ctx); active != nil {
+		return active, false, nil
+	}
+	window := &database.MaintenanceWindow{ID: int64(len(s.maintenance) + 1), Reason: reason, StartedBy: actor, StartedAt: time.Now(), ExpectedEnd: expectedEnd}
+	s.maintenance = append(s.maintenance, window)
+	return window, true, nil
+}
+
+func (s *fakeStore) EndMaintenance(ctx context.Context, actor, reason string) (*database.MaintenanceWindow, error) {
+	active, _ := s.GetActiveMaintenance(ctx)
+	if active != nil {
+		now := time.Now()
+		active.EndedBy, active.EndedAt = &actor, &now
+	}
+	return active, nil
+}
+
+func (s *fakeStore) GetActiveMaintenance(ctx context.Context) (*database.MaintenanceWindow, error) {
+	for _, window := range s.maintenance {
+		if window.EndedAt == nil {
+			return window, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListFreelancerTaxSummaries ignores filters and tags
+func (s *fakeStore) ListFreelancerTaxSummaries(ctx context.Context, year int, userID int32, filters []listquery.Condition, tags []string) ([]*database.FreelancerTaxSummary, error) {
+	var summaries []*database.FreelancerTaxSummary
+	for _, summary := range s.taxSummaries {
+		if userID == 0 || summary.UserID == userID {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries, nil
+}
+
+func (s *fakeStore) RecordAudit(ctx context.Context, entry database.AuditEntry) error {
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+func (s *fakeStore) SaveDiscoveredEscrow(ctx context.Context, escrow database.DiscoveredEscrow) error {
+	if s.discovered == nil {
+		s.discovered = make(map[uint64]database.DiscoveredEscrow)
+	}
+	s.discovered[escrow.JobID] = escrow
+	return nil
+}
+
+// LinkDiscoveredEscrow applies the escrow's record like the database does
+func (s *fakeStore) LinkDiscoveredEscrow(ctx context.Context, escrow database.DiscoveredEscrow, actor string) error {
+	details := s.details[int32(escrow.JobID)]
+	if details.PaymentStatus != "pending_deposit" {
+		return database.ErrStatusConflict
+	}
+	details.PaymentStatus = escrow.PaymentStatus
+	details.EscrowTxHashDeposit = escrow.DepositTxHash
+	details.EscrowTxHashRelease = escrow.ReleaseTxHash
+	details.EscrowTxHashRefund = escrow.RefundTxHash
+	applicationID := int32(escrow.JobID)
+	escrow.ApplicationID = &applicationID
+	escrow.Outcome = database.DiscoveryLinked
+	return s.SaveDiscoveredEscrow(ctx, escrow)
+}
+
+func (s *fakeStore) FindDiscoveredEscrows(ctx context.Context, params listquery.Params) ([]database.DiscoveredEscrow, string, error) {
+	var escrows []database.DiscoveredEscrow
+	for _, id := range slices.Sorted(maps.Keys(s.discovered)) {
+		escrows = append(escrows, s.discovered[id])
+	}
+	escrows, next := fakeFind(escrows, params, "outcome", func(e database.DiscoveredEscrow) (int64, string) { return int64(e.JobID), e.Outcome })
+	return escrows, next, nil
+}
+
+func (s *fakeStore) GetEventCheckpoint(ctx context.Context, name string) (*database.EventCheckpoint, error) {
+	if name == escrowStatusIndexer {
+		return s.statusCheckpoint, nil
+	}
+	return s.checkpoint, nil
+}
+
+func (s *fakeStore) SaveEventCheckpoint(ctx context.Context, checkpoint database.EventCheckpoint) error {
+	s.statusCheckpoint = &checkpoint
+	return nil
+}
+
+func (s *fakeStore) ListIndexedEvents(ctx context.Context, fromBlock, toBlock uint64) ([]database.IndexedEvent, error) {
+	var events []database.IndexedEvent
+	for _, event := range s.indexed {
+		if event.BlockNumber >= fromBlock && event.BlockNumber <= toBlock {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *fakeStore) GetEscrowJobApplication(ctx context.Context, jobID uint64) (int32, bool, error) {
+	for applicationID, mappedJob := range s.escrowJobs {
+		if mappedJob == jobID {
+			return applicationID, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// IndexEvents replaces the indexed events in the range as the database does,
+// keeping those already indexed by (tx_hash, log_index)
+func (s *fakeStore) IndexEvents(ctx context.Context, fromBlock uint64, events []database.IndexedEvent, checkpoint database.EventCheckpoint) (*database.IndexResult, error) {
+	key := func(e database.IndexedEvent) string { return fmt.Sprintf("%s/%d", e.TxHash, e.LogIndex) }
+	scanned := make(map[string]bool)
+	for _, event := range events {
+		scanned[key(event)] = true
+	}
+	result := &database.IndexResult{}
+	kept := make(map[string]bool)
+	var indexed []database.IndexedEvent
+	for _, event := range s.indexed {
+		if event.BlockNumber >= fromBlock && event.BlockNumber <= checkpoint.BlockNumber && !scanned[key(event)] {
+			result.Removed = append(result.Removed, event)
+			continue
+		}
+		kept[key(event)] = true
+		indexed = append(indexed, event)
+	}
+	for _, event := range events {
+		if !kept[key(event)] {
+			indexed = append(indexed, event)
+			result.Added = append(result.Added, event)
+		}
+	}
+	s.indexed = indexed
+	s.checkpoint = &checkpoint
+	return result, nil
+}
+
+func (s *fakeStore) CreateKYCHold(ctx context.Context, hold *database.KYCHold) (*database.KYCHold, error) {
+	details := s.details[hold.ApplicationID]
+	if details.PaymentStatus != "deposited" {
+		return nil, database.ErrStatusConflict
+	}
+	details.PaymentStatus = paymentstatus.KYCPending
+	created := *hold
+	created.ID = int64(len(s.kycHolds) + 1)
+	created.Status = database.KYCHoldOpen
+	created.CreatedAt = time.Now()
+	s.kycHolds = append(s.kycHolds, &created)
+	return &created, nil
+}
+
+func (s *fakeStore) GetKYCHold(ctx context.Context, id int64) (*database.KYCHold, error) {
+	if id < 1 || id > int64(len(s.kycHolds)) {
+		return nil, nil
+	}
+	return s.kycHolds[id-1], nil
+}
+
+func (s *fakeStore) ListKYCHolds(ctx context.Context, status string) ([]*database.KYCHold, error) {
+	var holds []*database.KYCHold
+	for _, hold := range s.kycHolds {
+		if hold.Status == status {
+			holds = append(holds, hold)
+		}
+	}
+	return holds, nil
+}
+
+func (s *fakeStore) FindKYCHolds(ctx context.Context, params listquery.Params) ([]*database.KYCHold, string, error) {
+	holds, next := fakeFind(s.kycHolds, params, "status", func(h *database.KYCHold) (int64, string) { return h.ID, h.Status })
+	return holds, next, nil
+}
+
+// fakeFind pages items, held in ID order, by ID and filters them on one
+// string field, which is all the list handlers rely on; sorting on other
+// fields is SQL
+func fakeFind[T any](items []T, params listquery.Params, field string, key func(T) (int64, string)) ([]T, string) {
+	var found []T
+	for _, item := range items {
+		id, value := key(item)
+		if params.After != nil && (params.Sort.Desc && id >= params.After[1].(int64) || !params.Sort.Desc && id <= params.After[1].(int64)) {
+			continue
+		}
+		if fakeMatch(params.Conditions, field, value) {
+			found = append(found, item)
+		}
+	}
+	if params.Sort.Desc {
+		slices.Reverse(found)
+	}
+	return listquery.Page(found, params, func(item T, field string) any {
+		id, _ := key(item)
+		return id
+	})
+}
+
+// fakeMatch applies the Eq, Ne and In conditions on field to value
+func fakeMatch(conditions []listquery.Condition, field, value string) bool {
+	for _, c := range conditions {
+		if c.Field == field && (c.Op == listquery.Ne) == slices.Contains(c.Values, any(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *fakeStore) RecordKYCCheck(ctx context.Context, id int64, kycStatus string, lastError *string) error {
+	if hold := s.kycHolds[id-1]; hold.Status == database.KYCHoldOpen {
+		hold.KYCStatus, hold.LastError = kycStatus, lastError
+	}
+	return nil
+}
+
+func (s *fakeStore) ResolveKYCHold(ctx context.Context, id int64, status, kycStatus, actor, reason string) (*database.KYCHold, error) {
+	hold := s.kycHolds[id-1]
+	if hold.Status != database.KYCHoldOpen {
+		return nil, database.ErrKYCHoldResolved
+	}
+	now := time.Now()
+	hold.Status, hold.KYCStatus, hold.LastError = status, kycStatus, nil
+	hold.ResolvedBy, hold.ResolutionReason, hold.ResolvedAt = &actor, &reason, &now
+	s.details[hold.ApplicationID].PaymentStatus = "deposited"
+	copied := *hold
+	return &copied, nil
+}
+
+func (s *fakeStore) RestoreArchivedJob(ctx context.Context, job database.ArchivedJob) (*database.RestoreResult, error) {
+	s.restored = append(s.restored, job)
+	return &database.RestoreResult{Events: len(job.Events), Costs: len(job.Costs), LedgerTransactions: len(job.Ledger)}, nil
+}
+
+func (s *fakeStore) Close() {}
+
+// fakeChain panics on any chain call a test does not stub
+type fakeChain struct {
+	ChainClient
+	closed bool
+	proxy  *payment.ProxyInfo
+	config *payment.ContractConfig
+
+	jobs     map[uint64]*payment.JobDetails
+	deposits map[uint64]*payment.Deposit
+	balance  *big.Int
+
+	posted      []uint64
+	completed   []uint64
+	cancelled   []uint64
+	releaseErrs map[uint64]error // MarkJobCompleted failures by job
+
+	contractAddress common.Address
+	adminAddress    common.Address
+	block           uint64
+	blockErr        error
+	escrowEvents    []payment.EscrowEvent
+	nonce           uint64 // of the signer, advanced by each transaction sent
+	blockTimes      map[uint64]time.Time
+	blockHashes     map[uint64]string
+	history         map[uint64][]payment.JobEvent
+	mempool         uint64 // transactions of the signer sent but not mined
+	blockGas        *payment.BlockGas
+	pendingTxs      uint // in the node's pending pool
+	canaryErr       error
+	receipts        map[common.Hash]*payment.ReceiptStatus // unmined when missing
+	cancelErr       error
+	cancelledTxs    []common.Hash
+}
+
+func (c *fakeChain) Close() { c.closed = true }
+
+func (c *fakeChain) RPCProviders() []payment.RPCProviderStatus { return nil }
+
+func (c *fakeChain) ProbeRPCProviders(ctx context.Context) {}
+
+func (c *fakeChain) GetJobDetails(ctx context.Context, jobID uint64) (*payment.JobDetails, error) {
+	if job, ok := c.jobs[jobID]; ok {
+		return job, nil
+	}
+	return &payment.JobDetails{}, nil
+}
+
+func (c *fakeChain) Address() common.Address {
+	return common.HexToAddress("0x00000000000000000000000000000000000000a0")
+}
+
+func (c *fakeChain) GetBalance(ctx context.Context, address common.Address) (*big.Int, error) {
+	return c.balance, nil
+}
+
+// SuggestGasPrice quotes 10 gwei
+func (c *fakeChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(10_000_000_000), nil
+}
+
+func (c *fakeChain) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.block, c.blockErr
+}
+
+func (c *fakeChain) LatestBlockGas(ctx context.Context) (*payment.BlockGas, error) {
+	if c.blockGas == nil {
+		return nil, errors.New("no block")
+	}
+	return c.blockGas, nil
+}
+
+func (c *fakeChain) PendingTransactionCount(ctx context.Context) (uint, error) {
+	return c.pendingTxs, nil
+}
+
+// ScanEscrowEvents serves the events between the blocks
+func (c *fakeChain) ScanEscrowEvents(ctx context.Context, fromBlock, toBlock uint64) ([]payment.EscrowEvent, error) {
+	var events []payment.EscrowEvent
+	for _, event := range c.escrowEvents {
+		if event.BlockNumber >= fromBlock && event.BlockNumber <= toBlock {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (c *fakeChain) GasPriceCeiling() *big.Int {
+	return nil
+}
+
+func (c *fakeChain) GetJobDeposit(ctx context.Context, jobID uint64) (*payment.Deposit, error) {
+	return c.deposits[jobID], nil
+}
+
+// BlockHash serves the hash set for the block, or one naming it
+func (c *fakeChain) BlockHash(ctx context.Context, blockNumber uint64) (string, error) {
+	if hash, ok := c.blockHashes[blockNumber]; ok {
+		return hash, nil
+	}
+	return fmt.Sprintf("0xblock%d", blockNumber), nil
+}
+
+func (c *fakeChain) BlockTime(ctx context.Context, blockNumber uint64) (time.Time, error) {
+	return c.blockTimes[blockNumber], nil
+}
+
+// PostJob, MarkJobCompleted and CancelJob record the job and succeed with a
+// hash naming it
+func (c *fakeChain) PostJob(ctx context.Context, jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address) (*payment.TransactionResult, error) {
+	c.posted = append(c.posted, jobID)
+	c.nonce++
+	return &payment.TransactionResult{TxHash: fmt.Sprintf("0xpost%d", jobID), From: c.Address(), Nonce: c.nonce - 1, Success: true}, nil
+}
+
+func (c *fakeChain) MarkJobCompleted(ctx context.Context, jobID uint64) (*payment.TransactionResult, error) {
+	if err := c.releaseErrs[jobID]; err != nil {
+		return nil, err
+	}
+	c.completed = append(c.completed, jobID)
+	c.nonce++
+	return &payment.TransactionResult{TxHash: fmt.Sprintf("0xrelease%d", jobID), From: c.Address(), Nonce: c.nonce - 1, Success: true}, nil
+}
+
+func (c *fakeChain) CancelJob(ctx context.Context, jobID uint64) (*payment.TransactionResult, error) {
+	c.cancelled = append(c.cancelled, jobID)
+	c.nonce++
+	return &payment.TransactionResult{TxHash: fmt.Sprintf("0xrefund%d", jobID), From: c.Address(), Nonce: c.nonce - 1, Success: true}, nil
+}
+
+// CancelTransaction sends a cancel transaction naming the one it replaces
+func (c *fakeChain) CancelTransaction(ctx context.Context, hash common.Hash) (*payment.TransactionResult, error) {
+	if c.cancelErr != nil {
+		return nil, c.cancelErr
+	}
+	c.cancelledTxs = append(c.cancelledTxs, hash)
+	return &payment.TransactionResult{TxHash: "0xcancel" + hash.Hex()[2:10], From: c.Address(), Success: true}, nil
+}
+
+func (c *fakeChain) GetReceiptStatuses(ctx context.Context, hashes []common.Hash) (map[common.Hash]*payment.ReceiptStatus, error) {
+	statuses := make(map[common.Hash]*payment.ReceiptStatus, len(hashes))
+	for _, hash := range hashes {
+		statuses[hash] = &payment.ReceiptStatus{TxHash: hash}
+		if receipt, ok := c.receipts[hash]; ok {
+			statuses[hash] = receipt
+		}
+	}
+	return statuses, nil
+}
+
+func (c *fakeChain) LatestBaseFee(ctx context.Context) (uint64, *big.Int, error) {
+	return c.block, big.NewInt(30_000_000_000), nil
+}
+
+func (c *fakeChain) NonceAt(ctx context.Context, address common.Address) (uint64, error) {
+	return c.nonce, nil
+}
+
+func (c *fakeChain) PendingNonceAt(ctx context.Context, address common.Address) (uint64, error) {
+	return c.nonce + c.mempool, nil
+}
+
+func (c *fakeChain) GetJobHistory(ctx context.Context, jobID uint64) ([]payment.JobEvent, error) {
+	return c.history[jobID], nil
+}
+
+func (c *fakeChain) AdminAddress() common.Address {
+	return c.adminAddress
+}
+
+func (c *fakeChain) ContractAddress() common.Address {
+	return c.contractAddress
+}
+
+// SwapContract checks the ABI as the real client does but skips the code lookup
+func (c *fakeChain) SwapContract(ctx context.Context, address common.Address, abiJSON []byte) (common.Address, error) {
+	if err := payment.CheckABICompatible(abiJSON); err != nil {
+		return common.Address{}, err
+	}
+	previous := c.contractAddress
+	c.contractAddress = address
+	return previous, nil
+}
+
+func (c *fakeChain) GetProxyInfo(ctx context.Context) (*payment.ProxyInfo, error) {
+	return c.proxy, nil
+}
+
+func (c *fakeChain) GetContractConfig(ctx context.Context) (*payment.ContractConfig, error) {
+	return c.config, nil
+}
+
+func (c *fakeChain) Canary(ctx context.Context, send bool) (*payment.CanaryResult, error) {
+	if c.canaryErr != nil {
+		return nil, c.canaryErr
+	}
+	result := &payment.CanaryResult{Nonce: c.nonce + c.mempool, GasEstimate: 21000, TxHash: "0xcanary"}
+	if send {
+		result.Sent = &payment.TransactionResult{TxHash: result.TxHash, Success: true}
+		c.nonce++
+	}
+	return result, nil
+}
+
+type fakeNotifier struct{}
+
+func (fakeNotifier) Enabled() bool                                        { return false }
+func (fakeNotifier) Notify(ctx context.Context, e *events.Envelope) error { return nil }
+func (fakeNotifier) NotifyURL(ctx context.Context, url string, e *events.Envelope) error {
+	return nil
+}
+
+// recordingNotifier reports where each event was delivered
+type recordingNotifier struct {
+	deliveries chan string
+}
+
+func (n recordingNotifier) Enabled() bool { return true }
+
+func (n recordingNotifier) Notify(ctx context.Context, e *events.Envelope) error {
+	n.deliveries <- delivery("global", e)
+	return nil
+}
+
+func (n recordingNotifier) NotifyURL(ctx context.Context, url string, e *events.Envelope) error {
+	n.deliveries <- delivery(url, e)
+	return nil
+}
+
+// delivery describes an event sent to target, with its trace when it has one
+func delivery(target string, e *events.Envelope) string {
+	if e.TraceID != "" {
+		return target + " " + string(e.Type) + " trace " + e.TraceID
+	}
+	return target + " " + string(e.Type)
+}
+
+func strPtr(s string) *string { return &s }
+
+func newTestStore() *fakeStore {
+	amount := int32(250)
+	return &fakeStore{
+		details: map[int32]*database.ApplicationPaymentDetails{
+			7: {
+				ApplicationID:          7,
+				AgreedUSDAmount:        &amount,
+				PaymentStatus:          "deposited",
+				EscrowTxHashDeposit:    strPtr("0xdeposit"),
+				ApplicantWalletAddress: strPtr("0x00000000000000000000000000000000000000f1"),
+				PosterWalletAddress:    strPtr("0x00000000000000000000000000000000000000c1"),
+				ApplicationStatus:      "hired",
+			},
+			8: {ApplicationID: 8, PaymentStatus: "pending_deposit"},
+		},
+		events: map[int32][]database.PaymentEvent{
+			7: {
+				{Status: "deposit_initiated", TxHash: strPtr("0xdeposit"), Actor: database.ActorGateway, CreatedAt: time.Unix(1700000000, 0)},
+				{Status: "deposited", TxHash: strPtr("0xdeposit"), Actor: database.ActorReconciler, CreatedAt: time.Unix(1700000060, 0)},
+			},
+		},
+	}
+}
+
+// fakeOracle quotes $3,000 and serves a fixed round at block 100
+type fakeOracle struct {
+	PriceOracle
+}
+
+func (fakeOracle) GetETHUSDPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(300000000000), nil
+}
+
+func (fakeOracle) PriceRoundAtBlock(ctx context.Context, blockNumber uint64) (*oracle.RoundData, error) {
+	return &oracle.RoundData{RoundID: big.NewInt(7), Answer: big.NewInt(300000000000), UpdatedAt: time.Unix(1700000000, 0)}, nil
+}
+
+func (fakeOracle) PriceRoundAtTime(ctx context.Context, at time.Time) (*oracle.RoundData, error) {
+	return nil, oracle.ErrBeforeCurrentPhase
+}
+
+// RecentPriceRounds serves $2,400 for the first half of the last hour and
+// $3,000 since
+func (fakeOracle) RecentPriceRounds(ctx context.Context, n int) ([]*oracle.RoundData, error) {
+	now := time.Now()
+	rounds := []*oracle.RoundData{
+		{RoundID: big.NewInt(5), Answer: big.NewInt(240000000000), UpdatedAt: now.Add(-time.Hour)},
+		{RoundID: big.NewInt(6), Answer: big.NewInt(300000000000), UpdatedAt: now.Add(-30 * time.Minute)},
+	}
+	if n < len(rounds) {
+		rounds = rounds[len(rounds)-n:]
+	}
+	return rounds, nil
+}
+
+func newTestGateway(t *testing.T, store *fakeStore, cfg *config.Config) *PaymentGateway {
+	t.Helper()
+	gateway, err := NewPaymentGateway(cfg,
+		WithChainClient(&fakeChain{}),
+		WithOracle(fakeOracle{}),
+		WithStore(store),
+		WithNotifier(fakeNotifier{}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	return gateway
+}
+
+func TestNewPaymentGatewayRequiresOracle(t *testing.T) {
+	chain := &fakeChain{}
+	_, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithStore(newTestStore()), WithNotifier(fakeNotifier{}))
+	if err == nil {
+		t.Fatal("Expected an error for a chain client without prices")
+	}
+	if !chain.closed {
+		t.Error("Expected the chain client to be closed after a failed construction")
+	}
+}
+
+func TestGetJobStatusHandler(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{})
+
+	rec := httptest.NewRecorder()
+	gateway.getJobStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/job-status?job_id=7", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response JobStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.PaymentStatus != "deposited" || response.USDAmount != "250" || response.TxHashDeposit != "0xdeposit" {
+		t.Errorf("Unexpected status response: %+v", response)
+	}
+	if response.USDAmountDisplay != "$250.00" {
+		t.Errorf("Expected usd_amount_display $250.00, got %q", response.USDAmountDisplay)
+	}
+	if len(response.Timeline) != 2 || response.Timeline[1].Actor != database.ActorReconciler {
+		t.Errorf("Expected the two recorded transitions in the timeline, got %+v", response.Timeline)
+	}
+}
+
+func TestGetJobStatusLongPoll(t *testing.T) {
+	store := newTestStore()
+	store.details[7].PaymentStatus = "deposited"
+	store.nextStatuses = map[int32][]paymentstatus.Status{7: {"release_initiated", "released"}}
+	gateway := newTestGateway(t, store, &config.Config{JobStatusMaxWait: time.Minute, JobStatusRecheckInterval: time.Second})
+
+	get := func(target string) (*httptest.ResponseRecorder, JobStatusResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		gateway.getJobStatusHandler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var response JobStatusResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec, response
+	}
+
+	// Held through release_initiated until released
+	rec, response := get("/job-status?job_id=7&wait_for=released&timeout=60s")
+	if rec.Code != http.StatusOK || response.PaymentStatus != "released" || response.WaitTimedOut {
+		t.Fatalf("Expected released, got %d: %+v", rec.Code, response)
+	}
+	if len(store.nextStatuses[7]) != 0 {
+		t.Errorf("Expected both transitions to be watched, %v left", store.nextStatuses[7])
+	}
+
+	// Already there: answered at once
+	start := time.Now()
+	if _, response := get("/job-status?job_id=7&wait_for=refunded,released"); response.PaymentStatus != "released" || time.Since(start) > time.Second {
+		t.Errorf("Expected an immediate answer, got %+v after %s", response, time.Since(start))
+	}
+
+	// Not reached: the current status once the timeout passes
+	rec, response = get("/job-status?job_id=7&wait_for=refunded&timeout=50ms")
+	if rec.Code != http.StatusOK || response.PaymentStatus != "released" || !response.WaitTimedOut {
+		t.Errorf("Expected a timed out wait, got %d: %+v", rec.Code, response)
+	}
+
+	for _, target := range []string{
+		"/job-status?job_id=7&wait_for=Released",
+		"/job-status?job_id=7&wait_for=released&timeout=soon",
+		"/job-status?job_id=7&timeout=60s",
+	} {
+		if rec, _ := get(target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestBatchedPayouts(t *testing.T) {
+	store := newTestStore()
+	amount := int32(300)
+	store.details[7].ApplicantUserID = 42
+	store.details[9] = &database.ApplicationPaymentDetails{ApplicationID: 9, ApplicantUserID: 42, AgreedUSDAmount: &amount, PaymentStatus: "deposited"}
+	gateway := newTestGateway(t, store, &config.Config{DeferDeadline: time.Hour})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/complete-job", gateway.completeJobHandler)
+	mux.HandleFunc("GET /freelancers/{user_id}/payouts", gateway.getPayoutsHandler)
+	mux.HandleFunc("PUT /freelancers/{user_id}/payouts", gateway.setPayoutPreferenceHandler)
+	mux.HandleFunc("DELETE /freelancers/{user_id}/payouts", gateway.deletePayoutPreferenceHandler)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	payouts := func() PayoutsResponse {
+		t.Helper()
+		var response PayoutsResponse
+		if err := json.NewDecoder(do(http.MethodGet, "/freelancers/42/payouts", "").Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode payouts: %v", err)
+		}
+		return response
+	}
+
+	for _, body := range []string{
+		`{"threshold_usd": 500, "weekday": "someday", "hour_utc": 17}`,
+		`{"threshold_usd": 0, "weekday": "friday", "hour_utc": 17}`,
+		`{"threshold_usd": 500, "weekday": "friday", "hour_utc": 24}`,
+	} {
+		if rec := do(http.MethodPut, "/freelancers/42/payouts", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+	if rec := do(http.MethodPut, "/freelancers/42/payouts", `{"threshold_usd": 500, "weekday": "Friday", "hour_utc": 17}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the preference to be set, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Below the threshold the release waits for Friday 17:00 UTC
+	rec := do(http.MethodPost, "/complete-job?job_id=7", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected the release to be held, got %d: %s", rec.Code, rec.Body)
+	}
+	held := payouts()
+	if held.ClaimableUSD != 250 || len(held.Held) != 1 || held.NextPayoutAt == nil {
+		t.Fatalf("Expected $250 held, got %+v", held)
+	}
+	if at := held.NextPayoutAt.UTC(); at.Weekday() != time.Friday || at.Hour() != 17 || !at.After(time.Now()) || at.After(time.Now().Add(7*24*time.Hour)) {
+		t.Errorf("Expected the next Friday 17:00 UTC, got %s", at)
+	}
+
+	// Reaching it makes everything held due now
+	if rec := do(http.MethodPost, "/complete-job?job_id=9", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected the release to be held, got %d: %s", rec.Code, rec.Body)
+	}
+	held = payouts()
+	if held.ClaimableUSD != 550 || held.NextPayoutAt.After(time.Now()) {
+		t.Errorf("Expected $550 due now, got %+v", held)
+	}
+	for _, h := range held.Held {
+		if h.PayoutAt.After(time.Now()) {
+			t.Errorf("Expected application %d to be due, it waits until %s", h.ApplicationID, h.PayoutAt)
+		}
+	}
+
+	if rec := do(http.MethodDelete, "/freelancers/42/payouts", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected the preference to be deleted, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/freelancers/42/payouts", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 once opted out, got %d", rec.Code)
+	}
+}
+
+func TestCompleteJobHandlerRequiresDeposit(t *testing.T) {
+	// fakeChain would panic if the handler tried to release on-chain
+	gateway := newTestGateway(t, newTestStore(), &config.Config{})
+
+	rec := httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=8", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "pending_deposit") {
+		t.Errorf("Expected the current status in the error, got %q", rec.Body)
+	}
+}
+
+func TestConfirmReleaseHandlerRecordsPlatformActor(t *testing.T) {
+	store := newTestStore()
+	gateway := newTestGateway(t, store, &config.Config{})
+
+	rec := httptest.NewRecorder()
+	gateway.confirmReleaseHandler(rec, httptest.NewRequest(http.MethodPost, "/confirm-release?job_id=7", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(store.changes) != 1 || store.changes[0].Status != "released" || store.changes[0].Actor != database.ActorPlatform {
+		t.Errorf("Expected one released change by the platform, got %+v", store.changes)
+	}
+}
+
+func TestConfirmCallsAreIdempotent(t *testing.T) {
+	store := newTestStore()
+	gateway := newTestGateway(t, store, &config.Config{})
+
+	confirm := func(handler http.HandlerFunc, path string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	// Job 8 has no deposit yet, so its release can't be confirmed
+	code, body := confirm(gateway.confirmReleaseHandler, "/confirm-release?job_id=8")
+	if code != http.StatusConflict {
+		t.Fatalf("Expected 409 for a release confirmed before its deposit, got %d: %s", code, body)
+	}
+	var conflict StatusConflictResponse
+	if err := json.Unmarshal([]byte(body), &conflict); err != nil {
+		t.Fatalf("Failed to decode conflict: %v", err)
+	}
+	if conflict.Code != "out_of_order" || conflict.PaymentStatus != "pending_deposit" || len(conflict.AllowedStatuses) == 0 {
+		t.Errorf("Unexpected conflict: %+v", conflict)
+	}
+
+	for i, expectChanged := range []bool{true, false} {
+		code, body := confirm(gateway.confirmDepositHandler, "/confirm-deposit?job_id=8")
+		var response ConfirmResponse
+		if err := json.Unmarshal([]byte(body), &response); err != nil || code != http.StatusOK {
+			t.Fatalf("Expected 200 for confirm %d, got %d: %s", i+1, code, body)
+		}
+		if response.PaymentStatus != "deposited" || response.Changed != expectChanged {
+			t.Errorf("Unexpected response to confirm %d: %+v", i+1, response)
+		}
+	}
+	if len(store.changes) != 1 {
+		t.Errorf("Expected the deposit recorded once, got %+v", store.changes)
+	}
+}
+
+func TestSignedConfirmRelease(t *testing.T) {
+	cfg := &config.Config{RequestSigningSecret: strings.Repeat("k", 32), ReplayWindow: time.Minute}
+	store := newTestStore()
+	gateway := newTestGateway(t, store, cfg)
+	handler := gateway.requireSignedRequest(gateway.confirmReleaseHandler)
+
+	send := func(nonce string, sign bool) int {
+		req := httptest.NewRequest(http.MethodPost, "/confirm-release?job_id=7", nil)
+		if sign {
+			ts := time.Now().Unix()
+			req.Header.Set(replay.TimestampHeader, strconv.FormatInt(ts, 10))
+			req.Header.Set(replay.NonceHeader, nonce)
+			req.Header.Set(replay.SignatureHeader, replay.Sign(cfg.RequestSigningSecret, ts, nonce, "POST /confirm-release?job_id=7", nil))
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if code := send("", false); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unsigned request, got %d", code)
+	}
+	if code := send("0123456789abcdef", true); code != http.StatusOK {
+		t.Errorf("Expected 200 for a signed request, got %d", code)
+	}
+	if code := send("0123456789abcdef", true); code != http.StatusConflict {
+		t.Errorf("Expected 409 for a replayed nonce, got %d", code)
+	}
+	if len(store.changes) != 1 {
+		t.Errorf("Expected one status change, got %d", len(store.changes))
+	}
+}
+
+func TestPublicJobStatusHandler(t *testing.T) {
+	cfg := &config.Config{StatusTokenSecret: strings.Repeat("s", 32), StatusTokenTTL: time.Hour}
+	store := newTestStore()
+	store.escrowTenants = map[int32]string{8: "acme"}
+	store.flagRules = []features.Rule{{Flag: features.PublicStatusLinks, Tenant: "acme", Enabled: false}}
+	gateway := newTestGateway(t, store, cfg)
+	signer, _ := statustoken.NewSigner(cfg.StatusTokenSecret)
+
+	tests := []struct {
+		name  string
+		token string
+		code  int
+	}{
+		{"valid", signer.Sign(7, time.Now().Add(time.Hour)), http.StatusOK},
+		{"expired", signer.Sign(7, time.Now().Add(-time.Minute)), http.StatusGone},
+		{"tampered", signer.Sign(7, time.Now().Add(time.Hour)) + "x", http.StatusUnauthorized},
+		{"unknown job", signer.Sign(99, time.Now().Add(time.Hour)), http.StatusNotFound},
+		{"disabled for the job's tenant", signer.Sign(8, time.Now().Add(time.Hour)), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The flag follows the job's tenant, not one the caller claims
+			req := httptest.NewRequest(http.MethodGet, "/public/job-status?token="+tt.token, nil)
+			req.Header.Set(TenantHeader, "acme")
+			rec := httptest.NewRecorder()
+			gateway.publicJobStatusHandler(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("Expected %d, got %d: %s", tt.code, rec.Code, rec.Body)
+			}
+			if tt.code == http.StatusOK && strings.Contains(rec.Body.String(), "0x00000000000000000000000000000000000000f1") {
+				t.Error("Expected wallet addresses to be left out of the public status")
+			}
+		})
+	}
+}
+
+func TestHistoricalEthPriceHandler(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{})
+
+	rec := httptest.NewRecorder()
+	gateway.getEthPriceHandler(rec, httptest.NewRequest(http.MethodGet, "/eth-price?at_block=100", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response HistoricalPriceResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.ETHUSDPrice != "300000000000" || response.RoundID != "7" {
+		t.Errorf("Expected round 7 at 300000000000, got round %s at %s", response.RoundID, response.ETHUSDPrice)
+	}
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"at_block=abc", http.StatusBadRequest},
+		{"at_block=1&at_time=2024-01-01T00:00:00Z", http.StatusBadRequest},
+		{"at_time=yesterday", http.StatusBadRequest},
+		{"at_time=2020-01-01T00:00:00Z", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		gateway.getEthPriceHandler(rec, httptest.NewRequest(http.MethodGet, "/eth-price?"+tt.query, nil))
+		if rec.Code != tt.expected {
+			t.Errorf("Expected status %d for %q, got %d", tt.expected, tt.query, rec.Code)
+		}
+	}
+}
+
+func TestQuoteHandler(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{FeePercentage: 5})
+
+	req := httptest.NewRequest(http.MethodGet, "/quote?usd_amount=1250", nil)
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+	rec := httptest.NewRecorder()
+	gateway.quoteHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response QuoteResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.RequiredWei != "4166666666" || response.PlatformFeeWei != "208333333" || response.FreelancerNetWei != "3958333333" {
+		t.Errorf("Unexpected quote amounts: %+v", response)
+	}
+	if response.USDAmountDisplay != "1.250,00 $" || response.ETHUSDPriceDisplay != "3.000,00 $" || response.Locale != "de-DE" {
+		t.Errorf("Expected de-DE display strings, got %+v", response)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.quoteHandler(rec, httptest.NewRequest(http.MethodGet, "/quote?usd_amount=-5", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative amount, got %d", rec.Code)
+	}
+}
+
+func TestQuoteBreakdown(t *testing.T) {
+	store := newTestStore()
+	store.gasAverages = []database.OperationGasAverage{{Operation: opPostJob, Transactions: 3, AvgGasUsed: 150000}}
+	gateway := newTestGateway(t, store, &config.Config{FeePercentage: 5, GasLimit: 300000, PriceDeviationPercent: 2, ReserveFeeBPS: 2000})
+
+	rec := httptest.NewRecorder()
+	gateway.quoteHandler(rec, httptest.NewRequest(http.MethodGet, "/quote?usd_amount=1250&breakdown=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response QuoteResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	breakdown := response.Breakdown
+	if breakdown == nil {
+		t.Fatal("Expected a breakdown")
+	}
+	if breakdown.PrincipalWei != response.RequiredWei || breakdown.PlatformFeeWei != "208333333" || breakdown.FreelancerNetWei != "3958333333" {
+		t.Errorf("Expected the breakdown to match the quote, got %+v", breakdown)
+	}
+	// 1250e18 / $2,940 less 1250e18 / $3,000
+	if breakdown.FundingBufferWei != "85034014" {
+		t.Errorf("Expected a buffer up to a 2%% drop, got %s", breakdown.FundingBufferWei)
+	}
+	// postJob at its recorded 150,000 gas, markJobCompleted at GAS_LIMIT, both at 10 gwei
+	if len(breakdown.Gas) != 2 || breakdown.Gas[0].EstimatedFrom != runwayFromHistory || breakdown.Gas[1].EstimatedFrom != runwayFromGasLimit {
+		t.Errorf("Unexpected gas estimates: %+v", breakdown.Gas)
+	}
+	if breakdown.GasWei != "4500000000000000" || breakdown.GasUSD != "13.500000" {
+		t.Errorf("Expected 0.0045 ETH of gas worth $13.50, got %s (%s)", breakdown.GasWei, breakdown.GasUSD)
+	}
+	if breakdown.TotalClientCostWei != "4500004251700680" {
+		t.Errorf("Expected principal, buffer and gas in the total, got %s", breakdown.TotalClientCostWei)
+	}
+	expected := map[string]string{ledger.AccountPlatformWallet: "208333333", ledger.AccountFeeRevenue: "-166666667", ledger.AccountReserveFund: "-41666666"}
+	if !maps.Equal(breakdown.LedgerEntries, expected) {
+		t.Errorf("Expected the fee accrual the ledger posts, got %v", breakdown.LedgerEntries)
+	}
+	if breakdown.Display["gas_usd"] != "$13.50" {
+		t.Errorf("Expected a display string for the gas cost, got %v", breakdown.Display)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.quoteHandler(rec, httptest.NewRequest(http.MethodGet, "/quote?usd_amount=1250", nil))
+	if strings.Contains(rec.Body.String(), "breakdown") {
+		t.Errorf("Expected no breakdown unless asked for, got %s", rec.Body)
+	}
+}
+
+func TestQuoteHandlerTWAP(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{FeePercentage: 5, TWAPMinUSD: 10000, TWAPRounds: 12})
+	quote := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		gateway.quoteHandler(rec, httptest.NewRequest(http.MethodGet, "/quote?"+query, nil))
+		return rec
+	}
+
+	rec := quote("usd_amount=27000&pricing=twap")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response QuoteResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	// Half an hour at $2,400 and half at $3,000
+	price, _ := new(big.Int).SetString(response.ETHUSDPrice, 10)
+	if response.Pricing != pricingTWAP || response.TWAPRounds != 2 || price.Cmp(big.NewInt(269900000000)) < 0 || price.Cmp(big.NewInt(270100000000)) > 0 {
+		t.Errorf("Expected a TWAP near $2,700 over 2 rounds, got %+v", response)
+	}
+	if response.SpotETHUSDPrice != "300000000000" || response.SpotRequiredWei != "90000000000" {
+		t.Errorf("Expected the spot requirement alongside the TWAP, got %+v", response)
+	}
+
+	// One round is just the spot price
+	if rec := quote("usd_amount=27000&pricing=twap&rounds=1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a single round, got %d", rec.Code)
+	}
+	if rec := quote("usd_amount=9999&pricing=twap"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 below TWAP_MIN_USD, got %d", rec.Code)
+	}
+	if rec := quote("usd_amount=27000&pricing=median"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown pricing mode, got %d", rec.Code)
+	}
+}
+
+func TestFaultInjectionWrapsStore(t *testing.T) {
+	cfg := &config.Config{NetworkID: 11155111, FaultInjection: true, FaultDBErrorRate: 1, FaultSeed: 1}
+	gateway := newTestGateway(t, newTestStore(), cfg)
+
+	rec := httptest.NewRecorder()
+	gateway.getJobStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/job-status?job_id=7", nil))
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "injected fault") {
+		t.Errorf("Expected an injected 500, got %d: %s", rec.Code, rec.Body)
+	}
+
+	cfg = &config.Config{NetworkID: 1, FaultInjection: true}
+	if _, err := NewPaymentGateway(cfg, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected fault injection to be refused on mainnet")
+	}
+}
+
+func TestProxyMonitor(t *testing.T) {
+	implementation := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	chain := &fakeChain{proxy: &payment.ProxyInfo{
+		Address:        common.HexToAddress("0x00000000000000000000000000000000000000e1"),
+		Implementation: implementation,
+	}}
+	store := newTestStore()
+	cfg := &config.Config{ExpectedImplementation: implementation.Hex()}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if strings.Contains(rec.Body.String(), "contract") {
+		t.Errorf("Expected no contract info before the first check, got %s", rec.Body)
+	}
+
+	var alerted common.Address
+	gateway.checkProxy(context.Background(), &alerted)
+
+	rec = httptest.NewRecorder()
+	gateway.healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if health.Contract == nil || !health.Contract.Proxy || health.Contract.Implementation != implementation.Hex() {
+		t.Fatalf("Expected the proxy and its implementation in /health, got %+v", health.Contract)
+	}
+	if alerted != (common.Address{}) {
+		t.Errorf("Expected no alert for the expected implementation, got %s", alerted.Hex())
+	}
+
+	// An upgrade to anything but the expected implementation is alerted on once
+	unexpected := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	chain.proxy.Implementation = unexpected
+	gateway.checkProxy(context.Background(), &alerted)
+	if alerted != unexpected {
+		t.Errorf("Expected an alert for %s, got %s", unexpected.Hex(), alerted.Hex())
+	}
+	if len(store.implementations) != 2 || store.implementations[1] != unexpected.Hex() {
+		t.Errorf("Expected both implementations to be recorded, got %v", store.implementations)
+	}
+}
+
+func TestFeatureFlagGatesQuotes(t *testing.T) {
+	store := newTestStore()
+	store.flagRules = []features.Rule{{Flag: features.Quotes, Tenant: "acme", Enabled: true}}
+	gateway := newTestGateway(t, store, &config.Config{NetworkID: 11155111, FeatureFlags: "quotes=off"})
+
+	quote := func(tenant string) int {
+		req := httptest.NewRequest(http.MethodGet, "/quote?usd_amount=100", nil)
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		rec := httptest.NewRecorder()
+		gateway.quoteHandler(rec, req)
+		return rec.Code
+	}
+
+	if code := quote(""); code != http.StatusForbidden {
+		t.Errorf("Expected 403 with quotes off by default, got %d", code)
+	}
+	if code := quote("acme"); code != http.StatusOK {
+		t.Errorf("Expected 200 for a tenant with quotes enabled, got %d", code)
+	}
+
+	if _, err := NewPaymentGateway(&config.Config{FeatureFlags: "invoicing=on"}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected an error for an unknown flag in FEATURE_FLAGS")
+	}
+}
+
+func TestFeatureFlagGatesJobHandlers(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{FeatureFlags: "disputes=off,top_ups=off,funding_links=off,release_batches=off,job_clones=off"})
+	mux := http.NewServeMux()
+	routes := map[string]http.HandlerFunc{
+		"POST /jobs/{id}/dispute":          gateway.openDisputeHandler,
+		"POST /jobs/{id}/dispute/evidence": gateway.addDisputeEvidenceHandler,
+		"POST /jobs/{id}/dispute/resolve":  gateway.resolveDisputeHandler,
+		"POST /jobs/{id}/top-up":           gateway.topUpJobHandler,
+		"POST /jobs/{id}/funding-link":     gateway.createFundingLinkHandler,
+		"POST /jobs/{id}/clone":            gateway.cloneJobHandler,
+		"POST /release-batch":              gateway.releaseBatchHandler,
+	}
+	for pattern, handler := range routes {
+		mux.HandleFunc(pattern, handler)
+	}
+
+	for pattern := range routes {
+		target := strings.Replace(strings.TrimPrefix(pattern, "POST "), "{id}", "7", 1)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{}`)))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 with its feature off, got %d", pattern, rec.Code)
+		}
+	}
+}
+
+func TestPostJobReturnsExistingDeposit(t *testing.T) {
+	chain := &fakeChain{
+		jobs: map[uint64]*payment.JobDetails{7: {
+			Client:     common.HexToAddress("0x00000000000000000000000000000000000000c1"),
+			Freelancer: common.HexToAddress("0x00000000000000000000000000000000000000f1"),
+			USDAmount:  big.NewInt(250),
+			ETHAmount:  big.NewInt(83333333),
+		}},
+		deposits: map[uint64]*payment.Deposit{7: {TxHash: "0xdeposit", Value: big.NewInt(83333333)}},
+	}
+	store := newTestStore()
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// fakeChain would panic if either post reached PostJob
+	post := func(usdAmount string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"job_id":7,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":%q,"client_address":"0x00000000000000000000000000000000000000c1"}`, usdAmount)
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("250")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a retried post, got %d: %s", rec.Code, rec.Body)
+	}
+	var response TransactionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.TxHash != "0xdeposit" || !response.Success {
+		t.Errorf("Expected the original deposit hash, got %+v", response)
+	}
+	if len(store.changes) != 0 {
+		t.Errorf("Expected the recorded deposit to be left alone, got %+v", store.changes)
+	}
+
+	if rec := post("300"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a post with different terms, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestJobStatusExplorerLinks(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{NetworkID: 8453, ExplorerURLs: "8453=https://explorer.example/"})
+
+	rec := httptest.NewRecorder()
+	gateway.getJobStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/job-status?job_id=7", nil))
+
+	var response JobStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.TxURLDeposit != "https://explorer.example/tx/0xdeposit" {
+		t.Errorf("Expected the configured explorer's deposit link, got %q", response.TxURLDeposit)
+	}
+	if response.TxURLRelease != "" || response.Timeline[0].TxURL != response.TxURLDeposit {
+		t.Errorf("Expected links only for recorded hashes, got %+v", response)
+	}
+
+	if _, err := NewPaymentGateway(&config.Config{ExplorerURLs: "base=https://basescan.org"}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected an error for a malformed EXPLORER_URLS")
+	}
+}
+
+func TestWalletHandler(t *testing.T) {
+	// 0.05 ETH at 10 gwei
+	chain := &fakeChain{balance: big.NewInt(50_000_000_000_000_000)}
+	store := newTestStore()
+	cfg := &config.Config{GasLimit: 300000, WalletLowRunway: 20}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	wallet := func() WalletResponse {
+		rec := httptest.NewRecorder()
+		gateway.getWalletHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/wallet", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var response WalletResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	// Without history every operation is assumed to use GAS_LIMIT: 0.003 ETH each
+	response := wallet()
+	if response.EstimatedFrom != "gas_limit" || response.RemainingOperations != 16 || !response.LowBalance {
+		t.Errorf("Unexpected projection without history: %+v", response)
+	}
+
+	// 3 posts at 150k and 1 release at 50k average 125k gas: 0.00125 ETH each
+	store.gasAverages = []database.OperationGasAverage{
+		{Operation: opCompleteJob, Transactions: 1, AvgGasUsed: 50000},
+		{Operation: opPostJob, Transactions: 3, AvgGasUsed: 150000},
+	}
+	response = wallet()
+	if response.AvgGasUsed != 125000 || response.RemainingOperations != 40 || response.LowBalance {
+		t.Errorf("Unexpected projection from history: %+v", response)
+	}
+	if len(response.Operations) != 2 || response.Operations[0].RemainingOperations != 100 || response.Operations[1].CostWei != "1500000000000000" {
+		t.Errorf("Unexpected per-operation runway: %+v", response.Operations)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.getWalletHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/wallet?days=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for days=0, got %d", rec.Code)
+	}
+}
+
+func TestChangesHandler(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{})
+
+	page := func(cursor string) (int, ChangesResponse) {
+		rec := httptest.NewRecorder()
+		gateway.getChangesHandler(rec, httptest.NewRequest(http.MethodGet, "/changes?limit=1&since_cursor="+cursor, nil))
+		var response ChangesResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec.Code, response
+	}
+
+	_, first := page("")
+	if len(first.Changes) != 1 || first.Changes[0].Status != "deposit_initiated" || !first.HasMore {
+		t.Fatalf("Unexpected first page: %+v", first)
+	}
+	_, second := page(first.NextCursor)
+	if len(second.Changes) != 1 || second.Changes[0].Status != "deposited" {
+		t.Fatalf("Unexpected second page: %+v", second)
+	}
+	_, last := page(second.NextCursor)
+	if len(last.Changes) != 0 || last.HasMore || last.NextCursor != second.NextCursor {
+		t.Errorf("Expected an empty page that keeps the cursor, got %+v", last)
+	}
+
+	if code, _ := page("not-a-cursor"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a cursor the gateway did not issue, got %d", code)
+	}
+
+	// Filters narrow the feed without changing its order
+	rec := httptest.NewRecorder()
+	gateway.getChangesHandler(rec, httptest.NewRequest(http.MethodGet, "/changes?status=deposited", nil))
+	var deposited ChangesResponse
+	json.NewDecoder(rec.Body).Decode(&deposited)
+	if len(deposited.Changes) != 1 || deposited.Changes[0].Status != "deposited" || deposited.HasMore {
+		t.Errorf("Expected only the deposit, got %+v", deposited)
+	}
+	for _, query := range []string{"sort=-id", "status[gt]=deposited"} {
+		rec := httptest.NewRecorder()
+		gateway.getChangesHandler(rec, httptest.NewRequest(http.MethodGet, "/changes?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestTopUpJobHandler(t *testing.T) {
+	chain := &fakeChain{}
+	store := newTestStore()
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	topUp := func(jobID string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/jobs/"+jobID+"/top-up", strings.NewReader(body))
+		req.SetPathValue("id", jobID)
+		rec := httptest.NewRecorder()
+		gateway.topUpJobHandler(rec, req)
+		return rec
+	}
+
+	rec := topUp("7", `{"usd_amount":50,"reason":"extra pages"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var response TopUpsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	escrowJobID := payment.TopUpJobID(1)
+	if len(chain.posted) != 1 || chain.posted[0] != escrowJobID {
+		t.Fatalf("Expected the top-up posted as escrow job %d, got %v", escrowJobID, chain.posted)
+	}
+	if response.EscrowedUSD != 300 || response.TopUpUSD != 50 || len(response.TopUps) != 1 {
+		t.Errorf("Unexpected top-up totals: %+v", response)
+	}
+	if !slices.Equal(response.DepositTxHashes, []string{"0xdeposit", fmt.Sprintf("0xpost%d", escrowJobID)}) {
+		t.Errorf("Expected both deposit hashes, got %v", response.DepositTxHashes)
+	}
+
+	for jobID, body := range map[string]string{"8": `{"usd_amount":50}`, "7": `{"usd_amount":0}`} {
+		if rec := topUp(jobID, body); rec.Code == http.StatusCreated {
+			t.Errorf("Expected job %s with %s to be rejected", jobID, body)
+		}
+	}
+
+	// Releasing the job pays out the top-up with it
+	if _, err := gateway.sendOperation(context.Background(), 7, opCompleteJob, database.OperationParams{JobID: 7}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(chain.completed, []uint64{7, escrowJobID}) {
+		t.Errorf("Expected the job and its top-up released, got %v", chain.completed)
+	}
+	if store.topUps[0].Status != database.TopUpStatusReleased {
+		t.Errorf("Expected the top-up released, got %q", store.topUps[0].Status)
+	}
+}
+
+func TestPostJobRegistersWebhook(t *testing.T) {
+	chain := &fakeChain{
+		jobs: map[uint64]*payment.JobDetails{7: {
+			Client:     common.HexToAddress("0x00000000000000000000000000000000000000c1"),
+			Freelancer: common.HexToAddress("0x00000000000000000000000000000000000000f1"),
+			USDAmount:  big.NewInt(250),
+		}},
+		deposits: map[uint64]*payment.Deposit{7: {TxHash: "0xdeposit", Value: big.NewInt(83333333)}},
+	}
+	store := newTestStore()
+	notifier := recordingNotifier{deliveries: make(chan string, 2)}
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(notifier))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	post := func(webhookURL string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"job_id":7,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":"250","client_address":"0x00000000000000000000000000000000000000c1","webhook_url":%q,"tags":["enterprise-client","design"]}`, webhookURL)
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := post("disputes.example/hooks"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a relative webhook URL, got %d", rec.Code)
+	}
+	if rec := post("https://disputes.example/hooks"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if store.webhooks[7] != "https://disputes.example/hooks" {
+		t.Fatalf("Expected the webhook registered, got %q", store.webhooks[7])
+	}
+	if !slices.Equal(store.jobTags[7], []string{"design", "enterprise-client"}) {
+		t.Errorf("Expected the job tagged at creation, got %v", store.jobTags[7])
+	}
+
+	gateway.notifyJob(7, "", events.TransactionConfirmed, events.Transaction{ApplicationID: 7})
+	got := []string{<-notifier.deliveries, <-notifier.deliveries}
+	want := []string{"global transaction.confirmed", "https://disputes.example/hooks transaction.confirmed"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected delivery to both webhooks, got %v", got)
+	}
+}
+
+// feedOracle serves configurable primary and fallback rounds
+type feedOracle struct {
+	fakeOracle
+	primary, fallback *oracle.RoundData
+}
+
+func (o *feedOracle) LatestPriceRound(ctx context.Context) (*oracle.RoundData, error) {
+	return o.primary, nil
+}
+
+func (o *feedOracle) FallbackPriceRound(ctx context.Context) (*oracle.RoundData, error) {
+	return o.fallback, nil
+}
+
+func TestPriceFeedMonitorFailsOver(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	feed := &feedOracle{
+		primary:  &oracle.RoundData{RoundID: big.NewInt(1), Answer: big.NewInt(300000000000), UpdatedAt: now.Add(-3 * time.Hour)},
+		fallback: &oracle.RoundData{RoundID: big.NewInt(9), Answer: big.NewInt(310000000000), UpdatedAt: now.Add(-time.Minute)},
+	}
+	notifier := recordingNotifier{deliveries: make(chan string, 2)}
+	cfg := &config.Config{OracleHeartbeat: time.Hour, OracleDeviationPercent: 0.5, OracleHeartbeatGrace: 5 * time.Minute, OracleFallbackFeed: "0x00000000000000000000000000000000000000fb"}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(&fakeChain{}), WithOracle(feed), WithStore(newTestStore()), WithNotifier(notifier))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	gateway.checkPriceFeed(context.Background(), now)
+	if got := <-notifier.deliveries; got != "global price_feed.stalled" {
+		t.Errorf("Expected a stalled alert, got %q", got)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if health.PriceFeed == nil || health.PriceFeed.Source != "fallback" || health.PriceFeed.StalenessSeconds != 3*3600 {
+		t.Fatalf("Expected a stalled feed read from the fallback in /health, got %+v", health.PriceFeed)
+	}
+	if price, _ := gateway.marketPrice(context.Background()); price.Cmp(feed.fallback.Answer) != 0 {
+		t.Errorf("Expected the fallback's market price, got %s", price)
+	}
+
+	// A repeat check doesn't alert again; a fresh round recovers
+	gateway.checkPriceFeed(context.Background(), now)
+	feed.primary = &oracle.RoundData{RoundID: big.NewInt(2), Answer: big.NewInt(310000000000), UpdatedAt: now}
+	gateway.checkPriceFeed(context.Background(), now)
+	if got := <-notifier.deliveries; got != "global price_feed.recovered" {
+		t.Errorf("Expected a recovery notice, got %q", got)
+	}
+	if price, _ := gateway.marketPrice(context.Background()); price.Cmp(big.NewInt(300000000000)) != 0 {
+		t.Errorf("Expected the contract's price after recovery, got %s", price)
+	}
+}
+
+func TestVelocityLimits(t *testing.T) {
+	body := `{"job_id":7,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":"250","client_address":"0x00000000000000000000000000000000000000c1"}`
+	post := func(limits string) (*fakeStore, *httptest.ResponseRecorder) {
+		store := newTestStore()
+		store.clientEscrowUSD = 900
+		chain := &fakeChain{jobs: map[uint64]*payment.JobDetails{}, deposits: map[uint64]*payment.Deposit{}}
+		gateway, err := NewPaymentGateway(&config.Config{VelocityLimits: limits}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+		if err != nil {
+			t.Fatalf("Failed to create gateway: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+		return store, rec
+	}
+
+	// $900 already escrowed today plus $250 exceeds a $1,000 limit
+	store, rec := post("client_daily_usd=1000:block")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	var violation VelocityViolationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&violation); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if violation.Code != "velocity_limit" || len(violation.Violations) != 1 || violation.Violations[0].Rule != velocity.ClientDailyUSD {
+		t.Errorf("Unexpected violation %+v", violation)
+	}
+	if len(store.reviews) != 0 {
+		t.Errorf("Expected a blocked operation not to be queued for review, got %d", len(store.reviews))
+	}
+
+	// A flagging rule holds the escrow for review; a new wallet rule under
+	// its limit does nothing
+	store, rec = post("client_daily_usd=1000:flag,new_wallet_usd=500:block")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(store.reviews) != 1 || store.reviews[0].Rule != string(velocity.ClientDailyUSD) || store.reviews[0].Operation != opPostJob {
+		t.Fatalf("Expected one client_daily_usd review, got %+v", store.reviews)
+	}
+
+	if _, err := NewPaymentGateway(&config.Config{VelocityLimits: "client_daily_usd=lots"}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected invalid VELOCITY_LIMITS to be rejected")
+	}
+}
+
+func TestReviewQueue(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{jobs: map[uint64]*payment.JobDetails{}, deposits: map[uint64]*payment.Deposit{}}
+	gateway, err := NewPaymentGateway(&config.Config{VelocityLimits: "client_daily_usd=100"}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	store.details[8].ApplicantWalletAddress = strPtr("0x00000000000000000000000000000000000000f1")
+	store.details[8].PosterWalletAddress = strPtr("0x00000000000000000000000000000000000000c1")
+
+	post := func() *httptest.ResponseRecorder {
+		body := `{"job_id":8,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":"250","client_address":"0x00000000000000000000000000000000000000c1"}`
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+		return rec
+	}
+	resolve := func(action string, id int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/reviews/%d/%s", id, action), strings.NewReader(`{"reason":"checked"}`))
+		req.SetPathValue("id", strconv.Itoa(id))
+		req.Header.Set("X-Actor", "ops@example.com")
+		rec := httptest.NewRecorder()
+		if action == "approve" {
+			gateway.approveReviewHandler(rec, req)
+		} else {
+			gateway.rejectReviewHandler(rec, req)
+		}
+		return rec
+	}
+
+	// A $250 escrow over the $100 limit is held, not posted
+	if rec := post(); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	if store.details[8].PaymentStatus != paymentstatus.PendingReview || len(chain.posted) != 0 {
+		t.Fatalf("Expected the job held in pending_review, got %q with posts %v", store.details[8].PaymentStatus, chain.posted)
+	}
+	if rec := post(); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a held job to refuse another post, got %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.listReviewsHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/reviews", nil))
+	var open []ReviewResponse
+	if err := json.NewDecoder(rec.Body).Decode(&open); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(open) != 1 || open[0].ApplicationID != 8 || open[0].PreviousStatus != "pending_deposit" {
+		t.Fatalf("Expected the held job in the queue, got %+v", open)
+	}
+
+	// Rejecting restores the job without posting it
+	if rec := resolve("reject", 1); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if store.details[8].PaymentStatus != "pending_deposit" || len(chain.posted) != 0 {
+		t.Errorf("Expected the rejected job back in pending_deposit and unposted, got %q with posts %v", store.details[8].PaymentStatus, chain.posted)
+	}
+	if rec := resolve("approve", 1); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a resolved review to refuse another decision, got %d", rec.Code)
+	}
+
+	// Approving a second hold posts the escrow
+	post()
+	rec = resolve("approve", 2)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var decision ReviewDecisionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&decision); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if decision.Review.Status != database.ReviewStatusApproved || decision.Review.ResolvedBy != "ops@example.com" || decision.Transaction == nil {
+		t.Errorf("Unexpected decision %+v", decision)
+	}
+	if len(chain.posted) != 1 || chain.posted[0] != 8 || store.details[8].PaymentStatus != "deposit_initiated" {
+		t.Errorf("Expected job 8 posted, got %v with status %q", chain.posted, store.details[8].PaymentStatus)
+	}
+
+	if rec := resolve("approve", 9); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown review, got %d", rec.Code)
+	}
+}
+
+// staticPrice is an independent price source answering a fixed rate
+type staticPrice int64
+
+func (p staticPrice) Name() string { return "static" }
+
+func (p staticPrice) Price(ctx context.Context) (*big.Int, error) { return big.NewInt(int64(p)), nil }
+
+func TestOracleConsensus(t *testing.T) {
+	cfg := &config.Config{OracleConsensusUSD: 1000, OracleConsensusTolerancePercent: 1, OracleConsensusMinSources: 2}
+	post := func(usd string, source staticPrice) (*fakeStore, *fakeChain, *httptest.ResponseRecorder) {
+		store := newTestStore()
+		chain := &fakeChain{jobs: map[uint64]*payment.JobDetails{}, deposits: map[uint64]*payment.Deposit{}}
+		gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}), WithPriceSources(source))
+		if err != nil {
+			t.Fatalf("Failed to create gateway: %v", err)
+		}
+		body := fmt.Sprintf(`{"job_id":7,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":%q,"client_address":"0x00000000000000000000000000000000000000c1"}`, usd)
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+		return store, chain, rec
+	}
+
+	// A source within 1% of the contract's $3,000 lets a large escrow through
+	if _, chain, rec := post("5000", staticPrice(302000000000)); rec.Code != http.StatusOK || len(chain.posted) != 1 {
+		t.Fatalf("Expected an agreed escrow posted, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// A diverging source holds it for review
+	store, chain, rec := post("5000", staticPrice(320000000000))
+	if rec.Code != http.StatusAccepted || len(chain.posted) != 0 {
+		t.Fatalf("Expected 202 without a post, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(store.reviews) != 1 || store.reviews[0].Rule != string(ruleOracleConsensus) || !strings.Contains(store.reviews[0].Detail, "static $3200.00 (6.67% off)") {
+		t.Fatalf("Expected one oracle_consensus review, got %+v", store.reviews)
+	}
+
+	// Escrows under the threshold aren't checked
+	if _, chain, rec := post("500", staticPrice(320000000000)); rec.Code != http.StatusOK || len(chain.posted) != 1 {
+		t.Errorf("Expected a small escrow posted, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Consensus can't be required of more sources than are configured
+	if _, err := NewPaymentGateway(&config.Config{OracleConsensusUSD: 1000, OracleConsensusTolerancePercent: 1, OracleConsensusMinSources: 2}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected ORACLE_CONSENSUS_USD without a second price source to be rejected")
+	}
+}
+
+func TestContractUpdate(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	original := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	redeployed := common.HexToAddress("0x00000000000000000000000000000000000000e2")
+	cfg := &config.Config{
+		NetworkID:            11155111,
+		ContractUpdateSigner: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		ContractUpdateMaxAge: 15 * time.Minute,
+	}
+	store := newTestStore()
+	chain := &fakeChain{contractAddress: original, proxy: &payment.ProxyInfo{Address: redeployed}}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	body := func(address common.Address, abiJSON string, issuedAt time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"network_id":11155111,"contract_address":%q,"abi":%s,"issued_at":%q,"reason":"patch"}`,
+			address.Hex(), abiJSON, issuedAt.Format(time.RFC3339)))
+	}
+	post := func(body []byte, signed bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/contract", bytes.NewReader(body))
+		if signed {
+			signature, err := contractupdate.Sign(body, key)
+			if err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+			req.Header.Set(contractupdate.SignatureHeader, signature)
+		}
+		req.Header.Set("X-Actor", "ops@example.com")
+		rec := httptest.NewRecorder()
+		gateway.updateContractHandler(rec, req)
+		return rec
+	}
+
+	update := body(redeployed, contracts.EthJobEscrowMetaData.ABI, time.Now().Add(-time.Minute))
+	if rec := post(update, false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unsigned update, got %d", rec.Code)
+	}
+	if rec := post(body(redeployed, `[]`, time.Now()), true); rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "postJob") {
+		t.Errorf("Expected 422 for an incompatible ABI, got %d: %s", rec.Code, rec.Body)
+	}
+	if chain.contractAddress != original {
+		t.Fatalf("Expected rejected updates to leave the contract alone, got %s", chain.contractAddress.Hex())
+	}
+
+	rec := post(update, true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if chain.contractAddress != redeployed || len(store.contractUpdates) != 1 || store.contractUpdates[0].PreviousAddress != original.Hex() {
+		t.Errorf("Expected the gateway on %s with the update recorded, got %s and %+v", redeployed.Hex(), chain.contractAddress.Hex(), store.contractUpdates)
+	}
+	if gateway.contract.Load() == nil || gateway.contract.Load().Address != redeployed.Hex() {
+		t.Errorf("Expected the contract info refreshed, got %+v", gateway.contract.Load())
+	}
+
+	// An update issued before the one in effect can't move the gateway back
+	rollback := body(original, contracts.EthJobEscrowMetaData.ABI, time.Now().Add(-2*time.Minute))
+	if rec := post(rollback, true); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a superseded update, got %d", rec.Code)
+	}
+
+	// A restarted gateway configured with the original address reapplies the
+	// update; once CONTRACT_ADDRESS is rolled forward it is left alone
+	restarted := &fakeChain{contractAddress: original}
+	gateway, err = NewPaymentGateway(cfg, WithChainClient(restarted), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	if err := gateway.applyStoredContractUpdate(context.Background()); err != nil {
+		t.Fatalf("Failed to reapply update: %v", err)
+	}
+	if restarted.contractAddress != redeployed {
+		t.Errorf("Expected the update reapplied, got %s", restarted.contractAddress.Hex())
+	}
+
+	rolledForward := &fakeChain{contractAddress: common.HexToAddress("0x00000000000000000000000000000000000000e3")}
+	gateway, err = NewPaymentGateway(cfg, WithChainClient(rolledForward), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	if err := gateway.applyStoredContractUpdate(context.Background()); err != nil || rolledForward.contractAddress.Hex() != "0x00000000000000000000000000000000000000E3" {
+		t.Errorf("Expected the configured address kept, got %s (%v)", rolledForward.contractAddress.Hex(), err)
+	}
+
+	disabled := newTestGateway(t, newTestStore(), &config.Config{})
+	rec = httptest.NewRecorder()
+	disabled.updateContractHandler(rec, httptest.NewRequest(http.MethodPost, "/admin/contract", bytes.NewReader(update)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without CONTRACT_UPDATE_SIGNER, got %d", rec.Code)
+	}
+}
+
+func TestReleaseBatch(t *testing.T) {
+	store := newTestStore()
+	small, large := int32(300), int32(8000)
+	for id, amount := range map[int32]*int32{20: &small, 21: &large, 22: &small, 23: &small} {
+		store.details[id] = &database.ApplicationPaymentDetails{ApplicationID: id, ApplicantUserID: 5, AgreedUSDAmount: amount, PaymentStatus: "deposited", ApplicationStatus: "approved"}
+	}
+	store.details[22].PaymentStatus = "pending_deposit"
+	store.details[23].ApplicationStatus = "hired"
+	store.details[24] = &database.ApplicationPaymentDetails{ApplicationID: 24, ApplicantUserID: 6, AgreedUSDAmount: &small, PaymentStatus: "deposited", ApplicationStatus: "approved"}
+	store.details[25] = &database.ApplicationPaymentDetails{ApplicationID: 25, ApplicantUserID: 5, AgreedUSDAmount: &small, PaymentStatus: "deposited", ApplicationStatus: "approved"}
+
+	chain := &fakeChain{
+		adminAddress: common.HexToAddress("0x00000000000000000000000000000000000000ad"),
+		releaseErrs:  map[uint64]error{25: errors.New("execution reverted: JobNotCompleted")},
+	}
+	cfg := &config.Config{ReleaseApprovedStatus: "approved", ReleaseBatchLimit: 10, PrivilegedUSDThreshold: 5000}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	release := func() (*httptest.ResponseRecorder, ReleaseBatchResponse) {
+		rec := httptest.NewRecorder()
+		gateway.releaseBatchHandler(rec, httptest.NewRequest(http.MethodPost, "/release-batch", strings.NewReader(`{"freelancer_user_id":5}`)))
+		var response ReleaseBatchResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec, response
+	}
+
+	rec, response := release()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	// Hot key releases first, then the admin-signed $8,000 job; a revert only fails its own job
+	var order []int32
+	for _, result := range response.Results {
+		order = append(order, result.ApplicationID)
+	}
+	if !slices.Equal(order, []int32{20, 25, 21}) || response.Released != 2 {
+		t.Fatalf("Expected jobs 20, 25, 21 with 2 released, got %v with %d", order, response.Released)
+	}
+	if response.Results[1].Status != batchFailed || response.Results[1].Code != payment.CodeJobNotCompleted || response.Results[2].Status != batchReleased || response.Results[2].Transaction.TxHash != "0xrelease21" {
+		t.Errorf("Unexpected results %+v", response.Results)
+	}
+	if !slices.Equal(chain.completed, []uint64{20, 21}) {
+		t.Errorf("Expected jobs 20 and 21 released on chain, got %v", chain.completed)
+	}
+
+	// A gas spike stops the batch rather than failing every remaining job
+	store.details[20].PaymentStatus, store.details[21].PaymentStatus = "deposited", "deposited"
+	chain.releaseErrs = map[uint64]error{20: &payment.GasPriceTooHighError{GasPrice: big.NewInt(90e9), Ceiling: big.NewInt(50e9)}}
+	chain.completed = nil
+	_, response = release()
+	if response.Results[0].Status != batchFailed || response.Results[1].Status != batchSkipped || response.Results[2].Status != batchSkipped || len(chain.completed) != 0 {
+		t.Errorf("Expected the batch stopped after the gas failure, got %+v", response.Results)
+	}
+
+	cfg.ReleaseBatchLimit = 2
+	if rec, _ := release(); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 over RELEASE_BATCH_LIMIT, got %d", rec.Code)
+	}
+}
+
+func TestEconomicalRelease(t *testing.T) {
+	store := newTestStore()
+	amount := int32(300)
+	store.details[20] = &database.ApplicationPaymentDetails{ApplicationID: 20, ApplicantUserID: 5, AgreedUSDAmount: &amount, PaymentStatus: "deposited", ApplicationStatus: "approved"}
+
+	// Base fees are 30 gwei all day except three hours from now
+	now := time.Now().UTC()
+	cheap := now.Truncate(time.Hour).Add(3 * time.Hour)
+	for hour := 0; hour < 24; hour++ {
+		store.baseFeeProfile = append(store.baseFeeProfile, gaswindow.Hour{Hour: hour, AverageGwei: 30, Samples: 12})
+	}
+	store.baseFeeProfile[cheap.Hour()].AverageGwei = 6
+
+	chain := &fakeChain{block: 500}
+	cfg := &config.Config{
+		ReleaseApprovedStatus: "approved",
+		BaseFeeSampleInterval: 5 * time.Minute,
+		BaseFeeHistory:        7 * 24 * time.Hour,
+		EconomicalMaxDelay:    12 * time.Hour,
+		DeferDeadline:         6 * time.Hour,
+	}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	gateway.sampleBaseFee(context.Background())
+	if !slices.Equal(store.baseFees, []uint64{500}) {
+		t.Errorf("Expected the latest block's base fee recorded, got %v", store.baseFees)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7&mode=fast", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown mode, got %d", rec.Code)
+	}
+
+	// The release waits for the cheap hour instead of going on chain
+	rec = httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7&mode=economical", nil))
+	var op DeferredOperationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&op); err != nil || rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202 with the scheduled operation, got %d (%v)", rec.Code, err)
+	}
+	if op.NextAttemptAt == nil || !op.NextAttemptAt.Equal(cheap) || !op.Deadline.Equal(cheap.Add(6*time.Hour)) || len(chain.completed) != 0 {
+		t.Fatalf("Expected the release scheduled for %s, got %+v", cheap, op)
+	}
+	if !strings.Contains(op.Error, "6.00 gwei against 30.00 gwei") {
+		t.Errorf("Expected the expected saving explained, got %q", op.Error)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.gasWindowsHandler(rec, httptest.NewRequest(http.MethodGet, "/gas-windows", nil))
+	var windows GasWindowsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&windows); err != nil || len(windows.Hours) != 24 || windows.NextWindow == nil || windows.NextWindow.SavingPercent != 80 || *windows.BaseFeeGwei != 30 {
+		t.Errorf("Expected the cheap hour reported, got %+v (%v)", windows, err)
+	}
+
+	// A batch is scheduled the same way; past ECONOMICAL_MAX_DELAY jobs go now
+	batch := func() ReleaseBatchResponse {
+		rec := httptest.NewRecorder()
+		gateway.releaseBatchHandler(rec, httptest.NewRequest(http.MethodPost, "/release-batch", strings.NewReader(`{"freelancer_user_id":5,"mode":"economical"}`)))
+		var response ReleaseBatchResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+	if response := batch(); len(response.Results) != 1 || response.Results[0].Status != batchScheduled || response.Results[0].OperationID == 0 {
+		t.Errorf("Expected job 20 scheduled, got %+v", response.Results)
+	}
+	store.deferred = nil
+	cfg.EconomicalMaxDelay = time.Hour
+	if response := batch(); response.Results[0].Status != batchReleased || !slices.Equal(chain.completed, []uint64{20}) {
+		t.Errorf("Expected job 20 released now with no cheaper hour in reach, got %+v", response.Results)
+	}
+
+	cfg.BaseFeeSampleInterval = 0
+	rec = httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7&mode=economical", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 with base fee sampling disabled, got %d", rec.Code)
+	}
+}
+
+func TestPlatformEvents(t *testing.T) {
+	store := newTestStore()
+	store.details[7].ApplicationStatus = "approved"
+	store.details[8].ApplicationStatus = "approved"
+	amount := int32(400)
+	store.details[30] = &database.ApplicationPaymentDetails{ApplicationID: 30, AgreedUSDAmount: &amount, PaymentStatus: "deposited", ApplicationStatus: "approved"}
+	store.details[31] = &database.ApplicationPaymentDetails{ApplicationID: 31, AgreedUSDAmount: &amount, PaymentStatus: "deposited", ApplicationStatus: "hired"}
+
+	chain := &fakeChain{}
+	cfg := &config.Config{ReleaseApprovedStatus: "approved", PlatformEventsChannel: "payment_gateway"}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// Approvals missed while not listening: only deposited approved jobs
+	gateway.releaseMissedApprovals(context.Background())
+	if !slices.Equal(chain.completed, []uint64{7, 30}) {
+		t.Fatalf("Expected jobs 7 and 30 released, got %v", chain.completed)
+	}
+
+	chain.completed = nil
+	for _, payload := range []string{
+		`{"event":"work_approved","application_id":31}`, // the event itself is the approval
+		`{"event":"work_approved","application_id":7}`,  // already releasing
+		`{"event":"work_approved","application_id":8}`,  // never funded
+		`{"event":"offer_accepted","application_id":31}`,
+		`not json`,
+	} {
+		gateway.handlePlatformEvent(context.Background(), payload)
+	}
+	if !slices.Equal(chain.completed, []uint64{31}) {
+		t.Errorf("Expected only job 31 released, got %v", chain.completed)
+	}
+}
+
+func TestAutomationRules(t *testing.T) {
+	store := newTestStore()
+	amount := int32(400)
+	for _, id := range []int32{40, 41, 42, 43} {
+		store.details[id] = &database.ApplicationPaymentDetails{ApplicationID: id, AgreedUSDAmount: &amount, PaymentStatus: "deposited", EscrowTxHashDeposit: strPtr("0xdeposit"), ApplicationStatus: "hired"}
+	}
+	store.details[44] = &database.ApplicationPaymentDetails{ApplicationID: 44, AgreedUSDAmount: &amount, PaymentStatus: "released", EscrowTxHashDeposit: strPtr("0xdeposit"), ApplicationStatus: "approved"}
+	store.escrowTenants = map[int32]string{42: "globex"}
+	store.statusSeen = map[int32]string{7: "hired", 40: "hired", 41: "hired", 42: "hired", 43: "hired"}
+
+	chain := &fakeChain{}
+	cfg := &config.Config{
+		AutomationRules:        "approved=release,cancelled=refund:client_cancelled,hired>disputed=notify,globex:approved=none",
+		AutomationPollInterval: time.Minute,
+	}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// Nothing has moved since the escrows were posted
+	gateway.automateTransitions(context.Background())
+	if len(chain.completed) != 0 || len(chain.cancelled) != 0 {
+		t.Fatalf("Expected no action before a transition, got releases %v and refunds %v", chain.completed, chain.cancelled)
+	}
+
+	store.details[40].ApplicationStatus = "approved"
+	store.details[41].ApplicationStatus = "cancelled"
+	store.details[42].ApplicationStatus = "approved" // exempted tenant
+	store.details[43].ApplicationStatus = "disputed"
+	gateway.automateTransitions(context.Background())
+	if !slices.Equal(chain.completed, []uint64{40}) || !slices.Equal(chain.cancelled, []uint64{41}) {
+		t.Fatalf("Expected job 40 released and 41 refunded, got releases %v and refunds %v", chain.completed, chain.cancelled)
+	}
+	if store.refundReasons[41] != "client_cancelled" {
+		t.Errorf("Expected the rule's refund reason recorded, got %v", store.refundReasons)
+	}
+	if store.statusSeen[42] != "approved" || store.statusSeen[43] != "disputed" {
+		t.Errorf("Expected every transition recorded, got %v", store.statusSeen)
+	}
+	if _, ok := store.statusSeen[44]; ok {
+		t.Errorf("Expected a settled escrow not seen before to be left alone, got %v", store.statusSeen)
+	}
+
+	// A transition is acted on once
+	gateway.automateTransitions(context.Background())
+	if len(chain.completed) != 1 || len(chain.cancelled) != 1 {
+		t.Errorf("Expected no repeated action, got releases %v and refunds %v", chain.completed, chain.cancelled)
+	}
+
+	// The platform's change feed wakes the worker rather than acting itself
+	gateway.handlePlatformEvent(context.Background(), `{"event":"status_changed","application_id":7}`)
+	if len(gateway.automationWake) != 1 || len(chain.completed) != 1 {
+		t.Errorf("Expected a status_changed event to wake the worker only, got %d wakes and releases %v", len(gateway.automationWake), chain.completed)
+	}
+
+	cfg.AutomationRules = "approved=release+refund"
+	if _, err := NewPaymentGateway(cfg, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected invalid AUTOMATION_RULES to be rejected")
+	}
+}
+
+func TestPriceDeviationGuard(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{}
+	gateway, err := NewPaymentGateway(&config.Config{PriceDeviationPercent: 2}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// A $3,300 quote is 10% from the oracle's $3,000
+	body := `{"job_id":7,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":"250","client_address":"0x00000000000000000000000000000000000000c1","quoted_eth_usd_price":"330000000000"}`
+	rec := httptest.NewRecorder()
+	gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict || len(chain.posted) != 0 {
+		t.Fatalf("Expected 409 without posting, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// A deferred escrow quoted before the move is paused rather than funded
+	params := database.OperationParams{JobID: 8, FreelancerAddress: "0x00000000000000000000000000000000000000f1", ClientAddress: "0x00000000000000000000000000000000000000c1", USDAmount: "250", QuotedETHUSDPrice: "330000000000"}
+	op := &database.DeferredOperation{ID: 3, ApplicationID: 8, Operation: opPostJob, Params: params, Status: database.DeferredStatusDeferred, Deadline: time.Now().Add(time.Hour)}
+	store.deferred = []*database.DeferredOperation{op}
+	gateway.processDeferredOperations(context.Background())
+	if op.Status != database.DeferredStatusPaused || len(chain.posted) != 0 {
+		t.Fatalf("Expected the operation paused without posting, got %s and posts %v", op.Status, chain.posted)
+	}
+
+	confirm := func(price string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/deferred-operations/3/confirm-price", strings.NewReader(fmt.Sprintf(`{"quoted_eth_usd_price":%q}`, price)))
+		req.SetPathValue("id", "3")
+		gateway.confirmPriceHandler(rec, req)
+		return rec
+	}
+	if rec := confirm("330000000000"); rec.Code != http.StatusConflict || op.Status != database.DeferredStatusPaused {
+		t.Errorf("Expected a stale confirmation refused, got %d with %s", rec.Code, op.Status)
+	}
+	if rec := confirm("301000000000"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := confirm("301000000000"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 once no longer paused, got %d", rec.Code)
+	}
+
+	gateway.processDeferredOperations(context.Background())
+	if op.Status != database.DeferredStatusSubmitted || !slices.Equal(chain.posted, []uint64{8}) {
+		t.Errorf("Expected the confirmed escrow posted, got %s and posts %v", op.Status, chain.posted)
+	}
+}
+
+func TestHealthSnapshot(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{block: 100}
+	gateway, err := NewPaymentGateway(&config.Config{HealthSlowLatency: time.Second}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	gateway.markWorkerRun("deferred_operations", time.Minute)
+	snapshot := gateway.takeHealthSnapshot(context.Background(), nil)
+	if snapshot.Status != database.HealthStatusOK || *snapshot.LastBlock != 100 || len(snapshot.Workers) != 1 || snapshot.RPCLatencyMS == nil || snapshot.DBLatencyMS == nil {
+		t.Fatalf("Expected a healthy snapshot at block 100, got %+v", snapshot)
+	}
+
+	// The same block as last time and a worker that missed two runs
+	gateway.workers.Store("status_poller", workerRun{at: time.Now().Add(-10 * time.Minute), every: time.Minute})
+	snapshot = gateway.takeHealthSnapshot(context.Background(), snapshot.LastBlock)
+	if snapshot.Status != database.HealthStatusDegraded || len(snapshot.Problems) != 2 || !snapshot.Workers[1].Stale {
+		t.Errorf("Expected a degraded snapshot with two problems, got %+v", snapshot)
+	}
+
+	chain.blockErr = errors.New("connection refused")
+	if snapshot := gateway.takeHealthSnapshot(context.Background(), nil); snapshot.Status != database.HealthStatusDown || snapshot.LastBlock != nil {
+		t.Errorf("Expected the gateway down without an RPC node, got %+v", snapshot)
+	}
+}
+
+func TestHealthHistoryHandler(t *testing.T) {
+	store := newTestStore()
+	now := time.Now()
+	for i, status := range []string{"ok", "ok", "ok", "degraded", "down"} {
+		store.healthSnapshots = append(store.healthSnapshots, &database.HealthSnapshot{ID: int64(i + 1), TakenAt: now.Add(time.Duration(i-5) * time.Minute), Status: status})
+	}
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.getHealthHistoryHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/health-history?limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response HealthHistoryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.SnapshotCount != 5 || *response.UptimePercent != 60 || len(response.Snapshots) != 2 || response.Snapshots[0].Status != "down" {
+		t.Errorf("Expected 60%% uptime over 5 snapshots, newest first, got %+v", response)
+	}
+	if response.DegradedSince == nil || !response.DegradedSince.Equal(store.healthSnapshots[3].TakenAt) {
+		t.Errorf("Expected degradation from the fourth snapshot, got %v", response.DegradedSince)
+	}
+
+	// The next page picks up after the last snapshot, and filters narrow the
+	// snapshots but not the uptime
+	next := rec.Header().Get("X-Next-Cursor")
+	if next == "" {
+		t.Fatal("Expected a cursor for the next page")
+	}
+	rec = httptest.NewRecorder()
+	gateway.getHealthHistoryHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/health-history?limit=2&status=ok&cursor="+next, nil))
+	response = HealthHistoryResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.SnapshotCount != 5 || len(response.Snapshots) != 2 || !response.Snapshots[0].TakenAt.Equal(store.healthSnapshots[2].TakenAt) || rec.Header().Get("X-Next-Cursor") == "" {
+		t.Errorf("Expected the two newest ok snapshots after the first page and another page, got %+v", response)
+	}
+
+	for _, query := range []string{"since=yesterday", "status[gt]=ok", "sort=status"} {
+		rec = httptest.NewRecorder()
+		gateway.getHealthHistoryHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/health-history?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	store := newTestStore()
+	applicationID := int32(7)
+	store.ledger = []*database.LedgerTransaction{{
+		ID:            1,
+		Kind:          "fee_accrual",
+		ApplicationID: &applicationID,
+		Entries:       []database.LedgerEntry{{Account: "platform_wallet", AmountWei: "500"}, {Account: "fee_revenue", AmountWei: "-500"}},
+	}}
+	gateway := newTestGateway(t, store, &config.Config{})
+	graphql := func(method, body string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if method == http.MethodGet {
+			req = httptest.NewRequest(method, "/graphql?query="+url.QueryEscape(body), nil)
+		} else {
+			req = httptest.NewRequest(method, "/graphql", strings.NewReader(body))
+		}
+		rec := httptest.NewRecorder()
+		gateway.graphqlHandler(rec, req)
+		return rec
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"query": `query Dashboard($status: String) {
+			jobs(paymentStatus: $status) {
+				applicationId usdAmount
+				events { status actor }
+				ledgerTransactions(kind: "fee_accrual") { kind entries { account amountWei } job { paymentStatus } }
+			}
+			price(atBlock: 100) { ethUsd roundId }
+			missing: job(id: 99) { applicationId }
+		}`,
+		"variables": map[string]interface{}{"status": "deposited"},
+	})
+	rec := graphql(http.MethodPost, string(body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	expected := `{"data":{"jobs":[{"applicationId":7,"usdAmount":250,` +
+		`"events":[{"status":"deposit_initiated","actor":"gateway"},{"status":"deposited","actor":"reconciler"}],` +
+		`"ledgerTransactions":[{"kind":"fee_accrual","entries":[{"account":"platform_wallet","amountWei":"500"},{"account":"fee_revenue","amountWei":"-500"}],"job":{"paymentStatus":"deposited"}}]}],` +
+		`"price":{"ethUsd":"3000.00","roundId":"7"},"missing":null}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != expected {
+		t.Errorf("Unexpected response:\nexpected %s\n     got %s", expected, got)
+	}
+
+	// A failing field is null with its error; the rest of the query still resolves
+	rec = graphql(http.MethodGet, `{ events(status: "deposited") { applicationId } price(atTime: "2020-01-01T00:00:00Z") { ethUsd } }`)
+	var response struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string        `json:"message"`
+			Path    []interface{} `json:"path"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if string(response.Data["events"]) != `[{"applicationId":7}]` || string(response.Data["price"]) != "null" {
+		t.Errorf("Expected the events with a null price, got %v", response.Data)
+	}
+	if len(response.Errors) != 1 || response.Errors[0].Path[0] != "price" {
+		t.Errorf("Expected the price error at its path, got %+v", response.Errors)
+	}
+
+	// jobs pages with the cursor of the last job, as list endpoints do
+	var page struct {
+		Data struct {
+			Jobs []struct {
+				ApplicationID int32  `json:"applicationId"`
+				Cursor        string `json:"cursor"`
+			} `json:"jobs"`
+		} `json:"data"`
+	}
+	rec = graphql(http.MethodGet, `{ jobs(limit: 1) { applicationId cursor } }`)
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil || len(page.Data.Jobs) != 1 || page.Data.Jobs[0].ApplicationID != 7 {
+		t.Fatalf("Expected the first job alone, got %+v, %v", page, err)
+	}
+	rec = graphql(http.MethodGet, `{ jobs(after: "`+page.Data.Jobs[0].Cursor+`") { applicationId } }`)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"data":{"jobs":[{"applicationId":8}]}}` {
+		t.Errorf("Expected the jobs after the cursor, got %s", got)
+	}
+	if rec := graphql(http.MethodGet, `{ events(after: "not-a-cursor") { id } }`); !strings.Contains(rec.Body.String(), "invalid cursor") {
+		t.Errorf("Expected the cursor to be checked, got %s", rec.Body)
+	}
+
+	for _, query := range []string{`{ jobs { secret } }`, `mutation { jobs { applicationId } }`, `{ jobs(limit: 5000) { applicationId }`} {
+		if rec := graphql(http.MethodGet, query); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"errors"`) {
+			t.Errorf("%s: expected a 400 with errors, got %d: %s", query, rec.Code, rec.Body)
+		}
+	}
+	if rec := graphql(http.MethodGet, `{ jobs(limit: 5000) { applicationId } }`); !strings.Contains(rec.Body.String(), "limit must be between 1 and 1000") {
+		t.Errorf("Expected the limit to be checked, got %s", rec.Body)
+	}
+}
+
+func TestArchiveSettledJobs(t *testing.T) {
+	store := newTestStore()
+	applicationID := int32(7)
+	store.details[7].PaymentStatus = "released"
+	store.details[9] = &database.ApplicationPaymentDetails{ApplicationID: 9, PaymentStatus: "refunded"}
+	store.events[9] = []database.PaymentEvent{{Status: "refunded", Actor: database.ActorGateway, CreatedAt: time.Now()}}
+	store.costs = map[int32][]database.TransactionCost{7: {{Operation: opPostJob, TxHash: "0xdeposit", GasUsed: 150000, EffectiveGasPrice: "20000000000", CostWei: "3000000000000000"}}}
+	store.ledger = []*database.LedgerTransaction{{
+		Kind:          "fee_accrual",
+		Reference:     "0xrelease",
+		ApplicationID: &applicationID,
+		Entries:       []database.LedgerEntry{{Account: "platform_wallet", AmountWei: "500"}, {Account: "fee_revenue", AmountWei: "-500"}},
+	}}
+
+	cfg := &config.Config{ArchiveBucketURL: "file://" + t.TempDir(), ArchiveAfter: 24 * time.Hour, ArchiveBatchSize: 10, ArchiveRetention: time.Hour}
+	gateway := newTestGateway(t, store, cfg)
+
+	now := time.Now()
+	written := gateway.archiveSettledJobs(context.Background(), now)
+	if len(written) != 1 || written[0].Records != 1 || !slices.Equal(written[0].ApplicationIDs, []int32{7}) {
+		t.Fatalf("Expected only the job settled over a day ago to be archived, got %+v", written)
+	}
+	if store.archived[7] != written[0].Batch {
+		t.Errorf("Expected application 7 to be marked archived in %s, got %q", written[0].Batch, store.archived[7])
+	}
+	if again := gateway.archiveSettledJobs(context.Background(), now); len(again) != 0 {
+		t.Errorf("Expected archived jobs to be skipped, got %+v", again)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.listArchivesHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/archives", nil))
+	var list ArchiveListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list.Batches) != 1 || list.Batches[0].SHA256 != written[0].SHA256 {
+		t.Fatalf("Expected the written batch to be listed, got %d: %+v (%v)", rec.Code, list, err)
+	}
+
+	restore := func(batch, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/archives/"+batch+"/restore"+query, nil)
+		req.SetPathValue("batch", batch)
+		rec := httptest.NewRecorder()
+		gateway.restoreArchiveHandler(rec, req)
+		return rec
+	}
+	rec = restore(written[0].Batch, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var restored RestoreArchiveResponse
+	json.NewDecoder(rec.Body).Decode(&restored)
+	if restored.Jobs != 1 || restored.Events != 2 || restored.Receipts != 1 || restored.LedgerTransactions != 1 {
+		t.Errorf("Unexpected restore counts: %+v", restored)
+	}
+	job := store.restored[0]
+	if job.ApplicationID != 7 || !job.Events[1].CreatedAt.Equal(time.Unix(1700000060, 0)) || job.Costs[0].CostWei != "3000000000000000" {
+		t.Errorf("Unexpected restored job: %+v", job)
+	}
+	if entries := job.Ledger[0].Entries; len(entries) != 2 || entries[0] != (database.LedgerEntry{Account: "fee_revenue", AmountWei: "-500"}) {
+		t.Errorf("Unexpected restored ledger entries: %+v", entries)
+	}
+
+	if rec := restore(written[0].Batch, "?application_id=9"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a job outside the batch, got %d", rec.Code)
+	}
+	if rec := restore("20200101T000000Z-1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown batch, got %d", rec.Code)
+	}
+
+	// The batch expires an hour after it was written
+	if pruned := gateway.archiveSettledJobs(context.Background(), now.Add(2*time.Hour)); len(pruned) != 0 {
+		t.Errorf("Expected no new batches, got %+v", pruned)
+	}
+	if rec := restore(written[0].Batch, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the expired batch to be pruned, got %d", rec.Code)
+	}
+
+	disabled := newTestGateway(t, newTestStore(), &config.Config{})
+	rec = httptest.NewRecorder()
+	disabled.listArchivesHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/archives", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without ARCHIVE_BUCKET_URL, got %d", rec.Code)
+	}
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	cfg := &config.Config{
+		ServerPort:          "8081",
+		ServerReadTimeout:   30 * time.Second,
+		ServerWriteTimeout:  5 * time.Minute,
+		MaxRequestBodyBytes: 16,
+		TLSAutocertHTTPAddr: ":80",
+	}
+	server, err := newHTTPServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if server.tls() || server.challenge != nil || server.ReadTimeout != 30*time.Second {
+		t.Errorf("Expected a plain HTTP server with the configured timeouts, got %s", server.describe())
+	}
+
+	post := func(body io.Reader) int {
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/post-job", body))
+		return rec.Code
+	}
+	if code := post(strings.NewReader(`{"application_id":7}`)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a declared oversized body, got %d", code)
+	}
+	if code := post(io.MultiReader(strings.NewReader(`{"application_id":`), strings.NewReader(`7}`))); code != http.StatusBadRequest {
+		t.Errorf("Expected reads past the limit to fail, got %d", code)
+	}
+	if code := post(strings.NewReader(`{}`)); code != http.StatusOK {
+		t.Errorf("Expected a small body through, got %d", code)
+	}
+
+	autocertCfg := *cfg
+	autocertCfg.TLSAutocertDomains = "pay.example.com, api.example.com"
+	server, err = newHTTPServer(&autocertCfg, http.NotFoundHandler())
+	if err != nil || !server.tls() || server.challenge == nil || server.challenge.Addr != ":80" {
+		t.Fatalf("Expected an autocert server with a challenge listener, got %+v (%v)", server, err)
+	}
+
+	for _, bad := range []config.Config{
+		{TLSCertFile: "cert.pem"},
+		{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", TLSAutocertDomains: "pay.example.com"},
+	} {
+		if _, err := newHTTPServer(&bad, http.NotFoundHandler()); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestServerConnections(t *testing.T) {
+	cfg := &config.Config{ServerHTTP2: true, ServerH2C: true, ServerHTTP2MaxStreams: 100, ServerTCPKeepAlive: -1}
+	server, err := newHTTPServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if server.HTTP2.MaxConcurrentStreams != 100 || !server.Protocols.UnencryptedHTTP2() {
+		t.Errorf("Expected h2c with 100 streams, got %s", server.describe())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+	url := "http://" + listener.Addr().String() + "/health"
+
+	get := func(client *http.Client) {
+		t.Helper()
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	keepAlive := &http.Client{Transport: &http.Transport{}}
+	get(keepAlive)
+	get(keepAlive)
+	if stats := server.connections.Stats(); stats.Accepted != 1 || stats.Requests != 2 || stats.RequestsPerConnection != 2 || stats.HTTP2Requests != 0 {
+		t.Errorf("Expected two HTTP/1.1 requests on one kept-alive connection, got %+v", stats)
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	h2c := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	get(h2c)
+	if stats := server.connections.Stats(); stats.Accepted != 2 || stats.HTTP2Requests != 1 || stats.Open != 2 {
+		t.Errorf("Expected an h2c request on a second connection, got %+v", stats)
+	}
+
+	keepAlive.CloseIdleConnections()
+	h2c.CloseIdleConnections()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.connections.Stats().Closed < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := server.connections.Stats(); stats.Closed != 2 || stats.Open != 0 {
+		t.Errorf("Expected both connections closed, got %+v", stats)
+	}
+}
+
+func TestDiscoverEscrowsHandler(t *testing.T) {
+	client := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	freelancer := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	posted := func(jobID uint64, tx string, block uint64, usd int64) payment.EscrowEvent {
+		return payment.EscrowEvent{
+			JobEvent:   payment.JobEvent{Kind: payment.JobEventPosted, TxHash: tx, BlockNumber: block},
+			JobID:      jobID,
+			Client:     client,
+			Freelancer: freelancer,
+			USDAmount:  big.NewInt(usd),
+			ETHAmount:  big.NewInt(usd * 1e15),
+		}
+	}
+	chain := &fakeChain{block: 500, escrowEvents: []payment.EscrowEvent{
+		posted(7, "0xdeposit", 110, 250), // already tracked
+		posted(8, "0xlegacy8", 120, 40),
+		{JobEvent: payment.JobEvent{Kind: payment.JobEventReleased, TxHash: "0xrelease8", BlockNumber: 130}, JobID: 8, Freelancer: freelancer},
+		posted(9, "0xlegacy9", 140, 60),   // wallets differ
+		posted(10, "0xlegacy10", 150, 60), // released with another deposit
+		posted(404, "0xlegacy404", 160, 10),
+		posted(retainer.JobIDOffset+1, "0xperiod", 170, 10),
+		posted(11, "0xlater", 600, 10), // past the scanned range
+	}}
+	store := newTestStore()
+	store.details[8].ApplicantWalletAddress = strPtr(strings.ToLower(freelancer.Hex()))
+	store.details[8].PosterWalletAddress = strPtr(client.Hex())
+	store.details[9] = &database.ApplicationPaymentDetails{ApplicationID: 9, PaymentStatus: "pending_deposit", ApplicantWalletAddress: strPtr("0x00000000000000000000000000000000000000f9"), PosterWalletAddress: strPtr(client.Hex())}
+	store.details[10] = &database.ApplicationPaymentDetails{ApplicationID: 10, PaymentStatus: "released", EscrowTxHashDeposit: strPtr("0xother")}
+	gateway, err := NewPaymentGateway(&config.Config{ContractDeployBlock: 100}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	discover := func(query string) DiscoverEscrowsResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		gateway.discoverEscrowsHandler(rec, httptest.NewRequest(http.MethodPost, "/admin/escrows/discover"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var response DiscoverEscrowsResponse
+		json.NewDecoder(rec.Body).Decode(&response)
+		return response
+	}
+
+	dry := discover("?dry_run=true")
+	if dry.FromBlock != 100 || dry.ToBlock != 500 || dry.Events != 7 || dry.Tracked != 1 {
+		t.Errorf("Unexpected scan summary: %+v", dry)
+	}
+	outcomes := map[string]int{database.DiscoveryLinked: 1, database.DiscoveryMismatch: 1, database.DiscoveryConflict: 1, database.DiscoveryUnmatched: 1}
+	if !maps.Equal(dry.Outcomes, outcomes) {
+		t.Errorf("Expected outcomes %v, got %v", outcomes, dry.Outcomes)
+	}
+	if store.details[8].PaymentStatus != "pending_deposit" || len(store.discovered) != 0 {
+		t.Fatal("Expected a dry run to change nothing")
+	}
+
+	discover("")
+	linked := store.details[8]
+	if linked.PaymentStatus != "released" || derefString(linked.EscrowTxHashDeposit) != "0xlegacy8" || derefString(linked.EscrowTxHashRelease) != "0xrelease8" {
+		t.Errorf("Expected application 8 to follow its released escrow, got %+v", linked)
+	}
+	if reason := store.discovered[9].Reason; store.discovered[9].Outcome != database.DiscoveryMismatch || !strings.Contains(reason, "applicant") {
+		t.Errorf("Expected a freelancer wallet mismatch for job 9, got %+v", store.discovered[9])
+	}
+	if store.details[10].PaymentStatus != "released" || store.discovered[10].Outcome != database.DiscoveryConflict {
+		t.Errorf("Expected job 10 to be left for resync, got %+v", store.discovered[10])
+	}
+	if store.discovered[404].ApplicationID != nil || store.discovered[404].Outcome != database.DiscoveryUnmatched {
+		t.Errorf("Expected job 404 to be recorded unmatched, got %+v", store.discovered[404])
+	}
+	if _, ok := store.discovered[retainer.JobIDOffset+1]; ok {
+		t.Error("Expected retainer periods to be skipped")
+	}
+
+	// Once linked, the deposit counts as tracked
+	if again := discover("?dry_run=true"); again.Tracked != 2 || again.Outcomes[database.DiscoveryLinked] != 0 {
+		t.Errorf("Expected the linked escrow to be tracked on a second scan, got %+v", again)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.listDiscoveredEscrowsHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/escrows/discovered?unlinked=true", nil))
+	var unlinked []DiscoveredEscrowResponse
+	json.NewDecoder(rec.Body).Decode(&unlinked)
+	if len(unlinked) != 3 || unlinked[0].JobID != 9 {
+		t.Errorf("Expected the three unlinked escrows, got %+v", unlinked)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.listDiscoveredEscrowsHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/escrows/discovered?outcome[in]=mismatch,conflict&limit=1", nil))
+	var mismatched []DiscoveredEscrowResponse
+	json.NewDecoder(rec.Body).Decode(&mismatched)
+	if len(mismatched) != 1 || mismatched[0].JobID != 9 || rec.Header().Get("X-Next-Cursor") == "" {
+		t.Errorf("Expected job 9 and a cursor to job 10, got %+v", mismatched)
+	}
+	rec = httptest.NewRecorder()
+	gateway.listDiscoveredEscrowsHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/escrows/discovered?outcome[gt]=linked", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported operator, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.discoverEscrowsHandler(rec, httptest.NewRequest(http.MethodPost, "/admin/escrows/discover?from_block=200&to_block=100", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an inverted range, got %d", rec.Code)
+	}
+}
+
+func TestEventIndexer(t *testing.T) {
+	event := func(kind, tx string, block uint64, logIndex uint, jobID uint64) payment.EscrowEvent {
+		return payment.EscrowEvent{
+			JobEvent:  payment.JobEvent{Kind: kind, TxHash: tx, BlockNumber: block, LogIndex: logIndex},
+			BlockHash: fmt.Sprintf("0xblock%d", block),
+			JobID:     jobID,
+			ETHAmount: big.NewInt(1e15),
+		}
+	}
+	chain := &fakeChain{block: 3000, escrowEvents: []payment.EscrowEvent{
+		event(payment.JobEventPosted, "0xpost8", 150, 0, 8),
+		event(payment.JobEventPosted, "0xpost9", 2500, 1, 9),
+		event(payment.JobEventReleased, "0xrelease8", 2980, 0, 8),
+	}}
+	store := newTestStore()
+	gateway, err := NewPaymentGateway(&config.Config{ContractDeployBlock: 100, IndexerSafetyWindow: 64, IndexerPollInterval: time.Second},
+		WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ctx := context.Background()
+
+	// The first scan starts at the deploy block and checkpoints in batches
+	added, removed, err := gateway.indexEscrowEvents(ctx, true)
+	if err != nil || added != 3 || removed != 0 {
+		t.Fatalf("first scan = %d added, %d removed, %v; want 3 and 0", added, removed, err)
+	}
+	if store.checkpoint == nil || store.checkpoint.BlockNumber != 3000 || store.checkpoint.BlockHash != "0xblock3000" {
+		t.Fatalf("checkpoint = %+v, want block 3000 with its hash", store.checkpoint)
+	}
+
+	// Restarting scans the safety window again without indexing anything twice
+	added, removed, err = gateway.indexEscrowEvents(ctx, true)
+	if err != nil || added != 0 || removed != 0 || len(store.indexed) != 3 {
+		t.Fatalf("rescan = %d added, %d removed, %v with %d indexed; want nothing new and 3 indexed", added, removed, err, len(store.indexed))
+	}
+
+	// New blocks are scanned from the checkpoint
+	chain.block = 3010
+	chain.escrowEvents = append(chain.escrowEvents, event(payment.JobEventPosted, "0xpost10", 3005, 0, 10))
+	if added, _, err := gateway.indexEscrowEvents(ctx, false); err != nil || added != 1 || store.checkpoint.BlockNumber != 3010 {
+		t.Fatalf("next scan = %d added, %v at checkpoint %d; want 1 at 3010", added, err, store.checkpoint.BlockNumber)
+	}
+
+	// A reorg of the checkpointed block drops the release and mines another
+	chain.block = 3012
+	chain.blockHashes = map[uint64]string{3010: "0xfork3010"}
+	chain.escrowEvents = []payment.EscrowEvent{
+		event(payment.JobEventPosted, "0xpost8", 150, 0, 8),
+		event(payment.JobEventPosted, "0xpost9", 2500, 1, 9),
+		event(payment.JobEventPosted, "0xpost10", 3005, 0, 10),
+		event(payment.JobEventCancelled, "0xcancel9", 3011, 0, 9),
+	}
+	added, removed, err = gateway.indexEscrowEvents(ctx, false)
+	if err != nil || added != 1 || removed != 1 {
+		t.Fatalf("scan after reorg = %d added, %d removed, %v; want 1 and 1", added, removed, err)
+	}
+	var txs []string
+	for _, indexed := range store.indexed {
+		txs = append(txs, indexed.TxHash)
+	}
+	if got := strings.Join(txs, ","); got != "0xpost8,0xpost9,0xpost10,0xcancel9" {
+		t.Errorf("indexed %s after the reorg", got)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.getIndexerStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/indexer", nil))
+	var status IndexerStatusResponse
+	json.NewDecoder(rec.Body).Decode(&status)
+	if rec.Code != http.StatusOK || !status.Enabled || status.Checkpoint == nil || status.Checkpoint.BlockNumber != 3012 || status.LagBlocks != 0 {
+		t.Errorf("GET /admin/indexer = %d %+v", rec.Code, status)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	gateway := newTestGateway(t, newTestStore(), &config.Config{})
+
+	gateway.goWorker("status_poller", func(ctx context.Context) { <-ctx.Done() })
+	gateway.goWorker("archiver", func(ctx context.Context) {}) // disabled
+	release := make(chan struct{})
+	delivered := make(chan error, 1)
+	gateway.goDelivery(time.Minute, func(ctx context.Context) {
+		select {
+		case <-release:
+			delivered <- ctx.Err()
+		case <-ctx.Done():
+			delivered <- ctx.Err()
+		}
+	})
+
+	var vars DebugVars
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		rec := httptest.NewRecorder()
+		gateway.debugVarsHandler(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+		if err := json.NewDecoder(rec.Body).Decode(&vars); err != nil {
+			t.Fatalf("Failed to decode debug vars: %v", err)
+		}
+		if vars.WorkersRunning == 1 {
+			break
+		}
+	}
+	if len(vars.Workers) != 2 || vars.Workers[0].Name != "archiver" || vars.Workers[0].State != workerExited || vars.Workers[1].State != workerRunning {
+		t.Errorf("Expected a running poller and an exited archiver, got %+v", vars.Workers)
+	}
+	if vars.WorkersRunning != 1 || vars.WebhookDeliveries != 1 || vars.Goroutines < 2 {
+		t.Errorf("Unexpected counts %+v", vars)
+	}
+
+	// Shutdown stops the poller at once but lets the delivery finish
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- gateway.Shutdown(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-delivered; err != nil {
+		t.Errorf("Expected the delivery to run to the end, got %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if states := gateway.workerStates(); states[1].State != workerStopped || states[1].Running != 0 {
+		t.Errorf("Expected the poller stopped, got %+v", states[1])
+	}
+
+	// A worker that ignores the root context is reported when the grace period ends
+	gateway = newTestGateway(t, newTestStore(), &config.Config{})
+	stuck := make(chan struct{})
+	defer close(stuck)
+	gateway.goWorker("wallet_monitor", func(ctx context.Context) { <-stuck })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := gateway.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "wallet_monitor") {
+		t.Errorf("Expected the stuck worker named, got %v", err)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	store := newTestStore()
+	gateway, err := NewPaymentGateway(&config.Config{IdempotencyKeyTTL: time.Hour},
+		WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// The handler stands in for a submission whose outcome is set per call
+	calls := 0
+	status, txHash := http.StatusOK, "0xsubmitted"
+	handler := gateway.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if status != http.StatusOK {
+			http.Error(w, "RPC unavailable", status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(TransactionResponse{TxHash: txHash, Success: true})
+	})
+	send := func(key, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/complete-job?"+query, nil)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	first := send("retry-1", "job_id=7")
+	txHash = "0xsecond"
+	retry := send("retry-1", "job_id=7")
+	if calls != 1 || retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() || retry.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Fatalf("Expected the retry replayed without a second call, got %d calls and %d %q", calls, retry.Code, retry.Body.String())
+	}
+	if stored := store.idempotencyKeys["/retry-1"].response; stored.TxHash == nil || *stored.TxHash != "0xsubmitted" {
+		t.Errorf("Expected the transaction hash stored with the key, got %+v", stored)
+	}
+
+	if rec := send("retry-1", "job_id=8"); rec.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("Expected a reused key on another job to get 422, got %d after %d calls", rec.Code, calls)
+	}
+
+	// A failure that sent nothing frees the key for the retry
+	status = http.StatusBadGateway
+	if rec := send("retry-2", "job_id=8"); rec.Code != http.StatusBadGateway {
+		t.Fatalf("Expected the failure passed through, got %d", rec.Code)
+	}
+	status = http.StatusOK
+	if rec := send("retry-2", "job_id=8"); rec.Code != http.StatusOK || calls != 3 || rec.Header().Get(IdempotentReplayHeader) != "" {
+		t.Errorf("Expected the retry after a failure handled afresh, got %d after %d calls", rec.Code, calls)
+	}
+
+	// Claimed by a request that hasn't answered yet
+	store.idempotencyKeys["/in-flight"] = &fakeIdempotencyKey{requestHash: fmt.Sprintf("%x", sha256.Sum256([]byte("POST /complete-job?job_id=9\n")))}
+	if rec := send("in-flight", "job_id=9"); rec.Code != http.StatusConflict || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected a retry during the first request to get 409 with Retry-After, got %d", rec.Code)
+	}
+
+	if rec := send("", "job_id=7"); rec.Code != http.StatusOK || calls != 4 {
+		t.Errorf("Expected a request without a key passed through, got %d after %d calls", rec.Code, calls)
+	}
+	if rec := send("bad\x01key", "job_id=7"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a key with control characters refused, got %d", rec.Code)
+	}
+}
+
+func TestEscrowEventStatusUpdates(t *testing.T) {
+	store := newTestStore()
+	store.details[9] = &database.ApplicationPaymentDetails{ApplicationID: 9, PaymentStatus: paymentstatus.ReleaseInitiated, EscrowTxHashRelease: strPtr("0xrelease9")}
+	store.details[10] = &database.ApplicationPaymentDetails{ApplicationID: 10, PaymentStatus: paymentstatus.RefundInitiated, EscrowTxHashRefund: strPtr("0xcancel-replaced")}
+	store.details[11] = &database.ApplicationPaymentDetails{ApplicationID: 11, PaymentStatus: paymentstatus.Released}
+	store.details[12] = &database.ApplicationPaymentDetails{ApplicationID: 12, PaymentStatus: paymentstatus.Deposited}
+	store.details[13] = &database.ApplicationPaymentDetails{ApplicationID: 13, PaymentStatus: paymentstatus.PendingDeposit}
+	store.escrowJobs = map[int32]uint64{8: 8, 9: 9, 10: 10, 11: 11, 12: 12, 13: 1013}
+	event := func(kind, tx string, block, jobID uint64) database.IndexedEvent {
+		return database.IndexedEvent{Kind: kind, TxHash: tx, BlockNumber: block, BlockHash: fmt.Sprintf("0xblock%d", block), JobID: jobID}
+	}
+	store.indexed = []database.IndexedEvent{
+		event(payment.JobEventPosted, "0xpost8", 150, 8),
+		event(payment.JobEventPosted, "0xpost11", 160, 11),
+		event(payment.JobEventPosted, "0xretainer", 170, 500),
+		event(payment.JobEventReleased, "0xrelease9", 2000, 9),
+		event(payment.JobEventCancelled, "0xcancel10", 2990, 10),
+		event(payment.JobEventCancelled, "0xcancel12", 2995, 12),
+		event(payment.JobEventPosted, "0xpost13", 2999, 1013),
+	}
+	store.checkpoint = &database.EventCheckpoint{Name: escrowIndexer, BlockNumber: 3000, BlockHash: "0xblock3000"}
+
+	gateway, err := NewPaymentGateway(&config.Config{ContractDeployBlock: 100, RequiredConfirmations: 3, StatusPolling: true, EventStatusUpdates: true},
+		WithChainClient(&fakeChain{block: 3000}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := gateway.applyEscrowEvents(ctx); err != nil {
+		t.Fatalf("Failed to apply escrow events: %v", err)
+	}
+	expected := map[int32]paymentstatus.Status{
+		8:  paymentstatus.Deposited,        // posted outside the gateway
+		9:  paymentstatus.ReleaseInitiated, // left to the poller, which records the gas too
+		10: paymentstatus.Refunded,         // cancelled by another transaction than the recorded one
+		11: paymentstatus.Released,         // already past the deposit
+		12: paymentstatus.Refunded,         // cancelled on-chain by the client
+		13: paymentstatus.PendingDeposit,   // not yet REQUIRED_CONFIRMATIONS deep
+	}
+	for applicationID, want := range expected {
+		if got := store.details[applicationID].PaymentStatus; got != want {
+			t.Errorf("application %d is '%s', want '%s'", applicationID, got, want)
+		}
+	}
+	if len(store.changes) != 3 || store.changes[0].Actor != database.ActorIndexer || store.changes[0].TxType != "deposit" || *store.changes[0].TxHash != "0xpost8" {
+		t.Fatalf("Expected three changes by the indexer, the first recording the deposit, got %+v", store.changes)
+	}
+	if store.refundReasons[12] != string(payment.RefundReasonOther) || len(store.refundReasons) != 1 {
+		t.Errorf("Expected only the refund cancelled outside the gateway recorded, as other, got %v", store.refundReasons)
+	}
+	if store.statusCheckpoint == nil || store.statusCheckpoint.BlockNumber != 2998 {
+		t.Fatalf("status checkpoint = %+v, want block 2998", store.statusCheckpoint)
+	}
+
+	// Once the indexer moves on, the deposit is deep enough and only it is applied
+	store.checkpoint = &database.EventCheckpoint{Name: escrowIndexer, BlockNumber: 3001, BlockHash: "0xblock3001"}
+	if err := gateway.applyEscrowEvents(ctx); err != nil {
+		t.Fatalf("Failed to apply escrow events: %v", err)
+	}
+	if store.details[13].PaymentStatus != paymentstatus.Deposited || len(store.changes) != 4 || store.statusCheckpoint.BlockNumber != 2999 {
+		t.Errorf("Expected application 13 deposited at checkpoint 2999, got '%s' with %d changes at %d", store.details[13].PaymentStatus, len(store.changes), store.statusCheckpoint.BlockNumber)
+	}
+}
+
+func TestKYCGate(t *testing.T) {
+	chain := &fakeChain{}
+	store := newTestStore()
+	amount := int32(80)
+	store.details[9] = &database.ApplicationPaymentDetails{ApplicationID: 9, ApplicantUserID: 5, AgreedUSDAmount: &amount, PaymentStatus: "deposited"}
+	statuses := map[int32]kyc.Status{}
+	checker := kyc.CheckerFunc(func(ctx context.Context, userID int32) (kyc.Status, error) {
+		if status, ok := statuses[userID]; ok {
+			return status, nil
+		}
+		return "", errors.New("provider unavailable")
+	})
+	gateway, err := NewPaymentGateway(&config.Config{KYCThresholdUSD: 100},
+		WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}), WithKYCChecker(checker))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/complete-job", gateway.completeJobHandler)
+	mux.HandleFunc("GET /admin/kyc-holds", gateway.listKYCHoldsHandler)
+	mux.HandleFunc("POST /admin/kyc-holds/{id}/recheck", gateway.recheckKYCHoldHandler)
+	mux.HandleFunc("POST /admin/kyc-holds/{id}/withdraw", gateway.withdrawKYCHoldHandler)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	// Releases at or below the threshold don't wait for KYC
+	if rec := do(http.MethodPost, "/complete-job?job_id=9", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the $80 release to go ahead, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// A status that can't be looked up holds the release all the same
+	rec := do(http.MethodPost, "/complete-job?job_id=7", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected the $250 release to be held, got %d: %s", rec.Code, rec.Body)
+	}
+	var hold database.KYCHold
+	json.NewDecoder(rec.Body).Decode(&hold)
+	if hold.Status != database.KYCHoldOpen || hold.KYCStatus != string(kyc.StatusPending) || hold.LastError == nil || store.details[7].PaymentStatus != paymentstatus.KYCPending {
+		t.Fatalf("Expected an open hold with the lookup error and a kyc_pending job, got %+v and %q", hold, store.details[7].PaymentStatus)
+	}
+	if rec := do(http.MethodPost, "/complete-job?job_id=7", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a held job not to be released again, got %d", rec.Code)
+	}
+
+	// Rechecking a freelancer still in review keeps the hold
+	applicant := store.details[7].ApplicantUserID
+	statuses[applicant] = kyc.StatusPending
+	if rec := do(http.MethodPost, "/admin/kyc-holds/1/recheck", ""); rec.Code != http.StatusOK || store.kycHolds[0].Status != database.KYCHoldOpen || store.kycHolds[0].LastError != nil {
+		t.Fatalf("Expected the hold to stay open, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(chain.completed) != 1 {
+		t.Fatalf("Expected only job 9 released, got %v", chain.completed)
+	}
+
+	// Once they pass, the worker clears the hold and releases the job
+	statuses[applicant] = kyc.StatusVerified
+	gateway.recheckKYCHolds(context.Background())
+	if store.kycHolds[0].Status != database.KYCHoldCleared || !slices.Equal(chain.completed, []uint64{9, 7}) {
+		t.Fatalf("Expected the hold cleared and job 7 released, got %q and %v", store.kycHolds[0].Status, chain.completed)
+	}
+	if rec := do(http.MethodPost, "/admin/kyc-holds/1/recheck", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a cleared hold to be refused, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/admin/kyc-holds", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected no open holds, got %s", rec.Body)
+	}
+
+	// A withdrawn hold returns the job to deposited so it can be refunded
+	store.details[7].PaymentStatus = "deposited"
+	statuses[applicant] = kyc.StatusRejected
+	if rec := do(http.MethodPost, "/complete-job?job_id=7", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected a rejected freelancer's release to be held, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = do(http.MethodPost, "/admin/kyc-holds/2/withdraw", `{"reason": "refunding the client"}`)
+	if rec.Code != http.StatusOK || store.kycHolds[1].Status != database.KYCHoldWithdrawn || store.details[7].PaymentStatus != "deposited" {
+		t.Errorf("Expected the hold withdrawn and the job deposited, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/admin/kyc-holds/3/withdraw", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown hold, got %d", rec.Code)
+	}
+
+	// Past holds page one at a time
+	rec = do(http.MethodGet, "/admin/kyc-holds?status[in]=cleared,withdrawn&limit=1", "")
+	next := rec.Header().Get("X-Next-Cursor")
+	if rec.Code != http.StatusOK || next == "" || !strings.Contains(rec.Body.String(), `"status":"cleared"`) {
+		t.Fatalf("Expected the cleared hold and a next cursor, got %d %q: %s", rec.Code, next, rec.Body)
+	}
+	rec = do(http.MethodGet, "/admin/kyc-holds?status[in]=cleared,withdrawn&limit=1&cursor="+next, "")
+	if rec.Header().Get("X-Next-Cursor") != "" || !strings.Contains(rec.Body.String(), `"status":"withdrawn"`) {
+		t.Errorf("Expected the withdrawn hold on the last page, got %q: %s", rec.Header().Get("X-Next-Cursor"), rec.Body)
+	}
+	for _, target := range []string{"/admin/kyc-holds?sort=params", "/admin/kyc-holds?usd_amount[like]=5", "/admin/kyc-holds?limit=0"} {
+		if rec := do(http.MethodGet, target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestOversizedJobIDsAreRejected(t *testing.T) {
+	store := newTestStore()
+	gateway := newTestGateway(t, store, &config.Config{})
+
+	// 4294967303 wraps to application 7 as an int32
+	for _, target := range []string{"/complete-job?job_id=4294967303", "/cancel-job?job_id=4294967303&reason=client_cancelled", "/job-status?job_id=0"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		switch {
+		case strings.HasPrefix(target, "/complete-job"):
+			gateway.completeJobHandler(rec, req)
+		case strings.HasPrefix(target, "/cancel-job"):
+			gateway.cancelJobHandler(rec, req)
+		default:
+			req.Method = http.MethodGet
+			gateway.getJobStatusHandler(rec, req)
+		}
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "outside the application ID range") {
+			t.Errorf("%s: expected a range error, got %d: %s", target, rec.Code, rec.Body)
+		}
+	}
+
+	body := `{"job_id":4294967303,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":"250","client_address":"0x00000000000000000000000000000000000000c1"}`
+	rec := httptest.NewRecorder()
+	gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized post, got %d: %s", rec.Code, rec.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/4294967303/top-ups", nil)
+	req.SetPathValue("id", "4294967303")
+	rec = httptest.NewRecorder()
+	gateway.listTopUpsHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized top-up lookup, got %d", rec.Code)
+	}
+
+	if _, err := gateway.sendOperation(context.Background(), 7, opCompleteJob, database.OperationParams{JobID: 4294967303}); err == nil {
+		t.Error("Expected an operation for another application's job ID to be refused")
+	}
+
+	// Job ID 7 already belongs to another application's escrow
+	store.escrowJobs = map[int32]uint64{99: 7}
+	body = strings.Replace(body, "4294967303", "7", 1)
+	rec = httptest.NewRecorder()
+	gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "application 99") {
+		t.Errorf("Expected 409 for a job ID mapped elsewhere, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPostJobAddressCase(t *testing.T) {
+	const freelancer = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	chain := &fakeChain{
+		jobs: map[uint64]*payment.JobDetails{7: {
+			Client:     common.HexToAddress("0x00000000000000000000000000000000000000c1"),
+			Freelancer: common.HexToAddress(freelancer),
+			USDAmount:  big.NewInt(250),
+			ETHAmount:  big.NewInt(83333333),
+		}},
+		deposits: map[uint64]*payment.Deposit{7: {TxHash: "0xdeposit", Value: big.NewInt(83333333)}},
+	}
+	store := newTestStore()
+	store.details[7].ApplicantWalletAddress = strPtr(freelancer)
+	store.details[7].PosterWalletAddress = strPtr("0x00000000000000000000000000000000000000C1")
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	post := func(freelancer, client string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"job_id":7,"freelancer_address":%q,"usd_amount":"250","client_address":%q}`, freelancer, client)
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body)))
+		return rec
+	}
+
+	// The platform's checksummed and uppercase wallets match a lowercase request
+	if rec := post(strings.ToLower(freelancer), "0x00000000000000000000000000000000000000c1"); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for differently cased addresses, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := post("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "0x00000000000000000000000000000000000000c1"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "checksum") {
+		t.Errorf("Expected 400 for a miscased freelancer address, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := post(freelancer, "0x00000000000000000000000000000000000000c2"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Client address mismatch") {
+		t.Errorf("Expected a mismatch for another client, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestClientLimits(t *testing.T) {
+	store := newTestStore()
+	store.clientOpenUSD = map[string]int64{"tenant:acme": 900, "wallet:0x00000000000000000000000000000000000000C1": 100}
+	chain := &fakeChain{jobs: map[uint64]*payment.JobDetails{}, deposits: map[uint64]*payment.Deposit{}}
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	limitRequest := func(method, scope, subject, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/client-limits/"+scope+"/"+subject, strings.NewReader(body))
+		req.SetPathValue("scope", scope)
+		req.SetPathValue("subject", subject)
+		rec := httptest.NewRecorder()
+		switch method {
+		case http.MethodPut:
+			gateway.setClientLimitHandler(rec, req)
+		case http.MethodDelete:
+			gateway.deleteClientLimitHandler(rec, req)
+		default:
+			gateway.getClientLimitHandler(rec, req)
+		}
+		return rec
+	}
+	post := func(tenant string) *httptest.ResponseRecorder {
+		body := `{"job_id":7,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":"250","client_address":"0x00000000000000000000000000000000000000c1"}`
+		req := httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body))
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, req)
+		return rec
+	}
+
+	for _, c := range []struct{ scope, subject, body string }{
+		{"user", "7", `{"max_open_usd":1}`},
+		{"wallet", "0xc1", `{"max_open_usd":1}`},
+		{"tenant", "acme", `{}`},
+		{"tenant", "acme", `{"max_transaction_usd":-1}`},
+	} {
+		if rec := limitRequest(http.MethodPut, c.scope, c.subject, c.body); rec.Code != http.StatusBadRequest && rec.Code != http.StatusNotFound {
+			t.Errorf("Expected %s %s %s to be rejected, got %d", c.scope, c.subject, c.body, rec.Code)
+		}
+	}
+
+	// $900 open for the tenant plus $250 exceeds its $1,000 cap; the wallet's
+	// per-transaction cap is not reached
+	if rec := limitRequest(http.MethodPut, "tenant", "acme", `{"max_open_usd":1000,"reason":"new customer"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := limitRequest(http.MethodPut, "wallet", "0x00000000000000000000000000000000000000c1", `{"max_transaction_usd":300}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec := post("acme")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d: %s", rec.Code, rec.Body)
+	}
+	var blocked ClientLimitResponse
+	if err := json.NewDecoder(rec.Body).Decode(&blocked); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if blocked.Code != "client_limit" || len(blocked.Violations) != 1 || blocked.Violations[0].Rule != clientlimit.MaxOpenUSD || blocked.Violations[0].Subject != "acme" {
+		t.Errorf("Unexpected violations %+v", blocked)
+	}
+	if len(store.escrowJobs) != 0 || len(store.escrowTenants) != 0 {
+		t.Error("Expected a blocked escrow not to be recorded")
+	}
+
+	rec = limitRequest(http.MethodGet, "tenant", "acme", "")
+	var status ClientLimitStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if !status.Limited || status.OpenUSD != 900 || status.RemainingOpenUSD == nil || *status.RemainingOpenUSD != 100 || status.MaxTransactionUSD != nil {
+		t.Errorf("Unexpected tenant status %+v", status)
+	}
+
+	// Lowering the wallet's cap under the escrow blocks it without a tenant
+	limitRequest(http.MethodPut, "wallet", "0x00000000000000000000000000000000000000C1", `{"max_transaction_usd":200}`)
+	if len(store.clientLimits) != 2 {
+		t.Fatalf("Expected the checksummed wallet's limit to be replaced, got %+v", store.clientLimits)
+	}
+	if rec := post(""); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "max_transaction_usd") {
+		t.Errorf("Expected the per-transaction cap to block, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.listClientLimitsHandler(rec, httptest.NewRequest(http.MethodGet, "/client-limits?scope=wallet&limit=1", nil))
+	var listed []clientlimit.Limit
+	json.NewDecoder(rec.Body).Decode(&listed)
+	if len(listed) != 1 || listed[0].Scope != clientlimit.Wallet || rec.Header().Get("X-Next-Cursor") != "" {
+		t.Errorf("Expected only the wallet's limit on one page, got %+v", listed)
+	}
+	rec = httptest.NewRecorder()
+	gateway.listClientLimitsHandler(rec, httptest.NewRequest(http.MethodGet, "/client-limits?sort=subject", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsortable field, got %d", rec.Code)
+	}
+
+	if rec := limitRequest(http.MethodDelete, "wallet", "0x00000000000000000000000000000000000000c1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if rec := limitRequest(http.MethodDelete, "tenant", "acme", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if rec := limitRequest(http.MethodDelete, "tenant", "acme", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a removed limit, got %d", rec.Code)
+	}
+
+	rec = limitRequest(http.MethodGet, "tenant", "acme", "")
+	status = ClientLimitStatusResponse{}
+	json.NewDecoder(rec.Body).Decode(&status)
+	if status.Limited || status.RemainingOpenUSD != nil {
+		t.Errorf("Expected an unlimited tenant, got %+v", status)
+	}
+	if rec := post("acme"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the escrow to be posted without limits, got %d: %s", rec.Code, rec.Body)
+	}
+	if store.escrowTenants[7] != "acme" {
+		t.Errorf("Expected the escrow's tenant to be recorded, got %+v", store.escrowTenants)
+	}
+}
+
+func TestReleaseAuthorizationExpiry(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{}
+	gateway, err := NewPaymentGateway(&config.Config{ReleaseAuthorizationTTL: time.Hour, MaxOperationAttempts: 3}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// Replaying a release nobody approved is refused
+	_, err = gateway.submitOperation(context.Background(), 7, opCompleteJob, database.OperationParams{JobID: 7})
+	if !errors.Is(err, errReleaseNotAuthorized) || len(chain.completed) != 0 {
+		t.Fatalf("Expected an unauthorized release to be refused, got %v and releases %v", err, chain.completed)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7", nil)
+	req.Header.Set("X-Actor", "client@example.com")
+	rec := httptest.NewRecorder()
+	gateway.completeJobHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(store.releaseAuths) != 1 {
+		t.Fatalf("Expected one authorization, got %+v", store.releaseAuths)
+	}
+	authorization := store.releaseAuths[0]
+	if authorization.Actor != "client@example.com" || authorization.TxHash == nil || *authorization.TxHash != "0xrelease7" {
+		t.Errorf("Expected the approval recorded with its release, got %+v", authorization)
+	}
+	if time.Until(authorization.ExpiresAt) <= 59*time.Minute {
+		t.Errorf("Expected the authorization to expire in an hour, got %s", authorization.ExpiresAt)
+	}
+
+	// The release failed and its retry waited out the approval
+	store.details[7].PaymentStatus = "release_failed"
+	authorization.ExpiresAt = time.Now().Add(-time.Minute)
+	op := &database.DeferredOperation{ID: 4, ApplicationID: 7, Operation: opCompleteJob, Params: database.OperationParams{JobID: 7}, Status: database.DeferredStatusDeferred, Deadline: time.Now().Add(time.Hour)}
+	store.deferred = []*database.DeferredOperation{op}
+	gateway.lapseReleaseAuthorizations(context.Background())
+	gateway.processDeferredOperations(context.Background())
+	if authorization.Status != database.ReleaseAuthorizationLapsed {
+		t.Errorf("Expected the authorization to lapse, got %s", authorization.Status)
+	}
+	if op.Status != database.DeferredStatusExpired || op.LastError == nil || !strings.Contains(*op.LastError, "approve the work again") {
+		t.Errorf("Expected the queued release to expire, got %s: %v", op.Status, op.LastError)
+	}
+	if len(chain.completed) != 1 {
+		t.Errorf("Expected no release after the approval lapsed, got %v", chain.completed)
+	}
+
+	statusReq := httptest.NewRequest(http.MethodGet, "/jobs/7/release-authorization", nil)
+	statusReq.SetPathValue("id", "7")
+	rec = httptest.NewRecorder()
+	gateway.getReleaseAuthorizationHandler(rec, statusReq)
+	var response ReleaseAuthorizationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Status != database.ReleaseAuthorizationLapsed || response.ApprovedBy != "client@example.com" || response.TxHash != "0xrelease7" {
+		t.Errorf("Unexpected authorization %+v", response)
+	}
+
+	// Approving again authorizes a new release
+	store.details[7].PaymentStatus = "deposited"
+	store.deferred = nil
+	rec = httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7", nil))
+	if rec.Code != http.StatusOK || len(chain.completed) != 2 {
+		t.Fatalf("Expected the re-approved release to be submitted, got %d: %s", rec.Code, rec.Body)
+	}
+	if latest := store.releaseAuths[1]; latest.Status != database.ReleaseAuthorizationActive || latest.Actor != "api" {
+		t.Errorf("Expected a new active authorization, got %+v", latest)
+	}
+
+	statusReq.SetPathValue("id", "8")
+	rec = httptest.NewRecorder()
+	gateway.getReleaseAuthorizationHandler(rec, statusReq)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a job never approved, got %d", rec.Code)
+	}
+}
+
+func TestWebhookTestDelivery(t *testing.T) {
+	var received []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(webhook.SignatureHeader) != webhook.Sign("secret", body) || r.Header.Get(webhook.TestHeader) != "true" {
+			t.Errorf("Expected a signed test delivery, got headers %v", r.Header)
+		}
+		received = append(received, r.URL.Path)
+		if r.URL.Path == "/job" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "no such job")
+		}
+	}))
+	defer endpoint.Close()
+
+	store := newTestStore()
+	store.webhooks = map[int32]string{7: endpoint.URL + "/job"}
+	gateway := newTestGateway(t, store, &config.Config{WebhookURL: endpoint.URL + "/global", WebhookSecret: "secret"})
+
+	send := func(body string) (*httptest.ResponseRecorder, WebhookTestResponse) {
+		rec := httptest.NewRecorder()
+		gateway.testWebhookHandler(rec, httptest.NewRequest(http.MethodPost, "/admin/webhooks/test", strings.NewReader(body)))
+		var response WebhookTestResponse
+		json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&response)
+		return rec, response
+	}
+
+	rec, response := send(`{"type": "transaction.confirmed"}`)
+	if rec.Code != http.StatusOK || !response.Delivered || response.StatusCode != http.StatusOK || response.Event.Type != events.TransactionConfirmed {
+		t.Fatalf("Expected the sample delivered, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// A job's own endpoint rejecting the event is reported, not an error
+	_, response = send(`{"type": "operation.deferred", "job_id": "7"}`)
+	if response.Delivered || response.StatusCode != http.StatusNotFound || response.ResponseBody != "no such job" {
+		t.Errorf("Expected the job endpoint's rejection reported, got %+v", response)
+	}
+	if !slices.Equal(received, []string{"/global", "/job"}) {
+		t.Errorf("Unexpected deliveries %v", received)
+	}
+
+	if rec, _ := send(`{"type": "job.unknown"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown event type, got %d", rec.Code)
+	}
+	if rec, _ := send(`{"type": "operation.deferred", "job_id": "8"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a job without a webhook, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.listWebhookEventsHandler(rec, httptest.NewRequest(http.MethodGet, "/webhooks/events", nil))
+	var catalog []events.Schema
+	if err := json.NewDecoder(rec.Body).Decode(&catalog); err != nil || len(catalog) != len(events.Types()) {
+		t.Errorf("Expected every event type listed, got %d: %v", len(catalog), err)
+	}
+}
+
+func TestContractErrorResponse(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{releaseErrs: map[uint64]error{7: errors.New("failed to estimate gas: execution reverted: OnlyClientCanMarkCompleted")}}
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", rec.Code, rec.Body)
+	}
+	var response ContractErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Code != payment.CodeOnlyClient || response.ContractError != "OnlyClientCanMarkCompleted" || !strings.Contains(response.Error, "only the job's client") {
+		t.Errorf("Unexpected response %+v", response)
+	}
+}
+
+func TestTraceIDs(t *testing.T) {
+	store := newTestStore()
+	notifier := recordingNotifier{deliveries: make(chan string, 4)}
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(notifier))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	handler := traceMutations(http.HandlerFunc(gateway.completeJobHandler))
+
+	// The platform's own ID is kept and follows the release into the database
+	req := httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7", nil)
+	req.Header.Set(trace.Header, "platform-req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get(trace.Header) != "platform-req-42" {
+		t.Fatalf("Expected the trace ID echoed, got %d with %q: %s", rec.Code, rec.Header().Get(trace.Header), rec.Body)
+	}
+	if change := store.changes[len(store.changes)-1]; change.Status != "release_initiated" || change.TraceID != "platform-req-42" {
+		t.Errorf("Expected the release recorded under the trace, got %+v", change)
+	}
+
+	// Its receipt and webhook carry the same trace
+	gateway.applyReceipt(context.Background(), database.InitiatedTransaction{ApplicationID: 7, PaymentStatus: "release_initiated", TxType: "release", TxHash: "0xrelease7", TraceID: "platform-req-42"}, &payment.ReceiptStatus{Mined: true, Success: true, BlockNumber: 12})
+	if change := store.changes[len(store.changes)-1]; change.Status != "released" || change.TraceID != "platform-req-42" {
+		t.Errorf("Expected the confirmation recorded under the trace, got %+v", change)
+	}
+	if got := <-notifier.deliveries; got != "global transaction.confirmed trace platform-req-42" {
+		t.Errorf("Expected the webhook to carry the trace, got %q", got)
+	}
+
+	// An invalid ID is replaced, and reads are not traced
+	req = httptest.NewRequest(http.MethodPost, "/complete-job?job_id=8", nil)
+	req.Header.Set(trace.Header, "not a valid id")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if id := rec.Header().Get(trace.Header); !trace.Valid(id) || id == "not a valid id" {
+		t.Errorf("Expected a generated trace ID, got %q", id)
+	}
+	rec = httptest.NewRecorder()
+	traceMutations(http.HandlerFunc(gateway.getJobStatusHandler)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/job-status?job_id=7", nil))
+	if id := rec.Header().Get(trace.Header); id != "" {
+		t.Errorf("Expected reads to be untraced, got %q", id)
+	}
+}
+
+func TestSettlementSummaries(t *testing.T) {
+	slackMessages := make(chan string, 4)
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message map[string]string
+		json.NewDecoder(r.Body).Decode(&message)
+		slackMessages <- message["text"]
+	}))
+	defer slack.Close()
+
+	now := time.Date(2025, 6, 5, 9, 30, 0, 0, time.UTC)
+	store := newTestStore()
+	store.settlements = []*database.SettlementSummary{{Day: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}}
+	notifier := recordingNotifier{deliveries: make(chan string, 4)}
+	gateway, err := NewPaymentGateway(&config.Config{SettlementSlackWebhookURL: slack.URL}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(notifier))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// The days since the last summary are caught up, but not today
+	built := gateway.summarizeSettlements(context.Background(), now)
+	if len(built) != 3 || built[0].Day.Format(time.DateOnly) != "2025-06-02" || built[2].Day.Format(time.DateOnly) != "2025-06-04" {
+		t.Fatalf("Expected summaries of June 2nd to 4th, got %+v", built)
+	}
+	for i := 0; i < 3; i++ {
+		if got := <-notifier.deliveries; got != "global settlement.summarized" {
+			t.Errorf("Expected a settlement webhook, got %q", got)
+		}
+	}
+	message := <-slackMessages
+	if !strings.Contains(message, "Settlement summary for 2025-06-02") || !strings.Contains(message, "Deposits: 1 ($100.00)") || !strings.Contains(message, "Net treasury movement: -0.0020 ETH") {
+		t.Errorf("Unexpected Slack message %q", message)
+	}
+	if again := gateway.summarizeSettlements(context.Background(), now); len(again) != 0 {
+		t.Errorf("Expected summarised days not to be built again, got %+v", again)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.settlementReportHandler(rec, httptest.NewRequest(http.MethodGet, "/reports/settlements?from=2025-06-02&to=2025-06-04", nil))
+	var report SettlementReportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.Summaries) != 3 || report.Summaries[0].DepositUSDDisplay != "$100.00" || report.Summaries[0].NetTreasuryETHDisplay != "-0.0020 ETH" {
+		t.Errorf("Unexpected settlement report %+v", report)
+	}
+
+	// Only a finished day can be rebuilt
+	req := httptest.NewRequest(http.MethodPost, "/admin/settlements/"+time.Now().UTC().Format(time.DateOnly), nil)
+	req.SetPathValue("day", time.Now().UTC().Format(time.DateOnly))
+	rec = httptest.NewRecorder()
+	gateway.rebuildSettlementSummaryHandler(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 rebuilding today, got %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/admin/settlements/2025-06-01", nil)
+	req.SetPathValue("day", "2025-06-01")
+	rec = httptest.NewRecorder()
+	gateway.rebuildSettlementSummaryHandler(rec, req)
+	if rec.Code != http.StatusOK || store.settlements[0].DepositCount != 1 || len(store.settlements) != 4 {
+		t.Errorf("Expected June 1st to be rebuilt in place, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestContractInfo(t *testing.T) {
+	feed := common.HexToAddress("0x694AA1769357215DE4FAC081bf1f309aDC325306")
+	implementationHash := common.HexToHash("0x02")
+	chain := &fakeChain{config: &payment.ContractConfig{
+		Address:     common.HexToAddress("0x00000000000000000000000000000000000000c0"),
+		BlockNumber: 1234,
+		Owner:       common.HexToAddress("0x00000000000000000000000000000000000000a0"),
+		FeePercent:  big.NewInt(5),
+		PriceFeed:   feed,
+		CodeHash:    common.HexToHash("0x01"),
+		CodeSize:    4096,
+		Proxy: &payment.ProxyInfo{
+			Address:        common.HexToAddress("0x00000000000000000000000000000000000000c0"),
+			Implementation: common.HexToAddress("0x00000000000000000000000000000000000000d0"),
+		},
+		ImplementationCodeHash: &implementationHash,
+	}}
+	cfg := &config.Config{NetworkID: 11155111, ETHUSDPriceFeed: feed.Hex(), ExpectedImplementation: "0x00000000000000000000000000000000000000e0"}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.contractInfoHandler(rec, httptest.NewRequest(http.MethodGet, "/contract-info", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response ContractConfigResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.SignerIsOwner || response.FeePercent != 5 || !response.PriceFeedMatches || response.BlockNumber != 1234 {
+		t.Errorf("Unexpected contract configuration %+v", response)
+	}
+	if response.Pausable || response.Paused != nil {
+		t.Errorf("Expected a contract without paused() to be reported unpausable, got %+v", response)
+	}
+	if !response.Proxy || response.ImplementationCodeHash != implementationHash.Hex() || response.ExpectedImplementation == nil || *response.ExpectedImplementation {
+		t.Errorf("Expected an unexpected proxy implementation to be reported, got %+v", response)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{}
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/complete-job", gateway.completeJobHandler)
+	mux.HandleFunc("/job-status", gateway.getJobStatusHandler)
+	mux.HandleFunc("PUT /admin/maintenance", gateway.startMaintenanceHandler)
+	mux.HandleFunc("DELETE /admin/maintenance", gateway.endMaintenanceHandler)
+	handler := gateway.readOnlyDuringMaintenance(mux)
+
+	expectedEnd := time.Now().Add(10 * time.Minute)
+	body, _ := json.Marshal(StartMaintenanceRequest{Reason: "key rotation", ExpectedEnd: &expectedEnd})
+	req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", bytes.NewReader(body))
+	req.Header.Set("X-Actor", "ops@example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || gateway.maintenance.Load() == nil {
+		t.Fatalf("Expected maintenance to start, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/maintenance", bytes.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 starting maintenance twice, got %d", rec.Code)
+	}
+
+	// Mutations are refused and reads still served
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected 503 with Retry-After, got %d: %s", rec.Code, rec.Body)
+	}
+	var refused MaintenanceResponse
+	if err := json.NewDecoder(rec.Body).Decode(&refused); err != nil || refused.Code != "maintenance" || refused.Reason != "key rotation" {
+		t.Errorf("Unexpected maintenance response %+v: %v", refused, err)
+	}
+	if len(chain.completed) != 0 {
+		t.Errorf("Expected no release during maintenance, got %v", chain.completed)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/job-status?job_id=7", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status reads during maintenance, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Background submissions wait for the window to close
+	store.deferred = []*database.DeferredOperation{{ID: 1, ApplicationID: 7, Operation: opCompleteJob, Params: database.OperationParams{JobID: 7}, Status: database.DeferredStatusDeferred, Deadline: time.Now().Add(time.Hour)}}
+	gateway.processDeferredOperations(context.Background())
+	if store.deferred[0].Status != database.DeferredStatusDeferred {
+		t.Errorf("Expected the deferred release to wait, got %s", store.deferred[0].Status)
+	}
+	if stop := gateway.autoRelease(7, database.ActorPlatform); stop == "" || len(chain.completed) != 0 {
+		t.Errorf("Expected approvals not to be released during maintenance, got %q", stop)
+	}
+	rec = httptest.NewRecorder()
+	gateway.healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil || health.Maintenance == nil || health.Maintenance.StartedBy != "ops@example.com" {
+		t.Errorf("Expected /health to report maintenance, got %+v: %v", health, err)
+	}
+	if snapshot := gateway.takeHealthSnapshot(context.Background(), nil); !snapshot.Maintenance {
+		t.Error("Expected health snapshots to record maintenance")
+	}
+
+	store.deferred = nil
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/maintenance", nil))
+	if rec.Code != http.StatusOK || gateway.maintenance.Load() != nil {
+		t.Fatalf("Expected maintenance to end, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected mutations after maintenance, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// A window opened on another replica is picked up on refresh
+	store.StartMaintenance(context.Background(), "migration", "ops", nil)
+	if err := gateway.refreshMaintenance(context.Background()); err != nil || gateway.maintenance.Load() == nil {
+		t.Errorf("Expected the refresh to enter maintenance, got %v", err)
+	}
+}
+
+func TestTaxExport(t *testing.T) {
+	store := newTestStore()
+	wallet := "0x00000000000000000000000000000000000000f1"
+	released := time.Date(2025, time.March, 4, 12, 0, 0, 0, time.UTC)
+	store.taxSummaries = []*database.FreelancerTaxSummary{
+		{UserID: 11, WalletAddress: &wallet, TotalUSD: 1500, ContractCount: 1, Payouts: []database.TaxPayout{
+			{ApplicationID: 7, JobID: 3, Kind: database.PayoutKindJob, USDAmount: 1000, TxHash: "0xaa", ReleasedAt: released},
+			{ApplicationID: 7, JobID: 3, Kind: database.PayoutKindTopUp, USDAmount: 500, TxHash: "0xbb", ReleasedAt: released.Add(time.Hour)},
+		}},
+		{UserID: 12, TotalUSD: 200, ContractCount: 1, Payouts: []database.TaxPayout{
+			{ApplicationID: 9, JobID: 4, Kind: database.PayoutKindRetainerPeriod, USDAmount: 200, TxHash: "0xcc", ReleasedAt: released},
+		}},
+	}
+	key := strings.Repeat("k", 32)
+	gateway, err := NewPaymentGateway(&config.Config{AdminAPIKeys: "finance:" + key}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/tax-exports/{year}", gateway.requireAdminKey(gateway.taxExportHandler))
+	mux.HandleFunc("GET /admin/tax-exports/{year}/freelancers/{user_id}", gateway.requireAdminKey(gateway.freelancerTaxExportHandler))
+	get := func(target, adminKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if adminKey != "" {
+			req.Header.Set(AdminKeyHeader, adminKey)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, adminKey := range []string{"", strings.Repeat("x", 32)} {
+		if rec := get("/admin/tax-exports/2025", adminKey); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 with admin key %q, got %d", adminKey, rec.Code)
+		}
+	}
+	if len(store.audit) != 0 {
+		t.Fatalf("Expected refused exports not to be audited, got %+v", store.audit)
+	}
+
+	rec := get("/admin/tax-exports/2025?reason=1099+filing", key)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var export TaxExportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&export); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if export.Year != 2025 || export.TotalUSD != 1700 || len(export.Freelancers) != 2 {
+		t.Fatalf("Unexpected export %+v", export)
+	}
+	first := export.Freelancers[0]
+	if first.TotalUSD != 1500 || first.ContractCount != 1 || first.PayoutCount != 2 || first.Payouts[1].TxHash != "0xbb" {
+		t.Errorf("Unexpected freelancer summary %+v", first)
+	}
+	if len(store.audit) != 1 || store.audit[0].Action != "tax_export.read" || store.audit[0].Actor != "finance" || store.audit[0].Reason != "1099 filing" {
+		t.Errorf("Expected the export to be audited, got %+v", store.audit)
+	}
+
+	rec = get("/admin/tax-exports/2025/freelancers/11?format=csv", key)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("Expected a CSV export, got %d: %s", rec.Code, rec.Body)
+	}
+	want := "year,user_id,wallet_address,total_usd,contract_count,payout_count,tx_hashes\n2025,11," + wallet + ",1500,1,2,0xaa;0xbb\n"
+	if rec.Body.String() != want {
+		t.Errorf("Expected CSV %q, got %q", want, rec.Body.String())
+	}
+	if len(store.audit) != 2 {
+		t.Errorf("Expected every export to be audited, got %d entries", len(store.audit))
+	}
+
+	if rec := get("/admin/tax-exports/2025/freelancers/99", key); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a freelancer without payouts, got %d", rec.Code)
+	}
+	if rec := get("/admin/tax-exports/1999", key); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid year, got %d", rec.Code)
+	}
+
+	// Without admin keys the exports don't exist
+	disabled, _ := NewPaymentGateway(&config.Config{}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	rec = httptest.NewRecorder()
+	disabled.requireAdminKey(disabled.taxExportHandler)(rec, httptest.NewRequest(http.MethodGet, "/admin/tax-exports/2025", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without ADMIN_API_KEYS, got %d", rec.Code)
+	}
+	if _, err := NewPaymentGateway(&config.Config{AdminAPIKeys: "finance:short"}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected a short admin key to be rejected")
+	}
+}
+
+func TestJobTags(t *testing.T) {
+	store := newTestStore()
+	store.details[9] = &database.ApplicationPaymentDetails{ApplicationID: 9, PaymentStatus: "deposited"}
+	gateway := newTestGateway(t, store, &config.Config{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs/{id}/tags", gateway.getJobTagsHandler)
+	mux.HandleFunc("PUT /jobs/{id}/tags", gateway.setJobTagsHandler)
+	mux.HandleFunc("DELETE /jobs/{id}/tags/{tag}", gateway.deleteJobTagHandler)
+	mux.HandleFunc("/graphql", gateway.graphqlHandler)
+	mux.HandleFunc("/reports/refunds", gateway.refundReportHandler)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPut, "/jobs/7/tags", `{"tags":["Urgent","design","design"]}`)
+	var tagged JobTagsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&tagged); err != nil || !slices.Equal(tagged.Tags, []string{"design", "urgent"}) {
+		t.Fatalf("Expected normalized tags, got %d %+v: %v", rec.Code, tagged, err)
+	}
+	if rec := do(http.MethodPut, "/jobs/7/tags", `{"tags":["two words"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid tag, got %d", rec.Code)
+	}
+	if !slices.Equal(store.jobTags[7], []string{"design", "urgent"}) {
+		t.Errorf("Expected an invalid update to keep the tags, got %v", store.jobTags[7])
+	}
+
+	// Only jobs carrying every tag in the filter are listed
+	rec = do(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ jobs(tag: "urgent,design") { applicationId tags } }`), "")
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"data":{"jobs":[{"applicationId":7,"tags":["design","urgent"]}]}}` {
+		t.Errorf("Unexpected filtered jobs %s", got)
+	}
+	rec = do(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ jobs(tag: "enterprise-client") { applicationId } }`), "")
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"data":{"jobs":[]}}` {
+		t.Errorf("Expected no jobs with an unused tag, got %s", got)
+	}
+	if rec := do(http.MethodGet, "/reports/refunds?tag=bad+tag", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid tag filter, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/reports/refunds?reason[gt]=other", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported report filter, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodDelete, "/jobs/7/tags/Urgent", ""); rec.Code != http.StatusOK || !slices.Equal(store.jobTags[7], []string{"design"}) {
+		t.Errorf("Expected the tag removed, got %d: %v", rec.Code, store.jobTags[7])
+	}
+	if rec := do(http.MethodDelete, "/jobs/7/tags/urgent", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 removing a missing tag, got %d", rec.Code)
+	}
+	rec = do(http.MethodGet, "/jobs/9/tags", "")
+	if strings.TrimSpace(rec.Body.String()) != `{"application_id":9,"tags":[]}` {
+		t.Errorf("Expected an untagged job to have no tags, got %s", rec.Body)
+	}
+}
+
+func TestFeeOverrides(t *testing.T) {
+	chain := &fakeChain{
+		jobs: map[uint64]*payment.JobDetails{7: {
+			Client:     common.HexToAddress("0x00000000000000000000000000000000000000c1"),
+			Freelancer: common.HexToAddress("0x00000000000000000000000000000000000000f1"),
+			USDAmount:  big.NewInt(250),
+			ETHAmount:  big.NewInt(83333333),
+		}},
+		deposits: map[uint64]*payment.Deposit{7: {TxHash: "0xdeposit", Value: big.NewInt(83333333)}},
+	}
+	financeKey, opsKey := strings.Repeat("f", 32), strings.Repeat("o", 32)
+	store := newTestStore()
+	gateway, err := NewPaymentGateway(&config.Config{FeePercentage: 5, ReserveFeeBPS: 2000, AdminAPIKeys: "finance:" + financeKey + ",ops:" + opsKey, FeeOverrideApprovers: "finance"},
+		WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	post := func(key, override string) *httptest.ResponseRecorder {
+		body := `{"job_id":7,"freelancer_address":"0x00000000000000000000000000000000000000f1","usd_amount":"250","client_address":"0x00000000000000000000000000000000000000c1"` + override + `}`
+		req := httptest.NewRequest(http.MethodPost, "/post-job", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(AdminKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		gateway.postJobHandler(rec, req)
+		return rec
+	}
+
+	for _, c := range []struct {
+		key, override string
+		expected      int
+	}{
+		{"", `,"platform_fee_bps":0,"fee_override_reason":"launch promotion"`, http.StatusUnauthorized},
+		{opsKey, `,"platform_fee_bps":0,"fee_override_reason":"launch promotion"`, http.StatusForbidden},
+		{financeKey, `,"platform_fee_bps":0`, http.StatusBadRequest},
+		{financeKey, `,"platform_fee_bps":600,"fee_override_reason":"raise"`, http.StatusBadRequest},
+		{financeKey, `,"fee_override_reason":"launch promotion"`, http.StatusBadRequest},
+	} {
+		if rec := post(c.key, c.override); rec.Code != c.expected {
+			t.Errorf("Expected %d for override %s, got %d: %s", c.expected, c.override, rec.Code, rec.Body)
+		}
+	}
+	if len(store.feeOverrides) != 0 {
+		t.Fatalf("Expected refused overrides to be left unset, got %+v", store.feeOverrides)
+	}
+
+	if rec := post(financeKey, `,"platform_fee_bps":0,"fee_override_reason":"launch promotion"`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for an approved override, got %d: %s", rec.Code, rec.Body)
+	}
+	if override := store.feeOverrides[7]; override.FeeBPS != 0 || override.Approver != "finance" || override.Reason != "launch promotion" {
+		t.Errorf("Expected the override stored with its approver and reason, got %+v", override)
+	}
+	if len(store.audit) != 1 || store.audit[0].Action != "fee_override.set" || store.audit[0].Actor != "finance" {
+		t.Errorf("Expected the override audited, got %+v", store.audit)
+	}
+
+	// The contract took its 5% on release; all of it is owed back and none accrues to the reserve
+	gateway.accrueReserve(context.Background(), 7, 7, "0xrelease")
+	if len(store.ledger) != 1 {
+		t.Fatalf("Expected only the fee override posted, got %+v", store.ledger)
+	}
+	posted := store.ledger[0]
+	if posted.Kind != ledger.KindFeeOverride || posted.Memo != "launch promotion" || posted.Actor != "finance" ||
+		!slices.Equal(posted.Entries, []database.LedgerEntry{{Account: ledger.AccountPlatformWallet, AmountWei: "4166666"}, {Account: ledger.AccountFeeRebates, AmountWei: "-4166666"}}) {
+		t.Errorf("Unexpected fee override posting %+v", posted)
+	}
+
+	// A partial override accrues the reserve from the fee the platform keeps
+	store.ledger = nil
+	store.feeOverrides[7] = database.FeeOverride{ApplicationID: 7, FeeBPS: 200, Reason: "volume discount", Approver: "finance"}
+	gateway.accrueReserve(context.Background(), 7, 7, "0xrelease")
+	if len(store.ledger) != 2 || store.ledger[0].Entries[0].AmountWei != "2500000" || store.ledger[1].Kind != ledger.KindFeeAccrual || store.ledger[1].Entries[0].AmountWei != "1666666" {
+		t.Errorf("Expected 2500000 wei owed back and 1666666 wei accrued, got %+v %+v", store.ledger[0], store.ledger[len(store.ledger)-1])
+	}
+
+	if _, err := NewPaymentGateway(&config.Config{AdminAPIKeys: "finance:" + financeKey, FeeOverrideApprovers: "treasury"}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected an approver without an admin key to be refused")
+	}
+}
+
+func TestGasWaste(t *testing.T) {
+	store := newTestStore()
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	// 50,000 gas at 20 Gwei is 0.001 ETH, $3 at $3,000
+	price := big.NewInt(20_000_000_000)
+	gateway.recordGasCost(context.Background(), 7, opCompleteJob, &payment.TransactionResult{TxHash: "0xreverted", GasUsed: 50_000, EffectiveGasPrice: price, Success: false})
+	gateway.recordGasCost(context.Background(), 7, opCompleteJob, &payment.TransactionResult{TxHash: "0xrelease7", GasUsed: 50_000, EffectiveGasPrice: price, Success: true})
+	if len(store.costs[7]) != 2 {
+		t.Fatalf("Expected both transactions' gas recorded, got %+v", store.costs[7])
+	}
+	if len(store.ledger) != 1 {
+		t.Fatalf("Expected only the reverted transaction posted as waste, got %+v", store.ledger)
+	}
+	posted := store.ledger[0]
+	if posted.Kind != ledger.KindGasWaste || posted.Reference != "0xreverted" || posted.Memo != ledger.WasteReverted ||
+		!slices.Equal(posted.Entries, []database.LedgerEntry{{Account: ledger.AccountGasWaste, AmountWei: "1000000000000000"}, {Account: ledger.AccountSignerWallet, AmountWei: "-1000000000000000"}}) {
+		t.Errorf("Unexpected gas waste posting %+v", posted)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.gasWasteReportHandler(rec, httptest.NewRequest(http.MethodGet, "/reports/gas-waste", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var report GasWasteReportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.Buckets) != 1 || report.Buckets[0].Reason != ledger.WasteReverted || report.Buckets[0].Operation != opCompleteJob || report.Buckets[0].CostUSD != "3.000000" {
+		t.Errorf("Expected one bucket of $3 wasted on a reverted release, got %+v", report.Buckets)
+	}
+
+	rec = httptest.NewRecorder()
+	gateway.gasWasteReportHandler(rec, httptest.NewRequest(http.MethodGet, "/reports/gas-waste?reason=other", nil))
+	report = GasWasteReportResponse{}
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusOK || len(report.Buckets) != 0 {
+		t.Errorf("Expected no buckets for another reason, got %d %+v", rec.Code, report.Buckets)
+	}
+	for _, query := range []string{"interval=year", "reason[gt]=reverted"} {
+		rec := httptest.NewRecorder()
+		gateway.gasWasteReportHandler(rec, httptest.NewRequest(http.MethodGet, "/reports/gas-waste?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestBroadcastResolver(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{nonce: 6}
+	gateway, err := NewPaymentGateway(&config.Config{RequiredConfirmations: 2}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	signer := chain.Address().Hex()
+	hash := func(n int) string { return common.BigToHash(big.NewInt(int64(n))).Hex() }
+	record := func(b database.Broadcast) {
+		b.Address = signer
+		store.RecordBroadcast(context.Background(), b)
+	}
+	mined := func(n int, success bool, confirmations uint64) {
+		if chain.receipts == nil {
+			chain.receipts = make(map[common.Hash]*payment.ReceiptStatus)
+		}
+		h := common.HexToHash(hash(n))
+		chain.receipts[h] = &payment.ReceiptStatus{TxHash: h, Mined: true, Success: success, BlockNumber: 100, GasUsed: 50_000, EffectiveGasPrice: big.NewInt(20_000_000_000), Confirmations: confirmations}
+	}
+
+	// Application 7's release was resent at nonce 3 with higher fees, and the
+	// gateway waits on the resend, but the first signing is mined
+	store.details[7].PaymentStatus, store.details[7].EscrowTxHashRelease = paymentstatus.ReleaseInitiated, strPtr(hash(2))
+	record(database.Broadcast{TxHash: hash(1), ApplicationID: 7, IntentKey: "complete_job:7", Operation: opCompleteJob, Nonce: 3})
+	record(database.Broadcast{TxHash: hash(2), ApplicationID: 7, IntentKey: "complete_job:7", Operation: opCompleteJob, Nonce: 3})
+	mined(1, true, 2)
+
+	// Application 8's stuck deposit at nonce 4 was cancelled
+	store.details[8].PaymentStatus, store.details[8].EscrowTxHashDeposit = paymentstatus.DepositInitiated, strPtr(hash(3))
+	record(database.Broadcast{TxHash: hash(3), ApplicationID: 8, IntentKey: "post_job:8", Operation: opPostJob, Nonce: 4})
+	record(database.Broadcast{TxHash: hash(4), ApplicationID: 8, IntentKey: "post_job:8", Operation: opCancelTransaction, Nonce: 4, Cancels: strPtr(hash(3))})
+	mined(4, true, 5)
+
+	// Nonce 5 isn't final yet, and nonce 6 isn't mined
+	record(database.Broadcast{TxHash: hash(5), ApplicationID: 7, IntentKey: "cancel_job:7", Operation: opCancelJob, Nonce: 5})
+	mined(5, false, 1)
+	record(database.Broadcast{TxHash: hash(6), ApplicationID: 7, IntentKey: "cancel_job:7", Operation: opCancelJob, Nonce: 6})
+
+	gateway.resolveBroadcasts(context.Background())
+	outcomes := map[string]string{}
+	for _, b := range store.broadcasts {
+		if b.Outcome != nil {
+			outcomes[b.TxHash] = *b.Outcome
+		}
+	}
+	want := map[string]string{hash(1): database.BroadcastMined, hash(2): database.BroadcastReplaced, hash(3): database.BroadcastReplaced, hash(4): database.BroadcastCancelled}
+	if !maps.Equal(outcomes, want) {
+		t.Fatalf("Expected outcomes %v, got %v", want, outcomes)
+	}
+
+	// The mined signing is followed and costed; the resend cost nothing
+	if details := store.details[7]; details.PaymentStatus != paymentstatus.ReleaseInitiated || *store.changes[0].TxHash != hash(1) {
+		t.Errorf("Expected application 7 to wait on the mined release, got %s and %+v", details.PaymentStatus, store.changes)
+	}
+	if costs := store.costs[7]; len(costs) != 1 || costs[0].TxHash != hash(1) || costs[0].Operation != opCompleteJob {
+		t.Errorf("Expected only the mined release costed, got %+v", costs)
+	}
+
+	// The cancel's gas is waste, and the deposit can be sent again
+	if store.details[8].PaymentStatus != paymentstatus.DepositFailed {
+		t.Errorf("Expected the cancelled deposit to have failed, got %s", store.details[8].PaymentStatus)
+	}
+	if costs := store.costs[8]; len(costs) != 1 || costs[0].Operation != opCancelTransaction {
+		t.Errorf("Expected the cancel transaction costed, got %+v", costs)
+	}
+	if len(store.ledger) != 1 || store.ledger[0].Reference != hash(4) || store.ledger[0].Memo != ledger.WasteCancelled {
+		t.Fatalf("Expected the cancel posted as waste, got %+v", store.ledger)
+	}
+
+	// Once confirmed, the reverted refund is costed and posted as waste, once
+	mined(5, false, 2)
+	gateway.resolveBroadcasts(context.Background())
+	gateway.resolveBroadcasts(context.Background())
+	if len(store.ledger) != 2 || store.ledger[1].Reference != hash(5) || store.ledger[1].Memo != ledger.WasteReverted {
+		t.Errorf("Expected the reverted refund posted as waste once, got %+v", store.ledger)
+	}
+	if b, _ := store.GetBroadcast(context.Background(), hash(6)); b.Outcome != nil {
+		t.Errorf("Expected nonce 6 left until it is mined, got %s", *b.Outcome)
+	}
+}
+
+func TestCancelTransactionHandler(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{}
+	gateway, err := NewPaymentGateway(&config.Config{}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/transactions/{hash}/cancel", gateway.cancelTransactionHandler)
+	cancel := func(hash string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/transactions/"+hash+"/cancel", nil))
+		return rec
+	}
+
+	stuck := common.BigToHash(big.NewInt(1)).Hex()
+	settled := common.BigToHash(big.NewInt(2)).Hex()
+	store.RecordBroadcast(context.Background(), database.Broadcast{TxHash: stuck, ApplicationID: 8, IntentKey: "post_job:8", Operation: opPostJob, Nonce: 4})
+	store.RecordBroadcast(context.Background(), database.Broadcast{TxHash: settled, ApplicationID: 7, IntentKey: "post_job:7", Operation: opPostJob, Nonce: 3})
+	store.ResolveBroadcast(context.Background(), settled, database.BroadcastMined)
+
+	for hash, code := range map[string]int{
+		"0x1234":                              http.StatusBadRequest,
+		common.BigToHash(big.NewInt(9)).Hex(): http.StatusNotFound,
+		settled:                               http.StatusConflict,
+	} {
+		if rec := cancel(hash); rec.Code != code {
+			t.Errorf("Expected %d cancelling %s, got %d: %s", code, hash, rec.Code, rec.Body)
+		}
+	}
+
+	chain.cancelErr = fmt.Errorf("%w: %s", payment.ErrNotPending, stuck)
+	if rec := cancel(stuck); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a mined transaction, got %d", rec.Code)
+	}
+	chain.cancelErr = nil
+	rec := cancel(stuck)
+	if rec.Code != http.StatusOK || len(chain.cancelledTxs) != 1 || chain.cancelledTxs[0].Hex() != stuck {
+		t.Fatalf("Expected the stuck transaction cancelled, got %d %s and %v", rec.Code, rec.Body, chain.cancelledTxs)
+	}
+	if !store.signedNonces[chain.Address().Hex()+":0"] {
+		t.Errorf("Expected the cancel's nonce recorded for the wallet monitor")
+	}
+
+	// Signing halted
+	gateway.setSigningHalt(&database.SigningHalt{Reason: "test", TrippedBy: "test", TrippedAt: time.Now()})
+	if rec := cancel(stuck); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while signing is halted, got %d", rec.Code)
+	}
+}
+
+func TestDisplayCurrencies(t *testing.T) {
+	store := newTestStore()
+	gateway := newTestGateway(t, store, &config.Config{FXRates: "PKR=278.45"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs/{id}/display-currencies", gateway.getDisplayCurrenciesHandler)
+	mux.HandleFunc("PUT /jobs/{id}/display-currencies", gateway.setDisplayCurrenciesHandler)
+	mux.HandleFunc("/job-status", gateway.getJobStatusHandler)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	for _, body := range []string{`{"freelancer":"rupees"}`, `{"client":"EUR"}`} {
+		if rec := do(http.MethodPut, "/jobs/7/display-currencies", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, rec.Code)
+		}
+	}
+	rec := do(http.MethodPut, "/jobs/7/display-currencies", `{"client":"usd","freelancer":"pkr"}`)
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != `{"application_id":7,"client":"USD","freelancer":"PKR"}` {
+		t.Fatalf("Expected normalized currencies, got %d %s", rec.Code, got)
+	}
+
+	rec = do(http.MethodGet, "/job-status?job_id=7", "")
+	var status JobStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.FreelancerDisplay == nil || status.FreelancerDisplay.Display != "PKR 69,612.50" || status.FreelancerDisplay.Amount != "69612.50" || status.FreelancerDisplay.RatePerUSD != "278.45" {
+		t.Errorf("Expected the freelancer's amount in rupees, got %+v", status.FreelancerDisplay)
+	}
+	if status.ClientDisplay == nil || status.ClientDisplay.Display != "$250.00" {
+		t.Errorf("Expected the client's amount in dollars, got %+v", status.ClientDisplay)
+	}
+
+	event := events.Transaction{ApplicationID: 7, Status: "completed"}
+	gateway.addDisplayAmounts(context.Background(), &event)
+	if event.FreelancerDisplay == nil || event.FreelancerDisplay.Currency != "PKR" {
+		t.Errorf("Expected the webhook to carry the rupee amount, got %+v", event.FreelancerDisplay)
+	}
+
+	// Without a provider only dollars can be chosen
+	plain := newTestGateway(t, newTestStore(), &config.Config{})
+	rec = httptest.NewRecorder()
+	plain.setDisplayCurrenciesHandler(rec, httptest.NewRequest(http.MethodPut, "/jobs/7/display-currencies", strings.NewReader(`{"freelancer":"PKR"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without exchange rates, got %d", rec.Code)
+	}
+	if _, err := NewPaymentGateway(&config.Config{FXRates: "PKR=lots"}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected invalid FX_RATES to be rejected")
+	}
+}
+
+func TestFundingLinks(t *testing.T) {
+	store := newTestStore()
+	amount := int32(250)
+	store.details[8].AgreedUSDAmount = &amount
+	store.details[8].ApplicantWalletAddress = strPtr("0x00000000000000000000000000000000000000f1")
+	store.details[8].PosterWalletAddress = strPtr("0x00000000000000000000000000000000000000c1")
+	store.details[9] = &database.ApplicationPaymentDetails{ApplicationID: 9, AgreedUSDAmount: &amount, PaymentStatus: "pending_deposit",
+		ApplicantWalletAddress: strPtr("0x00000000000000000000000000000000000000f2"), PosterWalletAddress: strPtr("0x00000000000000000000000000000000000000c2")}
+	chain := &fakeChain{contractAddress: common.HexToAddress("0x00000000000000000000000000000000000000e1"), jobs: map[uint64]*payment.JobDetails{}, deposits: map[uint64]*payment.Deposit{}, blockTimes: map[uint64]time.Time{}}
+	gateway, err := NewPaymentGateway(&config.Config{NetworkID: 11155111, FundingLinkTTL: time.Hour}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs/{id}/funding-link", gateway.createFundingLinkHandler)
+	mux.HandleFunc("GET /jobs/{id}/funding-link", gateway.getFundingLinkHandler)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/jobs/7/funding-link"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a deposited job, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/jobs/8/funding-link"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 before a link is generated, got %d", rec.Code)
+	}
+
+	rec := do(http.MethodPost, "/jobs/8/funding-link")
+	var link FundingLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&link); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("Expected a funding link, got %d: %v", rec.Code, err)
+	}
+	if link.ValueWei != "833333333" || link.QRPayload != link.PaymentURI || !strings.HasPrefix(link.PaymentURI, "ethereum:0x00000000000000000000000000000000000000e1@11155111/postJob?uint256=8&") ||
+		!strings.HasSuffix(link.PaymentURI, "&value=833333333") || !strings.HasPrefix(link.Calldata, "0x") || link.Status != database.FundingLinkOpen {
+		t.Errorf("Unexpected funding link %+v", link)
+	}
+	if !link.ExpiresAt.After(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("Expected the link to expire after FUNDING_LINK_TTL, got %s", link.ExpiresAt)
+	}
+	if rec := do(http.MethodPost, "/jobs/9/funding-link"); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected a second job's link, got %d", rec.Code)
+	}
+
+	// Job 8 is funded in time, job 9 after its link expired, and job 9's
+	// link is reported expired first
+	chain.jobs[8] = &payment.JobDetails{Client: common.HexToAddress("0xc1"), Freelancer: common.HexToAddress("0xf1"), USDAmount: big.NewInt(250)}
+	chain.deposits[8] = &payment.Deposit{TxHash: "0xclientdeposit", BlockNumber: 120, Value: big.NewInt(833333333)}
+	chain.blockTimes[120] = time.Now()
+	gateway.checkFundingLinks(context.Background(), time.Now().Add(2*time.Hour))
+	if store.details[8].PaymentStatus != "deposited" || store.fundingLinks[0].Status != database.FundingLinkFunded {
+		t.Errorf("Expected job 8 funded through its link, got %s and %s", store.details[8].PaymentStatus, store.fundingLinks[0].Status)
+	}
+	if store.fundingLinks[1].Status != database.FundingLinkExpired {
+		t.Errorf("Expected job 9's link to expire, got %s", store.fundingLinks[1].Status)
+	}
+
+	chain.jobs[9] = &payment.JobDetails{Client: common.HexToAddress("0xc2"), Freelancer: common.HexToAddress("0xf2"), USDAmount: big.NewInt(250)}
+	chain.deposits[9] = &payment.Deposit{TxHash: "0xlatedeposit", BlockNumber: 130, Value: big.NewInt(833333333)}
+	chain.blockTimes[130] = time.Now().Add(3 * time.Hour)
+	gateway.checkFundingLinks(context.Background(), time.Now().Add(3*time.Hour))
+	rec = do(http.MethodGet, "/jobs/9/funding-link")
+	if err := json.NewDecoder(rec.Body).Decode(&link); err != nil || link.Status != database.FundingLinkFundedLate || link.TxHash != "0xlatedeposit" || link.FundedAt == nil {
+		t.Errorf("Expected job 9 funded late, got %+v: %v", link, err)
+	}
+	if store.details[9].PaymentStatus != "deposited" {
+		t.Errorf("Expected the late deposit recorded, got %s", store.details[9].PaymentStatus)
+	}
+}
+
+func TestDisputes(t *testing.T) {
+	store := newTestStore()
+	store.events = map[int32][]database.PaymentEvent{7: {{ID: 1, ApplicationID: 7, Status: "deposited", Actor: database.ActorReconciler}}}
+	gateway := newTestGateway(t, store, &config.Config{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs/{id}/dispute", gateway.openDisputeHandler)
+	mux.HandleFunc("GET /jobs/{id}/dispute", gateway.getDisputeHandler)
+	mux.HandleFunc("POST /jobs/{id}/dispute/evidence", gateway.addDisputeEvidenceHandler)
+	mux.HandleFunc("POST /jobs/{id}/dispute/resolve", gateway.resolveDisputeHandler)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Actor", "arbitrator@example.com")
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/jobs/7/dispute/evidence", `{"kind":"deliverable","uri":"https://files.example/final.zip"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for evidence on an undisputed job, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/jobs/7/dispute", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a reason, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/jobs/7/dispute", `{"reason":"work not delivered"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected the dispute opened, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/jobs/7/dispute", `{"reason":"again"}`); rec.Code != http.StatusConflict || len(store.disputes) != 1 {
+		t.Errorf("Expected 409 for a second open dispute, got %d", rec.Code)
+	}
+
+	// Decisions for a party need evidence on record
+	if rec := do(http.MethodPost, "/jobs/7/dispute/resolve", `{"outcome":"refund","justification":"nothing delivered"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 deciding without evidence, got %d", rec.Code)
+	}
+
+	for _, body := range []string{
+		`{"kind":"deliverable"}`,
+		`{"kind":"video","uri":"https://files.example/a.mp4"}`,
+		`{"kind":"deliverable","uri":"final.zip"}`,
+		`{"kind":"deliverable","sha256":"abc"}`,
+	} {
+		if rec := do(http.MethodPost, "/jobs/7/dispute/evidence", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for evidence %s, got %d", body, rec.Code)
+		}
+	}
+	digest := strings.Repeat("AB", 32)
+	if rec := do(http.MethodPost, "/jobs/7/dispute/evidence", `{"kind":"deliverable","uri":"ipfs://bafybeigdyrzt","sha256":"0x`+digest+`"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected evidence attached, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/jobs/7/dispute/evidence", `{"kind":"chat_transcript","uri":"https://chat.example/threads/12","description":"client confirms receipt"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected evidence attached, got %d", rec.Code)
+	}
+	if got := *store.evidence[0].SHA256; got != strings.ToLower(digest) || store.evidence[0].SubmittedBy != "arbitrator@example.com" {
+		t.Errorf("Expected a normalized digest and the submitter recorded, got %s by %s", got, store.evidence[0].SubmittedBy)
+	}
+
+	rec := do(http.MethodGet, "/jobs/7/dispute", "")
+	var view DisputeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&view); err != nil {
+		t.Fatalf("Failed to decode dispute view: %v", err)
+	}
+	if view.Dispute.Reason != "work not delivered" || len(view.Evidence) != 2 || view.Evidence[1].Kind != "chat_transcript" {
+		t.Errorf("Expected the dispute with its evidence in order, got %+v", view)
+	}
+	if view.PaymentStatus != "deposited" || len(view.Timeline) != 1 {
+		t.Errorf("Expected the payment and its timeline, got %s with %d events", view.PaymentStatus, len(view.Timeline))
+	}
+
+	if rec := do(http.MethodPost, "/jobs/7/dispute/resolve", `{"outcome":"release"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a justification, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/jobs/7/dispute/resolve", `{"outcome":"release","justification":"client confirmed receipt in chat"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the dispute resolved, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/jobs/7/dispute/evidence", `{"kind":"other","uri":"https://files.example/late.pdf"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for evidence after the resolution, got %d", rec.Code)
+	}
+	rec = do(http.MethodGet, "/jobs/7/dispute", "")
+	if !strings.Contains(rec.Body.String(), `"justification":"client confirmed receipt in chat"`) {
+		t.Errorf("Expected the resolution in the dispute view, got %s", rec.Body)
+	}
+	if rec := do(http.MethodGet, "/jobs/8/dispute", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a job never disputed, got %d", rec.Code)
+	}
+}
+
+func TestWalletAnomalyKillSwitch(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{nonce: 5}
+	key := strings.Repeat("k", 32)
+	secondKey := strings.Repeat("s", 32)
+	gateway, err := NewPaymentGateway(&config.Config{AdminAPIKeys: "ops:" + key + ",security:" + secondKey, WalletAnomalyKillSwitch: true, KillSwitchApprovalWindow: time.Minute},
+		WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ctx := context.Background()
+	signer := chain.Address()
+	observed := make(map[common.Address]uint64)
+
+	// The first check only sets the baseline
+	gateway.checkWalletActivity(ctx, observed)
+	if store.watermarks[signer.Hex()] != 5 {
+		t.Fatalf("Expected the watermark at the current nonce, got %v", store.watermarks)
+	}
+
+	// The gateway's own transactions are not anomalies
+	if _, err := gateway.client.PostJob(ctx, 7, common.Address{}, big.NewInt(100), common.Address{}); err != nil {
+		t.Fatalf("Failed to post job: %v", err)
+	}
+	gateway.checkWalletActivity(ctx, observed)
+	gateway.checkWalletActivity(ctx, observed)
+	if len(store.audit) != 0 || gateway.signingHalt.Load() != nil {
+		t.Fatalf("Expected no anomaly for the gateway's transaction, got %+v", store.audit)
+	}
+
+	// Someone else signs two transactions with the key. They are reported one
+	// check after they are first seen, in case the gateway is still recording them.
+	chain.nonce += 2
+	gateway.checkWalletActivity(ctx, observed)
+	if len(store.audit) != 0 {
+		t.Fatalf("Expected the new nonces to get an interval's grace, got %+v", store.audit)
+	}
+	gateway.checkWalletActivity(ctx, observed)
+	if len(store.audit) != 1 || store.audit[0].Action != "wallet.anomaly" || !strings.Contains(store.audit[0].Reason, "2 transactions") {
+		t.Fatalf("Expected the foreign transactions audited, got %+v", store.audit)
+	}
+	if gateway.signingHalt.Load() == nil || store.watermarks[signer.Hex()] != 8 {
+		t.Fatalf("Expected signing halted and the watermark advanced, got %v", store.watermarks)
+	}
+
+	if _, err := gateway.client.MarkJobCompleted(ctx, 7); !errors.Is(err, errSigningHalted) || len(chain.completed) != 0 {
+		t.Fatalf("Expected nothing signed while halted, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	gateway.writeChainError(rec, "Failed to release payment", nil, fmt.Errorf("submitting: %w", errSigningHalted))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while halted, got %d", rec.Code)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/kill-switch", gateway.getKillSwitchHandler)
+	mux.HandleFunc("DELETE /admin/kill-switch", gateway.requireAdminKey(gateway.deactivateKillSwitchHandler))
+	do := func(method, target, adminKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if adminKey != "" {
+			req.Header.Set(AdminKeyHeader, adminKey)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := do(http.MethodGet, "/admin/kill-switch", "", ""); !strings.Contains(rec.Body.String(), `"tripped":true`) || !strings.Contains(rec.Body.String(), `"tripped_by":"wallet_monitor"`) {
+		t.Errorf("Expected the halt reported, got %s", rec.Body)
+	}
+	if rec := do(http.MethodDelete, "/admin/kill-switch", "", `{"reason":"rotated key"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 re-arming without an admin key, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/admin/kill-switch", key, `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 re-arming without a reason, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/admin/kill-switch", key, `{"reason":"rotated key"}`); rec.Code != http.StatusAccepted || gateway.signingHalt.Load() == nil {
+		t.Fatalf("Expected one admin key to only propose re-arming, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodDelete, "/admin/kill-switch", secondKey, `{"reason":"agreed"}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"rearmed_by":"ops,security"`) {
+		t.Fatalf("Expected signing re-armed by both admin keys' holders, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := gateway.client.MarkJobCompleted(ctx, 7); err != nil || len(chain.completed) != 1 {
+		t.Errorf("Expected signing to resume once re-armed, got %v", err)
+	}
+	if rec := do(http.MethodDelete, "/admin/kill-switch", key, `{"reason":"again"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 re-arming when not halted, got %d", rec.Code)
+	}
+}
+
+func TestKillSwitchTwoPersonRule(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{}
+	approverKey, _ := crypto.GenerateKey()
+	opsKey, secKey := strings.Repeat("o", 32), strings.Repeat("s", 32)
+	gateway, err := NewPaymentGateway(&config.Config{
+		AdminAPIKeys:             "ops:" + opsKey + ",security:" + secKey,
+		NetworkID:                11155111,
+		KillSwitchApprovers:      crypto.PubkeyToAddress(approverKey.PublicKey).Hex(),
+		KillSwitchApprovalWindow: 15 * time.Minute,
+	}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /admin/kill-switch", gateway.requireAdminKey(gateway.activateKillSwitchHandler))
+	mux.HandleFunc("DELETE /admin/kill-switch", gateway.requireAdminKey(gateway.deactivateKillSwitchHandler))
+	do := func(method, adminKey, body, approval string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/kill-switch", strings.NewReader(body))
+		req.Header.Set(AdminKeyHeader, adminKey)
+		if approval != "" {
+			req.Header.Set(killswitch.ApprovalHeader, approval)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Activating takes a second admin key; the first can't approve itself
+	if rec := do(http.MethodPut, opsKey, `{"reason":"suspected breach"}`, ""); rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"proposed_by":"ops"`) {
+		t.Fatalf("Expected the first key to propose the halt, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPut, opsKey, `{"reason":"suspected breach"}`, ""); rec.Code != http.StatusConflict || gateway.signingHalt.Load() != nil {
+		t.Errorf("Expected the same key approving twice to be refused, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, secKey, `{"reason":"confirmed"}`, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"tripped_by":"ops,security"`) {
+		t.Fatalf("Expected the second key to halt signing, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := gateway.client.PostJob(context.Background(), 1, common.Address{}, big.NewInt(100), common.Address{}); !errors.Is(err, errSigningHalted) {
+		t.Fatalf("Expected nothing signed once halted, got %v", err)
+	}
+	if rec := do(http.MethodPut, secKey, `{"reason":"again"}`, ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 activating while halted, got %d", rec.Code)
+	}
+
+	// Deactivating with one admin key and an approval signed for this halt
+	halt := gateway.signingHalt.Load()
+	sign := func(body string, key *ecdsa.PrivateKey) string {
+		sig, err := killswitch.Sign([]byte(body), key)
+		if err != nil {
+			t.Fatalf("Failed to sign approval: %v", err)
+		}
+		return sig
+	}
+	approval := func(haltID int64) string {
+		return fmt.Sprintf(`{"action":"deactivate","network_id":11155111,"halt_id":%d,"reason":"investigated","issued_at":%q}`, haltID, time.Now().UTC().Format(time.RFC3339))
+	}
+	stranger, _ := crypto.GenerateKey()
+	if rec := do(http.MethodDelete, opsKey, approval(halt.ID), sign(approval(halt.ID), stranger)); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an approval from an unknown key, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, opsKey, approval(halt.ID+1), sign(approval(halt.ID+1), approverKey)); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an approval of another halt, got %d", rec.Code)
+	}
+	body := approval(halt.ID)
+	rec := do(http.MethodDelete, opsKey, body, sign(body, approverKey))
+	if rec.Code != http.StatusOK || gateway.signingHalt.Load() != nil {
+		t.Fatalf("Expected the signed approval to re-arm signing, got %d: %s", rec.Code, rec.Body)
+	}
+	if rearmedBy := *store.signingHalts[0].RearmedBy; rearmedBy != "ops,"+crypto.PubkeyToAddress(approverKey.PublicKey).Hex() {
+		t.Errorf("Expected both approvers recorded, got %s", rearmedBy)
+	}
+	if rec := do(http.MethodDelete, opsKey, body, sign(body, approverKey)); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 replaying the approval when not halted, got %d", rec.Code)
+	}
+}
+
+func TestChainIntents(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{jobs: map[uint64]*payment.JobDetails{}, releaseErrs: map[uint64]error{}, blockTimes: map[uint64]time.Time{}, history: map[uint64][]payment.JobEvent{}}
+	gateway, err := NewPaymentGateway(&config.Config{IntentRecoveryGrace: 5 * time.Minute, MaxOperationAttempts: 3, RetryBackoff: time.Second}, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	release := func() error {
+		_, err := gateway.sendOperation(context.Background(), 7, opCompleteJob, database.OperationParams{JobID: 7})
+		return err
+	}
+
+	// The status change that records the release resolves its intent
+	if err := release(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if intent := store.intents[0]; intent.Outcome == nil || *intent.Outcome != database.IntentSent || derefString(intent.TxHash) != "0xrelease7" {
+		t.Errorf("Expected the intent resolved as sent, got %+v", intent)
+	}
+
+	// A timeout may have reached the node, so the intent waits for recovery
+	// and blocks another attempt meanwhile
+	chain.releaseErrs[7] = context.DeadlineExceeded
+	if err := release(); !errors.Is(err, context.DeadlineExceeded) || store.intents[1].Outcome != nil {
+		t.Fatalf("Expected the intent left unresolved after a timeout, got %v and %+v", err, store.intents[1])
+	}
+	delete(chain.releaseErrs, 7)
+	if err = release(); !errors.Is(err, database.ErrIntentPending) || len(chain.completed) != 1 {
+		t.Errorf("Expected a second release refused while the first is unresolved, got %v with %v released", err, chain.completed)
+	}
+	rec := httptest.NewRecorder()
+	gateway.writeChainError(rec, "Failed to complete job", nil, err)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an unresolved intent, got %d", rec.Code)
+	}
+
+	// The release was mined after all; the post of job 8 never left
+	now := time.Now()
+	store.intents[1].CreatedAt = now.Add(-10 * time.Minute)
+	chain.history[7] = []payment.JobEvent{{Kind: payment.JobEventPosted, TxHash: "0xdeposit", BlockNumber: 10}, {Kind: payment.JobEventReleased, TxHash: "0xlost", BlockNumber: 50}}
+	chain.blockTimes[10], chain.blockTimes[50] = now.Add(-24*time.Hour), now.Add(-9*time.Minute)
+	lost, _ := store.BeginChainIntent(context.Background(), 8, opPostJob, database.OperationParams{JobID: 8, USDAmount: "100"})
+	lost.CreatedAt = now.Add(-10 * time.Minute)
+	fresh, _ := store.BeginChainIntent(context.Background(), 8, opCancelJob, database.OperationParams{JobID: 8})
+
+	chain.mempool = 1
+	gateway.recoverChainIntents(context.Background(), now)
+	if store.intents[1].Outcome != nil || lost.Outcome != nil {
+		t.Fatalf("Expected recovery to wait while the signer has transactions in the mempool")
+	}
+
+	chain.mempool = 0
+	gateway.recoverChainIntents(context.Background(), now)
+	if intent := store.intents[1]; intent.Outcome == nil || *intent.Outcome != database.IntentRecovered || derefString(intent.TxHash) != "0xlost" {
+		t.Errorf("Expected the release recovered from the chain, got %+v", intent)
+	}
+	last := store.changes[len(store.changes)-1]
+	if last.ApplicationID != 7 || last.Status != "release_initiated" || derefString(last.TxHash) != "0xlost" {
+		t.Errorf("Expected the recovered release recorded as initiated, got %+v", last)
+	}
+	if lost.Outcome == nil || *lost.Outcome != database.IntentRequeued || len(store.deferred) != 1 || store.deferred[0].Operation != opPostJob || store.deferred[0].Params.USDAmount != "100" {
+		t.Errorf("Expected the unsent post requeued, got %+v and %+v", lost, store.deferred)
+	}
+	if fresh.Outcome != nil {
+		t.Errorf("Expected an intent within INTENT_RECOVERY_GRACE left alone, got %+v", fresh)
+	}
+	if len(chain.completed) != 1 || len(chain.posted) != 0 {
+		t.Errorf("Expected recovery to send nothing itself, got %v released and %v posted", chain.completed, chain.posted)
+	}
+}
+
+func TestCloneJob(t *testing.T) {
+	store := newTestStore()
+	store.jobTags = map[int32][]string{7: {"design"}}
+	store.displayCurrency = map[int32]database.DisplayCurrencies{7: {Freelancer: "PKR"}}
+	store.webhooks = map[int32]string{7: "https://platform.example/hooks/7"}
+	gateway := newTestGateway(t, store, &config.Config{FeePercentage: 5})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs/{id}/clone", gateway.cloneJobHandler)
+	do := func(target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do("/jobs/7/clone", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var clone CloneJobResponse
+	if err := json.NewDecoder(rec.Body).Decode(&clone); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if clone.JobID != 9 || clone.ClonedFrom != 7 || clone.USDAmount != 250 || clone.PaymentStatus != "pending_deposit" {
+		t.Errorf("Expected job 9 cloned from 7 at $250 awaiting its deposit, got %+v", clone)
+	}
+	if clone.FreelancerAddress != *store.details[7].ApplicantWalletAddress || clone.ClientAddress != *store.details[7].PosterWalletAddress {
+		t.Errorf("Expected the previous job's parties, got %s and %s", clone.ClientAddress, clone.FreelancerAddress)
+	}
+	if !slices.Equal(clone.Tags, []string{"design"}) || clone.DisplayCurrencies == nil || clone.DisplayCurrencies.Freelancer != "PKR" || clone.WebhookURL != "https://platform.example/hooks/7" {
+		t.Errorf("Expected the previous job's preferences, got %v, %+v, %q", clone.Tags, clone.DisplayCurrencies, clone.WebhookURL)
+	}
+	// $250 at $3,000 with a 5% fee, as postJob converts it
+	if clone.Quote.RequiredWei != "833333333" || clone.Quote.PlatformFeeWei != "41666666" || clone.Quote.Pricing != pricingSpot {
+		t.Errorf("Expected a spot quote for $250, got %+v", clone.Quote)
+	}
+
+	rec = do("/jobs/7/clone", `{"usd_amount":400}`)
+	if err := json.NewDecoder(rec.Body).Decode(&clone); err != nil || clone.JobID != 10 || clone.USDAmount != 400 || *store.details[10].AgreedUSDAmount != 400 {
+		t.Errorf("Expected job 10 agreed at $400, got %d %+v", rec.Code, clone)
+	}
+
+	for target, want := range map[string]int{
+		"/jobs/99/clone": http.StatusNotFound,
+		"/jobs/8/clone":  http.StatusConflict, // no wallets
+		"/jobs/x/clone":  http.StatusBadRequest,
+	} {
+		if rec := do(target, ""); rec.Code != want {
+			t.Errorf("Expected %d for %s, got %d", want, target, rec.Code)
+		}
+	}
+	if rec := do("/jobs/7/clone", `{"usd_amount":-1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative amount, got %d", rec.Code)
+	}
+}
+
+func TestPriorityLanes(t *testing.T) {
+	store := newTestStore()
+	store.details[7].ApplicantUserID = 42
+	store.payoutPrefs = map[int32]*database.PayoutPreference{42: {FreelancerUserID: 42, ThresholdUSD: 1000, Weekday: time.Friday, Hour: 17}}
+	chain := &fakeChain{}
+	gateway, err := NewPaymentGateway(&config.Config{SubmissionWorkers: 1, SubmissionQueueDepth: 0, DeferDeadline: time.Hour},
+		WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	complete := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7"+query, nil))
+		return rec
+	}
+
+	for _, query := range []string{"&priority=asap", "&priority=urgent&mode=economical"} {
+		if rec := complete(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+
+	// Bulk work holds every normal worker
+	busy, release := make(chan struct{}), make(chan struct{})
+	go gateway.submissions.Do(context.Background(), func() {
+		close(busy)
+		<-release
+	})
+	<-busy
+	defer close(release)
+
+	// Neither the saturated pool nor the freelancer's payout batching delays an urgent release
+	rec := complete("&priority=urgent")
+	if rec.Code != http.StatusOK || !slices.Equal(chain.completed, []uint64{7}) {
+		t.Fatalf("Expected the urgent release to be sent at once, got %d: %s", rec.Code, rec.Body)
+	}
+	if held := store.payoutHolds[7]; held != 0 {
+		t.Errorf("Expected the urgent release not to be held for batching, got a hold for freelancer %d", held)
+	}
+	if stats := gateway.submissions.Stats(); stats.Rejected != 0 {
+		t.Errorf("Expected the normal pool to be left alone, got %+v", stats)
+	}
+
+	params := database.OperationParams{JobID: 8, Priority: priorityUrgent}
+	if pool, ctx := gateway.lane(context.Background(), params); pool != gateway.urgent || ctx == context.Background() {
+		t.Error("Expected urgent operations on the urgent lane with their gas preset")
+	}
+	if pool, _ := gateway.lane(context.Background(), database.OperationParams{JobID: 8}); pool != gateway.submissions {
+		t.Error("Expected normal operations on the shared pool")
+	}
+}
+
+func TestFeeStrategies(t *testing.T) {
+	if _, err := NewPaymentGateway(&config.Config{ReleaseFeeStrategy: "turbo"}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected an unknown RELEASE_FEE_STRATEGY to be refused")
+	}
+
+	store := newTestStore()
+	chain := &fakeChain{}
+	cfg := &config.Config{FeeStrategy: "standard", ReleaseFeeStrategy: "fast", UrgentFeeStrategy: "fast"}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		operation string
+		params    database.OperationParams
+		want      payment.FeeStrategy
+	}{
+		{name: "deposit", operation: opPostJob, want: payment.FeeStandard},
+		{name: "refund", operation: opCancelJob, want: payment.FeeStandard},
+		{name: "release", operation: opCompleteJob, want: payment.FeeFast},
+		{name: "top-up release", operation: opTopUpRelease, want: payment.FeeFast},
+		{name: "urgent deposit", operation: opPostJob, params: database.OperationParams{Priority: priorityUrgent}, want: payment.FeeFast},
+		{name: "request override", operation: opCompleteJob, params: database.OperationParams{FeeStrategy: "slow"}, want: payment.FeeSlow},
+	}
+	for _, tt := range tests {
+		if got := gateway.feeStrategy(tt.operation, tt.params); got != tt.want {
+			t.Errorf("%s: fee strategy = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7&fee_strategy=turbo", nil))
+	if rec.Code != http.StatusBadRequest || len(chain.completed) != 0 {
+		t.Errorf("Expected an unknown fee_strategy to get 400 without a release, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	gateway.completeJobHandler(rec, httptest.NewRequest(http.MethodPost, "/complete-job?job_id=7&fee_strategy=slow", nil))
+	if rec.Code != http.StatusOK || !slices.Equal(chain.completed, []uint64{7}) {
+		t.Errorf("Expected the release to be sent, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCongestion(t *testing.T) {
+	store := newTestStore()
+	chain := &fakeChain{
+		block:      100,
+		blockGas:   &payment.BlockGas{Number: 100, BaseFee: big.NewInt(20e9), GasUsed: 15_000_000, GasLimit: 30_000_000},
+		pendingTxs: 40,
+	}
+	cfg := &config.Config{CongestionBaseFee: 50, CongestionGasUsedPercent: 95, CongestionPendingTxs: 100, CongestionInclusionTime: 2 * time.Minute}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(store), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	handler := gateway.warnOfCongestion(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	warning := func(method string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/complete-job?job_id=7", nil))
+		return rec.Header().Get(congestionHeader)
+	}
+
+	health := gateway.checkCongestion(context.Background())
+	if health == nil || health.Congested || health.GasUsedPercent != 50 || health.BaseFeeWei != "20000000000" || *health.PendingTransactions != 40 || health.MedianInclusionSeconds != nil {
+		t.Fatalf("Expected a quiet network, got %+v", health)
+	}
+	gateway.congestion.Store(health)
+	if got := warning(http.MethodPost); got != "" {
+		t.Errorf("Expected no warning on a quiet network, got %q", got)
+	}
+
+	// Full blocks and slow inclusions of the gateway's own transactions
+	chain.blockGas.GasUsed = 29_000_000
+	for _, took := range []time.Duration{time.Minute, 3 * time.Minute, 4 * time.Minute} {
+		gateway.recordInclusion(took)
+	}
+	health = gateway.checkCongestion(context.Background())
+	if !health.Congested || len(health.Reasons) != 2 || health.RecentInclusions != 3 || *health.MedianInclusionSeconds != 180 {
+		t.Fatalf("Expected full blocks and slow inclusions to count as congestion, got %+v", health)
+	}
+	gateway.congestion.Store(health)
+	if got := warning(http.MethodPost); !strings.HasPrefix(got, "slow; block 100 used 97% of its gas limit") {
+		t.Errorf("Expected a congestion warning on mutations, got %q", got)
+	}
+	if got := warning(http.MethodGet); got != "" {
+		t.Errorf("Expected no warning on reads, got %q", got)
+	}
+	if _, n := gateway.inclusions.median(time.Now().Add(inclusionWindow + time.Minute)); n != 0 {
+		t.Errorf("Expected inclusions older than the window to be ignored, got %d", n)
+	}
+
+	// Congestion is detail; only unreachable dependencies make the gateway unready
+	rec := httptest.NewRecorder()
+	gateway.readyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var ready ReadinessResponse
+	json.NewDecoder(rec.Body).Decode(&ready)
+	if rec.Code != http.StatusOK || ready.Status != "ready" || ready.Congestion == nil || !ready.Congestion.Congested {
+		t.Errorf("Expected ready with congestion detail, got %d: %+v", rec.Code, ready)
+	}
+	store.pingErr = errors.New("connection refused")
+	rec = httptest.NewRecorder()
+	gateway.readyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "connection refused") {
+		t.Errorf("Expected 503 with the database down, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCanary(t *testing.T) {
+	chain := &fakeChain{block: 100, nonce: 7, mempool: 2}
+	cfg := &config.Config{CanaryMode: canaryTransfer, CanaryRetryInterval: time.Minute}
+	gateway, err := NewPaymentGateway(cfg, WithChainClient(chain), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{}))
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	ready := func() (int, ReadinessResponse) {
+		rec := httptest.NewRecorder()
+		gateway.readyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var response ReadinessResponse
+		json.NewDecoder(rec.Body).Decode(&response)
+		return rec.Code, response
+	}
+
+	if code, response := ready(); code != http.StatusServiceUnavailable || response.Checks["canary"] != "not run yet" {
+		t.Errorf("Expected unready before the first canary, got %d: %+v", code, response)
+	}
+
+	// Transactions stuck in the pool would hold the canary behind them
+	health := gateway.checkCanary(context.Background())
+	if health.Status != canaryFailed || health.Nonce != 7 || health.PendingNonce != 9 || health.Sent || !strings.Contains(health.Error, "2 transactions") {
+		t.Fatalf("Expected stuck transactions to fail the canary, got %+v", health)
+	}
+	gateway.canary.Store(health)
+	if code, response := ready(); code != http.StatusServiceUnavailable || response.Checks["canary"] != health.Error {
+		t.Errorf("Expected unready after a failed canary, got %d: %+v", code, response)
+	}
+
+	chain.mempool = 0
+	health = gateway.checkCanary(context.Background())
+	if health.Status != canaryPassed || !health.Sent || health.TxHash != "0xcanary" || chain.nonce != 8 {
+		t.Fatalf("Expected the canary to pass and send a transfer, got %+v", health)
+	}
+	gateway.canary.Store(health)
+	if code, response := ready(); code != http.StatusOK || response.Checks["canary"] != "ok" || response.Canary == nil {
+		t.Errorf("Expected ready after the canary passed, got %d: %+v", code, response)
+	}
+
+	// Estimate mode tolerates pending transactions but not a signer the node refuses
+	gateway.config.CanaryMode = canaryEstimate
+	chain.mempool = 2
+	if health := gateway.checkCanary(context.Background()); health.Status != canaryPassed || health.Sent || chain.nonce != 8 {
+		t.Errorf("Expected the estimate canary to pass without sending, got %+v", health)
+	}
+	chain.canaryErr = errors.New("canary transfer was signed by 0x1, not the gateway account 0x2")
+	if health := gateway.checkCanary(context.Background()); health.Status != canaryFailed || health.Error != chain.canaryErr.Error() {
+		t.Errorf("Expected a signing failure to fail the canary, got %+v", health)
+	}
+
+	if _, err := NewPaymentGateway(&config.Config{CanaryMode: "always"}, WithChainClient(&fakeChain{}), WithOracle(fakeOracle{}), WithStore(newTestStore()), WithNotifier(fakeNotifier{})); err == nil {
+		t.Error("Expected an unknown CANARY_MODE to be refused")
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/graphql"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/tags"
+)
+
+const (
+	defaultGraphQLLimit = 100
+	maxGraphQLLimit     = 1000
+
+	// maxGraphQLBodyBytes bounds a POSTed query and its variables
+	maxGraphQLBodyBytes = 64 << 10
+)
+
+// GET|POST /graphql - Jobs, payment events, ledger transactions and prices in one query
+func (pg *PaymentGateway) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.GraphQL) {
+		return
+	}
+
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query, req.OperationName = query.Get("query"), query.Get("operationName")
+		if raw := query.Get("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				writeGraphQLError(w, "Invalid variables")
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLBodyBytes))
+		if err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeGraphQLError(w, "Invalid JSON")
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Query == "" {
+		writeGraphQLError(w, "query is required")
+		return
+	}
+
+	prepared, err := pg.schema.Prepare(req)
+	if err != nil {
+		writeGraphQLError(w, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prepared.Run(ctx))
+}
+
+// writeGraphQLError rejects a request that can't be executed, in the
+// response shape GraphQL clients expect
+func writeGraphQLError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(graphql.Response{Errors: []graphql.Error{{Message: message}}})
+}
+
+// graphqlLimit reads a list field's limit argument
+func graphqlLimit(args graphql.Args) (int, error) {
+	limit := args.Int("limit", defaultGraphQLLimit)
+	if limit < 1 || limit > maxGraphQLLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxGraphQLLimit)
+	}
+	return limit, nil
+}
+
+// graphqlPage reads a paged list field's limit and after arguments, after
+// being the cursor of the last item of the previous page, the same as a list
+// endpoint's limit and cursor
+func graphqlPage(spec listquery.Spec, args graphql.Args) (listquery.Params, error) {
+	query := url.Values{}
+	if args.Has("limit") {
+		query.Set("limit", strconv.Itoa(args.Int("limit", 0)))
+	}
+	if args.Has("after") {
+		query.Set("cursor", args.String("after"))
+	}
+	return spec.Parse(query)
+}
+
+// graphqlSince reads an RFC3339 since argument; without one every row matches
+func graphqlSince(args graphql.Args) (time.Time, error) {
+	if !args.Has("since") {
+		return time.Time{}, nil
+	}
+	since, err := time.Parse(time.RFC3339, args.String("since"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since, expected RFC3339")
+	}
+	return since, nil
+}
+
+// field resolves a scalar from a source of type T
+func field[T any](get func(T) interface{}) *graphql.Field {
+	return &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return get(source.(T)), nil
+	}}
+}
+
+// graphqlSchema builds the read-only schema /graphql serves. Nested lists
+// are looked up per parent, so a large jobs list with events selected costs
+// one query per job.
+func (pg *PaymentGateway) graphqlSchema() *graphql.Schema {
+	job := &graphql.Object{Name: "Job"}
+	event := &graphql.Object{Name: "PaymentEvent"}
+	ledgerTransaction := &graphql.Object{Name: "LedgerTransaction"}
+	ledgerEntry := &graphql.Object{Name: "LedgerEntry"}
+	ledgerBalance := &graphql.Object{Name: "LedgerBalance"}
+	gasCost := &graphql.Object{Name: "GasCost"}
+	price := &graphql.Object{Name: "PriceRound"}
+
+	// jobByID resolves a job or nil if there is no such application
+	jobByID := func(ctx context.Context, applicationID int32) (interface{}, error) {
+		details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return details, err
+	}
+
+	ledgerArgs := map[string]graphql.ArgType{
+		"kind":    graphql.String,
+		"account": graphql.String,
+		"since":   graphql.String,
+		"limit":   graphql.Int,
+	}
+	findLedger := func(ctx context.Context, applicationID int32, args graphql.Args) (interface{}, error) {
+		limit, err := graphqlLimit(args)
+		if err != nil {
+			return nil, err
+		}
+		since, err := graphqlSince(args)
+		if err != nil {
+			return nil, err
+		}
+		return pg.db.FindLedgerTransactions(ctx, database.LedgerFilter{
+			Kind:          args.String("kind"),
+			ApplicationID: applicationID,
+			Account:       args.String("account"),
+			Since:         since,
+			Limit:         limit,
+		})
+	}
+
+	// Each job and event carries the cursor of the page after it, for the
+	// after argument of jobs and events
+	jobsPage, _ := database.ApplicationListSpec.Parse(nil)
+	eventsPage, _ := database.PaymentEventListSpec.Parse(nil)
+
+	type details = *database.ApplicationPaymentDetails
+	job.Fields = map[string]*graphql.Field{
+		"cursor": field(func(d details) interface{} {
+			return jobsPage.Cursor(int64(d.ApplicationID), int64(d.ApplicationID))
+		}),
+		"applicationId":     field(func(d details) interface{} { return d.ApplicationID }),
+		"jobId":             field(func(d details) interface{} { return d.JobID }),
+		"freelancerUserId":  field(func(d details) interface{} { return d.ApplicantUserID }),
+		"clientUserId":      field(func(d details) interface{} { return d.PosterUserID }),
+		"freelancerAddress": field(func(d details) interface{} { return d.ApplicantWalletAddress }),
+		"clientAddress":     field(func(d details) interface{} { return d.PosterWalletAddress }),
+		"usdAmount":         field(func(d details) interface{} { return d.AgreedUSDAmount }),
+		"paymentStatus":     field(func(d details) interface{} { return d.PaymentStatus }),
+		"applicationStatus": field(func(d details) interface{} { return d.ApplicationStatus }),
+		"escrowJobId":       field(func(d details) interface{} { return d.EscrowJobID }),
+		"txHashDeposit":     field(func(d details) interface{} { return d.EscrowTxHashDeposit }),
+		"txHashRelease":     field(func(d details) interface{} { return d.EscrowTxHashRelease }),
+		"txHashRefund":      field(func(d details) interface{} { return d.EscrowTxHashRefund }),
+		"events": {
+			Type: event,
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return pg.db.GetPaymentEvents(ctx, source.(details).ApplicationID)
+			},
+		},
+		"ledgerTransactions": {
+			Type: ledgerTransaction,
+			Args: ledgerArgs,
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return findLedger(ctx, source.(details).ApplicationID, args)
+			},
+		},
+		"tags": {
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return pg.db.GetJobTags(ctx, source.(details).ApplicationID)
+			},
+		},
+		"gasCost": {
+			Type: gasCost,
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return pg.db.GetJobGasCost(ctx, source.(details).ApplicationID)
+			},
+		},
+	}
+
+	event.Fields = map[string]*graphql.Field{
+		"cursor":        field(func(e database.PaymentEvent) interface{} { return eventsPage.Cursor(e.ID, e.ID) }),
+		"id":            field(func(e database.PaymentEvent) interface{} { return e.ID }),
+		"applicationId": field(func(e database.PaymentEvent) interface{} { return e.ApplicationID }),
+		"status":        field(func(e database.PaymentEvent) interface{} { return e.Status }),
+		"txHash":        field(func(e database.PaymentEvent) interface{} { return e.TxHash }),
+		"blockNumber":   field(func(e database.PaymentEvent) interface{} { return e.BlockNumber }),
+		"actor":         field(func(e database.PaymentEvent) interface{} { return e.Actor }),
+		"createdAt":     field(func(e database.PaymentEvent) interface{} { return e.CreatedAt }),
+		"job": {
+			Type: job,
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return jobByID(ctx, source.(database.PaymentEvent).ApplicationID)
+			},
+		},
+	}
+
+	type transaction = *database.LedgerTransaction
+	ledgerTransaction.Fields = map[string]*graphql.Field{
+		"id":            field(func(t transaction) interface{} { return t.ID }),
+		"kind":          field(func(t transaction) interface{} { return t.Kind }),
+		"reference":     field(func(t transaction) interface{} { return t.Reference }),
+		"applicationId": field(func(t transaction) interface{} { return t.ApplicationID }),
+		"counterparty":  field(func(t transaction) interface{} { return t.Counterparty }),
+		"memo":          field(func(t transaction) interface{} { return t.Memo }),
+		"actor":         field(func(t transaction) interface{} { return t.Actor }),
+		"createdAt":     field(func(t transaction) interface{} { return t.CreatedAt }),
+		"entries": {
+			Type: ledgerEntry,
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return source.(transaction).Entries, nil
+			},
+		},
+		"job": {
+			Type: job,
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				if id := source.(transaction).ApplicationID; id != nil {
+					return jobByID(ctx, *id)
+				}
+				return nil, nil
+			},
+		},
+	}
+
+	ledgerEntry.Fields = map[string]*graphql.Field{
+		"account":   field(func(e database.LedgerEntry) interface{} { return e.Account }),
+		"amountWei": field(func(e database.LedgerEntry) interface{} { return e.AmountWei }),
+	}
+	ledgerBalance.Fields = map[string]*graphql.Field{
+		"account":    field(func(b [2]string) interface{} { return b[0] }),
+		"balanceWei": field(func(b [2]string) interface{} { return b[1] }),
+	}
+
+	gasCost.Fields = map[string]*graphql.Field{
+		"transactions": field(func(c *database.JobGasCost) interface{} { return c.Transactions }),
+		"gasUsed":      field(func(c *database.JobGasCost) interface{} { return c.GasUsed }),
+		"costWei":      field(func(c *database.JobGasCost) interface{} { return c.CostWei }),
+		"costUsd":      field(func(c *database.JobGasCost) interface{} { return c.CostUSD }),
+	}
+
+	price.Fields = map[string]*graphql.Field{
+		"roundId":     field(func(p *oracle.RoundData) interface{} { return p.RoundID.String() }),
+		"ethUsdPrice": field(func(p *oracle.RoundData) interface{} { return p.Answer.String() }),
+		"ethUsd": field(func(p *oracle.RoundData) interface{} {
+			return amounts.Decimal(amounts.PriceUSD(p.Answer), 2, amounts.HalfUp)
+		}),
+		"startedAt": field(func(p *oracle.RoundData) interface{} { return p.StartedAt }),
+		"updatedAt": field(func(p *oracle.RoundData) interface{} { return p.UpdatedAt }),
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"job": {
+			Type: job,
+			Args: map[string]graphql.ArgType{"id": graphql.NonNull(graphql.Int)},
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return jobByID(ctx, int32(args.Int("id", 0)))
+			},
+		},
+		"jobs": {
+			Type: job,
+			Args: map[string]graphql.ArgType{
+				"paymentStatus":     graphql.String,
+				"applicationStatus": graphql.String,
+				"freelancerUserId":  graphql.Int,
+				"clientUserId":      graphql.Int,
+				"tag":               graphql.String,
+				"after":             graphql.String,
+				"limit":             graphql.Int,
+			},
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				page, err := graphqlPage(database.ApplicationListSpec, args)
+				if err != nil {
+					return nil, err
+				}
+				tagFilter, err := tags.ParseFilter([]string{args.String("tag")})
+				if err != nil {
+					return nil, err
+				}
+				var status paymentstatus.Status
+				if raw := args.String("paymentStatus"); raw != "" {
+					if status, err = paymentstatus.Parse(raw); err != nil {
+						return nil, err
+					}
+				}
+				jobs, _, err := pg.db.ListApplications(ctx, database.ApplicationFilter{
+					PaymentStatus:     status,
+					ApplicationStatus: args.String("applicationStatus"),
+					ApplicantUserID:   int32(args.Int("freelancerUserId", 0)),
+					PosterUserID:      int32(args.Int("clientUserId", 0)),
+					Tags:              tagFilter,
+					Page:              page,
+				})
+				return jobs, err
+			},
+		},
+		"events": {
+			Type: event,
+			Args: map[string]graphql.ArgType{
+				"applicationId": graphql.Int,
+				"status":        graphql.String,
+				"actor":         graphql.String,
+				"since":         graphql.String,
+				"after":         graphql.String,
+				"limit":         graphql.Int,
+			},
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				page, err := graphqlPage(database.PaymentEventListSpec, args)
+				if err != nil {
+					return nil, err
+				}
+				since, err := graphqlSince(args)
+				if err != nil {
+					return nil, err
+				}
+				events, _, err := pg.db.ListPaymentEvents(ctx, database.PaymentEventFilter{
+					ApplicationID: int32(args.Int("applicationId", 0)),
+					Status:        args.String("status"),
+					Actor:         args.String("actor"),
+					Since:         since,
+					Page:          page,
+				})
+				return events, err
+			},
+		},
+		"ledgerTransactions": {
+			Type: ledgerTransaction,
+			Args: map[string]graphql.ArgType{
+				"kind":          graphql.String,
+				"applicationId": graphql.Int,
+				"account":       graphql.String,
+				"since":         graphql.String,
+				"limit":         graphql.Int,
+			},
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				return findLedger(ctx, int32(args.Int("applicationId", 0)), args)
+			},
+		},
+		"ledgerBalances": {
+			Type: ledgerBalance,
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				balances, err := pg.db.GetLedgerBalances(ctx)
+				if err != nil {
+					return nil, err
+				}
+				accounts := make([][2]string, 0, len(balances))
+				for account, balance := range balances {
+					accounts = append(accounts, [2]string{account, balance})
+				}
+				sort.Slice(accounts, func(i, j int) bool { return accounts[i][0] < accounts[j][0] })
+				return accounts, nil
+			},
+		},
+		"price": {
+			Type: price,
+			Args: map[string]graphql.ArgType{"atBlock": graphql.Int, "atTime": graphql.String},
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				switch {
+				case args.Has("atBlock") && args.Has("atTime"):
+					return nil, fmt.Errorf("specify atBlock or atTime, not both")
+				case args.Has("atBlock"):
+					block := args.Int("atBlock", 0)
+					if block < 0 {
+						return nil, fmt.Errorf("invalid atBlock")
+					}
+					return pg.oracle.PriceRoundAtBlock(ctx, uint64(block))
+				case args.Has("atTime"):
+					at, err := time.Parse(time.RFC3339, args.String("atTime"))
+					if err != nil {
+						return nil, fmt.Errorf("invalid atTime, expected RFC3339")
+					}
+					return pg.oracle.PriceRoundAtTime(ctx, at)
+				}
+				return pg.oracle.LatestPriceRound(ctx)
+			},
+		},
+		"priceRounds": {
+			Type: price,
+			Args: map[string]graphql.ArgType{"last": graphql.Int},
+			Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+				last := args.Int("last", pg.config.TWAPRounds)
+				if last < 1 || last > maxTWAPRounds {
+					return nil, fmt.Errorf("last must be between 1 and %d", maxTWAPRounds)
+				}
+				return pg.oracle.RecentPriceRounds(ctx, last)
+			},
+		},
+	}}
+
+	return &graphql.Schema{Query: query}
+}
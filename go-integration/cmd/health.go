@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/workpool"
+)
+
+// HealthResponse reports liveness, which contract the gateway is using, how
+// stale the price feed is, whether the gateway is in maintenance or has
+// halted signing, how loaded the transaction submission pool is, whether the
+// network is congested, how the startup canary went, how clients are using
+// their connections, and which RPC providers are answering
+type HealthResponse struct {
+	Status      string                      `json:"status"`
+	Contract    *ContractInfoResponse       `json:"contract,omitempty"`     // absent until the first proxy check
+	PriceFeed   *PriceFeedHealth            `json:"price_feed,omitempty"`   // absent until the first heartbeat check
+	Maintenance *database.MaintenanceWindow `json:"maintenance,omitempty"`  // present while the gateway is read-only
+	SigningHalt *database.SigningHalt       `json:"signing_halt,omitempty"` // present while the kill switch is tripped
+	Submissions workpool.Stats              `json:"submissions"`
+	Urgent      workpool.Stats              `json:"urgent_submissions"`
+	Congestion  *CongestionHealth           `json:"congestion,omitempty"`    // absent until the first congestion check
+	Canary      *CanaryHealth               `json:"canary,omitempty"`        // absent until the first canary
+	Connections *ConnectionStats            `json:"connections,omitempty"`   // absent when not serving through newHTTPServer
+	RPC         []payment.RPCProviderStatus `json:"rpc_providers,omitempty"` // absent without ETHEREUM_RPC_FALLBACK_URLS
+}
+
+// GET /health - Liveness, contract addresses, price feed staleness, maintenance, kill switch, submission load, congestion, canary, connections and RPC providers
+func (pg *PaymentGateway) healthHandler(w http.ResponseWriter, r *http.Request) {
+	var connections *ConnectionStats
+	if pg.connections != nil {
+		stats := pg.connections.Stats()
+		connections = &stats
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HealthResponse{
+		Status:      "ok",
+		Contract:    pg.contract.Load(),
+		PriceFeed:   pg.priceFeed.Load(),
+		Maintenance: pg.maintenance.Load(),
+		SigningHalt: pg.signingHalt.Load(),
+		Submissions: pg.submissions.Stats(),
+		Urgent:      pg.urgent.Stats(),
+		Congestion:  pg.congestion.Load(),
+		Canary:      pg.canary.Load(),
+		Connections: connections,
+		RPC:         pg.client.RPCProviders(),
+	})
+}
+
+// ReadinessResponse reports whether the gateway can serve payments: its
+// database and RPC node answer and, unless CANARY_MODE is off, a startup
+// canary has passed. A congested network makes operations slow, not
+// impossible, so it is detail and never makes the gateway unready.
+type ReadinessResponse struct {
+	Status     string            `json:"status"` // "ready" or "unready"
+	Checks     map[string]string `json:"checks"` // "ok" or why the dependency failed
+	Canary     *CanaryHealth     `json:"canary,omitempty"`
+	Congestion *CongestionHealth `json:"congestion,omitempty"`
+}
+
+// GET /readyz - Database and RPC reachability and the startup canary, with congestion detail; 503 when unready
+func (pg *PaymentGateway) readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	response := ReadinessResponse{Status: "ready", Checks: map[string]string{"database": "ok", "rpc": "ok"}, Congestion: pg.congestion.Load()}
+	if err := pg.db.Ping(ctx); err != nil {
+		response.Status, response.Checks["database"] = "unready", err.Error()
+	}
+	if _, err := pg.client.BlockNumber(ctx); err != nil {
+		response.Status, response.Checks["rpc"] = "unready", err.Error()
+	}
+	if pg.canaryEnabled() {
+		response.Canary = pg.canary.Load()
+		switch {
+		case response.Canary == nil:
+			response.Status, response.Checks["canary"] = "unready", "not run yet"
+		case response.Canary.Status != canaryPassed:
+			response.Status, response.Checks["canary"] = "unready", response.Canary.Error
+		default:
+			response.Checks["canary"] = "ok"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
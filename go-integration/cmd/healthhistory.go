@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// maxHealthBacklog bounds the snapshots kept in memory while the database is unreachable
+const maxHealthBacklog = 1440
+
+// workerRun is when a background worker last ran and how often it should
+type workerRun struct {
+	at    time.Time
+	every time.Duration
+}
+
+// HealthSnapshotResponse is the gateway's health at one moment
+type HealthSnapshotResponse struct {
+	TakenAt      time.Time               `json:"taken_at"`
+	Status       string                  `json:"status"`
+	RPCLatencyMS *int64                  `json:"rpc_latency_ms"`
+	DBLatencyMS  *int64                  `json:"db_latency_ms"`
+	LastBlock    *int64                  `json:"last_block"`
+	Workers      []database.WorkerHealth `json:"workers"`
+	Problems     []string                `json:"problems,omitempty"`
+	Maintenance  bool                    `json:"maintenance"` // the gateway was read-only
+}
+
+// HealthHistoryResponse is a page of snapshots, newest first by default, with
+// the share of snapshots since Since that were ok
+type HealthHistoryResponse struct {
+	Since         time.Time                `json:"since"`
+	UptimePercent *float64                 `json:"uptime_percent"` // nil without snapshots
+	SnapshotCount int64                    `json:"snapshot_count"`
+	DegradedSince *time.Time               `json:"degraded_since,omitempty"` // start of the current outage
+	Snapshots     []HealthSnapshotResponse `json:"snapshots"`
+}
+
+// markWorkerRun records that a background worker ran and is due again within every
+func (pg *PaymentGateway) markWorkerRun(worker string, every time.Duration) {
+	pg.workers.Store(worker, workerRun{at: time.Now(), every: every})
+}
+
+// workerHealth reports every background worker that has run, by name. A
+// worker that has missed two runs is stale.
+func (pg *PaymentGateway) workerHealth(now time.Time) []database.WorkerHealth {
+	workers := []database.WorkerHealth{}
+	pg.workers.Range(func(name, value any) bool {
+		run := value.(workerRun)
+		workers = append(workers, database.WorkerHealth{
+			Name:     name.(string),
+			LastRun:  run.at,
+			Interval: run.every.String(),
+			Stale:    now.Sub(run.at) > 2*run.every,
+		})
+		return true
+	})
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Name < workers[j].Name })
+	return workers
+}
+
+// runHealthHistory records a health snapshot every HEALTH_SNAPSHOT_INTERVAL.
+// Snapshots taken while the database is unreachable are kept in memory and
+// recorded once it is back, so the history covers database outages too.
+func (pg *PaymentGateway) runHealthHistory(ctx context.Context) {
+	if pg.config.HealthSnapshotInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.HealthSnapshotInterval)
+	defer ticker.Stop()
+
+	var backlog []*database.HealthSnapshot
+	var lastBlock *int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshot := pg.takeHealthSnapshot(ctx, lastBlock)
+			if snapshot.LastBlock != nil {
+				lastBlock = snapshot.LastBlock
+			}
+
+			backlog = append(backlog, snapshot)
+			if len(backlog) > maxHealthBacklog {
+				backlog = backlog[len(backlog)-maxHealthBacklog:]
+			}
+			if err := pg.db.RecordHealthSnapshots(ctx, backlog, time.Now().Add(-pg.config.HealthHistoryRetention)); err != nil {
+				log.Printf("Failed to record health snapshots, %d held in memory: %v", len(backlog), err)
+				continue
+			}
+			backlog = nil
+		}
+	}
+}
+
+// takeHealthSnapshot times a round trip to the RPC node and the database and
+// checks the background workers. The chain is stalled if no block has been
+// seen since previousBlock.
+func (pg *PaymentGateway) takeHealthSnapshot(ctx context.Context, previousBlock *int64) *database.HealthSnapshot {
+	now := time.Now()
+	snapshot := &database.HealthSnapshot{TakenAt: now, Status: database.HealthStatusOK, Workers: pg.workerHealth(now), Maintenance: pg.maintenance.Load() != nil}
+	degrade := func(status, problem string) {
+		if snapshot.Status != database.HealthStatusDown {
+			snapshot.Status = status
+		}
+		snapshot.Problems = append(snapshot.Problems, problem)
+	}
+	slow := func(latency time.Duration) bool {
+		return pg.config.HealthSlowLatency > 0 && latency > pg.config.HealthSlowLatency
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	start := time.Now()
+	block, err := pg.client.BlockNumber(rpcCtx)
+	latency := time.Since(start)
+	cancel()
+	if err != nil {
+		degrade(database.HealthStatusDown, fmt.Sprintf("RPC node unreachable: %v", err))
+	} else {
+		latencyMS, lastBlock := latency.Milliseconds(), int64(block)
+		snapshot.RPCLatencyMS, snapshot.LastBlock = &latencyMS, &lastBlock
+		if slow(latency) {
+			degrade(database.HealthStatusDegraded, fmt.Sprintf("RPC round trip took %dms", latencyMS))
+		}
+		if previousBlock != nil && lastBlock <= *previousBlock {
+			degrade(database.HealthStatusDegraded, fmt.Sprintf("no new block since %d", *previousBlock))
+		}
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	start = time.Now()
+	err = pg.db.Ping(dbCtx)
+	latency = time.Since(start)
+	cancel()
+	if err != nil {
+		degrade(database.HealthStatusDown, fmt.Sprintf("database unreachable: %v", err))
+	} else {
+		latencyMS := latency.Milliseconds()
+		snapshot.DBLatencyMS = &latencyMS
+		if slow(latency) {
+			degrade(database.HealthStatusDegraded, fmt.Sprintf("database round trip took %dms", latencyMS))
+		}
+	}
+
+	if congestion := pg.congestion.Load(); congestion != nil && congestion.Congested {
+		degrade(database.HealthStatusDegraded, "network congested: "+strings.Join(congestion.Reasons, "; "))
+	}
+
+	for _, worker := range snapshot.Workers {
+		if worker.Stale {
+			degrade(database.HealthStatusDegraded, fmt.Sprintf("%s last ran %s ago", worker.Name, now.Sub(worker.LastRun).Round(time.Second)))
+		}
+	}
+
+	return snapshot
+}
+
+// GET /admin/health-history?since=RFC3339 - Recorded health and uptime, with snapshots paged like other lists
+func (pg *PaymentGateway) getHealthHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since := time.Now().Add(-24 * time.Hour)
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	params, err := database.HealthSnapshotListSpec.Parse(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !params.Filters("taken_at") {
+		params.Conditions = append(params.Conditions, database.HealthSnapshotListSpec.Where("taken_at", listquery.Gte, since))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	snapshots, next, err := pg.db.FindHealthSnapshots(ctx, params)
+	if err != nil {
+		writeServerError(w, "Failed to list health snapshots", err)
+		return
+	}
+	summary, err := pg.db.GetHealthSummary(ctx, since)
+	if err != nil {
+		writeServerError(w, "Failed to summarise health snapshots", err)
+		return
+	}
+
+	response := HealthHistoryResponse{
+		Since:         since,
+		SnapshotCount: summary.Total,
+		DegradedSince: summary.DegradedSince,
+		Snapshots:     make([]HealthSnapshotResponse, 0, len(snapshots)),
+	}
+	if summary.Total > 0 {
+		uptime := float64(summary.OK) * 100 / float64(summary.Total)
+		response.UptimePercent = &uptime
+	}
+	for _, snapshot := range snapshots {
+		response.Snapshots = append(response.Snapshots, HealthSnapshotResponse{
+			TakenAt:      snapshot.TakenAt,
+			Status:       snapshot.Status,
+			RPCLatencyMS: snapshot.RPCLatencyMS,
+			DBLatencyMS:  snapshot.DBLatencyMS,
+			LastBlock:    snapshot.LastBlock,
+			Workers:      snapshot.Workers,
+			Problems:     snapshot.Problems,
+			Maintenance:  snapshot.Maintenance,
+		})
+	}
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
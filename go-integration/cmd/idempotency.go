@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// IdempotencyKeyHeader carries a client-chosen key that makes a mutating
+// request safe to retry: every request with the same key gets the original
+// response instead of submitting another transaction
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader marks a response replayed for a retried key
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+const (
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodyBytes  = 1 << 20
+
+	// idempotencyStaleAfter is how long a key stays claimed by a request that
+	// never answered, well past the handlers' 30-second deadline
+	idempotencyStaleAfter = 2 * time.Minute
+)
+
+// idempotent wraps a mutating endpoint so that a request carrying an
+// Idempotency-Key is handled once per key and tenant. Retries get the stored
+// response with Idempotent-Replayed: true; the same key on a different
+// request gets a 422, and a retry while the first request is still being
+// handled a 409. Only a 2xx response or one naming a transaction is stored;
+// any other error submitted nothing, so it releases the key and a retry is
+// handled afresh. Requests without a key are passed straight through.
+func (pg *PaymentGateway) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength || !printableASCII(key) {
+			http.Error(w, "Idempotency-Key must be at most 255 printable ASCII characters", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		digest := sha256.New()
+		io.WriteString(digest, r.Method+" "+r.URL.RequestURI()+"\n")
+		digest.Write(body)
+		requestHash := hex.EncodeToString(digest.Sum(nil))
+		tenant := r.Header.Get(TenantHeader)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		now := time.Now()
+		stored, err := pg.db.ClaimIdempotencyKey(ctx, tenant, key, requestHash, now.Add(-idempotencyStaleAfter), now.Add(-pg.config.IdempotencyKeyTTL))
+		cancel()
+		switch {
+		case errors.Is(err, database.ErrIdempotencyKeyReused):
+			http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, database.ErrIdempotencyKeyInFlight):
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "A request with this Idempotency-Key is still being handled", http.StatusConflict)
+			return
+		case err != nil:
+			writeServerError(w, "Failed to check idempotency key", err)
+			return
+		case stored != nil:
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set(IdempotentReplayHeader, "true")
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		// Detached like the handlers, so a client disconnect can't leave the key claimed
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		response := database.IdempotentResponse{
+			StatusCode:  recorder.status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+			TxHash:      responseTxHash(recorder.body.Bytes()),
+		}
+		if response.TxHash == nil && (response.StatusCode < 200 || response.StatusCode > 299) {
+			if err := pg.db.ReleaseIdempotencyKey(ctx, tenant, key); err != nil {
+				log.Printf("Failed to release idempotency key %s: %v", strconv.Quote(key), err)
+			}
+			return
+		}
+		if err := pg.db.CompleteIdempotencyKey(ctx, tenant, key, response); err != nil {
+			log.Printf("Failed to store the response for idempotency key %s: %v", strconv.Quote(key), err)
+		}
+	}
+}
+
+// responseTxHash is the tx_hash of a JSON response, if it has one
+func responseTxHash(body []byte) *string {
+	var response struct {
+		TxHash string `json:"tx_hash"`
+	}
+	if json.Unmarshal(body, &response) != nil || response.TxHash == "" {
+		return nil
+	}
+	return &response.TxHash
+}
+
+func printableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+// escrowIndexer names the escrow contract's event checkpoint
+const escrowIndexer = "escrow"
+
+// indexerBatchBlocks is the most blocks scanned in one log query, which RPC
+// providers limit; the checkpoint moves after each batch
+const indexerBatchBlocks = 2000
+
+// IndexerStatusResponse is how far the escrow event indexer has got
+type IndexerStatusResponse struct {
+	Enabled      bool                      `json:"enabled"`
+	Checkpoint   *database.EventCheckpoint `json:"checkpoint"` // null before the first scan
+	HeadBlock    uint64                    `json:"head_block"`
+	LagBlocks    uint64                    `json:"lag_blocks"`
+	SafetyWindow uint64                    `json:"safety_window"`
+
+	StatusUpdates    bool                      `json:"status_updates"`
+	StatusCheckpoint *database.EventCheckpoint `json:"status_checkpoint"` // the last block whose events were applied to payment statuses
+}
+
+// runEventIndexer records the escrow contract's events every
+// INDEXER_POLL_INTERVAL, from its checkpoint onwards. The first scan after a
+// restart goes back INDEXER_SAFETY_WINDOW blocks, in case the checkpointed
+// blocks were reorganised while the gateway was down. With
+// EVENT_STATUS_UPDATES each scan is followed by applying the events to
+// payment statuses.
+func (pg *PaymentGateway) runEventIndexer(ctx context.Context) {
+	if pg.config.IndexerPollInterval <= 0 {
+		return
+	}
+
+	rescan := true
+	index := func() {
+		if _, _, err := pg.indexEscrowEvents(ctx, rescan); err != nil {
+			log.Printf("Failed to index escrow events: %v", err)
+			return
+		}
+		rescan = false
+		if pg.config.EventStatusUpdates {
+			if err := pg.applyEscrowEvents(ctx); err != nil {
+				log.Printf("Failed to apply escrow events to payment statuses: %v", err)
+			}
+		}
+		pg.markWorkerRun("event_indexer", pg.config.IndexerPollInterval)
+	}
+	index()
+
+	ticker := time.NewTicker(pg.config.IndexerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			index()
+		}
+	}
+}
+
+// indexEscrowEvents scans the blocks after the checkpoint, or from the safety
+// window below it when rescan is set or the checkpointed block's hash has
+// changed, up to the head. It returns how many events were added and how
+// many a reorganisation removed.
+func (pg *PaymentGateway) indexEscrowEvents(ctx context.Context, rescan bool) (added, removed int, err error) {
+	head, err := pg.client.BlockNumber(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get latest block: %w", err)
+	}
+	checkpoint, err := pg.db.GetEventCheckpoint(ctx, escrowIndexer)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	from := pg.config.ContractDeployBlock
+	if checkpoint != nil {
+		if !rescan && checkpoint.BlockNumber <= head {
+			hash, err := pg.client.BlockHash(ctx, checkpoint.BlockNumber)
+			if err != nil {
+				return 0, 0, err
+			}
+			if hash != checkpoint.BlockHash {
+				log.Printf("Block %d was reorganised from %s to %s; scanning the last %d blocks again", checkpoint.BlockNumber, checkpoint.BlockHash, hash, pg.config.IndexerSafetyWindow)
+				rescan = true
+			}
+		}
+		// A head behind the checkpoint means the chain was reorganised onto a shorter fork
+		rescan = rescan || checkpoint.BlockNumber > head
+
+		from = checkpoint.BlockNumber + 1
+		if rescan {
+			from -= min(pg.config.IndexerSafetyWindow, from)
+		}
+		from = max(from, pg.config.ContractDeployBlock)
+	}
+
+	for start := from; start <= head; start += indexerBatchBlocks {
+		end := min(start+indexerBatchBlocks-1, head)
+		// Taken before the scan: if the block is reorganised after it, the
+		// next scan sees the hash change and rescans these blocks
+		hash, err := pg.client.BlockHash(ctx, end)
+		if err != nil {
+			return added, removed, err
+		}
+		scanned, err := pg.client.ScanEscrowEvents(ctx, start, end)
+		if err != nil {
+			return added, removed, err
+		}
+
+		events := make([]database.IndexedEvent, len(scanned))
+		for i, event := range scanned {
+			events[i] = newIndexedEvent(event)
+		}
+		result, err := pg.db.IndexEvents(ctx, start, events, database.EventCheckpoint{Name: escrowIndexer, BlockNumber: end, BlockHash: hash})
+		if err != nil {
+			return added, removed, err
+		}
+		for _, event := range result.Removed {
+			log.Printf("Warning: Reorganisation removed the %s event of job %d in transaction %s at block %d", event.Kind, event.JobID, event.TxHash, event.BlockNumber)
+		}
+		if len(result.Added) > 0 {
+			log.Printf("Indexed %d escrow events in blocks %d-%d", len(result.Added), start, end)
+		}
+		added += len(result.Added)
+		removed += len(result.Removed)
+	}
+	return added, removed, nil
+}
+
+func newIndexedEvent(event payment.EscrowEvent) database.IndexedEvent {
+	address := func(a common.Address) *string {
+		if a == (common.Address{}) {
+			return nil
+		}
+		hex := a.Hex()
+		return &hex
+	}
+	indexed := database.IndexedEvent{
+		TxHash:            event.TxHash,
+		LogIndex:          event.LogIndex,
+		BlockNumber:       event.BlockNumber,
+		BlockHash:         event.BlockHash,
+		Kind:              event.Kind,
+		JobID:             event.JobID,
+		ClientAddress:     address(event.Client),
+		FreelancerAddress: address(event.Freelancer),
+		ETHAmountWei:      "0",
+	}
+	if event.USDAmount != nil {
+		usd := event.USDAmount.String()
+		indexed.USDAmount = &usd
+	}
+	if event.ETHAmount != nil {
+		indexed.ETHAmountWei = event.ETHAmount.String()
+	}
+	return indexed
+}
+
+// GET /admin/indexer - The escrow event indexer's checkpoint and lag
+func (pg *PaymentGateway) getIndexerStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	checkpoint, err := pg.db.GetEventCheckpoint(ctx, escrowIndexer)
+	if err != nil {
+		writeServerError(w, "Failed to get event checkpoint", err)
+		return
+	}
+	statusCheckpoint, err := pg.db.GetEventCheckpoint(ctx, escrowStatusIndexer)
+	if err != nil {
+		writeServerError(w, "Failed to get event checkpoint", err)
+		return
+	}
+	head, err := pg.client.BlockNumber(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get latest block", err)
+		return
+	}
+
+	response := IndexerStatusResponse{
+		Enabled:      pg.config.IndexerPollInterval > 0,
+		Checkpoint:   checkpoint,
+		HeadBlock:    head,
+		SafetyWindow: pg.config.IndexerSafetyWindow,
+
+		StatusUpdates:    pg.config.EventStatusUpdates,
+		StatusCheckpoint: statusCheckpoint,
+	}
+	switch {
+	case checkpoint == nil:
+		response.LagBlocks = head - min(pg.config.ContractDeployBlock, head)
+	case checkpoint.BlockNumber < head:
+		response.LagBlocks = head - checkpoint.BlockNumber
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
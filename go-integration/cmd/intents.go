@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+const (
+	// intentRecoveryInterval is how often intents past INTENT_RECOVERY_GRACE are looked for
+	intentRecoveryInterval = time.Minute
+
+	// intentClockSkew allows for the database and block clocks disagreeing
+	// when deciding whether an on-chain event came after an intent
+	intentClockSkew = time.Minute
+)
+
+// intentEffect is what a chain operation leaves on-chain and in the
+// application's payment record once sent
+type intentEffect struct {
+	event  string               // payment.JobEvent kind
+	status paymentstatus.Status // the *_initiated status the receipt poller settles
+	txType string
+}
+
+var intentEffects = map[string]intentEffect{
+	opPostJob:     {payment.JobEventPosted, paymentstatus.DepositInitiated, "deposit"},
+	opCompleteJob: {payment.JobEventReleased, paymentstatus.ReleaseInitiated, "release"},
+	opCancelJob:   {payment.JobEventCancelled, paymentstatus.RefundInitiated, "refund"},
+}
+
+// resolveFailedIntent records that a submission stopped before anything was
+// broadcast. An error that may have come after the node accepted the
+// transaction, such as a timeout, leaves the intent for recovery to check
+// against the chain. It runs on its own context since the caller's may have
+// expired.
+func (pg *PaymentGateway) resolveFailedIntent(intent *database.ChainIntent, err error) {
+	if !errors.Is(err, errSigningHalted) {
+		switch payment.ClassifyError(err).Reason {
+		case payment.ReasonTimeout, payment.ReasonUnavailable, payment.ReasonUnknown:
+			log.Printf("%s%s for application %d may have been sent; intent %d is left for recovery: %v",
+				tracePrefix(intent.Params.TraceID), intent.Operation, intent.ApplicationID, intent.ID, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if dbErr := pg.db.ResolveChainIntent(ctx, intent.ID, database.IntentFailed, nil, err.Error()); dbErr != nil {
+		log.Printf("Warning: Failed to record the failure of intent %d; recovery will check it against the chain: %v", intent.ID, dbErr)
+	}
+}
+
+// runIntentRecovery settles chain intents left without an outcome, at
+// startup and then every minute. Only intents older than
+// INTENT_RECOVERY_GRACE are touched, so submissions still in flight on this
+// or another replica are left alone.
+func (pg *PaymentGateway) runIntentRecovery(ctx context.Context) {
+	if pg.config.IntentRecoveryGrace <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(intentRecoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		pg.recoverChainIntents(ctx, time.Now())
+		pg.markWorkerRun("intent_recovery", intentRecoveryInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (pg *PaymentGateway) recoverChainIntents(ctx context.Context, now time.Time) {
+	intents, err := pg.db.ListUnresolvedChainIntents(ctx, now.Add(-pg.config.IntentRecoveryGrace))
+	if err != nil {
+		log.Printf("Failed to list unresolved chain intents: %v", err)
+		return
+	}
+	if len(intents) == 0 {
+		return
+	}
+
+	// A transaction still in the mempool may be one of them; it is looked for
+	// again once mined or dropped
+	if busy, err := pg.signerHasPending(ctx); err != nil || busy {
+		if err != nil {
+			log.Printf("Failed to check signer nonces for intent recovery: %v", err)
+		}
+		return
+	}
+
+	for _, intent := range intents {
+		if err := pg.recoverChainIntent(ctx, intent); err != nil {
+			log.Printf("%sFailed to recover intent %d (%s for application %d): %v", tracePrefix(intent.Params.TraceID), intent.ID, intent.Operation, intent.ApplicationID, err)
+		}
+	}
+}
+
+// signerHasPending reports whether a signer account has sent transactions
+// that are not yet mined
+func (pg *PaymentGateway) signerHasPending(ctx context.Context) (bool, error) {
+	accounts := []common.Address{pg.client.Address()}
+	if admin := pg.client.AdminAddress(); admin != accounts[0] {
+		accounts = append(accounts, admin)
+	}
+	for _, account := range accounts {
+		mined, err := pg.client.NonceAt(ctx, account)
+		if err != nil {
+			return false, err
+		}
+		pending, err := pg.client.PendingNonceAt(ctx, account)
+		if err != nil {
+			return false, err
+		}
+		if pending > mined {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recoverChainIntent finds out from the chain whether an intent's operation
+// was sent. One that was is recorded as initiated for the receipt poller to
+// settle; one that wasn't is handed to the deferred queue, so it is neither
+// lost nor sent twice.
+func (pg *PaymentGateway) recoverChainIntent(ctx context.Context, intent *database.ChainIntent) error {
+	effect, ok := intentEffects[intent.Operation]
+	if !ok {
+		return pg.db.ResolveChainIntent(ctx, intent.ID, database.IntentFailed, nil, fmt.Sprintf("unknown operation %q", intent.Operation))
+	}
+
+	history, err := pg.client.GetJobHistory(ctx, intent.Params.JobID)
+	if err != nil {
+		return fmt.Errorf("failed to get job history: %w", err)
+	}
+	var sent *payment.JobEvent
+	for i := range history {
+		if history[i].Kind == effect.event && (sent == nil || history[i].BlockNumber > sent.BlockNumber) {
+			sent = &history[i]
+		}
+	}
+	if sent != nil {
+		minedAt, err := pg.client.BlockTime(ctx, sent.BlockNumber)
+		if err != nil {
+			return fmt.Errorf("failed to get time of block %d: %w", sent.BlockNumber, err)
+		}
+		if minedAt.Before(intent.CreatedAt.Add(-intentClockSkew)) {
+			sent = nil // an earlier cycle of a job that was cancelled and posted again
+		}
+	}
+
+	if sent != nil {
+		details, err := pg.db.GetApplicationPaymentDetails(ctx, intent.ApplicationID)
+		if err != nil {
+			return fmt.Errorf("failed to get application details: %w", err)
+		}
+		recorded := map[string]*string{
+			"deposit": details.EscrowTxHashDeposit,
+			"release": details.EscrowTxHashRelease,
+			"refund":  details.EscrowTxHashRefund,
+		}[effect.txType]
+		if recorded == nil || *recorded != sent.TxHash {
+			change := database.StatusChange{
+				ApplicationID: intent.ApplicationID,
+				Status:        effect.status,
+				TxHash:        &sent.TxHash,
+				TxType:        effect.txType,
+				Actor:         database.ActorGateway,
+				TraceID:       intent.Params.TraceID,
+			}
+			if err := pg.db.ApplyStatusChange(ctx, change); err != nil {
+				return fmt.Errorf("failed to record recovered transaction: %w", err)
+			}
+			pg.wakePoller()
+		}
+		log.Printf("%sRecovered %s for application %d: sent as %s before the gateway recorded it", tracePrefix(intent.Params.TraceID), intent.Operation, intent.ApplicationID, sent.TxHash)
+		return pg.db.ResolveChainIntent(ctx, intent.ID, database.IntentRecovered, &sent.TxHash, "")
+	}
+
+	// A queued operation that was being submitted when the gateway stopped
+	// is still due, and retries on its own
+	existing, err := pg.db.GetPendingDeferredOperation(ctx, intent.ApplicationID)
+	if err != nil {
+		return fmt.Errorf("failed to get deferred operation: %w", err)
+	}
+	if existing == nil {
+		reason := "requeued by intent recovery: the gateway stopped before the operation was sent"
+		op, err := pg.db.CreateDeferredOperation(ctx, intent.ApplicationID, intent.Operation, intent.Params, time.Now().Add(pg.retryWindow()), reason)
+		if err != nil {
+			return fmt.Errorf("failed to requeue operation: %w", err)
+		}
+		pg.notifyJob(op.ApplicationID, op.Params.TraceID, events.OperationDeferred, operationEvent(op, pg.explorer))
+	}
+	log.Printf("%sRequeued %s for application %d: intent %d was never sent", tracePrefix(intent.Params.TraceID), intent.Operation, intent.ApplicationID, intent.ID)
+	return pg.db.ResolveChainIntent(ctx, intent.ID, database.IntentRequeued, nil, "")
+}
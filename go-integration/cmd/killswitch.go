@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/killswitch"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+// errSigningHalted is returned instead of sending a transaction while the
+// kill switch is tripped
+var errSigningHalted = errors.New("signing is halted by the kill switch")
+
+// KillSwitchResponse reports whether the gateway may sign transactions
+type KillSwitchResponse struct {
+	Tripped bool                  `json:"tripped"`
+	Halt    *database.SigningHalt `json:"halt,omitempty"`
+
+	// The first admin key's request, waiting on a second
+	Pending *database.KillSwitchApproval `json:"pending,omitempty"`
+}
+
+// signingGuard wraps the chain client so nothing is signed while the kill
+// switch is tripped, and records the account and nonce of everything that is
+// so the wallet monitor can tell the gateway's transactions from anyone else's
+type signingGuard struct {
+	ChainClient
+	pg *PaymentGateway
+}
+
+func (g signingGuard) PostJob(ctx context.Context, jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address) (*payment.TransactionResult, error) {
+	if err := g.pg.checkSigning(); err != nil {
+		return nil, err
+	}
+	result, err := g.ChainClient.PostJob(ctx, jobID, freelancer, usdAmount, client)
+	g.record(result)
+	return result, err
+}
+
+func (g signingGuard) MarkJobCompleted(ctx context.Context, jobID uint64) (*payment.TransactionResult, error) {
+	if err := g.pg.checkSigning(); err != nil {
+		return nil, err
+	}
+	result, err := g.ChainClient.MarkJobCompleted(ctx, jobID)
+	g.record(result)
+	return result, err
+}
+
+func (g signingGuard) CancelJob(ctx context.Context, jobID uint64) (*payment.TransactionResult, error) {
+	if err := g.pg.checkSigning(); err != nil {
+		return nil, err
+	}
+	result, err := g.ChainClient.CancelJob(ctx, jobID)
+	g.record(result)
+	return result, err
+}
+
+func (g signingGuard) CancelTransaction(ctx context.Context, hash common.Hash) (*payment.TransactionResult, error) {
+	if err := g.pg.checkSigning(); err != nil {
+		return nil, err
+	}
+	result, err := g.ChainClient.CancelTransaction(ctx, hash)
+	g.record(result)
+	return result, err
+}
+
+// Canary only signs while the kill switch allows it, and records a sent
+// transfer like any other transaction
+func (g signingGuard) Canary(ctx context.Context, send bool) (*payment.CanaryResult, error) {
+	if err := g.pg.checkSigning(); err != nil {
+		return nil, err
+	}
+	result, err := g.ChainClient.Canary(ctx, send)
+	if result != nil {
+		g.record(result.Sent)
+	}
+	return result, err
+}
+
+// record stores the nonce of a broadcast transaction. It runs on its own
+// context since the caller's may have expired waiting for the receipt.
+func (g signingGuard) record(result *payment.TransactionResult) {
+	if result == nil || result.TxHash == "" || result.From == (common.Address{}) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := g.pg.db.RecordSignedTransaction(ctx, result.From.Hex(), result.Nonce, result.TxHash); err != nil {
+		log.Printf("Failed to record nonce %d of %s; the wallet monitor will report it as unexpected: %v", result.Nonce, result.TxHash, err)
+	}
+}
+
+// checkSigning returns errSigningHalted while the kill switch is tripped
+func (pg *PaymentGateway) checkSigning() error {
+	if halt := pg.signingHalt.Load(); halt != nil {
+		return fmt.Errorf("%w since %s: %s", errSigningHalted, halt.TrippedAt.Format(time.RFC3339), halt.Reason)
+	}
+	return nil
+}
+
+// setSigningHalt records the halt in force, or nil once signing is re-armed.
+// Approvals the platform sent meanwhile were not acted on, so they are
+// released once signing is re-armed.
+func (pg *PaymentGateway) setSigningHalt(halt *database.SigningHalt) {
+	previous := pg.signingHalt.Swap(halt)
+	switch {
+	case previous == nil && halt != nil:
+		log.Printf("ALERT: signing halted by %s: %s", halt.TrippedBy, halt.Reason)
+	case previous != nil && halt == nil:
+		log.Printf("Signing re-armed after %s", time.Since(previous.TrippedAt).Round(time.Second))
+		if pg.config.PlatformEventsChannel != "" {
+			pg.goWorker("missed_approvals", pg.releaseMissedApprovals)
+		}
+	}
+}
+
+// refreshSigningHalt picks up halts tripped or re-armed by another replica
+func (pg *PaymentGateway) refreshSigningHalt(ctx context.Context) error {
+	halt, err := pg.db.GetActiveSigningHalt(ctx)
+	if err != nil {
+		return err
+	}
+	pg.setSigningHalt(halt)
+	return nil
+}
+
+// GET /admin/kill-switch - Whether the gateway may sign transactions
+func (pg *PaymentGateway) getKillSwitchHandler(w http.ResponseWriter, r *http.Request) {
+	halt := pg.signingHalt.Load()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(KillSwitchResponse{Tripped: halt != nil, Halt: halt})
+}
+
+// PUT /admin/kill-switch - Halt all transaction signing
+func (pg *PaymentGateway) activateKillSwitchHandler(w http.ResponseWriter, r *http.Request) {
+	pg.changeKillSwitch(w, r, killswitch.ActionActivate)
+}
+
+// DELETE /admin/kill-switch - Sign transactions again after a halt
+func (pg *PaymentGateway) deactivateKillSwitchHandler(w http.ResponseWriter, r *http.Request) {
+	pg.changeKillSwitch(w, r, killswitch.ActionDeactivate)
+}
+
+// changeKillSwitch needs a second person for either action: an approval
+// signed by a KILL_SWITCH_APPROVERS key in the X-Kill-Switch-Approval header,
+// or the same request from another admin key within
+// KILL_SWITCH_APPROVAL_WINDOW. The first admin key's request is held as a
+// proposal and answered with 202.
+func (pg *PaymentGateway) changeKillSwitch(w http.ResponseWriter, r *http.Request, action string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	var req killswitch.Approval
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	if req.Action != "" && req.Action != action {
+		http.Error(w, fmt.Sprintf("action must be %s", action), http.StatusBadRequest)
+		return
+	}
+	actor := adminKeyName(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := pg.refreshSigningHalt(ctx); err != nil {
+		writeServerError(w, "Failed to get kill switch", err)
+		return
+	}
+	current := pg.signingHalt.Load()
+	if action == killswitch.ActionActivate && current != nil {
+		http.Error(w, "Signing is already halted", http.StatusConflict)
+		return
+	}
+	if action == killswitch.ActionDeactivate && current == nil {
+		http.Error(w, "Signing is not halted", http.StatusNotFound)
+		return
+	}
+
+	if signature := r.Header.Get(killswitch.ApprovalHeader); signature != "" {
+		pg.applySignedKillSwitch(ctx, w, body, signature, action, actor, current)
+		return
+	}
+
+	proposal, halt, err := pg.db.ApproveKillSwitch(ctx, action, req.Reason, actor, time.Now().Add(pg.config.KillSwitchApprovalWindow))
+	if errors.Is(err, database.ErrSameApprover) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeServerError(w, "Failed to approve kill switch", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if proposal.ApprovedBy == nil {
+		log.Printf("Kill switch %s proposed with admin key %s: %s", action, actor, req.Reason)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(KillSwitchResponse{Tripped: current != nil, Halt: current, Pending: proposal})
+		return
+	}
+	log.Printf("Kill switch %s approved by admin keys %s and %s: %s", action, proposal.ProposedBy, actor, proposal.Reason)
+	pg.writeKillSwitchChange(w, action, halt)
+}
+
+// applySignedKillSwitch carries out action on the strength of the admin key
+// and an approval signed for it. A deactivation names the halt it lifts, so
+// an approval to lift one halt cannot be replayed against the next.
+func (pg *PaymentGateway) applySignedKillSwitch(ctx context.Context, w http.ResponseWriter, body []byte, signature, action, actor string, current *database.SigningHalt) {
+	if pg.killSwitchApprovals == nil {
+		http.Error(w, "Signed approvals are disabled; set KILL_SWITCH_APPROVERS", http.StatusBadRequest)
+		return
+	}
+	approval, approver, err := pg.killSwitchApprovals.Verify(body, signature, action, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if action == killswitch.ActionDeactivate && approval.HaltID != current.ID {
+		http.Error(w, fmt.Sprintf("approval is for halt %d, the halt in force is %d", approval.HaltID, current.ID), http.StatusConflict)
+		return
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{"admin_key": actor, "approver": approver.Hex(), "approval": json.RawMessage(body)})
+	approvers := actor + "," + approver.Hex()
+	var halt *database.SigningHalt
+	if action == killswitch.ActionActivate {
+		halt, _, err = pg.db.TripSigningHalt(ctx, approval.Reason, approvers, details)
+	} else {
+		halt, err = pg.db.RearmSigning(ctx, approvers, approval.Reason, details)
+	}
+	if err != nil {
+		writeServerError(w, fmt.Sprintf("Failed to %s kill switch", action), err)
+		return
+	}
+	log.Printf("Kill switch %s by admin key %s with approval from %s: %s", action, actor, approver.Hex(), approval.Reason)
+	pg.writeKillSwitchChange(w, action, halt)
+}
+
+func (pg *PaymentGateway) writeKillSwitchChange(w http.ResponseWriter, action string, halt *database.SigningHalt) {
+	tripped := action == killswitch.ActionActivate
+	if tripped {
+		pg.setSigningHalt(halt)
+	} else {
+		pg.setSigningHalt(nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(KillSwitchResponse{Tripped: tripped, Halt: halt})
+}
+
+// GET /admin/audit/verify - Check the audit log's hash chain for tampering
+func (pg *PaymentGateway) verifyAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	verification, err := pg.db.VerifyAuditLog(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to verify audit log", err)
+		return
+	}
+	if !verification.Valid {
+		log.Printf("ALERT: audit log hash chain is broken at entry %d: %s", *verification.BrokenAt, verification.Problem)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/kyc"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// KYCRecheckResponse is a hold after checking its freelancer again and, when
+// they passed, the transaction that submitted the release
+type KYCRecheckResponse struct {
+	Hold        *database.KYCHold    `json:"hold"`
+	Transaction *TransactionResponse `json:"transaction,omitempty"`
+}
+
+// KYCWithdrawRequest is the optional body of withdrawing a hold
+type KYCWithdrawRequest struct {
+	Reason string `json:"reason"`
+}
+
+func kycHoldEvent(hold *database.KYCHold) events.KYCHold {
+	return events.KYCHold{
+		HoldID:           hold.ID,
+		ApplicationID:    hold.ApplicationID,
+		FreelancerUserID: hold.FreelancerUserID,
+		USDAmount:        hold.USDAmount,
+		KYCStatus:        hold.KYCStatus,
+		Status:           hold.Status,
+		HeldAt:           hold.CreatedAt,
+		ResolvedAt:       hold.ResolvedAt,
+	}
+}
+
+// kycHold holds a release over KYC_THRESHOLD_USD whose freelancer has not
+// passed KYC, moving the application to kyc_pending. A status that can't be
+// looked up holds the release too, to be checked again later. It returns nil
+// when the release may go ahead.
+func (pg *PaymentGateway) kycHold(ctx context.Context, details *database.ApplicationPaymentDetails, params database.OperationParams) (*database.KYCHold, error) {
+	if pg.kyc == nil || details.AgreedUSDAmount == nil || int64(*details.AgreedUSDAmount) <= pg.config.KYCThresholdUSD {
+		return nil, nil
+	}
+
+	status, err := pg.kyc.Status(ctx, details.ApplicantUserID)
+	if err == nil && status == kyc.StatusVerified {
+		return nil, nil
+	}
+	hold := &database.KYCHold{
+		ApplicationID:    details.ApplicationID,
+		FreelancerUserID: details.ApplicantUserID,
+		USDAmount:        int64(*details.AgreedUSDAmount),
+		Operation:        opCompleteJob,
+		Params:           params,
+		KYCStatus:        string(status),
+	}
+	if err != nil {
+		message := err.Error()
+		hold.KYCStatus, hold.LastError = string(kyc.StatusPending), &message
+		log.Printf("%sWarning: Failed to check the KYC status of freelancer %d, holding the release of application %d: %v", tracePrefix(params.TraceID), details.ApplicantUserID, details.ApplicationID, err)
+	}
+
+	created, err := pg.db.CreateKYCHold(ctx, hold)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%sHeld $%d release of application %d in KYC hold %d: freelancer %d is %s", tracePrefix(params.TraceID), created.USDAmount, created.ApplicationID, created.ID, created.FreelancerUserID, created.KYCStatus)
+	pg.notifyJob(created.ApplicationID, params.TraceID, events.KYCPending, kycHoldEvent(created))
+	return created, nil
+}
+
+// holdIfKYCPending holds a release for KYC, writing 202 with the hold. It
+// returns true if a response has been written.
+func (pg *PaymentGateway) holdIfKYCPending(ctx context.Context, w http.ResponseWriter, details *database.ApplicationPaymentDetails, params database.OperationParams) bool {
+	hold, err := pg.kycHold(ctx, details, params)
+	switch {
+	case errors.Is(err, database.ErrStatusConflict):
+		http.Error(w, "Payment status changed while holding the release for KYC", http.StatusConflict)
+		return true
+	case err != nil:
+		writeServerError(w, "Failed to hold release for KYC", err)
+		return true
+	case hold == nil:
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(hold)
+	return true
+}
+
+// runKYCRechecks checks the freelancers of held releases again every
+// KYC_RECHECK_INTERVAL, submitting each release whose freelancer has passed
+func (pg *PaymentGateway) runKYCRechecks(ctx context.Context) {
+	if pg.kyc == nil || pg.config.KYCRecheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.KYCRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pg.recheckKYCHolds(ctx)
+			pg.markWorkerRun("kyc_rechecks", pg.config.KYCRecheckInterval)
+		}
+	}
+}
+
+func (pg *PaymentGateway) recheckKYCHolds(ctx context.Context) {
+	holds, err := pg.db.ListKYCHolds(ctx, database.KYCHoldOpen)
+	if err != nil {
+		log.Printf("Failed to list KYC holds: %v", err)
+		return
+	}
+	for _, hold := range holds {
+		if _, _, err := pg.recheckKYCHold(ctx, hold); err != nil {
+			log.Printf("Failed to recheck KYC hold %d: %v", hold.ID, err)
+		}
+	}
+}
+
+// recheckKYCHold looks up the hold's freelancer again. Once they have passed
+// the hold is cleared, the application is deposited again and the release is
+// submitted, or queued if the failure is retryable. It returns the hold as
+// it now is and the release's transaction, if one was sent.
+func (pg *PaymentGateway) recheckKYCHold(ctx context.Context, hold *database.KYCHold) (*database.KYCHold, *TransactionResponse, error) {
+	traceID := hold.Params.TraceID
+	status, err := pg.kyc.Status(ctx, hold.FreelancerUserID)
+	if err != nil {
+		message := err.Error()
+		if recordErr := pg.db.RecordKYCCheck(ctx, hold.ID, hold.KYCStatus, &message); recordErr != nil {
+			log.Printf("Warning: Failed to record KYC check of hold %d: %v", hold.ID, recordErr)
+		}
+		return hold, nil, fmt.Errorf("failed to check the KYC status of freelancer %d: %w", hold.FreelancerUserID, err)
+	}
+
+	if status != kyc.StatusVerified {
+		if err := pg.db.RecordKYCCheck(ctx, hold.ID, string(status), nil); err != nil {
+			return hold, nil, err
+		}
+		if status == kyc.StatusRejected && hold.KYCStatus != string(kyc.StatusRejected) {
+			log.Printf("%sFreelancer %d failed KYC; the release of application %d stays held", tracePrefix(traceID), hold.FreelancerUserID, hold.ApplicationID)
+			pg.notifyJob(hold.ApplicationID, traceID, events.KYCRejected, kycHoldEvent(hold))
+		}
+		hold.KYCStatus, hold.LastError = string(status), nil
+		return hold, nil, nil
+	}
+
+	cleared, err := pg.db.ResolveKYCHold(ctx, hold.ID, database.KYCHoldCleared, string(kyc.StatusVerified), database.ActorKYC, "freelancer passed KYC")
+	if err != nil {
+		return hold, nil, err
+	}
+	log.Printf("%sFreelancer %d passed KYC; releasing application %d from KYC hold %d", tracePrefix(traceID), cleared.FreelancerUserID, cleared.ApplicationID, cleared.ID)
+	pg.notifyJob(cleared.ApplicationID, traceID, events.KYCCleared, kycHoldEvent(cleared))
+
+	result, err := pg.submitOperation(ctx, cleared.ApplicationID, cleared.Operation, cleared.Params)
+	if err == nil {
+		return cleared, pg.newTransactionResponse(result), nil
+	}
+	op, queued, dbErr := pg.queueRetryable(ctx, cleared.ApplicationID, cleared.Operation, cleared.Params, err)
+	switch {
+	case queued && dbErr != nil:
+		return cleared, nil, fmt.Errorf("release of application %d failed and queueing it failed: %v", cleared.ApplicationID, dbErr)
+	case queued:
+		log.Printf("%sQueued the release of application %d as deferred operation %d: %v", tracePrefix(traceID), cleared.ApplicationID, op.ID, err)
+		return cleared, nil, nil
+	}
+	return cleared, pg.newTransactionResponse(result), fmt.Errorf("release of application %d failed: %w", cleared.ApplicationID, err)
+}
+
+// GET /admin/kyc-holds?status=open&sort=-usd_amount&limit=100 - Releases held until freelancers pass KYC
+func (pg *PaymentGateway) listKYCHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := database.KYCHoldListSpec.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !params.Filters("status") {
+		params.Conditions = append(params.Conditions, database.KYCHoldListSpec.Where("status", listquery.Eq, database.KYCHoldOpen))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	holds, next, err := pg.db.FindKYCHolds(ctx, params)
+	if err != nil {
+		writeServerError(w, "Failed to list KYC holds", err)
+		return
+	}
+	if holds == nil {
+		holds = []*database.KYCHold{}
+	}
+
+	writeListPage(w, holds, next)
+}
+
+// openKYCHold reads the {id} path value and returns that hold if it is open.
+// It returns nil after writing an error response.
+func (pg *PaymentGateway) openKYCHold(ctx context.Context, w http.ResponseWriter, r *http.Request) *database.KYCHold {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid KYC hold ID", http.StatusBadRequest)
+		return nil
+	}
+	hold, err := pg.db.GetKYCHold(ctx, id)
+	switch {
+	case err != nil:
+		writeServerError(w, "Failed to get KYC hold", err)
+		return nil
+	case hold == nil:
+		http.Error(w, "KYC hold not found", http.StatusNotFound)
+		return nil
+	case hold.Status != database.KYCHoldOpen:
+		http.Error(w, fmt.Sprintf("KYC hold is already %s", hold.Status), http.StatusConflict)
+		return nil
+	}
+	return hold
+}
+
+// POST /admin/kyc-holds/{id}/recheck - Check the freelancer now, releasing if they passed
+func (pg *PaymentGateway) recheckKYCHoldHandler(w http.ResponseWriter, r *http.Request) {
+	if pg.kyc == nil {
+		http.Error(w, "KYC checks are not configured; set KYC_SOURCE", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	hold := pg.openKYCHold(ctx, w, r)
+	if hold == nil {
+		return
+	}
+	hold, transaction, err := pg.recheckKYCHold(ctx, hold)
+	switch {
+	case errors.Is(err, database.ErrKYCHoldResolved):
+		http.Error(w, "KYC hold is already resolved", http.StatusConflict)
+		return
+	case errors.Is(err, database.ErrStatusConflict):
+		http.Error(w, "Payment is no longer held for KYC", http.StatusConflict)
+		return
+	case err != nil && hold.Status == database.KYCHoldOpen:
+		writeServerError(w, "Failed to recheck KYC", err)
+		return
+	case err != nil:
+		// Cleared, but the release failed; the job is deposited again
+		log.Printf("Warning: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(KYCRecheckResponse{Hold: hold, Transaction: transaction})
+}
+
+// POST /admin/kyc-holds/{id}/withdraw - Return the job to deposited without releasing it
+func (pg *PaymentGateway) withdrawKYCHoldHandler(w http.ResponseWriter, r *http.Request) {
+	var req KYCWithdrawRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hold := pg.openKYCHold(ctx, w, r)
+	if hold == nil {
+		return
+	}
+	hold, err := pg.db.ResolveKYCHold(ctx, hold.ID, database.KYCHoldWithdrawn, hold.KYCStatus, actor, req.Reason)
+	switch {
+	case errors.Is(err, database.ErrKYCHoldResolved):
+		http.Error(w, "KYC hold is already resolved", http.StatusConflict)
+		return
+	case errors.Is(err, database.ErrStatusConflict):
+		http.Error(w, "Payment is no longer held for KYC", http.StatusConflict)
+		return
+	case err != nil:
+		writeServerError(w, "Failed to withdraw KYC hold", err)
+		return
+	}
+	log.Printf("KYC hold %d of application %d withdrawn by %s", hold.ID, hold.ApplicationID, actor)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/workpool"
+)
+
+// Worker states
+const (
+	workerRunning = "running"
+	workerExited  = "exited"  // returned on its own, e.g. disabled by configuration
+	workerStopped = "stopped" // returned after shutdown began
+)
+
+// lifecycle ties the gateway's background goroutines to one root context.
+// Workers and event subscriptions run under root, which shutdown cancels at
+// once; webhook deliveries run under drain, which is only cancelled when the
+// shutdown grace period runs out, so events already under way still arrive.
+// Every goroutine is counted, so one that outlives shutdown is reported.
+type lifecycle struct {
+	root  context.Context
+	stop  context.CancelFunc
+	drain context.Context
+	abort context.CancelFunc
+
+	started  time.Time
+	wg       sync.WaitGroup
+	stopping atomic.Bool
+
+	mu      sync.Mutex
+	workers map[string]*workerState
+
+	deliveries atomic.Int64 // webhook deliveries in flight
+	watchers   atomic.Int64 // requests waiting on a status change
+}
+
+type workerState struct {
+	running   int
+	starts    int
+	startedAt time.Time
+	exitedAt  time.Time
+	state     string
+}
+
+func newLifecycle() *lifecycle {
+	l := &lifecycle{started: time.Now(), workers: make(map[string]*workerState)}
+	l.root, l.stop = context.WithCancel(context.Background())
+	l.drain, l.abort = context.WithCancel(context.Background())
+	return l
+}
+
+// goWorker runs a background worker under the root context. A name may be
+// started more than once, e.g. a catch-up run; each run is counted.
+func (pg *PaymentGateway) goWorker(name string, run func(context.Context)) {
+	l := pg.life
+	l.mu.Lock()
+	w, ok := l.workers[name]
+	if !ok {
+		w = &workerState{}
+		l.workers[name] = w
+	}
+	w.running++
+	w.starts++
+	w.startedAt = time.Now()
+	w.state = workerRunning
+	l.mu.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			w.running--
+			w.exitedAt = time.Now()
+			if w.running > 0 {
+				return
+			}
+			w.state = workerExited
+			if l.stopping.Load() {
+				w.state = workerStopped
+			}
+		}()
+		run(l.root)
+	}()
+}
+
+// goDelivery runs a webhook delivery, giving it up to timeout and whatever is
+// left of the shutdown grace period
+func (pg *PaymentGateway) goDelivery(timeout time.Duration, deliver func(context.Context)) {
+	l := pg.life
+	l.deliveries.Add(1)
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer l.deliveries.Add(-1)
+
+		ctx, cancel := context.WithTimeout(l.drain, timeout)
+		defer cancel()
+		deliver(ctx)
+	}()
+}
+
+// Shutdown stops the background workers and waits for them and in-flight
+// webhook deliveries until ctx ends. It then cancels what is left and
+// returns an error naming the workers that haven't returned.
+func (pg *PaymentGateway) Shutdown(ctx context.Context) error {
+	l := pg.life
+	l.stopping.Store(true)
+	l.stop()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		l.abort()
+		return nil
+	case <-ctx.Done():
+	}
+	l.abort()
+
+	return fmt.Errorf("shutdown timed out with workers [%s] and %d webhook deliveries still running", strings.Join(runningWorkers(pg.workerStates()), ", "), l.deliveries.Load())
+}
+
+// WorkerState is one background worker as /debug/vars reports it
+type WorkerState struct {
+	Name      string     `json:"name"`
+	State     string     `json:"state"`   // running, exited or stopped
+	Running   int        `json:"running"` // goroutines running it; more than 1 only during a catch-up run
+	Starts    int        `json:"starts"`
+	StartedAt time.Time  `json:"started_at"`
+	ExitedAt  *time.Time `json:"exited_at,omitempty"`
+	LastRun   *time.Time `json:"last_run,omitempty"` // last completed pass, for workers that record one
+}
+
+func (pg *PaymentGateway) workerStates() []WorkerState {
+	l := pg.life
+	l.mu.Lock()
+	states := make([]WorkerState, 0, len(l.workers))
+	for name, w := range l.workers {
+		state := WorkerState{Name: name, State: w.state, Running: w.running, Starts: w.starts, StartedAt: w.startedAt}
+		if !w.exitedAt.IsZero() {
+			exitedAt := w.exitedAt
+			state.ExitedAt = &exitedAt
+		}
+		states = append(states, state)
+	}
+	l.mu.Unlock()
+
+	for i := range states {
+		if run, ok := pg.workers.Load(states[i].Name); ok {
+			at := run.(workerRun).at
+			states[i].LastRun = &at
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// DebugVars is the runtime state GET /debug/vars reports, in the spirit of
+// expvar: enough to spot goroutines or watchers piling up in a long-running
+// gateway
+type DebugVars struct {
+	UptimeSeconds     int64              `json:"uptime_seconds"`
+	ShuttingDown      bool               `json:"shutting_down"`
+	Goroutines        int                `json:"goroutines"`
+	Workers           []WorkerState      `json:"workers"`
+	WorkersRunning    int                `json:"workers_running"`
+	WebhookDeliveries int64              `json:"webhook_deliveries"` // in flight
+	StatusWatchers    int64              `json:"status_watchers"`    // /job-status requests waiting on a change
+	Submissions       workpool.Stats     `json:"submissions"`
+	UrgentSubmissions workpool.Stats     `json:"urgent_submissions"`
+	Connections       *ConnectionStats   `json:"connections,omitempty"`
+	Database          database.PoolStats `json:"database"`
+	HeapAllocBytes    uint64             `json:"heap_alloc_bytes"`
+	HeapObjects       uint64             `json:"heap_objects"`
+	GCCycles          uint32             `json:"gc_cycles"`
+}
+
+func (pg *PaymentGateway) debugVars() DebugVars {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	workers := pg.workerStates()
+	vars := DebugVars{
+		UptimeSeconds:     int64(time.Since(pg.life.started).Seconds()),
+		ShuttingDown:      pg.life.stopping.Load(),
+		Goroutines:        runtime.NumGoroutine(),
+		Workers:           workers,
+		WebhookDeliveries: pg.life.deliveries.Load(),
+		StatusWatchers:    pg.life.watchers.Load(),
+		Submissions:       pg.submissions.Stats(),
+		UrgentSubmissions: pg.urgent.Stats(),
+		Database:          pg.db.PoolStats(),
+		HeapAllocBytes:    mem.HeapAlloc,
+		HeapObjects:       mem.HeapObjects,
+		GCCycles:          mem.NumGC,
+	}
+	for _, w := range workers {
+		vars.WorkersRunning += w.Running
+	}
+	if pg.connections != nil {
+		stats := pg.connections.Stats()
+		vars.Connections = &stats
+	}
+	return vars
+}
+
+// GET /debug/vars - Goroutine counts and background worker states
+func (pg *PaymentGateway) debugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pg.debugVars())
+}
+
+// runningWorkers names the workers with a goroutine still running
+func runningWorkers(states []WorkerState) []string {
+	var names []string
+	for _, w := range states {
+		if w.Running > 0 {
+			names = append(names, w.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeListPage writes one page of a list endpoint. The body stays a plain
+// array; the cursor of the next page, if there is one, is sent in
+// X-Next-Cursor to pass back as ?cursor=.
+func writeListPage(w http.ResponseWriter, items any, next string) {
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
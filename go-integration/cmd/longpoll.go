@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// statusWait is a /job-status request's wait_for and timeout
+type statusWait struct {
+	statuses []paymentstatus.Status
+	timeout  time.Duration
+}
+
+// parseStatusWait reads wait_for, a comma-separated list of payment statuses,
+// and timeout, which defaults to and is capped at JOB_STATUS_MAX_WAIT. It
+// returns nil when the request doesn't wait.
+func (pg *PaymentGateway) parseStatusWait(r *http.Request) (*statusWait, error) {
+	query := r.URL.Query()
+	if query.Get("wait_for") == "" {
+		if query.Get("timeout") != "" {
+			return nil, fmt.Errorf("timeout needs wait_for")
+		}
+		return nil, nil
+	}
+	if pg.config.JobStatusMaxWait <= 0 {
+		return nil, fmt.Errorf("waiting is disabled (JOB_STATUS_MAX_WAIT=0)")
+	}
+
+	wait := &statusWait{timeout: pg.config.JobStatusMaxWait}
+	for _, raw := range strings.Split(query.Get("wait_for"), ",") {
+		status, err := paymentstatus.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid wait_for status %q", strings.TrimSpace(raw))
+		}
+		wait.statuses = append(wait.statuses, status)
+	}
+	if value := query.Get("timeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q: expected a positive duration such as 60s", value)
+		}
+		wait.timeout = min(timeout, pg.config.JobStatusMaxWait)
+	}
+	return wait, nil
+}
+
+// waitForStatus holds until the application's payment status is one of
+// wait's, reporting false if the timeout passes first. Writes by this gateway
+// wake it at once; changes made by another replica or directly in the
+// database are seen within JOB_STATUS_RECHECK_INTERVAL and DETAILS_CACHE_TTL.
+func (pg *PaymentGateway) waitForStatus(ctx context.Context, applicationID int32, wait *statusWait) (bool, error) {
+	deadline := time.NewTimer(wait.timeout)
+	defer deadline.Stop()
+
+	recheck := pg.config.JobStatusRecheckInterval
+	if recheck <= 0 {
+		recheck = 2 * time.Second
+	}
+	ticker := time.NewTicker(recheck)
+	defer ticker.Stop()
+	pg.life.watchers.Add(1)
+	defer pg.life.watchers.Add(-1)
+
+	for {
+		changed := pg.db.StatusChanged(applicationID)
+
+		readCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		details, err := pg.db.GetApplicationPaymentDetails(readCtx, applicationID)
+		cancel()
+		if err != nil {
+			return false, err
+		}
+		if slices.Contains(wait.statuses, details.PaymentStatus) {
+			return true, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-deadline.C:
+			return false, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
@@ -3,76 +3,110 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 
 	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/address"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/clientlimit"
 	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/jobid"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
 	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/tags"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/trace"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/webhook"
 )
 
-type PaymentGateway struct {
-	client *payment.Client
-	config *config.Config
-	db     *database.DB
-}
-
 // Request/Response types for your application flow
 type PostJobRequest struct {
-	JobID             uint64 `json:"job_id"`             // application.id (your escrow_job_id)
-	FreelancerAddress string `json:"freelancer_address"` // applicant wallet
-	USDAmount         string `json:"usd_amount"`         // agreed_usd_amount
-	ClientAddress     string `json:"client_address"`     // poster wallet
+	JobID             uint64   `json:"job_id"`               // application.id (your escrow_job_id)
+	FreelancerAddress string   `json:"freelancer_address"`   // applicant wallet
+	USDAmount         string   `json:"usd_amount"`           // agreed_usd_amount
+	ClientAddress     string   `json:"client_address"`       // poster wallet
+	WebhookURL        string   `json:"webhook_url"`          // optional: also send this job's events here
+	QuotedETHUSDPrice string   `json:"quoted_eth_usd_price"` // optional: eth_usd_price of the /quote the client accepted
+	Tags              []string `json:"tags"`                 // optional: labels to filter lists, exports and reports by
+	Priority          string   `json:"priority"`             // optional: "urgent" to submit on the urgent lane
+	FeeStrategy       string   `json:"fee_strategy"`         // optional: slow, standard or fast instead of FEE_STRATEGY
+	PlatformFeeBPS    *int64   `json:"platform_fee_bps"`     // optional: fee the release is accounted at instead of FEE_PERCENTAGE, e.g. 0 for a promotion
+	FeeOverrideReason string   `json:"fee_override_reason"`  // required with platform_fee_bps
 }
 
 type JobStatusResponse struct {
-	JobID             uint64 `json:"job_id"`
-	ApplicationID     int32  `json:"application_id"`
-	FreelancerAddress string `json:"freelancer_address"`
-	ClientAddress     string `json:"client_address"`
-	USDAmount         string `json:"usd_amount"`
-	PaymentStatus     string `json:"payment_status"`
-	ApplicationStatus string `json:"application_status"`
-	TxHashDeposit     string `json:"tx_hash_deposit,omitempty"`
-	TxHashRelease     string `json:"tx_hash_release,omitempty"`
-	TxHashRefund      string `json:"tx_hash_refund,omitempty"`
+	JobID             uint64               `json:"job_id"`
+	ApplicationID     int32                `json:"application_id"`
+	FreelancerAddress string               `json:"freelancer_address"`
+	ClientAddress     string               `json:"client_address"`
+	USDAmount         string               `json:"usd_amount"`
+	USDAmountDisplay  string               `json:"usd_amount_display"`
+	PaymentStatus     paymentstatus.Status `json:"payment_status"`
+	ApplicationStatus string               `json:"application_status"`
+	TxHashDeposit     string               `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease     string               `json:"tx_hash_release,omitempty"`
+	TxHashRefund      string               `json:"tx_hash_refund,omitempty"`
+	TxURLDeposit      string               `json:"tx_url_deposit,omitempty"` // block explorer pages for the hashes above
+	TxURLRelease      string               `json:"tx_url_release,omitempty"`
+	TxURLRefund       string               `json:"tx_url_refund,omitempty"`
+
+	Contract          *ContractInfoResponse      `json:"contract,omitempty"`
+	DeferredOperation *DeferredOperationResponse `json:"deferred_operation,omitempty"`
+	Timeline          []TimelineEntry            `json:"timeline"`
+	GasCost           *GasCostResponse           `json:"gas_cost,omitempty"`
+	RetainerIDs       []int64                    `json:"retainer_ids,omitempty"`
+	EscrowedUSD       int64                      `json:"escrowed_usd"` // agreed amount plus top-ups not yet refunded
+	DepositTxHashes   []string                   `json:"deposit_tx_hashes,omitempty"`
+	TopUps            []TopUpResponse            `json:"top_ups,omitempty"`
+
+	// The amount in the client's and freelancer's display currencies
+	ClientDisplay     *events.DisplayAmount `json:"client_display,omitempty"`
+	FreelancerDisplay *events.DisplayAmount `json:"freelancer_display,omitempty"`
+
+	WaitTimedOut bool `json:"wait_timed_out,omitempty"` // wait_for was not reached within timeout
+}
+
+// GasCostResponse is the gas the gateway has spent on a job
+type GasCostResponse struct {
+	Transactions   int64  `json:"transactions"`
+	GasUsed        int64  `json:"gas_used"`
+	CostWei        string `json:"cost_wei"`
+	CostETHDisplay string `json:"cost_eth_display"`
+	CostUSD        string `json:"cost_usd"`
+	CostUSDDisplay string `json:"cost_usd_display"`
+}
+
+// TimelineEntry is one payment status transition of a job
+type TimelineEntry struct {
+	Status      string    `json:"status"`
+	TxHash      string    `json:"tx_hash,omitempty"`
+	TxURL       string    `json:"tx_url,omitempty"`
+	BlockNumber *int64    `json:"block_number,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Actor       string    `json:"actor"`
+	TraceID     string    `json:"trace_id,omitempty"` // the request that caused the change
 }
 
 type TransactionResponse struct {
 	TxHash      string `json:"tx_hash"`
+	TxURL       string `json:"tx_url,omitempty"`
 	BlockNumber uint64 `json:"block_number"`
 	GasUsed     uint64 `json:"gas_used"`
 	Success     bool   `json:"success"`
 	Error       string `json:"error,omitempty"`
 }
 
-func NewPaymentGateway(cfg *config.Config) (*PaymentGateway, error) {
-	// Initialize blockchain client
-	client, err := payment.NewClient(cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	// Initialize database connection
-	db, err := database.NewDB(cfg.DatabaseURL)
-	if err != nil {
-		client.Close()
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
-	}
-
-	return &PaymentGateway{
-		client: client,
-		config: cfg,
-		db:     db,
-	}, nil
-}
-
 // POST /post-job - Called when candidate accepts offer
 func (pg *PaymentGateway) postJobHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -86,12 +120,39 @@ func (pg *PaymentGateway) postJobHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Using application.id as escrow job_id
+	applicationID, err := jobid.ApplicationID(req.JobID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid job ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Compared and passed on in canonical form, whatever case the caller used
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{"freelancer_address", &req.FreelancerAddress},
+		{"client_address", &req.ClientAddress},
+	} {
+		canonical, err := address.Canonical(*field.value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s: %v", field.name, err), http.StatusBadRequest)
+			return
+		}
+		*field.value = canonical
+	}
+
+	// Detached from the request so a client disconnect cannot abandon a broadcast transaction
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	// Validate the application is ready for blockchain operations
-	applicationID := int32(req.JobID) // Using application.id as escrow job_id
 	if err := pg.db.ValidateApplicationForBlockchain(ctx, applicationID); err != nil {
+		if database.IsTimeout(err) {
+			writeServerError(w, "Application validation failed", err)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Application validation failed: %v", err), http.StatusBadRequest)
 		return
 	}
@@ -99,159 +160,524 @@ func (pg *PaymentGateway) postJobHandler(w http.ResponseWriter, r *http.Request)
 	// Get application details from database
 	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get application details: %v", err), http.StatusInternalServerError)
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+
+	if details.PaymentStatus == paymentstatus.PendingReview {
+		http.Error(w, "Cannot post job: payment is held for review", http.StatusConflict)
 		return
 	}
 
 	// Verify the request matches database data
-	if details.ApplicantWalletAddress == nil || *details.ApplicantWalletAddress != req.FreelancerAddress {
+	if details.ApplicantWalletAddress == nil || !address.Equal(*details.ApplicantWalletAddress, req.FreelancerAddress) {
 		http.Error(w, "Freelancer address mismatch", http.StatusBadRequest)
 		return
 	}
-	if details.PosterWalletAddress == nil || *details.PosterWalletAddress != req.ClientAddress {
+	if details.PosterWalletAddress == nil || !address.Equal(*details.PosterWalletAddress, req.ClientAddress) {
 		http.Error(w, "Client address mismatch", http.StatusBadRequest)
 		return
 	}
 
-	// Parse addresses and amount
-	freelancerAddr := common.HexToAddress(req.FreelancerAddress)
-	clientAddr := common.HexToAddress(req.ClientAddress)
 	usdAmount, ok := new(big.Int).SetString(req.USDAmount, 10)
-	if !ok {
+	if !ok || !usdAmount.IsInt64() {
 		http.Error(w, "Invalid USD amount", http.StatusBadRequest)
 		return
 	}
 
-	// Post job to blockchain
-	result, err := pg.client.PostJob(ctx, req.JobID, freelancerAddr, usdAmount, clientAddr)
+	if req.WebhookURL != "" {
+		if err := webhook.ValidateURL(req.WebhookURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	jobTags, err := tags.NormalizeAll(req.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	priority, err := parsePriority(req.Priority)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	feeStrategy, err := parseFeeStrategy(req.FeeStrategy)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to post job to blockchain: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	feeOverride, ok := pg.parseFeeOverride(w, r, req, applicationID)
+	if !ok {
+		return
+	}
+	var tenant string
+	if raw := r.Header.Get(TenantHeader); raw != "" {
+		if tenant, err = clientlimit.NormalizeSubject(clientlimit.Tenant, raw); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s: %v", TenantHeader, err), http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Update database with transaction hash
-	if err := pg.db.UpdatePaymentStatus(ctx, applicationID, "deposit_initiated", &result.TxHash, "deposit"); err != nil {
-		log.Printf("Warning: Failed to update payment status in database: %v", err)
+	quotedPrice, ok := pg.quotedPrice(ctx, w, req.QuotedETHUSDPrice)
+	if !ok {
+		return
 	}
 
-	response := TransactionResponse{
-		TxHash:      result.TxHash,
-		BlockNumber: result.BlockNumber,
-		GasUsed:     result.GasUsed,
-		Success:     result.Success,
+	pg.recordParties(ctx, details)
+
+	if !pg.checkNoDeferredOperation(ctx, w, applicationID) {
+		return
+	}
+	violations, ok := pg.escrowViolations(ctx, w, details, usdAmount.Int64(), applicationID)
+	if !ok || pg.rejectBlocked(w, applicationID, opPostJob, violations) {
+		return
+	}
+	consensus, ok := pg.consensusViolations(ctx, w, usdAmount.Int64())
+	if !ok {
+		return
+	}
+	violations = append(violations, consensus...)
+	if !pg.checkClientLimits(ctx, w, details, tenant, usdAmount.Int64()) {
+		return
 	}
 
-	if result.Error != nil {
-		response.Error = result.Error.Error()
+	// Registered before submitting so events of a deferred post reach it too
+	if req.WebhookURL != "" {
+		if err := pg.db.SetJobWebhook(ctx, applicationID, req.WebhookURL); err != nil {
+			writeServerError(w, "Failed to register job webhook", err)
+			return
+		}
+	}
+	if len(jobTags) > 0 {
+		actor := r.Header.Get("X-Actor")
+		if actor == "" {
+			actor = "api"
+		}
+		if err := pg.db.SetJobTags(ctx, applicationID, jobTags, actor); err != nil {
+			writeServerError(w, "Failed to tag job", err)
+			return
+		}
+	}
+	if feeOverride != nil {
+		if err := pg.setFeeOverride(ctx, feeOverride); err != nil {
+			writeServerError(w, "Failed to set fee override", err)
+			return
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	// Pins the escrow to this application before anything reaches the chain
+	if err := pg.db.RecordEscrowJob(ctx, applicationID, req.JobID); err != nil {
+		if errors.Is(err, database.ErrEscrowJobConflict) {
+			http.Error(w, fmt.Sprintf("Cannot post job: %v", err), http.StatusConflict)
+			return
+		}
+		writeServerError(w, "Failed to record escrow job ID", err)
+		return
+	}
+	if tenant != "" {
+		if err := pg.db.RecordEscrowTenant(ctx, applicationID, tenant); err != nil {
+			writeServerError(w, "Failed to record escrow tenant", err)
+			return
+		}
+	}
+
+	params := database.OperationParams{
+		JobID:             req.JobID,
+		FreelancerAddress: req.FreelancerAddress,
+		ClientAddress:     req.ClientAddress,
+		USDAmount:         req.USDAmount,
+		QuotedETHUSDPrice: quotedPrice,
+		TraceID:           trace.ID(r.Context()),
+		Priority:          priority,
+		FeeStrategy:       feeStrategy,
+	}
+
+	if len(violations) > 0 {
+		pg.holdForReview(ctx, w, &database.Review{ApplicationID: applicationID, Operation: opPostJob, Params: params, PreviousStatus: details.PaymentStatus}, violations)
+		return
+	}
+
+	// Post job to blockchain
+	result, err := pg.submitOperation(ctx, applicationID, opPostJob, params)
+	if err != nil {
+		if pg.queueIfRetryable(ctx, w, applicationID, opPostJob, params, err) {
+			return
+		}
+		pg.writeChainError(w, "Failed to post job to blockchain", result, err)
+		return
+	}
+
+	pg.writeTransactionResponse(w, result)
 }
 
-// POST /complete-job?job_id=X - Called when poster approves work
+// POST /complete-job?job_id=X&mode=economical&priority=urgent&fee_strategy=fast - Called when poster approves work
 func (pg *PaymentGateway) completeJobHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	jobIDStr := r.URL.Query().Get("job_id")
-	jobID, err := strconv.ParseUint(jobIDStr, 10, 64)
+	jobID, applicationID, ok := parseJobID(w, r.URL.Query().Get("job_id"))
+	if !ok {
+		return
+	}
+
+	mode, err := pg.parseReleaseMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	priority, err := parsePriority(r.URL.Query().Get("priority"))
 	if err != nil {
-		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	feeStrategy, err := parseFeeStrategy(r.URL.Query().Get("fee_strategy"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if priority == priorityUrgent && mode == releaseModeEconomical {
+		http.Error(w, "An urgent release can't wait for an economical gas window", http.StatusBadRequest)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	applicationID := int32(jobID) // application.id is used as escrow job_id
-
 	// Get application details to verify payment status
 	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get application details: %v", err), http.StatusInternalServerError)
+		writeServerError(w, "Failed to get application details", err)
 		return
 	}
 
-	if details.PaymentStatus != "deposited" {
+	if details.PaymentStatus != paymentstatus.Deposited {
 		http.Error(w, fmt.Sprintf("Cannot complete job: payment status is '%s', expected 'deposited'", details.PaymentStatus), http.StatusBadRequest)
 		return
 	}
 
-	// Complete job on blockchain
-	result, err := pg.client.MarkJobCompleted(ctx, jobID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to complete job on blockchain: %v", err), http.StatusInternalServerError)
+	if !pg.checkNoDeferredOperation(ctx, w, applicationID) {
 		return
 	}
 
-	// Update database with release transaction hash
-	if err := pg.db.UpdatePaymentStatus(ctx, applicationID, "release_initiated", &result.TxHash, "release"); err != nil {
-		log.Printf("Warning: Failed to update payment status in database: %v", err)
+	// The approval expires if the release cannot be confirmed in time
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+	if err := pg.authorizeRelease(ctx, applicationID, actor); err != nil {
+		writeServerError(w, "Failed to authorize release", err)
+		return
 	}
 
-	response := TransactionResponse{
-		TxHash:      result.TxHash,
-		BlockNumber: result.BlockNumber,
-		GasUsed:     result.GasUsed,
-		Success:     result.Success,
+	params := database.OperationParams{JobID: jobID, TraceID: trace.ID(r.Context()), Priority: priority, FeeStrategy: feeStrategy}
+	if pg.holdIfKYCPending(ctx, w, details, params) {
+		return
+	}
+	// Urgent releases skip the freelancer's payout batching
+	if priority != priorityUrgent && pg.holdIfBatched(ctx, w, details, params, r.URL.Query().Get("mode")) {
+		return
+	}
+	if pg.scheduleIfEconomical(ctx, w, applicationID, params, mode) {
+		return
 	}
 
-	if result.Error != nil {
-		response.Error = result.Error.Error()
+	// Complete job on blockchain
+	result, err := pg.submitOperation(ctx, applicationID, opCompleteJob, params)
+	if err != nil {
+		if pg.queueIfRetryable(ctx, w, applicationID, opCompleteJob, params, err) {
+			return
+		}
+		pg.writeChainError(w, "Failed to complete job on blockchain", result, err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	pg.writeTransactionResponse(w, result)
 }
 
-// POST /cancel-job?job_id=X - Called for refunds
+// POST /cancel-job?job_id=X&reason=Y&priority=urgent&fee_strategy=slow - Called for refunds
 func (pg *PaymentGateway) cancelJobHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	jobIDStr := r.URL.Query().Get("job_id")
-	jobID, err := strconv.ParseUint(jobIDStr, 10, 64)
+	jobID, applicationID, ok := parseJobID(w, r.URL.Query().Get("job_id"))
+	if !ok {
+		return
+	}
+
+	reason, err := payment.ParseRefundReason(r.URL.Query().Get("reason"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid refund reason: expected one of %v", payment.RefundReasons), http.StatusBadRequest)
+		return
+	}
+	priority, err := parsePriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	feeStrategy, err := parseFeeStrategy(r.URL.Query().Get("fee_strategy"))
 	if err != nil {
-		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	applicationID := int32(jobID) // application.id is used as escrow job_id
-
 	// Get application details to verify payment status
 	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get application details: %v", err), http.StatusInternalServerError)
+		writeServerError(w, "Failed to get application details", err)
 		return
 	}
 
-	if details.PaymentStatus != "deposited" {
+	if details.PaymentStatus != paymentstatus.Deposited {
 		http.Error(w, fmt.Sprintf("Cannot cancel job: payment status is '%s', expected 'deposited'", details.PaymentStatus), http.StatusBadRequest)
 		return
 	}
 
+	if !pg.checkNoDeferredOperation(ctx, w, applicationID) {
+		return
+	}
+
+	violations, ok := pg.refundViolations(ctx, w, details)
+	if !ok || pg.rejectBlocked(w, applicationID, opCancelJob, violations) {
+		return
+	}
+
+	params := database.OperationParams{JobID: jobID, RefundReason: string(reason), TraceID: trace.ID(r.Context()), Priority: priority, FeeStrategy: feeStrategy}
+
+	if len(violations) > 0 {
+		pg.holdForReview(ctx, w, &database.Review{ApplicationID: applicationID, Operation: opCancelJob, Params: params, PreviousStatus: details.PaymentStatus}, violations)
+		return
+	}
+
 	// Cancel job on blockchain
-	result, err := pg.client.CancelJob(ctx, jobID)
+	result, err := pg.submitOperation(ctx, applicationID, opCancelJob, params)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to cancel job on blockchain: %v", err), http.StatusInternalServerError)
+		if pg.queueIfRetryable(ctx, w, applicationID, opCancelJob, params, err) {
+			return
+		}
+		pg.writeChainError(w, "Failed to cancel job on blockchain", result, err)
 		return
 	}
 
-	// Update database with refund transaction hash
-	if err := pg.db.UpdatePaymentStatus(ctx, applicationID, "refund_initiated", &result.TxHash, "refund"); err != nil {
+	pg.writeTransactionResponse(w, result)
+}
+
+// Chain operations that can be submitted directly or replayed from the deferred queue
+const (
+	opPostJob     = "post_job"
+	opCompleteJob = "complete_job"
+	opCancelJob   = "cancel_job"
+)
+
+// submitOperation runs a chain operation on its lane's submission pool, returning
+// workpool.ErrQueueFull without sending anything when the pool is saturated
+func (pg *PaymentGateway) submitOperation(ctx context.Context, applicationID int32, operation string, params database.OperationParams) (*payment.TransactionResult, error) {
+	var result *payment.TransactionResult
+	var err error
+	pool, laneCtx := pg.lane(ctx, params)
+	laneCtx = payment.WithFeeStrategy(laneCtx, pg.feeStrategy(operation, params))
+	laneCtx = pg.trackBroadcasts(laneCtx, applicationID, operation, params.JobID)
+	if poolErr := pool.Do(ctx, func() {
+		result, err = pg.sendOperation(laneCtx, applicationID, operation, params)
+	}); poolErr != nil {
+		return nil, poolErr
+	}
+	if result != nil && result.Success {
+		pg.recordInclusion(result.InclusionTime)
+	}
+	return result, err
+}
+
+// sendOperation sends a chain operation and records its transaction hash in the database
+func (pg *PaymentGateway) sendOperation(ctx context.Context, applicationID int32, operation string, params database.OperationParams) (*payment.TransactionResult, error) {
+	var result *payment.TransactionResult
+	var err error
+	var status paymentstatus.Status
+	var txType string
+	var intent *database.ChainIntent
+
+	switch operation {
+	case opPostJob, opCompleteJob, opCancelJob:
+		// Any other job ID would move a different escrow than the application's
+		if mapped, err := jobid.ApplicationID(params.JobID); err != nil || mapped != applicationID {
+			return nil, fmt.Errorf("escrow job ID %d does not belong to application %d", params.JobID, applicationID)
+		}
+	}
+
+	switch operation {
+	case opPostJob:
+		usdAmount, ok := new(big.Int).SetString(params.USDAmount, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid USD amount %q", params.USDAmount)
+		}
+		freelancerAddr := common.HexToAddress(params.FreelancerAddress)
+		clientAddr := common.HexToAddress(params.ClientAddress)
+		existing, lookupErr := pg.existingPost(ctx, applicationID, params.JobID, freelancerAddr, usdAmount, clientAddr)
+		if lookupErr != nil || existing != nil {
+			return existing, lookupErr
+		}
+		if err := pg.checkPriceDeviation(ctx, params); err != nil {
+			return nil, err
+		}
+		if intent, err = pg.db.BeginChainIntent(ctx, applicationID, operation, params); err != nil {
+			return nil, err
+		}
+		result, err = pg.client.PostJob(ctx, params.JobID, freelancerAddr, usdAmount, clientAddr)
+		status, txType = paymentstatus.DepositInitiated, "deposit"
+	case opCompleteJob:
+		authorization, authErr := pg.activeReleaseAuthorization(ctx, applicationID)
+		if authErr != nil {
+			return nil, authErr
+		}
+		if intent, err = pg.db.BeginChainIntent(ctx, applicationID, operation, params); err != nil {
+			return nil, err
+		}
+		result, err = pg.client.MarkJobCompleted(ctx, params.JobID)
+		status, txType = paymentstatus.ReleaseInitiated, "release"
+		if authorization != nil && result != nil && result.TxHash != "" {
+			if dbErr := pg.db.RecordReleaseAuthorizationTx(ctx, authorization.ID, result.TxHash); dbErr != nil {
+				log.Printf("Warning: Failed to record release on its authorization: %v", dbErr)
+			}
+		}
+	case opCancelJob:
+		if intent, err = pg.db.BeginChainIntent(ctx, applicationID, operation, params); err != nil {
+			return nil, err
+		}
+		result, err = pg.client.CancelJob(ctx, params.JobID)
+		status, txType = paymentstatus.RefundInitiated, "refund"
+	default:
+		return nil, fmt.Errorf("unknown operation %q", operation)
+	}
+	if err != nil {
+		// A broadcast transaction still needs its hash recorded so it isn't resent
+		var pending *payment.TransactionPendingError
+		if errors.As(err, &pending) {
+			log.Printf("%s%s for application %d sent as %s, awaiting its receipt", tracePrefix(params.TraceID), operation, applicationID, result.TxHash)
+			change := database.StatusChange{ApplicationID: applicationID, Status: status, TxHash: &result.TxHash, TxType: txType, Actor: database.ActorGateway, TraceID: params.TraceID, IntentID: intent.ID}
+			if dbErr := pg.db.ApplyStatusChange(ctx, change); dbErr != nil {
+				log.Printf("Warning: Failed to update payment status in database: %v", dbErr)
+			}
+			pg.wakePoller()
+		} else {
+			pg.resolveFailedIntent(intent, err)
+		}
+		return result, err
+	}
+	log.Printf("%s%s for application %d sent as %s", tracePrefix(params.TraceID), operation, applicationID, result.TxHash)
+
+	// Update database with transaction hash
+	change := database.StatusChange{
+		ApplicationID: applicationID,
+		Status:        status,
+		TxHash:        &result.TxHash,
+		TxType:        txType,
+		Actor:         database.ActorGateway,
+		TraceID:       params.TraceID,
+		IntentID:      intent.ID,
+	}
+	if result.BlockNumber > 0 {
+		blockNumber := int64(result.BlockNumber)
+		change.BlockNumber = &blockNumber
+	}
+	if err := pg.db.ApplyStatusChange(ctx, change); err != nil {
 		log.Printf("Warning: Failed to update payment status in database: %v", err)
 	}
+	pg.wakePoller()
+
+	pg.recordGasCost(ctx, applicationID, operation, result)
+
+	if operation == opCompleteJob && result.Success {
+		pg.accrueReserve(ctx, applicationID, params.JobID, result.TxHash)
+	}
+
+	// Top-ups are settled along with the escrow they added to
+	if (operation == opCompleteJob || operation == opCancelJob) && result.Success {
+		if err := pg.settleTopUps(ctx, applicationID, txType, params.RefundReason); err != nil {
+			log.Printf("Warning: Failed to settle top-ups of job %d: %v", params.JobID, err)
+		}
+	}
+
+	// Record the refund reason for reporting
+	if operation == opCancelJob && result.Success {
+		var usdAmount int32
+		if details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID); err == nil && details.AgreedUSDAmount != nil {
+			usdAmount = *details.AgreedUSDAmount
+		}
+		if err := pg.db.RecordRefund(ctx, applicationID, params.RefundReason, usdAmount, result.TxHash); err != nil {
+			log.Printf("Warning: Failed to record refund reason in database: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// recordGasCost stores what a mined transaction cost in wei and in USD at the
+// current rate, once per transaction. The gas of a transaction that reverted
+// is also posted to the ledger as waste.
+func (pg *PaymentGateway) recordGasCost(ctx context.Context, applicationID int32, operation string, result *payment.TransactionResult) {
+	costWei := result.GasCost()
+	if costWei == nil {
+		return
+	}
+
+	cost := database.TransactionCost{
+		ApplicationID:     applicationID,
+		Operation:         operation,
+		TxHash:            result.TxHash,
+		GasUsed:           result.GasUsed,
+		EffectiveGasPrice: result.EffectiveGasPrice.String(),
+		CostWei:           costWei.String(),
+	}
+
+	if price, err := pg.marketPrice(ctx); err != nil {
+		log.Printf("Warning: Failed to get ETH price for gas accounting: %v", err)
+	} else {
+		priceStr := price.String()
+		usdStr := amounts.Decimal(amounts.WeiToUSD(costWei, price), 6, amounts.HalfUp)
+		cost.ETHUSDPrice = &priceStr
+		cost.CostUSD = &usdStr
+	}
+
+	if err := pg.db.RecordTransactionCost(ctx, cost); err != nil {
+		log.Printf("Warning: Failed to record gas cost: %v", err)
+	}
+
+	if !result.Success {
+		pg.recordGasWaste(ctx, applicationID, operation, result.TxHash, costWei, ledger.WasteReverted)
+	}
+}
+
+// recordGasWaste posts the gas of a transaction that achieved nothing to the
+// ledger. Posting the same transaction again does nothing.
+func (pg *PaymentGateway) recordGasWaste(ctx context.Context, applicationID int32, operation, txHash string, costWei *big.Int, reason string) {
+	t := ledger.GasWaste(applicationID, txHash, costWei, reason)
+	if t == nil {
+		return
+	}
+	posted, err := pg.db.PostLedgerTransaction(ctx, t)
+	if err != nil {
+		log.Printf("Warning: Failed to record gas wasted by %s: %v", txHash, err)
+	} else if posted {
+		log.Printf("Recorded %s wei of gas wasted by %s %s transaction %s", costWei, reason, operation, txHash)
+	}
+}
 
-	response := TransactionResponse{
+// writeTransactionResponse encodes a chain transaction result as JSON
+func (pg *PaymentGateway) writeTransactionResponse(w http.ResponseWriter, result *payment.TransactionResult) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pg.newTransactionResponse(result))
+}
+
+func (pg *PaymentGateway) newTransactionResponse(result *payment.TransactionResult) *TransactionResponse {
+	response := &TransactionResponse{
 		TxHash:      result.TxHash,
+		TxURL:       pg.explorer.Tx(result.TxHash),
 		BlockNumber: result.BlockNumber,
 		GasUsed:     result.GasUsed,
 		Success:     result.Success,
@@ -260,34 +686,50 @@ func (pg *PaymentGateway) cancelJobHandler(w http.ResponseWriter, r *http.Reques
 	if result.Error != nil {
 		response.Error = result.Error.Error()
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return response
 }
 
-// GET /job-status?job_id=X - Get application payment status
+// GET /job-status?job_id=X - Get application payment status. With
+// wait_for=status[,status] the response is held until the status is one of
+// them or timeout passes.
 func (pg *PaymentGateway) getJobStatusHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	jobIDStr := r.URL.Query().Get("job_id")
-	jobID, err := strconv.ParseUint(jobIDStr, 10, 64)
+	jobID, applicationID, ok := parseJobID(w, r.URL.Query().Get("job_id"))
+	if !ok {
+		return
+	}
+
+	wait, err := pg.parseStatusWait(r)
 	if err != nil {
-		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	waitTimedOut := false
+	if wait != nil {
+		reached, err := pg.waitForStatus(r.Context(), applicationID, wait)
+		if err != nil {
+			if r.Context().Err() != nil {
+				return // the client went away
+			}
+			writeServerError(w, "Failed to get application details", err)
+			return
+		}
+		waitTimedOut = !reached
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	applicationID := int32(jobID)
+	locale := localeFor(r)
 
 	// Get application details from database
 	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get application details: %v", err), http.StatusInternalServerError)
+		writeServerError(w, "Failed to get application details", err)
 		return
 	}
 
@@ -297,80 +739,132 @@ func (pg *PaymentGateway) getJobStatusHandler(w http.ResponseWriter, r *http.Req
 		FreelancerAddress: *details.ApplicantWalletAddress,
 		ClientAddress:     *details.PosterWalletAddress,
 		USDAmount:         fmt.Sprintf("%d", *details.AgreedUSDAmount),
+		USDAmountDisplay:  locale.USDInt(int64(*details.AgreedUSDAmount)),
 		PaymentStatus:     details.PaymentStatus,
 		ApplicationStatus: details.ApplicationStatus,
+		Contract:          pg.contract.Load(),
+		WaitTimedOut:      waitTimedOut,
 	}
+	response.ClientDisplay, response.FreelancerDisplay = pg.jobDisplayAmounts(ctx, locale, applicationID, *details.AgreedUSDAmount)
 
 	if details.EscrowTxHashDeposit != nil {
 		response.TxHashDeposit = *details.EscrowTxHashDeposit
+		response.TxURLDeposit = pg.explorer.Tx(*details.EscrowTxHashDeposit)
 	}
 	if details.EscrowTxHashRelease != nil {
 		response.TxHashRelease = *details.EscrowTxHashRelease
+		response.TxURLRelease = pg.explorer.Tx(*details.EscrowTxHashRelease)
 	}
 	if details.EscrowTxHashRefund != nil {
 		response.TxHashRefund = *details.EscrowTxHashRefund
+		response.TxURLRefund = pg.explorer.Tx(*details.EscrowTxHashRefund)
+	}
+
+	// Include the status history for progress trackers
+	events, err := pg.db.GetPaymentEvents(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get payment events", err)
+		return
+	}
+	response.Timeline = make([]TimelineEntry, 0, len(events))
+	for _, event := range events {
+		entry := TimelineEntry{
+			Status:      event.Status,
+			BlockNumber: event.BlockNumber,
+			Timestamp:   event.CreatedAt,
+			Actor:       event.Actor,
+		}
+		if event.TxHash != nil {
+			entry.TxHash = *event.TxHash
+			entry.TxURL = pg.explorer.Tx(*event.TxHash)
+		}
+		if event.TraceID != nil {
+			entry.TraceID = *event.TraceID
+		}
+		response.Timeline = append(response.Timeline, entry)
+	}
+
+	// Include what the gateway has spent on gas for this job
+	if cost, err := pg.db.GetJobGasCost(ctx, applicationID); err != nil {
+		log.Printf("Warning: Failed to get job gas cost: %v", err)
+	} else if cost.Transactions > 0 {
+		response.GasCost = &GasCostResponse{
+			Transactions:   cost.Transactions,
+			GasUsed:        cost.GasUsed,
+			CostWei:        cost.CostWei,
+			CostETHDisplay: displayWei(locale, cost.CostWei),
+			CostUSD:        cost.CostUSD,
+			CostUSDDisplay: displayUSD(locale, cost.CostUSD),
+		}
+	}
+
+	// Link recurring retainers created for this application
+	if ids, err := pg.db.ListApplicationRetainerIDs(ctx, applicationID); err != nil {
+		log.Printf("Warning: Failed to get retainers: %v", err)
+	} else {
+		response.RetainerIDs = ids
+	}
+
+	// Include top-ups agreed after the original deposit
+	if topUps, err := pg.db.ListTopUps(ctx, applicationID); err != nil {
+		log.Printf("Warning: Failed to get top-ups: %v", err)
+	} else {
+		summary := newTopUpsResponse(details, topUps, pg.explorer)
+		response.EscrowedUSD = summary.EscrowedUSD
+		response.DepositTxHashes = summary.DepositTxHashes
+		if len(summary.TopUps) > 0 {
+			response.TopUps = summary.TopUps
+		}
+	}
+
+	// Include any operation waiting for gas prices to drop
+	if op, err := pg.db.GetPendingDeferredOperation(ctx, applicationID); err != nil {
+		log.Printf("Warning: Failed to get deferred operation: %v", err)
+	} else if op != nil {
+		deferred := newDeferredOperationResponse(op, pg.explorer)
+		response.DeferredOperation = &deferred
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// POST /confirm-deposit?job_id=X - Called to confirm deposit (for polling/webhook)
+// POST /confirm-deposit?job_id=X - Called to confirm deposit (legacy; the status poller settles deposits itself)
 func (pg *PaymentGateway) confirmDepositHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	pg.markLegacyConfirm(w)
 
-	jobIDStr := r.URL.Query().Get("job_id")
-	jobID, err := strconv.ParseUint(jobIDStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+	_, applicationID, ok := parseJobID(w, r.URL.Query().Get("job_id"))
+	if !ok {
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	applicationID := int32(jobID)
-
-	// Update payment status to deposited
-	if err := pg.db.UpdatePaymentStatus(ctx, applicationID, "deposited", nil, ""); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update payment status: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	pg.confirmStatus(ctx, w, applicationID, depositConfirmation)
 }
 
-// POST /confirm-release?job_id=X - Called to confirm release (for polling/webhook)
+// POST /confirm-release?job_id=X - Called to confirm release (legacy; the status poller settles releases itself)
 func (pg *PaymentGateway) confirmReleaseHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	pg.markLegacyConfirm(w)
 
-	jobIDStr := r.URL.Query().Get("job_id")
-	jobID, err := strconv.ParseUint(jobIDStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+	_, applicationID, ok := parseJobID(w, r.URL.Query().Get("job_id"))
+	if !ok {
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	applicationID := int32(jobID)
-
-	// Update payment status to released
-	if err := pg.db.UpdatePaymentStatus(ctx, applicationID, "released", nil, ""); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update payment status: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	pg.confirmStatus(ctx, w, applicationID, releaseConfirmation)
 }
 
 // GET /eth-price - Get current ETH price
@@ -380,12 +874,18 @@ func (pg *PaymentGateway) getEthPriceHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	price, err := pg.client.GetETHUSDPrice(ctx)
+	query := r.URL.Query()
+	if query.Has("at_block") || query.Has("at_time") {
+		pg.historicalEthPrice(ctx, w, query)
+		return
+	}
+
+	price, err := pg.oracle.GetETHUSDPrice(ctx)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get ETH price: %v", err), http.StatusInternalServerError)
+		writeServerError(w, "Failed to get ETH price", err)
 		return
 	}
 
@@ -402,8 +902,14 @@ func main() {
 	cfg := config.Load()
 
 	// Validate required configuration
+	if cfg.DeploymentError != nil {
+		log.Fatalf("Invalid DEPLOYMENT_MANIFEST: %v", cfg.DeploymentError)
+	}
+	if d := cfg.Deployment; d != nil {
+		log.Printf("Using contract %s on chain %d from %s, deployed at block %d", d.ContractAddress, d.NetworkID, cfg.DeploymentManifest, d.DeployBlock)
+	}
 	if cfg.ContractAddress == "" {
-		log.Fatal("CONTRACT_ADDRESS environment variable is required")
+		log.Fatal("CONTRACT_ADDRESS environment variable or DEPLOYMENT_MANIFEST is required")
 	}
 	if cfg.PrivateKey == "" {
 		log.Fatal("PRIVATE_KEY environment variable is required")
@@ -414,36 +920,266 @@ func main() {
 	if cfg.DatabaseURL == "" {
 		log.Fatal("DATABASE_URL environment variable is required")
 	}
+	if cfg.ServerWriteTimeout > 0 && cfg.JobStatusMaxWait >= cfg.ServerWriteTimeout {
+		log.Fatal("JOB_STATUS_MAX_WAIT must be shorter than SERVER_WRITE_TIMEOUT, or waiting responses are cut off")
+	}
 
 	// Initialize payment gateway
 	gateway, err := NewPaymentGateway(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize payment gateway: %v", err)
 	}
-	defer gateway.client.Close()
-	defer gateway.db.Close()
+	defer gateway.Close()
+
+	// Stay on a contract adopted through POST /admin/contract
+	if err := gateway.applyStoredContractUpdate(context.Background()); err != nil {
+		log.Fatalf("Failed to apply contract update: %v", err)
+	}
+
+	// Start read-only if a maintenance window is open, and follow toggles made
+	// on other replicas
+	if err := gateway.refreshMaintenance(context.Background()); err != nil {
+		log.Fatalf("Failed to load maintenance mode: %v", err)
+	}
+	gateway.goWorker("maintenance_refresh", gateway.runMaintenanceRefresh)
+
+	// Refuse to sign if the kill switch was tripped, and watch the signer
+	// wallets for transactions the gateway didn't send
+	if err := gateway.refreshSigningHalt(context.Background()); err != nil {
+		log.Fatalf("Failed to load kill switch: %v", err)
+	}
+	gateway.goWorker("wallet_monitor", gateway.runWalletMonitor)
+
+	// Settle chain operations a crash left without an outcome
+	gateway.goWorker("intent_recovery", gateway.runIntentRecovery)
+
+	// Cost every signed transaction once its nonce is mined, including ones
+	// replaced or cancelled while the gateway waited on another
+	gateway.goWorker("broadcast_resolver", gateway.runBroadcastResolver)
+
+	// Submit operations deferred by gas price spikes
+	gateway.goWorker("deferred_operations", gateway.runDeferredOperations)
+
+	// Settle initiated transactions from their receipts
+	gateway.goWorker("status_poller", gateway.runStatusPoller)
+
+	// Open and fund recurring retainer periods
+	gateway.goWorker("retainers", gateway.runRetainers)
+
+	// Release jobs the platform approves through PLATFORM_EVENTS_CHANNEL
+	gateway.goWorker("platform_events", gateway.runPlatformEvents)
+
+	// Act on application status transitions per AUTOMATION_RULES
+	gateway.goWorker("automation", gateway.runAutomation)
+
+	// Probe every RPC provider so requests fail over before they meet an outage
+	gateway.goWorker("rpc_probe", gateway.runRPCProbes)
+
+	// Record RPC, database and worker health for /admin/health-history
+	gateway.goWorker("health_history", gateway.runHealthHistory)
+
+	// Track the implementation behind an upgradeable escrow contract
+	gateway.goWorker("proxy_monitor", gateway.runProxyMonitor)
+
+	// Export settled jobs to ARCHIVE_BUCKET_URL for long-term storage
+	gateway.goWorker("archiver", gateway.runArchiver)
+
+	// Total each finished UTC day's money movement for reconciliation
+	gateway.goWorker("settlement_summaries", gateway.runSettlementSummaries)
+
+	// Watch the price feed's heartbeat, failing over to ORACLE_FALLBACK_FEED
+	gateway.goWorker("price_feed_monitor", gateway.runPriceFeedMonitor)
+
+	// Sample base fees so economical releases can wait for a cheap hour
+	gateway.goWorker("base_fee_sampler", gateway.runBaseFeeSampler)
+
+	// Watch base fees, block fullness and inclusion times to warn callers of slow transactions
+	gateway.goWorker("congestion_monitor", gateway.runCongestionMonitor)
+
+	// Prove the key, node and nonce state work before /readyz lets traffic in
+	gateway.goWorker("canary", gateway.runCanary)
+
+	// Record deposits clients make through funding links, and links that expire
+	gateway.goWorker("funding_links", gateway.runFundingLinks)
+
+	// Record escrow events from a checkpoint that survives restarts and reorgs
+	gateway.goWorker("event_indexer", gateway.runEventIndexer)
+
+	// Submit releases held for KYC once their freelancers pass
+	gateway.goWorker("kyc_rechecks", gateway.runKYCRechecks)
+
+	// Confirmations can be triggered from outside the platform, so they are
+	// signature and replay checked when REQUEST_SIGNING_SECRET is set
+	confirmDeposit := gateway.requireSignedRequest(gateway.confirmDepositHandler)
+	confirmRelease := gateway.requireSignedRequest(gateway.confirmReleaseHandler)
+
+	// Tax exports hold every freelancer's earnings, so only ADMIN_API_KEYS
+	// holders can read them and each read is audited
+	taxExport := gateway.requireAdminKey(gateway.taxExportHandler)
+	freelancerTaxExport := gateway.requireAdminKey(gateway.freelancerTaxExportHandler)
+
+	// The kill switch is only thrown either way by two ADMIN_API_KEYS holders,
+	// or one with an approval signed by a KILL_SWITCH_APPROVERS key
+	activateKillSwitch := gateway.requireAdminKey(gateway.activateKillSwitchHandler)
+	deactivateKillSwitch := gateway.requireAdminKey(gateway.deactivateKillSwitchHandler)
+	verifyAuditLog := gateway.requireAdminKey(gateway.verifyAuditLogHandler)
+
+	// Endpoints that submit transactions answer a retry carrying the same
+	// Idempotency-Key with the original response instead of sending again
+	postJob := gateway.idempotent(gateway.postJobHandler)
+	completeJob := gateway.idempotent(gateway.completeJobHandler)
+	cancelJob := gateway.idempotent(gateway.cancelJobHandler)
+	releaseBatch := gateway.idempotent(gateway.releaseBatchHandler)
+	confirmPrice := gateway.idempotent(gateway.confirmPriceHandler)
+	releaseRetainerPeriod := gateway.idempotent(gateway.releaseRetainerPeriodHandler)
+	refundRetainerPeriod := gateway.idempotent(gateway.refundRetainerPeriodHandler)
 
 	// Setup HTTP routes for your application flow
-	http.HandleFunc("/post-job", gateway.postJobHandler)               // Offer accepted → fund escrow
-	http.HandleFunc("/complete-job", gateway.completeJobHandler)       // Work approved → release payment
-	http.HandleFunc("/cancel-job", gateway.cancelJobHandler)           // Cancel/refund
-	http.HandleFunc("/job-status", gateway.getJobStatusHandler)        // Get payment status
-	http.HandleFunc("/confirm-deposit", gateway.confirmDepositHandler) // Confirm deposit completion
-	http.HandleFunc("/confirm-release", gateway.confirmReleaseHandler) // Confirm release completion
-	http.HandleFunc("/eth-price", gateway.getEthPriceHandler)          // Current ETH price
+	http.HandleFunc("/post-job", postJob)                                // Offer accepted → fund escrow
+	http.HandleFunc("/complete-job", completeJob)                        // Work approved → release payment
+	http.HandleFunc("/cancel-job", cancelJob)                            // Cancel/refund
+	http.HandleFunc("POST /release-batch", releaseBatch)                 // Weekly payout of approved jobs
+	http.HandleFunc("/job-status", gateway.getJobStatusHandler)          // Get payment status
+	http.HandleFunc("/confirm-deposit", confirmDeposit)                  // Confirm deposit completion (legacy)
+	http.HandleFunc("/confirm-release", confirmRelease)                  // Confirm release completion (legacy)
+	http.HandleFunc("/eth-price", gateway.getEthPriceHandler)            // Current or historical ETH price
+	http.HandleFunc("/reports/refunds", gateway.refundReportHandler)     // Refunds by reason
+	http.HandleFunc("/reports/gas-costs", gateway.gasCostReportHandler)  // Gas spend by operation
+	http.HandleFunc("/reports/gas-waste", gateway.gasWasteReportHandler) // Gas spent on transactions that failed
+
+	http.HandleFunc("POST /jobs/{id}/preflight-release", gateway.preflightReleaseHandler)           // Diagnose release blockers
+	http.HandleFunc("POST /jobs/{id}/resync", gateway.resyncJobHandler)                             // Overwrite DB record from chain
+	http.HandleFunc("GET /jobs/{id}/release-authorization", gateway.getReleaseAuthorizationHandler) // Approval and its expiry
+	http.HandleFunc("GET /addresses/{addr}", gateway.getAddressHandler)                             // Who owns a wallet
+	http.HandleFunc("PUT /addresses/{addr}", gateway.setAddressLabelHandler)                        // Label a wallet
+
+	http.HandleFunc("GET /quote", gateway.quoteHandler)                // Deposit, fee and payout for a USD amount
+	http.HandleFunc("GET /contract-info", gateway.contractInfoHandler) // Owner, fee, price feed and bytecode on-chain
+	http.HandleFunc("GET /gas-windows", gateway.gasWindowsHandler)     // Base fees by hour and the next cheap one
+
+	http.HandleFunc("POST /deferred-operations/{id}/confirm-price", confirmPrice) // Fund a paused escrow at a new rate
+
+	http.HandleFunc("GET /admin/wallet", gateway.getWalletHandler)                    // Signer balance and gas runway
+	http.HandleFunc("GET /admin/reviews", gateway.listReviewsHandler)                 // Operations held by velocity rules
+	http.HandleFunc("POST /admin/reviews/{id}/approve", gateway.approveReviewHandler) // Submit a held operation
+	http.HandleFunc("POST /admin/reviews/{id}/reject", gateway.rejectReviewHandler)   // Drop a held operation
+	http.HandleFunc("POST /admin/contract", gateway.updateContractHandler)            // Adopt a redeployed contract
+	http.HandleFunc("GET /admin/health-history", gateway.getHealthHistoryHandler)     // Recorded health and uptime
+	http.HandleFunc("GET /admin/maintenance", gateway.getMaintenanceHandler)          // Whether the gateway is read-only
+	http.HandleFunc("PUT /admin/maintenance", gateway.startMaintenanceHandler)        // Refuse mutations with 503
+	http.HandleFunc("DELETE /admin/maintenance", gateway.endMaintenanceHandler)       // Accept mutations again
+	http.HandleFunc("GET /admin/kill-switch", gateway.getKillSwitchHandler)           // Whether signing is halted
+	http.HandleFunc("PUT /admin/kill-switch", activateKillSwitch)                     // Halt all transaction signing
+	http.HandleFunc("DELETE /admin/kill-switch", deactivateKillSwitch)                // Sign transactions again
+	http.HandleFunc("GET /admin/audit/verify", verifyAuditLog)                        // Check the audit log for tampering
+
+	http.HandleFunc("POST /admin/transactions/{hash}/cancel", gateway.cancelTransactionHandler) // Free a stuck nonce with an empty transfer
+
+	http.HandleFunc("GET /admin/kyc-holds", gateway.listKYCHoldsHandler)                   // Releases waiting for KYC
+	http.HandleFunc("POST /admin/kyc-holds/{id}/recheck", gateway.recheckKYCHoldHandler)   // Check again, releasing if passed
+	http.HandleFunc("POST /admin/kyc-holds/{id}/withdraw", gateway.withdrawKYCHoldHandler) // Return the job to deposited
+
+	http.HandleFunc("GET /admin/archives", gateway.listArchivesHandler)                    // Archived batch manifests
+	http.HandleFunc("POST /admin/archives/{batch}/restore", gateway.restoreArchiveHandler) // Put archived rows back
+
+	http.HandleFunc("GET /admin/tax-exports/{year}", taxExport)                                 // Payouts per freelancer in a year
+	http.HandleFunc("GET /admin/tax-exports/{year}/freelancers/{user_id}", freelancerTaxExport) // One freelancer's payouts
+
+	http.HandleFunc("GET /reports/settlements", gateway.settlementReportHandler)              // Daily settlement summaries
+	http.HandleFunc("GET /reports/settlements/{day}", gateway.getSettlementSummaryHandler)    // One day's settlement summary
+	http.HandleFunc("POST /admin/settlements/{day}", gateway.rebuildSettlementSummaryHandler) // Summarise a day again
+
+	http.HandleFunc("POST /admin/escrows/discover", gateway.discoverEscrowsHandler)        // Adopt escrows funded outside the gateway
+	http.HandleFunc("GET /admin/escrows/discovered", gateway.listDiscoveredEscrowsHandler) // Escrows found by discovery
+	http.HandleFunc("GET /admin/indexer", gateway.getIndexerStatusHandler)                 // Event indexer checkpoint and lag
+
+	http.HandleFunc("GET /webhooks/events", gateway.listWebhookEventsHandler) // Event types and their schemas
+	http.HandleFunc("POST /admin/webhooks/test", gateway.testWebhookHandler)  // Send a sample event
+
+	http.HandleFunc("GET /changes", gateway.getChangesHandler) // Status changes since a cursor
+	http.HandleFunc("/graphql", gateway.graphqlHandler)        // Jobs, events, ledger and prices in one query
+
+	http.HandleFunc("GET /feature-flags", gateway.getFeatureFlagsHandler)             // Flag states and rules
+	http.HandleFunc("PUT /feature-flags/{flag}", gateway.setFeatureFlagHandler)       // Set a flag rule
+	http.HandleFunc("DELETE /feature-flags/{flag}", gateway.deleteFeatureFlagHandler) // Remove a flag rule
+
+	http.HandleFunc("GET /client-limits", gateway.listClientLimitsHandler)                       // Configured client limits
+	http.HandleFunc("GET /client-limits/{scope}/{subject}", gateway.getClientLimitHandler)       // A client's limit and open escrows
+	http.HandleFunc("PUT /client-limits/{scope}/{subject}", gateway.setClientLimitHandler)       // Cap a client's escrows
+	http.HandleFunc("DELETE /client-limits/{scope}/{subject}", gateway.deleteClientLimitHandler) // Remove a client's cap
+
+	http.HandleFunc("POST /jobs/{id}/status-token", gateway.createStatusTokenHandler) // Signed link for a status page
+	http.HandleFunc("GET /public/job-status", gateway.publicJobStatusHandler)         // Read-only status by token
+
+	http.HandleFunc("GET /reserve", gateway.getReserveHandler)                   // Reserve fund balance
+	http.HandleFunc("POST /reserve/payouts", gateway.createReservePayoutHandler) // Record compensation paid
+
+	http.HandleFunc("GET /jobs/{id}/tags", gateway.getJobTagsHandler)            // A job's tags
+	http.HandleFunc("PUT /jobs/{id}/tags", gateway.setJobTagsHandler)            // Replace a job's tags
+	http.HandleFunc("DELETE /jobs/{id}/tags/{tag}", gateway.deleteJobTagHandler) // Remove one tag
+
+	http.HandleFunc("POST /jobs/{id}/funding-link", gateway.createFundingLinkHandler) // Payment URI and QR payload for the client to fund the escrow
+	http.HandleFunc("GET /jobs/{id}/funding-link", gateway.getFundingLinkHandler)     // Latest funding link and whether it was funded
+
+	http.HandleFunc("GET /jobs/{id}/display-currencies", gateway.getDisplayCurrenciesHandler) // Client's and freelancer's display currencies
+	http.HandleFunc("PUT /jobs/{id}/display-currencies", gateway.setDisplayCurrenciesHandler) // Set display currencies
+
+	http.HandleFunc("POST /jobs/{id}/dispute", gateway.openDisputeHandler)                 // Open a dispute
+	http.HandleFunc("GET /jobs/{id}/dispute", gateway.getDisputeHandler)                   // Dispute, evidence and payment for the arbitrator
+	http.HandleFunc("POST /jobs/{id}/dispute/evidence", gateway.addDisputeEvidenceHandler) // Attach an evidence reference
+	http.HandleFunc("POST /jobs/{id}/dispute/resolve", gateway.resolveDisputeHandler)      // Record the decision and its justification
+
+	http.HandleFunc("POST /jobs/{id}/clone", gateway.cloneJobHandler) // Repeat engagement with a funding quote
+
+	http.HandleFunc("POST /jobs/{id}/top-up", gateway.topUpJobHandler)             // Add to a funded escrow
+	http.HandleFunc("GET /jobs/{id}/top-ups", gateway.listTopUpsHandler)           // Cumulative escrow and top-ups
+	http.HandleFunc("POST /jobs/{id}/top-ups/settle", gateway.settleTopUpsHandler) // Retry settling top-ups
+
+	http.HandleFunc("GET /freelancers/{user_id}/payouts", gateway.getPayoutsHandler)                // Batched payout preference and claimable balance
+	http.HandleFunc("PUT /freelancers/{user_id}/payouts", gateway.setPayoutPreferenceHandler)       // Opt into batched payouts
+	http.HandleFunc("DELETE /freelancers/{user_id}/payouts", gateway.deletePayoutPreferenceHandler) // Opt out, paying out what is held
+
+	http.HandleFunc("POST /retainers", gateway.createRetainerHandler)                       // Define a recurring escrow
+	http.HandleFunc("GET /retainers/{id}", gateway.getRetainerHandler)                      // Retainer with its periods
+	http.HandleFunc("POST /retainers/{id}/cancel", gateway.cancelRetainerHandler)           // Stop future periods
+	http.HandleFunc("POST /retainers/{id}/periods/{period}/release", releaseRetainerPeriod) // Pay out a period
+	http.HandleFunc("POST /retainers/{id}/periods/{period}/refund", refundRetainerPeriod)   // Refund a period
 
 	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-
-	log.Printf("Starting payment gateway server on port %s", cfg.ServerPort)
-	log.Printf("Contract address: %s", cfg.ContractAddress)
-	log.Printf("Network ID: %d", cfg.NetworkID)
+	http.HandleFunc("/health", gateway.healthHandler)
+	http.HandleFunc("GET /readyz", gateway.readyHandler)         // Database and RPC reachability, with congestion detail
+	http.HandleFunc("GET /debug/vars", gateway.debugVarsHandler) // Goroutine counts and background worker states
+
+	server, err := newHTTPServer(cfg, gateway.readOnlyDuringMaintenance(gateway.warnOfCongestion(http.DefaultServeMux)))
+	if err != nil {
+		log.Fatalf("Invalid server configuration: %v", err)
+	}
+	gateway.connections = server.connections
+
+	log.Printf("Starting payment gateway server: %s", server.describe())
+	log.Printf("Contract address: %s", gateway.client.ContractAddress().Hex())
+	log.Printf("Network ID: %d (%d confirmations, %d block reorg window, receipts polled every %s)",
+		cfg.NetworkID, cfg.RequiredConfirmations, cfg.ReorgWindow, cfg.PollMinInterval)
 	log.Printf("Database connected successfully")
 
-	if err := http.ListenAndServe(":"+cfg.ServerPort, nil); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	go func() {
+		if err := server.serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// Drain requests, then stop the workers and let webhook deliveries finish
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-signals.Done()
+	stop()
+	log.Printf("Shutting down, waiting up to %s for requests and workers", cfg.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.shutdown(ctx); err != nil {
+		log.Printf("Warning: Server did not drain: %v", err)
+	}
+	if err := gateway.Shutdown(ctx); err != nil {
+		log.Printf("Warning: %v", err)
 	}
 }
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// MaintenanceResponse is returned with 503 for requests refused while the
+// gateway is read-only
+type MaintenanceResponse struct {
+	Error       string     `json:"error"`
+	Code        string     `json:"code"` // "maintenance"
+	Reason      string     `json:"reason,omitempty"`
+	Since       time.Time  `json:"since"`
+	ExpectedEnd *time.Time `json:"expected_end,omitempty"`
+}
+
+// MaintenanceStatusResponse reports whether the gateway is read-only
+type MaintenanceStatusResponse struct {
+	Enabled bool                        `json:"enabled"`
+	Window  *database.MaintenanceWindow `json:"window,omitempty"`
+}
+
+// StartMaintenanceRequest puts the gateway into read-only mode
+type StartMaintenanceRequest struct {
+	Reason      string     `json:"reason"`
+	ExpectedEnd *time.Time `json:"expected_end"` // optional, sent to callers as Retry-After
+}
+
+// maintenanceExempt reports whether a non-GET request only reads state, so it
+// is still served in maintenance
+func maintenanceExempt(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/admin/maintenance"), path == "/admin/kill-switch":
+		return true
+	case path == "/graphql": // queries only
+		return true
+	case path == "/admin/webhooks/test":
+		return true
+	case strings.HasPrefix(path, "/jobs/") && strings.HasSuffix(path, "/preflight-release"):
+		return true
+	}
+	return false
+}
+
+// readOnlyDuringMaintenance refuses requests that could change state with 503
+// while a maintenance window is open. Reads are always served.
+func (pg *PaymentGateway) readOnlyDuringMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		window := pg.maintenance.Load()
+		switch {
+		case window == nil:
+		case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+		case maintenanceExempt(r):
+		default:
+			writeMaintenance(w, window)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeMaintenance(w http.ResponseWriter, window *database.MaintenanceWindow) {
+	if window.ExpectedEnd != nil {
+		if wait := time.Until(*window.ExpectedEnd); wait > 0 {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int64(math.Ceil(wait.Seconds()))))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(MaintenanceResponse{
+		Error:       "The gateway is in read-only maintenance",
+		Code:        "maintenance",
+		Reason:      window.Reason,
+		Since:       window.StartedAt,
+		ExpectedEnd: window.ExpectedEnd,
+	})
+}
+
+// setMaintenance records the open maintenance window, or nil once it has
+// ended. Approvals the platform sent meanwhile were not acted on, so they are
+// released when maintenance ends.
+func (pg *PaymentGateway) setMaintenance(window *database.MaintenanceWindow) {
+	previous := pg.maintenance.Swap(window)
+	switch {
+	case previous == nil && window != nil:
+		log.Printf("Maintenance started by %s: %s", window.StartedBy, window.Reason)
+	case previous != nil && window == nil:
+		log.Printf("Maintenance ended after %s", time.Since(previous.StartedAt).Round(time.Second))
+		if pg.config.PlatformEventsChannel != "" {
+			pg.goWorker("missed_approvals", pg.releaseMissedApprovals)
+		}
+	}
+}
+
+// refreshMaintenance picks up windows opened or closed by another replica
+func (pg *PaymentGateway) refreshMaintenance(ctx context.Context) error {
+	window, err := pg.db.GetActiveMaintenance(ctx)
+	if err != nil {
+		return err
+	}
+	pg.setMaintenance(window)
+	return nil
+}
+
+// runMaintenanceRefresh reloads the maintenance state every
+// MAINTENANCE_REFRESH_INTERVAL
+func (pg *PaymentGateway) runMaintenanceRefresh(ctx context.Context) {
+	if pg.config.MaintenanceRefreshInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.MaintenanceRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := pg.refreshMaintenance(ctx); err != nil {
+				log.Printf("Failed to refresh maintenance mode: %v", err)
+			}
+			pg.markWorkerRun("maintenance_refresh", pg.config.MaintenanceRefreshInterval)
+		}
+	}
+}
+
+// GET /admin/maintenance - Whether the gateway is read-only
+func (pg *PaymentGateway) getMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	window := pg.maintenance.Load()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MaintenanceStatusResponse{Enabled: window != nil, Window: window})
+}
+
+// PUT /admin/maintenance - Put the gateway into read-only mode
+func (pg *PaymentGateway) startMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	var req StartMaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	if req.ExpectedEnd != nil && !req.ExpectedEnd.After(time.Now()) {
+		http.Error(w, "expected_end must be in the future", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	window, started, err := pg.db.StartMaintenance(ctx, req.Reason, actor, req.ExpectedEnd)
+	if err != nil {
+		writeServerError(w, "Failed to start maintenance", err)
+		return
+	}
+	pg.setMaintenance(window)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !started {
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(MaintenanceStatusResponse{Enabled: true, Window: window})
+}
+
+// DELETE /admin/maintenance?reason= - Accept mutations again
+func (pg *PaymentGateway) endMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	window, err := pg.db.EndMaintenance(ctx, actor, r.URL.Query().Get("reason"))
+	if err != nil {
+		writeServerError(w, "Failed to end maintenance", err)
+		return
+	}
+	pg.setMaintenance(nil)
+	if window == nil {
+		http.Error(w, "The gateway is not in maintenance", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MaintenanceStatusResponse{Enabled: false, Window: window})
+}
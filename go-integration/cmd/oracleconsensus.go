@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/velocity"
+)
+
+// ruleOracleConsensus flags an escrow whose ETH/USD rate too few independent
+// sources agree with
+const ruleOracleConsensus velocity.Rule = "oracle_consensus"
+
+// priceSources are the independent sources ORACLE_CONSENSUS_USD checks the
+// contract's rate against: the fallback feed and the off-chain price URL,
+// whichever are configured
+func priceSources(cfg *config.Config, priceOracle PriceOracle) []oracle.Source {
+	var sources []oracle.Source
+	if cfg.OracleFallbackFeed != "" {
+		sources = append(sources, &oracle.FeedSource{Label: "fallback_feed", Latest: priceOracle.FallbackPriceRound, MaxAge: cfg.OracleMaxAge})
+	}
+	if cfg.OracleConsensusPriceURL != "" {
+		sources = append(sources, oracle.NewHTTPSource(cfg.OracleConsensusPriceURL, cfg.OracleConsensusPriceField))
+	}
+	return sources
+}
+
+// consensusViolations checks the contract's ETH/USD rate against the
+// independent price sources before an escrow of ORACLE_CONSENSUS_USD or more
+// is funded. When fewer than ORACLE_CONSENSUS_MIN_SOURCES agree, including the
+// contract's rate, the escrow is flagged for review rather than funded at a
+// rate nothing else confirms. It writes the error response and returns false
+// when the contract's rate can't be read.
+func (pg *PaymentGateway) consensusViolations(ctx context.Context, w http.ResponseWriter, usdAmount int64) ([]velocity.Violation, bool) {
+	if pg.config.OracleConsensusUSD <= 0 || usdAmount < pg.config.OracleConsensusUSD {
+		return nil, true
+	}
+	rate, err := pg.oracle.GetETHUSDPrice(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get ETH price", err)
+		return nil, false
+	}
+
+	consensus := oracle.CheckConsensus(ctx, rate, pg.priceSources, pg.config.OracleConsensusTolerancePercent)
+	if consensus.Reached(pg.config.OracleConsensusMinSources) {
+		return nil, true
+	}
+	log.Printf("Warning: No ETH/USD price consensus for a $%d escrow: %s", usdAmount, consensus)
+	return []velocity.Violation{{Rule: ruleOracleConsensus, Action: velocity.Flag, Detail: "ETH/USD price consensus failed: " + consensus.String()}}, true
+}
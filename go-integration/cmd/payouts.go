@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/trace"
+)
+
+// PayoutPreferenceRequest opts a freelancer into batched payouts
+type PayoutPreferenceRequest struct {
+	ThresholdUSD int64  `json:"threshold_usd"` // held releases are paid out once they reach this
+	Weekday      string `json:"weekday"`       // of the weekly payout, e.g. "friday"
+	HourUTC      int    `json:"hour_utc"`
+}
+
+// PayoutsResponse is a freelancer's batched payout preference and the
+// releases held for it
+type PayoutsResponse struct {
+	FreelancerUserID int32                      `json:"freelancer_user_id"`
+	Preference       *database.PayoutPreference `json:"preference"` // null when paid out job by job
+	ClaimableUSD     int64                      `json:"claimable_usd"`
+	Held             []database.HeldPayout      `json:"held"`
+	NextPayoutAt     *time.Time                 `json:"next_payout_at,omitempty"`
+}
+
+// parseFreelancerUserID reads the {user_id} path value. It returns false
+// after writing an error response.
+func parseFreelancerUserID(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 32)
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return 0, false
+	}
+	return int32(userID), true
+}
+
+// GET /freelancers/{user_id}/payouts - Batched payout preference and claimable balance
+func (pg *PaymentGateway) getPayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseFreelancerUserID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	pref, err := pg.db.GetPayoutPreference(ctx, userID)
+	if err != nil {
+		writeServerError(w, "Failed to get payout preference", err)
+		return
+	}
+	held, err := pg.db.ListHeldPayouts(ctx, userID)
+	if err != nil {
+		writeServerError(w, "Failed to get held payouts", err)
+		return
+	}
+
+	response := PayoutsResponse{FreelancerUserID: userID, Preference: pref, Held: held}
+	if response.Held == nil {
+		response.Held = []database.HeldPayout{}
+	}
+	for _, h := range held {
+		response.ClaimableUSD += h.USDAmount
+		if response.NextPayoutAt == nil || h.PayoutAt.Before(*response.NextPayoutAt) {
+			payoutAt := h.PayoutAt
+			response.NextPayoutAt = &payoutAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PUT /freelancers/{user_id}/payouts - Opt into batched payouts or change the threshold and schedule
+func (pg *PaymentGateway) setPayoutPreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseFreelancerUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req PayoutPreferenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	weekday, ok := parseWeekday(req.Weekday)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Invalid weekday %q: expected a day name such as friday", req.Weekday), http.StatusBadRequest)
+		return
+	}
+	if req.ThresholdUSD <= 0 {
+		http.Error(w, "threshold_usd must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.HourUTC < 0 || req.HourUTC > 23 {
+		http.Error(w, "hour_utc must be between 0 and 23", http.StatusBadRequest)
+		return
+	}
+
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+	pref := database.PayoutPreference{
+		FreelancerUserID: userID,
+		ThresholdUSD:     req.ThresholdUSD,
+		Weekday:          weekday,
+		Hour:             req.HourUTC,
+		UpdatedBy:        actor,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := pg.db.SetPayoutPreference(ctx, pref); err != nil {
+		writeServerError(w, "Failed to set payout preference", err)
+		return
+	}
+	// A lower threshold may already be met
+	if err := pg.payOutIfOverThreshold(ctx, &pref, trace.ID(r.Context())); err != nil {
+		log.Printf("Warning: Failed to check the payout threshold of freelancer %d: %v", userID, err)
+	}
+
+	pref.UpdatedAt = time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pref)
+}
+
+// DELETE /freelancers/{user_id}/payouts - Opt out of batched payouts, paying out held releases now
+func (pg *PaymentGateway) deletePayoutPreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseFreelancerUserID(w, r)
+	if !ok {
+		return
+	}
+
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	deleted, err := pg.db.DeletePayoutPreference(ctx, userID, actor)
+	if err != nil {
+		writeServerError(w, "Failed to delete payout preference", err)
+		return
+	}
+	if !deleted {
+		http.Error(w, fmt.Sprintf("Freelancer %d has not opted into batched payouts", userID), http.StatusNotFound)
+		return
+	}
+	released, err := pg.db.ReleaseHeldPayouts(ctx, userID, "batched payout ended: the freelancer opted out")
+	if err != nil {
+		writeServerError(w, "Failed to release held payouts", err)
+		return
+	}
+	if released > 0 {
+		log.Printf("Paying out %d held releases of freelancer %d, who opted out of batched payouts", released, userID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseWeekday reads a day name, case-insensitively
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// payoutHoldUntil is when a release held now for pref is paid out if the
+// threshold isn't reached first: the next weekly payout hour, or sooner when
+// the release authorization given with it would lapse before then
+func (pg *PaymentGateway) payoutHoldUntil(pref *database.PayoutPreference, now time.Time) time.Time {
+	payoutAt := pref.NextPayout(now)
+	if ttl := pg.config.ReleaseAuthorizationTTL; ttl > 0 {
+		if lapse := now.Add(ttl - pg.config.DeferredPollInterval); lapse.Before(payoutAt) {
+			payoutAt = lapse
+		}
+	}
+	return payoutAt
+}
+
+// holdIfBatched holds the release of a freelancer who opted into batched
+// payouts, then pays out everything held once it reaches their threshold.
+// A release with an explicit mode is not held. It returns true if a response
+// has been written.
+func (pg *PaymentGateway) holdIfBatched(ctx context.Context, w http.ResponseWriter, details *database.ApplicationPaymentDetails, params database.OperationParams, requestedMode string) bool {
+	if requestedMode != "" || details.AgreedUSDAmount == nil {
+		return false
+	}
+	pref, err := pg.db.GetPayoutPreference(ctx, details.ApplicantUserID)
+	if err != nil {
+		log.Printf("%sWarning: Failed to get the payout preference of freelancer %d, releasing application %d now: %v", tracePrefix(params.TraceID), details.ApplicantUserID, details.ApplicationID, err)
+		return false
+	}
+	if pref == nil {
+		return false
+	}
+
+	payoutAt := pg.payoutHoldUntil(pref, time.Now())
+	reason := fmt.Sprintf("held for batched payout at %s or once $%d is claimable", payoutAt.Format(time.RFC3339), pref.ThresholdUSD)
+	op, err := pg.db.HoldPayout(ctx, details.ApplicationID, details.ApplicantUserID, int64(*details.AgreedUSDAmount), opCompleteJob, params, payoutAt, payoutAt.Add(pg.config.DeferDeadline), reason)
+	if err != nil {
+		writeServerError(w, "Failed to hold release for batched payout", err)
+		return true
+	}
+	log.Printf("%sHeld release of application %d for the batched payout of freelancer %d", tracePrefix(params.TraceID), details.ApplicationID, details.ApplicantUserID)
+	pg.notifyJob(op.ApplicationID, op.Params.TraceID, events.OperationDeferred, operationEvent(op, pg.explorer))
+
+	if err := pg.payOutIfOverThreshold(ctx, pref, params.TraceID); err != nil {
+		log.Printf("%sWarning: Failed to check the payout threshold of freelancer %d: %v", tracePrefix(params.TraceID), details.ApplicantUserID, err)
+	} else if refreshed, err := pg.db.GetPendingDeferredOperation(ctx, details.ApplicationID); err == nil && refreshed != nil {
+		op = refreshed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newDeferredOperationResponse(op, pg.explorer))
+	return true
+}
+
+// payOutIfOverThreshold makes a freelancer's held releases due now once
+// together they reach the threshold. EthJobEscrow pays each job out in its
+// own transaction, so they are still sent one by one, by the deferred
+// operation worker.
+func (pg *PaymentGateway) payOutIfOverThreshold(ctx context.Context, pref *database.PayoutPreference, traceID string) error {
+	held, err := pg.db.ListHeldPayouts(ctx, pref.FreelancerUserID)
+	if err != nil {
+		return err
+	}
+	var claimable int64
+	for _, h := range held {
+		claimable += h.USDAmount
+	}
+	if len(held) == 0 || claimable < pref.ThresholdUSD {
+		return nil
+	}
+
+	reason := fmt.Sprintf("batched payout: $%d claimable reached the $%d threshold", claimable, pref.ThresholdUSD)
+	released, err := pg.db.ReleaseHeldPayouts(ctx, pref.FreelancerUserID, reason)
+	if err != nil {
+		return err
+	}
+	if released > 0 {
+		log.Printf("%sPaying out %d held releases of freelancer %d: $%d reached the $%d threshold", tracePrefix(traceID), released, pref.FreelancerUserID, claimable, pref.ThresholdUSD)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/archive"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/backup"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+const backupUsage = `Usage: pgwctl backup <create|list|verify|restore> [flags]
+
+  create    export the gateway's tables; -incremental builds on the latest backup
+  list      list the backups in the bucket
+  verify    check a backup and those it builds on against their manifests
+  restore   write a backup into a database and check every row
+`
+
+// backupCommand runs a backup subcommand and returns its exit code
+func backupCommand(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, backupUsage)
+		return 2
+	}
+	switch args[0] {
+	case "create":
+		return backupCreateCommand(args[1:])
+	case "list":
+		return backupListCommand(args[1:])
+	case "verify":
+		return backupVerifyCommand(args[1:])
+	case "restore":
+		return backupRestoreCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "pgwctl backup: unknown subcommand %q\n\n%s", args[0], backupUsage)
+		return 2
+	}
+}
+
+// backupFlags are the flags every backup subcommand shares. The bucket and
+// database default to the gateway's own settings, and the bucket's keys are
+// only read from $ARCHIVE_ACCESS_KEY and $ARCHIVE_SECRET_KEY.
+type backupFlags struct {
+	cfg         *config.Config
+	bucketURL   *string
+	databaseURL *string
+}
+
+func newBackupFlags(flags *flag.FlagSet, withDatabase bool) *backupFlags {
+	cfg := config.Load()
+	f := &backupFlags{cfg: cfg}
+	f.bucketURL = flags.String("bucket", "", "s3://, gs:// or file:// bucket the backups are kept in, under backups/ (default $ARCHIVE_BUCKET_URL)")
+	if withDatabase {
+		f.databaseURL = flags.String("database-url", "", "database to back up or restore into (default built from $DB_HOST, $DB_USER and the rest)")
+	}
+	return f
+}
+
+func (f *backupFlags) bucket() (archive.Bucket, error) {
+	bucketURL := *f.bucketURL
+	if bucketURL == "" {
+		bucketURL = f.cfg.ArchiveBucketURL
+	}
+	if bucketURL == "" {
+		return nil, errors.New("set -bucket or $ARCHIVE_BUCKET_URL")
+	}
+	return archive.Open(bucketURL, archive.S3Options{
+		Endpoint:  f.cfg.ArchiveEndpoint,
+		Region:    f.cfg.ArchiveRegion,
+		AccessKey: f.cfg.ArchiveAccessKey,
+		SecretKey: f.cfg.ArchiveSecretKey,
+	})
+}
+
+// open connects to the database; the URL's default stays out of -h output
+// since it holds $DB_PASSWORD
+func (f *backupFlags) open() (*database.DB, error) {
+	databaseURL := *f.databaseURL
+	if databaseURL == "" {
+		databaseURL = f.cfg.DatabaseURL
+	}
+	return database.NewDB(databaseURL)
+}
+
+// backupCreateCommand writes a backup and returns 0 once its manifest is
+// written, 1 when the export fails and 2 for bad flags
+func backupCreateCommand(args []string) int {
+	flags := flag.NewFlagSet("backup create", flag.ContinueOnError)
+	shared := newBackupFlags(flags, true)
+	incremental := flags.Bool("incremental", false, "only export rows written since the latest backup")
+	timeout := flags.Duration("timeout", time.Hour, "longest the export may take")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	bucket, err := shared.bucket()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup create: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	db, err := shared.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup create: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	manifest, err := backup.Create(ctx, db, bucket, time.Now(), *incremental)
+	if errors.Is(err, backup.ErrNoBackup) {
+		fmt.Fprintln(os.Stderr, "pgwctl backup create: no backup to build on; take a full backup first")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup create: %v\n", err)
+		return 1
+	}
+
+	kind := "full"
+	if manifest.Incremental() {
+		kind = "incremental on " + manifest.Base
+	}
+	fmt.Printf("Wrote backup %s (%s): %d rows in %d tables\n", manifest.ID, kind, manifest.Rows(), len(manifest.Tables))
+	return 0
+}
+
+func backupListCommand(args []string) int {
+	flags := flag.NewFlagSet("backup list", flag.ContinueOnError)
+	shared := newBackupFlags(flags, false)
+	asJSON := flags.Bool("json", false, "print the manifests as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	bucket, err := shared.bucket()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup list: %v\n", err)
+		return 2
+	}
+
+	manifests, err := backup.List(context.Background(), bucket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup list: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(manifests)
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tBASE\tCREATED\tROWS")
+	for _, manifest := range manifests {
+		base := manifest.Base
+		if base == "" {
+			base = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", manifest.ID, base, manifest.CreatedAt.Format(time.RFC3339), manifest.Rows())
+	}
+	w.Flush()
+	return 0
+}
+
+// backupVerifyCommand returns 0 when every object of the backup's chain
+// matches its manifest and 1 otherwise
+func backupVerifyCommand(args []string) int {
+	flags := flag.NewFlagSet("backup verify", flag.ContinueOnError)
+	shared := newBackupFlags(flags, false)
+	id := flags.String("id", "", "backup to verify (default the latest)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	bucket, err := shared.bucket()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup verify: %v\n", err)
+		return 2
+	}
+
+	ctx := context.Background()
+	if *id == "" {
+		latest, err := backup.Latest(ctx, bucket)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pgwctl backup verify: %v\n", err)
+			return 1
+		}
+		*id = latest.ID
+	}
+	chain, counts, err := backup.Verify(ctx, bucket, *id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup verify: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Backup %s is intact across %d backups\n", *id, len(chain))
+	printBackupTables(counts, nil)
+	return 0
+}
+
+// backupRestoreCommand returns 0 once the restored rows are committed, or
+// checked with -dry-run, 1 when the restore or its check fails and 2 for
+// bad flags
+func backupRestoreCommand(args []string) int {
+	flags := flag.NewFlagSet("backup restore", flag.ContinueOnError)
+	shared := newBackupFlags(flags, true)
+	id := flags.String("id", "", "backup to restore; the backups it builds on are restored with it")
+	dryRun := flags.Bool("dry-run", false, "restore and check inside a transaction, then roll it back")
+	timeout := flags.Duration("timeout", time.Hour, "longest the restore may take")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *id == "" {
+		fmt.Fprintln(os.Stderr, "pgwctl backup restore: set -id; 'pgwctl backup list' shows the backups")
+		return 2
+	}
+	bucket, err := shared.bucket()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup restore: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	db, err := shared.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup restore: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	// The gateway's tables must exist before rows can be written to them
+	if err := db.Migrate(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup restore: %v\n", err)
+		return 1
+	}
+
+	chain, result, err := backup.Restore(ctx, db, bucket, *id, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl backup restore: %v\n", err)
+		return 1
+	}
+	if *dryRun {
+		fmt.Printf("Backup %s restores cleanly from %d backups; rolled back\n", *id, len(chain))
+	} else {
+		fmt.Printf("Restored backup %s from %d backups\n", *id, len(chain))
+	}
+	counts := make(map[string]int, len(result.Tables))
+	extra := make(map[string]int, len(result.Tables))
+	for table, restored := range result.Tables {
+		counts[table], extra[table] = restored.Rows, restored.Extra
+	}
+	printBackupTables(counts, extra)
+	return 0
+}
+
+// printBackupTables prints the rows of each table in restore order, and with
+// extra the rows the database holds beyond the backup
+func printBackupTables(counts, extra map[string]int) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if extra != nil {
+		fmt.Fprintln(w, "TABLE\tROWS\tNOT IN BACKUP")
+	} else {
+		fmt.Fprintln(w, "TABLE\tROWS")
+	}
+	for _, table := range database.BackupTables {
+		if extra != nil {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", table.Name, counts[table.Name], extra[table.Name])
+		} else {
+			fmt.Fprintf(w, "%s\t%d\n", table.Name, counts[table.Name])
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/deploy"
+)
+
+// deployCommand deploys EthJobEscrow with the key in $PRIVATE_KEY and writes
+// the manifest DEPLOYMENT_MANIFEST points the gateway at. It returns 0 once
+// the manifest is written, 1 when deploying fails and 2 for bad flags or
+// parameters that fail validation.
+func deployCommand(args []string) int {
+	flags := flag.NewFlagSet("deploy", flag.ContinueOnError)
+	rpcURL := flags.String("rpc", os.Getenv("ETHEREUM_RPC_URL"), "node to deploy through (default $ETHEREUM_RPC_URL)")
+	bytecodePath := flags.String("bytecode", "out/PaymentGateway.sol/EthJobEscrow.json", "Foundry build artifact or hex file of the contract's creation code")
+	oracle := flags.String("oracle", "", "Chainlink ETH/USD feed (default the network's feed in config.Networks)")
+	owner := flags.String("owner", "", "contract owner (default the deployer)")
+	feeBPS := flags.Int64("fee-bps", 500, "fee the contract must charge, in basis points; the contract's FEE_PERCENT is compiled in, so this is checked, not set")
+	manifest := flags.String("manifest", "deployment.json", "where the deployment manifest is written")
+	timeout := flags.Duration("timeout", 5*time.Minute, "longest wait for the deployment to be mined")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *rpcURL == "" {
+		fmt.Fprintln(os.Stderr, "pgwctl deploy: set -rpc or $ETHEREUM_RPC_URL")
+		return 2
+	}
+
+	// The key is only read from the environment, so it stays out of shell
+	// history and -h output
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(os.Getenv("PRIVATE_KEY"), "0x"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: invalid $PRIVATE_KEY: %v\n", err)
+		return 2
+	}
+	bytecode, err := deploy.ReadBytecode(*bytecodePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	client, err := ethclient.DialContext(ctx, *rpcURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: failed to connect to %s: %v\n", *rpcURL, err)
+		return 1
+	}
+	defer client.Close()
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: failed to get chain ID: %v\n", err)
+		return 1
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: %v\n", err)
+		return 1
+	}
+
+	params := deploy.Params{Owner: auth.From, FeeBPS: *feeBPS}
+	if *owner != "" {
+		if !common.IsHexAddress(*owner) {
+			fmt.Fprintf(os.Stderr, "pgwctl deploy: -owner %q is not an address\n", *owner)
+			return 2
+		}
+		params.Owner = common.HexToAddress(*owner)
+	}
+	feed := *oracle
+	if feed == "" {
+		feed = config.Networks[chainID.Int64()].ETHUSDPriceFeed
+	}
+	if !common.IsHexAddress(feed) {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: set -oracle; chain %s has no known ETH/USD feed\n", chainID)
+		return 2
+	}
+	params.PriceFeed = common.HexToAddress(feed)
+
+	if err := params.Validate(ctx, client); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: %v\n", err)
+		return 2
+	}
+
+	fmt.Printf("Deploying EthJobEscrow on chain %s from %s (owner %s, feed %s)\n", chainID, auth.From.Hex(), params.Owner.Hex(), params.PriceFeed.Hex())
+	deployment, err := deploy.Deploy(ctx, client, auth, bytecode, params)
+	if err != nil {
+		if errors.Is(err, deploy.ErrFeeMismatch) {
+			fmt.Fprintf(os.Stderr, "pgwctl deploy: %v; %s is deployed but no manifest was written\n", err, deployment.ContractAddress)
+		} else {
+			fmt.Fprintf(os.Stderr, "pgwctl deploy: %v\n", err)
+		}
+		return 1
+	}
+	if err := deployment.Write(*manifest); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl deploy: %s is deployed at block %d but %v\n", deployment.ContractAddress, deployment.DeployBlock, err)
+		return 1
+	}
+
+	fmt.Printf("Deployed %s at block %d in %s\n", deployment.ContractAddress, deployment.DeployBlock, deployment.DeployTx)
+	fmt.Printf("Wrote %s; set DEPLOYMENT_MANIFEST=%s for the gateway\n", *manifest, *manifest)
+	return 0
+}
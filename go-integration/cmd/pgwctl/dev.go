@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/deploy"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/devenv"
+)
+
+const devUsage = `Usage: pgwctl dev <up|down> [flags]
+
+  up     start Postgres and anvil, deploy the contract, seed sample
+         applications and run the gateway against them
+  down   stop the containers and throw their data away
+`
+
+// devCommand runs a dev subcommand and returns its exit code
+func devCommand(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, devUsage)
+		return 2
+	}
+	switch args[0] {
+	case "up":
+		return devUpCommand(args[1:])
+	case "down":
+		return devDownCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "pgwctl dev: unknown subcommand %q\n\n%s", args[0], devUsage)
+		return 2
+	}
+}
+
+// devUpCommand provisions the stack and runs the gateway in the foreground
+// until interrupted, leaving the containers up for the next run. It returns 1
+// when a step fails and 2 for bad flags.
+func devUpCommand(args []string) int {
+	opts := devenv.DefaultOptions
+	flags := flag.NewFlagSet("dev up", flag.ContinueOnError)
+	dir := flags.String("dir", ".pgwctl-dev", "where the compose file, manifest and gateway environment are written")
+	bytecodePath := flags.String("bytecode", "contracts/testdata/EthJobEscrow.bin", "Foundry build artifact or hex file of the contract's creation code")
+	flags.IntVar(&opts.PostgresPort, "postgres-port", opts.PostgresPort, "host port of Postgres")
+	flags.IntVar(&opts.AnvilPort, "anvil-port", opts.AnvilPort, "host port of the anvil node")
+	flags.IntVar(&opts.BlockTime, "block-time", 0, "seconds between anvil blocks; 0 mines each transaction at once")
+	port := flags.Int("port", 8081, "port the gateway listens on")
+	gateway := flags.String("gateway", "", "gateway binary to run (default 'go run ./cmd')")
+	noGateway := flags.Bool("no-gateway", false, "provision the stack and print the gateway's environment without running it")
+	timeout := flags.Duration("timeout", 3*time.Minute, "longest wait for the containers and the deployment")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	bytecode, err := deploy.ReadBytecode(*bytecodePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 2
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	setupCtx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	composeFile := filepath.Join(*dir, "docker-compose.yml")
+	if err := os.WriteFile(composeFile, opts.Compose(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Starting Postgres on :%d and anvil on :%d (%s)\n", opts.PostgresPort, opts.AnvilPort, composeFile)
+	if err := dockerCompose(setupCtx, composeFile, "up", "-d", "--wait"); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+
+	client, err := devenv.WaitForChain(setupCtx, opts.RPCURL())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+	conn, err := devenv.WaitForDatabase(setupCtx, opts.DatabaseURL())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+	defer conn.Close(context.Background())
+
+	// A contract still on the node is reused; anvil keeps no state across
+	// restarts, so after one it is deployed again
+	manifest := filepath.Join(*dir, "deployment.json")
+	deployment, err := devenv.ExistingDeployment(setupCtx, client, manifest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+	if deployment != nil {
+		fmt.Printf("Reusing EthJobEscrow at %s from %s\n", deployment.ContractAddress, manifest)
+	} else {
+		if err := devenv.InstallFeed(setupCtx, client); err != nil {
+			fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+			return 1
+		}
+		key, _ := crypto.HexToECDSA(devenv.DeployerKey)
+		auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(devenv.ChainID))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+			return 1
+		}
+		deployment, err = deploy.Deploy(setupCtx, client, auth, bytecode, deploy.Params{PriceFeed: devenv.FeedAddress, Owner: auth.From, FeeBPS: 500})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+			return 1
+		}
+		if err := deployment.Write(manifest); err != nil {
+			fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Deployed EthJobEscrow at %s in block %d\n", deployment.ContractAddress, deployment.DeployBlock)
+	}
+
+	if err := devenv.Seed(setupCtx, conn); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+	for _, app := range devenv.SampleApplications {
+		fmt.Printf("Seeded application %d: %s, $%d, %s\n", app.ID, app.Title, app.USDAmount, app.Status)
+	}
+
+	absManifest, err := filepath.Abs(manifest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+	env := opts.GatewayEnv(absManifest, *port)
+	envFile := filepath.Join(*dir, "gateway.env")
+	if err := os.WriteFile(envFile, []byte(strings.Join(env, "\n")+"\n"), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: %v\n", err)
+		return 1
+	}
+	if *noGateway {
+		fmt.Printf("Wrote the gateway's environment to %s\n", envFile)
+		return 0
+	}
+
+	name, gatewayArgs := "go", []string{"run", "./cmd"}
+	if *gateway != "" {
+		name, gatewayArgs = *gateway, nil
+	}
+	fmt.Printf("Starting the gateway on :%d; Ctrl-C stops it, 'pgwctl dev down' stops the containers\n", *port)
+	cmd := exec.CommandContext(ctx, name, gatewayArgs...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = 10 * time.Second
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev up: gateway exited: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// devDownCommand removes the containers started by dev up
+func devDownCommand(args []string) int {
+	flags := flag.NewFlagSet("dev down", flag.ContinueOnError)
+	dir := flags.String("dir", ".pgwctl-dev", "directory dev up wrote the compose file to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	composeFile := filepath.Join(*dir, "docker-compose.yml")
+	if _, err := os.Stat(composeFile); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev down: %v; run 'pgwctl dev up' first\n", err)
+		return 1
+	}
+	if err := dockerCompose(context.Background(), composeFile, "down", "--volumes"); err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl dev down: %v\n", err)
+		return 1
+	}
+	// The contract went with anvil's state
+	if err := os.Remove(filepath.Join(*dir, "deployment.json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "pgwctl dev down: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func dockerCompose(ctx context.Context, composeFile string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "docker", append([]string{"compose", "-f", composeFile}, args...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker compose %s failed: %w", args[0], err)
+	}
+	return nil
+}
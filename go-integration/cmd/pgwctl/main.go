@@ -0,0 +1,136 @@
+// Command pgwctl operates a payment gateway deployment from the command line.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/simulate"
+)
+
+const usage = `Usage: pgwctl <command> [flags]
+
+Commands:
+  backup     back up the gateway's tables to a bucket, verify and restore them
+  deploy     deploy the escrow contract and write a deployment manifest
+  dev        run a local Postgres, anvil node and gateway with sample data
+  probe      run a tiny escrow round trip on a testnet on a schedule and export metrics
+  simulate   drive payment lifecycles against a gateway and check every step
+
+Run 'pgwctl <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var code int
+	switch os.Args[1] {
+	case "backup":
+		code = backupCommand(os.Args[2:])
+	case "deploy":
+		code = deployCommand(os.Args[2:])
+	case "dev":
+		code = devCommand(os.Args[2:])
+	case "probe":
+		code = probeCommand(os.Args[2:])
+	case "simulate":
+		code = simulateCommand(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "pgwctl: unknown command %q\n\n%s", os.Args[1], usage)
+		code = 2
+	}
+	os.Exit(code)
+}
+
+// simulateCommand runs the scenarios given a job and returns the exit code:
+// 0 when every step passed, 1 when one failed and 2 for bad flags
+func simulateCommand(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	baseURL := flags.String("url", "http://localhost:8081", "gateway base URL")
+	releaseJob := flags.Int("release-job", 0, "application in pending_deposit to post, complete and release")
+	cancelJob := flags.Int("cancel-job", 0, "application in pending_deposit to post and cancel")
+	disputeJob := flags.Int("dispute-job", 0, "application in pending_deposit to post and refund for dispute_resolution")
+	secret := flags.String("signing-secret", os.Getenv("REQUEST_SIGNING_SECRET"), "secret for signing confirm requests (default $REQUEST_SIGNING_SECRET)")
+	actor := flags.String("actor", "pgwctl-simulate", "X-Actor recorded on the payment events")
+	timeout := flags.Duration("timeout", 5*time.Minute, "longest wait for each transaction to be confirmed")
+	poll := flags.Duration("poll", 2*time.Second, "how often the job status is read while waiting")
+	legacyConfirm := flags.Bool("legacy-confirm", false, "also call /confirm-deposit and /confirm-release while waiting")
+	asJSON := flags.Bool("json", false, "print the results as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	jobs := make(map[string]int32)
+	for name, id := range map[string]int{
+		simulate.ScenarioRelease: *releaseJob,
+		simulate.ScenarioCancel:  *cancelJob,
+		simulate.ScenarioDispute: *disputeJob,
+	} {
+		if id > 0 {
+			jobs[name] = int32(id)
+		}
+	}
+	if len(jobs) == 0 {
+		fmt.Fprintln(os.Stderr, "pgwctl simulate: set at least one of -release-job, -cancel-job or -dispute-job")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := &simulate.Client{
+		BaseURL:       *baseURL,
+		SigningSecret: *secret,
+		Actor:         *actor,
+		HTTP:          &http.Client{Timeout: time.Minute},
+	}
+	results, err := simulate.Run(ctx, client, simulate.Options{
+		SettleTimeout: *timeout,
+		PollInterval:  *poll,
+		LegacyConfirm: *legacyConfirm,
+	}, jobs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgwctl simulate: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	} else {
+		printResults(results)
+	}
+
+	for _, result := range results {
+		if !result.Passed() {
+			return 1
+		}
+	}
+	return 0
+}
+
+func printResults(results []simulate.StepResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCENARIO\tJOB\tSTEP\tRESULT\tTIME\tDETAIL")
+	for _, r := range results {
+		outcome, detail := "ok", r.Detail
+		if !r.Passed() {
+			outcome, detail = "FAIL", r.Error
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", r.Scenario, r.JobID, r.Step, outcome, r.Duration.Round(time.Millisecond), detail)
+	}
+	w.Flush()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/simulate"
+)
+
+// probeCommand runs a tiny escrow round trip against a testnet gateway every
+// -interval and exports the timings and failures as Prometheus metrics. With
+// -once it runs one round and returns 0 if it passed and 1 if it failed;
+// otherwise it runs until interrupted and returns 0. Bad flags return 2.
+func probeCommand(args []string) int {
+	flags := flag.NewFlagSet("probe", flag.ContinueOnError)
+	env := flags.String("env", "", "environment probed, the env label of every metric, e.g. staging")
+	baseURL := flags.String("url", os.Getenv("PROBE_URL"), "gateway base URL (default $PROBE_URL)")
+	template := flags.Int("template-job", envInt("PROBE_TEMPLATE_JOB"), "application whose client and freelancer test wallets each round engages again (default $PROBE_TEMPLATE_JOB)")
+	usdAmount := flags.Int("usd-amount", 1, "USD amount of each round's escrow")
+	scenario := flags.String("scenario", simulate.ScenarioRelease, "round trip each round drives: release or cancel")
+	interval := flags.Duration("interval", 15*time.Minute, "time between the starts of two rounds")
+	once := flags.Bool("once", false, "run one round and exit with its result")
+	secret := flags.String("signing-secret", os.Getenv("REQUEST_SIGNING_SECRET"), "secret for signing confirm requests (default $REQUEST_SIGNING_SECRET)")
+	timeout := flags.Duration("timeout", 5*time.Minute, "longest wait for each transaction to be confirmed")
+	metricsAddr := flags.String("metrics-addr", "", "address to serve the metrics on at /metrics, e.g. :9464")
+	metricsFile := flags.String("metrics-file", "", "file the metrics are written to after each round, for node_exporter's textfile collector")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	switch {
+	case *env == "":
+		fmt.Fprintln(os.Stderr, "pgwctl probe: set -env to the environment being probed")
+		return 2
+	case *baseURL == "":
+		fmt.Fprintln(os.Stderr, "pgwctl probe: set -url or $PROBE_URL")
+		return 2
+	case *template <= 0:
+		fmt.Fprintln(os.Stderr, "pgwctl probe: set -template-job or $PROBE_TEMPLATE_JOB to an application between two test wallets")
+		return 2
+	case *usdAmount <= 0:
+		fmt.Fprintln(os.Stderr, "pgwctl probe: -usd-amount must be positive")
+		return 2
+	case !slices.Contains([]string{simulate.ScenarioRelease, simulate.ScenarioCancel}, *scenario):
+		fmt.Fprintf(os.Stderr, "pgwctl probe: -scenario must be %s or %s\n", simulate.ScenarioRelease, simulate.ScenarioCancel)
+		return 2
+	case *interval <= 0 && !*once:
+		fmt.Fprintln(os.Stderr, "pgwctl probe: -interval must be positive")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := &simulate.Client{
+		BaseURL:       *baseURL,
+		SigningSecret: *secret,
+		Actor:         "pgwctl-probe/" + *env,
+		HTTP:          &http.Client{Timeout: time.Minute},
+	}
+	options := simulate.ProbeOptions{
+		Options:   simulate.Options{SettleTimeout: *timeout},
+		Scenario:  *scenario,
+		Template:  int32(*template),
+		USDAmount: int32(*usdAmount),
+	}
+
+	var mu sync.Mutex
+	metrics := &simulate.ProbeMetrics{Env: *env}
+	if *metricsAddr != "" {
+		listener, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pgwctl probe: %v\n", err)
+			return 2
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			metrics.WriteTo(w)
+		})
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go server.Serve(listener)
+		defer server.Close()
+	}
+
+	round := func() bool {
+		result := simulate.Probe(ctx, client, options)
+		mu.Lock()
+		metrics.Record(result)
+		var b bytes.Buffer
+		metrics.WriteTo(&b)
+		mu.Unlock()
+
+		if result.Passed() {
+			log.Printf("Probe of %s passed in %s on job %d", *env, result.Duration.Round(time.Millisecond), result.JobID)
+		} else {
+			log.Printf("Probe of %s failed after %s on job %d: %s", *env, result.Duration.Round(time.Millisecond), result.JobID, result.Error)
+		}
+		if *metricsFile != "" {
+			if err := writeFileAtomic(*metricsFile, b.Bytes()); err != nil {
+				log.Printf("Failed to write probe metrics: %v", err)
+			}
+		}
+		return result.Passed()
+	}
+
+	if *once {
+		if !round() {
+			return 1
+		}
+		return 0
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		round()
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeFileAtomic replaces path with data through a rename, so a collector
+// never reads a half-written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// envInt reads an integer flag default from the environment, 0 when unset or
+// malformed
+func envInt(name string) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return 0
+	}
+	return n
+}
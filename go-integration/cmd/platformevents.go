@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/trace"
+)
+
+// Platform events the gateway acts on
+const (
+	platformWorkApproved  = "work_approved"  // releases the job's payment
+	platformStatusChanged = "status_changed" // checks AUTOMATION_RULES without waiting for the next poll
+)
+
+// PlatformEvent is the JSON payload the platform sends with
+// NOTIFY on PLATFORM_EVENTS_CHANNEL
+type PlatformEvent struct {
+	Event         string `json:"event"`
+	ApplicationID int32  `json:"application_id"`
+}
+
+// runPlatformEvents listens for platform events, listening again after
+// PLATFORM_EVENTS_RETRY whenever the connection drops
+func (pg *PaymentGateway) runPlatformEvents(ctx context.Context) {
+	if pg.config.PlatformEventsChannel == "" {
+		return
+	}
+
+	for {
+		pg.listenPlatformEvents(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pg.config.PlatformEventsRetry):
+		}
+	}
+}
+
+// listenPlatformEvents handles platform events until the listen connection
+// drops. Approvals notified while the gateway wasn't listening are never
+// delivered, so approved jobs still holding their deposit are released first.
+func (pg *PaymentGateway) listenPlatformEvents(ctx context.Context) {
+	channel := pg.config.PlatformEventsChannel
+	listener, err := pg.db.Listen(ctx, channel)
+	if err != nil {
+		log.Printf("Failed to listen for platform events: %v", err)
+		return
+	}
+	defer listener.Close()
+	log.Printf("Listening for platform events on %s", channel)
+
+	pg.releaseMissedApprovals(ctx)
+
+	for {
+		payload, err := listener.Wait(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Stopped listening for platform events: %v", err)
+			}
+			return
+		}
+		pg.handlePlatformEvent(ctx, payload)
+	}
+}
+
+// releaseMissedApprovals releases every deposited job whose application status
+// is RELEASE_APPROVED_STATUS. A job whose release authorization lapsed stays
+// deposited: the status may be left over from an approval long ago.
+func (pg *PaymentGateway) releaseMissedApprovals(ctx context.Context) {
+	ids, err := pg.db.ListApprovedDeposits(ctx, pg.config.ReleaseApprovedStatus)
+	if err != nil {
+		log.Printf("Failed to list approved jobs awaiting release: %v", err)
+		return
+	}
+
+	for _, applicationID := range ids {
+		actor, ok := pg.missedApprovalActor(ctx, applicationID)
+		if !ok {
+			continue
+		}
+		if stop := pg.autoRelease(applicationID, actor); stop != "" {
+			log.Printf("Releasing approved jobs stopped at application %d, %s", applicationID, stop)
+			return
+		}
+	}
+}
+
+// handlePlatformEvent acts on one notification payload. Events other than
+// work_approved and status_changed are left to the channel's other listeners.
+func (pg *PaymentGateway) handlePlatformEvent(ctx context.Context, payload string) {
+	var event PlatformEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil || event.ApplicationID <= 0 {
+		log.Printf("Ignoring malformed platform event %q", payload)
+		return
+	}
+	if event.Event == platformStatusChanged {
+		pg.wakeAutomation()
+		return
+	}
+	if event.Event != platformWorkApproved {
+		return
+	}
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, event.ApplicationID)
+	if err != nil {
+		log.Printf("Failed to get application %d for approved work: %v", event.ApplicationID, err)
+		return
+	}
+	// The platform may also call /complete-job, or approve before the deposit confirms
+	if details.PaymentStatus != paymentstatus.Deposited {
+		log.Printf("Not releasing approved application %d: payment status is '%s', expected 'deposited'", event.ApplicationID, details.PaymentStatus)
+		return
+	}
+
+	pg.autoRelease(event.ApplicationID, database.ActorPlatform)
+}
+
+// missedApprovalActor decides whether an approved job found on startup may be
+// released. It returns the actor to authorize the release for, or "" when
+// the job's authorization is still active and is reused.
+func (pg *PaymentGateway) missedApprovalActor(ctx context.Context, applicationID int32) (string, bool) {
+	if pg.config.ReleaseAuthorizationTTL <= 0 {
+		return database.ActorPlatform, true
+	}
+
+	authorization, err := pg.db.GetReleaseAuthorization(ctx, applicationID)
+	switch {
+	case err != nil:
+		log.Printf("Failed to get release authorization of approved application %d: %v", applicationID, err)
+		return "", false
+	case authorization == nil:
+		return database.ActorPlatform, true
+	case authorization.Status != database.ReleaseAuthorizationActive || authorization.Expired(time.Now()):
+		log.Printf("Not releasing approved application %d: its release authorization by %s is no longer active; the work must be approved again", applicationID, authorization.Actor)
+		return "", false
+	}
+	return "", true
+}
+
+// autoRelease releases an approved job under a new trace and logs the
+// outcome, authorizing the release for actor unless it is empty. It returns
+// why releases after it should not be attempted, or "" to carry on.
+func (pg *PaymentGateway) autoRelease(applicationID int32, actor string) string {
+	if pg.maintenance.Load() != nil {
+		log.Printf("Not releasing approved application %d during maintenance; approved jobs are released when it ends", applicationID)
+		return "the gateway is in maintenance"
+	}
+	if pg.signingHalt.Load() != nil {
+		log.Printf("Not releasing approved application %d while signing is halted; approved jobs are released once it is re-armed", applicationID)
+		return "signing is halted"
+	}
+
+	var result ReleaseBatchResult
+	traceID := trace.NewID()
+	stop := pg.releaseApprovedJob(applicationID, actor, releaseModeImmediate, traceID, &result)
+
+	switch result.Status {
+	case batchReleased, batchPending:
+		log.Printf("Released approved application %d: %s", applicationID, result.Transaction.TxHash)
+	case batchDeferred:
+		log.Printf("Release of approved application %d deferred as operation %d", applicationID, result.OperationID)
+	case batchSkipped:
+		log.Printf("Not releasing approved application %d: %s", applicationID, result.Error)
+	default:
+		log.Printf("Failed to release approved application %d: %s", applicationID, result.Error)
+	}
+	return stop
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Preflight check outcomes
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// PreflightCheck is one precondition of a release
+type PreflightCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type PreflightResponse struct {
+	JobID  uint64           `json:"job_id"`
+	Ready  bool             `json:"ready"`
+	Checks []PreflightCheck `json:"checks"`
+}
+
+func (p *PreflightResponse) add(name, status, detail string) {
+	p.Checks = append(p.Checks, PreflightCheck{Name: name, Status: status, Detail: detail})
+	if status == checkFail {
+		p.Ready = false
+	}
+}
+
+// POST /jobs/{id}/preflight-release - Check every release precondition without submitting anything
+func (pg *PaymentGateway) preflightReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	response := pg.preflightRelease(ctx, jobID, applicationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// preflightRelease runs every check even after a failure so support sees the full picture
+func (pg *PaymentGateway) preflightRelease(ctx context.Context, jobID uint64, applicationID int32) *PreflightResponse {
+	response := &PreflightResponse{JobID: jobID, Ready: true, Checks: []PreflightCheck{}}
+
+	// Database payment status
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	switch {
+	case err != nil:
+		response.add("db_status", checkFail, fmt.Sprintf("application lookup failed: %v", err))
+	case details.PaymentStatus != paymentstatus.Deposited:
+		response.add("db_status", checkFail, fmt.Sprintf("payment status is '%s', expected 'deposited'", details.PaymentStatus))
+	default:
+		response.add("db_status", checkPass, "payment status is 'deposited'")
+	}
+
+	// Nothing already queued for this job
+	op, err := pg.db.GetPendingDeferredOperation(ctx, applicationID)
+	switch {
+	case err != nil:
+		response.add("no_queued_operation", checkFail, fmt.Sprintf("deferred operation lookup failed: %v", err))
+	case op != nil:
+		response.add("no_queued_operation", checkFail, fmt.Sprintf("%s is queued until %s", op.Operation, op.Deadline.Format(time.RFC3339)))
+	default:
+		response.add("no_queued_operation", checkPass, "no queued operation")
+	}
+
+	// On-chain job state
+	job, err := pg.client.GetJobDetails(ctx, jobID)
+	switch {
+	case err != nil:
+		response.add("onchain_state", checkFail, fmt.Sprintf("failed to read job from contract: %v", err))
+	case job.Client == (common.Address{}):
+		response.add("onchain_state", checkFail, "job does not exist on-chain (never funded or already refunded)")
+	case job.IsCompleted || job.IsPaid:
+		response.add("onchain_state", checkFail, "job is already completed on-chain")
+	default:
+		response.add("onchain_state", checkPass, fmt.Sprintf("job funded with %s wei and not completed", job.ETHAmount))
+	}
+
+	// The contract only lets the job's client release
+	if err == nil && job.Client != (common.Address{}) {
+		if job.Client == pg.client.Address() || job.Client == pg.client.AdminAddress() {
+			response.add("signer_is_client", checkPass, fmt.Sprintf("gateway signer %s is the job client", job.Client.Hex()))
+		} else {
+			response.add("signer_is_client", checkFail, fmt.Sprintf("job client is %s but gateway signs as %s", job.Client.Hex(), pg.client.Address().Hex()))
+		}
+	} else {
+		response.add("signer_is_client", checkSkip, "job not found on-chain")
+	}
+
+	// The deployed escrow has no pause switch
+	response.add("contract_not_paused", checkSkip, "contract has no pause mechanism")
+
+	// Oracle freshness
+	round, err := pg.oracle.LatestPriceRound(ctx)
+	switch {
+	case err != nil:
+		response.add("oracle_freshness", checkFail, fmt.Sprintf("failed to read price feed: %v", err))
+	case round.Age() > pg.config.OracleMaxAge:
+		response.add("oracle_freshness", checkFail, fmt.Sprintf("latest round is %s old, limit is %s", round.Age().Round(time.Second), pg.config.OracleMaxAge))
+	default:
+		response.add("oracle_freshness", checkPass, fmt.Sprintf("latest round updated %s ago", round.Age().Round(time.Second)))
+	}
+
+	// Gas price and estimate
+	gasPrice, err := pg.client.SuggestGasPrice(ctx)
+	if err != nil {
+		response.add("gas_price_under_cap", checkFail, fmt.Sprintf("failed to get gas price: %v", err))
+	} else if ceiling := pg.client.GasPriceCeiling(); ceiling != nil && gasPrice.Cmp(ceiling) > 0 {
+		response.add("gas_price_under_cap", checkFail, fmt.Sprintf("gas price %s wei exceeds ceiling %s wei", gasPrice, ceiling))
+	} else {
+		response.add("gas_price_under_cap", checkPass, fmt.Sprintf("gas price %s wei", gasPrice))
+	}
+
+	gasLimit := pg.config.GasLimit
+	estimate, err := pg.client.EstimateGas(ctx, nil, "markJobCompleted", new(big.Int).SetUint64(jobID))
+	contractErr := payment.DecodeContractError(err)
+	switch {
+	case contractErr != nil:
+		response.add("gas_estimate_under_limit", checkFail, fmt.Sprintf("the call would revert with %s (%s): %s", contractErr.Name, contractErr.Code, contractErr.Message))
+	case err != nil:
+		response.add("gas_estimate_under_limit", checkFail, fmt.Sprintf("estimation failed, the call would likely revert: %v", err))
+	case estimate > pg.config.GasLimit:
+		response.add("gas_estimate_under_limit", checkFail, fmt.Sprintf("estimated %d gas exceeds limit %d", estimate, pg.config.GasLimit))
+	default:
+		gasLimit = estimate
+		response.add("gas_estimate_under_limit", checkPass, fmt.Sprintf("estimated %d gas, limit %d", estimate, pg.config.GasLimit))
+	}
+
+	// Wallet can pay for the transaction
+	balance, balanceErr := pg.client.GetBalance(ctx, pg.client.Address())
+	switch {
+	case balanceErr != nil:
+		response.add("wallet_gas_balance", checkFail, fmt.Sprintf("failed to get signer balance: %v", balanceErr))
+	case gasPrice == nil:
+		response.add("wallet_gas_balance", checkSkip, "gas price unavailable")
+	default:
+		required := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))
+		if balance.Cmp(required) < 0 {
+			response.add("wallet_gas_balance", checkFail, fmt.Sprintf("balance %s wei is below the %s wei needed", balance, required))
+		} else {
+			response.add("wallet_gas_balance", checkPass, fmt.Sprintf("balance %s wei covers %s wei", balance, required))
+		}
+	}
+
+	return response
+}
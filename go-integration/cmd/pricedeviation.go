@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+// ConfirmPriceRequest is the ETH/USD rate a client agrees to fund a paused escrow at
+type ConfirmPriceRequest struct {
+	QuotedETHUSDPrice string `json:"quoted_eth_usd_price"` // eth_usd_price of a fresh /quote
+}
+
+// quotedPrice returns the rate a new escrow is checked against at submission:
+// the client's quoted rate if it sent one, otherwise the current rate. It
+// returns "" when PRICE_DEVIATION_PERCENT is unset, and false if a response has
+// been written.
+func (pg *PaymentGateway) quotedPrice(ctx context.Context, w http.ResponseWriter, quoted string) (string, bool) {
+	if pg.config.PriceDeviationPercent <= 0 {
+		return "", true
+	}
+	if quoted != "" {
+		if price, ok := new(big.Int).SetString(quoted, 10); !ok || price.Sign() <= 0 {
+			http.Error(w, "Invalid quoted_eth_usd_price", http.StatusBadRequest)
+			return "", false
+		}
+		return quoted, true
+	}
+
+	price, err := pg.oracle.GetETHUSDPrice(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get ETH price", err)
+		return "", false
+	}
+	return price.String(), true
+}
+
+// checkPriceDeviation returns a *payment.PriceDeviationError if the ETH/USD
+// rate has moved more than PRICE_DEVIATION_PERCENT from the one the escrow was
+// quoted at, so a deferred or reviewed escrow can't lock far more or less ETH
+// than the client agreed to
+func (pg *PaymentGateway) checkPriceDeviation(ctx context.Context, params database.OperationParams) error {
+	threshold := pg.config.PriceDeviationPercent
+	if threshold <= 0 || params.QuotedETHUSDPrice == "" {
+		return nil
+	}
+	quoted, ok := new(big.Int).SetString(params.QuotedETHUSDPrice, 10)
+	if !ok || quoted.Sign() <= 0 {
+		return fmt.Errorf("invalid quoted ETH/USD price %q", params.QuotedETHUSDPrice)
+	}
+
+	current, err := pg.oracle.GetETHUSDPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get ETH/USD price: %w", err)
+	}
+	if percent := payment.PriceDeviation(quoted, current); percent > threshold {
+		return &payment.PriceDeviationError{Quoted: quoted, Current: current, Percent: percent, Threshold: threshold}
+	}
+	return nil
+}
+
+// POST /deferred-operations/{id}/confirm-price - Fund a paused escrow at a new rate
+func (pg *PaymentGateway) confirmPriceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid operation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ConfirmPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if price, ok := new(big.Int).SetString(req.QuotedETHUSDPrice, 10); !ok || price.Sign() <= 0 {
+		http.Error(w, "quoted_eth_usd_price is required", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A rate that has already moved would only pause the operation again
+	if err := pg.checkPriceDeviation(ctx, database.OperationParams{QuotedETHUSDPrice: req.QuotedETHUSDPrice}); err != nil {
+		var deviation *payment.PriceDeviationError
+		if errors.As(err, &deviation) {
+			http.Error(w, fmt.Sprintf("Quote is out of date: %v", err), http.StatusConflict)
+			return
+		}
+		writeServerError(w, "Failed to check the ETH/USD rate", err)
+		return
+	}
+
+	op, err := pg.db.ConfirmDeferredOperationPrice(ctx, id, req.QuotedETHUSDPrice)
+	switch {
+	case errors.Is(err, database.ErrNotPaused):
+		http.Error(w, fmt.Sprintf("Operation %d is not paused for a price confirmation", id), http.StatusConflict)
+		return
+	case err != nil:
+		writeServerError(w, "Failed to confirm operation price", err)
+		return
+	case op == nil:
+		http.Error(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Deferred operation %d (%s for application %d) confirmed at ETH/USD %s by %s", op.ID, op.Operation, op.ApplicationID, req.QuotedETHUSDPrice, actor)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDeferredOperationResponse(op, pg.explorer))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+// Where market prices are read from
+const (
+	priceSourcePrimary  = "primary"
+	priceSourceFallback = "fallback"
+)
+
+// PriceFeedHealth is the heartbeat monitor's latest view of the ETH/USD feed
+type PriceFeedHealth struct {
+	Status           string    `json:"status"` // "ok" or "stalled"
+	Source           string    `json:"source"` // "primary" or "fallback"
+	FeedAddress      string    `json:"feed_address"`
+	FallbackAddress  string    `json:"fallback_address,omitempty"`
+	RoundID          string    `json:"round_id"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	StalenessSeconds int64     `json:"staleness_seconds"`
+	HeartbeatSeconds int64     `json:"heartbeat_seconds"`
+	DeviationPercent *float64  `json:"deviation_percent,omitempty"` // from the fallback feed
+	Reason           string    `json:"reason,omitempty"`
+	CheckedAt        time.Time `json:"checked_at"`
+}
+
+func (h *PriceFeedHealth) stalled() bool {
+	return h != nil && h.Status == "stalled"
+}
+
+// runPriceFeedMonitor checks the price feed against its heartbeat at startup
+// and every ORACLE_CHECK_INTERVAL
+func (pg *PaymentGateway) runPriceFeedMonitor(ctx context.Context) {
+	pg.checkPriceFeed(ctx, time.Now())
+	pg.markWorkerRun("price_feed_monitor", pg.config.OracleCheckInterval)
+
+	ticker := time.NewTicker(pg.config.OracleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pg.checkPriceFeed(ctx, time.Now())
+			pg.markWorkerRun("price_feed_monitor", pg.config.OracleCheckInterval)
+		}
+	}
+}
+
+// checkPriceFeed assesses the latest round, comparing it with the fallback
+// feed when one is configured. While the feed is stalled, market prices are
+// read from the fallback; ops are alerted when it stalls and when it recovers.
+func (pg *PaymentGateway) checkPriceFeed(ctx context.Context, now time.Time) {
+	round, err := pg.oracle.LatestPriceRound(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to check price feed: %v", err)
+		return
+	}
+
+	fallback, err := pg.oracle.FallbackPriceRound(ctx)
+	if err != nil {
+		if !errors.Is(err, payment.ErrNoFallbackFeed) {
+			log.Printf("Warning: Failed to read fallback price feed: %v", err)
+		}
+		fallback = nil
+	}
+
+	heartbeat := oracle.Heartbeat{
+		Interval:         pg.config.OracleHeartbeat,
+		DeviationPercent: pg.config.OracleDeviationPercent,
+		Grace:            pg.config.OracleHeartbeatGrace,
+	}
+	assessment := heartbeat.Assess(round, fallback, now)
+
+	health := &PriceFeedHealth{
+		Status:           "ok",
+		Source:           priceSourcePrimary,
+		FeedAddress:      pg.config.ETHUSDPriceFeed,
+		FallbackAddress:  pg.config.OracleFallbackFeed,
+		RoundID:          round.RoundID.String(),
+		UpdatedAt:        round.UpdatedAt,
+		StalenessSeconds: int64(assessment.Staleness / time.Second),
+		HeartbeatSeconds: int64(pg.config.OracleHeartbeat / time.Second),
+		DeviationPercent: assessment.DeviationPercent,
+		Reason:           assessment.Reason,
+		CheckedAt:        now,
+	}
+	if assessment.Stalled {
+		health.Status = "stalled"
+		if fallback != nil && !heartbeat.Assess(fallback, nil, now).Stalled {
+			health.Source = priceSourceFallback
+		}
+	}
+
+	if previous := pg.priceFeed.Swap(health); previous.stalled() == health.stalled() {
+		return
+	}
+
+	payload := events.PriceFeed{
+		FeedAddress:      health.FeedAddress,
+		FallbackAddress:  health.FallbackAddress,
+		Source:           health.Source,
+		RoundID:          health.RoundID,
+		UpdatedAt:        health.UpdatedAt,
+		StalenessSeconds: health.StalenessSeconds,
+		HeartbeatSeconds: health.HeartbeatSeconds,
+		DeviationPercent: health.DeviationPercent,
+		Reason:           health.Reason,
+	}
+
+	if health.stalled() {
+		log.Printf("ALERT: Price feed %s stalled: %s; market prices read from %s", health.FeedAddress, health.Reason, health.Source)
+		pg.notify(events.PriceFeedStalled, payload)
+	} else {
+		log.Printf("Price feed %s recovered at round %s", health.FeedAddress, health.RoundID)
+		pg.notify(events.PriceFeedRecovered, payload)
+	}
+}
+
+// marketPrice returns the ETH/USD answer used to value amounts in dollars:
+// the contract's rate, or the fallback feed's while the primary is stalled.
+// Escrow amounts always use GetETHUSDPrice, since the contract keeps
+// converting with its own feed.
+func (pg *PaymentGateway) marketPrice(ctx context.Context) (*big.Int, error) {
+	if health := pg.priceFeed.Load(); health != nil && health.Source == priceSourceFallback {
+		round, err := pg.oracle.FallbackPriceRound(ctx)
+		if err == nil {
+			return round.Answer, nil
+		}
+		log.Printf("Warning: Failed to read fallback price feed, using the contract's rate: %v", err)
+	}
+	return pg.oracle.GetETHUSDPrice(ctx)
+}
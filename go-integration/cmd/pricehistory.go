@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+)
+
+// HistoricalPriceResponse is the Chainlink round that applied at a past block or time
+type HistoricalPriceResponse struct {
+	ETHUSDPrice string    `json:"eth_usd_price"`
+	RoundID     string    `json:"round_id"`
+	StartedAt   time.Time `json:"started_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	AtBlock     *uint64   `json:"at_block,omitempty"`
+	AtTime      *string   `json:"at_time,omitempty"`
+}
+
+// historicalEthPrice answers GET /eth-price?at_block= or ?at_time=. The block
+// form reads latestRoundData as of that block, which is exactly the rate the
+// escrow contract converted with there; the time form searches feed rounds.
+func (pg *PaymentGateway) historicalEthPrice(ctx context.Context, w http.ResponseWriter, query url.Values) {
+	if query.Has("at_block") && query.Has("at_time") {
+		http.Error(w, "Specify at_block or at_time, not both", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		round    *oracle.RoundData
+		err      error
+		response HistoricalPriceResponse
+	)
+
+	if query.Has("at_block") {
+		block, parseErr := strconv.ParseUint(query.Get("at_block"), 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid at_block", http.StatusBadRequest)
+			return
+		}
+		response.AtBlock = &block
+		round, err = pg.oracle.PriceRoundAtBlock(ctx, block)
+	} else {
+		at, parseErr := time.Parse(time.RFC3339, query.Get("at_time"))
+		if parseErr != nil {
+			http.Error(w, "Invalid at_time, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		atStr := at.UTC().Format(time.RFC3339)
+		response.AtTime = &atStr
+		round, err = pg.oracle.PriceRoundAtTime(ctx, at)
+	}
+
+	if errors.Is(err, oracle.ErrBeforeCurrentPhase) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeServerError(w, "Failed to get historical ETH price", err)
+		return
+	}
+
+	response.ETHUSDPrice = round.Answer.String()
+	response.RoundID = round.RoundID.String()
+	response.StartedAt = round.StartedAt
+	response.UpdatedAt = round.UpdatedAt
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
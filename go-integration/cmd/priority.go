@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/workpool"
+)
+
+// Priorities of a mutating request
+const (
+	priorityNormal = "normal" // the shared pool, batching and economical scheduling
+	priorityUrgent = "urgent" // the urgent lane: its own workers, URGENT_GAS_PRICE_PERCENT and no holds for batching
+)
+
+// parsePriority reads a request's priority, returning how OperationParams
+// records it: "" for normal and priorityUrgent for urgent
+func parsePriority(value string) (string, error) {
+	switch value {
+	case "", priorityNormal:
+		return "", nil
+	case priorityUrgent:
+		return priorityUrgent, nil
+	}
+	return "", fmt.Errorf("invalid priority %q: expected %s or %s", value, priorityNormal, priorityUrgent)
+}
+
+// parseFeeStrategy reads a request's fee_strategy, returning how
+// OperationParams records it: "" for the operation's default
+func parseFeeStrategy(value string) (string, error) {
+	strategy, err := payment.ParseFeeStrategy(value)
+	return string(strategy), err
+}
+
+// lane returns the pool an operation is submitted on and the context its
+// transactions are priced under
+func (pg *PaymentGateway) lane(ctx context.Context, params database.OperationParams) (*workpool.Pool, context.Context) {
+	if params.Priority != priorityUrgent {
+		return pg.submissions, ctx
+	}
+	return pg.urgent, payment.WithGasPreset(ctx, payment.GasPreset{
+		PricePercent: pg.config.UrgentGasPricePercent,
+		MaxGasPrice:  pg.config.UrgentMaxGasPrice,
+	})
+}
+
+// feeStrategy is the EIP-1559 fee strategy an operation's transactions are
+// priced by: the request's own, URGENT_FEE_STRATEGY on the urgent lane,
+// RELEASE_FEE_STRATEGY for payouts, and FEE_STRATEGY otherwise
+func (pg *PaymentGateway) feeStrategy(operation string, params database.OperationParams) payment.FeeStrategy {
+	switch {
+	case params.FeeStrategy != "":
+		return payment.FeeStrategy(params.FeeStrategy)
+	case params.Priority == priorityUrgent && pg.config.UrgentFeeStrategy != "":
+		return payment.FeeStrategy(pg.config.UrgentFeeStrategy)
+	}
+	switch operation {
+	case opCompleteJob, opTopUpRelease, opRetainerRelease:
+		if pg.config.ReleaseFeeStrategy != "" {
+			return payment.FeeStrategy(pg.config.ReleaseFeeStrategy)
+		}
+	}
+	return payment.FeeStrategy(pg.config.FeeStrategy)
+}
+
+// gasCeiling is the highest network gas price an operation is submitted at,
+// or nil when it has no ceiling
+func (pg *PaymentGateway) gasCeiling(params database.OperationParams) *big.Int {
+	if params.Priority == priorityUrgent && pg.config.UrgentMaxGasPrice > 0 {
+		return new(big.Int).Mul(big.NewInt(pg.config.UrgentMaxGasPrice), big.NewInt(1e9))
+	}
+	return pg.client.GasPriceCeiling()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+// ContractInfoResponse identifies the escrow contract and, when it is an
+// EIP-1967 proxy, the implementation it delegates to
+type ContractInfoResponse struct {
+	Address        string    `json:"address"`
+	Proxy          bool      `json:"proxy"`
+	Implementation string    `json:"implementation,omitempty"`
+	Admin          string    `json:"admin,omitempty"`
+	Beacon         string    `json:"beacon,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+func newContractInfoResponse(info *payment.ProxyInfo, checkedAt time.Time) *ContractInfoResponse {
+	response := &ContractInfoResponse{
+		Address:   info.Address.Hex(),
+		Proxy:     info.IsProxy(),
+		CheckedAt: checkedAt,
+	}
+	if !response.Proxy {
+		return response
+	}
+	response.Implementation = info.Implementation.Hex()
+	if info.Admin != (common.Address{}) {
+		response.Admin = info.Admin.Hex()
+	}
+	if info.Beacon != (common.Address{}) {
+		response.Beacon = info.Beacon.Hex()
+	}
+	return response
+}
+
+// runProxyMonitor resolves the escrow contract's implementation at startup and
+// every PROXY_CHECK_INTERVAL, recording each implementation it sees
+func (pg *PaymentGateway) runProxyMonitor(ctx context.Context) {
+	var alerted common.Address // last unexpected implementation alerted on
+	pg.checkProxy(ctx, &alerted)
+	pg.markWorkerRun("proxy_monitor", pg.config.ProxyCheckInterval)
+
+	ticker := time.NewTicker(pg.config.ProxyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pg.checkProxy(ctx, &alerted)
+			pg.markWorkerRun("proxy_monitor", pg.config.ProxyCheckInterval)
+		}
+	}
+}
+
+// checkProxy refreshes the contract info and alerts when the implementation
+// changes or differs from EXPECTED_IMPLEMENTATION_ADDRESS. An unexpected
+// implementation is alerted on once, not on every check.
+func (pg *PaymentGateway) checkProxy(ctx context.Context, alerted *common.Address) {
+	info, err := pg.client.GetProxyInfo(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to check contract proxy: %v", err)
+		return
+	}
+	pg.contract.Store(newContractInfoResponse(info, time.Now()))
+	if !info.IsProxy() {
+		return
+	}
+
+	expected := true
+	if pg.config.ExpectedImplementation != "" {
+		expected = info.Implementation == common.HexToAddress(pg.config.ExpectedImplementation)
+	}
+
+	previous, err := pg.db.RecordContractImplementation(ctx, info.Address.Hex(), info.Implementation.Hex())
+	if err != nil {
+		log.Printf("Warning: Failed to record contract implementation: %v", err)
+	}
+
+	if previous == nil && (expected || *alerted == info.Implementation) {
+		return
+	}
+
+	payload := events.ContractImplementation{
+		ContractAddress: info.Address.Hex(),
+		Implementation:  info.Implementation.Hex(),
+		Expected:        expected,
+	}
+	if previous != nil {
+		payload.PreviousImplementation = *previous
+	}
+
+	if expected {
+		log.Printf("Contract %s upgraded from %s to expected implementation %s", payload.ContractAddress, payload.PreviousImplementation, payload.Implementation)
+	} else {
+		log.Printf("ALERT: Contract %s delegates to unexpected implementation %s (previously %s, expected %s)",
+			payload.ContractAddress, payload.Implementation, payload.PreviousImplementation, pg.config.ExpectedImplementation)
+		*alerted = info.Implementation
+	}
+	pg.notify(events.ContractImplementationChanged, payload)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/format"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+)
+
+// Pricing modes of a quote
+const (
+	pricingSpot = "spot" // the latest round, as postJob converts
+	pricingTWAP = "twap" // time-weighted average of recent rounds
+)
+
+// maxTWAPRounds caps the rounds one quote reads from the feed
+const maxTWAPRounds = 100
+
+// quoteGasHistory is how far back a breakdown averages recorded gas usage
+const quoteGasHistory = 30 * 24 * time.Hour
+
+// QuoteResponse is what a job of a given USD amount would cost at the current
+// rate. Each raw value has a display string formatted for the request's locale.
+type QuoteResponse struct {
+	USDAmount               string          `json:"usd_amount"`
+	USDAmountDisplay        string          `json:"usd_amount_display"`
+	Pricing                 string          `json:"pricing"` // "spot" or "twap"
+	ETHUSDPrice             string          `json:"eth_usd_price"`
+	ETHUSDPriceDisplay      string          `json:"eth_usd_price_display"`
+	TWAPRounds              int             `json:"twap_rounds,omitempty"`
+	TWAPSince               *time.Time      `json:"twap_since,omitempty"` // update time of the oldest averaged round
+	SpotETHUSDPrice         string          `json:"spot_eth_usd_price,omitempty"`
+	SpotRequiredWei         string          `json:"spot_required_wei,omitempty"` // what postJob would check right now
+	RequiredWei             string          `json:"required_wei"`
+	RequiredETHDisplay      string          `json:"required_eth_display"`
+	PlatformFeeWei          string          `json:"platform_fee_wei"`
+	PlatformFeeETHDisplay   string          `json:"platform_fee_eth_display"`
+	FreelancerNetWei        string          `json:"freelancer_net_wei"`
+	FreelancerNetETHDisplay string          `json:"freelancer_net_eth_display"`
+	Breakdown               *QuoteBreakdown `json:"breakdown,omitempty"` // with breakdown=true
+	Locale                  string          `json:"locale"`
+}
+
+// QuoteBreakdown is a dry run of everything a job of the quoted amount moves,
+// computed the way the gateway records it: gas as transaction_costs does, the
+// fee accrual as the ledger posts it on release. USD values use the quote's
+// ETH/USD rate.
+type QuoteBreakdown struct {
+	PrincipalWei       string            `json:"principal_wei"` // escrowed by postJob
+	PrincipalUSD       string            `json:"principal_usd"`
+	FundingBufferWei   string            `json:"funding_buffer_wei"` // extra the escrow may need before PRICE_DEVIATION_PERCENT pauses it
+	FundingBufferUSD   string            `json:"funding_buffer_usd"`
+	GasPriceWei        string            `json:"gas_price_wei"`
+	Gas                []QuoteGas        `json:"gas"`
+	GasWei             string            `json:"gas_wei"`
+	GasUSD             string            `json:"gas_usd"`
+	PlatformFeeWei     string            `json:"platform_fee_wei"`
+	PlatformFeeUSD     string            `json:"platform_fee_usd"`
+	LedgerEntries      map[string]string `json:"ledger_entries,omitempty"` // fee_accrual on release, account -> signed wei; absent without RESERVE_FEE_BPS
+	TotalClientCostWei string            `json:"total_client_cost_wei"`    // principal, buffer and gas
+	TotalClientCostUSD string            `json:"total_client_cost_usd"`
+	FreelancerNetWei   string            `json:"freelancer_net_wei"`
+	FreelancerNetUSD   string            `json:"freelancer_net_usd"`
+	Display            map[string]string `json:"display"` // each *_usd value formatted for the locale
+}
+
+// QuoteGas is the estimated gas of one transaction of the job
+type QuoteGas struct {
+	Operation     string `json:"operation"`
+	GasUsed       int64  `json:"gas_used"`
+	CostWei       string `json:"cost_wei"`
+	EstimatedFrom string `json:"estimated_from"` // "history" or "gas_limit"
+}
+
+// GET /quote?usd_amount=X&pricing=spot|twap&rounds=N&breakdown=true - Escrow deposit, fee and payout for a USD amount
+func (pg *PaymentGateway) quoteHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.Quotes) {
+		return
+	}
+
+	query := r.URL.Query()
+	usdAmount, ok := new(big.Int).SetString(query.Get("usd_amount"), 10)
+	if !ok || usdAmount.Sign() <= 0 {
+		http.Error(w, "Invalid usd_amount", http.StatusBadRequest)
+		return
+	}
+
+	pricing := query.Get("pricing")
+	if pricing == "" {
+		pricing = pricingSpot
+	}
+	rounds := pg.config.TWAPRounds
+	switch pricing {
+	case pricingSpot:
+	case pricingTWAP:
+		if usdAmount.Cmp(big.NewInt(pg.config.TWAPMinUSD)) < 0 {
+			http.Error(w, fmt.Sprintf("TWAP pricing is only available for usd_amount of at least %d", pg.config.TWAPMinUSD), http.StatusBadRequest)
+			return
+		}
+		if value := query.Get("rounds"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid rounds", http.StatusBadRequest)
+				return
+			}
+			rounds = n
+		}
+		if rounds < 2 || rounds > maxTWAPRounds {
+			http.Error(w, fmt.Sprintf("rounds must be between 2 and %d", maxTWAPRounds), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "pricing must be spot or twap", http.StatusBadRequest)
+		return
+	}
+
+	var withBreakdown bool
+	if value := query.Get("breakdown"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid breakdown", http.StatusBadRequest)
+			return
+		}
+		withBreakdown = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	spot, err := pg.oracle.GetETHUSDPrice(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get ETH price", err)
+		return
+	}
+	price := spot
+
+	var averaged []*oracle.RoundData
+	if pricing == pricingTWAP {
+		averaged, err = pg.oracle.RecentPriceRounds(ctx, rounds)
+		if err != nil {
+			writeServerError(w, "Failed to get price rounds", err)
+			return
+		}
+		price, err = oracle.TWAP(averaged, time.Now())
+		if err != nil {
+			writeServerError(w, "Failed to average price rounds", err)
+			return
+		}
+	}
+
+	locale := localeFor(r)
+	response := pg.newQuote(locale, pricing, usdAmount, price)
+	if pricing == pricingTWAP {
+		// The contract still converts at the spot round when postJob is
+		// mined, so show what it would require alongside the averaged quote
+		since := averaged[0].UpdatedAt
+		response.TWAPRounds = len(averaged)
+		response.TWAPSince = &since
+		response.SpotETHUSDPrice = spot.String()
+		response.SpotRequiredWei = amounts.EscrowWei(usdAmount, spot).String()
+	}
+	if withBreakdown {
+		required := amounts.EscrowWei(usdAmount, price)
+		fee, net := amounts.Split(required, pg.config.FeePercentage)
+		breakdown, err := pg.quoteBreakdown(ctx, locale, usdAmount, price, required, fee, net)
+		if err != nil {
+			writeServerError(w, "Failed to estimate quote costs", err)
+			return
+		}
+		response.Breakdown = breakdown
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// newQuote is what a usdAmount job costs at price, with the same conversion
+// and fee the contract applies in postJob and markJobCompleted
+func (pg *PaymentGateway) newQuote(locale format.Locale, pricing string, usdAmount, price *big.Int) QuoteResponse {
+	required := amounts.EscrowWei(usdAmount, price)
+	fee, net := amounts.Split(required, pg.config.FeePercentage)
+	return QuoteResponse{
+		USDAmount:               usdAmount.String(),
+		USDAmountDisplay:        locale.USD(new(big.Rat).SetInt(usdAmount)),
+		Pricing:                 pricing,
+		ETHUSDPrice:             price.String(),
+		ETHUSDPriceDisplay:      locale.USD(amounts.PriceUSD(price)),
+		RequiredWei:             required.String(),
+		RequiredETHDisplay:      locale.ETH(required),
+		PlatformFeeWei:          fee.String(),
+		PlatformFeeETHDisplay:   locale.ETH(fee),
+		FreelancerNetWei:        net.String(),
+		FreelancerNetETHDisplay: locale.ETH(net),
+		Locale:                  locale.Tag,
+	}
+}
+
+// quoteBreakdown estimates the full cost of a usdAmount job escrowing required
+// at price.
+// Gas covers the deposit and the release, each at its recorded average or
+// GAS_LIMIT before any has been recorded, at the current gas price.
+func (pg *PaymentGateway) quoteBreakdown(ctx context.Context, locale format.Locale, usdAmount, price, required, fee, net *big.Int) (*QuoteBreakdown, error) {
+	gasPrice, err := pg.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	averages, err := pg.db.GetOperationGasAverages(ctx, time.Now().Add(-quoteGasHistory))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas averages: %w", err)
+	}
+	recorded := make(map[string]int64, len(averages))
+	for _, average := range averages {
+		recorded[average.Operation] = average.AvgGasUsed
+	}
+
+	totalGas := new(big.Int)
+	gas := make([]QuoteGas, 0, 2)
+	for _, operation := range []string{opPostJob, opCompleteJob} {
+		estimate := QuoteGas{Operation: operation, GasUsed: recorded[operation], EstimatedFrom: runwayFromHistory}
+		if estimate.GasUsed <= 0 {
+			estimate.GasUsed, estimate.EstimatedFrom = int64(pg.config.GasLimit), runwayFromGasLimit
+		}
+		cost := new(big.Int).Mul(gasPrice, big.NewInt(estimate.GasUsed))
+		estimate.CostWei = cost.String()
+		totalGas.Add(totalGas, cost)
+		gas = append(gas, estimate)
+	}
+
+	buffer := new(big.Int)
+	if percent := pg.config.PriceDeviationPercent; percent > 0 && percent < 100 {
+		// The contract needs the most wei at the lowest rate the guard lets through
+		remaining := new(big.Rat).Sub(big.NewRat(100, 1), new(big.Rat).SetFloat64(percent))
+		lowest := amounts.Round(new(big.Rat).Mul(new(big.Rat).SetInt(price), remaining.Quo(remaining, big.NewRat(100, 1))), amounts.Down)
+		if lowest.Sign() > 0 {
+			buffer.Sub(amounts.EscrowWei(usdAmount, lowest), required)
+		}
+	}
+	total := new(big.Int).Add(required, buffer)
+	total.Add(total, totalGas)
+
+	usd := func(wei *big.Int) string {
+		return amounts.Decimal(amounts.WeiToUSD(wei, price), 6, amounts.HalfUp)
+	}
+	breakdown := &QuoteBreakdown{
+		PrincipalWei:       required.String(),
+		PrincipalUSD:       usd(required),
+		FundingBufferWei:   buffer.String(),
+		FundingBufferUSD:   usd(buffer),
+		GasPriceWei:        gasPrice.String(),
+		Gas:                gas,
+		GasWei:             totalGas.String(),
+		GasUSD:             usd(totalGas),
+		PlatformFeeWei:     fee.String(),
+		PlatformFeeUSD:     usd(fee),
+		TotalClientCostWei: total.String(),
+		TotalClientCostUSD: usd(total),
+		FreelancerNetWei:   net.String(),
+		FreelancerNetUSD:   usd(net),
+	}
+	if pg.config.ReserveFeeBPS > 0 {
+		if accrual := ledger.FeeAccrual(0, "", fee, pg.config.ReserveFeeBPS); accrual != nil {
+			breakdown.LedgerEntries = make(map[string]string, len(accrual.Entries))
+			for _, entry := range accrual.Entries {
+				breakdown.LedgerEntries[entry.Account] = entry.Amount.String()
+			}
+		}
+	}
+	breakdown.Display = map[string]string{
+		"principal_usd":         displayUSD(locale, breakdown.PrincipalUSD),
+		"funding_buffer_usd":    displayUSD(locale, breakdown.FundingBufferUSD),
+		"gas_usd":               displayUSD(locale, breakdown.GasUSD),
+		"platform_fee_usd":      displayUSD(locale, breakdown.PlatformFeeUSD),
+		"total_client_cost_usd": displayUSD(locale, breakdown.TotalClientCostUSD),
+		"freelancer_net_usd":    displayUSD(locale, breakdown.FreelancerNetUSD),
+	}
+	return breakdown, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/poll"
+)
+
+// Final payment statuses for each transaction type once its receipt is found
+var (
+	confirmedStatuses = map[string]paymentstatus.Status{"deposit": paymentstatus.Deposited, "release": paymentstatus.Released, "refund": paymentstatus.Refunded}
+	failedStatuses    = map[string]paymentstatus.Status{"deposit": paymentstatus.DepositFailed, "release": paymentstatus.ReleaseFailed, "refund": paymentstatus.RefundFailed}
+	operationsByType  = map[string]string{"deposit": opPostJob, "release": opCompleteJob, "refund": opCancelJob}
+)
+
+// runStatusPoller settles *_initiated applications from their receipts. It
+// polls at POLL_MIN_INTERVAL while transactions are in flight, backs off to
+// POLL_MAX_INTERVAL while idle, and wakes early after a new submission.
+func (pg *PaymentGateway) runStatusPoller(ctx context.Context) {
+	if !pg.config.StatusPolling {
+		return
+	}
+
+	interval := poll.NewInterval(pg.config.PollMinInterval, pg.config.PollMaxInterval, pg.config.PollJitter)
+	next := interval.Next(true)
+	timer := time.NewTimer(next)
+	defer timer.Stop()
+	pg.markWorkerRun("status_poller", next)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pg.pollWake:
+			// Check the new transaction after the minimum interval instead of waiting out a backoff
+			interval.Reset()
+			next = interval.Next(true)
+			timer.Reset(next)
+		case <-timer.C:
+			busy := pg.reconcileInitiatedTransactions(ctx)
+			next = interval.Next(busy)
+			timer.Reset(next)
+		}
+		pg.markWorkerRun("status_poller", next)
+	}
+}
+
+// wakePoller tells the status poller a transaction was just submitted
+func (pg *PaymentGateway) wakePoller() {
+	select {
+	case pg.pollWake <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileInitiatedTransactions fetches the receipts of all initiated
+// transactions in batched RPC calls and applies the confirmed outcomes. It
+// reports whether any transaction is still waiting, so the poller stays fast.
+func (pg *PaymentGateway) reconcileInitiatedTransactions(ctx context.Context) bool {
+	pending, err := pg.db.ListInitiatedTransactions(ctx)
+	if err != nil {
+		log.Printf("Failed to list initiated transactions: %v", err)
+		return false
+	}
+	if len(pending) == 0 {
+		return false
+	}
+
+	hashes := make([]common.Hash, len(pending))
+	for i, tx := range pending {
+		hashes[i] = common.HexToHash(tx.TxHash)
+	}
+
+	rpcCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	statuses, err := pg.client.GetReceiptStatuses(rpcCtx, hashes)
+	cancel()
+	if err != nil {
+		log.Printf("Failed to fetch receipts for reconciliation: %v", err)
+		return true
+	}
+
+	remaining := 0
+	for i, tx := range pending {
+		receipt := statuses[hashes[i]]
+		switch {
+		case receipt == nil:
+			remaining++
+			continue
+		case receipt.Err != nil:
+			log.Printf("Failed to fetch receipt %s for application %d: %v", tx.TxHash, tx.ApplicationID, receipt.Err)
+			remaining++
+			continue
+		case !receipt.Mined || receipt.Confirmations < pg.config.RequiredConfirmations:
+			remaining++
+			continue
+		}
+
+		pg.applyReceipt(ctx, tx, receipt)
+	}
+
+	return remaining > 0
+}
+
+// markLegacyConfirm flags the manual confirm endpoints as deprecated when the
+// status poller settles transactions on its own
+func (pg *PaymentGateway) markLegacyConfirm(w http.ResponseWriter) {
+	if pg.config.StatusPolling {
+		w.Header().Set("Deprecation", "true")
+	}
+}
+
+// applyReceipt moves an initiated application to its final status and records what the transaction cost
+func (pg *PaymentGateway) applyReceipt(ctx context.Context, tx database.InitiatedTransaction, receipt *payment.ReceiptStatus) {
+	status := failedStatuses[tx.TxType]
+	if receipt.Success {
+		status = confirmedStatuses[tx.TxType]
+	}
+
+	blockNumber := int64(receipt.BlockNumber)
+	change := database.StatusChange{
+		ApplicationID: tx.ApplicationID,
+		Status:        status,
+		TxHash:        &tx.TxHash,
+		TxType:        tx.TxType,
+		BlockNumber:   &blockNumber,
+		Actor:         database.ActorReconciler,
+		TraceID:       tx.TraceID,
+	}
+	if err := pg.db.ApplyStatusChange(ctx, change); err != nil {
+		log.Printf("Failed to reconcile application %d: %v", tx.ApplicationID, err)
+		return
+	}
+
+	// Transactions that were pending at submission have no cost recorded yet
+	pg.recordGasCost(ctx, tx.ApplicationID, operationsByType[tx.TxType], &payment.TransactionResult{
+		TxHash:            tx.TxHash,
+		BlockNumber:       receipt.BlockNumber,
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: receipt.EffectiveGasPrice,
+		Success:           receipt.Success,
+	})
+
+	if receipt.Success && tx.TxType == "release" {
+		pg.accrueReserve(ctx, tx.ApplicationID, uint64(tx.ApplicationID), tx.TxHash)
+	}
+
+	log.Printf("%sReconciled application %d: %s -> %s (tx %s)", tracePrefix(tx.TraceID), tx.ApplicationID, tx.PaymentStatus, status, tx.TxHash)
+
+	eventType := events.TransactionConfirmed
+	if !receipt.Success {
+		eventType = events.TransactionFailed
+	}
+	event := events.Transaction{
+		ApplicationID: tx.ApplicationID,
+		TxHash:        tx.TxHash,
+		TxURL:         pg.explorer.Tx(tx.TxHash),
+		BlockNumber:   receipt.BlockNumber,
+		Status:        status,
+	}
+	pg.addDisplayAmounts(ctx, &event)
+	pg.notifyJob(tx.ApplicationID, tx.TraceID, eventType, event)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+)
+
+// errReleaseNotAuthorized is returned when submitting a release that has no
+// active, unexpired authorization
+var errReleaseNotAuthorized = errors.New("release is not authorized")
+
+// ReleaseAuthorizationResponse is a job's latest approval to release its escrow
+type ReleaseAuthorizationResponse struct {
+	AuthorizationID int64      `json:"authorization_id"`
+	ApplicationID   int32      `json:"application_id"`
+	ApprovedBy      string     `json:"approved_by"`
+	ApprovedAt      time.Time  `json:"approved_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	Status          string     `json:"status"` // active, executed, lapsed or superseded
+	Expired         bool       `json:"expired"`
+	TxHash          string     `json:"tx_hash,omitempty"`
+	TxURL           string     `json:"tx_url,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
+
+// authorizeRelease records the client's approval of an application's work.
+// The release must then be confirmed within RELEASE_AUTHORIZATION_TTL. It
+// does nothing when authorizations do not expire.
+func (pg *PaymentGateway) authorizeRelease(ctx context.Context, applicationID int32, actor string) error {
+	if pg.config.ReleaseAuthorizationTTL <= 0 {
+		return nil
+	}
+	_, err := pg.db.CreateReleaseAuthorization(ctx, applicationID, actor, time.Now().Add(pg.config.ReleaseAuthorizationTTL))
+	return err
+}
+
+// activeReleaseAuthorization returns the authorization a release of the
+// application would be submitted under, or errReleaseNotAuthorized when it
+// has lapsed or was never given. It returns nil, nil when authorizations do
+// not expire.
+func (pg *PaymentGateway) activeReleaseAuthorization(ctx context.Context, applicationID int32) (*database.ReleaseAuthorization, error) {
+	if pg.config.ReleaseAuthorizationTTL <= 0 {
+		return nil, nil
+	}
+
+	authorization, err := pg.db.GetReleaseAuthorization(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case authorization == nil:
+		return nil, fmt.Errorf("%w: the client has not approved the work", errReleaseNotAuthorized)
+	case authorization.Status != database.ReleaseAuthorizationActive:
+		return nil, fmt.Errorf("%w: the approval by %s is %s; the client must approve the work again", errReleaseNotAuthorized, authorization.Actor, authorization.Status)
+	case authorization.Expired(time.Now()):
+		return nil, fmt.Errorf("%w: the approval by %s expired at %s; the client must approve the work again", errReleaseNotAuthorized, authorization.Actor, authorization.ExpiresAt.Format(time.RFC3339))
+	}
+	return authorization, nil
+}
+
+// lapseReleaseAuthorizations expires the approvals whose release was not
+// confirmed in time and tells the platform each job needs approving again
+func (pg *PaymentGateway) lapseReleaseAuthorizations(ctx context.Context) {
+	if pg.config.ReleaseAuthorizationTTL <= 0 {
+		return
+	}
+
+	lapsed, err := pg.db.LapseReleaseAuthorizations(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to lapse release authorizations: %v", err)
+		return
+	}
+	for _, authorization := range lapsed {
+		log.Printf("Release authorization %d for application %d by %s lapsed at %s", authorization.ID, authorization.ApplicationID, authorization.Actor, authorization.ExpiresAt.Format(time.RFC3339))
+
+		payload := events.ReleaseAuthorization{
+			AuthorizationID: authorization.ID,
+			ApplicationID:   authorization.ApplicationID,
+			ApprovedBy:      authorization.Actor,
+			ApprovedAt:      authorization.CreatedAt,
+			ExpiresAt:       authorization.ExpiresAt,
+			Status:          authorization.Status,
+		}
+		if authorization.TxHash != nil {
+			payload.TxHash = *authorization.TxHash
+			payload.TxURL = pg.explorer.Tx(*authorization.TxHash)
+		}
+		pg.notifyJob(authorization.ApplicationID, "", events.ReleaseAuthorizationLapsed, payload)
+	}
+}
+
+// GET /jobs/{id}/release-authorization - The latest approval to release a job
+func (pg *PaymentGateway) getReleaseAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	authorization, err := pg.db.GetReleaseAuthorization(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get release authorization", err)
+		return
+	}
+	if authorization == nil {
+		http.Error(w, "Release has not been authorized", http.StatusNotFound)
+		return
+	}
+
+	response := ReleaseAuthorizationResponse{
+		AuthorizationID: authorization.ID,
+		ApplicationID:   authorization.ApplicationID,
+		ApprovedBy:      authorization.Actor,
+		ApprovedAt:      authorization.CreatedAt,
+		ExpiresAt:       authorization.ExpiresAt,
+		Status:          authorization.Status,
+		Expired:         authorization.Expired(time.Now()),
+		ResolvedAt:      authorization.ResolvedAt,
+	}
+	if authorization.TxHash != nil {
+		response.TxHash = *authorization.TxHash
+		response.TxURL = pg.explorer.Tx(*authorization.TxHash)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
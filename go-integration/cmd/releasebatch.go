@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/trace"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/workpool"
+)
+
+// Outcomes of one job in a batch release
+const (
+	batchReleased   = "released"    // release mined
+	batchPending    = "pending"     // release broadcast, receipt not yet seen
+	batchDeferred   = "deferred"    // queued until gas drops or the RPC recovers
+	batchScheduled  = "scheduled"   // queued for a cheaper gas hour in economical mode
+	batchSkipped    = "skipped"     // not attempted
+	batchKYCPending = "kyc_pending" // held until the freelancer passes KYC
+	batchFailed     = "failed"
+)
+
+// ReleaseBatchRequest names the freelancer whose approved jobs are paid out
+type ReleaseBatchRequest struct {
+	FreelancerUserID int32  `json:"freelancer_user_id"`
+	Mode             string `json:"mode,omitempty"` // immediate (default) or economical
+}
+
+// ReleaseBatchResult is the outcome of releasing one job of a batch
+type ReleaseBatchResult struct {
+	ApplicationID int32                `json:"application_id"`
+	USDAmount     *int32               `json:"usd_amount,omitempty"`
+	Status        string               `json:"status"`
+	Transaction   *TransactionResponse `json:"transaction,omitempty"`
+	OperationID   int64                `json:"operation_id,omitempty"` // set when deferred or scheduled
+	KYCHoldID     int64                `json:"kyc_hold_id,omitempty"`  // set when held for KYC
+	Error         string               `json:"error,omitempty"`
+	Code          string               `json:"code,omitempty"` // the contract's revert, e.g. job_not_completed
+}
+
+// ReleaseBatchResponse lists each job's outcome in the order it was released
+type ReleaseBatchResponse struct {
+	FreelancerUserID int32                `json:"freelancer_user_id"`
+	Released         int                  `json:"released"`
+	Results          []ReleaseBatchResult `json:"results"`
+}
+
+// POST /release-batch - Release every approved job of one freelancer
+func (pg *PaymentGateway) releaseBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.ReleaseBatches) {
+		return
+	}
+
+	var req ReleaseBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.FreelancerUserID <= 0 {
+		http.Error(w, "freelancer_user_id is required", http.StatusBadRequest)
+		return
+	}
+	mode, err := pg.parseReleaseMode(req.Mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	listCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	applications, err := pg.db.ListReleasableApplications(listCtx, req.FreelancerUserID, pg.config.ReleaseApprovedStatus)
+	cancel()
+	if err != nil {
+		writeServerError(w, "Failed to list approved jobs", err)
+		return
+	}
+	if limit := pg.config.ReleaseBatchLimit; limit > 0 && len(applications) > limit {
+		http.Error(w, fmt.Sprintf("Freelancer has %d approved jobs, more than RELEASE_BATCH_LIMIT of %d", len(applications), limit), http.StatusUnprocessableEntity)
+		return
+	}
+	pg.orderBatch(applications)
+
+	response := ReleaseBatchResponse{FreelancerUserID: req.FreelancerUserID, Results: make([]ReleaseBatchResult, 0, len(applications))}
+	var stop string // why the rest of the batch is not attempted
+	for _, details := range applications {
+		result := ReleaseBatchResult{ApplicationID: details.ApplicationID, USDAmount: details.AgreedUSDAmount}
+		if stop != "" {
+			result.Status, result.Error = batchSkipped, stop
+		} else {
+			stop = pg.releaseApprovedJob(details.ApplicationID, actor, mode, trace.ID(r.Context()), &result)
+		}
+		if result.Status == batchReleased {
+			response.Released++
+		}
+		response.Results = append(response.Results, result)
+	}
+	log.Printf("Batch release for freelancer %d: %d of %d jobs released", req.FreelancerUserID, response.Released, len(applications))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// orderBatch sorts the jobs so those signed by the hot key go first and any
+// needing the admin signer follow together, oldest first within each group.
+// Releases are sent one after another, so each signer's nonces stay in order.
+func (pg *PaymentGateway) orderBatch(applications []*database.ApplicationPaymentDetails) {
+	privileged := func(details *database.ApplicationPaymentDetails) bool {
+		if pg.client.AdminAddress() == (common.Address{}) || pg.config.PrivilegedUSDThreshold <= 0 || details.AgreedUSDAmount == nil {
+			return false
+		}
+		return big.NewInt(int64(*details.AgreedUSDAmount)).Cmp(big.NewInt(pg.config.PrivilegedUSDThreshold)) >= 0
+	}
+	sort.SliceStable(applications, func(i, j int) bool {
+		pi, pj := privileged(applications[i]), privileged(applications[j])
+		if pi != pj {
+			return !pi
+		}
+		return applications[i].ApplicationID < applications[j].ApplicationID
+	})
+}
+
+// releaseApprovedJob releases one approved job into result, the way
+// /complete-job would in mode, authorizing the release on behalf of actor
+// unless it is empty and tracing it under traceID. It returns why the
+// releases after it should not be attempted, or "" to carry on.
+func (pg *PaymentGateway) releaseApprovedJob(applicationID int32, actor, mode, traceID string, result *ReleaseBatchResult) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pending, err := pg.db.GetPendingDeferredOperation(ctx, applicationID)
+	if err != nil {
+		result.Status, result.Error = batchFailed, fmt.Sprintf("failed to check deferred operations: %v", err)
+		return ""
+	}
+	if pending != nil {
+		result.Status, result.Error = batchSkipped, fmt.Sprintf("operation %s is already deferred until %s", pending.Operation, pending.Deadline.Format(time.RFC3339))
+		return ""
+	}
+
+	if actor != "" {
+		if err := pg.authorizeRelease(ctx, applicationID, actor); err != nil {
+			result.Status, result.Error = batchFailed, fmt.Sprintf("failed to authorize release: %v", err)
+			return ""
+		}
+	}
+
+	params := database.OperationParams{JobID: uint64(applicationID), TraceID: traceID}
+	if pg.kyc != nil {
+		details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+		if err != nil {
+			result.Status, result.Error = batchFailed, fmt.Sprintf("failed to get application details: %v", err)
+			return ""
+		}
+		hold, err := pg.kycHold(ctx, details, params)
+		switch {
+		case err != nil:
+			result.Status, result.Error = batchFailed, fmt.Sprintf("failed to hold release for KYC: %v", err)
+			return ""
+		case hold != nil:
+			result.Status, result.KYCHoldID = batchKYCPending, hold.ID
+			return ""
+		}
+	}
+	if mode == releaseModeEconomical {
+		op, scheduled, err := pg.scheduleEconomicalRelease(ctx, applicationID, params)
+		switch {
+		case scheduled && err != nil:
+			result.Status, result.Error = batchFailed, fmt.Sprintf("failed to schedule economical release: %v", err)
+			return ""
+		case scheduled:
+			result.Status, result.OperationID = batchScheduled, op.ID
+			return ""
+		}
+	}
+
+	tx, err := pg.submitOperation(ctx, applicationID, opCompleteJob, params)
+	if err == nil {
+		result.Status, result.Transaction = batchReleased, pg.newTransactionResponse(tx)
+		return ""
+	}
+
+	op, queued, dbErr := pg.queueRetryable(ctx, applicationID, opCompleteJob, params, err)
+	switch {
+	case queued && dbErr != nil:
+		result.Status, result.Error = batchFailed, fmt.Sprintf("%s and queueing failed: %v", payment.ClassifyError(err).Message, dbErr)
+		return ""
+	case queued:
+		result.Status, result.OperationID = batchDeferred, op.ID
+		return ""
+	}
+
+	if errors.Is(err, workpool.ErrQueueFull) {
+		result.Status, result.Error = batchFailed, "gateway is busy submitting other transactions"
+		return "not attempted: " + result.Error
+	}
+	if errors.Is(err, errSigningHalted) {
+		result.Status, result.Error = batchFailed, err.Error()
+		return "not attempted: " + result.Error
+	}
+	classified := payment.ClassifyError(err)
+	switch classified.Reason {
+	case payment.ReasonPending:
+		result.Status, result.Transaction = batchPending, pg.newTransactionResponse(tx)
+		return ""
+	case payment.ReasonGasPriceTooHigh, payment.ReasonInsufficientFunds:
+		// Every later release would fail the same way
+		result.Status, result.Error = batchFailed, classified.Message
+		return "not attempted: " + classified.Message
+	}
+	result.Status, result.Error = batchFailed, classified.Error()
+	if classified.Contract != nil {
+		result.Code = classified.Contract.Code
+	}
+	return ""
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// RefundReportBucket is the refund volume for one reason within one period
+type RefundReportBucket struct {
+	Period          string `json:"period"`
+	Reason          string `json:"reason"`
+	Count           int64  `json:"count"`
+	TotalUSD        int64  `json:"total_usd"`
+	TotalUSDDisplay string `json:"total_usd_display"`
+}
+
+// RefundReasonTotal is the refund volume for one reason across the whole range
+type RefundReasonTotal struct {
+	Reason          string `json:"reason"`
+	Count           int64  `json:"count"`
+	TotalUSD        int64  `json:"total_usd"`
+	TotalUSDDisplay string `json:"total_usd_display"`
+}
+
+type RefundReportResponse struct {
+	From     string               `json:"from"`
+	To       string               `json:"to"`
+	Interval string               `json:"interval"`
+	Tags     []string             `json:"tags,omitempty"` // only jobs carrying all of these
+	Buckets  []RefundReportBucket `json:"buckets"`
+	Totals   []RefundReasonTotal  `json:"totals"`
+}
+
+// GET /reports/refunds?from=YYYY-MM-DD&to=YYYY-MM-DD&interval=day|week|month&tag=&reason[in]= - Refund volume by reason
+func (pg *PaymentGateway) refundReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reportRange, err := listquery.ParseRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filters, err := database.RefundReportSpec.ParseFilters(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tagFilter, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pg.db.GetRefundReport(ctx, reportRange, filters, tagFilter)
+	if err != nil {
+		writeServerError(w, "Failed to build refund report", err)
+		return
+	}
+	locale := localeFor(r)
+
+	response := RefundReportResponse{
+		From:     reportRange.From.Format(time.DateOnly),
+		To:       reportRange.Last().Format(time.DateOnly),
+		Interval: reportRange.Interval,
+		Tags:     tagFilter,
+		Buckets:  []RefundReportBucket{},
+		Totals:   []RefundReasonTotal{},
+	}
+
+	totals := make(map[string]int) // reason -> index into response.Totals
+	for _, row := range rows {
+		response.Buckets = append(response.Buckets, RefundReportBucket{
+			Period:          row.Period.Format(time.DateOnly),
+			Reason:          row.Reason,
+			Count:           row.Count,
+			TotalUSD:        row.TotalUSD,
+			TotalUSDDisplay: locale.USDInt(row.TotalUSD),
+		})
+
+		idx, ok := totals[row.Reason]
+		if !ok {
+			response.Totals = append(response.Totals, RefundReasonTotal{Reason: row.Reason})
+			idx = len(response.Totals) - 1
+			totals[row.Reason] = idx
+		}
+		response.Totals[idx].Count += row.Count
+		response.Totals[idx].TotalUSD += row.TotalUSD
+	}
+	for i := range response.Totals {
+		response.Totals[i].TotalUSDDisplay = locale.USDInt(response.Totals[i].TotalUSD)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GasCostReportBucket is the gas spent on one operation type within one period
+type GasCostReportBucket struct {
+	Period         string `json:"period"`
+	Operation      string `json:"operation"`
+	Transactions   int64  `json:"transactions"`
+	GasUsed        int64  `json:"gas_used"`
+	CostWei        string `json:"cost_wei"`
+	CostETHDisplay string `json:"cost_eth_display"`
+	CostUSD        string `json:"cost_usd"`
+	CostUSDDisplay string `json:"cost_usd_display"`
+}
+
+type GasCostReportResponse struct {
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	Interval string                `json:"interval"`
+	Tags     []string              `json:"tags,omitempty"` // only jobs carrying all of these
+	Buckets  []GasCostReportBucket `json:"buckets"`
+}
+
+// GET /reports/gas-costs?from=YYYY-MM-DD&to=YYYY-MM-DD&interval=day|week|month&tag=&operation[in]= - Gas spend by operation
+func (pg *PaymentGateway) gasCostReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reportRange, err := listquery.ParseRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filters, err := database.GasCostReportSpec.ParseFilters(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tagFilter, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pg.db.GetGasCostReport(ctx, reportRange, filters, tagFilter)
+	if err != nil {
+		writeServerError(w, "Failed to build gas cost report", err)
+		return
+	}
+	locale := localeFor(r)
+
+	response := GasCostReportResponse{
+		From:     reportRange.From.Format(time.DateOnly),
+		To:       reportRange.Last().Format(time.DateOnly),
+		Interval: reportRange.Interval,
+		Tags:     tagFilter,
+		Buckets:  []GasCostReportBucket{},
+	}
+	for _, row := range rows {
+		response.Buckets = append(response.Buckets, GasCostReportBucket{
+			Period:         row.Period.Format(time.DateOnly),
+			Operation:      row.Operation,
+			Transactions:   row.Transactions,
+			GasUsed:        row.GasUsed,
+			CostWei:        row.CostWei,
+			CostETHDisplay: displayWei(locale, row.CostWei),
+			CostUSD:        row.CostUSD,
+			CostUSDDisplay: displayUSD(locale, row.CostUSD),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GasWasteReportBucket is the gas wasted by one operation type for one reason
+// within one period
+type GasWasteReportBucket struct {
+	Period         string `json:"period"`
+	Operation      string `json:"operation"`
+	Reason         string `json:"reason"`
+	Transactions   int64  `json:"transactions"`
+	GasUsed        int64  `json:"gas_used"`
+	CostWei        string `json:"cost_wei"`
+	CostETHDisplay string `json:"cost_eth_display"`
+	CostUSD        string `json:"cost_usd"`
+	CostUSDDisplay string `json:"cost_usd_display"`
+}
+
+type GasWasteReportResponse struct {
+	From     string                 `json:"from"`
+	To       string                 `json:"to"`
+	Interval string                 `json:"interval"`
+	Tags     []string               `json:"tags,omitempty"` // only jobs carrying all of these
+	Buckets  []GasWasteReportBucket `json:"buckets"`
+}
+
+// GET /reports/gas-waste?from=YYYY-MM-DD&to=YYYY-MM-DD&interval=day|week|month&tag=&reason[in]= - Gas wasted by operation and reason
+func (pg *PaymentGateway) gasWasteReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reportRange, err := listquery.ParseRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filters, err := database.GasWasteReportSpec.ParseFilters(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tagFilter, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pg.db.GetGasWasteReport(ctx, reportRange, filters, tagFilter)
+	if err != nil {
+		writeServerError(w, "Failed to build gas waste report", err)
+		return
+	}
+	locale := localeFor(r)
+
+	response := GasWasteReportResponse{
+		From:     reportRange.From.Format(time.DateOnly),
+		To:       reportRange.Last().Format(time.DateOnly),
+		Interval: reportRange.Interval,
+		Tags:     tagFilter,
+		Buckets:  []GasWasteReportBucket{},
+	}
+	for _, row := range rows {
+		response.Buckets = append(response.Buckets, GasWasteReportBucket{
+			Period:         row.Period.Format(time.DateOnly),
+			Operation:      row.Operation,
+			Reason:         row.Reason,
+			Transactions:   row.Transactions,
+			GasUsed:        row.GasUsed,
+			CostWei:        row.CostWei,
+			CostETHDisplay: displayWei(locale, row.CostWei),
+			CostUSD:        row.CostUSD,
+			CostUSDDisplay: displayUSD(locale, row.CostUSD),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
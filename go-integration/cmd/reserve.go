@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/address"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+)
+
+// reservePayoutHistory is how many recent payouts GET /reserve lists
+const reservePayoutHistory = 20
+
+type ReservePayoutRequest struct {
+	Reference     string `json:"reference"` // e.g. hash of the compensation transfer; unique per payout
+	Recipient     string `json:"recipient"`
+	AmountWei     string `json:"amount_wei"`
+	ApplicationID *int32 `json:"application_id,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// LedgerTransactionResponse is a posted ledger transaction with its entries
+type LedgerTransactionResponse struct {
+	ID            int64             `json:"id"`
+	Kind          string            `json:"kind"`
+	Reference     string            `json:"reference"`
+	ApplicationID *int32            `json:"application_id,omitempty"`
+	Counterparty  string            `json:"counterparty,omitempty"`
+	Memo          string            `json:"memo,omitempty"`
+	Actor         string            `json:"actor"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Entries       map[string]string `json:"entries"` // account -> signed wei, positive for debits
+}
+
+type ReserveResponse struct {
+	Enabled                bool                        `json:"enabled"`
+	FeeBPS                 int64                       `json:"fee_bps"`
+	BalanceWei             string                      `json:"balance_wei"`
+	Accounts               map[string]string           `json:"accounts"`
+	Balanced               bool                        `json:"balanced"`
+	UnbalancedTransactions []int64                     `json:"unbalanced_transactions,omitempty"`
+	RecentPayouts          []LedgerTransactionResponse `json:"recent_payouts"`
+}
+
+func newLedgerTransactionResponse(t *database.LedgerTransaction) LedgerTransactionResponse {
+	response := LedgerTransactionResponse{
+		ID:            t.ID,
+		Kind:          t.Kind,
+		Reference:     t.Reference,
+		ApplicationID: t.ApplicationID,
+		Counterparty:  derefString(t.Counterparty),
+		Memo:          t.Memo,
+		Actor:         t.Actor,
+		CreatedAt:     t.CreatedAt,
+		Entries:       make(map[string]string, len(t.Entries)),
+	}
+	for _, entry := range t.Entries {
+		response.Entries[entry.Account] = entry.AmountWei
+	}
+	return response
+}
+
+// accrueReserve posts the platform fee of a confirmed release to the ledger,
+// setting aside RESERVE_FEE_BPS of it in the reserve fund, after the job's fee
+// override if it has one. Postings are keyed by release transaction, so
+// seeing the same release twice posts once.
+func (pg *PaymentGateway) accrueReserve(ctx context.Context, applicationID int32, jobID uint64, releaseTxHash string) {
+	override, err := pg.db.GetFeeOverride(ctx, applicationID)
+	if err != nil {
+		log.Printf("Warning: Failed to get fee override of application %d for reserve accounting: %v", applicationID, err)
+		return
+	}
+	if pg.config.ReserveFeeBPS <= 0 && override == nil {
+		return
+	}
+
+	job, err := pg.client.GetJobDetails(ctx, jobID)
+	if err != nil {
+		log.Printf("Warning: Failed to get released job %d for reserve accounting: %v", jobID, err)
+		return
+	}
+
+	fee := pg.applyFeeOverride(ctx, override, job, releaseTxHash)
+	if pg.config.ReserveFeeBPS <= 0 {
+		return
+	}
+	t := ledger.FeeAccrual(applicationID, releaseTxHash, fee, pg.config.ReserveFeeBPS)
+	if t == nil {
+		return
+	}
+	t.Actor = database.ActorGateway
+
+	if _, err := pg.db.PostLedgerTransaction(ctx, t); err != nil {
+		log.Printf("Warning: Failed to post fee accrual for release %s: %v", releaseTxHash, err)
+	}
+}
+
+// GET /reserve - Reserve fund balance, ledger integrity and recent payouts
+func (pg *PaymentGateway) getReserveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	balances, err := pg.db.GetLedgerBalances(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get ledger balances", err)
+		return
+	}
+	unbalanced, err := pg.db.ListUnbalancedLedgerTransactions(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to check ledger", err)
+		return
+	}
+	payouts, err := pg.db.ListLedgerTransactions(ctx, ledger.KindReservePayout, reservePayoutHistory)
+	if err != nil {
+		writeServerError(w, "Failed to list reserve payouts", err)
+		return
+	}
+
+	// The reserve is a credit balance, so its available amount is the negated net
+	balance := new(big.Int)
+	if net, ok := new(big.Int).SetString(balances[ledger.AccountReserveFund], 10); ok {
+		balance.Neg(net)
+	}
+
+	response := ReserveResponse{
+		Enabled:                pg.config.ReserveFeeBPS > 0,
+		FeeBPS:                 pg.config.ReserveFeeBPS,
+		BalanceWei:             balance.String(),
+		Accounts:               balances,
+		Balanced:               len(unbalanced) == 0,
+		UnbalancedTransactions: unbalanced,
+		RecentPayouts:          make([]LedgerTransactionResponse, 0, len(payouts)),
+	}
+	for _, payout := range payouts {
+		response.RecentPayouts = append(response.RecentPayouts, newLedgerTransactionResponse(payout))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /reserve/payouts - Record compensation paid out of the reserve fund
+func (pg *PaymentGateway) createReservePayoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.ReservePayouts) {
+		return
+	}
+
+	var req ReservePayoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Reference == "" {
+		http.Error(w, "reference is required", http.StatusBadRequest)
+		return
+	}
+	recipient, err := address.Canonical(req.Recipient)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid recipient address: %v", err), http.StatusBadRequest)
+		return
+	}
+	amount, ok := new(big.Int).SetString(req.AmountWei, 10)
+	if !ok || amount.Sign() <= 0 {
+		http.Error(w, "amount_wei must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t := ledger.ReservePayout(req.Reference, recipient, amount, req.ApplicationID, req.Reason, actor)
+	posted, err := pg.db.PostReservePayout(ctx, t, amount)
+	if errors.Is(err, database.ErrInsufficientReserve) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeServerError(w, "Failed to record reserve payout", err)
+		return
+	}
+	if !posted {
+		http.Error(w, "A payout with this reference was already recorded", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Recorded reserve payout %s of %s wei to %s by %s", req.Reference, amount, recipient, actor)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "reference": req.Reference})
+}
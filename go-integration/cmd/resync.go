@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+type ResyncRequest struct {
+	Reason string `json:"reason"`
+}
+
+// PaymentRecordResponse is an application's payment status and transaction hashes
+type PaymentRecordResponse struct {
+	PaymentStatus paymentstatus.Status `json:"payment_status"`
+	TxHashDeposit string               `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease string               `json:"tx_hash_release,omitempty"`
+	TxHashRefund  string               `json:"tx_hash_refund,omitempty"`
+}
+
+type ResyncResponse struct {
+	JobID   uint64                `json:"job_id"`
+	Before  PaymentRecordResponse `json:"before"`
+	After   PaymentRecordResponse `json:"after"`
+	Changed bool                  `json:"changed"`
+	DryRun  bool                  `json:"dry_run"`
+	Events  int                   `json:"events"` // escrow events found for the job
+}
+
+func newPaymentRecordResponse(record database.PaymentRecord) PaymentRecordResponse {
+	return PaymentRecordResponse{
+		PaymentStatus: record.PaymentStatus,
+		TxHashDeposit: derefString(record.DepositTxHash),
+		TxHashRelease: derefString(record.ReleaseTxHash),
+		TxHashRefund:  derefString(record.RefundTxHash),
+	}
+}
+
+// POST /jobs/{id}/resync?dry_run=true - Overwrite a job's payment record with chain truth
+func (pg *PaymentGateway) resyncJobHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req ResyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+
+	onchain, err := pg.client.GetJobDetails(ctx, jobID)
+	if err != nil {
+		writeServerError(w, "Failed to get on-chain job", err)
+		return
+	}
+	history, err := pg.client.GetJobHistory(ctx, jobID)
+	if err != nil {
+		writeServerError(w, "Failed to get job event history", err)
+		return
+	}
+
+	chain := payment.DeriveChainRecord(history, onchain)
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	after := database.PaymentRecord{
+		PaymentStatus: chain.PaymentStatus,
+		DepositTxHash: optional(chain.DepositTxHash),
+		ReleaseTxHash: optional(chain.ReleaseTxHash),
+		RefundTxHash:  optional(chain.RefundTxHash),
+	}
+	before := database.PaymentRecord{
+		PaymentStatus: details.PaymentStatus,
+		DepositTxHash: details.EscrowTxHashDeposit,
+		ReleaseTxHash: details.EscrowTxHashRelease,
+		RefundTxHash:  details.EscrowTxHashRefund,
+	}
+
+	response := ResyncResponse{
+		JobID:  jobID,
+		Before: newPaymentRecordResponse(before),
+		After:  newPaymentRecordResponse(after),
+		DryRun: dryRun,
+		Events: len(history),
+	}
+	response.Changed = response.Before != response.After
+
+	if response.Changed && !dryRun {
+		stored, err := pg.db.OverwritePaymentRecord(ctx, applicationID, after, actor, req.Reason)
+		if err != nil {
+			writeServerError(w, "Failed to overwrite payment record", err)
+			return
+		}
+		response.Before = newPaymentRecordResponse(*stored)
+		log.Printf("Resynced application %d from chain by %s: %s -> %s", applicationID, actor, stored.PaymentStatus, after.PaymentStatus)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/explorer"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/retainer"
+)
+
+// Chain operations on retainer periods, recorded separately in gas reports
+const (
+	opRetainerFund    = "retainer_fund"
+	opRetainerRelease = "retainer_release"
+	opRetainerRefund  = "retainer_refund"
+)
+
+type CreateRetainerRequest struct {
+	ApplicationID int32      `json:"application_id"`
+	USDAmount     int32      `json:"usd_amount"` // per period
+	Interval      string     `json:"interval"`   // "week" or "month"
+	Mode          string     `json:"mode"`       // "custodial" (default) or "prompt"
+	StartAt       *time.Time `json:"start_at"`   // defaults to now
+	EndAt         time.Time  `json:"end_at"`
+}
+
+type RetainerResponse struct {
+	RetainerID    int64                    `json:"retainer_id"`
+	ApplicationID int32                    `json:"application_id"`
+	USDAmount     int32                    `json:"usd_amount"`
+	Interval      string                   `json:"interval"`
+	Mode          string                   `json:"mode"`
+	Status        string                   `json:"status"`
+	StartAt       time.Time                `json:"start_at"`
+	EndAt         time.Time                `json:"end_at"`
+	NextPeriodAt  *time.Time               `json:"next_period_at,omitempty"`
+	Periods       []RetainerPeriodResponse `json:"periods"`
+	FundedUSD     int64                    `json:"funded_usd"`
+	ReleasedUSD   int64                    `json:"released_usd"`
+	RefundedUSD   int64                    `json:"refunded_usd"`
+}
+
+// RetainerPeriodResponse describes one billing period and its escrow job
+type RetainerPeriodResponse struct {
+	RetainerID    int64     `json:"retainer_id"`
+	PeriodNumber  int       `json:"period_number"`
+	EscrowJobID   uint64    `json:"escrow_job_id"`
+	USDAmount     int32     `json:"usd_amount"`
+	Status        string    `json:"status"`
+	PeriodStart   time.Time `json:"period_start"`
+	TxHashDeposit string    `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease string    `json:"tx_hash_release,omitempty"`
+	TxHashRefund  string    `json:"tx_hash_refund,omitempty"`
+	TxURLDeposit  string    `json:"tx_url_deposit,omitempty"`
+	TxURLRelease  string    `json:"tx_url_release,omitempty"`
+	TxURLRefund   string    `json:"tx_url_refund,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	RequiredWei   string    `json:"required_wei,omitempty"`
+	DepositedWei  string    `json:"deposited_wei,omitempty"`
+	TopUpWei      string    `json:"topup_wei,omitempty"`
+	OverfundedWei string    `json:"overfunded_wei,omitempty"`
+}
+
+func newRetainerPeriodResponse(period *database.RetainerPeriod, links explorer.Links) RetainerPeriodResponse {
+	response := RetainerPeriodResponse{
+		RetainerID:   period.RetainerID,
+		PeriodNumber: period.PeriodNumber,
+		EscrowJobID:  retainer.EscrowJobID(period.ID),
+		USDAmount:    period.USDAmount,
+		Status:       period.Status,
+		PeriodStart:  period.PeriodStart,
+	}
+	if period.TxHashDeposit != nil {
+		response.TxHashDeposit = *period.TxHashDeposit
+		response.TxURLDeposit = links.Tx(*period.TxHashDeposit)
+	}
+	if period.TxHashRelease != nil {
+		response.TxHashRelease = *period.TxHashRelease
+		response.TxURLRelease = links.Tx(*period.TxHashRelease)
+	}
+	if period.TxHashRefund != nil {
+		response.TxHashRefund = *period.TxHashRefund
+		response.TxURLRefund = links.Tx(*period.TxHashRefund)
+	}
+	if period.LastError != nil {
+		response.Error = *period.LastError
+	}
+	response.RequiredWei = derefString(period.RequiredWei)
+	response.DepositedWei = derefString(period.DepositedWei)
+	response.TopUpWei = derefString(period.TopUpWei)
+	response.OverfundedWei = derefString(period.OverfundedWei)
+	return response
+}
+
+func newRetainerResponse(r *database.Retainer, periods []*database.RetainerPeriod, links explorer.Links) RetainerResponse {
+	response := RetainerResponse{
+		RetainerID:    r.ID,
+		ApplicationID: r.ApplicationID,
+		USDAmount:     r.USDAmount,
+		Interval:      r.Interval,
+		Mode:          r.Mode,
+		Status:        r.Status,
+		StartAt:       r.StartAt,
+		EndAt:         r.EndAt,
+		Periods:       make([]RetainerPeriodResponse, 0, len(periods)),
+	}
+	if r.Status == database.RetainerStatusActive {
+		response.NextPeriodAt = &r.NextPeriodAt
+	}
+
+	for _, period := range periods {
+		response.Periods = append(response.Periods, newRetainerPeriodResponse(period, links))
+		switch period.Status {
+		case database.PeriodStatusFunded:
+			response.FundedUSD += int64(period.USDAmount)
+		case database.PeriodStatusReleased:
+			response.FundedUSD += int64(period.USDAmount)
+			response.ReleasedUSD += int64(period.USDAmount)
+		case database.PeriodStatusRefunded:
+			response.FundedUSD += int64(period.USDAmount)
+			response.RefundedUSD += int64(period.USDAmount)
+		}
+	}
+	return response
+}
+
+// POST /retainers - Define a recurring escrow schedule for an accepted application
+func (pg *PaymentGateway) createRetainerHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.Retainers) {
+		return
+	}
+
+	var req CreateRetainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = retainer.ModeCustodial
+	}
+	startAt := time.Now().UTC()
+	if req.StartAt != nil {
+		startAt = *req.StartAt
+	}
+	if req.USDAmount <= 0 {
+		http.Error(w, "Invalid USD amount", http.StatusBadRequest)
+		return
+	}
+	if err := retainer.ValidateSchedule(req.Interval, req.Mode, startAt, req.EndAt); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid schedule: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := pg.db.ValidateApplicationForBlockchain(ctx, req.ApplicationID); err != nil {
+		if database.IsTimeout(err) {
+			writeServerError(w, "Application validation failed", err)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Application validation failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, req.ApplicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	pg.recordParties(ctx, details)
+
+	created := &database.Retainer{
+		ApplicationID: req.ApplicationID,
+		USDAmount:     req.USDAmount,
+		Interval:      req.Interval,
+		Mode:          req.Mode,
+		StartAt:       startAt,
+		EndAt:         req.EndAt,
+	}
+	if err := pg.db.CreateRetainer(ctx, created); err != nil {
+		writeServerError(w, "Failed to create retainer", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newRetainerResponse(created, nil, pg.explorer))
+}
+
+// GET /retainers/{id} - Get a retainer with all of its periods
+func (pg *PaymentGateway) getRetainerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	found, ok := pg.loadRetainer(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	periods, err := pg.db.ListRetainerPeriods(ctx, found.ID)
+	if err != nil {
+		writeServerError(w, "Failed to get retainer periods", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRetainerResponse(found, periods, pg.explorer))
+}
+
+// POST /retainers/{id}/cancel - Stop creating new periods; existing escrows are unaffected
+func (pg *PaymentGateway) cancelRetainerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	found, ok := pg.loadRetainer(ctx, w, r)
+	if !ok {
+		return
+	}
+	if found.Status != database.RetainerStatusActive {
+		http.Error(w, fmt.Sprintf("Retainer is already %s", found.Status), http.StatusConflict)
+		return
+	}
+
+	if err := pg.db.SetRetainerStatus(ctx, found.ID, database.RetainerStatusCancelled); err != nil {
+		writeServerError(w, "Failed to cancel retainer", err)
+		return
+	}
+	found.Status = database.RetainerStatusCancelled
+
+	periods, err := pg.db.ListRetainerPeriods(ctx, found.ID)
+	if err != nil {
+		writeServerError(w, "Failed to get retainer periods", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRetainerResponse(found, periods, pg.explorer))
+}
+
+// POST /retainers/{id}/periods/{period}/release - Release a funded period to the freelancer
+func (pg *PaymentGateway) releaseRetainerPeriodHandler(w http.ResponseWriter, r *http.Request) {
+	pg.settleRetainerPeriod(w, r, "release")
+}
+
+// POST /retainers/{id}/periods/{period}/refund?reason=X - Refund a funded period to the client
+func (pg *PaymentGateway) refundRetainerPeriodHandler(w http.ResponseWriter, r *http.Request) {
+	pg.settleRetainerPeriod(w, r, "refund")
+}
+
+// settleRetainerPeriod releases or refunds the escrow job of a funded period
+func (pg *PaymentGateway) settleRetainerPeriod(w http.ResponseWriter, r *http.Request, txType string) {
+	periodNumber, err := strconv.Atoi(r.PathValue("period"))
+	if err != nil {
+		http.Error(w, "Invalid period number", http.StatusBadRequest)
+		return
+	}
+
+	reason := payment.RefundReasonOther
+	if txType == "refund" {
+		if reason, err = payment.ParseRefundReason(r.URL.Query().Get("reason")); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid refund reason: expected one of %v", payment.RefundReasons), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	found, ok := pg.loadRetainer(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	period, err := pg.db.GetRetainerPeriod(ctx, found.ID, periodNumber)
+	if err != nil {
+		writeServerError(w, "Failed to get retainer period", err)
+		return
+	}
+	if period == nil {
+		http.Error(w, "Retainer period not found", http.StatusNotFound)
+		return
+	}
+	// An underfunded escrow can be refunded but not paid out
+	refundable := txType == "refund" && period.Status == database.PeriodStatusUnderfunded
+	if period.Status != database.PeriodStatusFunded && !refundable {
+		http.Error(w, fmt.Sprintf("Cannot %s period: status is '%s', expected 'funded'", txType, period.Status), http.StatusBadRequest)
+		return
+	}
+
+	jobID := retainer.EscrowJobID(period.ID)
+	var result *payment.TransactionResult
+	var operation, status string
+	if txType == "release" {
+		opCtx := pg.trackBroadcasts(ctx, found.ApplicationID, opRetainerRelease, jobID)
+		result, err = pg.client.MarkJobCompleted(payment.WithFeeStrategy(opCtx, pg.feeStrategy(opRetainerRelease, database.OperationParams{})), jobID)
+		operation, status = opRetainerRelease, database.PeriodStatusReleased
+	} else {
+		result, err = pg.client.CancelJob(pg.trackBroadcasts(ctx, found.ApplicationID, opRetainerRefund, jobID), jobID)
+		operation, status = opRetainerRefund, database.PeriodStatusRefunded
+	}
+	if err != nil {
+		// Keep the hash of a broadcast transaction so it isn't resent
+		var pending *payment.TransactionPendingError
+		if errors.As(err, &pending) {
+			msg := pending.Error()
+			if dbErr := pg.db.UpdateRetainerPeriod(ctx, period.ID, period.Status, txType, &result.TxHash, &msg); dbErr != nil {
+				log.Printf("Warning: Failed to update retainer period: %v", dbErr)
+			}
+		}
+		pg.writeChainError(w, fmt.Sprintf("Failed to %s retainer period", txType), result, err)
+		return
+	}
+
+	if err := pg.db.UpdateRetainerPeriod(ctx, period.ID, status, txType, &result.TxHash, nil); err != nil {
+		log.Printf("Warning: Failed to update retainer period: %v", err)
+	}
+	pg.recordGasCost(ctx, found.ApplicationID, operation, result)
+
+	if txType == "release" && result.Success {
+		pg.accrueReserve(ctx, found.ApplicationID, jobID, result.TxHash)
+	}
+
+	if txType == "refund" && result.Success {
+		if err := pg.db.RecordRefund(ctx, found.ApplicationID, string(reason), period.USDAmount, result.TxHash); err != nil {
+			log.Printf("Warning: Failed to record refund reason in database: %v", err)
+		}
+	}
+
+	pg.writeTransactionResponse(w, result)
+}
+
+// loadRetainer reads the {id} path value and fetches the retainer.
+// It returns false after writing an error response.
+func (pg *PaymentGateway) loadRetainer(ctx context.Context, w http.ResponseWriter, r *http.Request) (*database.Retainer, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid retainer ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	found, err := pg.db.GetRetainer(ctx, id)
+	if err != nil {
+		writeServerError(w, "Failed to get retainer", err)
+		return nil, false
+	}
+	if found == nil {
+		http.Error(w, "Retainer not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return found, true
+}
+
+// runRetainers periodically opens new retainer periods and checks whether
+// client-funded periods have been deposited
+func (pg *PaymentGateway) runRetainers(ctx context.Context) {
+	ticker := time.NewTicker(pg.config.RetainerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pg.processDueRetainers(ctx)
+			pg.checkUnconfirmedPeriods(ctx)
+			pg.markWorkerRun("retainers", pg.config.RetainerPollInterval)
+		}
+	}
+}
+
+func (pg *PaymentGateway) processDueRetainers(ctx context.Context) {
+	if pg.maintenance.Load() != nil || pg.signingHalt.Load() != nil {
+		return
+	}
+
+	due, err := pg.db.ListDueRetainers(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to list due retainers: %v", err)
+		return
+	}
+
+	for _, r := range due {
+		if !r.NextPeriodAt.Before(r.EndAt) {
+			if err := pg.db.SetRetainerStatus(ctx, r.ID, database.RetainerStatusEnded); err != nil {
+				log.Printf("Failed to end retainer %d: %v", r.ID, err)
+				continue
+			}
+			log.Printf("Retainer %d ended after %d periods", r.ID, r.PeriodsCreated)
+			r.Status = database.RetainerStatusEnded
+			pg.notify(events.RetainerEnded, retainerEvent(r))
+			continue
+		}
+
+		next := retainer.PeriodStart(r.StartAt, r.Interval, r.PeriodsCreated+1)
+		period, err := pg.db.StartRetainerPeriod(ctx, r, r.NextPeriodAt, next)
+		if err != nil {
+			log.Printf("Failed to start period for retainer %d: %v", r.ID, err)
+			continue
+		}
+		if period == nil {
+			continue
+		}
+
+		if r.Mode == retainer.ModePrompt {
+			pg.quoteRetainerPeriod(ctx, period)
+			pg.notify(events.RetainerPeriodDue, retainerPeriodEvent(period, pg.explorer))
+			continue
+		}
+
+		pg.fundRetainerPeriod(ctx, r, period)
+	}
+}
+
+// fundRetainerPeriod deposits a period's escrow from the gateway wallet
+func (pg *PaymentGateway) fundRetainerPeriod(ctx context.Context, r *database.Retainer, period *database.RetainerPeriod) {
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, r.ApplicationID)
+	if err != nil || details.ApplicantWalletAddress == nil || details.PosterWalletAddress == nil {
+		pg.updatePeriod(ctx, period, database.PeriodStatusFailed, "", nil, fmt.Sprintf("application wallets unavailable: %v", err))
+		pg.notify(events.RetainerPeriodFailed, retainerPeriodEvent(period, pg.explorer))
+		return
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	jobID := retainer.EscrowJobID(period.ID)
+	freelancer := common.HexToAddress(*details.ApplicantWalletAddress)
+	client := common.HexToAddress(*details.PosterWalletAddress)
+	opCtx = pg.trackBroadcasts(opCtx, r.ApplicationID, opRetainerFund, jobID)
+	result, err := pg.client.PostJob(opCtx, jobID, freelancer, big.NewInt(int64(period.USDAmount)), client)
+	if err != nil {
+		var pending *payment.TransactionPendingError
+		if errors.As(err, &pending) {
+			pg.updatePeriod(ctx, period, database.PeriodStatusFunding, "deposit", &result.TxHash, pending.Error())
+			return
+		}
+		pg.updatePeriod(ctx, period, database.PeriodStatusFailed, "", nil, payment.ClassifyError(err).Error())
+		pg.notify(events.RetainerPeriodFailed, retainerPeriodEvent(period, pg.explorer))
+		return
+	}
+
+	pg.recordGasCost(ctx, r.ApplicationID, opRetainerFund, result)
+	pg.updatePeriod(ctx, period, database.PeriodStatusFunded, "deposit", &result.TxHash, "")
+	log.Printf("Funded period %d of retainer %d as escrow job %d", period.PeriodNumber, r.ID, jobID)
+	pg.notify(events.RetainerPeriodFunded, retainerPeriodEvent(period, pg.explorer))
+}
+
+// quoteRetainerPeriod asks the client to fund a period, pinning the wei they
+// must send at the current ETH/USD price. Without a price the period is still
+// due but its deposit is accepted unchecked.
+func (pg *PaymentGateway) quoteRetainerPeriod(ctx context.Context, period *database.RetainerPeriod) {
+	price, err := pg.oracle.GetETHUSDPrice(ctx)
+	if err != nil {
+		log.Printf("Failed to quote retainer period %d, deposit will not be checked: %v", period.ID, err)
+		pg.updatePeriod(ctx, period, database.PeriodStatusAwaitingClient, "", nil, "")
+		return
+	}
+
+	priceStr := price.String()
+	required := amounts.EscrowWei(big.NewInt(int64(period.USDAmount)), price).String()
+	if err := pg.db.QuoteRetainerPeriod(ctx, period.ID, priceStr, required); err != nil {
+		log.Printf("Failed to quote retainer period %d: %v", period.ID, err)
+		return
+	}
+
+	period.Status = database.PeriodStatusAwaitingClient
+	period.QuotedETHUSDPrice = &priceStr
+	period.RequiredWei = &required
+	period.LastError = nil
+}
+
+// checkClientDeposit compares a client's deposit with the period's quote. An
+// underfunded period records the top-up still owed; an overfunded one is
+// funded and records the excess for a dust refund.
+func (pg *PaymentGateway) checkClientDeposit(ctx context.Context, period *database.RetainerPeriod) {
+	required, ok := new(big.Int).SetString(derefString(period.RequiredWei), 10)
+	if !ok {
+		pg.updatePeriod(ctx, period, database.PeriodStatusFunded, "", nil, "")
+		pg.notify(events.RetainerPeriodFunded, retainerPeriodEvent(period, pg.explorer))
+		return
+	}
+
+	deposit, err := pg.client.GetJobDeposit(ctx, retainer.EscrowJobID(period.ID))
+	if err != nil {
+		log.Printf("Failed to get deposit for retainer period %d: %v", period.ID, err)
+		return
+	}
+	if deposit == nil {
+		log.Printf("No deposit transaction found for retainer period %d since block %d", period.ID, pg.config.ContractDeployBlock)
+		return
+	}
+
+	check := payment.CheckFunding(deposit.Value, required)
+	status, eventType := database.PeriodStatusFunded, events.RetainerPeriodFunded
+	var topUp, excess *string
+	switch check.Status {
+	case payment.FundingUnderfunded:
+		status, eventType = database.PeriodStatusUnderfunded, events.RetainerPeriodUnderfunded
+		owed := check.TopUp.String()
+		topUp = &owed
+	case payment.FundingOverfunded:
+		extra := check.Excess.String()
+		excess = &extra
+	}
+
+	deposited := deposit.Value.String()
+	if err := pg.db.RecordRetainerPeriodDeposit(ctx, period.ID, status, deposit.TxHash, deposited, topUp, excess); err != nil {
+		log.Printf("Failed to record deposit for retainer period %d: %v", period.ID, err)
+		return
+	}
+
+	period.Status = status
+	period.TxHashDeposit = &deposit.TxHash
+	period.DepositedWei = &deposited
+	period.TopUpWei = topUp
+	period.OverfundedWei = excess
+	period.LastError = nil
+
+	if check.Status != payment.FundingExact {
+		log.Printf("Retainer period %d deposit is %s: sent %s wei, quoted %s wei", period.ID, check.Status, deposited, required)
+	}
+	pg.notify(eventType, retainerPeriodEvent(period, pg.explorer))
+}
+
+// checkUnconfirmedPeriods marks periods funded once their escrow job exists on-chain,
+// covering both client-funded periods and deposits that were pending at submission
+func (pg *PaymentGateway) checkUnconfirmedPeriods(ctx context.Context) {
+	periods, err := pg.db.ListRetainerPeriodsByStatus(ctx, database.PeriodStatusAwaitingClient, database.PeriodStatusFunding)
+	if err != nil {
+		log.Printf("Failed to list unfunded retainer periods: %v", err)
+		return
+	}
+
+	for _, period := range periods {
+		job, err := pg.client.GetJobDetails(ctx, retainer.EscrowJobID(period.ID))
+		if err != nil {
+			log.Printf("Failed to get escrow job for retainer period %d: %v", period.ID, err)
+			continue
+		}
+		if job.Client == (common.Address{}) {
+			continue
+		}
+
+		if period.Status == database.PeriodStatusAwaitingClient {
+			pg.checkClientDeposit(ctx, period)
+			continue
+		}
+
+		pg.updatePeriod(ctx, period, database.PeriodStatusFunded, "", nil, "")
+		pg.notify(events.RetainerPeriodFunded, retainerPeriodEvent(period, pg.explorer))
+	}
+}
+
+// updatePeriod stores a period's new state and mirrors it on the in-memory copy
+func (pg *PaymentGateway) updatePeriod(ctx context.Context, period *database.RetainerPeriod, status, txType string, txHash *string, errMsg string) {
+	var lastError *string
+	if errMsg != "" {
+		lastError = &errMsg
+	}
+
+	if err := pg.db.UpdateRetainerPeriod(ctx, period.ID, status, txType, txHash, lastError); err != nil {
+		log.Printf("Failed to update retainer period %d: %v", period.ID, err)
+		return
+	}
+
+	period.Status = status
+	period.LastError = lastError
+	if txType == "deposit" {
+		period.TxHashDeposit = txHash
+	}
+}
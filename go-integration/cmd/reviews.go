@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/velocity"
+)
+
+// ReviewResponse is an operation held for manual review
+type ReviewResponse struct {
+	ReviewID         int64                    `json:"review_id"`
+	ApplicationID    int32                    `json:"application_id"`
+	Operation        string                   `json:"operation"`
+	Params           database.OperationParams `json:"params"`
+	PreviousStatus   paymentstatus.Status     `json:"previous_status,omitempty"` // restored when the review is resolved
+	Rule             string                   `json:"rule"`
+	Detail           string                   `json:"detail"`
+	Status           string                   `json:"status"`
+	ResolvedBy       string                   `json:"resolved_by,omitempty"`
+	ResolutionReason string                   `json:"resolution_reason,omitempty"`
+	ResolvedAt       *time.Time               `json:"resolved_at,omitempty"`
+	CreatedAt        time.Time                `json:"created_at"`
+}
+
+// ReviewDecisionRequest is the optional body of an approval or rejection
+type ReviewDecisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReviewDecisionResponse is a resolved review and, for an approval, the
+// transaction that submitted the held operation
+type ReviewDecisionResponse struct {
+	Review      ReviewResponse       `json:"review"`
+	Transaction *TransactionResponse `json:"transaction,omitempty"`
+}
+
+func newReviewResponse(review *database.Review) ReviewResponse {
+	response := ReviewResponse{
+		ReviewID:       review.ID,
+		ApplicationID:  review.ApplicationID,
+		Operation:      review.Operation,
+		Params:         review.Params,
+		PreviousStatus: review.PreviousStatus,
+		Rule:           review.Rule,
+		Detail:         review.Detail,
+		Status:         review.Status,
+		ResolvedAt:     review.ResolvedAt,
+		CreatedAt:      review.CreatedAt,
+	}
+	if review.ResolvedBy != nil {
+		response.ResolvedBy = *review.ResolvedBy
+	}
+	if review.ResolutionReason != nil {
+		response.ResolutionReason = *review.ResolutionReason
+	}
+	return response
+}
+
+// holdForReview queues an operation a velocity rule flagged instead of
+// submitting it, and writes 202 with the review
+func (pg *PaymentGateway) holdForReview(ctx context.Context, w http.ResponseWriter, review *database.Review, violations []velocity.Violation) {
+	created, err := pg.createReview(ctx, review, violations)
+	if errors.Is(err, database.ErrStatusConflict) {
+		http.Error(w, "Payment status changed while holding the operation for review", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeServerError(w, "Failed to hold operation for review", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newReviewResponse(created))
+}
+
+// createReview queues an operation for review under the rules of violations
+func (pg *PaymentGateway) createReview(ctx context.Context, review *database.Review, violations []velocity.Violation) (*database.Review, error) {
+	rules := make([]string, 0, len(violations))
+	details := make([]string, 0, len(violations))
+	for _, v := range violations {
+		rules = append(rules, string(v.Rule))
+		details = append(details, v.Detail)
+	}
+	review.Rule = strings.Join(rules, ",")
+	review.Detail = strings.Join(details, "; ")
+
+	created, err := pg.db.CreateReview(ctx, review)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Held %s for application %d in review %d: %s", created.Operation, created.ApplicationID, created.ID, created.Detail)
+	return created, nil
+}
+
+// GET /admin/reviews?status=open&sort=-created_at&limit=100 - Operations held by velocity rules
+func (pg *PaymentGateway) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := database.ReviewListSpec.Parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !params.Filters("status") {
+		params.Conditions = append(params.Conditions, database.ReviewListSpec.Where("status", listquery.Eq, database.ReviewStatusOpen))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	reviews, next, err := pg.db.FindReviews(ctx, params)
+	if err != nil {
+		writeServerError(w, "Failed to list reviews", err)
+		return
+	}
+
+	response := make([]ReviewResponse, 0, len(reviews))
+	for _, review := range reviews {
+		response = append(response, newReviewResponse(review))
+	}
+
+	writeListPage(w, response, next)
+}
+
+// POST /admin/reviews/{id}/approve - Restore the held payment and submit its operation
+func (pg *PaymentGateway) approveReviewHandler(w http.ResponseWriter, r *http.Request) {
+	pg.resolveReview(w, r, database.ReviewStatusApproved)
+}
+
+// POST /admin/reviews/{id}/reject - Restore the held payment without submitting
+func (pg *PaymentGateway) rejectReviewHandler(w http.ResponseWriter, r *http.Request) {
+	pg.resolveReview(w, r, database.ReviewStatusRejected)
+}
+
+// resolveReview records an admin's decision, returning the application to the
+// status it was held from. An approved escrow is then funded and an approved
+// refund submitted; a rejected refund leaves the escrow deposited, and a
+// rejected escrow or top-up is never funded.
+func (pg *PaymentGateway) resolveReview(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid review ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ReviewDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	review, err := pg.db.GetReview(ctx, id)
+	if err != nil {
+		writeServerError(w, "Failed to get review", err)
+		return
+	}
+	if review == nil {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+
+	review, err = pg.db.ResolveReview(ctx, id, status, actor, req.Reason)
+	switch {
+	case errors.Is(err, database.ErrReviewResolved):
+		http.Error(w, "Review is already resolved", http.StatusConflict)
+		return
+	case errors.Is(err, database.ErrStatusConflict):
+		http.Error(w, "Payment is no longer held for this review", http.StatusConflict)
+		return
+	case err != nil:
+		writeServerError(w, "Failed to resolve review", err)
+		return
+	}
+	log.Printf("Review %d of %s for application %d %s by %s", review.ID, review.Operation, review.ApplicationID, review.Status, actor)
+
+	response := ReviewDecisionResponse{Review: newReviewResponse(review)}
+	if status == database.ReviewStatusRejected {
+		if review.Operation == opTopUpFund {
+			pg.rejectTopUp(ctx, review)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if review.Operation == opTopUpFund {
+		result, ok := pg.approveTopUp(ctx, w, review)
+		if !ok {
+			return
+		}
+		response.Transaction = pg.newTransactionResponse(result)
+	} else {
+		result, err := pg.submitOperation(ctx, review.ApplicationID, review.Operation, review.Params)
+		if err != nil {
+			if pg.queueIfRetryable(ctx, w, review.ApplicationID, review.Operation, review.Params, err) {
+				return
+			}
+			pg.writeChainError(w, fmt.Sprintf("Review approved but %s failed", review.Operation), result, err)
+			return
+		}
+		response.Transaction = pg.newTransactionResponse(result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// findTopUp returns the held top-up of a review
+func (pg *PaymentGateway) findTopUp(ctx context.Context, review *database.Review) (*database.TopUp, error) {
+	topUps, err := pg.db.ListTopUps(ctx, review.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, topUp := range topUps {
+		if topUp.ID == review.Params.TopUpID {
+			return topUp, nil
+		}
+	}
+	return nil, fmt.Errorf("top-up %d not found", review.Params.TopUpID)
+}
+
+// approveTopUp funds a held top-up if its job's escrow is still deposited
+func (pg *PaymentGateway) approveTopUp(ctx context.Context, w http.ResponseWriter, review *database.Review) (*payment.TransactionResult, bool) {
+	topUp, err := pg.findTopUp(ctx, review)
+	if err != nil {
+		writeServerError(w, "Failed to get top-up", err)
+		return nil, false
+	}
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, review.ApplicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return nil, false
+	}
+	if details.PaymentStatus != paymentstatus.Deposited {
+		pg.updateTopUp(ctx, topUp, database.TopUpStatusFailed, "", nil, fmt.Sprintf("job was %s when the review was approved", details.PaymentStatus))
+		http.Error(w, fmt.Sprintf("Review approved but the escrow can no longer be topped up: payment status is '%s'", details.PaymentStatus), http.StatusConflict)
+		return nil, false
+	}
+
+	return pg.fundTopUp(ctx, w, details, topUp)
+}
+
+// rejectTopUp marks a held top-up as never funded
+func (pg *PaymentGateway) rejectTopUp(ctx context.Context, review *database.Review) {
+	topUp, err := pg.findTopUp(ctx, review)
+	if err != nil {
+		log.Printf("Warning: Failed to get top-up of review %d: %v", review.ID, err)
+		return
+	}
+	reason := "rejected in review"
+	if review.ResolutionReason != nil && *review.ResolutionReason != "" {
+		reason += ": " + *review.ResolutionReason
+	}
+	pg.updateTopUp(ctx, topUp, database.TopUpStatusFailed, "", nil, reason)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runRPCProbes probes the RPC providers every RPC_PROBE_INTERVAL. It returns
+// at once without ETHEREUM_RPC_FALLBACK_URLS or with a zero interval, leaving
+// a failed provider to be retried by live requests once its backoff ends.
+func (pg *PaymentGateway) runRPCProbes(ctx context.Context) {
+	if pg.config.RPCProbeInterval <= 0 || len(pg.client.RPCProviders()) < 2 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.RPCProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		pg.client.ProbeRPCProviders(probeCtx)
+		cancel()
+		pg.markWorkerRun("rpc_probe", pg.config.RPCProbeInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/trace"
+)
+
+// httpServer is the API server with how it listens: plain HTTP, TLS from a
+// certificate file, or TLS with certificates obtained through ACME
+type httpServer struct {
+	*http.Server
+	certFile, keyFile string
+	challenge         *http.Server // answers ACME HTTP-01 challenges; nil without autocert
+	tcpKeepAlive      time.Duration
+	connections       *connTracker
+}
+
+// newHTTPServer wraps handler in a server with the SERVER_* timeouts,
+// protocols and keep-alives, MAX_REQUEST_BODY_BYTES, and the TLS_* listener
+// settings. Mutating requests are traced, and connections are counted.
+func newHTTPServer(cfg *config.Config, handler http.Handler) (*httpServer, error) {
+	connections := newConnTracker()
+	server := &httpServer{
+		Server: &http.Server{
+			Addr:              ":" + cfg.ServerPort,
+			Handler:           connections.countRequests(limitRequestBodies(traceMutations(handler), cfg.MaxRequestBodyBytes)),
+			ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+			ReadTimeout:       cfg.ServerReadTimeout,
+			WriteTimeout:      cfg.ServerWriteTimeout,
+			IdleTimeout:       cfg.ServerIdleTimeout,
+			ConnState:         connections.track,
+			Protocols:         new(http.Protocols),
+			HTTP2:             &http.HTTP2Config{MaxConcurrentStreams: cfg.ServerHTTP2MaxStreams},
+		},
+		certFile:     cfg.TLSCertFile,
+		keyFile:      cfg.TLSKeyFile,
+		tcpKeepAlive: cfg.ServerTCPKeepAlive,
+		connections:  connections,
+	}
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetHTTP2(cfg.ServerHTTP2)
+	server.Protocols.SetUnencryptedHTTP2(cfg.ServerH2C)
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSAutocertDomains == "" {
+		return server, nil
+	}
+	if cfg.TLSCertFile != "" {
+		return nil, fmt.Errorf("TLS_AUTOCERT_DOMAINS cannot be combined with TLS_CERT_FILE")
+	}
+
+	var domains []string
+	for _, domain := range strings.Split(cfg.TLSAutocertDomains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cfg.TLSAutocertCacheDir),
+		Email:      cfg.TLSAutocertEmail,
+	}
+	server.TLSConfig = manager.TLSConfig()
+	server.TLSConfig.MinVersion = tls.VersionTLS12
+	if cfg.TLSAutocertHTTPAddr != "" {
+		// Redirects everything that isn't a challenge to HTTPS
+		server.challenge = &http.Server{
+			Addr:              cfg.TLSAutocertHTTPAddr,
+			Handler:           manager.HTTPHandler(nil),
+			ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+			ReadTimeout:       cfg.ServerReadTimeout,
+			WriteTimeout:      cfg.ServerWriteTimeout,
+			IdleTimeout:       cfg.ServerIdleTimeout,
+		}
+	}
+	return server, nil
+}
+
+// shutdown stops accepting connections, closes idle ones and waits for
+// requests in progress until ctx ends
+func (s *httpServer) shutdown(ctx context.Context) error {
+	if s.challenge != nil {
+		s.challenge.Shutdown(ctx)
+	}
+	return s.Shutdown(ctx)
+}
+
+// tls reports whether the server listens with TLS
+func (s *httpServer) tls() bool {
+	return s.certFile != "" || s.TLSConfig != nil
+}
+
+// serve listens until the server fails or is shut down
+func (s *httpServer) serve() error {
+	if s.challenge != nil {
+		go func() {
+			if err := s.challenge.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("ACME challenge listener on %s stopped: %v", s.challenge.Addr, err)
+			}
+		}()
+	}
+
+	// A negative SERVER_TCP_KEEPALIVE turns probes off, as net.ListenConfig does
+	listenConfig := net.ListenConfig{KeepAlive: s.tcpKeepAlive}
+	listener, err := listenConfig.Listen(context.Background(), "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if !s.tls() {
+		return s.Serve(listener)
+	}
+	if s.TLSConfig == nil {
+		s.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if !s.Protocols.HTTP2() {
+		// autocert offers h2, which the server would then fail to speak
+		s.TLSConfig.NextProtos = slices.DeleteFunc(slices.Clone(s.TLSConfig.NextProtos), func(proto string) bool { return proto == "h2" })
+	}
+	// Certificates come from TLSConfig under autocert, so the files are then empty
+	return s.ServeTLS(listener, s.certFile, s.keyFile)
+}
+
+// ConnectionStats counts the API server's client connections. Requests per
+// connection near 1 means clients reconnect for every poll.
+type ConnectionStats struct {
+	Open                  int     `json:"open"`
+	Active                int     `json:"active"` // serving a request
+	Idle                  int     `json:"idle"`   // kept alive, waiting for the next request
+	Accepted              uint64  `json:"accepted"`
+	Closed                uint64  `json:"closed"`
+	Requests              uint64  `json:"requests"`
+	HTTP2Requests         uint64  `json:"http2_requests"`
+	RequestsPerConnection float64 `json:"requests_per_connection"`
+}
+
+// connTracker follows connections through http.Server.ConnState
+type connTracker struct {
+	mu       sync.Mutex
+	states   map[net.Conn]http.ConnState
+	accepted uint64
+	closed   uint64
+
+	requests      atomic.Uint64
+	http2Requests atomic.Uint64
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{states: make(map[net.Conn]http.ConnState)}
+}
+
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		t.accepted++
+		t.states[conn] = state
+	case http.StateHijacked, http.StateClosed:
+		if _, ok := t.states[conn]; ok {
+			delete(t.states, conn)
+			t.closed++
+		}
+	default:
+		t.states[conn] = state
+	}
+}
+
+func (t *connTracker) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.requests.Add(1)
+		if r.ProtoMajor == 2 {
+			t.http2Requests.Add(1)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats returns the connection counts since the server started
+func (t *connTracker) Stats() ConnectionStats {
+	t.mu.Lock()
+	stats := ConnectionStats{Open: len(t.states), Accepted: t.accepted, Closed: t.closed}
+	for _, state := range t.states {
+		switch state {
+		case http.StateActive:
+			stats.Active++
+		case http.StateIdle:
+			stats.Idle++
+		}
+	}
+	t.mu.Unlock()
+
+	stats.Requests = t.requests.Load()
+	stats.HTTP2Requests = t.http2Requests.Load()
+	if stats.Accepted > 0 {
+		stats.RequestsPerConnection = float64(stats.Requests) / float64(stats.Accepted)
+	}
+	return stats
+}
+
+// limitRequestBodies rejects bodies larger than limit bytes with 413 when
+// the request declares its length, and cuts off reads past limit otherwise.
+// 0 disables the limit.
+func limitRequestBodies(next http.Handler, limit int64) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			http.Error(w, fmt.Sprintf("Request body is larger than %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceMutations gives every request that can change state a trace ID,
+// taken from the X-Trace-ID header when valid, returns it in the same header
+// and logs the request's outcome under it
+func traceMutations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id := r.Header.Get(trace.Header)
+		if !trace.Valid(id) {
+			id = trace.NewID()
+		}
+		w.Header().Set(trace.Header, id)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(trace.WithID(r.Context(), id)))
+		log.Printf("%s%s %s answered %d in %s", tracePrefix(id), r.Method, r.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// tracePrefix starts a log line about work done for a traced request
+func tracePrefix(traceID string) string {
+	if traceID == "" {
+		return ""
+	}
+	return "[trace " + traceID + "] "
+}
+
+// statusRecorder remembers the status code a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// describe summarises how the server listens, for the startup log
+func (s *httpServer) describe() string {
+	scheme := "HTTP"
+	switch {
+	case s.certFile != "":
+		scheme = "HTTPS with " + s.certFile
+	case s.TLSConfig != nil:
+		scheme = "HTTPS with ACME certificates"
+	}
+	protocols := "HTTP/1.1"
+	if s.tls() && s.Protocols.HTTP2() || s.Protocols.UnencryptedHTTP2() {
+		protocols += fmt.Sprintf(" and HTTP/2 (%d streams)", s.HTTP2.MaxConcurrentStreams)
+	}
+	return fmt.Sprintf("%s on %s, %s (read %s, write %s, idle %s, TCP keep-alive %s)", scheme, s.Addr, protocols,
+		durationOrNone(s.ReadTimeout), durationOrNone(s.WriteTimeout), durationOrNone(s.IdleTimeout), durationOrNone(s.tcpKeepAlive))
+}
+
+func durationOrNone(d time.Duration) string {
+	if d <= 0 {
+		return "none"
+	}
+	return d.String()
+}
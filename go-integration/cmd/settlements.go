@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/format"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/webhook"
+)
+
+// maxSettlementBackfillDays bounds how many missed days one run summarises,
+// so a gateway that was down for long catches up on the latest month only
+const maxSettlementBackfillDays = 31
+
+// SettlementSummaryResponse is the money that moved through the gateway on
+// one UTC day
+type SettlementSummaryResponse struct {
+	Day                   string    `json:"day"`
+	DepositCount          int64     `json:"deposit_count"`
+	DepositUSD            int64     `json:"deposit_usd"`
+	DepositUSDDisplay     string    `json:"deposit_usd_display"`
+	ReleaseCount          int64     `json:"release_count"`
+	ReleaseUSD            int64     `json:"release_usd"`
+	ReleaseUSDDisplay     string    `json:"release_usd_display"`
+	RefundCount           int64     `json:"refund_count"`
+	RefundUSD             int64     `json:"refund_usd"`
+	RefundUSDDisplay      string    `json:"refund_usd_display"`
+	FeeWei                string    `json:"fee_wei"`
+	FeeETHDisplay         string    `json:"fee_eth_display"`
+	ReserveWei            string    `json:"reserve_wei"`      // the part of fee_wei set aside in the reserve fund
+	ReservePaidWei        string    `json:"reserve_paid_wei"` // reserve payouts to users
+	GasTransactions       int64     `json:"gas_transactions"`
+	GasWei                string    `json:"gas_wei"`
+	GasETHDisplay         string    `json:"gas_eth_display"`
+	GasUSD                string    `json:"gas_usd"`
+	GasUSDDisplay         string    `json:"gas_usd_display"`
+	NetEscrowUSD          int64     `json:"net_escrow_usd"` // deposits less releases and refunds
+	NetEscrowUSDDisplay   string    `json:"net_escrow_usd_display"`
+	NetTreasuryWei        string    `json:"net_treasury_wei"` // fees less reserve payouts and gas
+	NetTreasuryETHDisplay string    `json:"net_treasury_eth_display"`
+	SummarizedAt          time.Time `json:"summarized_at"`
+}
+
+type SettlementReportResponse struct {
+	From      string                      `json:"from"`
+	To        string                      `json:"to"`
+	Summaries []SettlementSummaryResponse `json:"summaries"`
+}
+
+func settlementSummaryResponse(l format.Locale, s *database.SettlementSummary) SettlementSummaryResponse {
+	return SettlementSummaryResponse{
+		Day:                   s.Day.Format(time.DateOnly),
+		DepositCount:          s.DepositCount,
+		DepositUSD:            s.DepositUSD,
+		DepositUSDDisplay:     l.USDInt(s.DepositUSD),
+		ReleaseCount:          s.ReleaseCount,
+		ReleaseUSD:            s.ReleaseUSD,
+		ReleaseUSDDisplay:     l.USDInt(s.ReleaseUSD),
+		RefundCount:           s.RefundCount,
+		RefundUSD:             s.RefundUSD,
+		RefundUSDDisplay:      l.USDInt(s.RefundUSD),
+		FeeWei:                s.FeeWei,
+		FeeETHDisplay:         displayWei(l, s.FeeWei),
+		ReserveWei:            s.ReserveWei,
+		ReservePaidWei:        s.ReservePaidWei,
+		GasTransactions:       s.GasTransactions,
+		GasWei:                s.GasWei,
+		GasETHDisplay:         displayWei(l, s.GasWei),
+		GasUSD:                s.GasUSD,
+		GasUSDDisplay:         displayUSD(l, s.GasUSD),
+		NetEscrowUSD:          s.NetEscrowUSD,
+		NetEscrowUSDDisplay:   l.USDInt(s.NetEscrowUSD),
+		NetTreasuryWei:        s.NetTreasuryWei,
+		NetTreasuryETHDisplay: displayWei(l, s.NetTreasuryWei),
+		SummarizedAt:          s.CreatedAt,
+	}
+}
+
+// settlementMessage renders a summary as a Slack message
+func settlementMessage(s *database.SettlementSummary) string {
+	l := format.DefaultLocale
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Settlement summary for %s*\n", s.Day.Format(time.DateOnly))
+	fmt.Fprintf(&b, "Deposits: %d (%s)\n", s.DepositCount, l.USDInt(s.DepositUSD))
+	fmt.Fprintf(&b, "Releases: %d (%s)\n", s.ReleaseCount, l.USDInt(s.ReleaseUSD))
+	fmt.Fprintf(&b, "Refunds: %d (%s)\n", s.RefundCount, l.USDInt(s.RefundUSD))
+	fmt.Fprintf(&b, "Fees: %s, %s to the reserve\n", displayWei(l, s.FeeWei), displayWei(l, s.ReserveWei))
+	fmt.Fprintf(&b, "Reserve payouts: %s\n", displayWei(l, s.ReservePaidWei))
+	fmt.Fprintf(&b, "Gas: %d transactions, %s (%s)\n", s.GasTransactions, displayWei(l, s.GasWei), displayUSD(l, s.GasUSD))
+	fmt.Fprintf(&b, "Net escrow movement: %s\n", l.USDInt(s.NetEscrowUSD))
+	fmt.Fprintf(&b, "Net treasury movement: %s", displayWei(l, s.NetTreasuryWei))
+	return b.String()
+}
+
+// utcDay returns midnight UTC of the day t falls on
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// runSettlementSummaries summarises each UTC day once it is over, checking
+// every SETTLEMENT_SUMMARY_INTERVAL
+func (pg *PaymentGateway) runSettlementSummaries(ctx context.Context) {
+	if pg.config.SettlementSummaryInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pg.config.SettlementSummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		pg.summarizeSettlements(ctx, time.Now())
+		pg.markWorkerRun("settlement_summaries", pg.config.SettlementSummaryInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// summarizeSettlements builds the summary of every finished day since the
+// latest one stored, or of yesterday on the first run, and publishes each.
+// It returns the summaries it built.
+func (pg *PaymentGateway) summarizeSettlements(ctx context.Context, now time.Time) []*database.SettlementSummary {
+	yesterday := utcDay(now).AddDate(0, 0, -1)
+
+	latest, err := pg.db.LatestSettlementDay(ctx)
+	if err != nil {
+		log.Printf("Failed to get latest settlement summary: %v", err)
+		return nil
+	}
+	day := yesterday
+	if latest != nil {
+		day = utcDay(*latest).AddDate(0, 0, 1)
+	}
+	if earliest := yesterday.AddDate(0, 0, 1-maxSettlementBackfillDays); day.Before(earliest) {
+		log.Printf("Settlement summaries are missing before %s; rebuild them with POST /admin/settlements/{day}", earliest.Format(time.DateOnly))
+		day = earliest
+	}
+
+	var built []*database.SettlementSummary
+	for ; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		summary, err := pg.db.BuildSettlementSummary(ctx, day)
+		if err != nil {
+			log.Printf("Failed to summarise settlements for %s: %v", day.Format(time.DateOnly), err)
+			break
+		}
+		log.Printf("Settlement summary for %s: %d deposits, %d releases, %d refunds, net escrow %d USD, net treasury %s wei",
+			day.Format(time.DateOnly), summary.DepositCount, summary.ReleaseCount, summary.RefundCount, summary.NetEscrowUSD, summary.NetTreasuryWei)
+		pg.publishSettlementSummary(ctx, summary)
+		built = append(built, summary)
+	}
+	return built
+}
+
+// publishSettlementSummary sends a settlement.summarized webhook and posts
+// the summary to SETTLEMENT_SLACK_WEBHOOK_URL, if set
+func (pg *PaymentGateway) publishSettlementSummary(ctx context.Context, s *database.SettlementSummary) {
+	pg.notify(events.SettlementSummarized, events.Settlement{
+		Day:             s.Day.Format(time.DateOnly),
+		DepositCount:    s.DepositCount,
+		DepositUSD:      s.DepositUSD,
+		ReleaseCount:    s.ReleaseCount,
+		ReleaseUSD:      s.ReleaseUSD,
+		RefundCount:     s.RefundCount,
+		RefundUSD:       s.RefundUSD,
+		FeeWei:          s.FeeWei,
+		ReserveWei:      s.ReserveWei,
+		ReservePaidWei:  s.ReservePaidWei,
+		GasTransactions: s.GasTransactions,
+		GasWei:          s.GasWei,
+		GasUSD:          s.GasUSD,
+		NetEscrowUSD:    s.NetEscrowUSD,
+		NetTreasuryWei:  s.NetTreasuryWei,
+	})
+
+	slack := webhook.NewSlack(pg.config.SettlementSlackWebhookURL)
+	if !slack.Enabled() {
+		return
+	}
+	slackCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if err := slack.Post(slackCtx, settlementMessage(s)); err != nil {
+		log.Printf("Warning: Failed to post settlement summary for %s to Slack: %v", s.Day.Format(time.DateOnly), err)
+	}
+}
+
+// GET /reports/settlements?from=YYYY-MM-DD&to=YYYY-MM-DD - Stored daily settlement summaries
+func (pg *PaymentGateway) settlementReportHandler(w http.ResponseWriter, r *http.Request) {
+	reportRange, err := listquery.ParseRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	summaries, err := pg.db.ListSettlementSummaries(ctx, reportRange.From, reportRange.To)
+	if err != nil {
+		writeServerError(w, "Failed to list settlement summaries", err)
+		return
+	}
+	locale := localeFor(r)
+
+	response := SettlementReportResponse{
+		From:      reportRange.From.Format(time.DateOnly),
+		To:        reportRange.Last().Format(time.DateOnly),
+		Summaries: []SettlementSummaryResponse{},
+	}
+	for _, summary := range summaries {
+		response.Summaries = append(response.Summaries, settlementSummaryResponse(locale, summary))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GET /reports/settlements/{day} - One day's settlement summary
+func (pg *PaymentGateway) getSettlementSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	day, err := time.Parse(time.DateOnly, r.PathValue("day"))
+	if err != nil {
+		http.Error(w, "Invalid day, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	summary, err := pg.db.GetSettlementSummary(ctx, day)
+	if err != nil {
+		writeServerError(w, "Failed to get settlement summary", err)
+		return
+	}
+	if summary == nil {
+		http.Error(w, "Day has not been summarised", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlementSummaryResponse(localeFor(r), summary))
+}
+
+// POST /admin/settlements/{day} - Rebuild a finished day's settlement summary
+func (pg *PaymentGateway) rebuildSettlementSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	day, err := time.Parse(time.DateOnly, r.PathValue("day"))
+	if err != nil {
+		http.Error(w, "Invalid day, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if !day.Before(utcDay(time.Now())) {
+		http.Error(w, "Day is not over yet", http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	summary, err := pg.db.BuildSettlementSummary(ctx, day)
+	if err != nil {
+		writeServerError(w, "Failed to build settlement summary", err)
+		return
+	}
+	log.Printf("Rebuilt settlement summary for %s", day.Format(time.DateOnly))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlementSummaryResponse(localeFor(r), summary))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/replay"
+)
+
+// maxSignedBodyBytes bounds the body read to verify a signed request
+const maxSignedBodyBytes = 1 << 20
+
+// requireSignedRequest wraps an externally callable confirmation endpoint so
+// that, when REQUEST_SIGNING_SECRET is set, only signed requests with a fresh
+// timestamp and an unused nonce reach it. Without the secret it is a no-op.
+func (pg *PaymentGateway) requireSignedRequest(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if pg.replay == nil {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodyBytes))
+		if err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		err = pg.replay.Check(
+			r.Header.Get(replay.TimestampHeader),
+			r.Header.Get(replay.NonceHeader),
+			r.Header.Get(replay.SignatureHeader),
+			r.Method+" "+r.URL.RequestURI(),
+			body,
+		)
+		switch {
+		case errors.Is(err, replay.ErrReplayed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/jobid"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/statustoken"
+)
+
+type StatusTokenResponse struct {
+	Token     string    `json:"token"`
+	Path      string    `json:"path"` // public status URL path with the token
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PublicJobStatusResponse is the read-only view behind a status link. It
+// leaves out wallet addresses, actors and costs, which the link's recipient
+// has no need for.
+type PublicJobStatusResponse struct {
+	JobID         uint64                `json:"job_id"`
+	USDAmount     string                `json:"usd_amount,omitempty"`
+	PaymentStatus paymentstatus.Status  `json:"payment_status"`
+	TxHashDeposit string                `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease string                `json:"tx_hash_release,omitempty"`
+	TxHashRefund  string                `json:"tx_hash_refund,omitempty"`
+	TxURLDeposit  string                `json:"tx_url_deposit,omitempty"`
+	TxURLRelease  string                `json:"tx_url_release,omitempty"`
+	TxURLRefund   string                `json:"tx_url_refund,omitempty"`
+	Timeline      []PublicTimelineEntry `json:"timeline"`
+	ExpiresAt     time.Time             `json:"expires_at"`
+}
+
+type PublicTimelineEntry struct {
+	Status    string    `json:"status"`
+	TxHash    string    `json:"tx_hash,omitempty"`
+	TxURL     string    `json:"tx_url,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// POST /jobs/{id}/status-token?ttl=1h - Issue a signed link to a job's status page
+func (pg *PaymentGateway) createStatusTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if pg.statusTokens == nil {
+		http.Error(w, "Status links are disabled: set STATUS_TOKEN_SECRET", http.StatusNotFound)
+		return
+	}
+	if !pg.requireFeature(w, r, features.PublicStatusLinks) {
+		return
+	}
+
+	jobID, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	// A caller may ask for a shorter lifetime than STATUS_TOKEN_TTL, never a longer one
+	ttl := pg.config.StatusTokenTTL
+	if value := r.URL.Query().Get("ttl"); value != "" {
+		requested, err := time.ParseDuration(value)
+		if err != nil || requested <= 0 {
+			http.Error(w, "Invalid ttl", http.StatusBadRequest)
+			return
+		}
+		ttl = min(requested, ttl)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	token := pg.statusTokens.Sign(jobID, expiresAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StatusTokenResponse{
+		Token:     token,
+		Path:      "/public/job-status?token=" + url.QueryEscape(token),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// GET /public/job-status?token=X - Read-only payment status for the job a token was issued for
+func (pg *PaymentGateway) publicJobStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if pg.statusTokens == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	// The token is a credential, so keep responses out of shared caches
+	w.Header().Set("Cache-Control", "no-store")
+
+	jobID, expiresAt, err := pg.statusTokens.Verify(r.URL.Query().Get("token"), time.Now())
+	if errors.Is(err, statustoken.ErrExpired) {
+		http.Error(w, "Status link has expired", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid status link", http.StatusUnauthorized)
+		return
+	}
+	applicationID, err := jobid.ApplicationID(jobID)
+	if err != nil {
+		http.Error(w, "Invalid status link", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Whoever opens the link is not the platform, so the flag is checked for
+	// the tenant the job's escrow was posted for
+	tenant, err := pg.db.GetEscrowTenant(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get escrow tenant", err)
+		return
+	}
+	if !pg.requireTenantFeature(w, r, features.PublicStatusLinks, tenant) {
+		return
+	}
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+
+	events, err := pg.db.GetPaymentEvents(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get payment events", err)
+		return
+	}
+
+	response := PublicJobStatusResponse{
+		JobID:         jobID,
+		PaymentStatus: details.PaymentStatus,
+		TxHashDeposit: derefString(details.EscrowTxHashDeposit),
+		TxHashRelease: derefString(details.EscrowTxHashRelease),
+		TxHashRefund:  derefString(details.EscrowTxHashRefund),
+		TxURLDeposit:  pg.explorer.Tx(derefString(details.EscrowTxHashDeposit)),
+		TxURLRelease:  pg.explorer.Tx(derefString(details.EscrowTxHashRelease)),
+		TxURLRefund:   pg.explorer.Tx(derefString(details.EscrowTxHashRefund)),
+		Timeline:      make([]PublicTimelineEntry, 0, len(events)),
+		ExpiresAt:     expiresAt,
+	}
+	if details.AgreedUSDAmount != nil {
+		response.USDAmount = strconv.Itoa(int(*details.AgreedUSDAmount))
+	}
+	for _, event := range events {
+		response.Timeline = append(response.Timeline, PublicTimelineEntry{
+			Status:    event.Status,
+			TxHash:    derefString(event.TxHash),
+			TxURL:     pg.explorer.Tx(derefString(event.TxHash)),
+			Timestamp: event.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
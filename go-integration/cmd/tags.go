@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/tags"
+)
+
+// JobTagsResponse lists the tags on a payment record
+type JobTagsResponse struct {
+	ApplicationID int32    `json:"application_id"`
+	Tags          []string `json:"tags"`
+}
+
+// SetJobTagsRequest replaces a payment record's tags
+type SetJobTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// parseTagFilter reads the ?tag= filter of list, export and report endpoints
+func parseTagFilter(r *http.Request) ([]string, error) {
+	filter, err := tags.ParseFilter(r.URL.Query()["tag"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag filter: %v", err)
+	}
+	return filter, nil
+}
+
+// GET /jobs/{id}/tags - A payment record's tags
+func (pg *PaymentGateway) getJobTagsHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	jobTags, err := pg.db.GetJobTags(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get job tags", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JobTagsResponse{ApplicationID: applicationID, Tags: jobTags})
+}
+
+// PUT /jobs/{id}/tags - Replace a payment record's tags
+func (pg *PaymentGateway) setJobTagsHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req SetJobTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	jobTags, err := tags.NormalizeAll(req.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID); err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	pg.writeSetJobTags(ctx, w, r, applicationID, jobTags)
+}
+
+// DELETE /jobs/{id}/tags/{tag} - Remove one tag from a payment record
+func (pg *PaymentGateway) deleteJobTagHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	tag, err := tags.Normalize(r.PathValue("tag"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	current, err := pg.db.GetJobTags(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get job tags", err)
+		return
+	}
+	remaining := make([]string, 0, len(current))
+	for _, t := range current {
+		if t != tag {
+			remaining = append(remaining, t)
+		}
+	}
+	if len(remaining) == len(current) {
+		http.Error(w, fmt.Sprintf("Job is not tagged %q", tag), http.StatusNotFound)
+		return
+	}
+	pg.writeSetJobTags(ctx, w, r, applicationID, remaining)
+}
+
+func (pg *PaymentGateway) writeSetJobTags(ctx context.Context, w http.ResponseWriter, r *http.Request, applicationID int32, jobTags []string) {
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+	if err := pg.db.SetJobTags(ctx, applicationID, jobTags, actor); err != nil {
+		writeServerError(w, "Failed to set job tags", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JobTagsResponse{ApplicationID: applicationID, Tags: jobTags})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// TaxPayoutResponse is one release to a freelancer
+type TaxPayoutResponse struct {
+	ApplicationID int32     `json:"application_id"`
+	JobID         int32     `json:"job_id"`
+	Kind          string    `json:"kind"` // "job", "top_up" or "retainer_period"
+	USDAmount     int64     `json:"usd_amount"`
+	TxHash        string    `json:"tx_hash,omitempty"`
+	TxURL         string    `json:"tx_url,omitempty"`
+	ReleasedAt    time.Time `json:"released_at"`
+}
+
+// FreelancerTaxSummaryResponse is what one freelancer was paid in the year
+type FreelancerTaxSummaryResponse struct {
+	UserID          int32               `json:"user_id"`
+	WalletAddress   *string             `json:"wallet_address"`
+	TotalUSD        int64               `json:"total_usd"`
+	TotalUSDDisplay string              `json:"total_usd_display"`
+	ContractCount   int64               `json:"contract_count"`
+	PayoutCount     int                 `json:"payout_count"`
+	Payouts         []TaxPayoutResponse `json:"payouts"`
+}
+
+// TaxExportResponse is the yearly per-freelancer export tax documents are
+// generated from
+type TaxExportResponse struct {
+	Year        int                            `json:"year"`
+	Tags        []string                       `json:"tags,omitempty"` // only jobs carrying all of these
+	TotalUSD    int64                          `json:"total_usd"`
+	Freelancers []FreelancerTaxSummaryResponse `json:"freelancers"`
+	GeneratedAt time.Time                      `json:"generated_at"`
+}
+
+// taxExportCSVHeader are the columns of ?format=csv, one row per freelancer
+var taxExportCSVHeader = []string{"year", "user_id", "wallet_address", "total_usd", "contract_count", "payout_count", "tx_hashes"}
+
+// parseTaxYear reads the {year} path value, which must be a year the gateway
+// could have paid out in
+func parseTaxYear(r *http.Request) (int, error) {
+	year, err := strconv.Atoi(r.PathValue("year"))
+	if err != nil || year < 2000 || year > time.Now().UTC().Year() {
+		return 0, fmt.Errorf("invalid year, expected YYYY no later than this year")
+	}
+	return year, nil
+}
+
+// GET /admin/tax-exports/{year}?format=json|csv&tag=&kind[in]=&reason= - Payouts per freelancer in a year
+func (pg *PaymentGateway) taxExportHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := parseTaxYear(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pg.writeTaxExport(w, r, year, 0)
+}
+
+// GET /admin/tax-exports/{year}/freelancers/{user_id}?format=json|csv&tag=&kind[in]=&reason= - One freelancer's payouts in a year
+func (pg *PaymentGateway) freelancerTaxExportHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := parseTaxYear(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 32)
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return
+	}
+	pg.writeTaxExport(w, r, year, int32(userID))
+}
+
+// writeTaxExport builds the export and records who read it in the audit log
+// before sending it. An export that can't be audited isn't sent.
+func (pg *PaymentGateway) writeTaxExport(w http.ResponseWriter, r *http.Request, year int, userID int32) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "Invalid format, expected json or csv", http.StatusBadRequest)
+		return
+	}
+	filters, err := database.TaxPayoutSpec.ParseFilters(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tagFilter, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	summaries, err := pg.db.ListFreelancerTaxSummaries(ctx, year, userID, filters, tagFilter)
+	if err != nil {
+		writeServerError(w, "Failed to build tax export", err)
+		return
+	}
+	if userID != 0 && len(summaries) == 0 {
+		http.Error(w, fmt.Sprintf("No payouts to freelancer %d in %d", userID, year), http.StatusNotFound)
+		return
+	}
+
+	locale := localeFor(r)
+	response := TaxExportResponse{Year: year, Tags: tagFilter, Freelancers: []FreelancerTaxSummaryResponse{}, GeneratedAt: time.Now()}
+	for _, summary := range summaries {
+		freelancer := FreelancerTaxSummaryResponse{
+			UserID:          summary.UserID,
+			WalletAddress:   summary.WalletAddress,
+			TotalUSD:        summary.TotalUSD,
+			TotalUSDDisplay: locale.USDInt(summary.TotalUSD),
+			ContractCount:   summary.ContractCount,
+			PayoutCount:     len(summary.Payouts),
+			Payouts:         make([]TaxPayoutResponse, 0, len(summary.Payouts)),
+		}
+		for _, payout := range summary.Payouts {
+			entry := TaxPayoutResponse{
+				ApplicationID: payout.ApplicationID,
+				JobID:         payout.JobID,
+				Kind:          payout.Kind,
+				USDAmount:     payout.USDAmount,
+				TxHash:        payout.TxHash,
+				ReleasedAt:    payout.ReleasedAt,
+			}
+			if payout.TxHash != "" {
+				entry.TxURL = pg.explorer.Tx(payout.TxHash)
+			}
+			freelancer.Payouts = append(freelancer.Payouts, entry)
+		}
+		response.TotalUSD += summary.TotalUSD
+		response.Freelancers = append(response.Freelancers, freelancer)
+	}
+
+	exported, _ := json.Marshal(map[string]interface{}{
+		"year":        year,
+		"user_id":     userID,
+		"format":      format,
+		"tags":        tagFilter,
+		"freelancers": len(response.Freelancers),
+		"total_usd":   response.TotalUSD,
+	})
+	entry := database.AuditEntry{
+		Action: "tax_export.read",
+		Actor:  adminKeyName(r.Context()),
+		Reason: r.URL.Query().Get("reason"),
+		After:  exported,
+	}
+	if err := pg.db.RecordAudit(ctx, entry); err != nil {
+		writeServerError(w, "Failed to audit tax export", err)
+		return
+	}
+	log.Printf("Tax export for %d read with admin key %s (%d freelancers)", year, entry.Actor, len(response.Freelancers))
+
+	if format == "csv" {
+		writeTaxExportCSV(w, response)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeTaxExportCSV writes one row per freelancer with their transaction
+// hashes separated by semicolons
+func writeTaxExportCSV(w http.ResponseWriter, response TaxExportResponse) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tax-export-%d.csv"`, response.Year))
+
+	out := csv.NewWriter(w)
+	out.Write(taxExportCSVHeader)
+	for _, freelancer := range response.Freelancers {
+		wallet := ""
+		if freelancer.WalletAddress != nil {
+			wallet = *freelancer.WalletAddress
+		}
+		var hashes []string
+		for _, payout := range freelancer.Payouts {
+			if payout.TxHash != "" {
+				hashes = append(hashes, payout.TxHash)
+			}
+		}
+		out.Write([]string{
+			strconv.Itoa(response.Year),
+			strconv.Itoa(int(freelancer.UserID)),
+			wallet,
+			strconv.FormatInt(freelancer.TotalUSD, 10),
+			strconv.FormatInt(freelancer.ContractCount, 10),
+			strconv.Itoa(freelancer.PayoutCount),
+			strings.Join(hashes, ";"),
+		})
+	}
+	out.Flush()
+}
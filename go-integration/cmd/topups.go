@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/explorer"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Chain operations on escrow top-ups, recorded separately in gas reports
+const (
+	opTopUpFund    = "top_up_fund"
+	opTopUpRelease = "top_up_release"
+	opTopUpRefund  = "top_up_refund"
+)
+
+type TopUpRequest struct {
+	USDAmount int32  `json:"usd_amount"` // added to the escrow
+	Reason    string `json:"reason"`     // e.g. the scope change that was agreed
+}
+
+// TopUpResponse describes one top-up and its escrow job
+type TopUpResponse struct {
+	TopUpID       int64     `json:"top_up_id"`
+	EscrowJobID   uint64    `json:"escrow_job_id"`
+	USDAmount     int32     `json:"usd_amount"`
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason,omitempty"`
+	Actor         string    `json:"actor"`
+	TxHashDeposit string    `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease string    `json:"tx_hash_release,omitempty"`
+	TxHashRefund  string    `json:"tx_hash_refund,omitempty"`
+	TxURLDeposit  string    `json:"tx_url_deposit,omitempty"`
+	TxURLRelease  string    `json:"tx_url_release,omitempty"`
+	TxURLRefund   string    `json:"tx_url_refund,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TopUpsResponse is a job's escrow across its original deposit and every top-up
+type TopUpsResponse struct {
+	JobID           uint64          `json:"job_id"`
+	AgreedUSD       int32           `json:"agreed_usd"`
+	TopUpUSD        int64           `json:"top_up_usd"`
+	EscrowedUSD     int64           `json:"escrowed_usd"`
+	DepositTxHashes []string        `json:"deposit_tx_hashes"`
+	TopUps          []TopUpResponse `json:"top_ups"`
+}
+
+func newTopUpResponse(topUp *database.TopUp, links explorer.Links) TopUpResponse {
+	response := TopUpResponse{
+		TopUpID:     topUp.ID,
+		EscrowJobID: payment.TopUpJobID(topUp.ID),
+		USDAmount:   topUp.USDAmount,
+		Status:      topUp.Status,
+		Reason:      topUp.Reason,
+		Actor:       topUp.Actor,
+		CreatedAt:   topUp.CreatedAt,
+	}
+	if topUp.TxHashDeposit != nil {
+		response.TxHashDeposit = *topUp.TxHashDeposit
+		response.TxURLDeposit = links.Tx(*topUp.TxHashDeposit)
+	}
+	if topUp.TxHashRelease != nil {
+		response.TxHashRelease = *topUp.TxHashRelease
+		response.TxURLRelease = links.Tx(*topUp.TxHashRelease)
+	}
+	if topUp.TxHashRefund != nil {
+		response.TxHashRefund = *topUp.TxHashRefund
+		response.TxURLRefund = links.Tx(*topUp.TxHashRefund)
+	}
+	if topUp.LastError != nil {
+		response.Error = *topUp.LastError
+	}
+	return response
+}
+
+// newTopUpsResponse totals what has been deposited for a job. The original
+// deposit counts once it has a transaction, a top-up once its deposit was
+// broadcast; later refunds don't reduce the cumulative amount.
+func newTopUpsResponse(details *database.ApplicationPaymentDetails, topUps []*database.TopUp, links explorer.Links) TopUpsResponse {
+	response := TopUpsResponse{
+		JobID:           uint64(details.ApplicationID),
+		DepositTxHashes: []string{},
+		TopUps:          make([]TopUpResponse, 0, len(topUps)),
+	}
+	if details.AgreedUSDAmount != nil {
+		response.AgreedUSD = *details.AgreedUSDAmount
+	}
+	if details.EscrowTxHashDeposit != nil {
+		response.EscrowedUSD = int64(response.AgreedUSD)
+		response.DepositTxHashes = append(response.DepositTxHashes, *details.EscrowTxHashDeposit)
+	}
+
+	for _, topUp := range topUps {
+		response.TopUps = append(response.TopUps, newTopUpResponse(topUp, links))
+		if topUp.Status == database.TopUpStatusPending || topUp.Status == database.TopUpStatusFailed {
+			continue
+		}
+		response.TopUpUSD += int64(topUp.USDAmount)
+		if topUp.TxHashDeposit != nil {
+			response.DepositTxHashes = append(response.DepositTxHashes, *topUp.TxHashDeposit)
+		}
+	}
+	response.EscrowedUSD += response.TopUpUSD
+	return response
+}
+
+// POST /jobs/{id}/top-up - Add to a funded escrow after a scope increase
+func (pg *PaymentGateway) topUpJobHandler(w http.ResponseWriter, r *http.Request) {
+	if !pg.requireFeature(w, r, features.TopUps) {
+		return
+	}
+
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req TopUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.USDAmount <= 0 {
+		http.Error(w, "Invalid USD amount", http.StatusBadRequest)
+		return
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" {
+		actor = "api"
+	}
+
+	// Detached from the request so a client disconnect cannot abandon a broadcast transaction
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+	// Only a funded escrow that hasn't been settled can grow
+	if details.PaymentStatus != paymentstatus.Deposited {
+		http.Error(w, fmt.Sprintf("Cannot top up escrow: payment status is '%s', expected 'deposited'", details.PaymentStatus), http.StatusConflict)
+		return
+	}
+	if details.ApplicantWalletAddress == nil || details.PosterWalletAddress == nil {
+		http.Error(w, "Cannot top up escrow: application wallets are not set", http.StatusBadRequest)
+		return
+	}
+
+	violations, ok := pg.escrowViolations(ctx, w, details, int64(req.USDAmount), 0)
+	if !ok || pg.rejectBlocked(w, applicationID, opTopUpFund, violations) {
+		return
+	}
+	consensus, ok := pg.consensusViolations(ctx, w, int64(req.USDAmount))
+	if !ok {
+		return
+	}
+	violations = append(violations, consensus...)
+
+	topUp, err := pg.db.CreateTopUp(ctx, applicationID, req.USDAmount, req.Reason, actor)
+	if err != nil {
+		writeServerError(w, "Failed to create top-up", err)
+		return
+	}
+
+	if len(violations) > 0 {
+		pg.updateTopUp(ctx, topUp, database.TopUpStatusReview, "", nil, "")
+		pg.holdForReview(ctx, w, &database.Review{
+			ApplicationID: applicationID,
+			Operation:     opTopUpFund,
+			Params: database.OperationParams{
+				JobID:     payment.TopUpJobID(topUp.ID),
+				USDAmount: strconv.Itoa(int(req.USDAmount)),
+				TopUpID:   topUp.ID,
+			},
+		}, violations)
+		return
+	}
+
+	if _, ok := pg.fundTopUp(ctx, w, details, topUp); !ok {
+		return
+	}
+	pg.writeTopUps(ctx, w, details, http.StatusCreated)
+}
+
+// fundTopUp deposits a top-up as its own escrow job, writing the chain error
+// and returning false when it fails
+func (pg *PaymentGateway) fundTopUp(ctx context.Context, w http.ResponseWriter, details *database.ApplicationPaymentDetails, topUp *database.TopUp) (*payment.TransactionResult, bool) {
+	freelancer := common.HexToAddress(*details.ApplicantWalletAddress)
+	client := common.HexToAddress(*details.PosterWalletAddress)
+	var result *payment.TransactionResult
+	var err error
+	if poolErr := pg.submissions.Do(ctx, func() {
+		jobID := payment.TopUpJobID(topUp.ID)
+		result, err = pg.client.PostJob(pg.trackBroadcasts(ctx, details.ApplicationID, opTopUpFund, jobID), jobID, freelancer, big.NewInt(int64(topUp.USDAmount)), client)
+	}); poolErr != nil {
+		err = poolErr
+	}
+	if err != nil {
+		// Keep the hash of a broadcast transaction so it isn't resent
+		var pending *payment.TransactionPendingError
+		if errors.As(err, &pending) {
+			pg.updateTopUp(ctx, topUp, database.TopUpStatusFunding, "deposit", &result.TxHash, pending.Error())
+		} else {
+			pg.updateTopUp(ctx, topUp, database.TopUpStatusFailed, "", nil, payment.ClassifyError(err).Error())
+		}
+		pg.writeChainError(w, "Failed to top up escrow", result, err)
+		return nil, false
+	}
+
+	pg.recordGasCost(ctx, details.ApplicationID, opTopUpFund, result)
+	pg.updateTopUp(ctx, topUp, database.TopUpStatusFunded, "deposit", &result.TxHash, "")
+	log.Printf("Topped up job %d by $%d as escrow job %d", details.ApplicationID, topUp.USDAmount, payment.TopUpJobID(topUp.ID))
+	return result, true
+}
+
+// GET /jobs/{id}/top-ups - Cumulative escrow and every top-up of a job
+func (pg *PaymentGateway) listTopUpsHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+
+	pg.writeTopUps(ctx, w, details, http.StatusOK)
+}
+
+// POST /jobs/{id}/top-ups/settle?reason=X - Retry releasing or refunding top-ups
+// the way the job itself was settled
+func (pg *PaymentGateway) settleTopUpsHandler(w http.ResponseWriter, r *http.Request) {
+	_, applicationID, ok := parseJobID(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	details, err := pg.db.GetApplicationPaymentDetails(ctx, applicationID)
+	if err != nil {
+		writeServerError(w, "Failed to get application details", err)
+		return
+	}
+
+	var txType string
+	switch details.PaymentStatus {
+	case paymentstatus.ReleaseInitiated, paymentstatus.Released:
+		txType = "release"
+	case paymentstatus.RefundInitiated, paymentstatus.Refunded:
+		txType = "refund"
+	default:
+		http.Error(w, fmt.Sprintf("Cannot settle top-ups: payment status is '%s', expected the job to be released or refunded", details.PaymentStatus), http.StatusConflict)
+		return
+	}
+
+	reason := payment.RefundReasonOther
+	if txType == "refund" {
+		if reason, err = payment.ParseRefundReason(r.URL.Query().Get("reason")); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid refund reason: expected one of %v", payment.RefundReasons), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := pg.settleTopUps(ctx, details.ApplicationID, txType, string(reason)); err != nil {
+		writeServerError(w, "Failed to list top-ups", err)
+		return
+	}
+
+	pg.writeTopUps(ctx, w, details, http.StatusOK)
+}
+
+// writeTopUps encodes a job's current top-ups
+func (pg *PaymentGateway) writeTopUps(ctx context.Context, w http.ResponseWriter, details *database.ApplicationPaymentDetails, status int) {
+	topUps, err := pg.db.ListTopUps(ctx, details.ApplicationID)
+	if err != nil {
+		writeServerError(w, "Failed to list top-ups", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newTopUpsResponse(details, topUps, pg.explorer))
+}
+
+// settleTopUps releases or refunds ("release" or "refund") every funded top-up
+// of a job whose own escrow was just settled the same way. Deposits still
+// awaiting their receipt are settled once the chain shows them. A top-up that
+// fails keeps its status and error so the settle endpoint can retry it.
+func (pg *PaymentGateway) settleTopUps(ctx context.Context, applicationID int32, txType, reason string) error {
+	topUps, err := pg.db.ListTopUps(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	for _, topUp := range topUps {
+		jobID := payment.TopUpJobID(topUp.ID)
+		if topUp.Status == database.TopUpStatusFunding {
+			job, err := pg.client.GetJobDetails(ctx, jobID)
+			if err != nil {
+				log.Printf("Failed to get escrow job for top-up %d: %v", topUp.ID, err)
+				continue
+			}
+			if job.Client == (common.Address{}) {
+				continue
+			}
+			pg.updateTopUp(ctx, topUp, database.TopUpStatusFunded, "", nil, "")
+		}
+		if topUp.Status != database.TopUpStatusFunded {
+			continue
+		}
+
+		var result *payment.TransactionResult
+		var operation, status string
+		if txType == "release" {
+			opCtx := pg.trackBroadcasts(ctx, applicationID, opTopUpRelease, jobID)
+			result, err = pg.client.MarkJobCompleted(payment.WithFeeStrategy(opCtx, pg.feeStrategy(opTopUpRelease, database.OperationParams{})), jobID)
+			operation, status = opTopUpRelease, database.TopUpStatusReleased
+		} else {
+			result, err = pg.client.CancelJob(pg.trackBroadcasts(ctx, applicationID, opTopUpRefund, jobID), jobID)
+			operation, status = opTopUpRefund, database.TopUpStatusRefunded
+		}
+		if err != nil {
+			var pending *payment.TransactionPendingError
+			if errors.As(err, &pending) {
+				pg.updateTopUp(ctx, topUp, topUp.Status, txType, &result.TxHash, pending.Error())
+			} else {
+				pg.updateTopUp(ctx, topUp, topUp.Status, "", nil, payment.ClassifyError(err).Error())
+			}
+			log.Printf("Failed to %s top-up %d of job %d: %v", txType, topUp.ID, applicationID, err)
+			continue
+		}
+
+		pg.updateTopUp(ctx, topUp, status, txType, &result.TxHash, "")
+		pg.recordGasCost(ctx, applicationID, operation, result)
+
+		if txType == "release" && result.Success {
+			pg.accrueReserve(ctx, applicationID, jobID, result.TxHash)
+		}
+
+		if txType == "refund" && result.Success {
+			if err := pg.db.RecordRefund(ctx, applicationID, reason, topUp.USDAmount, result.TxHash); err != nil {
+				log.Printf("Warning: Failed to record refund reason in database: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// updateTopUp stores a top-up's new state and mirrors it on the in-memory copy
+func (pg *PaymentGateway) updateTopUp(ctx context.Context, topUp *database.TopUp, status, txType string, txHash *string, errMsg string) {
+	var lastError *string
+	if errMsg != "" {
+		lastError = &errMsg
+	}
+
+	if err := pg.db.UpdateTopUp(ctx, topUp.ID, status, txType, txHash, lastError); err != nil {
+		log.Printf("Failed to update top-up %d: %v", topUp.ID, err)
+		return
+	}
+
+	topUp.Status = status
+	topUp.LastError = lastError
+	switch txType {
+	case "deposit":
+		topUp.TxHashDeposit = txHash
+	case "release":
+		topUp.TxHashRelease = txHash
+	case "refund":
+		topUp.TxHashRefund = txHash
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/velocity"
+)
+
+// VelocityViolationResponse is returned with 403 when a velocity limit blocks an operation
+type VelocityViolationResponse struct {
+	Error      string               `json:"error"`
+	Code       string               `json:"code"` // "velocity_limit"
+	Violations []velocity.Violation `json:"violations"`
+}
+
+// escrowViolations returns the velocity limits funding usdAmount of an
+// application's escrow would exceed, or false after writing an error.
+// excludeApplicationID keeps a job's own earlier deposit attempts out of its
+// client's daily total.
+func (pg *PaymentGateway) escrowViolations(ctx context.Context, w http.ResponseWriter, details *database.ApplicationPaymentDetails, usdAmount int64, excludeApplicationID int32) ([]velocity.Violation, bool) {
+	if len(pg.velocity) == 0 {
+		return nil, true
+	}
+
+	escrow := velocity.Escrow{USDAmount: usdAmount, NewWalletMaxAge: pg.config.VelocityNewWalletAge}
+	if _, ok := pg.velocity[velocity.ClientDailyUSD]; ok {
+		total, err := pg.db.GetClientEscrowUSD(ctx, details.PosterUserID, excludeApplicationID, time.Now().Add(-velocity.ClientWindow))
+		if err != nil {
+			writeServerError(w, "Failed to check velocity limits", err)
+			return nil, false
+		}
+		escrow.ClientDailyUSD = total
+	}
+	if _, ok := pg.velocity[velocity.NewWalletUSD]; ok {
+		for _, wallet := range []*string{details.PosterWalletAddress, details.ApplicantWalletAddress} {
+			if wallet == nil || !common.IsHexAddress(*wallet) {
+				continue
+			}
+			address := common.HexToAddress(*wallet).Hex()
+			entry, err := pg.db.GetAddress(ctx, address)
+			if err != nil {
+				writeServerError(w, "Failed to check velocity limits", err)
+				return nil, false
+			}
+			if entry == nil || time.Since(entry.FirstSeenAt) < pg.config.VelocityNewWalletAge {
+				escrow.NewWallets = append(escrow.NewWallets, address)
+			}
+		}
+	}
+
+	return pg.velocity.CheckEscrow(escrow), true
+}
+
+// refundViolations returns the velocity limits refunding an application
+// would exceed, or false after writing an error
+func (pg *PaymentGateway) refundViolations(ctx context.Context, w http.ResponseWriter, details *database.ApplicationPaymentDetails) ([]velocity.Violation, bool) {
+	violations, err := pg.checkRefundLimits(ctx, details)
+	if err != nil {
+		writeServerError(w, "Failed to check velocity limits", err)
+		return nil, false
+	}
+	return violations, true
+}
+
+// checkRefundLimits returns the velocity limits refunding an application
+// would exceed
+func (pg *PaymentGateway) checkRefundLimits(ctx context.Context, details *database.ApplicationPaymentDetails) ([]velocity.Violation, error) {
+	if _, ok := pg.velocity[velocity.FreelancerWeeklyRefunds]; !ok {
+		return nil, nil
+	}
+
+	count, err := pg.db.CountFreelancerRefunds(ctx, details.ApplicantUserID, time.Now().Add(-velocity.FreelancerWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.velocity.CheckRefund(velocity.Refund{FreelancerWeeklyRefunds: count}), nil
+}
+
+// rejectBlocked writes a 403 and returns true when any violation blocks the
+// operation. Violations that only flag it are left to the caller to hold.
+func (pg *PaymentGateway) rejectBlocked(w http.ResponseWriter, applicationID int32, operation string, violations []velocity.Violation) bool {
+	if !velocity.Blocked(violations) {
+		return false
+	}
+
+	log.Printf("Blocked %s for application %d: %+v", operation, applicationID, violations)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(VelocityViolationResponse{
+		Error:      "Operation exceeds velocity limits",
+		Code:       "velocity_limit",
+		Violations: violations,
+	})
+	return true
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Where a runway projection's gas figure comes from
+const (
+	runwayFromHistory  = "history"   // average gas of recorded transactions
+	runwayFromGasLimit = "gas_limit" // GAS_LIMIT, when nothing has been recorded yet
+)
+
+// WalletResponse is the signer wallet's balance and how many more operations it can pay gas for
+type WalletResponse struct {
+	Address             string         `json:"address"`
+	AddressURL          string         `json:"address_url,omitempty"`
+	BalanceWei          string         `json:"balance_wei"`
+	BalanceETHDisplay   string         `json:"balance_eth_display"`
+	GasPriceWei         string         `json:"gas_price_wei"`
+	AvgGasUsed          int64          `json:"avg_gas_used"`
+	CostPerOperationWei string         `json:"cost_per_operation_wei"`
+	RemainingOperations int64          `json:"remaining_operations"`
+	LowBalance          bool           `json:"low_balance"` // fewer than WALLET_LOW_RUNWAY operations left
+	EstimatedFrom       string         `json:"estimated_from"`
+	Since               time.Time      `json:"since"`
+	Operations          []WalletRunway `json:"operations"`
+}
+
+// WalletRunway projects the remaining balance onto one operation type
+type WalletRunway struct {
+	Operation           string `json:"operation"`
+	Transactions        int64  `json:"transactions"`
+	AvgGasUsed          int64  `json:"avg_gas_used"`
+	CostWei             string `json:"cost_wei"` // at the current gas price
+	RemainingOperations int64  `json:"remaining_operations"`
+}
+
+// GET /admin/wallet?days=30 - Signer balance and projected operations left
+func (pg *PaymentGateway) getWalletHandler(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 365 {
+			http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	address := pg.client.Address()
+	balance, err := pg.client.GetBalance(ctx, address)
+	if err != nil {
+		writeServerError(w, "Failed to get signer balance", err)
+		return
+	}
+	gasPrice, err := pg.client.SuggestGasPrice(ctx)
+	if err != nil {
+		writeServerError(w, "Failed to get gas price", err)
+		return
+	}
+	averages, err := pg.db.GetOperationGasAverages(ctx, since)
+	if err != nil {
+		writeServerError(w, "Failed to get gas averages", err)
+		return
+	}
+
+	response := WalletResponse{
+		Address:           address.Hex(),
+		AddressURL:        pg.explorer.Address(address.Hex()),
+		BalanceWei:        balance.String(),
+		BalanceETHDisplay: localeFor(r).ETH(balance),
+		GasPriceWei:       gasPrice.String(),
+		EstimatedFrom:     runwayFromHistory,
+		Since:             since,
+		Operations:        make([]WalletRunway, 0, len(averages)),
+	}
+
+	var transactions, totalGas int64
+	for _, average := range averages {
+		cost, remaining := runway(balance, gasPrice, average.AvgGasUsed)
+		response.Operations = append(response.Operations, WalletRunway{
+			Operation:           average.Operation,
+			Transactions:        average.Transactions,
+			AvgGasUsed:          average.AvgGasUsed,
+			CostWei:             cost.String(),
+			RemainingOperations: remaining,
+		})
+		transactions += average.Transactions
+		totalGas += average.Transactions * average.AvgGasUsed
+	}
+
+	// Nothing recorded yet, so assume every operation uses its full gas limit
+	if transactions == 0 {
+		response.AvgGasUsed = int64(pg.config.GasLimit)
+		response.EstimatedFrom = runwayFromGasLimit
+	} else {
+		response.AvgGasUsed = totalGas / transactions
+	}
+	cost, remaining := runway(balance, gasPrice, response.AvgGasUsed)
+	response.CostPerOperationWei = cost.String()
+	response.RemainingOperations = remaining
+	response.LowBalance = remaining < pg.config.WalletLowRunway
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// runway returns the cost of one operation using gasUsed at gasPrice and how
+// many of them balance covers. A zero cost covers none, so a missing average
+// never reports an infinite runway.
+func runway(balance, gasPrice *big.Int, gasUsed int64) (*big.Int, int64) {
+	cost := new(big.Int).Mul(gasPrice, big.NewInt(gasUsed))
+	if cost.Sign() <= 0 {
+		return cost, 0
+	}
+	remaining := new(big.Int).Quo(balance, cost)
+	if !remaining.IsInt64() {
+		return cost, math.MaxInt64
+	}
+	return cost, remaining.Int64()
+}
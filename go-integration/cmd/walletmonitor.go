@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+)
+
+// walletMonitorActor trips the kill switch when the monitor finds foreign transactions
+const walletMonitorActor = "wallet_monitor"
+
+// runWalletMonitor compares each signer's on-chain nonce with the
+// transactions the gateway signed every WALLET_MONITOR_INTERVAL, and picks up
+// kill switch changes made on other replicas
+func (pg *PaymentGateway) runWalletMonitor(ctx context.Context) {
+	if pg.config.WalletMonitorInterval <= 0 {
+		return
+	}
+
+	// The nonce each signer had at the previous check. Only nonces used
+	// before it are checked, so a transaction the gateway is still waiting
+	// on has a full interval to be recorded.
+	observed := make(map[common.Address]uint64)
+	pg.checkWalletActivity(ctx, observed)
+	pg.markWorkerRun("wallet_monitor", pg.config.WalletMonitorInterval)
+
+	ticker := time.NewTicker(pg.config.WalletMonitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := pg.refreshSigningHalt(ctx); err != nil {
+				log.Printf("Failed to refresh kill switch: %v", err)
+			}
+			pg.checkWalletActivity(ctx, observed)
+			pg.markWorkerRun("wallet_monitor", pg.config.WalletMonitorInterval)
+		}
+	}
+}
+
+// checkWalletActivity checks the hot signer and, when one is configured, the
+// admin signer
+func (pg *PaymentGateway) checkWalletActivity(ctx context.Context, observed map[common.Address]uint64) {
+	pg.checkSignerActivity(ctx, "hot", pg.client.Address(), observed)
+	if admin := pg.client.AdminAddress(); admin != (common.Address{}) {
+		pg.checkSignerActivity(ctx, "admin", admin, observed)
+	}
+}
+
+func (pg *PaymentGateway) checkSignerActivity(ctx context.Context, signer string, address common.Address, observed map[common.Address]uint64) {
+	current, err := pg.client.NonceAt(ctx, address)
+	if err != nil {
+		log.Printf("Warning: Failed to get nonce of %s signer %s: %v", signer, address.Hex(), err)
+		return
+	}
+	watermark, ok, err := pg.db.GetWalletWatermark(ctx, address.Hex())
+	if err != nil {
+		log.Printf("Warning: Failed to get wallet watermark of %s: %v", address.Hex(), err)
+		return
+	}
+
+	previous, seen := observed[address]
+	observed[address] = current
+	if !ok {
+		// Nothing before the first check is known to be the gateway's
+		if err := pg.db.SetWalletWatermark(ctx, address.Hex(), current); err != nil {
+			log.Printf("Warning: Failed to set wallet watermark of %s: %v", address.Hex(), err)
+			return
+		}
+		log.Printf("Monitoring %s signer %s from nonce %d", signer, address.Hex(), current)
+		return
+	}
+	if !seen {
+		return
+	}
+
+	upTo := min(previous, current)
+	if upTo <= watermark {
+		return
+	}
+	nonces, err := pg.db.ListUnsignedNonces(ctx, address.Hex(), watermark, upTo)
+	if err != nil {
+		log.Printf("Warning: Failed to check transactions of %s: %v", address.Hex(), err)
+		return
+	}
+	if len(nonces) > 0 {
+		pg.reportWalletAnomaly(ctx, signer, address, nonces, current)
+	}
+	if err := pg.db.SetWalletWatermark(ctx, address.Hex(), upTo); err != nil {
+		log.Printf("Warning: Failed to set wallet watermark of %s: %v", address.Hex(), err)
+	}
+}
+
+// reportWalletAnomaly alerts ops to transactions the gateway didn't sign and,
+// with WALLET_ANOMALY_KILL_SWITCH, halts signing until an admin re-arms it
+func (pg *PaymentGateway) reportWalletAnomaly(ctx context.Context, signer string, address common.Address, nonces []uint64, current uint64) {
+	reason := fmt.Sprintf("%d transactions from the %s signer %s were not sent by the gateway, starting at nonce %d", len(nonces), signer, address.Hex(), nonces[0])
+	log.Printf("ALERT: %s; its key may be compromised", reason)
+
+	payload := events.WalletAnomaly{
+		Address:         address.Hex(),
+		AddressURL:      pg.explorer.Address(address.Hex()),
+		Signer:          signer,
+		FirstNonce:      nonces[0],
+		UnexpectedCount: len(nonces),
+		OnChainNonce:    current,
+		DetectedAt:      time.Now(),
+	}
+	details, _ := json.Marshal(map[string]interface{}{"address": address.Hex(), "signer": signer, "nonces": nonces, "on_chain_nonce": current})
+	if err := pg.db.RecordAudit(ctx, database.AuditEntry{Action: "wallet.anomaly", Actor: walletMonitorActor, Reason: reason, After: details}); err != nil {
+		log.Printf("Failed to audit wallet anomaly: %v", err)
+	}
+
+	if pg.config.WalletAnomalyKillSwitch {
+		halt, _, err := pg.db.TripSigningHalt(ctx, reason, walletMonitorActor, details)
+		if err != nil {
+			log.Printf("Failed to trip kill switch: %v", err)
+		} else {
+			pg.setSigningHalt(halt)
+			payload.KillSwitchTripped = true
+		}
+	}
+	pg.notify(events.WalletAnomalyDetected, payload)
+}
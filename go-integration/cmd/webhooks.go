@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/webhook"
+)
+
+// WebhookTestRequest picks the sample event to send. With a job ID it goes to
+// the callback URL registered for that job instead of WEBHOOK_URL.
+type WebhookTestRequest struct {
+	Type  events.Type `json:"type"`
+	JobID string      `json:"job_id,omitempty"`
+}
+
+// WebhookTestResponse reports how the endpoint answered a test delivery
+type WebhookTestResponse struct {
+	URL          string           `json:"url"`
+	Event        *events.Envelope `json:"event"`
+	Delivered    bool             `json:"delivered"` // the endpoint answered 2xx
+	StatusCode   int              `json:"status_code,omitempty"`
+	ResponseBody string           `json:"response_body,omitempty"` // first 4 KiB
+	DurationMS   int64            `json:"duration_ms"`
+	Error        string           `json:"error,omitempty"` // why no response was received
+}
+
+// GET /webhooks/events - Every event type with its fields and an example
+func (pg *PaymentGateway) listWebhookEventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events.Catalog())
+}
+
+// POST /admin/webhooks/test - Send a sample event and report the response
+func (pg *PaymentGateway) testWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req WebhookTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	payload, ok := events.Sample(req.Type)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown event type %q; GET /webhooks/events lists them", req.Type), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	url := pg.config.WebhookURL
+	if req.JobID != "" {
+		_, applicationID, ok := parseJobID(w, req.JobID)
+		if !ok {
+			return
+		}
+		jobURL, err := pg.db.GetJobWebhook(ctx, applicationID)
+		if err != nil {
+			writeServerError(w, "Failed to get job webhook", err)
+			return
+		}
+		if jobURL == "" {
+			http.Error(w, "Job has no webhook URL", http.StatusUnprocessableEntity)
+			return
+		}
+		url = jobURL
+	}
+	if url == "" {
+		http.Error(w, "No webhook endpoint configured; set WEBHOOK_URL", http.StatusUnprocessableEntity)
+		return
+	}
+
+	event, err := events.New(req.Type, payload)
+	if err != nil {
+		writeServerError(w, "Failed to build event", err)
+		return
+	}
+
+	// Sent directly rather than through pg.notifier, so fault injection
+	// cannot fail a test delivery the endpoint never saw
+	response := WebhookTestResponse{URL: url, Event: event}
+	delivery, err := webhook.NewNotifier(url, pg.config.WebhookSecret).Test(ctx, url, event)
+	if err != nil {
+		log.Printf("Test %s webhook to %s failed: %v", req.Type, url, err)
+		response.Error = err.Error()
+	} else {
+		log.Printf("Test %s webhook to %s answered %d in %s", req.Type, url, delivery.StatusCode, delivery.Duration)
+		response.Delivered = delivery.OK()
+		response.StatusCode = delivery.StatusCode
+		response.ResponseBody = delivery.Body
+		response.DurationMS = delivery.Duration.Milliseconds()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,192 @@
+package contracts
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+var updateGas = flag.Bool("update-gas", false, "rewrite testdata/gas.golden.json with the measured gas usage")
+
+// gasTolerancePercent is how much gas may grow over the golden value before the test fails
+const gasTolerancePercent = 1
+
+var (
+	goldenGasPath = filepath.Join("testdata", "gas.golden.json")
+
+	// mockFeedAddress holds a stub Chainlink aggregator in the simulated chain
+	mockFeedAddress = common.HexToAddress("0x00000000000000000000000000000000000fee0d")
+)
+
+// mockFeedCode answers every call with latestRoundData-shaped output:
+// roundId 1, answer 3000e8 (ETH at $3000), startedAt 1, updatedAt 1, answeredInRound 1
+var mockFeedCode = common.FromHex(
+	"600160005264" + "45d964b800" + "602052" + // roundId, answer
+		"6001604052" + "6001606052" + "6001608052" + // startedAt, updatedAt, answeredInRound
+		"60a06000f3") // return 160 bytes
+
+// escrowHarness is an EthJobEscrow deployed on a simulated chain
+type escrowHarness struct {
+	t        *testing.T
+	backend  *simulated.Backend
+	auth     *bind.TransactOpts
+	contract *EthJobEscrow
+	gas      map[string]uint64
+}
+
+func newEscrowHarness(t *testing.T) *escrowHarness {
+	t.Helper()
+
+	bin, err := os.ReadFile(filepath.Join("testdata", "EthJobEscrow.bin"))
+	if err != nil {
+		t.Fatalf("Failed to read contract bytecode: %v", err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		owner:           {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))},
+		mockFeedAddress: {Code: mockFeedCode},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	chainID, err := backend.Client().ChainID(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get chain ID: %v", err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		t.Fatalf("Failed to create transactor: %v", err)
+	}
+
+	parsed, err := EthJobEscrowMetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("Failed to parse ABI: %v", err)
+	}
+
+	h := &escrowHarness{t: t, backend: backend, auth: auth, gas: map[string]uint64{}}
+
+	address, tx, _, err := bind.DeployContract(auth, *parsed, common.FromHex(strings.TrimSpace(string(bin))), backend.Client(), mockFeedAddress, owner)
+	if err != nil {
+		t.Fatalf("Failed to deploy contract: %v", err)
+	}
+	h.mine("deploy", tx)
+
+	h.contract, err = NewEthJobEscrow(address, backend.Client())
+	if err != nil {
+		t.Fatalf("Failed to bind contract: %v", err)
+	}
+	return h
+}
+
+// mine commits a block and records the gas the transaction used under name
+func (h *escrowHarness) mine(name string, tx *types.Transaction) {
+	h.t.Helper()
+	h.backend.Commit()
+
+	receipt, err := h.backend.Client().TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		h.t.Fatalf("Failed to get %s receipt: %v", name, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		h.t.Fatalf("%s reverted", name)
+	}
+	h.gas[name] = receipt.GasUsed
+}
+
+func (h *escrowHarness) postJob(name string, jobID int64, usdAmount int64) {
+	h.t.Helper()
+
+	value, err := h.contract.ConvertUsdToEth(&bind.CallOpts{}, big.NewInt(usdAmount))
+	if err != nil {
+		h.t.Fatalf("Failed to convert USD to ETH: %v", err)
+	}
+
+	opts := *h.auth
+	opts.Value = value
+	freelancer := common.HexToAddress("0x000000000000000000000000000000000000f00d")
+	tx, err := h.contract.PostJob(&opts, big.NewInt(jobID), freelancer, big.NewInt(usdAmount), h.auth.From)
+	if err != nil {
+		h.t.Fatalf("Failed to post job: %v", err)
+	}
+	h.mine(name, tx)
+}
+
+// TestGasRegression fails when deploying the escrow or any gateway operation
+// uses more gas than recorded in testdata/gas.golden.json. After an intended
+// change, run `go test ./contracts -run TestGasRegression -update-gas`.
+func TestGasRegression(t *testing.T) {
+	h := newEscrowHarness(t)
+
+	h.postJob("post_job", 1, 250)
+	tx, err := h.contract.MarkJobCompleted(h.auth, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	h.mine("complete_job", tx)
+
+	h.postJob("post_job_second", 2, 250)
+	tx, err = h.contract.CancelJob(h.auth, big.NewInt(2))
+	if err != nil {
+		t.Fatalf("Failed to cancel job: %v", err)
+	}
+	h.mine("cancel_job", tx)
+
+	if *updateGas {
+		body, err := json.MarshalIndent(h.gas, "", "  ")
+		if err != nil {
+			t.Fatalf("Failed to encode gas usage: %v", err)
+		}
+		if err := os.WriteFile(goldenGasPath, append(body, '\n'), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", goldenGasPath, err)
+		}
+	}
+
+	body, err := os.ReadFile(goldenGasPath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", goldenGasPath, err)
+	}
+	var golden map[string]uint64
+	if err := json.Unmarshal(body, &golden); err != nil {
+		t.Fatalf("Failed to parse %s: %v", goldenGasPath, err)
+	}
+
+	names := make([]string, 0, len(h.gas))
+	for name := range h.gas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		used := h.gas[name]
+		expected, ok := golden[name]
+		if !ok {
+			t.Errorf("No golden gas value for %s (used %d)", name, used)
+			continue
+		}
+
+		limit := expected * (100 + gasTolerancePercent) / 100
+		switch {
+		case used > limit:
+			t.Errorf("%s used %d gas, more than %d%% over the golden %d", name, used, gasTolerancePercent, expected)
+		case used < expected:
+			t.Logf("%s used %d gas, less than the golden %d; consider -update-gas", name, used, expected)
+		}
+	}
+}
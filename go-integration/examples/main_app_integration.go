@@ -123,7 +123,7 @@ func (as *ApplicationService) RespondToOffer(ctx context.Context, params Respond
 func (as *ApplicationService) PosterReviewWork(ctx context.Context, params PosterReviewWorkParams) error {
 	// ... existing code for database updates ...
 
-	if params.NewStatus == StatusWorkApproved && currentAppPaymentDetails.PaymentStatus.String == PaymentStatusDeposited {
+	if params.NewStatus == StatusWorkApproved && paymentstatus.Status(currentAppPaymentDetails.PaymentStatus.String) == paymentstatus.Deposited {
 		// NEW: Call payment gateway to release payment
 		paymentGateway := payment.NewPaymentGatewayService("http://localhost:8081")
 
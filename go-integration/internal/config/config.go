@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -13,14 +14,232 @@ type Config struct {
 	ContractAddress string
 	PrivateKey      string
 
+	ContractDeployBlock uint64 // first block scanned for contract events
+	ExplorerURLs        string // chainID=baseURL overrides for block explorer links, e.g. "8453=https://basescan.org"
+
+	// RPC provider failover
+	EthereumRPCFallbackURLs string        // comma-separated providers tried in order when ETHEREUM_RPC_URL fails; empty disables failover
+	RPCFailoverBackoff      time.Duration // how long a failing provider is skipped, doubled after each further failure
+	RPCFailoverMaxBackoff   time.Duration
+	RPCProbeInterval        time.Duration // how often every provider is probed, so traffic moves before it fails
+
+	// Upgradeable contract tracking
+	ExpectedImplementation string        // implementation an EIP-1967 proxy should delegate to; empty accepts any
+	ProxyCheckInterval     time.Duration // how often the implementation is re-read
+
+	// Signed runtime contract updates
+	ContractUpdateSigner string        // address that signs POST /admin/contract bodies; empty disables
+	ContractUpdateMaxAge time.Duration // how long a signed update stays acceptable
+
+	// Remote signer in place of PRIVATE_KEY
+	RemoteSignerURL        string        // Web3Signer or Clef endpoint; empty signs with PRIVATE_KEY
+	RemoteSignerAPI        string        // "web3signer" or "clef"
+	RemoteSignerAddress    string        // account to sign with; empty uses the signer's only account
+	RemoteSignerTimeout    time.Duration // per signing request, including any approval the signer's policy waits for
+	RemoteSignerCAFile     string        // CA the signer's TLS certificate is checked against; empty uses the system roots
+	RemoteSignerClientCert string        // client certificate for signers that require mutual TLS
+	RemoteSignerClientKey  string
+
+	// Hardware signer for privileged operations
+	AdminSigner            string // "" (hot key only) or "ledger"
+	LedgerDerivationPath   string
+	PrivilegedUSDThreshold int64 // jobs at or above this USD amount are signed by the admin signer
+
 	// Chainlink price feed addresses
 	ETHUSDPriceFeed string
+	OracleMaxAge    time.Duration // oldest acceptable price round
+
+	// Price feed heartbeat monitor
+	OracleHeartbeat        time.Duration // longest the feed may go without a new round
+	OracleDeviationPercent float64       // price move that must produce a new round
+	OracleHeartbeatGrace   time.Duration // allowance for rounds still being published
+	OracleCheckInterval    time.Duration
+	OracleFallbackFeed     string // ETH/USD feed market prices are read from while the primary stalls; empty disables
+
+	// Time-weighted average quotes
+	TWAPMinUSD int64 // smallest USD amount /quote prices with a TWAP on request
+	TWAPRounds int   // oracle rounds averaged when the request doesn't say
+
+	// Quote to execution price guard
+	PriceDeviationPercent float64 // largest ETH/USD move between quote and escrow submission; 0 disables
+
+	// Multi-source price consensus
+	OracleConsensusUSD              int64   // escrows at or above this USD amount need agreeing ETH/USD sources; 0 disables
+	OracleConsensusTolerancePercent float64 // how far a source may be from the contract's rate and still agree
+	OracleConsensusMinSources       int     // agreeing sources required, counting the contract's rate
+	OracleConsensusPriceURL         string  // JSON endpoint of an off-chain ETH/USD price checked besides ORACLE_FALLBACK_FEED
+	OracleConsensusPriceField       string  // dotted path to the price in its response
 
 	// Application settings
 	FeePercentage int
+	ReserveFeeBPS int64 // basis points of each platform fee set aside in the reserve fund; 0 disables
 	GasLimit      uint64
 	GasPrice      int64 // in Gwei
 
+	NonceResyncAfter time.Duration // how long nonces the node doesn't show yet are trusted before re-syncing from it
+
+	// Batch releases
+	ReleaseApprovedStatus string // applications.status that marks work approved for POST /release-batch
+	ReleaseBatchLimit     int    // most jobs one batch releases
+
+	// Release authorizations
+	ReleaseAuthorizationTTL time.Duration // how long an approved release may take to be confirmed; 0 disables expiry
+
+	// Signer wallet capacity
+	WalletLowRunway int64 // /admin/wallet flags low_balance below this many projected operations
+
+	// Gas price spike protection
+	MaxGasPrice          int64         // in Gwei, 0 disables the ceiling
+	DeferOnHighGas       bool          // queue operations instead of failing when gas exceeds the ceiling
+	DeferDeadline        time.Duration // how long a deferred operation may wait for gas to drop
+	DeferredPollInterval time.Duration
+
+	// EIP-1559 fee strategies: slow, standard or fast
+	FeeStrategy        string // transactions that don't ask for another
+	ReleaseFeeStrategy string // payouts to freelancers; empty uses FEE_STRATEGY
+
+	// Economical releases scheduled into cheap gas hours
+	BaseFeeSampleInterval time.Duration // how often the latest base fee is recorded; 0 disables economical releases
+	BaseFeeHistory        time.Duration // how far back cheap hours are learned from
+	EconomicalMaxDelay    time.Duration // longest an economical release waits for a cheaper hour
+
+	// Retry queue for transient RPC failures
+	RetryFailedOperations bool          // queue retryable failures instead of returning an error
+	MaxOperationAttempts  int           // attempts before a queued operation is failed
+	RetryBackoff          time.Duration // base delay, doubled after each failed attempt
+
+	// Transaction submission pool
+	SubmissionWorkers    int // chain operations submitted at once
+	SubmissionQueueDepth int // operations that may wait for a worker before requests get 503
+
+	// Urgent lane for operations sent with priority=urgent
+	UrgentSubmissionWorkers int    // workers of the urgent lane's own pool
+	UrgentGasPricePercent   int64  // of the suggested tip urgent transactions offer
+	UrgentMaxGasPrice       int64  // in Gwei, the urgent lane's ceiling; 0 keeps MAX_GAS_PRICE
+	UrgentFeeStrategy       string // fee strategy of urgent transactions that don't ask for another
+
+	// Network congestion warnings; a threshold of 0 ignores its signal
+	CongestionSampleInterval time.Duration // how often the network is checked; 0 disables the warnings
+	CongestionBaseFee        int64         // in Gwei, a base fee above which the network is congested
+	CongestionGasUsedPercent int64         // of the gas limit the latest block used above which it is congested
+	CongestionPendingTxs     int64         // transactions in the node's pending pool above which it is congested
+	CongestionInclusionTime  time.Duration // median time to inclusion of recent transactions above which it is congested
+
+	// Startup canary gating readiness
+	CanaryMode          string        // "estimate" signs and estimates a self-transfer, "transfer" also sends it, "off" skips the canary
+	CanaryRetryInterval time.Duration // wait between failed canaries
+
+	// Write-ahead intent log
+	IntentRecoveryGrace time.Duration // age at which an intent without an outcome is presumed lost to a crash; 0 disables recovery
+
+	// Receipt polling
+	StatusPolling         bool          // settle initiated transactions from polled receipts
+	PollMinInterval       time.Duration // interval while transactions are in flight
+	PollMaxInterval       time.Duration // interval the poller backs off to while idle
+	PollJitter            float64       // fraction of the interval to randomise, e.g. 0.2
+	RequiredConfirmations uint64        // blocks before an initiated transaction is final
+	ReorgWindow           uint64        // blocks behind the head a reorganisation may still rewrite
+	ReceiptBatchSize      int           // receipts per JSON-RPC batch request
+
+	// KYC gate on releases
+	KYCSource          string        // "platform", "provider" or empty to release without checking KYC
+	KYCThresholdUSD    int64         // releases above this wait for the freelancer to pass KYC
+	KYCPlatformColumn  string        // users column holding the status when KYCSource is platform
+	KYCProviderURL     string        // base URL the provider serves GET {url}/{user_id} under
+	KYCProviderAPIKey  string        // bearer token for the provider
+	KYCRecheckInterval time.Duration // how often held releases are checked again
+
+	// Escrow event indexing
+	IndexerPollInterval time.Duration // how often new escrow events are indexed; 0 disables
+	IndexerSafetyWindow uint64        // blocks below the checkpoint scanned again on restart and after a reorg
+	EventStatusUpdates  bool          // settle payment statuses from indexed escrow events
+
+	// Recurring retainers
+	RetainerPollInterval time.Duration
+
+	// Health history
+	HealthSnapshotInterval time.Duration // how often health is recorded; 0 disables
+	HealthHistoryRetention time.Duration // how long snapshots are kept
+	HealthSlowLatency      time.Duration // RPC or database round trip that counts as degraded
+
+	// Archival of settled jobs to object storage
+	ArchiveBucketURL string        // s3://bucket, gs://bucket or file:///path; empty disables
+	ArchiveEndpoint  string        // S3-compatible endpoint overriding the AWS or GCS default
+	ArchiveRegion    string        // signing region; us-east-1 for s3:// and auto for gs:// when empty
+	ArchiveAccessKey string        // AWS access key or GCS HMAC key
+	ArchiveSecretKey string        // secret of ARCHIVE_ACCESS_KEY
+	ArchiveInterval  time.Duration // how often settled jobs are exported
+	ArchiveAfter     time.Duration // how long a job stays settled before it is exported
+	ArchiveRetention time.Duration // how long exported batches are kept; 0 keeps them forever
+	ArchiveBatchSize int           // jobs per NDJSON object
+
+	// Daily settlement summaries
+	SettlementSummaryInterval time.Duration // how often finished UTC days are looked for; 0 disables
+	SettlementSlackWebhookURL string        // Slack incoming webhook each summary is posted to; empty posts nothing
+
+	// Read-only maintenance mode
+	MaintenanceRefreshInterval time.Duration // how often a window opened on another replica is picked up; 0 disables
+
+	// Signer wallet monitoring
+	WalletMonitorInterval   time.Duration // how often signer nonces are checked for transactions the gateway didn't send; 0 disables
+	WalletAnomalyKillSwitch bool          // halt signing when one is found, until admins re-arm it
+
+	// Kill switch two-person rule
+	KillSwitchApprovers      string        // addresses whose signed approval can stand in for a second admin key; empty requires two keys
+	KillSwitchApprovalWindow time.Duration // how long a proposal waits for its second approval, and a signed approval stays acceptable
+
+	// Display currencies
+	FXRatesURL string        // USD-based exchange rates in the open.er-api.com format; empty uses FXRates
+	FXRates    string        // fixed rates such as PKR=278.50,EUR=0.92 when FXRatesURL is empty
+	FXCacheTTL time.Duration // how long fetched rates are used before they are fetched again
+
+	// Client funding links
+	FundingLinkTTL           time.Duration // how long a funding link's pinned amount is offered
+	FundingLinkBufferPercent float64       // added to a link's amount so an ETH/USD drop before it is mined doesn't revert it
+	FundingLinkPollInterval  time.Duration // how often links are checked for their deposit; 0 disables tracking
+
+	// Platform events over Postgres LISTEN/NOTIFY
+	PlatformEventsChannel string        // channel the platform notifies when work is approved; empty disables
+	PlatformEventsRetry   time.Duration // wait before listening again after the connection drops
+
+	// Application status automation
+	AutomationRules        string        // [tenant:][from>]status=action rules such as approved=release,cancelled=refund; empty disables
+	AutomationPollInterval time.Duration // how often application statuses are checked for transitions
+
+	// Webhook notifications
+	WebhookURL    string
+	WebhookSecret string
+
+	// Public status links
+	StatusTokenSecret string        // HMAC key for /public/job-status tokens; empty disables them
+	StatusTokenTTL    time.Duration // longest lifetime of an issued token
+
+	// Signed confirmation requests
+	RequestSigningSecret string        // HMAC key callers sign /confirm-* with; empty accepts unsigned requests
+	ReplayWindow         time.Duration // how far a request timestamp may be from now
+
+	// Idempotency keys
+	IdempotencyKeyTTL time.Duration // how long a response is kept for retries with the same Idempotency-Key
+
+	// Admin keys
+	AdminAPIKeys         string // "name:key,..." accepted in X-Admin-Key by sensitive exports; empty disables them
+	FeeOverrideApprovers string // names of the admin keys that may override a job's fee at /post-job; empty allows every key
+
+	// Feature flags
+	FeatureFlags string // deployment-wide defaults, e.g. "retainers=off"; stored rules override them
+
+	// Anti-fraud velocity limits
+	VelocityLimits       string        // e.g. "client_daily_usd=5000:block,new_wallet_usd=1000:flag"; empty checks nothing
+	VelocityNewWalletAge time.Duration // wallets first seen more recently count as new
+
+	// Fault injection for resilience testing, never enable in production
+	FaultInjection          bool
+	FaultRPCTimeoutRate     float64 // fraction of chain and price calls that time out
+	FaultReceiptDelayRate   float64 // fraction of mined receipts reported as pending
+	FaultDBErrorRate        float64 // fraction of payment status queries that fail
+	FaultWebhookFailureRate float64 // fraction of webhook deliveries that fail
+	FaultSeed               uint64  // non-zero makes injected faults repeatable
+
 	// Database settings
 	DBHost      string
 	DBPort      string
@@ -29,25 +248,243 @@ type Config struct {
 	DBName      string
 	DatabaseURL string // Constructed from individual settings
 
+	DBQueryTimeout  time.Duration // upper bound for a single query
+	DetailsCacheTTL time.Duration // how long payment details reads are cached; 0 disables
+
+	// Long polling on /job-status?wait_for=
+	JobStatusMaxWait         time.Duration // longest a request may wait; 0 disables waiting
+	JobStatusRecheckInterval time.Duration // how often a waiting request re-reads, for changes made elsewhere
+
 	// Server settings
-	ServerPort string
+	ServerPort              string
+	ServerReadHeaderTimeout time.Duration // time to read request headers, the slowloris bound
+	ServerReadTimeout       time.Duration // time to read a whole request
+	ServerWriteTimeout      time.Duration // time to write a response; covers the handler, so batch releases need headroom
+	ServerIdleTimeout       time.Duration // how long a keep-alive connection may wait for its next request
+	MaxRequestBodyBytes     int64         // larger request bodies get 413; 0 disables
+	ServerHTTP2             bool          // HTTP/2 over TLS; false serves HTTP/1.1 only
+	ServerH2C               bool          // also HTTP/2 over cleartext with prior knowledge, for a proxy that terminates TLS
+	ServerHTTP2MaxStreams   int           // concurrent requests a client may run on one HTTP/2 connection
+	ServerTCPKeepAlive      time.Duration // how long a connection is idle before TCP keep-alive probes; negative disables
+	ShutdownTimeout         time.Duration // how long a SIGTERM waits for requests, workers and webhook deliveries to finish
+
+	// TLS, off when neither certificate files nor autocert domains are set
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSAutocertDomains  string // comma-separated hosts to obtain Let's Encrypt certificates for
+	TLSAutocertCacheDir string // where obtained certificates are stored
+	TLSAutocertEmail    string // ACME account contact
+	TLSAutocertHTTPAddr string // listener for HTTP-01 challenges and HTTPS redirects; empty disables
+
+	// Deployment manifest written by pgwctl deploy
+	DeploymentManifest string      // path; empty takes the contract settings from the environment alone
+	Deployment         *Deployment // nil without a manifest
+	DeploymentError    error       // why the manifest could not be used; the gateway refuses to start
 }
 
 func Load() *Config {
+	var deployment *Deployment
+	var deploymentErr error
+	manifest := getEnv("DEPLOYMENT_MANIFEST", "")
+	if manifest != "" {
+		deployment, deploymentErr = ReadDeployment(manifest)
+	}
+
+	networkID := getEnvAsInt64("NETWORK_ID", 11155111) // Sepolia
+	if deployment != nil && os.Getenv("NETWORK_ID") == "" {
+		networkID = deployment.NetworkID
+	}
+	finality := FinalityFor(networkID)
+
 	cfg := &Config{
 		// Default to Sepolia testnet
 		EthereumRPCURL:  getEnv("ETHEREUM_RPC_URL", "https://sepolia.infura.io/v3/YOUR_INFURA_KEY"),
-		NetworkID:       getEnvAsInt64("NETWORK_ID", 11155111), // Sepolia
+		NetworkID:       networkID,
 		ContractAddress: getEnv("CONTRACT_ADDRESS", ""),
 		PrivateKey:      getEnv("PRIVATE_KEY", ""),
 
+		ContractDeployBlock: getEnvAsUint64("CONTRACT_DEPLOY_BLOCK", 0),
+		ExplorerURLs:        getEnv("EXPLORER_URLS", ""),
+
+		EthereumRPCFallbackURLs: getEnv("ETHEREUM_RPC_FALLBACK_URLS", ""),
+		RPCFailoverBackoff:      getEnvAsDuration("RPC_FAILOVER_BACKOFF", 5*time.Second),
+		RPCFailoverMaxBackoff:   getEnvAsDuration("RPC_FAILOVER_MAX_BACKOFF", 5*time.Minute),
+		RPCProbeInterval:        getEnvAsDuration("RPC_PROBE_INTERVAL", 30*time.Second),
+
+		ExpectedImplementation: getEnv("EXPECTED_IMPLEMENTATION_ADDRESS", ""),
+		ProxyCheckInterval:     getEnvAsDuration("PROXY_CHECK_INTERVAL", 10*time.Minute),
+
+		ContractUpdateSigner: getEnv("CONTRACT_UPDATE_SIGNER", ""),
+		ContractUpdateMaxAge: getEnvAsDuration("CONTRACT_UPDATE_MAX_AGE", 15*time.Minute),
+
+		RemoteSignerURL:        getEnv("REMOTE_SIGNER_URL", ""),
+		RemoteSignerAPI:        getEnv("REMOTE_SIGNER_API", "web3signer"),
+		RemoteSignerAddress:    getEnv("REMOTE_SIGNER_ADDRESS", ""),
+		RemoteSignerTimeout:    getEnvAsDuration("REMOTE_SIGNER_TIMEOUT", 30*time.Second),
+		RemoteSignerCAFile:     getEnv("REMOTE_SIGNER_CA_FILE", ""),
+		RemoteSignerClientCert: getEnv("REMOTE_SIGNER_CLIENT_CERT", ""),
+		RemoteSignerClientKey:  getEnv("REMOTE_SIGNER_CLIENT_KEY", ""),
+
+		AdminSigner:            getEnv("ADMIN_SIGNER", ""),
+		LedgerDerivationPath:   getEnv("LEDGER_DERIVATION_PATH", "m/44'/60'/0'/0/0"),
+		PrivilegedUSDThreshold: getEnvAsInt64("PRIVILEGED_USD_THRESHOLD", 0),
+
 		// Sepolia ETH/USD price feed
 		ETHUSDPriceFeed: getEnv("ETH_USD_PRICE_FEED", "0x694AA1769357215DE4FAC081bf1f309aDC325306"),
+		OracleMaxAge:    getEnvAsDuration("ORACLE_MAX_AGE", 2*time.Hour),
+
+		OracleHeartbeat:        getEnvAsDuration("ORACLE_HEARTBEAT", time.Hour),
+		OracleDeviationPercent: getEnvAsFloat("ORACLE_DEVIATION_PERCENT", 0.5),
+		OracleHeartbeatGrace:   getEnvAsDuration("ORACLE_HEARTBEAT_GRACE", 5*time.Minute),
+		OracleCheckInterval:    getEnvAsDuration("ORACLE_CHECK_INTERVAL", time.Minute),
+		OracleFallbackFeed:     getEnv("ORACLE_FALLBACK_FEED", ""),
+
+		TWAPMinUSD: getEnvAsInt64("TWAP_MIN_USD", 10000),
+		TWAPRounds: getEnvAsInt("TWAP_ROUNDS", 12),
+
+		PriceDeviationPercent: getEnvAsFloat("PRICE_DEVIATION_PERCENT", 0),
+
+		OracleConsensusUSD:              getEnvAsInt64("ORACLE_CONSENSUS_USD", 0),
+		OracleConsensusTolerancePercent: getEnvAsFloat("ORACLE_CONSENSUS_TOLERANCE_PERCENT", 1),
+		OracleConsensusMinSources:       getEnvAsInt("ORACLE_CONSENSUS_MIN_SOURCES", 2),
+		OracleConsensusPriceURL:         getEnv("ORACLE_CONSENSUS_PRICE_URL", ""),
+		OracleConsensusPriceField:       getEnv("ORACLE_CONSENSUS_PRICE_FIELD", "data.amount"),
 
 		FeePercentage: getEnvAsInt("FEE_PERCENTAGE", 5),
+		ReserveFeeBPS: getEnvAsInt64("RESERVE_FEE_BPS", 0),
 		GasLimit:      getEnvAsUint64("GAS_LIMIT", 300000),
 		GasPrice:      getEnvAsInt64("GAS_PRICE", 20), // 20 Gwei
 
+		NonceResyncAfter: getEnvAsDuration("NONCE_RESYNC_AFTER", time.Minute),
+
+		ReleaseApprovedStatus: getEnv("RELEASE_APPROVED_STATUS", "approved"),
+		ReleaseBatchLimit:     getEnvAsInt("RELEASE_BATCH_LIMIT", 50),
+
+		ReleaseAuthorizationTTL: getEnvAsDuration("RELEASE_AUTHORIZATION_TTL", 7*24*time.Hour),
+
+		WalletLowRunway: getEnvAsInt64("WALLET_LOW_RUNWAY", 20),
+
+		MaxGasPrice:          getEnvAsInt64("MAX_GAS_PRICE", 0),
+		DeferOnHighGas:       getEnvAsBool("DEFER_ON_HIGH_GAS", false),
+		DeferDeadline:        getEnvAsDuration("DEFER_DEADLINE", 6*time.Hour),
+		DeferredPollInterval: getEnvAsDuration("DEFERRED_POLL_INTERVAL", time.Minute),
+
+		FeeStrategy:        getEnv("FEE_STRATEGY", "standard"),
+		ReleaseFeeStrategy: getEnv("RELEASE_FEE_STRATEGY", ""),
+
+		BaseFeeSampleInterval: getEnvAsDuration("BASE_FEE_SAMPLE_INTERVAL", 5*time.Minute),
+		BaseFeeHistory:        getEnvAsDuration("BASE_FEE_HISTORY", 7*24*time.Hour),
+		EconomicalMaxDelay:    getEnvAsDuration("ECONOMICAL_MAX_DELAY", 12*time.Hour),
+
+		RetryFailedOperations: getEnvAsBool("RETRY_FAILED_OPERATIONS", false),
+		MaxOperationAttempts:  getEnvAsInt("MAX_OPERATION_ATTEMPTS", 5),
+		RetryBackoff:          getEnvAsDuration("RETRY_BACKOFF", 30*time.Second),
+
+		SubmissionWorkers:    getEnvAsInt("SUBMISSION_WORKERS", 4),
+		SubmissionQueueDepth: getEnvAsInt("SUBMISSION_QUEUE_DEPTH", 32),
+
+		UrgentSubmissionWorkers: getEnvAsInt("URGENT_SUBMISSION_WORKERS", 1),
+		UrgentGasPricePercent:   getEnvAsInt64("URGENT_GAS_PRICE_PERCENT", 150),
+		UrgentMaxGasPrice:       getEnvAsInt64("URGENT_MAX_GAS_PRICE", 0),
+		UrgentFeeStrategy:       getEnv("URGENT_FEE_STRATEGY", "fast"),
+
+		CongestionSampleInterval: getEnvAsDuration("CONGESTION_SAMPLE_INTERVAL", 30*time.Second),
+		CongestionBaseFee:        getEnvAsInt64("CONGESTION_BASE_FEE", 0),
+		CongestionGasUsedPercent: getEnvAsInt64("CONGESTION_GAS_USED_PERCENT", 95),
+		CongestionPendingTxs:     getEnvAsInt64("CONGESTION_PENDING_TXS", 0),
+		CongestionInclusionTime:  getEnvAsDuration("CONGESTION_INCLUSION_TIME", 2*time.Minute),
+
+		CanaryMode:          getEnv("CANARY_MODE", "estimate"),
+		CanaryRetryInterval: getEnvAsDuration("CANARY_RETRY_INTERVAL", time.Minute),
+
+		IntentRecoveryGrace: getEnvAsDuration("INTENT_RECOVERY_GRACE", 5*time.Minute),
+
+		StatusPolling:         getEnvAsBool("STATUS_POLLING", true),
+		PollMinInterval:       getEnvAsDuration("POLL_MIN_INTERVAL", finality.PollInterval),
+		PollMaxInterval:       getEnvAsDuration("POLL_MAX_INTERVAL", 5*time.Minute),
+		PollJitter:            getEnvAsFloat("POLL_JITTER", 0.2),
+		RequiredConfirmations: getEnvAsUint64("REQUIRED_CONFIRMATIONS", finality.Confirmations),
+		ReorgWindow:           getEnvAsUint64("REORG_WINDOW", finality.ReorgWindow),
+		ReceiptBatchSize:      getEnvAsInt("RECEIPT_BATCH_SIZE", 100),
+
+		KYCSource:          getEnv("KYC_SOURCE", ""),
+		KYCThresholdUSD:    getEnvAsInt64("KYC_THRESHOLD_USD", 1000),
+		KYCPlatformColumn:  getEnv("KYC_PLATFORM_COLUMN", "kyc_status"),
+		KYCProviderURL:     getEnv("KYC_PROVIDER_URL", ""),
+		KYCProviderAPIKey:  getEnv("KYC_PROVIDER_API_KEY", ""),
+		KYCRecheckInterval: getEnvAsDuration("KYC_RECHECK_INTERVAL", 5*time.Minute),
+
+		IndexerPollInterval: getEnvAsDuration("INDEXER_POLL_INTERVAL", finality.PollInterval),
+		IndexerSafetyWindow: getEnvAsUint64("INDEXER_SAFETY_WINDOW", getEnvAsUint64("REORG_WINDOW", finality.ReorgWindow)),
+		EventStatusUpdates:  getEnvAsBool("EVENT_STATUS_UPDATES", getEnvAsBool("STATUS_POLLING", true)),
+
+		RetainerPollInterval: getEnvAsDuration("RETAINER_POLL_INTERVAL", 5*time.Minute),
+
+		HealthSnapshotInterval: getEnvAsDuration("HEALTH_SNAPSHOT_INTERVAL", time.Minute),
+		HealthHistoryRetention: getEnvAsDuration("HEALTH_HISTORY_RETENTION", 7*24*time.Hour),
+		HealthSlowLatency:      getEnvAsDuration("HEALTH_SLOW_LATENCY", 2*time.Second),
+
+		ArchiveBucketURL: getEnv("ARCHIVE_BUCKET_URL", ""),
+		ArchiveEndpoint:  getEnv("ARCHIVE_ENDPOINT", ""),
+		ArchiveRegion:    getEnv("ARCHIVE_REGION", ""),
+		ArchiveAccessKey: getEnv("ARCHIVE_ACCESS_KEY", ""),
+		ArchiveSecretKey: getEnv("ARCHIVE_SECRET_KEY", ""),
+		ArchiveInterval:  getEnvAsDuration("ARCHIVE_INTERVAL", 24*time.Hour),
+		ArchiveAfter:     getEnvAsDuration("ARCHIVE_AFTER", 30*24*time.Hour),
+		ArchiveRetention: getEnvAsDuration("ARCHIVE_RETENTION", 0),
+		ArchiveBatchSize: getEnvAsInt("ARCHIVE_BATCH_SIZE", 500),
+
+		SettlementSummaryInterval: getEnvAsDuration("SETTLEMENT_SUMMARY_INTERVAL", time.Hour),
+		SettlementSlackWebhookURL: getEnv("SETTLEMENT_SLACK_WEBHOOK_URL", ""),
+
+		MaintenanceRefreshInterval: getEnvAsDuration("MAINTENANCE_REFRESH_INTERVAL", 15*time.Second),
+
+		WalletMonitorInterval:   getEnvAsDuration("WALLET_MONITOR_INTERVAL", time.Minute),
+		WalletAnomalyKillSwitch: getEnvAsBool("WALLET_ANOMALY_KILL_SWITCH", false),
+
+		KillSwitchApprovers:      getEnv("KILL_SWITCH_APPROVERS", ""),
+		KillSwitchApprovalWindow: getEnvAsDuration("KILL_SWITCH_APPROVAL_WINDOW", 15*time.Minute),
+
+		FXRatesURL: getEnv("FX_RATES_URL", ""),
+		FXRates:    getEnv("FX_RATES", ""),
+		FXCacheTTL: getEnvAsDuration("FX_CACHE_TTL", time.Hour),
+
+		FundingLinkTTL:           getEnvAsDuration("FUNDING_LINK_TTL", 24*time.Hour),
+		FundingLinkBufferPercent: getEnvAsFloat("FUNDING_LINK_BUFFER_PERCENT", 0),
+		FundingLinkPollInterval:  getEnvAsDuration("FUNDING_LINK_POLL_INTERVAL", time.Minute),
+
+		PlatformEventsChannel: getEnv("PLATFORM_EVENTS_CHANNEL", ""),
+		PlatformEventsRetry:   getEnvAsDuration("PLATFORM_EVENTS_RETRY", 5*time.Second),
+
+		AutomationRules:        getEnv("AUTOMATION_RULES", ""),
+		AutomationPollInterval: getEnvAsDuration("AUTOMATION_POLL_INTERVAL", time.Minute),
+
+		WebhookURL:    getEnv("WEBHOOK_URL", ""),
+		WebhookSecret: getEnv("WEBHOOK_SECRET", ""),
+
+		StatusTokenSecret: getEnv("STATUS_TOKEN_SECRET", ""),
+		StatusTokenTTL:    getEnvAsDuration("STATUS_TOKEN_TTL", 24*time.Hour),
+
+		RequestSigningSecret: getEnv("REQUEST_SIGNING_SECRET", ""),
+		ReplayWindow:         getEnvAsDuration("REPLAY_WINDOW", 5*time.Minute),
+
+		IdempotencyKeyTTL: getEnvAsDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+
+		AdminAPIKeys:         getEnv("ADMIN_API_KEYS", ""),
+		FeeOverrideApprovers: getEnv("FEE_OVERRIDE_APPROVERS", ""),
+
+		FeatureFlags: getEnv("FEATURE_FLAGS", ""),
+
+		VelocityLimits:       getEnv("VELOCITY_LIMITS", ""),
+		VelocityNewWalletAge: getEnvAsDuration("VELOCITY_NEW_WALLET_AGE", 72*time.Hour),
+
+		FaultInjection:          getEnvAsBool("FAULT_INJECTION", false),
+		FaultRPCTimeoutRate:     getEnvAsFloat("FAULT_RPC_TIMEOUT_RATE", 0),
+		FaultReceiptDelayRate:   getEnvAsFloat("FAULT_RECEIPT_DELAY_RATE", 0),
+		FaultDBErrorRate:        getEnvAsFloat("FAULT_DB_ERROR_RATE", 0),
+		FaultWebhookFailureRate: getEnvAsFloat("FAULT_WEBHOOK_FAILURE_RATE", 0),
+		FaultSeed:               getEnvAsUint64("FAULT_SEED", 0),
+
 		// Database settings
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
@@ -55,7 +492,37 @@ func Load() *Config {
 		DBPassword: getEnv("DB_PASSWORD", "junglebook"),
 		DBName:     getEnv("DB_NAME", "fyp-go"),
 
-		ServerPort: getEnv("SERVER_PORT", "8081"),
+		DBQueryTimeout:  getEnvAsDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+		DetailsCacheTTL: getEnvAsDuration("DETAILS_CACHE_TTL", 2*time.Second),
+
+		JobStatusMaxWait:         getEnvAsDuration("JOB_STATUS_MAX_WAIT", 60*time.Second),
+		JobStatusRecheckInterval: getEnvAsDuration("JOB_STATUS_RECHECK_INTERVAL", 2*time.Second),
+
+		ServerPort:              getEnv("SERVER_PORT", "8081"),
+		ServerReadHeaderTimeout: getEnvAsDuration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second),
+		ServerReadTimeout:       getEnvAsDuration("SERVER_READ_TIMEOUT", 30*time.Second),
+		ServerWriteTimeout:      getEnvAsDuration("SERVER_WRITE_TIMEOUT", 5*time.Minute),
+		ServerIdleTimeout:       getEnvAsDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute),
+		MaxRequestBodyBytes:     getEnvAsInt64("MAX_REQUEST_BODY_BYTES", 1<<20),
+		ServerHTTP2:             getEnvAsBool("SERVER_HTTP2", true),
+		ServerH2C:               getEnvAsBool("SERVER_H2C", false),
+		ServerHTTP2MaxStreams:   getEnvAsInt("SERVER_HTTP2_MAX_STREAMS", 250),
+		ServerTCPKeepAlive:      getEnvAsDuration("SERVER_TCP_KEEPALIVE", 30*time.Second),
+		ShutdownTimeout:         getEnvAsDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		TLSCertFile:         getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:          getEnv("TLS_KEY_FILE", ""),
+		TLSAutocertDomains:  getEnv("TLS_AUTOCERT_DOMAINS", ""),
+		TLSAutocertCacheDir: getEnv("TLS_AUTOCERT_CACHE_DIR", "autocert-cache"),
+		TLSAutocertEmail:    getEnv("TLS_AUTOCERT_EMAIL", ""),
+		TLSAutocertHTTPAddr: getEnv("TLS_AUTOCERT_HTTP_ADDR", ":80"),
+
+		DeploymentManifest: manifest,
+		Deployment:         deployment,
+		DeploymentError:    deploymentErr,
+	}
+	if deployment != nil {
+		cfg.DeploymentError = cfg.applyDeployment(deployment)
 	}
 
 	// Construct database URL
@@ -104,6 +571,33 @@ func getEnvAsUint64(key string, defaultValue uint64) uint64 {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 // Network configurations
 var Networks = map[int64]NetworkConfig{
 	1: { // Mainnet
@@ -111,12 +605,73 @@ var Networks = map[int64]NetworkConfig{
 		ChainID:         1,
 		ETHUSDPriceFeed: "0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419",
 		ExplorerURL:     "https://etherscan.io",
+		Finality:        Finality{Confirmations: 12, ReorgWindow: 64, PollInterval: 12 * time.Second},
 	},
 	11155111: { // Sepolia
 		Name:            "sepolia",
 		ChainID:         11155111,
 		ETHUSDPriceFeed: "0x694AA1769357215DE4FAC081bf1f309aDC325306",
 		ExplorerURL:     "https://sepolia.etherscan.io",
+		Finality:        Finality{Confirmations: 3, ReorgWindow: 64, PollInterval: 12 * time.Second},
+		Testnet:         true,
+	},
+	17000: { // Holesky
+		Name:        "holesky",
+		ChainID:     17000,
+		ExplorerURL: "https://holesky.etherscan.io",
+		Finality:    Finality{Confirmations: 3, ReorgWindow: 64, PollInterval: 12 * time.Second},
+		Testnet:     true,
+	},
+	10: { // OP Mainnet
+		Name:        "optimism",
+		ChainID:     10,
+		ExplorerURL: "https://optimistic.etherscan.io",
+		Finality:    Finality{Confirmations: 10, ReorgWindow: 1800, PollInterval: 4 * time.Second},
+	},
+	11155420: { // OP Sepolia
+		Name:        "optimism-sepolia",
+		ChainID:     11155420,
+		ExplorerURL: "https://sepolia-optimism.etherscan.io",
+		Finality:    Finality{Confirmations: 5, ReorgWindow: 1800, PollInterval: 4 * time.Second},
+		Testnet:     true,
+	},
+	42161: { // Arbitrum One
+		Name:        "arbitrum",
+		ChainID:     42161,
+		ExplorerURL: "https://arbiscan.io",
+		Finality:    Finality{Confirmations: 20, ReorgWindow: 3600, PollInterval: 2 * time.Second},
+	},
+	421614: { // Arbitrum Sepolia
+		Name:        "arbitrum-sepolia",
+		ChainID:     421614,
+		ExplorerURL: "https://sepolia.arbiscan.io",
+		Finality:    Finality{Confirmations: 10, ReorgWindow: 3600, PollInterval: 2 * time.Second},
+		Testnet:     true,
+	},
+	8453: { // Base
+		Name:        "base",
+		ChainID:     8453,
+		ExplorerURL: "https://basescan.org",
+		Finality:    Finality{Confirmations: 10, ReorgWindow: 1800, PollInterval: 4 * time.Second},
+	},
+	84532: { // Base Sepolia
+		Name:        "base-sepolia",
+		ChainID:     84532,
+		ExplorerURL: "https://sepolia.basescan.org",
+		Finality:    Finality{Confirmations: 5, ReorgWindow: 1800, PollInterval: 4 * time.Second},
+		Testnet:     true,
+	},
+	137: { // Polygon PoS
+		Name:        "polygon",
+		ChainID:     137,
+		ExplorerURL: "https://polygonscan.com",
+		Finality:    Finality{Confirmations: 64, ReorgWindow: 256, PollInterval: 4 * time.Second},
+	},
+	31337: { // Anvil and Hardhat
+		Name:     "local",
+		ChainID:  31337,
+		Finality: Finality{Confirmations: 1, ReorgWindow: 0, PollInterval: time.Second},
+		Testnet:  true,
 	},
 }
 
@@ -125,4 +680,25 @@ type NetworkConfig struct {
 	ChainID         int64
 	ETHUSDPriceFeed string
 	ExplorerURL     string
+	Finality        Finality
+	Testnet         bool // its ETH has no value, so synthetic probes may spend it
+}
+
+// Finality is how long a network's blocks take to settle. Load uses it for
+// REQUIRED_CONFIRMATIONS, REORG_WINDOW and POLL_MIN_INTERVAL when they're unset.
+type Finality struct {
+	Confirmations uint64        // blocks on top of a transaction before it counts as final
+	ReorgWindow   uint64        // blocks behind the head that may still be reorganised away
+	PollInterval  time.Duration // how often to check receipts, about one block time
+}
+
+// DefaultFinality is used for networks not in Networks
+var DefaultFinality = Finality{Confirmations: 1, ReorgWindow: 64, PollInterval: 15 * time.Second}
+
+// FinalityFor returns the registered finality of a chain ID, or DefaultFinality
+func FinalityFor(chainID int64) Finality {
+	if network, ok := Networks[chainID]; ok {
+		return network.Finality
+	}
+	return DefaultFinality
 }
@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -17,8 +20,8 @@ func TestLoad(t *testing.T) {
 		t.Errorf("Expected default FeePercentage to be 5, got %d", cfg.FeePercentage)
 	}
 
-	if cfg.ServerPort != "8080" {
-		t.Errorf("Expected default ServerPort to be 8080, got %s", cfg.ServerPort)
+	if cfg.ServerPort != "8081" {
+		t.Errorf("Expected default ServerPort to be 8081, got %s", cfg.ServerPort)
 	}
 }
 
@@ -65,3 +68,111 @@ func TestNetworkConfigs(t *testing.T) {
 		t.Errorf("Expected sepolia ChainID to be 11155111, got %d", sepolia.ChainID)
 	}
 }
+
+func TestNetworkFinality(t *testing.T) {
+	tests := []struct {
+		name          string
+		networkID     string
+		env           map[string]string
+		confirmations uint64
+		reorgWindow   uint64
+		pollInterval  time.Duration
+	}{
+		{"mainnet defaults", "1", nil, 12, 64, 12 * time.Second},
+		{"sepolia defaults", "11155111", nil, 3, 64, 12 * time.Second},
+		{"arbitrum defaults", "42161", nil, 20, 3600, 2 * time.Second},
+		{"unknown chain", "999999", nil, DefaultFinality.Confirmations, DefaultFinality.ReorgWindow, DefaultFinality.PollInterval},
+		{"overrides", "1", map[string]string{"REQUIRED_CONFIRMATIONS": "32", "REORG_WINDOW": "128", "POLL_MIN_INTERVAL": "30s"}, 32, 128, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NETWORK_ID", tt.networkID)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := Load()
+			if cfg.RequiredConfirmations != tt.confirmations {
+				t.Errorf("Expected %d confirmations, got %d", tt.confirmations, cfg.RequiredConfirmations)
+			}
+			if cfg.ReorgWindow != tt.reorgWindow {
+				t.Errorf("Expected a reorg window of %d, got %d", tt.reorgWindow, cfg.ReorgWindow)
+			}
+			if cfg.PollMinInterval != tt.pollInterval {
+				t.Errorf("Expected a poll interval of %s, got %s", tt.pollInterval, cfg.PollMinInterval)
+			}
+		})
+	}
+
+	for chainID, network := range Networks {
+		if network.ChainID != chainID {
+			t.Errorf("Network %s is registered under chain ID %d but has %d", network.Name, chainID, network.ChainID)
+		}
+		if network.Finality.Confirmations == 0 || network.Finality.PollInterval <= 0 {
+			t.Errorf("Network %s has no finality configured", network.Name)
+		}
+	}
+}
+
+func TestLoadDeploymentManifest(t *testing.T) {
+	manifest := &Deployment{
+		NetworkID:       31337,
+		ContractAddress: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		DeployBlock:     7,
+		Owner:           "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		ETHUSDPriceFeed: "0x0000000000000000000000000000000000000FEE",
+		FeeBPS:          500,
+	}
+	path := filepath.Join(t.TempDir(), "deployment.json")
+	if err := manifest.Write(path); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	t.Setenv("DEPLOYMENT_MANIFEST", path)
+
+	t.Run("applied", func(t *testing.T) {
+		// Agreeing values, in another letter case, are no conflict
+		t.Setenv("CONTRACT_ADDRESS", strings.ToLower(manifest.ContractAddress))
+
+		cfg := Load()
+		if cfg.DeploymentError != nil {
+			t.Fatalf("Unexpected deployment error: %v", cfg.DeploymentError)
+		}
+		if cfg.NetworkID != 31337 || cfg.ContractAddress != manifest.ContractAddress || cfg.ContractDeployBlock != 7 {
+			t.Errorf("Expected the manifest's contract, got chain %d, %s from block %d", cfg.NetworkID, cfg.ContractAddress, cfg.ContractDeployBlock)
+		}
+		if cfg.ETHUSDPriceFeed != manifest.ETHUSDPriceFeed || cfg.FeePercentage != 5 {
+			t.Errorf("Expected the manifest's feed and fee, got %s and %d%%", cfg.ETHUSDPriceFeed, cfg.FeePercentage)
+		}
+		if cfg.RequiredConfirmations != Networks[31337].Finality.Confirmations {
+			t.Errorf("Expected the manifest network's finality, got %d confirmations", cfg.RequiredConfirmations)
+		}
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		t.Setenv("NETWORK_ID", "1")
+		t.Setenv("CONTRACT_DEPLOY_BLOCK", "9")
+
+		cfg := Load()
+		if cfg.DeploymentError == nil {
+			t.Fatal("Expected a deployment error")
+		}
+		for _, key := range []string{"NETWORK_ID", "CONTRACT_DEPLOY_BLOCK"} {
+			if !strings.Contains(cfg.DeploymentError.Error(), key) {
+				t.Errorf("Expected %s to be reported, got %v", key, cfg.DeploymentError)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "deployment.json")
+		if err := os.WriteFile(bad, []byte(`{"network_id": 1, "contract_address": "0x0"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("DEPLOYMENT_MANIFEST", bad)
+
+		if cfg := Load(); cfg.DeploymentError == nil {
+			t.Error("Expected an invalid manifest to be reported")
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Deployment is the manifest pgwctl deploy writes for an escrow contract it
+// deployed. Load takes the contract settings from it when DEPLOYMENT_MANIFEST
+// is set, so they cannot drift from what was deployed.
+type Deployment struct {
+	NetworkID       int64     `json:"network_id"`
+	ContractAddress string    `json:"contract_address"`
+	DeployBlock     uint64    `json:"deploy_block"`
+	DeployTx        string    `json:"deploy_tx"`
+	Deployer        string    `json:"deployer"`
+	Owner           string    `json:"owner"`
+	ETHUSDPriceFeed string    `json:"eth_usd_price_feed"`
+	FeeBPS          int64     `json:"fee_bps"`   // read back from the deployed contract
+	CodeHash        string    `json:"code_hash"` // keccak256 of the runtime bytecode
+	DeployedAt      time.Time `json:"deployed_at"`
+}
+
+// ReadDeployment reads and validates the manifest at path
+func ReadDeployment(path string) (*Deployment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deployment manifest: %w", err)
+	}
+	var d Deployment
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse deployment manifest %s: %w", path, err)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid deployment manifest %s: %w", path, err)
+	}
+	return &d, nil
+}
+
+// Write validates the manifest and writes it to path, replacing any file
+// there only once the new one is complete
+func (d *Deployment) Write(path string) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode deployment manifest: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write deployment manifest: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write deployment manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write deployment manifest: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write deployment manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write deployment manifest: %w", err)
+	}
+	return nil
+}
+
+// Validate checks the manifest describes a deployment the gateway can use
+func (d *Deployment) Validate() error {
+	var errs []error
+	if d.NetworkID <= 0 {
+		errs = append(errs, fmt.Errorf("network_id %d is not a chain ID", d.NetworkID))
+	}
+	for _, field := range []struct{ name, value string }{
+		{"contract_address", d.ContractAddress},
+		{"owner", d.Owner},
+		{"eth_usd_price_feed", d.ETHUSDPriceFeed},
+	} {
+		if !common.IsHexAddress(field.value) || common.HexToAddress(field.value) == (common.Address{}) {
+			errs = append(errs, fmt.Errorf("%s %q is not a nonzero address", field.name, field.value))
+		}
+	}
+	if d.DeployBlock == 0 {
+		errs = append(errs, errors.New("deploy_block is missing"))
+	}
+	if d.FeeBPS < 0 || d.FeeBPS > 10000 {
+		errs = append(errs, fmt.Errorf("fee_bps %d is outside 0-10000", d.FeeBPS))
+	}
+	return errors.Join(errs...)
+}
+
+// applyDeployment takes the contract settings from d. A setting also given in
+// the environment must agree with the manifest; every disagreement is
+// reported, since the gateway would otherwise run against a contract other
+// than the one deployed.
+func (cfg *Config) applyDeployment(d *Deployment) error {
+	var errs []error
+	conflict := func(key, manifest string) {
+		errs = append(errs, fmt.Errorf("%s=%s disagrees with the deployment manifest's %s", key, os.Getenv(key), manifest))
+	}
+
+	if os.Getenv("NETWORK_ID") != "" && cfg.NetworkID != d.NetworkID {
+		conflict("NETWORK_ID", strconv.FormatInt(d.NetworkID, 10))
+	}
+	cfg.NetworkID = d.NetworkID
+
+	for _, field := range []struct {
+		key      string
+		manifest string
+		value    *string
+	}{
+		{"CONTRACT_ADDRESS", d.ContractAddress, &cfg.ContractAddress},
+		{"ETH_USD_PRICE_FEED", d.ETHUSDPriceFeed, &cfg.ETHUSDPriceFeed},
+	} {
+		if os.Getenv(field.key) != "" && common.HexToAddress(*field.value) != common.HexToAddress(field.manifest) {
+			conflict(field.key, field.manifest)
+		}
+		*field.value = common.HexToAddress(field.manifest).Hex()
+	}
+
+	if os.Getenv("CONTRACT_DEPLOY_BLOCK") != "" && cfg.ContractDeployBlock != d.DeployBlock {
+		conflict("CONTRACT_DEPLOY_BLOCK", strconv.FormatUint(d.DeployBlock, 10))
+	}
+	cfg.ContractDeployBlock = d.DeployBlock
+
+	// The gateway quotes fees in whole percent, as the contract charges them
+	if d.FeeBPS%100 != 0 {
+		errs = append(errs, fmt.Errorf("the deployment manifest's fee_bps %d is not a whole percent", d.FeeBPS))
+	} else {
+		if os.Getenv("FEE_PERCENTAGE") != "" && int64(cfg.FeePercentage) != d.FeeBPS/100 {
+			conflict("FEE_PERCENTAGE", strconv.FormatInt(d.FeeBPS/100, 10))
+		}
+		cfg.FeePercentage = int(d.FeeBPS / 100)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+// Package address parses Ethereum addresses at the API boundary into the
+// canonical form the gateway stores and compares: the EIP-55 checksummed
+// hex of common.Address.Hex.
+//
+// Wallet addresses in the platform's users table are written by the
+// platform, in whatever case the user pasted them. Comparing them as strings
+// against a request rejects the same wallet written differently, so every
+// comparison goes through Equal.
+package address
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrChecksum is returned for a mixed-case address whose case doesn't match
+// its EIP-55 checksum, most likely a mistyped address
+var ErrChecksum = errors.New("address has an invalid EIP-55 checksum")
+
+// Parse reads a 20-byte hex address, with or without its 0x prefix. An
+// all-lowercase or all-uppercase address carries no checksum and is taken
+// as is; a mixed-case one must match its EIP-55 checksum.
+func Parse(raw string) (common.Address, error) {
+	raw = strings.TrimSpace(raw)
+	if !common.IsHexAddress(raw) {
+		return common.Address{}, fmt.Errorf("%q is not a 20-byte hex address", raw)
+	}
+	digits := raw
+	if len(digits) == 2*common.AddressLength+2 {
+		digits = digits[2:]
+	}
+	address := common.HexToAddress(raw)
+	mixedCase := digits != strings.ToLower(digits) && digits != strings.ToUpper(digits)
+	if mixedCase && digits != address.Hex()[2:] {
+		return common.Address{}, fmt.Errorf("%w: %s", ErrChecksum, raw)
+	}
+	return address, nil
+}
+
+// Canonical returns raw in canonical form, or Parse's error
+func Canonical(raw string) (string, error) {
+	address, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return address.Hex(), nil
+}
+
+// Equal reports whether a and b are the same address however each is cased.
+// Stored values aren't checksum-validated: a wrongly cased address from the
+// platform still names the account it spells. Values that aren't addresses
+// are never equal.
+func Equal(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if !common.IsHexAddress(a) || !common.IsHexAddress(b) {
+		return false
+	}
+	return common.HexToAddress(a) == common.HexToAddress(b)
+}
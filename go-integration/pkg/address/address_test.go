@@ -0,0 +1,45 @@
+package address
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	const checksummed = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	for _, raw := range []string{
+		checksummed,
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		"0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED",
+		"0X5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		"5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		" 0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed\n",
+	} {
+		got, err := Canonical(raw)
+		if err != nil || got != checksummed {
+			t.Errorf("Canonical(%q) = %q, %v; want %s", raw, got, err, checksummed)
+		}
+	}
+
+	if _, err := Parse("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD"); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Parse of a miscased address = %v, want ErrChecksum", err)
+	}
+	for _, raw := range []string{"", "0x", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1bea", "0xzzaeb6053f3e94c9b9a09f33669435e7ef1beaed"} {
+		if _, err := Parse(raw); err == nil || errors.Is(err, ErrChecksum) {
+			t.Errorf("Parse(%q) = %v, want a format error", raw, err)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !Equal("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed") {
+		t.Error("Expected the checksummed and lowercase forms to be equal")
+	}
+	// A stored address with a bad checksum still names its account
+	if !Equal("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED") {
+		t.Error("Expected a miscased address to equal its uppercase form")
+	}
+	if Equal("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "0x00000000000000000000000000000000000000c1") || Equal("", "") || Equal("nope", "nope") {
+		t.Error("Expected different or invalid addresses to be unequal")
+	}
+}
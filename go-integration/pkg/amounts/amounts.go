@@ -0,0 +1,143 @@
+// Package amounts converts between USD, wei and ether. Every conversion the
+// gateway makes goes through here, with the rounding spelled out at each
+// call, so a quote, the deposit the contract checks and the payout it makes
+// agree to the wei.
+//
+// Math is exact on big.Rat and only rounded when an integer amount is needed.
+// Conversions that mirror the contract round down, the way Solidity's integer
+// division does.
+package amounts
+
+import (
+	"math/big"
+)
+
+// Decimals of the units the gateway handles
+const (
+	PriceDecimals = 8  // Chainlink ETH/USD answers
+	ETHDecimals   = 18 // wei per ether
+)
+
+// Rounding chooses how an exact amount becomes an integer
+type Rounding int
+
+const (
+	Down   Rounding = iota // toward zero, as Solidity's integer division
+	Up                     // away from zero
+	HalfUp                 // to nearest, halves away from zero
+)
+
+var (
+	weiPerETH   = pow10(ETHDecimals)
+	priceScale  = pow10(PriceDecimals)
+	percentBase = big.NewInt(100)
+)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Round returns r as an integer using mode
+func Round(r *big.Rat, mode Rounding) *big.Int {
+	quo, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	away := false
+	switch mode {
+	case Up:
+		away = true
+	case HalfUp:
+		away = new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(r.Denom()) >= 0
+	}
+	if away {
+		quo.Add(quo, big.NewInt(int64(r.Sign())))
+	}
+	return quo
+}
+
+// Scale returns r multiplied by 10^places and rounded with mode, e.g. cents
+// for a dollar amount with places 2
+func Scale(r *big.Rat, places int, mode Rounding) *big.Int {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(places)))
+	return Round(scaled, mode)
+}
+
+// Decimal writes r with exactly places decimals, rounded with mode, e.g.
+// "6.000000" for a stored cost_usd
+func Decimal(r *big.Rat, places int, mode Rounding) string {
+	scaled := Scale(r, places, mode)
+	return new(big.Rat).SetFrac(scaled, pow10(places)).FloatString(places)
+}
+
+// NormalizePrice rescales an answer of a feed with decimals to PriceDecimals,
+// rounding down, so the rest of the gateway only handles 8-decimal answers
+func NormalizePrice(answer *big.Int, decimals uint8) *big.Int {
+	switch {
+	case int(decimals) > PriceDecimals:
+		return new(big.Int).Quo(answer, pow10(int(decimals)-PriceDecimals))
+	case int(decimals) < PriceDecimals:
+		return new(big.Int).Mul(answer, pow10(PriceDecimals-int(decimals)))
+	}
+	return new(big.Int).Set(answer)
+}
+
+// PriceUSD returns a Chainlink ETH/USD answer as dollars per ether
+func PriceUSD(answer *big.Int) *big.Rat {
+	return new(big.Rat).SetFrac(answer, priceScale)
+}
+
+// EscrowWei returns the wei postJob requires for a whole-dollar amount,
+// exactly as the contract's convertUsdToEth computes it: usdAmount * 1e18
+// divided by the raw 8-decimal answer, rounded down. The contract doesn't
+// scale the answer, so this is 1e-8 of the market value USDToWei gives;
+// it is what the escrow holds and pays out, and must be used for deposits.
+func EscrowWei(usdAmount, answer *big.Int) *big.Int {
+	wei := new(big.Int).Mul(usdAmount, weiPerETH)
+	return wei.Quo(wei, answer)
+}
+
+// WeiToUSD returns the market value of wei at a Chainlink ETH/USD answer
+func WeiToUSD(wei, answer *big.Int) *big.Rat {
+	return new(big.Rat).Mul(WeiToETH(wei), PriceUSD(answer))
+}
+
+// USDToWei returns the wei worth usd at a Chainlink ETH/USD answer, rounded
+// with mode
+func USDToWei(usd *big.Rat, answer *big.Int, mode Rounding) *big.Int {
+	eth := new(big.Rat).Quo(usd, PriceUSD(answer))
+	return ETHToWei(eth, mode)
+}
+
+// WeiToETH returns wei in ether
+func WeiToETH(wei *big.Int) *big.Rat {
+	return new(big.Rat).SetFrac(wei, weiPerETH)
+}
+
+// ETHToWei returns ether in wei, rounded with mode
+func ETHToWei(eth *big.Rat, mode Rounding) *big.Int {
+	return Scale(eth, ETHDecimals, mode)
+}
+
+// Split divides an escrow the way markJobCompleted does: the platform fee is
+// feePercent of wei rounded down, and the freelancer receives the rest, so
+// the two always add up to wei
+func Split(wei *big.Int, feePercent int) (fee, payout *big.Int) {
+	fee = new(big.Int).Mul(wei, big.NewInt(int64(feePercent)))
+	fee.Quo(fee, percentBase)
+	return fee, new(big.Int).Sub(wei, fee)
+}
+
+// Fee returns the platform fee of Split
+func Fee(wei *big.Int, feePercent int) *big.Int {
+	fee, _ := Split(wei, feePercent)
+	return fee
+}
+
+// FeeBasisPoints returns basisPoints of wei rounded down, for a fee the
+// gateway accounts for itself such as a job's fee override
+func FeeBasisPoints(wei *big.Int, basisPoints int64) *big.Int {
+	fee := new(big.Int).Mul(wei, big.NewInt(basisPoints))
+	return fee.Quo(fee, big.NewInt(10000))
+}
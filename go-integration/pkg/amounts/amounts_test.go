@@ -0,0 +1,166 @@
+package amounts
+
+import (
+	"math/big"
+	"testing"
+	"testing/quick"
+)
+
+// answer turns a random value into a plausible Chainlink answer between $1 and $100,000
+func answer(n uint64) *big.Int {
+	return big.NewInt(int64(n%(100_000_00000000-1_00000000)) + 1_00000000)
+}
+
+func TestEscrowWei(t *testing.T) {
+	// Matches convertUsdToEth(100) with a feed answer of 3000.00000000, rounded down
+	wei := EscrowWei(big.NewInt(100), big.NewInt(3000_00000000))
+	expected := big.NewInt(333333333)
+	if wei.Cmp(expected) != 0 {
+		t.Errorf("Expected %s wei, got %s", expected, wei)
+	}
+}
+
+func TestWeiToUSD(t *testing.T) {
+	// 0.002 ETH at $3,000.00 (8 decimals) is $6
+	usd := WeiToUSD(big.NewInt(2_000_000_000_000_000), big.NewInt(3000_00000000))
+	if Decimal(usd, 2, HalfUp) != "6.00" {
+		t.Errorf("Expected $6.00, got %s", usd.FloatString(2))
+	}
+	if got := PriceUSD(big.NewInt(3000_12345678)).FloatString(8); got != "3000.12345678" {
+		t.Errorf("Unexpected price %s", got)
+	}
+}
+
+func TestNormalizePrice(t *testing.T) {
+	cases := []struct {
+		answer   int64
+		decimals uint8
+		want     int64
+	}{
+		{3000_00000000, 8, 3000_00000000},
+		{3000_123456789, 9, 3000_12345678}, // rounded down
+		{3000_123456, 6, 3000_12345600},
+		{3000, 0, 3000_00000000},
+	}
+	for _, c := range cases {
+		if got := NormalizePrice(big.NewInt(c.answer), c.decimals); got.Int64() != c.want {
+			t.Errorf("NormalizePrice(%d, %d) = %s, expected %d", c.answer, c.decimals, got, c.want)
+		}
+	}
+
+	eighteen, _ := new(big.Int).SetString("3000000000000000000000", 10)
+	if got := NormalizePrice(eighteen, 18); got.Int64() != 3000_00000000 {
+		t.Errorf("Expected an 18-decimal answer to become 3000.00000000, got %s", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	// markJobCompleted rounds the fee down and pays the remainder to the freelancer
+	fee, payout := Split(big.NewInt(1999), 5)
+	if fee.Int64() != 99 || payout.Int64() != 1900 {
+		t.Errorf("Expected fee 99 and payout 1900, got %s and %s", fee, payout)
+	}
+	if Fee(big.NewInt(1999), 5).Cmp(fee) != 0 {
+		t.Error("Expected Fee to match Split")
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		value          string
+		down, up, half int64
+	}{
+		{"5/2", 2, 3, 3},
+		{"-5/2", -2, -3, -3},
+		{"12/5", 2, 3, 2},
+		{"-12/5", -2, -3, -2},
+		{"3", 3, 3, 3},
+		{"0", 0, 0, 0},
+	}
+
+	for _, c := range cases {
+		r, _ := new(big.Rat).SetString(c.value)
+		for mode, expected := range map[Rounding]int64{Down: c.down, Up: c.up, HalfUp: c.half} {
+			if got := Round(r, mode); got.Int64() != expected {
+				t.Errorf("Round(%s, %d) = %s, expected %d", c.value, mode, got, expected)
+			}
+		}
+	}
+
+	if got := Decimal(big.NewRat(-1, 200), 2, HalfUp); got != "-0.01" {
+		t.Errorf("Expected -0.01, got %s", got)
+	}
+}
+
+func TestSplitProperties(t *testing.T) {
+	property := func(n uint64, percent uint8) bool {
+		wei := new(big.Int).SetUint64(n)
+		feePercent := int(percent % 101)
+		fee, payout := Split(wei, feePercent)
+		return new(big.Int).Add(fee, payout).Cmp(wei) == 0 &&
+			fee.Sign() >= 0 && payout.Sign() >= 0 &&
+			fee.Cmp(Round(new(big.Rat).SetFrac(new(big.Int).Mul(wei, big.NewInt(int64(feePercent))), big.NewInt(100)), Down)) == 0
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEscrowWeiMatchesContractDivision(t *testing.T) {
+	// wei * answer <= usd * 1e18 < (wei + 1) * answer, i.e. floor division
+	property := func(usd uint32, n uint64) bool {
+		price := answer(n)
+		usdAmount := big.NewInt(int64(usd))
+		wei := EscrowWei(usdAmount, price)
+
+		target := new(big.Int).Mul(usdAmount, pow10(ETHDecimals))
+		low := new(big.Int).Mul(wei, price)
+		high := new(big.Int).Mul(new(big.Int).Add(wei, big.NewInt(1)), price)
+		return low.Cmp(target) <= 0 && target.Cmp(high) < 0
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUSDToWeiBracketsValue(t *testing.T) {
+	// Rounding down never quotes more than usd is worth and rounding up never
+	// less, and the two are at most one wei apart
+	property := func(cents uint32, n uint64) bool {
+		price := answer(n)
+		usd := big.NewRat(int64(cents), 100)
+		down := USDToWei(usd, price, Down)
+		up := USDToWei(usd, price, Up)
+		half := USDToWei(usd, price, HalfUp)
+
+		return WeiToUSD(down, price).Cmp(usd) <= 0 &&
+			WeiToUSD(up, price).Cmp(usd) >= 0 &&
+			new(big.Int).Sub(up, down).Cmp(big.NewInt(1)) <= 0 &&
+			half.Cmp(down) >= 0 && half.Cmp(up) <= 0
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWeiRoundTrips(t *testing.T) {
+	property := func(n uint64, mode uint8) bool {
+		wei := new(big.Int).SetUint64(n)
+		return ETHToWei(WeiToETH(wei), Rounding(mode%3)).Cmp(wei) == 0
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecimalMatchesFloatString(t *testing.T) {
+	// big.Rat.FloatString also rounds halves away from zero
+	property := func(num int64, denom uint16, places uint8) bool {
+		r := new(big.Rat).SetFrac(big.NewInt(num), big.NewInt(int64(denom)+1))
+		p := int(places % 10)
+		return Decimal(r, p, HalfUp) == r.FloatString(p)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
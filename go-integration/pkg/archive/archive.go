@@ -0,0 +1,189 @@
+// Package archive writes batches of records to object storage as
+// newline-delimited JSON. Each batch is described by a manifest holding the
+// object's SHA-256, so a batch read back for a restore can be verified.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Bucket.Get for a key that does not exist
+var ErrNotFound = errors.New("archive object not found")
+
+// Bucket stores archive objects by key. Keys use "/" as the separator.
+type Bucket interface {
+	Put(ctx context.Context, key string, body []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	List(ctx context.Context, prefix string) ([]string, error) // keys under prefix, sorted
+	Delete(ctx context.Context, key string) error
+}
+
+const manifestPrefix = "manifests/"
+
+// Manifest describes one archived batch
+type Manifest struct {
+	Batch          string     `json:"batch"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"` // when the batch may be pruned; nil keeps it forever
+	Object         string     `json:"object"`               // key of the NDJSON object
+	Records        int        `json:"records"`
+	Bytes          int        `json:"bytes"`
+	SHA256         string     `json:"sha256"`
+	ApplicationIDs []int32    `json:"application_ids"`
+}
+
+// NewManifest starts the manifest of a batch created at createdAt. A positive
+// retention sets when the batch expires.
+func NewManifest(createdAt time.Time, retention time.Duration, applicationIDs []int32) *Manifest {
+	createdAt = createdAt.UTC().Truncate(time.Second)
+	batch := createdAt.Format("20060102T150405Z")
+	if len(applicationIDs) > 0 {
+		batch = fmt.Sprintf("%s-%d", batch, applicationIDs[0])
+	}
+	manifest := &Manifest{
+		Batch:          batch,
+		CreatedAt:      createdAt,
+		Object:         fmt.Sprintf("jobs/%s/%s.ndjson", createdAt.Format("2006/01/02"), batch),
+		ApplicationIDs: applicationIDs,
+	}
+	if retention > 0 {
+		expiresAt := createdAt.Add(retention)
+		manifest.ExpiresAt = &expiresAt
+	}
+	return manifest
+}
+
+// ManifestKey is the key a batch's manifest is stored under
+func ManifestKey(batch string) string {
+	return manifestPrefix + batch + ".json"
+}
+
+// Write encodes records one JSON document per line, stores them under
+// manifest.Object and then stores the completed manifest. The manifest is
+// written last, so a batch with a manifest always has its whole object.
+func Write[T any](ctx context.Context, bucket Bucket, manifest *Manifest, records []T) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("error encoding archive record: %w", err)
+		}
+	}
+
+	digest := sha256.Sum256(body.Bytes())
+	manifest.Records = len(records)
+	manifest.Bytes = body.Len()
+	manifest.SHA256 = hex.EncodeToString(digest[:])
+
+	if err := bucket.Put(ctx, manifest.Object, body.Bytes()); err != nil {
+		return fmt.Errorf("error writing archive object %s: %w", manifest.Object, err)
+	}
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding archive manifest: %w", err)
+	}
+	if err := bucket.Put(ctx, ManifestKey(manifest.Batch), encoded); err != nil {
+		return fmt.Errorf("error writing archive manifest %s: %w", manifest.Batch, err)
+	}
+	return nil
+}
+
+// GetManifest reads the manifest of a batch
+func GetManifest(ctx context.Context, bucket Bucket, batch string) (*Manifest, error) {
+	body, err := bucket.Get(ctx, ManifestKey(batch))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("error decoding archive manifest %s: %w", batch, err)
+	}
+	return &manifest, nil
+}
+
+// Manifests reads every manifest in the bucket, oldest batch first
+func Manifests(ctx context.Context, bucket Bucket) ([]*Manifest, error) {
+	keys, err := bucket.List(ctx, manifestPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("error listing archive manifests: %w", err)
+	}
+
+	manifests := make([]*Manifest, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".json") {
+			continue
+		}
+		manifest, err := GetManifest(ctx, bucket, strings.TrimSuffix(strings.TrimPrefix(key, manifestPrefix), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Batch < manifests[j].Batch })
+	return manifests, nil
+}
+
+// Read fetches a batch's object, checks it against the manifest's digest and
+// record count and decodes its records
+func Read[T any](ctx context.Context, bucket Bucket, manifest *Manifest) ([]T, error) {
+	body, err := bucket.Get(ctx, manifest.Object)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive object %s: %w", manifest.Object, err)
+	}
+	if digest := sha256.Sum256(body); hex.EncodeToString(digest[:]) != manifest.SHA256 {
+		return nil, fmt.Errorf("archive object %s does not match its manifest's sha256", manifest.Object)
+	}
+
+	records := make([]T, 0, manifest.Records)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		var record T
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("error decoding record %d of %s: %w", len(records)+1, manifest.Object, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading archive object %s: %w", manifest.Object, err)
+	}
+	if len(records) != manifest.Records {
+		return nil, fmt.Errorf("archive object %s has %d records, its manifest lists %d", manifest.Object, len(records), manifest.Records)
+	}
+	return records, nil
+}
+
+// Prune deletes the batches that expired before now and returns them. The
+// object goes first and the manifest last, so an interrupted prune is
+// finished by the next one.
+func Prune(ctx context.Context, bucket Bucket, now time.Time) ([]*Manifest, error) {
+	manifests, err := Manifests(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []*Manifest
+	for _, manifest := range manifests {
+		if manifest.ExpiresAt == nil || manifest.ExpiresAt.After(now) {
+			continue
+		}
+		if err := bucket.Delete(ctx, manifest.Object); err != nil {
+			return pruned, fmt.Errorf("error deleting archive object %s: %w", manifest.Object, err)
+		}
+		if err := bucket.Delete(ctx, ManifestKey(manifest.Batch)); err != nil {
+			return pruned, fmt.Errorf("error deleting archive manifest %s: %w", manifest.Batch, err)
+		}
+		pruned = append(pruned, manifest)
+	}
+	return pruned, nil
+}
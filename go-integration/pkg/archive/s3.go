@@ -0,0 +1,298 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat = "20060102T150405Z"
+	gcsEndpoint   = "https://storage.googleapis.com"
+)
+
+// S3Options configures the S3-compatible API a bucket URL is reached through
+type S3Options struct {
+	Endpoint  string // overrides the AWS or GCS endpoint, e.g. for MinIO
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// Open returns the bucket a URL names: s3://bucket for Amazon S3, gs://bucket
+// for Google Cloud Storage through its S3-compatible API with HMAC keys, or
+// file:///path for a local directory
+func Open(rawURL string, options S3Options) (Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive bucket URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("archive bucket URL %q has no path", rawURL)
+		}
+		return &DirBucket{Root: u.Path}, nil
+	case "s3", "gs":
+	default:
+		return nil, fmt.Errorf("archive bucket URL %q must start with s3://, gs:// or file://", rawURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("archive bucket URL %q has no bucket name", rawURL)
+	}
+	if options.AccessKey == "" || options.SecretKey == "" {
+		return nil, fmt.Errorf("archive bucket %s needs an access key and secret", rawURL)
+	}
+
+	bucket := &S3Bucket{
+		Endpoint:  options.Endpoint,
+		Bucket:    u.Host,
+		Region:    options.Region,
+		AccessKey: options.AccessKey,
+		SecretKey: options.SecretKey,
+	}
+	if u.Scheme == "gs" {
+		// GCS accepts any region in the signature; "auto" is what its docs use
+		if bucket.Region == "" {
+			bucket.Region = "auto"
+		}
+		if bucket.Endpoint == "" {
+			bucket.Endpoint = gcsEndpoint
+		}
+	}
+	if bucket.Region == "" {
+		bucket.Region = "us-east-1"
+	}
+	if bucket.Endpoint == "" {
+		bucket.Endpoint = "https://s3." + bucket.Region + ".amazonaws.com"
+	}
+	return bucket, nil
+}
+
+// S3Bucket talks to an S3-compatible object store with path-style requests
+// signed with AWS Signature Version 4
+type S3Bucket struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client // http.DefaultClient when nil
+}
+
+func (b *S3Bucket) Put(ctx context.Context, key string, body []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp, http.MethodPut, key)
+}
+
+func (b *S3Bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err := checkResponse(resp, http.MethodGet, key); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// List pages through ListObjectsV2, which both S3 and GCS serve
+func (b *S3Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := b.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = checkResponse(resp, http.MethodGet, prefix)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %w", prefix, err)
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (b *S3Bucket) Delete(ctx context.Context, key string) error {
+	resp, err := b.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkResponse(resp, http.MethodDelete, key)
+}
+
+func (b *S3Bucket) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(strings.TrimRight(b.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive endpoint: %w", err)
+	}
+	endpoint.Path += "/" + b.Bucket + "/" + key
+	endpoint.RawPath = uriEncode(endpoint.Path, false)
+	endpoint.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(body)
+	b.sign(req, hex.EncodeToString(digest[:]), time.Now())
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// sign adds the Signature Version 4 authorization header, signing the host
+// and every header already set on req
+func (b *S3Bucket) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := amzDate[:8]
+	scope := date + "/" + b.Region + "/s3/aws4_request"
+	requestDigest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestDigest[:])
+
+	key := hmacSHA256([]byte("AWS4"+b.SecretKey), date)
+	key = hmacSHA256(key, b.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery sorts and encodes query parameters the way SigV4 signs them
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but the RFC 3986 unreserved
+// characters, and "/" unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+// checkResponse turns a non-2xx response into an error carrying the service's error code
+func checkResponse(resp *http.Response, method, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	var failure struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(body, &failure) == nil && failure.Code != "" {
+		return fmt.Errorf("%s %s: %s: %s %s", method, key, resp.Status, failure.Code, failure.Message)
+	}
+	return fmt.Errorf("%s %s: %s", method, key, resp.Status)
+}
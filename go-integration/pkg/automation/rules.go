@@ -0,0 +1,150 @@
+// Package automation maps the platform's application status transitions to
+// gateway actions, so work approved on the platform is released and an
+// application cancelled there is refunded without the platform calling the
+// gateway for each. Rules apply to every tenant unless a tenant has its own
+// rule for the same status.
+package automation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+// Action is what the gateway does when a rule matches
+type Action string
+
+const (
+	Release Action = "release" // pay the freelancer
+	Refund  Action = "refund"  // return the deposit to the client
+	Notify  Action = "notify"  // publish an application.status_changed event
+	None    Action = "none"    // do nothing, e.g. to exempt a tenant from a default rule
+)
+
+// Rule maps a transition into To, optionally only from From, to actions
+type Rule struct {
+	Tenant       string // "" for tenants without a rule of their own for To
+	From         string // "" for any previous status, including one not seen before
+	To           string
+	Actions      []Action
+	RefundReason payment.RefundReason // of a refund action
+}
+
+// Rules are the configured rules
+type Rules []Rule
+
+// ParseRules reads an AUTOMATION_RULES value: comma-separated
+// [tenant:][from>]status=action[+action...] entries, e.g.
+// "approved=release,cancelled=refund:client_cancelled+notify,acme:approved=none".
+// A refund's reason defaults to other.
+func ParseRules(spec string) (Rules, error) {
+	var rules Rules
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		transition, actions, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("automation rule %q is not status=action", part)
+		}
+
+		var rule Rule
+		if tenant, rest, ok := strings.Cut(transition, ":"); ok {
+			rule.Tenant, transition = strings.TrimSpace(tenant), rest
+			if rule.Tenant == "" {
+				return nil, fmt.Errorf("automation rule %q has an empty tenant", part)
+			}
+		}
+		if from, to, ok := strings.Cut(transition, ">"); ok {
+			rule.From, transition = strings.TrimSpace(from), to
+			if rule.From == "" {
+				return nil, fmt.Errorf("automation rule %q has an empty previous status", part)
+			}
+		}
+		rule.To = strings.TrimSpace(transition)
+		if rule.To == "" {
+			return nil, fmt.Errorf("automation rule %q has an empty status", part)
+		}
+
+		for _, name := range strings.Split(actions, "+") {
+			name, reason, hasReason := strings.Cut(strings.TrimSpace(name), ":")
+			action := Action(name)
+			switch action {
+			case Release, Notify, None:
+				if hasReason {
+					return nil, fmt.Errorf("automation rule %q: only refund takes a reason", part)
+				}
+			case Refund:
+				rule.RefundReason = payment.RefundReasonOther
+				if hasReason {
+					parsed, err := payment.ParseRefundReason(reason)
+					if err != nil {
+						return nil, fmt.Errorf("automation rule %q: %v", part, err)
+					}
+					rule.RefundReason = parsed
+				}
+			default:
+				return nil, fmt.Errorf("automation rule %q: unknown action %q, expected release, refund, notify or none", part, name)
+			}
+			rule.Actions = append(rule.Actions, action)
+		}
+		if rule.has(None) && len(rule.Actions) > 1 {
+			return nil, fmt.Errorf("automation rule %q: none can't be combined with other actions", part)
+		}
+		if rule.has(Release) && rule.has(Refund) {
+			return nil, fmt.Errorf("automation rule %q: a job can't be both released and refunded", part)
+		}
+
+		key := rule.Tenant + "\x00" + rule.From + "\x00" + rule.To
+		if seen[key] {
+			return nil, fmt.Errorf("automation rule %q repeats an earlier rule's transition", part)
+		}
+		seen[key] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r Rule) has(action Action) bool {
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matches(tenant, from, to string) bool {
+	return r.Tenant == tenant && r.To == to && (r.From == "" || r.From == from)
+}
+
+// Match returns the rule for an application of tenant moving from one status
+// to another, and false when none applies. A tenant's own rules are preferred
+// over rules for every tenant, and a rule naming the previous status over one
+// that doesn't. from is "" for an application not seen before, which only
+// rules without a previous status match.
+func (rules Rules) Match(tenant, from, to string) (Rule, bool) {
+	scopes := []string{""}
+	if tenant != "" {
+		scopes = []string{tenant, ""}
+	}
+	for _, scope := range scopes {
+		var general *Rule
+		for i, rule := range rules {
+			if !rule.matches(scope, from, to) {
+				continue
+			}
+			if rule.From != "" {
+				return rule, !rule.has(None)
+			}
+			general = &rules[i]
+		}
+		if general != nil {
+			return *general, !general.has(None)
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,73 @@
+package automation
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/payment"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("approved=release, cancelled=refund:client_cancelled+notify, acme:disputed>cancelled=refund, acme:approved=none")
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if len(rules) != 4 {
+		t.Fatalf("Expected 4 rules, got %+v", rules)
+	}
+	if rule := rules[1]; rule.To != "cancelled" || !slices.Equal(rule.Actions, []Action{Refund, Notify}) || rule.RefundReason != payment.RefundReasonClientCancelled {
+		t.Errorf("Unexpected refund rule %+v", rule)
+	}
+	if rule := rules[2]; rule.Tenant != "acme" || rule.From != "disputed" || rule.RefundReason != payment.RefundReasonOther {
+		t.Errorf("Expected a tenant rule from disputed with the default reason, got %+v", rule)
+	}
+
+	for _, spec := range []string{
+		"approved",
+		"approved=pay",
+		"=release",
+		":approved=release",
+		">approved=release",
+		"approved=release:other",
+		"approved=none+notify",
+		"approved=release+refund",
+		"cancelled=refund:whenever",
+		"approved=release,approved=notify",
+	} {
+		if _, err := ParseRules(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestMatchRules(t *testing.T) {
+	rules, err := ParseRules("approved=release,cancelled=refund,disputed>cancelled=notify,acme:approved=notify,globex:approved=none")
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		tenant, from string
+		to           string
+		actions      []Action
+		matched      bool
+	}{
+		{name: "default rule", from: "in_progress", to: "approved", actions: []Action{Release}, matched: true},
+		{name: "first sight", to: "approved", actions: []Action{Release}, matched: true},
+		{name: "tenant's own rule", tenant: "acme", from: "in_progress", to: "approved", actions: []Action{Notify}, matched: true},
+		{name: "tenant without a rule for the status", tenant: "acme", from: "in_progress", to: "cancelled", actions: []Action{Refund}, matched: true},
+		{name: "tenant exempted", tenant: "globex", from: "in_progress", to: "approved"},
+		{name: "previous status preferred", from: "disputed", to: "cancelled", actions: []Action{Notify}, matched: true},
+		{name: "other previous status", from: "in_progress", to: "cancelled", actions: []Action{Refund}, matched: true},
+		{name: "no rule", from: "approved", to: "in_progress"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, matched := rules.Match(tt.tenant, tt.from, tt.to)
+			if matched != tt.matched || (matched && !slices.Equal(rule.Actions, tt.actions)) {
+				t.Errorf("Match(%q, %q, %q) = %+v, %v; want %v, %v", tt.tenant, tt.from, tt.to, rule, matched, tt.actions, tt.matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Package backup keeps logical backups of the gateway's own tables in an
+// archive bucket, so payment data can be recovered without the platform's
+// database backups. A full backup exports every table in
+// database.BackupTables; an incremental one builds on the latest backup and
+// only exports the rows written since it, plus the tables too small to track
+// that are exported whole every time.
+//
+// Each backup is a directory of NDJSON objects, one per table, and a
+// manifest holding their SHA-256 digests. The manifest is written last, so a
+// backup with a manifest has all its objects. A restore reads the chain of
+// backups an incremental builds on, checks every object against its
+// manifest, folds the rows together and hands them to the database, which
+// checks them again once they are written.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/archive"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// ErrNoBackup is returned when the bucket holds no backup an incremental
+// could build on
+var ErrNoBackup = errors.New("no backup to build on")
+
+// Source exports a consistent snapshot of the gateway's tables
+type Source interface {
+	ExportBackup(ctx context.Context, since *time.Time) (*database.BackupExport, error)
+}
+
+// Target writes a backup's rows back and checks them
+type Target interface {
+	RestoreBackup(ctx context.Context, rows map[string][]json.RawMessage, dryRun bool) (*database.BackupRestoreResult, error)
+}
+
+const prefix = "backups/"
+
+// Manifest describes one backup
+type Manifest struct {
+	ID        string     `json:"id"`
+	Base      string     `json:"base,omitempty"` // the backup an incremental builds on; empty for a full backup
+	CreatedAt time.Time  `json:"created_at"`
+	Since     *time.Time `json:"since,omitempty"` // rows written before this are in the base's chain
+	Cursor    time.Time  `json:"cursor"`          // where an incremental built on this backup starts
+	Tables    []Object   `json:"tables"`
+}
+
+// Object is the export of one table in a backup
+type Object struct {
+	Table  string `json:"table"`
+	Key    string `json:"key"`
+	Full   bool   `json:"full"` // the whole table rather than the rows written since the base
+	Rows   int    `json:"rows"`
+	Bytes  int    `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Incremental reports whether the backup builds on another
+func (m *Manifest) Incremental() bool {
+	return m.Base != ""
+}
+
+// Rows counts the rows of every table in the backup
+func (m *Manifest) Rows() int {
+	total := 0
+	for _, object := range m.Tables {
+		total += object.Rows
+	}
+	return total
+}
+
+func manifestKey(id string) string {
+	return prefix + id + "/manifest.json"
+}
+
+// Create exports the source into a new backup created at now. incremental
+// builds on the latest backup in the bucket and fails with ErrNoBackup when
+// there is none.
+func Create(ctx context.Context, source Source, bucket archive.Bucket, now time.Time, incremental bool) (*Manifest, error) {
+	now = now.UTC().Truncate(time.Second)
+	manifest := &Manifest{ID: now.Format("20060102T150405Z"), CreatedAt: now}
+	if incremental {
+		base, err := Latest(ctx, bucket)
+		if err != nil {
+			return nil, err
+		}
+		manifest.ID += "-incremental"
+		manifest.Base, manifest.Since = base.ID, &base.Cursor
+	}
+	if _, err := Get(ctx, bucket, manifest.ID); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", manifest.ID)
+	} else if !errors.Is(err, archive.ErrNotFound) {
+		return nil, err
+	}
+
+	export, err := source.ExportBackup(ctx, manifest.Since)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Cursor = export.Cursor.UTC()
+
+	for _, table := range database.BackupTables {
+		object, err := writeObject(ctx, bucket, manifest.ID, table.Name, export.Rows[table.Name])
+		if err != nil {
+			return nil, err
+		}
+		object.Full = manifest.Since == nil || table.Since == ""
+		manifest.Tables = append(manifest.Tables, object)
+	}
+
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding backup manifest: %w", err)
+	}
+	if err := bucket.Put(ctx, manifestKey(manifest.ID), encoded); err != nil {
+		return nil, fmt.Errorf("error writing backup manifest %s: %w", manifest.ID, err)
+	}
+	return manifest, nil
+}
+
+func writeObject(ctx context.Context, bucket archive.Bucket, id, table string, rows []json.RawMessage) (Object, error) {
+	var body bytes.Buffer
+	for _, row := range rows {
+		body.Write(row)
+		body.WriteByte('\n')
+	}
+	digest := sha256.Sum256(body.Bytes())
+	object := Object{
+		Table:  table,
+		Key:    fmt.Sprintf("%s%s/%s.ndjson", prefix, id, table),
+		Rows:   len(rows),
+		Bytes:  body.Len(),
+		SHA256: hex.EncodeToString(digest[:]),
+	}
+	if err := bucket.Put(ctx, object.Key, body.Bytes()); err != nil {
+		return Object{}, fmt.Errorf("error writing backup object %s: %w", object.Key, err)
+	}
+	return object, nil
+}
+
+// Get reads the manifest of a backup. It returns an error wrapping
+// archive.ErrNotFound when there is no such backup.
+func Get(ctx context.Context, bucket archive.Bucket, id string) (*Manifest, error) {
+	body, err := bucket.Get(ctx, manifestKey(id))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("error decoding backup manifest %s: %w", id, err)
+	}
+	return &manifest, nil
+}
+
+// List reads every backup's manifest, oldest first
+func List(ctx context.Context, bucket archive.Bucket) ([]*Manifest, error) {
+	keys, err := bucket.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("error listing backups: %w", err)
+	}
+
+	var manifests []*Manifest
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), "/manifest.json")
+		if !ok || strings.Contains(id, "/") {
+			continue
+		}
+		manifest, err := Get(ctx, bucket, id)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	sort.SliceStable(manifests, func(i, j int) bool { return manifests[i].CreatedAt.Before(manifests[j].CreatedAt) })
+	return manifests, nil
+}
+
+// Latest returns the newest backup, or ErrNoBackup
+func Latest(ctx context.Context, bucket archive.Bucket) (*Manifest, error) {
+	manifests, err := List(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, ErrNoBackup
+	}
+	return manifests[len(manifests)-1], nil
+}
+
+// Chain returns the backups a restore of id reads: the full backup it
+// descends from first, id last
+func Chain(ctx context.Context, bucket archive.Bucket, id string) ([]*Manifest, error) {
+	var chain []*Manifest
+	for next := id; next != ""; {
+		manifest, err := Get(ctx, bucket, next)
+		if errors.Is(err, archive.ErrNotFound) && next != id {
+			return nil, fmt.Errorf("backup %s builds on %s, which is missing", chain[len(chain)-1].ID, next)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(chain, func(seen *Manifest) bool { return seen.ID == manifest.ID }) {
+			return nil, fmt.Errorf("backup %s builds on itself", manifest.ID)
+		}
+		chain = append(chain, manifest)
+		next = manifest.Base
+	}
+	slices.Reverse(chain)
+	return chain, nil
+}
+
+// Read fetches every object of a chain, checks it against its manifest and
+// returns each table's rows as of the last backup. A table exported whole is
+// taken from the last backup that did, and the rows of later incrementals
+// replace the rows with the same key.
+func Read(ctx context.Context, bucket archive.Bucket, chain []*Manifest) (map[string][]json.RawMessage, error) {
+	if len(chain) == 0 || chain[0].Incremental() {
+		return nil, errors.New("a backup chain must start with a full backup")
+	}
+
+	merged := make(map[string][]json.RawMessage, len(database.BackupTables))
+	for _, table := range database.BackupTables {
+		var rows []json.RawMessage
+		index := make(map[string]int)
+		for _, manifest := range chain {
+			object, ok := manifest.object(table.Name)
+			if !ok {
+				return nil, fmt.Errorf("backup %s has no %s", manifest.ID, table.Name)
+			}
+			read, err := readObject(ctx, bucket, object)
+			if err != nil {
+				return nil, err
+			}
+			if object.Full {
+				rows, index = nil, make(map[string]int, len(read))
+			}
+			for _, row := range read {
+				key, err := database.BackupRowKey(table, row)
+				if err != nil {
+					return nil, fmt.Errorf("backup object %s: %w", object.Key, err)
+				}
+				if i, ok := index[key]; ok {
+					rows[i] = row
+					continue
+				}
+				index[key] = len(rows)
+				rows = append(rows, row)
+			}
+		}
+		merged[table.Name] = rows
+	}
+	return merged, nil
+}
+
+func (m *Manifest) object(table string) (Object, bool) {
+	for _, object := range m.Tables {
+		if object.Table == table {
+			return object, true
+		}
+	}
+	return Object{}, false
+}
+
+// readObject fetches an object and checks its digest and row count
+func readObject(ctx context.Context, bucket archive.Bucket, object Object) ([]json.RawMessage, error) {
+	body, err := bucket.Get(ctx, object.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup object %s: %w", object.Key, err)
+	}
+	if digest := sha256.Sum256(body); hex.EncodeToString(digest[:]) != object.SHA256 {
+		return nil, fmt.Errorf("backup object %s does not match its manifest's sha256", object.Key)
+	}
+
+	rows := make([]json.RawMessage, 0, object.Rows)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		if !json.Valid(scanner.Bytes()) {
+			return nil, fmt.Errorf("row %d of %s is not JSON", len(rows)+1, object.Key)
+		}
+		rows = append(rows, json.RawMessage(bytes.Clone(scanner.Bytes())))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading backup object %s: %w", object.Key, err)
+	}
+	if len(rows) != object.Rows {
+		return nil, fmt.Errorf("backup object %s has %d rows, its manifest lists %d", object.Key, len(rows), object.Rows)
+	}
+	return rows, nil
+}
+
+// Verify reads the chain of backup id and checks every object without
+// writing anything. It returns the chain and the rows a restore would write
+// per table.
+func Verify(ctx context.Context, bucket archive.Bucket, id string) ([]*Manifest, map[string]int, error) {
+	chain, err := Chain(ctx, bucket, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := Read(ctx, bucket, chain)
+	if err != nil {
+		return chain, nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for table, tableRows := range rows {
+		counts[table] = len(tableRows)
+	}
+	return chain, counts, nil
+}
+
+// Restore writes backup id and the backups it builds on into the target,
+// which checks the result before committing. With dryRun the target rolls
+// back after the check.
+func Restore(ctx context.Context, target Target, bucket archive.Bucket, id string, dryRun bool) ([]*Manifest, *database.BackupRestoreResult, error) {
+	chain, err := Chain(ctx, bucket, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := Read(ctx, bucket, chain)
+	if err != nil {
+		return chain, nil, err
+	}
+	result, err := target.RestoreBackup(ctx, rows, dryRun)
+	if err != nil {
+		return chain, nil, err
+	}
+	return chain, result, nil
+}
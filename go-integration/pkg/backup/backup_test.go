@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/archive"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/database"
+)
+
+// fakeSource serves the next export and records the cursor it was asked for
+type fakeSource struct {
+	export *database.BackupExport
+	since  *time.Time
+}
+
+func (s *fakeSource) ExportBackup(ctx context.Context, since *time.Time) (*database.BackupExport, error) {
+	s.since = since
+	return s.export, nil
+}
+
+type fakeTarget struct {
+	rows map[string][]json.RawMessage
+}
+
+func (t *fakeTarget) RestoreBackup(ctx context.Context, rows map[string][]json.RawMessage, dryRun bool) (*database.BackupRestoreResult, error) {
+	t.rows = rows
+	return &database.BackupRestoreResult{}, nil
+}
+
+func rows(lines ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(lines))
+	for i, line := range lines {
+		out[i] = json.RawMessage(line)
+	}
+	return out
+}
+
+func TestCreateAndRestoreChain(t *testing.T) {
+	ctx := context.Background()
+	bucket := &archive.DirBucket{Root: t.TempDir()}
+	start := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	if _, err := Create(ctx, &fakeSource{}, bucket, start, true); !errors.Is(err, ErrNoBackup) {
+		t.Fatalf("Expected an incremental without a base to fail with ErrNoBackup, got %v", err)
+	}
+
+	source := &fakeSource{export: &database.BackupExport{
+		Cursor: start.Add(-time.Second),
+		Rows: map[string][]json.RawMessage{
+			"payment_events": rows(`{"id":1,"status":"deposit_initiated"}`, `{"id":2,"status":"deposited"}`),
+			"retainers":      rows(`{"id":1,"status":"active"}`),
+			"chain_intents":  rows(`{"id":1,"outcome":null}`, `{"id":2,"outcome":"sent"}`),
+		},
+	}}
+	full, err := Create(ctx, source, bucket, start, false)
+	if err != nil {
+		t.Fatalf("Failed to create the full backup: %v", err)
+	}
+	if source.since != nil || full.Incremental() || full.Rows() != 5 {
+		t.Fatalf("Expected a full backup of 5 rows, got %+v (since %v)", full, source.since)
+	}
+
+	// The incremental brings a new event, an updated retainer and the whole
+	// of chain_intents, which has no cursor and lost a row meanwhile
+	source.export = &database.BackupExport{
+		Cursor: start.Add(time.Hour),
+		Rows: map[string][]json.RawMessage{
+			"payment_events": rows(`{"id":2,"status":"deposited"}`, `{"id":3,"status":"released"}`),
+			"retainers":      rows(`{"id":1,"status":"completed"}`),
+			"chain_intents":  rows(`{"id":2,"outcome":"sent"}`),
+		},
+	}
+	incremental, err := Create(ctx, source, bucket, start.Add(time.Hour), true)
+	if err != nil {
+		t.Fatalf("Failed to create the incremental backup: %v", err)
+	}
+	if incremental.Base != full.ID || source.since == nil || !source.since.Equal(full.Cursor) {
+		t.Fatalf("Expected the incremental to build on %s from its cursor, got base %s since %v", full.ID, incremental.Base, source.since)
+	}
+	if object, _ := incremental.object("payment_events"); object.Full {
+		t.Error("Expected payment_events to be exported incrementally")
+	}
+	if object, _ := incremental.object("chain_intents"); !object.Full {
+		t.Error("Expected chain_intents to be exported whole")
+	}
+
+	if latest, err := Latest(ctx, bucket); err != nil || latest.ID != incremental.ID {
+		t.Fatalf("Latest = %v, %v; want %s", latest, err, incremental.ID)
+	}
+
+	target := &fakeTarget{}
+	chain, _, err := Restore(ctx, target, bucket, incremental.ID, false)
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if len(chain) != 2 || chain[0].ID != full.ID {
+		t.Fatalf("Expected the chain to start at the full backup, got %v", chain)
+	}
+	expected := map[string]string{
+		"payment_events": `{"id":1,"status":"deposit_initiated"} {"id":2,"status":"deposited"} {"id":3,"status":"released"}`,
+		"retainers":      `{"id":1,"status":"completed"}`,
+		"chain_intents":  `{"id":2,"outcome":"sent"}`,
+		"audit_log":      ``,
+	}
+	for table, want := range expected {
+		var got []string
+		for _, row := range target.rows[table] {
+			got = append(got, string(row))
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s restored as %v, want %s", table, got, want)
+		}
+	}
+}
+
+func TestVerifyDetectsDamage(t *testing.T) {
+	ctx := context.Background()
+	bucket := &archive.DirBucket{Root: t.TempDir()}
+	start := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	source := &fakeSource{export: &database.BackupExport{
+		Cursor: start,
+		Rows:   map[string][]json.RawMessage{"audit_log": rows(`{"id":1,"action":"review.approve"}`)},
+	}}
+	full, err := Create(ctx, source, bucket, start, false)
+	if err != nil {
+		t.Fatalf("Failed to create the backup: %v", err)
+	}
+	incremental, err := Create(ctx, source, bucket, start.Add(time.Minute), true)
+	if err != nil {
+		t.Fatalf("Failed to create the incremental backup: %v", err)
+	}
+
+	if _, counts, err := Verify(ctx, bucket, incremental.ID); err != nil || counts["audit_log"] != 1 {
+		t.Fatalf("Verify = %v, %v; want one audit entry", counts, err)
+	}
+	if _, err := Create(ctx, source, bucket, start, false); err == nil {
+		t.Error("Expected a second backup in the same second to be refused")
+	}
+
+	object, _ := full.object("audit_log")
+	bucket.Put(ctx, object.Key, []byte(`{"id":1,"action":"review.reject"}`+"\n"))
+	if _, _, err := Verify(ctx, bucket, incremental.ID); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("Expected a modified object to fail verification, got %v", err)
+	}
+
+	bucket.Delete(ctx, manifestKey(full.ID))
+	if _, _, err := Verify(ctx, bucket, incremental.ID); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected a missing base to fail verification, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Package cache holds short-lived copies of hot database reads.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// TTL is a read-through cache whose entries expire after a fixed duration.
+// Invalidate drops an entry immediately; a load that was already in flight
+// when an invalidation happened is returned to its caller but not stored, so
+// a write can never be shadowed by the read it raced with.
+type TTL[K comparable, V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu          sync.Mutex
+	entries     map[K]ttlEntry[V]
+	generation  uint64 // bumped by every invalidation
+	nextSweepAt time.Time
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// NewTTL creates a cache. A ttl of 0 or less disables caching and every Get loads.
+func NewTTL[K comparable, V any](ttl time.Duration) *TTL[K, V] {
+	return &TTL[K, V]{ttl: ttl, now: time.Now, entries: make(map[K]ttlEntry[V])}
+}
+
+// Get returns the cached value for key, or calls load and caches its result.
+// Errors are never cached.
+func (c *TTL[K, V]) Get(key K, load func() (V, error)) (V, error) {
+	if c.ttl <= 0 {
+		return load()
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return entry.value, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		now = c.now()
+		c.sweep(now)
+		c.entries[key] = ttlEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+	}
+	return value, nil
+}
+
+// Invalidate drops key so the next Get loads it again
+func (c *TTL[K, V]) Invalidate(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	c.generation++
+}
+
+// Len returns the number of entries held, including expired ones not yet swept
+func (c *TTL[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// sweep removes expired entries at most once per ttl. c.mu must be held.
+func (c *TTL[K, V]) sweep(now time.Time) {
+	if now.Before(c.nextSweepAt) {
+		return
+	}
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.nextSweepAt = now.Add(c.ttl)
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+func newTestCache(ttl time.Duration) (*TTL[int, string], *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	c := NewTTL[int, string](ttl)
+	c.now = clock.now
+	return c, clock
+}
+
+func countingLoad(calls *int, value string) func() (string, error) {
+	return func() (string, error) {
+		*calls++
+		return value, nil
+	}
+}
+
+func TestTTLReadThrough(t *testing.T) {
+	c, clock := newTestCache(2 * time.Second)
+	calls := 0
+
+	for i := 0; i < 3; i++ {
+		v, err := c.Get(1, countingLoad(&calls, "a"))
+		if err != nil || v != "a" {
+			t.Fatalf("Expected a, got %q (%v)", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 load within the TTL, got %d", calls)
+	}
+
+	clock.t = clock.t.Add(2 * time.Second)
+	if v, _ := c.Get(1, countingLoad(&calls, "b")); v != "b" {
+		t.Errorf("Expected expired entry to reload as b, got %q", v)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 loads after expiry, got %d", calls)
+	}
+}
+
+func TestTTLInvalidate(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+	calls := 0
+
+	c.Get(1, countingLoad(&calls, "a"))
+	c.Invalidate(1)
+	if v, _ := c.Get(1, countingLoad(&calls, "b")); v != "b" {
+		t.Errorf("Expected invalidated entry to reload as b, got %q", v)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 loads, got %d", calls)
+	}
+}
+
+func TestTTLInvalidateDuringLoad(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+	calls := 0
+
+	// A write lands while the read for the old value is still in flight
+	v, _ := c.Get(1, func() (string, error) {
+		c.Invalidate(1)
+		return "stale", nil
+	})
+	if v != "stale" {
+		t.Errorf("Expected the in-flight load to be returned, got %q", v)
+	}
+	if v, _ := c.Get(1, countingLoad(&calls, "fresh")); v != "fresh" {
+		t.Errorf("Expected racing load not to be cached, got %q", v)
+	}
+}
+
+func TestTTLErrorsNotCached(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+	calls := 0
+
+	_, err := c.Get(1, func() (string, error) { return "", errors.New("down") })
+	if err == nil {
+		t.Fatal("Expected load error to be returned")
+	}
+	c.Get(1, countingLoad(&calls, "a"))
+	if calls != 1 {
+		t.Errorf("Expected reload after an error, got %d loads", calls)
+	}
+}
+
+func TestTTLDisabled(t *testing.T) {
+	c, _ := newTestCache(0)
+	calls := 0
+
+	c.Get(1, countingLoad(&calls, "a"))
+	c.Get(1, countingLoad(&calls, "a"))
+	if calls != 2 {
+		t.Errorf("Expected every Get to load when disabled, got %d", calls)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected nothing cached when disabled, got %d", c.Len())
+	}
+}
+
+func TestTTLSweepsExpired(t *testing.T) {
+	c, clock := newTestCache(time.Second)
+	calls := 0
+
+	for key := 0; key < 5; key++ {
+		c.Get(key, countingLoad(&calls, "a"))
+	}
+	clock.t = clock.t.Add(time.Second)
+	c.Get(99, countingLoad(&calls, "a"))
+	if c.Len() != 1 {
+		t.Errorf("Expected expired entries to be swept, got %d entries", c.Len())
+	}
+}
@@ -0,0 +1,50 @@
+// Package calldata encodes the EthJobEscrow calls the gateway sends, and
+// pins that encoding with golden vectors. The vectors are checked against the
+// compiled ABI, the generated bindings and a hand-rolled encoder, so an ABI
+// or binding regeneration that changes the bytes on the wire fails tests
+// instead of producing reverting transactions.
+package calldata
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+)
+
+// Contract methods the gateway calls
+const (
+	MethodPostJob          = "postJob"
+	MethodMarkJobCompleted = "markJobCompleted"
+	MethodCancelJob        = "cancelJob"
+)
+
+// Pack encodes a call of method with args using the compiled ABI
+func Pack(method string, args ...interface{}) ([]byte, error) {
+	parsed, err := contracts.EthJobEscrowMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	data, err := parsed.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s call: %w", method, err)
+	}
+	return data, nil
+}
+
+// PostJob encodes postJob(jobId, freelancer, usdAmount, client)
+func PostJob(jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address) ([]byte, error) {
+	return Pack(MethodPostJob, new(big.Int).SetUint64(jobID), freelancer, usdAmount, client)
+}
+
+// MarkJobCompleted encodes markJobCompleted(jobId)
+func MarkJobCompleted(jobID uint64) ([]byte, error) {
+	return Pack(MethodMarkJobCompleted, new(big.Int).SetUint64(jobID))
+}
+
+// CancelJob encodes cancelJob(jobId)
+func CancelJob(jobID uint64) ([]byte, error) {
+	return Pack(MethodCancelJob, new(big.Int).SetUint64(jobID))
+}
@@ -0,0 +1,200 @@
+package calldata
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/vectors.golden.json")
+
+var goldenPath = filepath.Join("testdata", "vectors.golden.json")
+
+// golden reads the expected calldata of each vector, or rewrites it with -update
+func golden(t *testing.T) map[string]string {
+	t.Helper()
+	if *update {
+		expected := make(map[string]string, len(Vectors))
+		for _, v := range Vectors {
+			data, err := Pack(v.Method, v.Args...)
+			if err != nil {
+				t.Fatalf("%s: %v", v.Name, err)
+			}
+			expected[v.Name] = "0x" + hex.EncodeToString(data)
+		}
+		body, err := json.MarshalIndent(expected, "", "  ")
+		if err != nil {
+			t.Fatalf("Failed to encode golden file: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, append(body, '\n'), 0o644); err != nil {
+			t.Fatalf("Failed to write golden file: %v", err)
+		}
+		return expected
+	}
+
+	body, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+	}
+	var expected map[string]string
+	if err := json.Unmarshal(body, &expected); err != nil {
+		t.Fatalf("Failed to decode golden file: %v", err)
+	}
+	if len(expected) != len(Vectors) {
+		t.Errorf("Golden file has %d vectors, expected %d (run with -update after adding one)", len(expected), len(Vectors))
+	}
+	return expected
+}
+
+func expectedCalldata(t *testing.T, expected map[string]string, v Vector) []byte {
+	t.Helper()
+	want, ok := expected[v.Name]
+	if !ok {
+		t.Fatalf("No golden calldata for %s", v.Name)
+	}
+	return common.FromHex(want)
+}
+
+func TestVectorsMatchABI(t *testing.T) {
+	expected := golden(t)
+
+	// The bindings embed their own copy of the ABI; the checked-in file is
+	// what they are regenerated from, so both must encode the same
+	file, err := os.Open(filepath.Join("..", "..", "contracts", "EthJobEscrow.abi"))
+	if err != nil {
+		t.Fatalf("Failed to open contract ABI: %v", err)
+	}
+	defer file.Close()
+	checkedIn, err := abi.JSON(file)
+	if err != nil {
+		t.Fatalf("Failed to parse contract ABI: %v", err)
+	}
+
+	for _, v := range Vectors {
+		want := expectedCalldata(t, expected, v)
+		data, err := Pack(v.Method, v.Args...)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s: compiled ABI encodes\n0x%x\nexpected\n0x%x", v.Name, data, want)
+		}
+		data, err = checkedIn.Pack(v.Method, v.Args...)
+		if err != nil {
+			t.Fatalf("%s: contracts/EthJobEscrow.abi: %v", v.Name, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s: contracts/EthJobEscrow.abi encodes\n0x%x\nexpected\n0x%x", v.Name, data, want)
+		}
+	}
+}
+
+// TestVectorsMatchHandEncoding checks the golden bytes against the ABI spec
+// itself: a selector hashed from the signature, then each argument as one
+// 32-byte word. Static types only, which is all the escrow takes.
+func TestVectorsMatchHandEncoding(t *testing.T) {
+	expected := golden(t)
+	for _, v := range Vectors {
+		data := crypto.Keccak256([]byte(v.Signature))[:4]
+		for _, arg := range v.Args {
+			switch value := arg.(type) {
+			case *big.Int:
+				data = append(data, common.LeftPadBytes(value.Bytes(), 32)...)
+			case common.Address:
+				data = append(data, common.LeftPadBytes(value.Bytes(), 32)...)
+			default:
+				t.Fatalf("%s: unexpected argument type %T", v.Name, arg)
+			}
+		}
+		if want := expectedCalldata(t, expected, v); !bytes.Equal(data, want) {
+			t.Errorf("%s: hand encoding gives\n0x%x\nexpected\n0x%x", v.Name, data, want)
+		}
+	}
+}
+
+// unsentTransactor satisfies the bindings without a node; transactions are
+// built with NoSend and every value a node would supply preset
+type unsentTransactor struct {
+	bind.ContractTransactor
+}
+
+func TestVectorsMatchBindings(t *testing.T) {
+	expected := golden(t)
+	escrow, err := contracts.NewEthJobEscrowTransactor(common.HexToAddress("0x1111111111111111111111111111111111111111"), unsentTransactor{})
+	if err != nil {
+		t.Fatalf("Failed to bind contract: %v", err)
+	}
+	opts := &bind.TransactOpts{
+		From:     common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		Nonce:    big.NewInt(0),
+		GasPrice: big.NewInt(1),
+		GasLimit: 300000,
+		NoSend:   true,
+		Signer:   func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) { return tx, nil },
+	}
+
+	for _, v := range Vectors {
+		var (
+			tx  *types.Transaction
+			err error
+		)
+		switch v.Method {
+		case MethodPostJob:
+			tx, err = escrow.PostJob(opts, v.Args[0].(*big.Int), v.Args[1].(common.Address), v.Args[2].(*big.Int), v.Args[3].(common.Address))
+		case MethodMarkJobCompleted:
+			tx, err = escrow.MarkJobCompleted(opts, v.Args[0].(*big.Int))
+		case MethodCancelJob:
+			tx, err = escrow.CancelJob(opts, v.Args[0].(*big.Int))
+		default:
+			t.Fatalf("%s: no binding for %s", v.Name, v.Method)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		if want := expectedCalldata(t, expected, v); !bytes.Equal(tx.Data(), want) {
+			t.Errorf("%s: bindings send\n0x%x\nexpected\n0x%x", v.Name, tx.Data(), want)
+		}
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	expected := golden(t)
+	byName := make(map[string]Vector, len(Vectors))
+	for _, v := range Vectors {
+		byName[v.Name] = v
+	}
+
+	encoded := map[string]func() ([]byte, error){
+		"post_job": func() ([]byte, error) {
+			return PostJob(42, vectorFreelancer, big.NewInt(1250), vectorClient)
+		},
+		"mark_job_completed_retainer_period": func() ([]byte, error) { return MarkJobCompleted(1099511627781) },
+		"cancel_job_max_job_id":              func() ([]byte, error) { return CancelJob(1<<63 - 1) },
+	}
+	for name, encode := range encoded {
+		data, err := encode()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if want := expectedCalldata(t, expected, byName[name]); !bytes.Equal(data, want) {
+			t.Errorf("%s: encoder gives\n0x%x\nexpected\n0x%x", name, data, want)
+		}
+	}
+
+	if _, err := Pack(MethodPostJob, big.NewInt(42)); err == nil {
+		t.Error("Expected an error for missing arguments")
+	}
+}
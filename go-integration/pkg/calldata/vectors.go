@@ -0,0 +1,76 @@
+package calldata
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Vector is one call with known inputs. Its expected calldata is stored in
+// testdata/vectors.golden.json under Name.
+type Vector struct {
+	Name      string
+	Method    string
+	Signature string // canonical signature the selector hashes
+	Args      []interface{}
+}
+
+var (
+	vectorFreelancer = common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	vectorClient     = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	vectorMixedCase  = common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+)
+
+// Vectors covers each method with ordinary and boundary inputs: the
+// retainer period job IDs above 2^32, the largest job ID PostJob can take,
+// and zero amounts the contract rejects but the encoding must still carry.
+var Vectors = []Vector{
+	{
+		Name:      "post_job",
+		Method:    MethodPostJob,
+		Signature: "postJob(uint256,address,uint256,address)",
+		Args:      []interface{}{big.NewInt(42), vectorFreelancer, big.NewInt(1250), vectorClient},
+	},
+	{
+		Name:      "post_job_retainer_period",
+		Method:    MethodPostJob,
+		Signature: "postJob(uint256,address,uint256,address)",
+		Args:      []interface{}{big.NewInt(1099511627781), vectorMixedCase, big.NewInt(500), vectorClient},
+	},
+	{
+		Name:      "post_job_max_job_id",
+		Method:    MethodPostJob,
+		Signature: "postJob(uint256,address,uint256,address)",
+		Args:      []interface{}{new(big.Int).SetUint64(1<<63 - 1), vectorFreelancer, big.NewInt(2147483647), vectorClient},
+	},
+	{
+		Name:      "post_job_zero",
+		Method:    MethodPostJob,
+		Signature: "postJob(uint256,address,uint256,address)",
+		Args:      []interface{}{big.NewInt(0), common.Address{}, big.NewInt(0), common.Address{}},
+	},
+	{
+		Name:      "mark_job_completed",
+		Method:    MethodMarkJobCompleted,
+		Signature: "markJobCompleted(uint256)",
+		Args:      []interface{}{big.NewInt(42)},
+	},
+	{
+		Name:      "mark_job_completed_retainer_period",
+		Method:    MethodMarkJobCompleted,
+		Signature: "markJobCompleted(uint256)",
+		Args:      []interface{}{big.NewInt(1099511627781)},
+	},
+	{
+		Name:      "cancel_job",
+		Method:    MethodCancelJob,
+		Signature: "cancelJob(uint256)",
+		Args:      []interface{}{big.NewInt(42)},
+	},
+	{
+		Name:      "cancel_job_max_job_id",
+		Method:    MethodCancelJob,
+		Signature: "cancelJob(uint256)",
+		Args:      []interface{}{new(big.Int).SetUint64(1<<63 - 1)},
+	},
+}
@@ -0,0 +1,142 @@
+// Package chaos injects faults at configurable rates so the platform can test
+// how its integration behaves when the gateway degrades. The gateway only
+// builds an Injector when FAULT_INJECTION is enabled; production code paths
+// are wrapped, never modified.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrInjected marks every error produced by an Injector
+var ErrInjected = errors.New("injected fault")
+
+// Fault is a kind of injected failure
+type Fault string
+
+const (
+	FaultRPCTimeout     Fault = "rpc_timeout"
+	FaultReceiptDelay   Fault = "receipt_delay"
+	FaultDBError        Fault = "db_error"
+	FaultWebhookFailure Fault = "webhook_failure"
+)
+
+// Rates are the probabilities, from 0 to 1, that each fault is injected into
+// a single call
+type Rates struct {
+	RPCTimeout     float64
+	ReceiptDelay   float64 // a mined receipt is reported as still pending
+	DBError        float64
+	WebhookFailure float64
+}
+
+// Validate reports a rate outside 0..1
+func (r Rates) Validate() error {
+	for fault, rate := range r.byFault() {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate %v must be between 0 and 1", fault, rate)
+		}
+	}
+	return nil
+}
+
+func (r Rates) byFault() map[Fault]float64 {
+	return map[Fault]float64{
+		FaultRPCTimeout:     r.RPCTimeout,
+		FaultReceiptDelay:   r.ReceiptDelay,
+		FaultDBError:        r.DBError,
+		FaultWebhookFailure: r.WebhookFailure,
+	}
+}
+
+// Injector decides, call by call, whether to inject a fault
+type Injector struct {
+	rates map[Fault]float64
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	counts map[Fault]int64
+}
+
+// NewInjector creates an injector. A non-zero seed makes the sequence of
+// injected faults repeatable; zero seeds from the clock.
+func NewInjector(rates Rates, seed uint64) (*Injector, error) {
+	if err := rates.Validate(); err != nil {
+		return nil, err
+	}
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	return &Injector{
+		rates:  rates.byFault(),
+		rng:    rand.New(rand.NewPCG(seed, seed)),
+		counts: make(map[Fault]int64),
+	}, nil
+}
+
+// Roll reports whether to inject fault into this call, counting and logging it if so
+func (i *Injector) Roll(fault Fault, call string) bool {
+	rate := i.rates[fault]
+	if rate <= 0 {
+		return false
+	}
+
+	i.mu.Lock()
+	hit := i.rng.Float64() < rate
+	if hit {
+		i.counts[fault]++
+	}
+	i.mu.Unlock()
+
+	if hit {
+		log.Printf("Fault injection: %s in %s", fault, call)
+	}
+	return hit
+}
+
+// RPCTimeout returns an error that looks like a timed-out RPC call, so it is
+// classified as retryable and reported as 504, or nil
+func (i *Injector) RPCTimeout(call string) error {
+	if !i.Roll(FaultRPCTimeout, call) {
+		return nil
+	}
+	return fmt.Errorf("%s: %w: %w", call, ErrInjected, context.DeadlineExceeded)
+}
+
+// DelayReceipt reports whether to hide a mined receipt for this poll
+func (i *Injector) DelayReceipt(call string) bool {
+	return i.Roll(FaultReceiptDelay, call)
+}
+
+// DBError returns an injected database error, or nil
+func (i *Injector) DBError(call string) error {
+	if !i.Roll(FaultDBError, call) {
+		return nil
+	}
+	return fmt.Errorf("error in %s: %w", call, ErrInjected)
+}
+
+// WebhookFailure returns an error that looks like a failing webhook endpoint, or nil
+func (i *Injector) WebhookFailure(call string) error {
+	if !i.Roll(FaultWebhookFailure, call) {
+		return nil
+	}
+	return fmt.Errorf("webhook endpoint returned status 503: %w", ErrInjected)
+}
+
+// Counts returns how many faults of each kind have been injected
+func (i *Injector) Counts() map[Fault]int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	counts := make(map[Fault]int64, len(i.counts))
+	for fault, n := range i.counts {
+		counts[fault] = n
+	}
+	return counts
+}
@@ -0,0 +1,70 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestInjectorRates(t *testing.T) {
+	injector, err := NewInjector(Rates{RPCTimeout: 1, DBError: 0.5}, 42)
+	if err != nil {
+		t.Fatalf("Failed to create injector: %v", err)
+	}
+
+	for range 1000 {
+		if err := injector.RPCTimeout("PostJob"); err == nil {
+			t.Fatal("Expected every RPC call to time out at rate 1")
+		}
+		injector.DBError("ApplyStatusChange")
+		if injector.WebhookFailure("Notify") != nil {
+			t.Fatal("Expected no webhook failures at rate 0")
+		}
+	}
+
+	counts := injector.Counts()
+	if counts[FaultRPCTimeout] != 1000 {
+		t.Errorf("Expected 1000 RPC timeouts, got %d", counts[FaultRPCTimeout])
+	}
+	if n := counts[FaultDBError]; n < 400 || n > 600 {
+		t.Errorf("Expected about 500 DB errors at rate 0.5, got %d", n)
+	}
+	if counts[FaultWebhookFailure] != 0 {
+		t.Errorf("Expected no webhook failures, got %d", counts[FaultWebhookFailure])
+	}
+}
+
+func TestInjectorSeedIsRepeatable(t *testing.T) {
+	a, _ := NewInjector(Rates{DBError: 0.3}, 7)
+	b, _ := NewInjector(Rates{DBError: 0.3}, 7)
+
+	for i := range 100 {
+		if (a.DBError("x") == nil) != (b.DBError("x") == nil) {
+			t.Fatalf("Expected identical fault sequences for the same seed, diverged at call %d", i)
+		}
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	injector, _ := NewInjector(Rates{RPCTimeout: 1, DBError: 1, WebhookFailure: 1}, 1)
+
+	rpcErr := injector.RPCTimeout("GetJobDetails")
+	if !errors.Is(rpcErr, ErrInjected) || !errors.Is(rpcErr, context.DeadlineExceeded) {
+		t.Errorf("Expected an injected deadline error, got %v", rpcErr)
+	}
+	if err := injector.DBError("GetPaymentEvents"); !errors.Is(err, ErrInjected) {
+		t.Errorf("Expected an injected DB error, got %v", err)
+	}
+	if err := injector.WebhookFailure("Notify"); !errors.Is(err, ErrInjected) {
+		t.Errorf("Expected an injected webhook error, got %v", err)
+	}
+}
+
+func TestRatesValidate(t *testing.T) {
+	if _, err := NewInjector(Rates{DBError: 1.5}, 1); err == nil {
+		t.Error("Expected an error for a rate above 1")
+	}
+	if _, err := NewInjector(Rates{RPCTimeout: -0.1}, 1); err == nil {
+		t.Error("Expected an error for a negative rate")
+	}
+}
@@ -0,0 +1,113 @@
+// Package clientlimit holds the risk limits configured for individual
+// clients, identified by their wallet or by the tenant the platform sends
+// requests for: how much USD they may hold in open escrows at once and how
+// much a single escrow may be. Unlike velocity limits, which apply to every
+// client alike, these are set per client, typically to cap new marketplace
+// customers until they have a track record.
+package clientlimit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/address"
+)
+
+// Scope is what a limit's subject identifies
+type Scope string
+
+const (
+	Wallet Scope = "wallet" // the client's wallet address
+	Tenant Scope = "tenant" // the X-Tenant-ID the escrow was posted for
+)
+
+// Rule names one of a limit's maximums
+type Rule string
+
+const (
+	MaxOpenUSD        Rule = "max_open_usd"        // USD held in open escrows, including the one being posted
+	MaxTransactionUSD Rule = "max_transaction_usd" // USD of a single escrow
+)
+
+// maxTenantLength matches the tenant columns
+const maxTenantLength = 100
+
+// ParseScope validates a scope name
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(s); scope {
+	case Wallet, Tenant:
+		return scope, nil
+	}
+	return "", fmt.Errorf("unknown client limit scope %q, expected wallet or tenant", s)
+}
+
+// NormalizeSubject validates a subject for its scope, returning wallets
+// checksummed so the same wallet always has the same limit
+func NormalizeSubject(scope Scope, subject string) (string, error) {
+	subject = strings.TrimSpace(subject)
+	switch scope {
+	case Wallet:
+		wallet, err := address.Canonical(subject)
+		if err != nil {
+			return "", fmt.Errorf("invalid wallet address: %w", err)
+		}
+		return wallet, nil
+	case Tenant:
+		if subject == "" || len(subject) > maxTenantLength {
+			return "", fmt.Errorf("tenant must be 1 to %d characters", maxTenantLength)
+		}
+		return subject, nil
+	}
+	return "", fmt.Errorf("unknown client limit scope %q", scope)
+}
+
+// Limit is the maximums configured for one client. A nil maximum is not
+// checked.
+type Limit struct {
+	Scope             Scope  `json:"scope"`
+	Subject           string `json:"subject"`
+	MaxOpenUSD        *int64 `json:"max_open_usd"`
+	MaxTransactionUSD *int64 `json:"max_transaction_usd"`
+}
+
+// Violation is a maximum an escrow would exceed
+type Violation struct {
+	Scope   Scope  `json:"scope"`
+	Subject string `json:"subject"`
+	Rule    Rule   `json:"rule"`
+	Detail  string `json:"detail"`
+}
+
+// Check returns the maximums escrowing usdAmount would exceed for a client
+// that already holds openUSD in open escrows
+func (l Limit) Check(openUSD, usdAmount int64) []Violation {
+	var violations []Violation
+	if l.MaxTransactionUSD != nil && usdAmount > *l.MaxTransactionUSD {
+		violations = append(violations, Violation{
+			Scope:   l.Scope,
+			Subject: l.Subject,
+			Rule:    MaxTransactionUSD,
+			Detail:  fmt.Sprintf("$%d escrow exceeds the $%d per-transaction limit of %s %s", usdAmount, *l.MaxTransactionUSD, l.Scope, l.Subject),
+		})
+	}
+	if l.MaxOpenUSD != nil && openUSD+usdAmount > *l.MaxOpenUSD {
+		violations = append(violations, Violation{
+			Scope:   l.Scope,
+			Subject: l.Subject,
+			Rule:    MaxOpenUSD,
+			Detail:  fmt.Sprintf("%s %s would hold $%d in open escrows, limit is $%d", l.Scope, l.Subject, openUSD+usdAmount, *l.MaxOpenUSD),
+		})
+	}
+	return violations
+}
+
+// RemainingUSD is how much more a client holding openUSD may escrow before
+// reaching MaxOpenUSD, never below zero, or nil when open escrows are not
+// limited
+func (l Limit) RemainingUSD(openUSD int64) *int64 {
+	if l.MaxOpenUSD == nil {
+		return nil
+	}
+	remaining := max(*l.MaxOpenUSD-openUSD, 0)
+	return &remaining
+}
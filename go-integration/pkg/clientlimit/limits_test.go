@@ -0,0 +1,60 @@
+package clientlimit
+
+import "testing"
+
+func int64Ptr(n int64) *int64 { return &n }
+
+func TestNormalizeSubject(t *testing.T) {
+	wallet, err := NormalizeSubject(Wallet, " 0x00000000000000000000000000000000000000c1 ")
+	if err != nil || wallet != "0x00000000000000000000000000000000000000C1" {
+		t.Errorf("Expected a checksummed wallet, got %q, %v", wallet, err)
+	}
+	if tenant, err := NormalizeSubject(Tenant, "acme"); err != nil || tenant != "acme" {
+		t.Errorf("Expected the tenant unchanged, got %q, %v", tenant, err)
+	}
+
+	for _, c := range []struct {
+		scope   Scope
+		subject string
+	}{{Wallet, "0xc1"}, {Tenant, " "}, {"user", "7"}} {
+		if _, err := NormalizeSubject(c.scope, c.subject); err == nil {
+			t.Errorf("Expected an error for %s %q", c.scope, c.subject)
+		}
+	}
+	if _, err := ParseScope("user"); err == nil {
+		t.Error("Expected an unknown scope to be rejected")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	limit := Limit{Scope: Tenant, Subject: "acme", MaxOpenUSD: int64Ptr(1000), MaxTransactionUSD: int64Ptr(400)}
+
+	if v := limit.Check(600, 400); len(v) != 0 {
+		t.Errorf("Expected no violation at both limits, got %+v", v)
+	}
+
+	v := limit.Check(700, 500)
+	if len(v) != 2 || v[0].Rule != MaxTransactionUSD || v[1].Rule != MaxOpenUSD {
+		t.Fatalf("Expected both limits exceeded, got %+v", v)
+	}
+	if v[1].Detail != "tenant acme would hold $1200 in open escrows, limit is $1000" {
+		t.Errorf("Unexpected detail %q", v[1].Detail)
+	}
+
+	if v := (Limit{Scope: Tenant, Subject: "acme"}).Check(1e9, 1e9); len(v) != 0 {
+		t.Errorf("Expected unset maximums not to be checked, got %+v", v)
+	}
+}
+
+func TestRemainingUSD(t *testing.T) {
+	limit := Limit{MaxOpenUSD: int64Ptr(1000)}
+	if remaining := limit.RemainingUSD(250); remaining == nil || *remaining != 750 {
+		t.Errorf("Expected $750 remaining, got %v", remaining)
+	}
+	if remaining := limit.RemainingUSD(1200); remaining == nil || *remaining != 0 {
+		t.Errorf("Expected nothing remaining over the limit, got %v", remaining)
+	}
+	if (Limit{}).RemainingUSD(1200) != nil {
+		t.Error("Expected no remaining amount without an open limit")
+	}
+}
@@ -0,0 +1,127 @@
+// Package contractupdate verifies signed requests to move the gateway onto a
+// redeployed escrow contract. An update names the network, the new contract
+// address and its ABI, and is signed with EIP-191 personal_sign by an offline
+// key whose address the gateway is configured with, so a leaked admin API
+// credential alone cannot redirect escrows.
+package contractupdate
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignatureHeader carries the 65-byte hex signature of the request body
+const SignatureHeader = "X-Contract-Update-Signature"
+
+var (
+	ErrMissing      = errors.New("contract update is not signed")
+	ErrBadSignature = errors.New("contract update is not signed by the configured signer")
+	ErrWrongNetwork = errors.New("contract update is for another network")
+	ErrStale        = errors.New("contract update is outside the allowed window")
+	ErrSuperseded   = errors.New("contract update is older than the one in effect")
+)
+
+// Update is the signed body of a contract update
+type Update struct {
+	NetworkID       int64           `json:"network_id"`
+	ContractAddress string          `json:"contract_address"`
+	ABI             json.RawMessage `json:"abi"`
+	IssuedAt        time.Time       `json:"issued_at"`
+	Reason          string          `json:"reason"`
+}
+
+// Verifier checks contract updates for one network against one signer
+type Verifier struct {
+	Signer    common.Address
+	NetworkID int64
+	MaxAge    time.Duration // how long after IssuedAt an update is accepted
+}
+
+// Verify checks that body is signed by the verifier's signer and decodes it.
+// The update must target the verifier's network, carry a valid contract
+// address, and have been issued within MaxAge of now.
+func (v *Verifier) Verify(body []byte, signature string, now time.Time) (*Update, error) {
+	if err := v.checkSignature(body, signature); err != nil {
+		return nil, err
+	}
+	update, err := decode(body)
+	if err != nil {
+		return nil, err
+	}
+	if update.NetworkID != v.NetworkID {
+		return nil, fmt.Errorf("%w: update is for %d, gateway runs on %d", ErrWrongNetwork, update.NetworkID, v.NetworkID)
+	}
+	if age := now.Sub(update.IssuedAt); age > v.MaxAge || age < -v.MaxAge {
+		return nil, ErrStale
+	}
+	return update, nil
+}
+
+// VerifyStored checks an update the gateway accepted earlier and persisted.
+// Only the signature and network are checked, since a stored update is
+// expected to be older than MaxAge when it is reapplied at startup.
+func (v *Verifier) VerifyStored(body []byte, signature string) (*Update, error) {
+	if err := v.checkSignature(body, signature); err != nil {
+		return nil, err
+	}
+	update, err := decode(body)
+	if err != nil {
+		return nil, err
+	}
+	if update.NetworkID != v.NetworkID {
+		return nil, ErrWrongNetwork
+	}
+	return update, nil
+}
+
+func (v *Verifier) checkSignature(body []byte, signature string) error {
+	if signature == "" {
+		return ErrMissing
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return ErrBadSignature
+	}
+	// personal_sign produces a recovery ID of 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(body), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != v.Signer {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func decode(body []byte) (*Update, error) {
+	var update Update
+	if err := json.Unmarshal(body, &update); err != nil {
+		return nil, fmt.Errorf("invalid contract update: %w", err)
+	}
+	if !common.IsHexAddress(update.ContractAddress) {
+		return nil, fmt.Errorf("invalid contract update: contract_address %q is not an address", update.ContractAddress)
+	}
+	if len(update.ABI) == 0 {
+		return nil, errors.New("invalid contract update: abi is required")
+	}
+	return &update, nil
+}
+
+// Sign returns the personal_sign signature of body, as `cast wallet sign`
+// would produce it
+func Sign(body []byte, key *ecdsa.PrivateKey) (string, error) {
+	sig, err := crypto.Sign(accounts.TextHash(body), key)
+	if err != nil {
+		return "", err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(sig), nil
+}
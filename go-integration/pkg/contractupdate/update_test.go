@@ -0,0 +1,68 @@
+package contractupdate
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVerify(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	verifier := &Verifier{Signer: crypto.PubkeyToAddress(key.PublicKey), NetworkID: 11155111, MaxAge: 15 * time.Minute}
+	now := time.Unix(1700000000, 0).UTC()
+
+	body := func(networkID int64, issuedAt time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"network_id":%d,"contract_address":"0x00000000000000000000000000000000000000e2","abi":[],"issued_at":%q,"reason":"patch"}`,
+			networkID, issuedAt.Format(time.RFC3339)))
+	}
+	sign := func(body []byte, signer *ecdsa.PrivateKey) string {
+		sig, err := Sign(body, signer)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return sig
+	}
+
+	valid := body(11155111, now.Add(-time.Minute))
+	update, err := verifier.Verify(valid, sign(valid, key), now)
+	if err != nil {
+		t.Fatalf("Expected a valid update, got %v", err)
+	}
+	if update.ContractAddress != "0x00000000000000000000000000000000000000e2" || update.Reason != "patch" {
+		t.Errorf("Unexpected update %+v", update)
+	}
+
+	tampered := body(11155111, now)
+	stale := body(11155111, now.Add(-time.Hour))
+	wrongNetwork := body(1, now)
+	cases := []struct {
+		name      string
+		body      []byte
+		signature string
+		expected  error
+	}{
+		{"unsigned", valid, "", ErrMissing},
+		{"other signer", valid, sign(valid, other), ErrBadSignature},
+		{"body changed after signing", tampered, sign(valid, key), ErrBadSignature},
+		{"garbage signature", valid, "0x1234", ErrBadSignature},
+		{"stale", stale, sign(stale, key), ErrStale},
+		{"other network", wrongNetwork, sign(wrongNetwork, key), ErrWrongNetwork},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifier.Verify(tt.body, tt.signature, now); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	// A stored update is reapplied regardless of age
+	if _, err := verifier.VerifyStored(stale, sign(stale, key)); err != nil {
+		t.Errorf("Expected a stored update to verify, got %v", err)
+	}
+}
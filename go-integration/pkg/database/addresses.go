@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Address book roles
+const (
+	RoleClient     = "client"
+	RoleFreelancer = "freelancer"
+)
+
+// AddressBookEntry maps a wallet address to the platform user it belongs to
+type AddressBookEntry struct {
+	Address     string
+	UserID      int32
+	Role        string
+	Label       *string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// DisplayName describes the address's owner, e.g. "freelancer #123 (Alice)"
+func (e *AddressBookEntry) DisplayName() string {
+	name := fmt.Sprintf("%s #%d", e.Role, e.UserID)
+	if e.Label != nil && *e.Label != "" {
+		name += fmt.Sprintf(" (%s)", *e.Label)
+	}
+	return name
+}
+
+// RecordAddress adds an address to the address book the first time it is used
+// and refreshes its owner and last-seen time afterwards. Existing labels are kept.
+func (db *DB) RecordAddress(ctx context.Context, address string, userID int32, role string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO address_book (address, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (address) DO UPDATE
+		SET user_id = EXCLUDED.user_id, role = EXCLUDED.role, last_seen_at = NOW()
+	`
+
+	if _, err := db.Pool.Exec(ctx, query, address, userID, role); err != nil {
+		return fmt.Errorf("error recording address: %w", err)
+	}
+
+	return nil
+}
+
+// GetAddress looks up an address book entry, returning nil if the address is unknown
+func (db *DB) GetAddress(ctx context.Context, address string) (*AddressBookEntry, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT address, user_id, role, label, first_seen_at, last_seen_at
+		FROM address_book
+		WHERE address = $1
+	`
+
+	entry := &AddressBookEntry{}
+	err := db.Pool.QueryRow(ctx, query, address).Scan(
+		&entry.Address,
+		&entry.UserID,
+		&entry.Role,
+		&entry.Label,
+		&entry.FirstSeenAt,
+		&entry.LastSeenAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying address book: %w", err)
+	}
+
+	return entry, nil
+}
+
+// SetAddressLabel sets the human-readable label of a known address
+func (db *DB) SetAddressLabel(ctx context.Context, address string, label string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE address_book SET label = NULLIF($1, '') WHERE address = $2`
+
+	tag, err := db.Pool.Exec(ctx, query, label, address)
+	if err != nil {
+		return false, fmt.Errorf("error updating address label: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ArchivedJob is the history of one settled job as read back from an archive
+type ArchivedJob struct {
+	ApplicationID int32
+	Events        []PaymentEvent
+	Costs         []TransactionCost
+	Ledger        []*LedgerTransaction
+}
+
+// RestoreResult counts the rows a restore inserted; rows still present are not counted
+type RestoreResult struct {
+	Events             int
+	Costs              int
+	LedgerTransactions int
+}
+
+// ListArchivableApplications returns up to limit released or refunded
+// applications not yet archived whose last payment event is before
+// settledBefore, oldest first
+func (db *DB) ListArchivableApplications(ctx context.Context, settledBefore time.Time, limit int) ([]*ApplicationPaymentDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := paymentDetailsQuery + `
+		WHERE a.payment_status IN ('released', 'refunded')
+			AND NOT EXISTS (SELECT 1 FROM archived_applications x WHERE x.application_id = a.id)
+			AND COALESCE((SELECT MAX(e.created_at) FROM payment_events e WHERE e.application_id = a.id), '-infinity') < $1
+		ORDER BY a.id
+		LIMIT $2
+	`
+	rows, err := db.Pool.Query(ctx, query, settledBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing archivable applications: %w", err)
+	}
+	defer rows.Close()
+
+	var applications []*ApplicationPaymentDetails
+	for rows.Next() {
+		details, err := scanPaymentDetails(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning application payment details: %w", err)
+		}
+		applications = append(applications, details)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing archivable applications: %w", err)
+	}
+
+	return applications, nil
+}
+
+// RecordArchivedApplications marks applications as exported in a batch so
+// later runs skip them
+func (db *DB) RecordArchivedApplications(ctx context.Context, batch, objectKey string, applicationIDs []int32) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO archived_applications (application_id, batch, object_key)
+		SELECT id, $2, $3 FROM UNNEST($1::integer[]) AS id
+		ON CONFLICT (application_id) DO NOTHING
+	`
+	if _, err := db.Pool.Exec(ctx, query, applicationIDs, batch, objectKey); err != nil {
+		return fmt.Errorf("error recording archived applications: %w", err)
+	}
+	return nil
+}
+
+// RestoreArchivedJob inserts the events, transaction costs and ledger
+// transactions of an archived job that are missing from the database, in one
+// transaction. Events match on status and time, costs on transaction hash
+// and ledger transactions on kind and reference, so restoring twice is safe.
+func (db *DB) RestoreArchivedJob(ctx context.Context, job ArchivedJob) (*RestoreResult, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result := &RestoreResult{}
+	eventQuery := `
+		INSERT INTO payment_events (application_id, status, tx_hash, block_number, actor, created_at)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE NOT EXISTS (SELECT 1 FROM payment_events WHERE application_id = $1 AND status = $2 AND created_at = $6)
+	`
+	for _, event := range job.Events {
+		tag, err := tx.Exec(ctx, eventQuery, job.ApplicationID, event.Status, event.TxHash, event.BlockNumber, event.Actor, event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error restoring payment event: %w", err)
+		}
+		result.Events += int(tag.RowsAffected())
+	}
+
+	costQuery := `
+		INSERT INTO transaction_costs
+			(application_id, operation, tx_hash, gas_used, effective_gas_price, cost_wei, eth_usd_price, cost_usd, created_at)
+		VALUES ($1, $2, $3, $4, $5::numeric, $6::numeric, $7::numeric, $8::numeric, $9)
+		ON CONFLICT (tx_hash) DO NOTHING
+	`
+	for _, cost := range job.Costs {
+		tag, err := tx.Exec(ctx, costQuery, job.ApplicationID, cost.Operation, cost.TxHash, int64(cost.GasUsed),
+			cost.EffectiveGasPrice, cost.CostWei, cost.ETHUSDPrice, cost.CostUSD, cost.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error restoring transaction cost: %w", err)
+		}
+		result.Costs += int(tag.RowsAffected())
+	}
+
+	if len(job.Ledger) > 0 {
+		// Serialise with postings, which check balances under the same lock
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('ledger'))`); err != nil {
+			return nil, fmt.Errorf("error locking ledger: %w", err)
+		}
+	}
+	ledgerQuery := `
+		INSERT INTO ledger_transactions (kind, reference, application_id, counterparty, memo, actor, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (kind, reference) DO NOTHING
+		RETURNING id
+	`
+	for _, t := range job.Ledger {
+		var id int64
+		err := tx.QueryRow(ctx, ledgerQuery, t.Kind, t.Reference, t.ApplicationID, t.Counterparty, t.Memo, t.Actor, t.CreatedAt).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error restoring ledger transaction: %w", err)
+		}
+		for _, entry := range t.Entries {
+			entryQuery := `INSERT INTO ledger_entries (transaction_id, account, amount_wei) VALUES ($1, $2, $3::numeric)`
+			if _, err := tx.Exec(ctx, entryQuery, id, entry.Account, entry.AmountWei); err != nil {
+				return nil, fmt.Errorf("error restoring ledger entry: %w", err)
+			}
+		}
+		result.LedgerTransactions++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing restore: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// AuditEntry records a manual or corrective change with its before and after state
+type AuditEntry struct {
+	ID            int64
+	Action        string
+	ApplicationID *int32
+	Actor         string
+	Reason        string
+	Before        json.RawMessage
+	After         json.RawMessage
+	CreatedAt     time.Time
+}
+
+// execer is satisfied by both the pool and a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// PaymentRecord is the payment state of an application as stored in the database
+type PaymentRecord struct {
+	PaymentStatus paymentstatus.Status `json:"payment_status"`
+	DepositTxHash *string              `json:"tx_hash_deposit"`
+	ReleaseTxHash *string              `json:"tx_hash_release"`
+	RefundTxHash  *string              `json:"tx_hash_refund"`
+}
+
+// RecordAudit appends an entry to the audit log
+func (db *DB) RecordAudit(ctx context.Context, entry AuditEntry) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return insertAudit(ctx, db.Pool, entry)
+}
+
+// ListAuditLog returns the audit entries for an application, newest first
+func (db *DB) ListAuditLog(ctx context.Context, applicationID int32) ([]AuditEntry, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, action, application_id, actor, reason, before, after, created_at
+		FROM audit_log
+		WHERE application_id = $1
+		ORDER BY id DESC
+	`
+
+	rows, err := db.Pool.Query(ctx, query, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Action, &entry.ApplicationID, &entry.Actor, &entry.Reason, &entry.Before, &entry.After, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// AuditVerification is the result of checking the audit log's hash chain
+type AuditVerification struct {
+	Valid     bool   `json:"valid"`
+	Entries   int64  `json:"entries"`             // chained entries checked
+	HeadSeq   int64  `json:"head_seq"`            // the latest entry, to compare with a copy kept elsewhere
+	HeadHash  string `json:"head_hash,omitempty"` // its hash
+	BrokenAt  *int64 `json:"broken_at,omitempty"` // chain_seq of the first entry that fails
+	Problem   string `json:"problem,omitempty"`
+	Unchained int64  `json:"unchained"` // entries written before the chain, which it doesn't cover
+}
+
+// VerifyAuditLog recomputes every chained entry's hash and checks each links
+// to the one before it with no gap in the sequence
+func (db *DB) VerifyAuditLog(ctx context.Context) (*AuditVerification, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	result := &AuditVerification{Valid: true}
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_log WHERE chain_seq IS NULL`).Scan(&result.Unchained); err != nil {
+		return nil, fmt.Errorf("error counting unchained audit entries: %w", err)
+	}
+
+	query := `
+		SELECT chain_seq, prev_hash, entry_hash,
+			audit_entry_hash(prev_hash, action, application_id, actor, reason, before, after, created_at)
+		FROM audit_log
+		WHERE chain_seq IS NOT NULL
+		ORDER BY chain_seq
+	`
+	rows, err := db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var previousHash *string
+	for rows.Next() {
+		var seq int64
+		var prevHash *string
+		var entryHash, computed string
+		if err := rows.Scan(&seq, &prevHash, &entryHash, &computed); err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %w", err)
+		}
+
+		problem := ""
+		switch {
+		case seq != result.HeadSeq+1:
+			problem = fmt.Sprintf("entries %d to %d are missing", result.HeadSeq+1, seq-1)
+		case (prevHash == nil) != (previousHash == nil) || (prevHash != nil && *prevHash != *previousHash):
+			problem = "entry does not link to the one before it"
+		case computed != entryHash:
+			problem = "entry content does not match its hash"
+		}
+		if problem != "" && result.Valid {
+			result.Valid = false
+			result.BrokenAt = &seq
+			result.Problem = problem
+		}
+
+		result.Entries++
+		result.HeadSeq = seq
+		result.HeadHash = entryHash
+		previousHash = &entryHash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	return result, nil
+}
+
+// OverwritePaymentRecord replaces an application's payment status and
+// transaction hashes from chain state, recording the transition in
+// payment_events and the operator's before/after state in the audit log within
+// one transaction. It returns the record as it was before the change.
+func (db *DB) OverwritePaymentRecord(ctx context.Context, applicationID int32, record PaymentRecord, actor, reason string) (*PaymentRecord, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if !record.PaymentStatus.Valid() {
+		return nil, fmt.Errorf("error overwriting payment record: unknown payment status %q", record.PaymentStatus)
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before := &PaymentRecord{}
+	selectQuery := `
+		SELECT COALESCE(payment_status, 'pending_deposit'), escrow_tx_hash_deposit, escrow_tx_hash_release, escrow_tx_hash_refund
+		FROM applications
+		WHERE id = $1
+		FOR UPDATE
+	`
+	err = tx.QueryRow(ctx, selectQuery, applicationID).Scan(&before.PaymentStatus, &before.DepositTxHash, &before.ReleaseTxHash, &before.RefundTxHash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment record: %w", err)
+	}
+
+	updateQuery := `
+		UPDATE applications
+		SET payment_status = $1, escrow_tx_hash_deposit = $2, escrow_tx_hash_release = $3, escrow_tx_hash_refund = $4
+		WHERE id = $5
+	`
+	if _, err := tx.Exec(ctx, updateQuery, record.PaymentStatus, record.DepositTxHash, record.ReleaseTxHash, record.RefundTxHash, applicationID); err != nil {
+		return nil, fmt.Errorf("error overwriting payment record: %w", err)
+	}
+
+	eventQuery := `
+		INSERT INTO payment_events (application_id, status, actor)
+		VALUES ($1, $2, $3)
+	`
+	if _, err := tx.Exec(ctx, eventQuery, applicationID, record.PaymentStatus, ActorResync); err != nil {
+		return nil, fmt.Errorf("error recording payment event: %w", err)
+	}
+	if record.PaymentStatus == paymentstatus.Released {
+		if err := executeReleaseAuthorization(ctx, tx, applicationID); err != nil {
+			return nil, err
+		}
+	}
+
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(record)
+	entry := AuditEntry{
+		Action:        "payment_record.overwrite",
+		ApplicationID: &applicationID,
+		Actor:         actor,
+		Reason:        reason,
+		Before:        beforeJSON,
+		After:         afterJSON,
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing payment record: %w", err)
+	}
+	db.invalidateDetails(applicationID)
+
+	return before, nil
+}
+
+func insertAudit(ctx context.Context, q execer, entry AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (action, application_id, actor, reason, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	if _, err := q.Exec(ctx, query, entry.Action, entry.ApplicationID, entry.Actor, entry.Reason, entry.Before, entry.After); err != nil {
+		return fmt.Errorf("error recording audit entry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// ApplicationTransition is an application whose platform status has moved
+// since the automation rules last saw it
+type ApplicationTransition struct {
+	ApplicationID  int32
+	Tenant         string // "" when the escrow was posted without a tenant
+	PreviousStatus string // "" for an escrow funded before its status was tracked
+	Status         string
+	PaymentStatus  paymentstatus.Status
+}
+
+// recordStatusSeen records the application status a newly posted escrow
+// starts from, so only later transitions are acted on
+func recordStatusSeen(ctx context.Context, q execer, applicationID int32) error {
+	query := `
+		INSERT INTO application_status_seen (application_id, status)
+		SELECT id, status FROM applications WHERE id = $1 AND status IS NOT NULL
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := q.Exec(ctx, query, applicationID); err != nil {
+		return fmt.Errorf("error recording application status: %w", err)
+	}
+	return nil
+}
+
+// ListApplicationTransitions returns up to limit applications with an escrow
+// whose status differs from the one last recorded by RecordTransition,
+// lowest ID first. Escrows funded before statuses were tracked are included
+// with no previous status while they are still open; once settled they are
+// left alone.
+func (db *DB) ListApplicationTransitions(ctx context.Context, limit int) ([]ApplicationTransition, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT a.id, COALESCE(t.tenant, ''), COALESCE(s.status, ''), a.status,
+			COALESCE(a.payment_status, 'pending_deposit')
+		FROM applications a
+		JOIN escrow_jobs e ON e.application_id = a.id
+		LEFT JOIN escrow_tenants t ON t.application_id = a.id
+		LEFT JOIN application_status_seen s ON s.application_id = a.id
+		WHERE a.status IS NOT NULL
+			AND a.status IS DISTINCT FROM s.status
+			AND (s.status IS NOT NULL OR COALESCE(a.payment_status, 'pending_deposit') NOT IN ('released', 'refunded'))
+		ORDER BY a.id
+		LIMIT $1
+	`
+	rows, err := db.Pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing application transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ApplicationTransition, error) {
+		var t ApplicationTransition
+		err := row.Scan(&t.ApplicationID, &t.Tenant, &t.PreviousStatus, &t.Status, &t.PaymentStatus)
+		return t, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing application transitions: %w", err)
+	}
+	return transitions, nil
+}
+
+// RecordTransition marks an application's move from one status to another
+// as handled. It returns false when the recorded status is no longer from,
+// e.g. another gateway instance handled the transition first, so each
+// transition is acted on once.
+func (db *DB) RecordTransition(ctx context.Context, applicationID int32, from, to string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO application_status_seen (application_id, status)
+		VALUES ($1, $3)
+		ON CONFLICT (application_id) DO UPDATE
+		SET status = EXCLUDED.status, seen_at = NOW()
+		WHERE application_status_seen.status = $2
+	`
+	tag, err := db.Pool.Exec(ctx, query, applicationID, from, to)
+	if err != nil {
+		return false, fmt.Errorf("error recording application transition: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,429 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BackupTable is a gateway-owned table a backup exports. Tables are written
+// back in the order of BackupTables, so a table comes after those it
+// references.
+type BackupTable struct {
+	Name      string   // name in the backup; also the table unless Source is set
+	Source    string   // table read and written when it differs from Name
+	Key       []string // columns identifying a row
+	Columns   []string // columns exported; empty exports every column
+	Since     string   // condition on $1 selecting rows written at or after a cursor; empty exports the whole table every time
+	Skip      []string // columns a restore leaves to the target's defaults
+	Immutable bool     // a trigger refuses updates, so a restore leaves rows already present alone
+	Update    bool     // a restore updates existing rows of Source instead of inserting
+	Triggers  []string // triggers disabled while the table is restored
+}
+
+func (t BackupTable) source() string {
+	if t.Source != "" {
+		return t.Source
+	}
+	return t.Name
+}
+
+// BackupTables lists what a backup holds: the payment columns of
+// applications, and the gateway's payment, event, ledger and audit tables.
+// Tables with an updated_at, or whose rows are never updated, are exported
+// incrementally; the rest are small enough to export whole every time.
+// Configuration such as feature flags and webhooks, and state rebuilt from
+// the chain or by the workers, is left out.
+var BackupTables = []BackupTable{
+	// Payments
+	{
+		Name:    "application_payments",
+		Source:  "applications",
+		Key:     []string{"id"},
+		Columns: []string{"id", "payment_status", "escrow_job_id", "escrow_tx_hash_deposit", "escrow_tx_hash_release", "escrow_tx_hash_refund"},
+		Update:  true,
+	},
+	{Name: "escrow_jobs", Key: []string{"application_id"}, Since: "created_at >= $1"},
+	{Name: "escrow_tenants", Key: []string{"application_id"}},
+	{Name: "payment_refunds", Key: []string{"id"}, Since: "created_at >= $1"},
+	{Name: "transaction_costs", Key: []string{"id"}, Since: "created_at >= $1"},
+	{Name: "deferred_operations", Key: []string{"id"}, Since: "updated_at >= $1"},
+	{Name: "payout_preferences", Key: []string{"freelancer_user_id"}, Since: "updated_at >= $1"},
+	{Name: "payout_holds", Key: []string{"application_id"}},
+	{Name: "fee_overrides", Key: []string{"application_id"}, Since: "updated_at >= $1"},
+	{Name: "retainers", Key: []string{"id"}, Since: "updated_at >= $1"},
+	{Name: "retainer_periods", Key: []string{"id"}, Since: "updated_at >= $1"},
+	{Name: "escrow_top_ups", Key: []string{"id"}, Since: "updated_at >= $1"},
+	{Name: "payment_reviews", Key: []string{"id"}, Since: "updated_at >= $1"},
+	{Name: "release_authorizations", Key: []string{"id"}},
+	{Name: "funding_links", Key: []string{"id"}},
+	{Name: "chain_intents", Key: []string{"id"}},
+	{Name: "kyc_holds", Key: []string{"id"}},
+	{Name: "settlement_summaries", Key: []string{"day"}},
+	{Name: "discovered_escrows", Key: []string{"job_id"}, Since: "updated_at >= $1"},
+	{Name: "archived_applications", Key: []string{"application_id"}, Since: "archived_at >= $1"},
+	{Name: "job_disputes", Key: []string{"id"}},
+	{Name: "dispute_evidence", Key: []string{"id"}, Since: "created_at >= $1", Immutable: true},
+
+	// Events. txid is the writing transaction's ID, meaningless in another
+	// database, so restored events take the restoring transaction's.
+	{Name: "payment_events", Key: []string{"id"}, Since: "created_at >= $1", Skip: []string{"txid"}},
+
+	// Ledger. Entries are written with their transaction.
+	{Name: "ledger_transactions", Key: []string{"id"}, Since: "created_at >= $1"},
+	{Name: "ledger_entries", Key: []string{"id"}, Since: "transaction_id IN (SELECT id FROM ledger_transactions WHERE created_at >= $1)"},
+
+	// Audit. The chain trigger would rehash restored entries, so it is off
+	// while their stored hashes are written back.
+	{Name: "audit_log", Key: []string{"id"}, Since: "created_at >= $1", Immutable: true, Triggers: []string{"audit_log_chain"}},
+	{Name: "contract_updates", Key: []string{"id"}, Since: "created_at >= $1"},
+	{Name: "signed_transactions", Key: []string{"address", "nonce"}},
+	{Name: "signing_halts", Key: []string{"id"}},
+	{Name: "kill_switch_approvals", Key: []string{"id"}},
+}
+
+// LookupBackupTable returns the entry of BackupTables with the given name
+func LookupBackupTable(name string) (BackupTable, bool) {
+	for _, table := range BackupTables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return BackupTable{}, false
+}
+
+// BackupExport is one consistent snapshot of BackupTables
+type BackupExport struct {
+	Cursor time.Time                    // an incremental built on this export reads rows written at or after it
+	Rows   map[string][]json.RawMessage // each table's rows as JSON objects, ordered by key
+}
+
+// ExportBackup reads BackupTables in one repeatable-read transaction, so
+// every table is exported as of the same moment. With since, tables that
+// can be exported incrementally only give the rows written at or after it.
+//
+// Rows are stamped with NOW(), the start of the transaction writing them, so
+// a transaction still open when the snapshot is taken can commit rows older
+// than the snapshot. The cursor is therefore the start of the oldest
+// transaction open beforehand, and the next incremental reads at or after
+// it. That repeats a few rows, which a restore folds together. Open
+// transactions are read from pg_stat_activity, which shows other roles'
+// sessions only to members of pg_read_all_stats.
+//
+// A backup is as long as the caller's context allows rather than
+// DB_QUERY_TIMEOUT.
+func (db *DB) ExportBackup(ctx context.Context, since *time.Time) (*BackupExport, error) {
+	export := &BackupExport{Rows: make(map[string][]json.RawMessage, len(BackupTables))}
+	cursorQuery := `
+		SELECT LEAST(clock_timestamp(), MIN(xact_start))
+		FROM pg_stat_activity
+		WHERE xact_start IS NOT NULL AND pid <> pg_backend_pid()
+	`
+	if err := db.Pool.QueryRow(ctx, cursorQuery).Scan(&export.Cursor); err != nil {
+		return nil, fmt.Errorf("error reading open transactions: %w", err)
+	}
+
+	tx, err := db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	// Timestamps are written in UTC so a restore compares them byte for byte
+	if _, err := tx.Exec(ctx, `SET LOCAL TimeZone = 'UTC'`); err != nil {
+		return nil, fmt.Errorf("error setting time zone: %w", err)
+	}
+
+	for _, table := range BackupTables {
+		rows, err := exportBackupTable(ctx, tx, table, since)
+		if err != nil {
+			return nil, err
+		}
+		export.Rows[table.Name] = rows
+	}
+	return export, nil
+}
+
+func exportBackupTable(ctx context.Context, tx pgx.Tx, table BackupTable, since *time.Time) ([]json.RawMessage, error) {
+	columns := "*"
+	if len(table.Columns) > 0 {
+		columns = strings.Join(table.Columns, ", ")
+	}
+	query := fmt.Sprintf(`SELECT row_to_json(t)::text FROM (SELECT %s FROM %s`, columns, table.source())
+	var args []interface{}
+	if since != nil && table.Since != "" {
+		query += " WHERE " + table.Since
+		args = append(args, *since)
+	}
+	query += fmt.Sprintf(`) t ORDER BY %s`, strings.Join(table.Key, ", "))
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting %s: %w", table.Name, err)
+	}
+	defer rows.Close()
+
+	var exported []json.RawMessage
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return nil, fmt.Errorf("error scanning %s row: %w", table.Name, err)
+		}
+		exported = append(exported, json.RawMessage(row))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error exporting %s: %w", table.Name, err)
+	}
+	return exported, nil
+}
+
+// BackupRestoreResult counts, per table, the rows a restore wrote and the
+// rows the target holds that the backup does not
+type BackupRestoreResult struct {
+	Tables map[string]RestoredTable
+}
+
+// RestoredTable is what a restore did to one table
+type RestoredTable struct {
+	Rows  int `json:"rows"`
+	Extra int `json:"extra"`
+}
+
+// RestoreBackup writes the rows of a backup into the database in one
+// transaction: inserted, or overwriting the row with the same key. The
+// payment columns of applications update rows the platform must already
+// have. Serial sequences are then moved past the restored IDs.
+//
+// Before committing, every table is read back and each backed-up row must be
+// present and equal, or nothing is written. Rows the target holds beyond
+// the backup are counted, not removed. With dryRun the transaction is rolled
+// back after the check, to rehearse a restore.
+func (db *DB) RestoreBackup(ctx context.Context, rows map[string][]json.RawMessage, dryRun bool) (*BackupRestoreResult, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SET LOCAL TimeZone = 'UTC'`); err != nil {
+		return nil, fmt.Errorf("error setting time zone: %w", err)
+	}
+
+	result := &BackupRestoreResult{Tables: make(map[string]RestoredTable)}
+	for _, table := range BackupTables {
+		if len(rows[table.Name]) == 0 {
+			continue
+		}
+		if err := restoreBackupTable(ctx, tx, table, rows[table.Name]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, table := range BackupTables {
+		if len(rows[table.Name]) == 0 {
+			continue
+		}
+		restored, err := verifyBackupTable(ctx, tx, table, rows[table.Name])
+		if err != nil {
+			return nil, err
+		}
+		result.Tables[table.Name] = restored
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing restore: %w", err)
+	}
+	return result, nil
+}
+
+func restoreBackupTable(ctx context.Context, tx pgx.Tx, table BackupTable, rows []json.RawMessage) error {
+	source := table.source()
+	columns := table.Columns
+	if len(columns) == 0 {
+		var err error
+		if columns, err = tableColumns(ctx, tx, source); err != nil {
+			return err
+		}
+	}
+	var written, updated []string
+	for _, column := range columns {
+		if slices.Contains(table.Skip, column) {
+			continue
+		}
+		written = append(written, column)
+		if !slices.Contains(table.Key, column) {
+			updated = append(updated, column)
+		}
+	}
+	assign := func(from string) string {
+		set := make([]string, len(updated))
+		for i, column := range updated {
+			set[i] = fmt.Sprintf("%s = %s.%s", column, from, column)
+		}
+		return strings.Join(set, ", ")
+	}
+	payload := jsonArray(rows)
+
+	for _, trigger := range table.Triggers {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s DISABLE TRIGGER %s`, source, trigger)); err != nil {
+			return fmt.Errorf("error disabling %s on %s: %w", trigger, source, err)
+		}
+	}
+
+	if table.Update {
+		var match []string
+		for _, column := range table.Key {
+			match = append(match, fmt.Sprintf("t.%s = r.%s", column, column))
+		}
+		query := fmt.Sprintf(`UPDATE %s t SET %s FROM json_populate_recordset(NULL::%s, $1::json) r WHERE %s`,
+			source, assign("r"), source, strings.Join(match, " AND "))
+		tag, err := tx.Exec(ctx, query, payload)
+		if err != nil {
+			return fmt.Errorf("error restoring %s: %w", table.Name, err)
+		}
+		if missing := len(rows) - int(tag.RowsAffected()); missing > 0 {
+			return fmt.Errorf("error restoring %s: %d of %d rows are not in %s; restore the platform's %s first", table.Name, missing, len(rows), source, source)
+		}
+	} else {
+		query := fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM json_populate_recordset(NULL::%s, $1::json) r ON CONFLICT (%s) `,
+			source, strings.Join(written, ", "), strings.Join(written, ", "), source, strings.Join(table.Key, ", "))
+		if table.Immutable || len(updated) == 0 {
+			query += "DO NOTHING"
+		} else {
+			query += "DO UPDATE SET " + assign("EXCLUDED")
+		}
+		if _, err := tx.Exec(ctx, query, payload); err != nil {
+			return fmt.Errorf("error restoring %s: %w", table.Name, err)
+		}
+	}
+
+	for _, trigger := range table.Triggers {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s ENABLE TRIGGER %s`, source, trigger)); err != nil {
+			return fmt.Errorf("error enabling %s on %s: %w", trigger, source, err)
+		}
+	}
+
+	// Later inserts must not reuse a restored ID
+	if !table.Update && len(table.Key) == 1 && table.Key[0] == "id" {
+		query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), MAX(id)) FROM %s HAVING MAX(id) IS NOT NULL`, source, source)
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("error advancing the %s sequence: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// verifyBackupTable reads a restored table back and checks it holds each
+// backed-up row unchanged
+func verifyBackupTable(ctx context.Context, tx pgx.Tx, table BackupTable, rows []json.RawMessage) (RestoredTable, error) {
+	current, err := exportBackupTable(ctx, tx, table, nil)
+	if err != nil {
+		return RestoredTable{}, err
+	}
+	stored := make(map[string]string, len(current))
+	for _, row := range current {
+		key, canonical, err := canonicalBackupRow(table, row)
+		if err != nil {
+			return RestoredTable{}, err
+		}
+		stored[key] = canonical
+	}
+
+	restored := RestoredTable{Rows: len(rows), Extra: len(current)}
+	for _, row := range rows {
+		key, canonical, err := canonicalBackupRow(table, row)
+		if err != nil {
+			return RestoredTable{}, err
+		}
+		got, ok := stored[key]
+		switch {
+		case !ok:
+			return RestoredTable{}, fmt.Errorf("restore check failed: %s row %s is missing", table.Name, key)
+		case got != canonical:
+			return RestoredTable{}, fmt.Errorf("restore check failed: %s row %s was restored as %s, the backup has %s", table.Name, key, got, canonical)
+		}
+		restored.Extra--
+	}
+	return restored, nil
+}
+
+// BackupRowKey returns the values of a row's key columns, identifying the
+// row across backups
+func BackupRowKey(table BackupTable, row json.RawMessage) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return "", fmt.Errorf("invalid %s row: %w", table.Name, err)
+	}
+	values := make([]string, len(table.Key))
+	for i, column := range table.Key {
+		value, ok := fields[column]
+		if !ok {
+			return "", fmt.Errorf("%s row has no %s", table.Name, column)
+		}
+		values[i] = string(value)
+	}
+	return strings.Join(values, ","), nil
+}
+
+// canonicalBackupRow returns a row's key and its columns other than Skip in
+// a form that compares equal for equal rows
+func canonicalBackupRow(table BackupTable, row json.RawMessage) (string, string, error) {
+	key, err := BackupRowKey(table, row)
+	if err != nil {
+		return "", "", err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(row))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return "", "", fmt.Errorf("invalid %s row: %w", table.Name, err)
+	}
+	for _, column := range table.Skip {
+		delete(fields, column)
+	}
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid %s row: %w", table.Name, err)
+	}
+	return key, string(canonical), nil
+}
+
+func tableColumns(ctx context.Context, tx pgx.Tx, table string) ([]string, error) {
+	query := `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position
+	`
+	rows, err := tx.Query(ctx, query, table)
+	if err != nil {
+		return nil, fmt.Errorf("error listing columns of %s: %w", table, err)
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("error listing columns of %s: %w", table, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist; run the gateway once to create it", table)
+	}
+	return columns, nil
+}
+
+func jsonArray(rows []json.RawMessage) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(row)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
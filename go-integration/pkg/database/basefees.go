@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/gaswindow"
+)
+
+// RecordBaseFee stores the base fee of a block and prunes samples taken
+// before olderThan
+func (db *DB) RecordBaseFee(ctx context.Context, blockNumber uint64, baseFee *big.Int, olderThan time.Time) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO base_fee_samples (block_number, base_fee)
+		VALUES ($1, $2::numeric)
+		ON CONFLICT (block_number) DO NOTHING
+	`
+	if _, err := db.Pool.Exec(ctx, query, int64(blockNumber), baseFee.String()); err != nil {
+		return fmt.Errorf("error recording base fee: %w", err)
+	}
+
+	if _, err := db.Pool.Exec(ctx, `DELETE FROM base_fee_samples WHERE sampled_at < $1`, olderThan); err != nil {
+		return fmt.Errorf("error pruning base fee samples: %w", err)
+	}
+	return nil
+}
+
+// GetBaseFeeProfile averages the base fees sampled since a time by UTC hour of the day
+func (db *DB) GetBaseFeeProfile(ctx context.Context, since time.Time) (gaswindow.Profile, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT EXTRACT(HOUR FROM sampled_at AT TIME ZONE 'UTC')::int AS hour,
+			(AVG(base_fee) / 1e9)::float8,
+			COUNT(*)
+		FROM base_fee_samples
+		WHERE sampled_at >= $1
+		GROUP BY hour
+		ORDER BY hour
+	`
+
+	rows, err := db.Pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("error querying base fee profile: %w", err)
+	}
+	defer rows.Close()
+
+	var profile gaswindow.Profile
+	for rows.Next() {
+		var hour gaswindow.Hour
+		if err := rows.Scan(&hour.Hour, &hour.AverageGwei, &hour.Samples); err != nil {
+			return nil, fmt.Errorf("error scanning base fee profile: %w", err)
+		}
+		profile = append(profile, hour)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading base fee profile: %w", err)
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Broadcast outcomes, settled once the account's nonce is mined past the
+// transaction's. Only one transaction per nonce is ever mined.
+const (
+	BroadcastMined     = "mined"     // mined, and costed under its operation
+	BroadcastCancelled = "cancelled" // a cancel transaction mined in place of the one it cancels
+	BroadcastReplaced  = "replaced"  // another of the gateway's transactions was mined at the nonce
+	BroadcastDropped   = "dropped"   // nothing the gateway signed was mined at the nonce
+)
+
+// Broadcast is a transaction signed for an operation, recorded before it is
+// sent so a hash that reaches the chain without the gateway waiting on it,
+// such as one a failed send still broadcast or one replaced at its nonce, is
+// still costed
+type Broadcast struct {
+	TxHash        string
+	ApplicationID int32
+	IntentKey     string // IntentKey of the operation and escrow job
+	Operation     string
+	Address       string
+	Nonce         uint64
+	Cancels       *string // the hash a cancel transaction replaces
+	Outcome       *string
+	CreatedAt     time.Time
+	ResolvedAt    *time.Time
+}
+
+const broadcastColumns = `tx_hash, application_id, intent_key, operation, address, nonce, cancels, outcome, created_at, resolved_at`
+
+func scanBroadcast(row pgx.Row) (*Broadcast, error) {
+	var b Broadcast
+	var nonce int64
+	err := row.Scan(&b.TxHash, &b.ApplicationID, &b.IntentKey, &b.Operation, &b.Address, &nonce, &b.Cancels, &b.Outcome, &b.CreatedAt, &b.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	b.Nonce = uint64(nonce)
+	return &b, nil
+}
+
+// RecordBroadcast records a signed transaction. Recording the same hash
+// again does nothing.
+func (db *DB) RecordBroadcast(ctx context.Context, b Broadcast) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO broadcast_transactions (tx_hash, application_id, intent_key, operation, address, nonce, cancels)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tx_hash) DO NOTHING
+	`
+	_, err := db.Pool.Exec(ctx, query, b.TxHash, b.ApplicationID, b.IntentKey, b.Operation, b.Address, int64(b.Nonce), b.Cancels)
+	if err != nil {
+		return fmt.Errorf("error recording broadcast transaction: %w", err)
+	}
+	return nil
+}
+
+// GetBroadcast returns the recorded transaction with txHash, or nil if the
+// gateway didn't sign it for an operation
+func (db *DB) GetBroadcast(ctx context.Context, txHash string) (*Broadcast, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + broadcastColumns + ` FROM broadcast_transactions WHERE tx_hash = $1`
+	b, err := scanBroadcast(db.Pool.QueryRow(ctx, query, txHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying broadcast transaction: %w", err)
+	}
+	return b, nil
+}
+
+// ListUnresolvedBroadcasts returns address's transactions without an outcome
+// at nonces below belowNonce, by nonce and then in the order they were signed
+func (db *DB) ListUnresolvedBroadcasts(ctx context.Context, address string, belowNonce uint64) ([]*Broadcast, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + broadcastColumns + `
+		FROM broadcast_transactions
+		WHERE address = $1 AND nonce < $2 AND outcome IS NULL
+		ORDER BY nonce, created_at
+	`
+	rows, err := db.Pool.Query(ctx, query, address, int64(belowNonce))
+	if err != nil {
+		return nil, fmt.Errorf("error querying broadcast transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var broadcasts []*Broadcast
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning broadcast transaction: %w", err)
+		}
+		broadcasts = append(broadcasts, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading broadcast transactions: %w", err)
+	}
+	return broadcasts, nil
+}
+
+// ResolveBroadcast records a transaction's outcome. One that already has an
+// outcome keeps it.
+func (db *DB) ResolveBroadcast(ctx context.Context, txHash, outcome string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE broadcast_transactions
+		SET outcome = $2, resolved_at = NOW()
+		WHERE tx_hash = $1 AND outcome IS NULL
+	`
+	if _, err := db.Pool.Exec(ctx, query, txHash, outcome); err != nil {
+		return fmt.Errorf("error resolving broadcast transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// ErrInvalidCursor is returned for a change feed cursor the gateway did not issue
+var ErrInvalidCursor = errors.New("invalid change cursor")
+
+// ChangeCursor is a position in the change feed: the writing transaction's ID
+// and the event's ID within it. The zero value is the start of the feed.
+type ChangeCursor struct {
+	TxID uint64
+	ID   int64
+}
+
+// String encodes the cursor as an opaque token
+func (c ChangeCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", c.TxID, c.ID)))
+}
+
+// ParseChangeCursor decodes a token from ChangeCursor.String. An empty token
+// is the start of the feed.
+func ParseChangeCursor(token string) (ChangeCursor, error) {
+	if token == "" {
+		return ChangeCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	txid, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	var cursor ChangeCursor
+	if cursor.TxID, err = strconv.ParseUint(txid, 10, 64); err != nil {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil || cursor.ID < 0 {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// PaymentChange is a payment status transition in change feed order
+type PaymentChange struct {
+	PaymentEvent
+	Cursor ChangeCursor
+}
+
+// ChangeFeedSpec is what GET /changes can filter on. The feed is always in
+// commit order and paged by its own cursor, so only the limit and filters of
+// the parsed params apply.
+var ChangeFeedSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"id":             {Column: "id", Kind: listquery.Int, Sortable: true},
+		"application_id": {Column: "application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"status":         {Column: "status", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+		"actor":          {Column: "actor", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+	},
+	ID:           "id",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// ListPaymentChanges returns up to limit payment status transitions matching
+// filters after cursor in commit order. Event IDs are allocated before
+// commit, so a later ID can become visible first; rows are therefore ordered
+// by the writing transaction's ID, and only transactions older than every
+// transaction still running are returned. A row never appears behind a
+// cursor already handed out.
+func (db *DB) ListPaymentChanges(ctx context.Context, after ChangeCursor, filters []listquery.Condition, limit int) ([]PaymentChange, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{strconv.FormatUint(after.TxID, 10), after.ID}
+	query := `
+		SELECT txid::text, id, application_id, status, tx_hash, block_number, actor, created_at
+		FROM payment_events
+		WHERE (txid, id) > ($1::text::xid8, $2)
+			AND txid < pg_snapshot_xmin(pg_current_snapshot())` + filterClause(filters, &args)
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY txid, id LIMIT $%d", len(args))
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []PaymentChange
+	for rows.Next() {
+		var change PaymentChange
+		var txid string
+		err := rows.Scan(
+			&txid,
+			&change.ID,
+			&change.ApplicationID,
+			&change.Status,
+			&change.TxHash,
+			&change.BlockNumber,
+			&change.Actor,
+			&change.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment change: %w", err)
+		}
+		if change.Cursor.TxID, err = strconv.ParseUint(txid, 10, 64); err != nil {
+			return nil, fmt.Errorf("error parsing payment change txid %q: %w", txid, err)
+		}
+		change.Cursor.ID = change.ID
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading payment changes: %w", err)
+	}
+
+	return changes, nil
+}
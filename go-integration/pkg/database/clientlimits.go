@@ -0,0 +1,301 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/clientlimit"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// openEscrowStatuses are the payment statuses whose escrow still holds, or
+// is about to hold, the client's funds. Held posts count so approving a
+// review cannot take a client past its limit unnoticed.
+var openEscrowStatuses = []paymentstatus.Status{
+	paymentstatus.DepositInitiated, paymentstatus.Deposited, paymentstatus.ReleaseInitiated, paymentstatus.ReleaseFailed,
+	paymentstatus.RefundInitiated, paymentstatus.RefundFailed, paymentstatus.PendingReview, paymentstatus.KYCPending,
+}
+
+// ClientLimitListSpec is what GET /client-limits can sort and filter on
+var ClientLimitListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"id":                  {Column: "id", Kind: listquery.Int, Sortable: true, Ops: []listquery.Op{listquery.Gt, listquery.Lt}},
+		"scope":               {Column: "scope", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"subject":             {Column: "subject", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"max_open_usd":        {Column: "max_open_usd", Kind: listquery.Int, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"max_transaction_usd": {Column: "max_transaction_usd", Kind: listquery.Int, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"updated_at":          {Column: "updated_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+	},
+	ID:           "id",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// listedClientLimit is a limit with the columns it is paged by
+type listedClientLimit struct {
+	id        int64
+	updatedAt time.Time
+	limit     clientlimit.Limit
+}
+
+// FindClientLimits returns a page of configured client limits matching
+// params and the cursor of the next page, or "" if it is the last
+func (db *DB) FindClientLimits(ctx context.Context, params listquery.Params) ([]clientlimit.Limit, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var args []interface{}
+	query := `SELECT id, updated_at, scope, subject, max_open_usd, max_transaction_usd FROM client_limits WHERE TRUE` + listClause(params, &args)
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error finding client limits: %w", err)
+	}
+	defer rows.Close()
+
+	var listed []listedClientLimit
+	for rows.Next() {
+		var l listedClientLimit
+		if err := rows.Scan(&l.id, &l.updatedAt, &l.limit.Scope, &l.limit.Subject, &l.limit.MaxOpenUSD, &l.limit.MaxTransactionUSD); err != nil {
+			return nil, "", fmt.Errorf("error scanning client limit: %w", err)
+		}
+		listed = append(listed, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error finding client limits: %w", err)
+	}
+
+	listed, next := listquery.Page(listed, params, func(l listedClientLimit, field string) any {
+		if field == "updated_at" {
+			return l.updatedAt
+		}
+		return l.id
+	})
+	limits := make([]clientlimit.Limit, len(listed))
+	for i, l := range listed {
+		limits[i] = l.limit
+	}
+	return limits, next, nil
+}
+
+// GetClientLimits returns the limits configured for a wallet and a tenant;
+// either may be empty
+func (db *DB) GetClientLimits(ctx context.Context, wallet, tenant string) ([]clientlimit.Limit, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT scope, subject, max_open_usd, max_transaction_usd
+		FROM client_limits
+		WHERE (scope = $1 AND subject = $2) OR (scope = $3 AND subject = $4)
+		ORDER BY scope
+	`
+	rows, err := db.Pool.Query(ctx, query, clientlimit.Wallet, wallet, clientlimit.Tenant, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("error querying client limits: %w", err)
+	}
+	return scanClientLimits(rows)
+}
+
+func scanClientLimits(rows pgx.Rows) ([]clientlimit.Limit, error) {
+	defer rows.Close()
+
+	var limits []clientlimit.Limit
+	for rows.Next() {
+		var limit clientlimit.Limit
+		if err := rows.Scan(&limit.Scope, &limit.Subject, &limit.MaxOpenUSD, &limit.MaxTransactionUSD); err != nil {
+			return nil, fmt.Errorf("error scanning client limit: %w", err)
+		}
+		limits = append(limits, limit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying client limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// SetClientLimit creates or replaces a client's limit, recording the change
+// in the audit log
+func (db *DB) SetClientLimit(ctx context.Context, limit clientlimit.Limit, actor, reason string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockClientLimit(ctx, tx, limit.Scope, limit.Subject)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO client_limits (scope, subject, max_open_usd, max_transaction_usd, actor)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, subject) DO UPDATE
+		SET max_open_usd = EXCLUDED.max_open_usd, max_transaction_usd = EXCLUDED.max_transaction_usd,
+			actor = EXCLUDED.actor, updated_at = NOW()
+	`
+	if _, err := tx.Exec(ctx, query, limit.Scope, limit.Subject, limit.MaxOpenUSD, limit.MaxTransactionUSD, actor); err != nil {
+		return fmt.Errorf("error setting client limit: %w", err)
+	}
+
+	if err := insertClientLimitAudit(ctx, tx, "client_limit.set", before, &limit, actor, reason); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing client limit: %w", err)
+	}
+	return nil
+}
+
+// DeleteClientLimit removes a client's limit. It reports whether one existed.
+func (db *DB) DeleteClientLimit(ctx context.Context, scope clientlimit.Scope, subject, actor, reason string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockClientLimit(ctx, tx, scope, subject)
+	if err != nil || before == nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM client_limits WHERE scope = $1 AND subject = $2`, scope, subject); err != nil {
+		return false, fmt.Errorf("error deleting client limit: %w", err)
+	}
+
+	if err := insertClientLimitAudit(ctx, tx, "client_limit.delete", before, nil, actor, reason); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("error committing client limit: %w", err)
+	}
+	return true, nil
+}
+
+// lockClientLimit returns the current limit, or nil if there is none
+func lockClientLimit(ctx context.Context, tx pgx.Tx, scope clientlimit.Scope, subject string) (*clientlimit.Limit, error) {
+	limit := &clientlimit.Limit{Scope: scope, Subject: subject}
+	query := `
+		SELECT max_open_usd, max_transaction_usd FROM client_limits
+		WHERE scope = $1 AND subject = $2
+		FOR UPDATE
+	`
+	err := tx.QueryRow(ctx, query, scope, subject).Scan(&limit.MaxOpenUSD, &limit.MaxTransactionUSD)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying client limit: %w", err)
+	}
+	return limit, nil
+}
+
+func insertClientLimitAudit(ctx context.Context, q execer, action string, before, after *clientlimit.Limit, actor, reason string) error {
+	entry := AuditEntry{Action: action, Actor: actor, Reason: reason}
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		entry.After, _ = json.Marshal(after)
+	}
+	return insertAudit(ctx, q, entry)
+}
+
+// GetClientOpenUSD returns the USD a client holds in open escrows, across job
+// deposits and funded top-ups, excluding one application. A wallet client is
+// the poster's wallet; a tenant client is every escrow posted for the tenant.
+func (db *DB) GetClientOpenUSD(ctx context.Context, scope clientlimit.Scope, subject string, excludeApplicationID int32) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var client string
+	switch scope {
+	case clientlimit.Wallet:
+		client = `
+			SELECT a.id FROM applications a
+			JOIN jobs j ON a.job_id = j.id
+			JOIN users poster ON j.user_id = poster.id
+			WHERE LOWER(poster.wallet_address) = LOWER($1)`
+	case clientlimit.Tenant:
+		client = `SELECT application_id AS id FROM escrow_tenants WHERE tenant = $1`
+	default:
+		return 0, fmt.Errorf("unknown client limit scope %q", scope)
+	}
+
+	query := `
+		WITH client_applications AS (` + client + `
+		)
+		SELECT
+			COALESCE((
+				SELECT SUM(a.agreed_usd_amount)
+				FROM applications a
+				WHERE a.id IN (SELECT id FROM client_applications) AND a.id <> $2
+				AND a.payment_status = ANY($3)
+			), 0) +
+			COALESCE((
+				SELECT SUM(t.usd_amount)
+				FROM escrow_top_ups t
+				JOIN applications a ON t.application_id = a.id
+				WHERE a.id IN (SELECT id FROM client_applications) AND a.id <> $2
+				AND a.payment_status = ANY($3) AND t.status = ANY($4)
+			), 0)
+	`
+
+	var total int64
+	err := db.Pool.QueryRow(ctx, query, subject, excludeApplicationID, openEscrowStatuses, []string{TopUpStatusFunding, TopUpStatusFunded}).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("error querying client open escrows: %w", err)
+	}
+
+	return total, nil
+}
+
+// RecordEscrowTenant records the tenant an application's escrow was posted
+// for, so it counts toward the tenant's open escrows
+func (db *DB) RecordEscrowTenant(ctx context.Context, applicationID int32, tenant string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO escrow_tenants (application_id, tenant)
+		VALUES ($1, $2)
+		ON CONFLICT (application_id) DO UPDATE SET tenant = EXCLUDED.tenant
+	`
+	if _, err := db.Pool.Exec(ctx, query, applicationID, tenant); err != nil {
+		return fmt.Errorf("error recording escrow tenant: %w", err)
+	}
+	return nil
+}
+
+// GetEscrowTenant returns the tenant an application's escrow was posted for,
+// or "" if it was posted without one
+func (db *DB) GetEscrowTenant(ctx context.Context, applicationID int32) (string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var tenant string
+	err := db.Pool.QueryRow(ctx, `SELECT tenant FROM escrow_tenants WHERE application_id = $1`, applicationID).Scan(&tenant)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error querying escrow tenant: %w", err)
+	}
+	return tenant, nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ClonedApplicationStatus is the applications.status a clone starts in: the
+// freelancer hired again, the escrow not yet funded
+const ClonedApplicationStatus = "accepted"
+
+// CloneApplication creates an application for the same freelancer on the
+// source's job, agreed at usdAmount, and copies the source's tags, display
+// currencies and job webhook to it. The clone starts in pending_deposit with
+// no escrow; the audit log records it against the new application. It
+// returns the new application's ID, or an error wrapping pgx.ErrNoRows when
+// the source doesn't exist.
+func (db *DB) CloneApplication(ctx context.Context, sourceID int32, usdAmount int32, actor string) (int32, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO applications (job_id, user_id, status, agreed_usd_amount, payment_status)
+		SELECT job_id, user_id, $2, $3, 'pending_deposit'
+		FROM applications
+		WHERE id = $1
+		RETURNING id
+	`
+	var applicationID int32
+	if err := tx.QueryRow(ctx, query, sourceID, ClonedApplicationStatus, usdAmount).Scan(&applicationID); err != nil {
+		return 0, fmt.Errorf("error cloning application: %w", err)
+	}
+
+	for _, table := range []struct {
+		what  string
+		query string
+	}{
+		{"job tags", `INSERT INTO application_tags (application_id, tag) SELECT $2, tag FROM application_tags WHERE application_id = $1`},
+		{"display currencies", `
+			INSERT INTO job_display_currencies (application_id, client_currency, freelancer_currency)
+			SELECT $2, client_currency, freelancer_currency FROM job_display_currencies WHERE application_id = $1
+		`},
+		{"job webhook", `INSERT INTO job_webhooks (application_id, url) SELECT $2, url FROM job_webhooks WHERE application_id = $1`},
+	} {
+		if _, err := tx.Exec(ctx, table.query, sourceID, applicationID); err != nil {
+			return 0, fmt.Errorf("error copying %s: %w", table.what, err)
+		}
+	}
+
+	afterJSON, _ := json.Marshal(map[string]interface{}{"cloned_from": sourceID, "agreed_usd_amount": usdAmount})
+	if err := insertAudit(ctx, tx, AuditEntry{
+		Action:        "application.clone",
+		ApplicationID: &applicationID,
+		Actor:         actor,
+		After:         afterJSON,
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error committing application clone: %w", err)
+	}
+	return applicationID, nil
+}
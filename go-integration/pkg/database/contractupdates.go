@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ContractUpdate is a signed move onto a redeployed escrow contract. Payload
+// is the exact body that was signed, so the update can be verified again
+// when it is reapplied at startup.
+type ContractUpdate struct {
+	ID              int64
+	NetworkID       int64
+	ContractAddress string
+	PreviousAddress string
+	Payload         []byte
+	Signature       string
+	IssuedAt        time.Time
+	Actor           string
+	CreatedAt       time.Time
+}
+
+// contractAudit is the escrow contract recorded in the audit log
+type contractAudit struct {
+	ContractAddress string `json:"contract_address"`
+}
+
+// RecordContractUpdate stores an applied contract update and audits the move
+// from PreviousAddress to ContractAddress
+func (db *DB) RecordContractUpdate(ctx context.Context, update *ContractUpdate, reason string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO contract_updates (network_id, contract_address, previous_address, payload, signature, issued_at, actor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRow(ctx, query, update.NetworkID, update.ContractAddress, update.PreviousAddress, update.Payload, update.Signature, update.IssuedAt, update.Actor).
+		Scan(&update.ID, &update.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error recording contract update: %w", err)
+	}
+
+	before, _ := json.Marshal(contractAudit{ContractAddress: update.PreviousAddress})
+	after, _ := json.Marshal(contractAudit{ContractAddress: update.ContractAddress})
+	entry := AuditEntry{
+		Action: "contract.update",
+		Actor:  update.Actor,
+		Reason: reason,
+		Before: before,
+		After:  after,
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing contract update: %w", err)
+	}
+	return nil
+}
+
+// LatestContractUpdate returns the last contract update applied on a
+// network, or nil if there is none
+func (db *DB) LatestContractUpdate(ctx context.Context, networkID int64) (*ContractUpdate, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, network_id, contract_address, previous_address, payload, signature, issued_at, actor, created_at
+		FROM contract_updates
+		WHERE network_id = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	update := &ContractUpdate{}
+	err := db.Pool.QueryRow(ctx, query, networkID).Scan(
+		&update.ID,
+		&update.NetworkID,
+		&update.ContractAddress,
+		&update.PreviousAddress,
+		&update.Payload,
+		&update.Signature,
+		&update.IssuedAt,
+		&update.Actor,
+		&update.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying contract update: %w", err)
+	}
+
+	return update, nil
+}
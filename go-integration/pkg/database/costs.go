@@ -0,0 +1,296 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// TransactionCost is the gas spent by one transaction of a job. Amounts are
+// decimal strings because wei values overflow int64.
+type TransactionCost struct {
+	ApplicationID     int32
+	Operation         string
+	TxHash            string
+	GasUsed           uint64
+	EffectiveGasPrice string
+	CostWei           string
+	ETHUSDPrice       *string // Chainlink answer with 8 decimals at execution time
+	CostUSD           *string
+	CreatedAt         time.Time // set when read back; recording uses the current time
+}
+
+// JobGasCost is the total gas spent across a job's transactions
+type JobGasCost struct {
+	Transactions int64
+	GasUsed      int64
+	CostWei      string
+	CostUSD      string
+}
+
+// GasCostReportRow is the gas spent on one operation type within one period
+type GasCostReportRow struct {
+	Period       time.Time
+	Operation    string
+	Transactions int64
+	GasUsed      int64
+	CostWei      string
+	CostUSD      string
+}
+
+// GasWasteReportRow is the gas wasted by one operation type for one reason
+// within one period
+type GasWasteReportRow struct {
+	Period       time.Time
+	Operation    string
+	Reason       string
+	Transactions int64
+	GasUsed      int64
+	CostWei      string
+	CostUSD      string
+}
+
+// OperationGasAverage is the mean gas used by one operation type
+type OperationGasAverage struct {
+	Operation    string
+	Transactions int64
+	AvgGasUsed   int64
+}
+
+// RecordTransactionCost stores the gas cost of a mined transaction
+func (db *DB) RecordTransactionCost(ctx context.Context, cost TransactionCost) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO transaction_costs
+			(application_id, operation, tx_hash, gas_used, effective_gas_price, cost_wei, eth_usd_price, cost_usd)
+		VALUES ($1, $2, $3, $4, $5::numeric, $6::numeric, $7::numeric, $8::numeric)
+		ON CONFLICT (tx_hash) DO NOTHING
+	`
+
+	_, err := db.Pool.Exec(ctx, query,
+		cost.ApplicationID,
+		cost.Operation,
+		cost.TxHash,
+		int64(cost.GasUsed),
+		cost.EffectiveGasPrice,
+		cost.CostWei,
+		cost.ETHUSDPrice,
+		cost.CostUSD,
+	)
+	if err != nil {
+		return fmt.Errorf("error recording transaction cost: %w", err)
+	}
+
+	return nil
+}
+
+// ListTransactionCosts returns the gas cost of each transaction recorded for an application, oldest first
+func (db *DB) ListTransactionCosts(ctx context.Context, applicationID int32) ([]TransactionCost, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT application_id, operation, tx_hash, gas_used, effective_gas_price::text, cost_wei::text,
+			eth_usd_price::text, cost_usd::text, created_at
+		FROM transaction_costs
+		WHERE application_id = $1
+		ORDER BY id
+	`
+	rows, err := db.Pool.Query(ctx, query, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing transaction costs: %w", err)
+	}
+	defer rows.Close()
+
+	var costs []TransactionCost
+	for rows.Next() {
+		var cost TransactionCost
+		var gasUsed int64
+		err := rows.Scan(&cost.ApplicationID, &cost.Operation, &cost.TxHash, &gasUsed, &cost.EffectiveGasPrice, &cost.CostWei,
+			&cost.ETHUSDPrice, &cost.CostUSD, &cost.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning transaction cost: %w", err)
+		}
+		cost.GasUsed = uint64(gasUsed)
+		costs = append(costs, cost)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing transaction costs: %w", err)
+	}
+
+	return costs, nil
+}
+
+// GetJobGasCost sums the gas cost of every transaction recorded for an application
+func (db *DB) GetJobGasCost(ctx context.Context, applicationID int32) (*JobGasCost, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(gas_used), 0),
+			COALESCE(SUM(cost_wei), 0)::text,
+			COALESCE(SUM(cost_usd), 0)::text
+		FROM transaction_costs
+		WHERE application_id = $1
+	`
+
+	cost := &JobGasCost{}
+	err := db.Pool.QueryRow(ctx, query, applicationID).Scan(
+		&cost.Transactions,
+		&cost.GasUsed,
+		&cost.CostWei,
+		&cost.CostUSD,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying job gas cost: %w", err)
+	}
+
+	return cost, nil
+}
+
+// GasCostReportSpec is what GET /reports/gas-costs can filter on
+var GasCostReportSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"application_id": {Column: "application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"operation":      {Column: "operation", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+	},
+}
+
+// GetGasCostReport aggregates the gas cost of transactions matching filters
+// by operation for each period of r
+func (db *DB) GetGasCostReport(ctx context.Context, r listquery.Range, filters []listquery.Condition, tags []string) ([]GasCostReportRow, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{r.Interval, r.From, r.To}
+	query := `
+		SELECT
+			date_trunc($1, created_at) as period,
+			operation,
+			COUNT(*),
+			COALESCE(SUM(gas_used), 0),
+			COALESCE(SUM(cost_wei), 0)::text,
+			COALESCE(SUM(cost_usd), 0)::text
+		FROM transaction_costs
+		WHERE created_at >= $2 AND created_at < $3` + filterClause(filters, &args) + tagFilter("transaction_costs.application_id", tags, &args) + `
+		GROUP BY period, operation
+		ORDER BY period, operation
+	`
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying gas cost report: %w", err)
+	}
+	defer rows.Close()
+
+	var report []GasCostReportRow
+	for rows.Next() {
+		var row GasCostReportRow
+		err := rows.Scan(&row.Period, &row.Operation, &row.Transactions, &row.GasUsed, &row.CostWei, &row.CostUSD)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning gas cost report: %w", err)
+		}
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading gas cost report: %w", err)
+	}
+
+	return report, nil
+}
+
+// GasWasteReportSpec is what GET /reports/gas-waste can filter on
+var GasWasteReportSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"application_id": {Column: "c.application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"operation":      {Column: "c.operation", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+		"reason":         {Column: "t.memo", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+	},
+}
+
+// GetGasWasteReport aggregates the gas cost of transactions posted to the
+// ledger as gas waste and matching filters by operation and reason for each
+// period of r
+func (db *DB) GetGasWasteReport(ctx context.Context, r listquery.Range, filters []listquery.Condition, tags []string) ([]GasWasteReportRow, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{r.Interval, r.From, r.To, ledger.KindGasWaste}
+	query := `
+		SELECT
+			date_trunc($1, c.created_at) as period,
+			c.operation,
+			t.memo,
+			COUNT(*),
+			COALESCE(SUM(c.gas_used), 0),
+			COALESCE(SUM(c.cost_wei), 0)::text,
+			COALESCE(SUM(c.cost_usd), 0)::text
+		FROM ledger_transactions t
+		JOIN transaction_costs c ON c.tx_hash = t.reference
+		WHERE t.kind = $4 AND c.created_at >= $2 AND c.created_at < $3` + filterClause(filters, &args) + tagFilter("c.application_id", tags, &args) + `
+		GROUP BY period, c.operation, t.memo
+		ORDER BY period, c.operation, t.memo
+	`
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying gas waste report: %w", err)
+	}
+	defer rows.Close()
+
+	var report []GasWasteReportRow
+	for rows.Next() {
+		var row GasWasteReportRow
+		err := rows.Scan(&row.Period, &row.Operation, &row.Reason, &row.Transactions, &row.GasUsed, &row.CostWei, &row.CostUSD)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning gas waste report: %w", err)
+		}
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading gas waste report: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetOperationGasAverages returns the mean gas used per operation type by transactions recorded since since
+func (db *DB) GetOperationGasAverages(ctx context.Context, since time.Time) ([]OperationGasAverage, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT operation, COUNT(*), ROUND(AVG(gas_used))::bigint
+		FROM transaction_costs
+		WHERE created_at >= $1
+		GROUP BY operation
+		ORDER BY operation
+	`
+
+	rows, err := db.Pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("error querying operation gas averages: %w", err)
+	}
+	defer rows.Close()
+
+	var averages []OperationGasAverage
+	for rows.Next() {
+		var average OperationGasAverage
+		if err := rows.Scan(&average.Operation, &average.Transactions, &average.AvgGasUsed); err != nil {
+			return nil, fmt.Errorf("error scanning operation gas average: %w", err)
+		}
+		averages = append(averages, average)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading operation gas averages: %w", err)
+	}
+
+	return averages, nil
+}
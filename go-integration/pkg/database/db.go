@@ -2,13 +2,37 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/cache"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+const (
+	// DefaultQueryTimeout bounds each query when no other timeout is configured
+	DefaultQueryTimeout = 5 * time.Second
+
+	// DefaultDetailsCacheTTL is how long application payment details are served from memory
+	DefaultDetailsCacheTTL = 2 * time.Second
 )
 
 type DB struct {
 	Pool *pgxpool.Pool
+
+	// QueryTimeout bounds every query on top of the caller's context; 0 relies on the caller alone
+	QueryTimeout time.Duration
+
+	details   *cache.TTL[int32, *ApplicationPaymentDetails]
+	watch     statusWatch
+	listeners atomic.Int64 // open Listeners, each holding a connection
 }
 
 // ApplicationPaymentDetails represents payment-related data from your existing schema
@@ -18,7 +42,7 @@ type ApplicationPaymentDetails struct {
 	ApplicantUserID        int32
 	PosterUserID           int32
 	AgreedUSDAmount        *int32
-	PaymentStatus          string
+	PaymentStatus          paymentstatus.Status
 	EscrowJobID            *int32
 	EscrowTxHashDeposit    *string
 	EscrowTxHashRelease    *string
@@ -32,20 +56,91 @@ type ApplicationPaymentDetails struct {
 func NewDB(connStr string) (*DB, error) {
 	pool, err := pgxpool.New(context.Background(), connStr)
 	if err != nil {
-		return nil, fmt.Errorf("error opening database: %v", err)
+		return nil, fmt.Errorf("error opening database: %w", err)
 	}
 
 	// Test the connection
 	if err := pool.Ping(context.Background()); err != nil {
-		return nil, fmt.Errorf("error connecting to database: %v", err)
+		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	return &DB{Pool: pool}, nil
+	db := &DB{Pool: pool, QueryTimeout: DefaultQueryTimeout}
+	db.SetDetailsCacheTTL(DefaultDetailsCacheTTL)
+	return db, nil
+}
+
+// PoolStats counts the pool's connections. A connection that is acquired and
+// never released, such as a listener left open, stays in Acquired.
+type PoolStats struct {
+	Total     int32 `json:"total"`
+	Acquired  int32 `json:"acquired"`
+	Idle      int32 `json:"idle"`
+	Max       int32 `json:"max"`
+	Listeners int64 `json:"listeners"` // acquired by open Listeners
+}
+
+// PoolStats returns the pool's current connection counts
+func (db *DB) PoolStats() PoolStats {
+	stat := db.Pool.Stat()
+	return PoolStats{
+		Total:     stat.TotalConns(),
+		Acquired:  stat.AcquiredConns(),
+		Idle:      stat.IdleConns(),
+		Max:       stat.MaxConns(),
+		Listeners: db.listeners.Load(),
+	}
+}
+
+// SetDetailsCacheTTL replaces the application payment details cache; 0 disables it
+func (db *DB) SetDetailsCacheTTL(ttl time.Duration) {
+	db.details = cache.NewTTL[int32, *ApplicationPaymentDetails](ttl)
 }
 
-// GetApplicationPaymentDetails retrieves application and payment details for blockchain operations
+// invalidateDetails drops the cached details of an application after its
+// payment record changes, and wakes anyone waiting for the change
+func (db *DB) invalidateDetails(applicationID int32) {
+	if db.details != nil {
+		db.details.Invalidate(applicationID)
+	}
+	db.statusWritten(applicationID)
+}
+
+// withTimeout derives the context a single query runs under. Cancelling the
+// caller's context still aborts the query immediately.
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.QueryTimeout)
+}
+
+// IsTimeout reports whether a database error was caused by a query or caller deadline
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err)
+}
+
+// GetApplicationPaymentDetails retrieves application and payment details for
+// blockchain operations. Results are cached briefly because the platform polls
+// job status; every payment status write invalidates the application's entry.
 func (db *DB) GetApplicationPaymentDetails(ctx context.Context, applicationID int32) (*ApplicationPaymentDetails, error) {
-	query := `
+	if db.details == nil {
+		return db.queryApplicationPaymentDetails(ctx, applicationID)
+	}
+
+	details, err := db.details.Get(applicationID, func() (*ApplicationPaymentDetails, error) {
+		return db.queryApplicationPaymentDetails(ctx, applicationID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers get their own copy so the cached entry cannot be modified
+	detailsCopy := *details
+	return &detailsCopy, nil
+}
+
+// paymentDetailsQuery selects ApplicationPaymentDetails; callers add the WHERE clause
+const paymentDetailsQuery = `
 		SELECT 
 			a.id as application_id,
 			a.job_id,
@@ -64,11 +159,150 @@ func (db *DB) GetApplicationPaymentDetails(ctx context.Context, applicationID in
 		JOIN jobs j ON a.job_id = j.id
 		JOIN users applicant ON a.user_id = applicant.id
 		JOIN users poster ON j.user_id = poster.id
-		WHERE a.id = $1
 	`
 
+func (db *DB) queryApplicationPaymentDetails(ctx context.Context, applicationID int32) (*ApplicationPaymentDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	details, err := scanPaymentDetails(db.Pool.QueryRow(ctx, paymentDetailsQuery+` WHERE a.id = $1`, applicationID))
+	if err != nil {
+		return nil, fmt.Errorf("error querying application payment details: %w", err)
+	}
+
+	return details, nil
+}
+
+// ListReleasableApplications returns a freelancer's deposited applications
+// whose application status is approvedStatus, oldest first
+func (db *DB) ListReleasableApplications(ctx context.Context, applicantUserID int32, approvedStatus string) ([]*ApplicationPaymentDetails, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := paymentDetailsQuery + `
+		WHERE a.user_id = $1
+			AND COALESCE(a.payment_status, 'pending_deposit') = 'deposited'
+			AND a.status = $2
+		ORDER BY a.id
+	`
+	rows, err := db.Pool.Query(ctx, query, applicantUserID, approvedStatus)
+	if err != nil {
+		return nil, fmt.Errorf("error listing releasable applications: %w", err)
+	}
+	defer rows.Close()
+
+	var applications []*ApplicationPaymentDetails
+	for rows.Next() {
+		details, err := scanPaymentDetails(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning application payment details: %w", err)
+		}
+		applications = append(applications, details)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing releasable applications: %w", err)
+	}
+
+	return applications, nil
+}
+
+// ApplicationListSpec is how GraphQL jobs pages: by ID, with the limit and
+// cursor of other lists
+var ApplicationListSpec = listquery.Spec{
+	Fields:       map[string]listquery.Field{"id": {Column: "a.id", Kind: listquery.Int, Sortable: true}},
+	ID:           "id",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// ApplicationFilter narrows ListApplications; zero fields match everything
+type ApplicationFilter struct {
+	PaymentStatus     paymentstatus.Status
+	ApplicationStatus string
+	ApplicantUserID   int32
+	PosterUserID      int32
+	Tags              []string         // applications carrying every one of these tags
+	Page              listquery.Params // parsed with ApplicationListSpec
+}
+
+// ListApplications returns a page of applications matching filter, oldest
+// first, and the cursor of the next page, or "" if it is the last
+func (db *DB) ListApplications(ctx context.Context, filter ApplicationFilter) ([]*ApplicationPaymentDetails, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var args []interface{}
+	query := paymentDetailsQuery + ` WHERE TRUE`
+	if filter.PaymentStatus != "" {
+		args = append(args, filter.PaymentStatus)
+		query += fmt.Sprintf(" AND COALESCE(a.payment_status, 'pending_deposit') = $%d", len(args))
+	}
+	if filter.ApplicationStatus != "" {
+		args = append(args, filter.ApplicationStatus)
+		query += fmt.Sprintf(" AND a.status = $%d", len(args))
+	}
+	if filter.ApplicantUserID != 0 {
+		args = append(args, filter.ApplicantUserID)
+		query += fmt.Sprintf(" AND a.user_id = $%d", len(args))
+	}
+	if filter.PosterUserID != 0 {
+		args = append(args, filter.PosterUserID)
+		query += fmt.Sprintf(" AND j.user_id = $%d", len(args))
+	}
+	query += tagFilter("a.id", filter.Tags, &args)
+	query += listClause(filter.Page, &args)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing applications: %w", err)
+	}
+	defer rows.Close()
+
+	var applications []*ApplicationPaymentDetails
+	for rows.Next() {
+		details, err := scanPaymentDetails(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("error scanning application payment details: %w", err)
+		}
+		applications = append(applications, details)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error listing applications: %w", err)
+	}
+
+	applications, next := listquery.Page(applications, filter.Page, func(details *ApplicationPaymentDetails, field string) any {
+		return int64(details.ApplicationID)
+	})
+	return applications, next, nil
+}
+
+// ListApprovedDeposits returns the IDs of every deposited application whose
+// application status is approvedStatus, oldest first
+func (db *DB) ListApprovedDeposits(ctx context.Context, approvedStatus string) ([]int32, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id FROM applications
+		WHERE COALESCE(payment_status, 'pending_deposit') = 'deposited' AND status = $1
+		ORDER BY id
+	`
+	rows, err := db.Pool.Query(ctx, query, approvedStatus)
+	if err != nil {
+		return nil, fmt.Errorf("error listing approved deposits: %w", err)
+	}
+	defer rows.Close()
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int32])
+	if err != nil {
+		return nil, fmt.Errorf("error listing approved deposits: %w", err)
+	}
+	return ids, nil
+}
+
+func scanPaymentDetails(row pgx.Row) (*ApplicationPaymentDetails, error) {
 	details := &ApplicationPaymentDetails{}
-	err := db.Pool.QueryRow(ctx, query, applicationID).Scan(
+	err := row.Scan(
 		&details.ApplicationID,
 		&details.JobID,
 		&details.ApplicantUserID,
@@ -84,58 +318,120 @@ func (db *DB) GetApplicationPaymentDetails(ctx context.Context, applicationID in
 		&details.ApplicationStatus,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error querying application payment details: %v", err)
+		return nil, err
 	}
-
 	return details, nil
 }
 
-// UpdatePaymentStatus updates the payment status and transaction hash
-func (db *DB) UpdatePaymentStatus(ctx context.Context, applicationID int32, status string, txHash *string, txType string) error {
+// UpdatePaymentStatus updates the payment status and transaction hash on behalf of the gateway
+func (db *DB) UpdatePaymentStatus(ctx context.Context, applicationID int32, status paymentstatus.Status, txHash *string, txType string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return db.ApplyStatusChange(ctx, StatusChange{
+		ApplicationID: applicationID,
+		Status:        status,
+		TxHash:        txHash,
+		TxType:        txType,
+		Actor:         ActorGateway,
+	})
+}
+
+// ApplyStatusChange updates the payment status and transaction hash and records
+// the transition in payment_events within one transaction. A status outside
+// paymentstatus.All is refused before anything is written.
+func (db *DB) ApplyStatusChange(ctx context.Context, change StatusChange) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if !change.Status.Valid() {
+		return fmt.Errorf("error updating payment status: unknown payment status %q", change.Status)
+	}
+
 	var query string
 	var args []interface{}
 
-	switch txType {
+	switch change.TxType {
 	case "deposit":
 		query = `
 			UPDATE applications 
 			SET payment_status = $1, escrow_tx_hash_deposit = $2
 			WHERE id = $3
 		`
-		args = []interface{}{status, txHash, applicationID}
+		args = []interface{}{change.Status, change.TxHash, change.ApplicationID}
 	case "release":
 		query = `
 			UPDATE applications 
 			SET payment_status = $1, escrow_tx_hash_release = $2
 			WHERE id = $3
 		`
-		args = []interface{}{status, txHash, applicationID}
+		args = []interface{}{change.Status, change.TxHash, change.ApplicationID}
 	case "refund":
 		query = `
 			UPDATE applications 
 			SET payment_status = $1, escrow_tx_hash_refund = $2
 			WHERE id = $3
 		`
-		args = []interface{}{status, txHash, applicationID}
+		args = []interface{}{change.Status, change.TxHash, change.ApplicationID}
 	default:
 		query = `
 			UPDATE applications 
 			SET payment_status = $1
 			WHERE id = $2
 		`
-		args = []interface{}{status, applicationID}
+		args = []interface{}{change.Status, change.ApplicationID}
+	}
+	if len(change.FromStatuses) > 0 {
+		args = append(args, change.FromStatuses)
+		query += fmt.Sprintf(" AND COALESCE(payment_status, 'pending_deposit') = ANY($%d)", len(args))
 	}
 
-	_, err := db.Pool.Exec(ctx, query, args...)
+	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("error updating payment status: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error updating payment status: %w", err)
+	}
+	if len(change.FromStatuses) > 0 && tag.RowsAffected() == 0 {
+		db.invalidateDetails(change.ApplicationID)
+		return ErrStatusConflict
+	}
+
+	eventQuery := `
+		INSERT INTO payment_events (application_id, status, tx_hash, block_number, actor, trace_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`
+	if _, err := tx.Exec(ctx, eventQuery, change.ApplicationID, change.Status, change.TxHash, change.BlockNumber, change.Actor, change.TraceID); err != nil {
+		return fmt.Errorf("error recording payment event: %w", err)
+	}
+	if change.Status == paymentstatus.Released {
+		if err := executeReleaseAuthorization(ctx, tx, change.ApplicationID); err != nil {
+			return err
+		}
+	}
+	if change.IntentID != 0 {
+		if err := resolveChainIntent(ctx, tx, change.IntentID, IntentSent, change.TxHash, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing payment status: %w", err)
+	}
+	db.invalidateDetails(change.ApplicationID)
 
 	return nil
 }
 
 // ValidateApplicationForBlockchain checks if application is ready for blockchain operations
 func (db *DB) ValidateApplicationForBlockchain(ctx context.Context, applicationID int32) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	var status string
 	var applicantWallet, posterWallet *string
 	var agreedAmount *int32
@@ -160,7 +456,7 @@ func (db *DB) ValidateApplicationForBlockchain(ctx context.Context, applicationI
 		&posterWallet,
 	)
 	if err != nil {
-		return fmt.Errorf("application not found: %v", err)
+		return fmt.Errorf("application not found: %w", err)
 	}
 
 	if applicantWallet == nil || *applicantWallet == "" {
@@ -178,6 +474,14 @@ func (db *DB) ValidateApplicationForBlockchain(ctx context.Context, applicationI
 	return nil
 }
 
+// Ping checks the database answers a round trip
+func (db *DB) Ping(ctx context.Context) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return db.Pool.Ping(ctx)
+}
+
 // Close closes the database connection pool
 func (db *DB) Close() {
 	db.Pool.Close()
@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Deferred operation states
+const (
+	DeferredStatusDeferred  = "deferred"
+	DeferredStatusPaused    = "paused" // waiting for the client to confirm a moved ETH/USD rate
+	DeferredStatusSubmitted = "submitted"
+	DeferredStatusExpired   = "expired"
+	DeferredStatusFailed    = "failed"
+)
+
+// DeferredOperation is a chain operation waiting for gas prices to drop or for
+// a transient RPC failure to clear
+type DeferredOperation struct {
+	ID            int64
+	ApplicationID int32
+	Operation     string
+	Params        OperationParams
+	Status        string
+	Deadline      time.Time
+	TxHash        *string
+	LastError     *string
+	Attempts      int
+	NextAttemptAt *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// OperationParams holds the request data needed to submit or replay a chain operation
+type OperationParams struct {
+	JobID             uint64 `json:"job_id"`
+	FreelancerAddress string `json:"freelancer_address,omitempty"`
+	ClientAddress     string `json:"client_address,omitempty"`
+	USDAmount         string `json:"usd_amount,omitempty"`
+	RefundReason      string `json:"refund_reason,omitempty"`
+	TopUpID           int64  `json:"top_up_id,omitempty"`            // top_up_fund reviews only
+	QuotedETHUSDPrice string `json:"quoted_eth_usd_price,omitempty"` // post_job only: rate the client agreed to, 8 decimals
+	TraceID           string `json:"trace_id,omitempty"`             // the API request that asked for the operation
+	Priority          string `json:"priority,omitempty"`             // "urgent" for the urgent lane; empty is normal
+	FeeStrategy       string `json:"fee_strategy,omitempty"`         // slow, standard or fast; empty uses the operation's default
+}
+
+// ErrNotPaused is returned when confirming the price of an operation that isn't paused
+var ErrNotPaused = errors.New("operation is not paused for a price confirmation")
+
+// CreateDeferredOperation queues an operation for later submission
+func (db *DB) CreateDeferredOperation(ctx context.Context, applicationID int32, operation string, params OperationParams, deadline time.Time, reason string) (*DeferredOperation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding deferred operation params: %w", err)
+	}
+
+	query := `
+		INSERT INTO deferred_operations (application_id, operation, params, status, deadline, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	op := &DeferredOperation{
+		ApplicationID: applicationID,
+		Operation:     operation,
+		Params:        params,
+		Status:        DeferredStatusDeferred,
+		Deadline:      deadline,
+		LastError:     &reason,
+	}
+	err = db.Pool.QueryRow(ctx, query, applicationID, operation, paramsJSON, DeferredStatusDeferred, deadline, reason).Scan(
+		&op.ID,
+		&op.CreatedAt,
+		&op.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating deferred operation: %w", err)
+	}
+
+	return op, nil
+}
+
+// ScheduleDeferredOperation queues an operation that is not to be submitted
+// before at
+func (db *DB) ScheduleDeferredOperation(ctx context.Context, applicationID int32, operation string, params OperationParams, at, deadline time.Time, reason string) (*DeferredOperation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding deferred operation params: %w", err)
+	}
+
+	query := `
+		INSERT INTO deferred_operations (application_id, operation, params, status, deadline, last_error, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+
+	op := &DeferredOperation{
+		ApplicationID: applicationID,
+		Operation:     operation,
+		Params:        params,
+		Status:        DeferredStatusDeferred,
+		Deadline:      deadline,
+		LastError:     &reason,
+		NextAttemptAt: &at,
+	}
+	err = db.Pool.QueryRow(ctx, query, applicationID, operation, paramsJSON, DeferredStatusDeferred, deadline, reason, at).Scan(
+		&op.ID,
+		&op.CreatedAt,
+		&op.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error scheduling deferred operation: %w", err)
+	}
+
+	return op, nil
+}
+
+// GetPendingDeferredOperation returns the still-deferred or paused operation for an application, or nil if none
+func (db *DB) GetPendingDeferredOperation(ctx context.Context, applicationID int32) (*DeferredOperation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, application_id, operation, params, status, deadline, tx_hash, last_error, attempts, next_attempt_at, created_at, updated_at
+		FROM deferred_operations
+		WHERE application_id = $1 AND status = ANY($2)
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	op, err := scanDeferredOperation(db.Pool.QueryRow(ctx, query, applicationID, []string{DeferredStatusDeferred, DeferredStatusPaused}))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying deferred operation: %w", err)
+	}
+
+	return op, nil
+}
+
+// ListDueDeferredOperations returns deferred operations whose next attempt is due, oldest first
+func (db *DB) ListDueDeferredOperations(ctx context.Context) ([]*DeferredOperation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, application_id, operation, params, status, deadline, tx_hash, last_error, attempts, next_attempt_at, created_at, updated_at
+		FROM deferred_operations
+		WHERE status = $1 AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+		ORDER BY id
+	`
+
+	rows, err := db.Pool.Query(ctx, query, DeferredStatusDeferred)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deferred operations: %w", err)
+	}
+	defer rows.Close()
+
+	var ops []*DeferredOperation
+	for rows.Next() {
+		op, err := scanDeferredOperation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning deferred operation: %w", err)
+		}
+		ops = append(ops, op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading deferred operations: %w", err)
+	}
+
+	return ops, nil
+}
+
+// UpdateDeferredOperation records the outcome of a deferred operation
+func (db *DB) UpdateDeferredOperation(ctx context.Context, id int64, status string, txHash *string, lastError *string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE deferred_operations
+		SET status = $1, tx_hash = $2, last_error = $3, updated_at = NOW()
+		WHERE id = $4
+	`
+
+	_, err := db.Pool.Exec(ctx, query, status, txHash, lastError, id)
+	if err != nil {
+		return fmt.Errorf("error updating deferred operation: %w", err)
+	}
+
+	return nil
+}
+
+// RescheduleDeferredOperation records a failed attempt and when to try again
+func (db *DB) RescheduleDeferredOperation(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE deferred_operations
+		SET attempts = $1, next_attempt_at = $2, last_error = $3, updated_at = NOW()
+		WHERE id = $4
+	`
+
+	_, err := db.Pool.Exec(ctx, query, attempts, nextAttemptAt, lastError, id)
+	if err != nil {
+		return fmt.Errorf("error rescheduling deferred operation: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmDeferredOperationPrice records the ETH/USD rate a client confirmed for
+// a paused operation and returns it to the queue to be submitted. It returns
+// nil if there is no such operation and ErrNotPaused if it isn't paused.
+func (db *DB) ConfirmDeferredOperationPrice(ctx context.Context, id int64, quotedPrice string) (*DeferredOperation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE deferred_operations
+		SET status = $1, params = jsonb_set(params, '{quoted_eth_usd_price}', to_jsonb($2::text)),
+			next_attempt_at = NULL, updated_at = NOW()
+		WHERE id = $3 AND status = $4
+		RETURNING id, application_id, operation, params, status, deadline, tx_hash, last_error, attempts, next_attempt_at, created_at, updated_at
+	`
+
+	op, err := scanDeferredOperation(db.Pool.QueryRow(ctx, query, DeferredStatusDeferred, quotedPrice, id, DeferredStatusPaused))
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM deferred_operations WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("error querying deferred operation: %w", err)
+		}
+		if !exists {
+			return nil, nil
+		}
+		return nil, ErrNotPaused
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error confirming deferred operation price: %w", err)
+	}
+
+	return op, nil
+}
+
+func scanDeferredOperation(row pgx.Row) (*DeferredOperation, error) {
+	op := &DeferredOperation{}
+	var paramsJSON []byte
+	err := row.Scan(
+		&op.ID,
+		&op.ApplicationID,
+		&op.Operation,
+		&paramsJSON,
+		&op.Status,
+		&op.Deadline,
+		&op.TxHash,
+		&op.LastError,
+		&op.Attempts,
+		&op.NextAttemptAt,
+		&op.CreatedAt,
+		&op.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(paramsJSON, &op.Params); err != nil {
+		return nil, fmt.Errorf("error decoding deferred operation params: %w", err)
+	}
+
+	return op, nil
+}
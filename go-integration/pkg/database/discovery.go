@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/jobid"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Outcomes of matching a discovered escrow to an application
+const (
+	DiscoveryLinked    = "linked"    // the application's payment record now follows the escrow
+	DiscoveryUnmatched = "unmatched" // no application has the escrow's job ID
+	DiscoveryMismatch  = "mismatch"  // the application's wallets or amount differ from the escrow's
+	DiscoveryConflict  = "conflict"  // the application already has a different payment record
+)
+
+// DiscoveredEscrow is an escrow found on-chain that the gateway did not create
+type DiscoveredEscrow struct {
+	JobID             uint64
+	PaymentStatus     paymentstatus.Status
+	ClientAddress     string
+	FreelancerAddress string
+	USDAmount         string
+	ETHAmountWei      string
+	DepositTxHash     *string
+	ReleaseTxHash     *string
+	RefundTxHash      *string
+	BlockNumber       int64 // of the posting
+	ApplicationID     *int32
+	Outcome           string
+	Reason            string
+	DiscoveredAt      time.Time
+	UpdatedAt         time.Time
+	LinkedAt          *time.Time
+}
+
+// SaveDiscoveredEscrow records an escrow and the outcome of matching it,
+// updating an earlier scan's row for the same job
+func (db *DB) SaveDiscoveredEscrow(ctx context.Context, escrow DiscoveredEscrow) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return saveDiscoveredEscrow(ctx, db.Pool, escrow)
+}
+
+func saveDiscoveredEscrow(ctx context.Context, q execer, escrow DiscoveredEscrow) error {
+	query := `
+		INSERT INTO discovered_escrows
+			(job_id, payment_status, client_address, freelancer_address, usd_amount, eth_amount_wei,
+			 tx_hash_deposit, tx_hash_release, tx_hash_refund, block_number, application_id, outcome, reason, linked_at)
+		VALUES ($1::numeric, $2, $3, $4, $5::numeric, $6::numeric, $7, $8, $9, $10, $11, $12, $13,
+			CASE WHEN $12 = 'linked' THEN NOW() END)
+		ON CONFLICT (job_id) DO UPDATE SET
+			payment_status = EXCLUDED.payment_status,
+			client_address = EXCLUDED.client_address,
+			freelancer_address = EXCLUDED.freelancer_address,
+			usd_amount = EXCLUDED.usd_amount,
+			eth_amount_wei = EXCLUDED.eth_amount_wei,
+			tx_hash_deposit = EXCLUDED.tx_hash_deposit,
+			tx_hash_release = EXCLUDED.tx_hash_release,
+			tx_hash_refund = EXCLUDED.tx_hash_refund,
+			block_number = EXCLUDED.block_number,
+			application_id = EXCLUDED.application_id,
+			outcome = EXCLUDED.outcome,
+			reason = EXCLUDED.reason,
+			updated_at = NOW(),
+			linked_at = COALESCE(discovered_escrows.linked_at, EXCLUDED.linked_at)
+	`
+	_, err := q.Exec(ctx, query, strconv.FormatUint(escrow.JobID, 10), escrow.PaymentStatus, escrow.ClientAddress, escrow.FreelancerAddress,
+		escrow.USDAmount, escrow.ETHAmountWei, escrow.DepositTxHash, escrow.ReleaseTxHash, escrow.RefundTxHash,
+		escrow.BlockNumber, escrow.ApplicationID, escrow.Outcome, escrow.Reason)
+	if err != nil {
+		return fmt.Errorf("error saving discovered escrow: %w", err)
+	}
+	return nil
+}
+
+// LinkDiscoveredEscrow writes a discovered escrow's payment record to the
+// application with its job ID and records the escrow as linked, in one
+// transaction. The application must still be pending_deposit, otherwise
+// ErrStatusConflict is returned and nothing changes; ErrEscrowJobConflict
+// likewise when it is mapped to another escrow job ID. A payment event is
+// recorded for the deposit and for the release or refund that followed it.
+func (db *DB) LinkDiscoveredEscrow(ctx context.Context, escrow DiscoveredEscrow, actor string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	applicationID, err := jobid.ApplicationID(escrow.JobID)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var status paymentstatus.Status
+	selectQuery := `SELECT COALESCE(payment_status, 'pending_deposit') FROM applications WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, selectQuery, applicationID).Scan(&status); err != nil {
+		return fmt.Errorf("error querying payment status: %w", err)
+	}
+	if status != paymentstatus.PendingDeposit {
+		return ErrStatusConflict
+	}
+
+	updateQuery := `
+		UPDATE applications
+		SET payment_status = $1, escrow_job_id = $2, escrow_tx_hash_deposit = $3, escrow_tx_hash_release = $4, escrow_tx_hash_refund = $5
+		WHERE id = $2
+	`
+	if _, err := tx.Exec(ctx, updateQuery, escrow.PaymentStatus, applicationID, escrow.DepositTxHash, escrow.ReleaseTxHash, escrow.RefundTxHash); err != nil {
+		return fmt.Errorf("error linking discovered escrow: %w", err)
+	}
+	if err := recordEscrowJob(ctx, tx, applicationID, escrow.JobID); err != nil {
+		return err
+	}
+
+	eventQuery := `
+		INSERT INTO payment_events (application_id, status, tx_hash, block_number, actor)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := tx.Exec(ctx, eventQuery, applicationID, paymentstatus.Deposited, escrow.DepositTxHash, escrow.BlockNumber, ActorDiscovery); err != nil {
+		return fmt.Errorf("error recording payment event: %w", err)
+	}
+	settledHash := escrow.ReleaseTxHash
+	if escrow.PaymentStatus == paymentstatus.Refunded {
+		settledHash = escrow.RefundTxHash
+	}
+	if escrow.PaymentStatus != paymentstatus.Deposited {
+		if _, err := tx.Exec(ctx, eventQuery, applicationID, escrow.PaymentStatus, settledHash, nil, ActorDiscovery); err != nil {
+			return fmt.Errorf("error recording payment event: %w", err)
+		}
+	}
+
+	escrow.ApplicationID = &applicationID
+	escrow.Outcome = DiscoveryLinked
+	escrow.Reason = ""
+	if err := saveDiscoveredEscrow(ctx, tx, escrow); err != nil {
+		return err
+	}
+
+	before, _ := json.Marshal(PaymentRecord{PaymentStatus: status})
+	after, _ := json.Marshal(PaymentRecord{
+		PaymentStatus: escrow.PaymentStatus,
+		DepositTxHash: escrow.DepositTxHash,
+		ReleaseTxHash: escrow.ReleaseTxHash,
+		RefundTxHash:  escrow.RefundTxHash,
+	})
+	entry := AuditEntry{
+		Action:        "escrow.discover",
+		ApplicationID: &applicationID,
+		Actor:         actor,
+		Reason:        fmt.Sprintf("escrow posted outside the gateway at block %d", escrow.BlockNumber),
+		Before:        before,
+		After:         after,
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing discovered escrow: %w", err)
+	}
+	db.invalidateDetails(applicationID)
+
+	return nil
+}
+
+// DiscoveredEscrowListSpec is what GET /admin/escrows/discovered can sort and
+// filter on
+var DiscoveredEscrowListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"job_id":         {Column: "job_id", Kind: listquery.Int, Sortable: true, Ops: []listquery.Op{listquery.Eq, listquery.In, listquery.Gt, listquery.Lt}},
+		"application_id": {Column: "application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"outcome":        {Column: "outcome", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+		"payment_status": {Column: "payment_status", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"block_number":   {Column: "block_number", Kind: listquery.Int, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+		"discovered_at":  {Column: "discovered_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+		"updated_at":     {Column: "updated_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+	},
+	ID:           "job_id",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// FindDiscoveredEscrows returns a page of recorded escrows matching params
+// and the cursor of the next page, or "" if it is the last
+func (db *DB) FindDiscoveredEscrows(ctx context.Context, params listquery.Params) ([]DiscoveredEscrow, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var args []interface{}
+	query := `
+		SELECT job_id::text, payment_status, client_address, freelancer_address, usd_amount::text, eth_amount_wei::text,
+			tx_hash_deposit, tx_hash_release, tx_hash_refund, block_number, application_id, outcome, reason,
+			discovered_at, updated_at, linked_at
+		FROM discovered_escrows
+		WHERE TRUE` + listClause(params, &args)
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error finding discovered escrows: %w", err)
+	}
+	defer rows.Close()
+
+	var escrows []DiscoveredEscrow
+	for rows.Next() {
+		var escrow DiscoveredEscrow
+		var jobID string
+		err := rows.Scan(&jobID, &escrow.PaymentStatus, &escrow.ClientAddress, &escrow.FreelancerAddress, &escrow.USDAmount, &escrow.ETHAmountWei,
+			&escrow.DepositTxHash, &escrow.ReleaseTxHash, &escrow.RefundTxHash, &escrow.BlockNumber, &escrow.ApplicationID, &escrow.Outcome, &escrow.Reason,
+			&escrow.DiscoveredAt, &escrow.UpdatedAt, &escrow.LinkedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("error scanning discovered escrow: %w", err)
+		}
+		if escrow.JobID, err = strconv.ParseUint(jobID, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("error scanning discovered escrow: %w", err)
+		}
+		escrows = append(escrows, escrow)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error finding discovered escrows: %w", err)
+	}
+
+	escrows, next := listquery.Page(escrows, params, discoveredEscrowListValue)
+	return escrows, next, nil
+}
+
+func discoveredEscrowListValue(escrow DiscoveredEscrow, field string) any {
+	switch field {
+	case "block_number":
+		return escrow.BlockNumber
+	case "discovered_at":
+		return escrow.DiscoveredAt
+	case "updated_at":
+		return escrow.UpdatedAt
+	default:
+		return int64(escrow.JobID)
+	}
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DisplayCurrencies are the ISO 4217 currencies a job's client and freelancer
+// see its amount in, besides USD. Empty means no preference.
+type DisplayCurrencies struct {
+	Client     string `json:"client,omitempty"`
+	Freelancer string `json:"freelancer,omitempty"`
+}
+
+// GetDisplayCurrencies returns an application's display currencies, or nil if none are set
+func (db *DB) GetDisplayCurrencies(ctx context.Context, applicationID int32) (*DisplayCurrencies, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return queryDisplayCurrencies(ctx, db.Pool, applicationID)
+}
+
+// SetDisplayCurrencies replaces an application's display currencies and
+// records the change in the audit log. Codes must already be normalized.
+func (db *DB) SetDisplayCurrencies(ctx context.Context, applicationID int32, currencies DisplayCurrencies, actor string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := queryDisplayCurrencies(ctx, tx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO job_display_currencies (application_id, client_currency, freelancer_currency)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''))
+		ON CONFLICT (application_id) DO UPDATE
+		SET client_currency = EXCLUDED.client_currency,
+			freelancer_currency = EXCLUDED.freelancer_currency,
+			updated_at = NOW()
+	`
+	if _, err := tx.Exec(ctx, query, applicationID, currencies.Client, currencies.Freelancer); err != nil {
+		return fmt.Errorf("error setting display currencies: %w", err)
+	}
+
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(currencies)
+	if err := insertAudit(ctx, tx, AuditEntry{
+		Action:        "display_currencies.set",
+		ApplicationID: &applicationID,
+		Actor:         actor,
+		Before:        beforeJSON,
+		After:         afterJSON,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing display currencies: %w", err)
+	}
+	return nil
+}
+
+func queryDisplayCurrencies(ctx context.Context, q querier, applicationID int32) (*DisplayCurrencies, error) {
+	query := `
+		SELECT COALESCE(client_currency, ''), COALESCE(freelancer_currency, '')
+		FROM job_display_currencies
+		WHERE application_id = $1
+	`
+	var currencies DisplayCurrencies
+	err := q.QueryRow(ctx, query, applicationID).Scan(&currencies.Client, &currencies.Freelancer)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying display currencies: %w", err)
+	}
+	return &currencies, nil
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrDisputeResolved is returned when evidence is added to, or a resolution
+// recorded for, a dispute that has already been resolved
+var ErrDisputeResolved = errors.New("dispute is already resolved")
+
+// Dispute outcomes. Resolving a dispute records the decision; the release or
+// refund that carries it out is made through the usual endpoints.
+const (
+	DisputeOutcomeRelease   = "release"   // the freelancer is paid
+	DisputeOutcomeRefund    = "refund"    // the client is refunded
+	DisputeOutcomeWithdrawn = "withdrawn" // the parties settled without a decision
+)
+
+// Dispute is a disagreement over a job that an arbitrator decides
+type Dispute struct {
+	ID            int64      `json:"id"`
+	ApplicationID int32      `json:"application_id"`
+	Reason        string     `json:"reason"`
+	OpenedBy      string     `json:"opened_by"`
+	OpenedAt      time.Time  `json:"opened_at"`
+	Outcome       *string    `json:"outcome,omitempty"`
+	Justification *string    `json:"justification,omitempty"`
+	ResolvedBy    *string    `json:"resolved_by,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+// DisputeEvidence is a reference to material backing a dispute, such as a
+// deliverable or a chat transcript. The material itself is stored elsewhere;
+// a URI locates it and a SHA-256 proves it hasn't changed since.
+type DisputeEvidence struct {
+	ID          int64     `json:"id"`
+	DisputeID   int64     `json:"dispute_id"`
+	Kind        string    `json:"kind"`
+	URI         *string   `json:"uri,omitempty"`
+	SHA256      *string   `json:"sha256,omitempty"` // hex
+	Description string    `json:"description"`
+	SubmittedBy string    `json:"submitted_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const disputeColumns = `id, application_id, reason, opened_by, opened_at, outcome, justification, resolved_by, resolved_at`
+
+func scanDispute(row pgx.Row) (*Dispute, error) {
+	var d Dispute
+	err := row.Scan(&d.ID, &d.ApplicationID, &d.Reason, &d.OpenedBy, &d.OpenedAt, &d.Outcome, &d.Justification, &d.ResolvedBy, &d.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// OpenDispute opens a dispute on an application. It returns the dispute
+// already open and false if there is one, which is left unchanged.
+func (db *DB) OpenDispute(ctx context.Context, applicationID int32, reason, actor string) (*Dispute, bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO job_disputes (application_id, reason, opened_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (application_id) WHERE resolved_at IS NULL DO NOTHING
+		RETURNING ` + disputeColumns
+	dispute, err := scanDispute(tx.QueryRow(ctx, query, applicationID, reason, actor))
+	if errors.Is(err, pgx.ErrNoRows) {
+		open, err := scanDispute(tx.QueryRow(ctx, `SELECT `+disputeColumns+` FROM job_disputes WHERE application_id = $1 AND resolved_at IS NULL`, applicationID))
+		if err != nil {
+			return nil, false, fmt.Errorf("error querying open dispute: %w", err)
+		}
+		return open, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error opening dispute: %w", err)
+	}
+
+	after, _ := json.Marshal(dispute)
+	if err := insertAudit(ctx, tx, AuditEntry{Action: "dispute.open", ApplicationID: &applicationID, Actor: actor, Reason: reason, After: after}); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("error committing dispute: %w", err)
+	}
+	return dispute, true, nil
+}
+
+// GetDispute returns an application's open dispute, or its latest resolved
+// one, or nil if it has never been disputed
+func (db *DB) GetDispute(ctx context.Context, applicationID int32) (*Dispute, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + disputeColumns + ` FROM job_disputes
+		WHERE application_id = $1
+		ORDER BY resolved_at IS NULL DESC, id DESC
+		LIMIT 1`
+	dispute, err := scanDispute(db.Pool.QueryRow(ctx, query, applicationID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying dispute: %w", err)
+	}
+	return dispute, nil
+}
+
+// AddDisputeEvidence appends evidence to an open dispute. Evidence can't be
+// changed or removed afterwards.
+func (db *DB) AddDisputeEvidence(ctx context.Context, evidence DisputeEvidence) (*DisputeEvidence, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the dispute so evidence can't land after its resolution
+	var applicationID int32
+	var resolvedAt *time.Time
+	err = tx.QueryRow(ctx, `SELECT application_id, resolved_at FROM job_disputes WHERE id = $1 FOR UPDATE`, evidence.DisputeID).Scan(&applicationID, &resolvedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error querying dispute: %w", err)
+	}
+	if resolvedAt != nil {
+		return nil, ErrDisputeResolved
+	}
+
+	query := `
+		INSERT INTO dispute_evidence (dispute_id, kind, uri, sha256, description, submitted_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	recorded := evidence
+	if err := tx.QueryRow(ctx, query, evidence.DisputeID, evidence.Kind, evidence.URI, evidence.SHA256, evidence.Description, evidence.SubmittedBy).
+		Scan(&recorded.ID, &recorded.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error adding dispute evidence: %w", err)
+	}
+
+	after, _ := json.Marshal(recorded)
+	if err := insertAudit(ctx, tx, AuditEntry{Action: "dispute.evidence", ApplicationID: &applicationID, Actor: evidence.SubmittedBy, After: after}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing dispute evidence: %w", err)
+	}
+	return &recorded, nil
+}
+
+// ListDisputeEvidence returns a dispute's evidence in the order it was submitted
+func (db *DB) ListDisputeEvidence(ctx context.Context, disputeID int64) ([]*DisputeEvidence, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, dispute_id, kind, uri, sha256, description, submitted_by, created_at
+		FROM dispute_evidence
+		WHERE dispute_id = $1
+		ORDER BY id
+	`
+	rows, err := db.Pool.Query(ctx, query, disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying dispute evidence: %w", err)
+	}
+	defer rows.Close()
+
+	var evidence []*DisputeEvidence
+	for rows.Next() {
+		var e DisputeEvidence
+		if err := rows.Scan(&e.ID, &e.DisputeID, &e.Kind, &e.URI, &e.SHA256, &e.Description, &e.SubmittedBy, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning dispute evidence: %w", err)
+		}
+		evidence = append(evidence, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying dispute evidence: %w", err)
+	}
+	return evidence, nil
+}
+
+// ResolveDispute records the arbitrator's decision on an open dispute and the
+// justification for it
+func (db *DB) ResolveDispute(ctx context.Context, disputeID int64, outcome, justification, actor string) (*Dispute, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE job_disputes
+		SET outcome = $2, justification = $3, resolved_by = $4, resolved_at = NOW()
+		WHERE id = $1 AND resolved_at IS NULL
+		RETURNING ` + disputeColumns
+	dispute, err := scanDispute(tx.QueryRow(ctx, query, disputeID, outcome, justification, actor))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDisputeResolved
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error resolving dispute: %w", err)
+	}
+
+	after, _ := json.Marshal(dispute)
+	if err := insertAudit(ctx, tx, AuditEntry{
+		Action:        "dispute.resolve",
+		ApplicationID: &dispute.ApplicationID,
+		Actor:         actor,
+		Reason:        justification,
+		After:         after,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing dispute resolution: %w", err)
+	}
+	return dispute, nil
+}
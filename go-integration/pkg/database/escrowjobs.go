@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrEscrowJobConflict is returned by RecordEscrowJob when the application or
+// the job ID is already mapped to another
+var ErrEscrowJobConflict = errors.New("escrow job ID mapping conflict")
+
+// RecordEscrowJob records the on-chain job ID an application's escrow is
+// posted under. Recording the same pair again is a no-op; an application
+// already mapped to another job ID, or a job ID mapped to another
+// application, returns ErrEscrowJobConflict.
+func (db *DB) RecordEscrowJob(ctx context.Context, applicationID int32, jobID uint64) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return recordEscrowJob(ctx, db.Pool, applicationID, jobID)
+}
+
+// querier is satisfied by both the pool and a transaction
+type querier interface {
+	execer
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// GetEscrowJobApplication returns the application an escrow job ID is posted
+// for, and false if the job isn't an application's, e.g. a retainer period
+func (db *DB) GetEscrowJobApplication(ctx context.Context, jobID uint64) (int32, bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var applicationID int32
+	err := db.Pool.QueryRow(ctx, `SELECT application_id FROM escrow_jobs WHERE job_id = $1::numeric`, strconv.FormatUint(jobID, 10)).Scan(&applicationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error querying escrow job application: %w", err)
+	}
+	return applicationID, true, nil
+}
+
+func recordEscrowJob(ctx context.Context, q querier, applicationID int32, jobID uint64) error {
+	insertQuery := `
+		INSERT INTO escrow_jobs (application_id, job_id)
+		VALUES ($1, $2::numeric)
+		ON CONFLICT DO NOTHING
+	`
+	tag, err := q.Exec(ctx, insertQuery, applicationID, strconv.FormatUint(jobID, 10))
+	if err != nil {
+		return fmt.Errorf("error recording escrow job ID: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return recordStatusSeen(ctx, q, applicationID)
+	}
+
+	// Either side may already be mapped; report the first mapping that differs
+	var mappedApplication int32
+	var mappedJob string
+	selectQuery := `
+		SELECT application_id, job_id::text
+		FROM escrow_jobs
+		WHERE (application_id = $1) <> (job_id = $2::numeric) AND (application_id = $1 OR job_id = $2::numeric)
+		LIMIT 1
+	`
+	err = q.QueryRow(ctx, selectQuery, applicationID, strconv.FormatUint(jobID, 10)).Scan(&mappedApplication, &mappedJob)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error querying escrow job ID: %w", err)
+	}
+	return fmt.Errorf("%w: application %d is mapped to job ID %s", ErrEscrowJobConflict, mappedApplication, mappedJob)
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// EventCheckpoint is the last block an event indexer has scanned, with the
+// hash it had then, so a reorganisation of that block can be noticed
+type EventCheckpoint struct {
+	Name        string    `json:"name"`
+	BlockNumber uint64    `json:"block_number"`
+	BlockHash   string    `json:"block_hash"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// IndexedEvent is an escrow event the indexer recorded, identified by its
+// transaction and log index
+type IndexedEvent struct {
+	TxHash            string    `json:"tx_hash"`
+	LogIndex          uint      `json:"log_index"`
+	BlockNumber       uint64    `json:"block_number"`
+	BlockHash         string    `json:"block_hash"`
+	Kind              string    `json:"kind"` // posted, released or cancelled
+	JobID             uint64    `json:"job_id"`
+	ClientAddress     *string   `json:"client_address,omitempty"`
+	FreelancerAddress *string   `json:"freelancer_address,omitempty"`
+	USDAmount         *string   `json:"usd_amount,omitempty"`
+	ETHAmountWei      string    `json:"eth_amount_wei"`
+	IndexedAt         time.Time `json:"indexed_at"`
+}
+
+// IndexResult is what recording a scanned block range changed
+type IndexResult struct {
+	Added   []IndexedEvent // events not indexed before
+	Removed []IndexedEvent // indexed events a reorganisation took out of the range
+}
+
+// GetEventCheckpoint returns an indexer's checkpoint, or nil if it has
+// never indexed a block
+func (db *DB) GetEventCheckpoint(ctx context.Context, name string) (*EventCheckpoint, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return queryEventCheckpoint(ctx, db.Pool, name, "")
+}
+
+func queryEventCheckpoint(ctx context.Context, q querier, name, lock string) (*EventCheckpoint, error) {
+	checkpoint := EventCheckpoint{Name: name}
+	var blockNumber int64
+	err := q.QueryRow(ctx, `SELECT block_number, block_hash, updated_at FROM event_checkpoints WHERE name = $1 `+lock, name).
+		Scan(&blockNumber, &checkpoint.BlockHash, &checkpoint.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying event checkpoint: %w", err)
+	}
+	checkpoint.BlockNumber = uint64(blockNumber)
+	return &checkpoint, nil
+}
+
+// IndexEvents records events as every escrow event from fromBlock through
+// checkpoint.BlockNumber and moves the indexer's checkpoint there, in one
+// transaction. Events are keyed by (tx_hash, log_index), so scanning a range
+// again never adds one twice: an event already indexed only has its block
+// updated, in case a reorganisation mined it again elsewhere. Indexed events
+// in the range that events no longer has were reorganised away and are
+// removed.
+func (db *DB) IndexEvents(ctx context.Context, fromBlock uint64, events []IndexedEvent, checkpoint EventCheckpoint) (*IndexResult, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Replicas indexing the same range wait for each other
+	if _, err := queryEventCheckpoint(ctx, tx, checkpoint.Name, "FOR UPDATE"); err != nil {
+		return nil, err
+	}
+
+	existing, err := queryIndexedEvents(ctx, tx, fromBlock, checkpoint.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	type eventKey struct {
+		txHash   string
+		logIndex uint
+	}
+	scanned := make(map[eventKey]bool, len(events))
+	for _, event := range events {
+		scanned[eventKey{event.TxHash, event.LogIndex}] = true
+	}
+
+	result := &IndexResult{}
+	for _, event := range existing {
+		if scanned[eventKey{event.TxHash, event.LogIndex}] {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM indexed_events WHERE tx_hash = $1 AND log_index = $2`, event.TxHash, int32(event.LogIndex)); err != nil {
+			return nil, fmt.Errorf("error removing reorganised event: %w", err)
+		}
+		result.Removed = append(result.Removed, event)
+	}
+
+	query := `
+		INSERT INTO indexed_events
+			(tx_hash, log_index, block_number, block_hash, kind, job_id,
+			 client_address, freelancer_address, usd_amount, eth_amount_wei)
+		VALUES ($1, $2, $3, $4, $5, $6::numeric, $7, $8, $9::numeric, $10::numeric)
+		ON CONFLICT (tx_hash, log_index) DO UPDATE
+		SET block_number = EXCLUDED.block_number, block_hash = EXCLUDED.block_hash
+		WHERE indexed_events.block_hash <> EXCLUDED.block_hash
+		RETURNING indexed_at, xmax = 0
+	`
+	for _, event := range events {
+		var inserted bool
+		err := tx.QueryRow(ctx, query, event.TxHash, int32(event.LogIndex), int64(event.BlockNumber), event.BlockHash, event.Kind,
+			strconv.FormatUint(event.JobID, 10), event.ClientAddress, event.FreelancerAddress, event.USDAmount, event.ETHAmountWei).
+			Scan(&event.IndexedAt, &inserted)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error indexing event: %w", err)
+		}
+		if inserted {
+			result.Added = append(result.Added, event)
+		}
+	}
+
+	if err := saveEventCheckpoint(ctx, tx, checkpoint); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing indexed events: %w", err)
+	}
+	return result, nil
+}
+
+// SaveEventCheckpoint moves a checkpoint that isn't saved along with indexed
+// events, such as that of a consumer of the index
+func (db *DB) SaveEventCheckpoint(ctx context.Context, checkpoint EventCheckpoint) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return saveEventCheckpoint(ctx, db.Pool, checkpoint)
+}
+
+func saveEventCheckpoint(ctx context.Context, q querier, checkpoint EventCheckpoint) error {
+	query := `
+		INSERT INTO event_checkpoints (name, block_number, block_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET block_number = EXCLUDED.block_number, block_hash = EXCLUDED.block_hash, updated_at = NOW()
+	`
+	if _, err := q.Exec(ctx, query, checkpoint.Name, int64(checkpoint.BlockNumber), checkpoint.BlockHash); err != nil {
+		return fmt.Errorf("error saving event checkpoint: %w", err)
+	}
+	return nil
+}
+
+// ListIndexedEvents returns the indexed events from fromBlock through
+// toBlock, in chain order
+func (db *DB) ListIndexedEvents(ctx context.Context, fromBlock, toBlock uint64) ([]IndexedEvent, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return queryIndexedEvents(ctx, db.Pool, fromBlock, toBlock)
+}
+
+func queryIndexedEvents(ctx context.Context, q querier, fromBlock, toBlock uint64) ([]IndexedEvent, error) {
+	query := `
+		SELECT tx_hash, log_index, block_number, block_hash, kind, job_id::text,
+			client_address, freelancer_address, usd_amount::text, eth_amount_wei::text, indexed_at
+		FROM indexed_events
+		WHERE block_number BETWEEN $1 AND $2
+		ORDER BY block_number, log_index
+	`
+	rows, err := q.Query(ctx, query, int64(fromBlock), int64(toBlock))
+	if err != nil {
+		return nil, fmt.Errorf("error querying indexed events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []IndexedEvent
+	for rows.Next() {
+		var event IndexedEvent
+		var logIndex int32
+		var blockNumber int64
+		var jobID string
+		if err := rows.Scan(&event.TxHash, &logIndex, &blockNumber, &event.BlockHash, &event.Kind, &jobID,
+			&event.ClientAddress, &event.FreelancerAddress, &event.USDAmount, &event.ETHAmountWei, &event.IndexedAt); err != nil {
+			return nil, fmt.Errorf("error scanning indexed event: %w", err)
+		}
+		event.LogIndex, event.BlockNumber = uint(logIndex), uint64(blockNumber)
+		if event.JobID, err = strconv.ParseUint(jobID, 10, 64); err != nil {
+			return nil, fmt.Errorf("error parsing indexed event job ID %q: %w", jobID, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading indexed events: %w", err)
+	}
+	return events, nil
+}
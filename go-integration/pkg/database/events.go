@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// ErrStatusConflict is returned by ApplyStatusChange when the application is
+// no longer in one of the change's FromStatuses
+var ErrStatusConflict = errors.New("payment status changed concurrently")
+
+// Actors that can change a payment status
+const (
+	ActorGateway    = "gateway"    // the gateway submitted a transaction
+	ActorPlatform   = "platform"   // the platform confirmed a transaction via the API
+	ActorReconciler = "reconciler" // the gateway found the transaction's receipt on-chain
+	ActorResync     = "resync"     // an operator overwrote the record from chain state
+	ActorDiscovery  = "discovery"  // a pre-existing escrow was linked to the application
+	ActorClient     = "client"     // the client funded the escrow from their own wallet
+	ActorIndexer    = "indexer"    // the escrow contract's event was indexed
+)
+
+// StatusChange is a payment status transition to apply and record
+type StatusChange struct {
+	ApplicationID int32
+	Status        paymentstatus.Status
+	TxHash        *string
+	TxType        string // "deposit", "release", "refund" or empty for status-only changes
+	BlockNumber   *int64
+	Actor         string
+	TraceID       string // the API request the change traces back to, if any
+
+	// FromStatuses, when set, applies the change only if the current status is
+	// one of them, so two concurrent confirmations can't both record it
+	FromStatuses []paymentstatus.Status
+
+	// IntentID, when set, is the chain intent the change is the outcome of. It
+	// is resolved as sent in the same transaction, flushed before returning.
+	IntentID int64
+}
+
+// PaymentEvent is a recorded payment status transition
+type PaymentEvent struct {
+	ID            int64
+	ApplicationID int32
+	Status        string
+	TxHash        *string
+	BlockNumber   *int64
+	Actor         string
+	TraceID       *string
+	CreatedAt     time.Time
+}
+
+// GetPaymentEvents returns an application's payment status history, oldest first
+func (db *DB) GetPaymentEvents(ctx context.Context, applicationID int32) ([]PaymentEvent, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, application_id, status, tx_hash, block_number, actor, trace_id, created_at
+		FROM payment_events
+		WHERE application_id = $1
+		ORDER BY id
+	`
+
+	rows, err := db.Pool.Query(ctx, query, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment events: %w", err)
+	}
+	return collectPaymentEvents(rows)
+}
+
+// PaymentEventListSpec is how GraphQL events pages: newest first by ID, with
+// the limit and cursor of other lists
+var PaymentEventListSpec = listquery.Spec{
+	Fields:       map[string]listquery.Field{"id": {Column: "id", Kind: listquery.Int, Sortable: true}},
+	ID:           "id",
+	DefaultSort:  "-id",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// PaymentEventFilter narrows ListPaymentEvents; zero fields match everything
+type PaymentEventFilter struct {
+	ApplicationID int32
+	Status        string
+	Actor         string
+	Since         time.Time
+	Page          listquery.Params // parsed with PaymentEventListSpec
+}
+
+// ListPaymentEvents returns a page of payment status transitions matching
+// filter, newest first, and the cursor of the next page, or "" if it is the
+// last
+func (db *DB) ListPaymentEvents(ctx context.Context, filter PaymentEventFilter) ([]PaymentEvent, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{filter.Since}
+	query := `
+		SELECT id, application_id, status, tx_hash, block_number, actor, trace_id, created_at
+		FROM payment_events
+		WHERE created_at >= $1
+	`
+	if filter.ApplicationID != 0 {
+		args = append(args, filter.ApplicationID)
+		query += fmt.Sprintf(" AND application_id = $%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		query += fmt.Sprintf(" AND actor = $%d", len(args))
+	}
+	query += listClause(filter.Page, &args)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing payment events: %w", err)
+	}
+	events, err := collectPaymentEvents(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	events, next := listquery.Page(events, filter.Page, func(event PaymentEvent, field string) any {
+		return event.ID
+	})
+	return events, next, nil
+}
+
+func collectPaymentEvents(rows pgx.Rows) ([]PaymentEvent, error) {
+	defer rows.Close()
+
+	var events []PaymentEvent
+	for rows.Next() {
+		var event PaymentEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.ApplicationID,
+			&event.Status,
+			&event.TxHash,
+			&event.BlockNumber,
+			&event.Actor,
+			&event.TraceID,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading payment events: %w", err)
+	}
+
+	return events, nil
+}
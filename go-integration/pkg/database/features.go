@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/features"
+)
+
+// ListFeatureFlagRules returns every stored feature flag rule
+func (db *DB) ListFeatureFlagRules(ctx context.Context) ([]features.Rule, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT flag, tenant, network_id, enabled
+		FROM feature_flags
+		ORDER BY flag, tenant, network_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []features.Rule
+	for rows.Next() {
+		var rule features.Rule
+		if err := rows.Scan(&rule.Flag, &rule.Tenant, &rule.NetworkID, &rule.Enabled); err != nil {
+			return nil, fmt.Errorf("error scanning feature flag: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying feature flags: %w", err)
+	}
+
+	return rules, nil
+}
+
+// SetFeatureFlagRule creates or replaces the rule for a flag, tenant and
+// network, recording the change in the audit log
+func (db *DB) SetFeatureFlagRule(ctx context.Context, rule features.Rule, actor, reason string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockFeatureFlagRule(ctx, tx, rule.Flag, rule.Tenant, rule.NetworkID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO feature_flags (flag, tenant, network_id, enabled, actor)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (flag, tenant, network_id) DO UPDATE
+		SET enabled = EXCLUDED.enabled, actor = EXCLUDED.actor, updated_at = NOW()
+	`
+	if _, err := tx.Exec(ctx, query, rule.Flag, rule.Tenant, rule.NetworkID, rule.Enabled, actor); err != nil {
+		return fmt.Errorf("error setting feature flag: %w", err)
+	}
+
+	if err := insertFeatureFlagAudit(ctx, tx, "feature_flag.set", before, &rule, actor, reason); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing feature flag: %w", err)
+	}
+	return nil
+}
+
+// DeleteFeatureFlagRule removes a rule so the flag falls back to less specific
+// rules or its default. It reports whether a rule existed.
+func (db *DB) DeleteFeatureFlagRule(ctx context.Context, flag features.Flag, tenant string, networkID int64, actor, reason string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockFeatureFlagRule(ctx, tx, flag, tenant, networkID)
+	if err != nil || before == nil {
+		return false, err
+	}
+
+	query := `DELETE FROM feature_flags WHERE flag = $1 AND tenant = $2 AND network_id = $3`
+	if _, err := tx.Exec(ctx, query, flag, tenant, networkID); err != nil {
+		return false, fmt.Errorf("error deleting feature flag: %w", err)
+	}
+
+	if err := insertFeatureFlagAudit(ctx, tx, "feature_flag.delete", before, nil, actor, reason); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("error committing feature flag: %w", err)
+	}
+	return true, nil
+}
+
+// lockFeatureFlagRule returns the current rule, or nil if there is none
+func lockFeatureFlagRule(ctx context.Context, tx pgx.Tx, flag features.Flag, tenant string, networkID int64) (*features.Rule, error) {
+	rule := &features.Rule{Flag: flag, Tenant: tenant, NetworkID: networkID}
+	query := `
+		SELECT enabled FROM feature_flags
+		WHERE flag = $1 AND tenant = $2 AND network_id = $3
+		FOR UPDATE
+	`
+	err := tx.QueryRow(ctx, query, flag, tenant, networkID).Scan(&rule.Enabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying feature flag: %w", err)
+	}
+	return rule, nil
+}
+
+func insertFeatureFlagAudit(ctx context.Context, q execer, action string, before, after *features.Rule, actor, reason string) error {
+	entry := AuditEntry{Action: action, Actor: actor, Reason: reason}
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		entry.After, _ = json.Marshal(after)
+	}
+	return insertAudit(ctx, q, entry)
+}
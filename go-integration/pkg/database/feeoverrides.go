@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FeeOverride is the platform fee a job's release is accounted at instead of
+// the contract's, with who approved it and why
+type FeeOverride struct {
+	ApplicationID int32
+	FeeBPS        int64
+	Reason        string
+	Approver      string // name of the admin key that set it
+	UpdatedAt     time.Time
+}
+
+// SetFeeOverride sets an application's fee override, replacing any earlier one
+func (db *DB) SetFeeOverride(ctx context.Context, override FeeOverride) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO fee_overrides (application_id, fee_bps, reason, approver)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (application_id) DO UPDATE
+		SET fee_bps = EXCLUDED.fee_bps, reason = EXCLUDED.reason, approver = EXCLUDED.approver, updated_at = NOW()
+	`
+
+	if _, err := db.Pool.Exec(ctx, query, override.ApplicationID, override.FeeBPS, override.Reason, override.Approver); err != nil {
+		return fmt.Errorf("error setting fee override: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeeOverride returns an application's fee override, or nil if its fee is the contract's
+func (db *DB) GetFeeOverride(ctx context.Context, applicationID int32) (*FeeOverride, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	override := &FeeOverride{ApplicationID: applicationID}
+	query := `SELECT fee_bps, reason, approver, updated_at FROM fee_overrides WHERE application_id = $1`
+	err := db.Pool.QueryRow(ctx, query, applicationID).Scan(&override.FeeBPS, &override.Reason, &override.Approver, &override.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying fee override: %w", err)
+	}
+
+	return override, nil
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Funding link states
+const (
+	FundingLinkOpen       = "open"        // waiting for the client's deposit
+	FundingLinkFunded     = "funded"      // deposited before the link expired
+	FundingLinkFundedLate = "funded_late" // deposited after the link expired
+	FundingLinkExpired    = "expired"     // expired without a deposit, still watched for a late one
+	FundingLinkSuperseded = "superseded"  // replaced by a later link
+)
+
+// FundingLink is a payment request sent to a client to fund a job's escrow
+// from their own wallet at a pinned amount, valid until it expires
+type FundingLink struct {
+	ID            int64
+	ApplicationID int32
+	USDAmount     int32
+	ETHUSDPrice   string // the rate ValueWei was quoted at
+	ValueWei      string
+	URI           string // EIP-681 request to call postJob with ValueWei
+	Actor         string
+	Status        string
+	ExpiresAt     time.Time
+	TxHash        *string    // the deposit, once found
+	FundedAt      *time.Time // when the deposit was mined
+	CreatedAt     time.Time
+	ResolvedAt    *time.Time
+}
+
+const fundingLinkColumns = `id, application_id, usd_amount, eth_usd_price::text, value_wei::text, uri, actor, status, expires_at, tx_hash, funded_at, created_at, resolved_at`
+
+func scanFundingLink(row pgx.Row) (*FundingLink, error) {
+	var l FundingLink
+	err := row.Scan(&l.ID, &l.ApplicationID, &l.USDAmount, &l.ETHUSDPrice, &l.ValueWei, &l.URI, &l.Actor, &l.Status, &l.ExpiresAt, &l.TxHash, &l.FundedAt, &l.CreatedAt, &l.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// CreateFundingLink records a funding link for an application, superseding
+// any earlier one still waiting for a deposit
+func (db *DB) CreateFundingLink(ctx context.Context, link FundingLink) (*FundingLink, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	supersedeQuery := `
+		UPDATE funding_links
+		SET status = $1, resolved_at = NOW()
+		WHERE application_id = $2 AND status IN ($3, $4)
+	`
+	if _, err := tx.Exec(ctx, supersedeQuery, FundingLinkSuperseded, link.ApplicationID, FundingLinkOpen, FundingLinkExpired); err != nil {
+		return nil, fmt.Errorf("error superseding funding link: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO funding_links (application_id, usd_amount, eth_usd_price, value_wei, uri, actor, status, expires_at)
+		VALUES ($1, $2, $3::numeric, $4::numeric, $5, $6, $7, $8)
+		RETURNING ` + fundingLinkColumns
+	created, err := scanFundingLink(tx.QueryRow(ctx, insertQuery, link.ApplicationID, link.USDAmount, link.ETHUSDPrice, link.ValueWei, link.URI, link.Actor, FundingLinkOpen, link.ExpiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error creating funding link: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing funding link: %w", err)
+	}
+	return created, nil
+}
+
+// GetFundingLink returns an application's latest funding link, or nil if none was generated
+func (db *DB) GetFundingLink(ctx context.Context, applicationID int32) (*FundingLink, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + fundingLinkColumns + ` FROM funding_links WHERE application_id = $1 ORDER BY id DESC LIMIT 1`
+	link, err := scanFundingLink(db.Pool.QueryRow(ctx, query, applicationID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying funding link: %w", err)
+	}
+	return link, nil
+}
+
+// ListWatchedFundingLinks returns the open links and those that expired
+// since expiredSince, oldest first, to check for their deposit
+func (db *DB) ListWatchedFundingLinks(ctx context.Context, expiredSince time.Time) ([]*FundingLink, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + fundingLinkColumns + `
+		FROM funding_links
+		WHERE status = $1 OR (status = $2 AND expires_at >= $3)
+		ORDER BY id
+	`
+	rows, err := db.Pool.Query(ctx, query, FundingLinkOpen, FundingLinkExpired, expiredSince)
+	if err != nil {
+		return nil, fmt.Errorf("error querying funding links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []*FundingLink
+	for rows.Next() {
+		link, err := scanFundingLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning funding link: %w", err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying funding links: %w", err)
+	}
+	return links, nil
+}
+
+// ResolveFundingLink moves a watched link to status, recording the deposit
+// that funded it if any. It returns false if the link was superseded meanwhile.
+func (db *DB) ResolveFundingLink(ctx context.Context, id int64, status string, txHash *string, fundedAt *time.Time) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE funding_links
+		SET status = $1, tx_hash = $2, funded_at = $3, resolved_at = NOW()
+		WHERE id = $4 AND status IN ($5, $6)
+	`
+	result, err := db.Pool.Exec(ctx, query, status, txHash, fundedAt, id, FundingLinkOpen, FundingLinkExpired)
+	if err != nil {
+		return false, fmt.Errorf("error resolving funding link: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
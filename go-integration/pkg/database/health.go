@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// Health snapshot states
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded" // slow, stalled or a background worker stopped
+	HealthStatusDown     = "down"     // the RPC node or the database could not be reached
+)
+
+// HealthSnapshot is the gateway's health at one moment
+type HealthSnapshot struct {
+	ID           int64
+	TakenAt      time.Time
+	Status       string
+	RPCLatencyMS *int64 // nil when the RPC node could not be reached
+	DBLatencyMS  *int64 // nil when the database could not be reached
+	LastBlock    *int64
+	Workers      []WorkerHealth
+	Problems     []string
+	Maintenance  bool // the gateway was read-only
+}
+
+// WorkerHealth is when a background worker last ran
+type WorkerHealth struct {
+	Name     string    `json:"name"`
+	LastRun  time.Time `json:"last_run"`
+	Interval string    `json:"interval"` // how often it is expected to run
+	Stale    bool      `json:"stale"`
+}
+
+// HealthSummary describes the snapshots taken since a time
+type HealthSummary struct {
+	Total         int64
+	OK            int64
+	DegradedSince *time.Time // first snapshot of the current outage, nil if the latest is ok
+}
+
+// RecordHealthSnapshots stores snapshots and deletes those taken before
+// olderThan. Snapshots are recorded in one batch so those taken while the
+// database was unreachable can be stored once it is back.
+func (db *DB) RecordHealthSnapshots(ctx context.Context, snapshots []*HealthSnapshot, olderThan time.Time) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO health_snapshots (taken_at, status, rpc_latency_ms, db_latency_ms, last_block, workers, problems, maintenance)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	for _, snapshot := range snapshots {
+		workers, err := json.Marshal(snapshot.Workers)
+		if err != nil {
+			return fmt.Errorf("error encoding health snapshot workers: %w", err)
+		}
+		problems, err := json.Marshal(snapshot.Problems)
+		if err != nil {
+			return fmt.Errorf("error encoding health snapshot problems: %w", err)
+		}
+		err = tx.QueryRow(ctx, query, snapshot.TakenAt, snapshot.Status, snapshot.RPCLatencyMS, snapshot.DBLatencyMS, snapshot.LastBlock, workers, problems, snapshot.Maintenance).Scan(&snapshot.ID)
+		if err != nil {
+			return fmt.Errorf("error recording health snapshot: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM health_snapshots WHERE taken_at < $1`, olderThan); err != nil {
+		return fmt.Errorf("error pruning health snapshots: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing health snapshots: %w", err)
+	}
+	return nil
+}
+
+// HealthSnapshotListSpec is what GET /admin/health-history can sort and filter snapshots on
+var HealthSnapshotListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"id":       {Column: "id", Kind: listquery.Int, Sortable: true},
+		"taken_at": {Column: "taken_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+		"status":   {Column: "status", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+	},
+	ID:           "id",
+	DefaultSort:  "-taken_at",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// FindHealthSnapshots returns a page of snapshots matching params and the
+// cursor of the next page, or "" if it is the last
+func (db *DB) FindHealthSnapshots(ctx context.Context, params listquery.Params) ([]*HealthSnapshot, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var args []interface{}
+	query := `
+		SELECT id, taken_at, status, rpc_latency_ms, db_latency_ms, last_block, workers, problems, maintenance
+		FROM health_snapshots
+		WHERE TRUE` + listClause(params, &args)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing health snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*HealthSnapshot
+	for rows.Next() {
+		snapshot := &HealthSnapshot{}
+		var workers, problems []byte
+		if err := rows.Scan(&snapshot.ID, &snapshot.TakenAt, &snapshot.Status, &snapshot.RPCLatencyMS, &snapshot.DBLatencyMS, &snapshot.LastBlock, &workers, &problems, &snapshot.Maintenance); err != nil {
+			return nil, "", fmt.Errorf("error scanning health snapshot: %w", err)
+		}
+		if err := json.Unmarshal(workers, &snapshot.Workers); err != nil {
+			return nil, "", fmt.Errorf("error decoding health snapshot workers: %w", err)
+		}
+		if err := json.Unmarshal(problems, &snapshot.Problems); err != nil {
+			return nil, "", fmt.Errorf("error decoding health snapshot problems: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error listing health snapshots: %w", err)
+	}
+
+	snapshots, next := listquery.Page(snapshots, params, healthSnapshotListValue)
+	return snapshots, next, nil
+}
+
+func healthSnapshotListValue(snapshot *HealthSnapshot, field string) any {
+	if field == "taken_at" {
+		return snapshot.TakenAt
+	}
+	return snapshot.ID
+}
+
+// GetHealthSummary counts the snapshots taken since a time and finds when the
+// current outage, if any, began
+func (db *DB) GetHealthSummary(ctx context.Context, since time.Time) (*HealthSummary, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE taken_at >= $1),
+			COUNT(*) FILTER (WHERE taken_at >= $1 AND status = $2),
+			(SELECT MIN(taken_at) FROM health_snapshots
+				WHERE status <> $2
+				AND taken_at > COALESCE((SELECT MAX(taken_at) FROM health_snapshots WHERE status = $2), '-infinity'))
+		FROM health_snapshots
+	`
+
+	summary := &HealthSummary{}
+	if err := db.Pool.QueryRow(ctx, query, since, HealthStatusOK).Scan(&summary.Total, &summary.OK, &summary.DegradedSince); err != nil {
+		return nil, fmt.Errorf("error summarising health snapshots: %w", err)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned by ClaimIdempotencyKey when the key
+	// was first sent with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+
+	// ErrIdempotencyKeyInFlight is returned by ClaimIdempotencyKey while the
+	// request that first sent the key is still being handled
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is in progress")
+)
+
+// IdempotentResponse is the response a request with an idempotency key got,
+// returned again to every retry
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	TxHash      *string // the transaction the request submitted, if any
+}
+
+// ClaimIdempotencyKey claims a client's idempotency key for a request
+// identified by requestHash. It returns nil when the caller should handle the
+// request and then complete or release the key, and the stored response when
+// the request was already handled. A key claimed before staleBefore and never
+// completed belongs to a request that was cut off, e.g. by a restart, and is
+// claimed again; chain intents keep its transaction from being sent twice.
+// Keys created before expireBefore are pruned.
+func (db *DB) ClaimIdempotencyKey(ctx context.Context, tenant, key, requestHash string, staleBefore, expireBefore time.Time) (*IdempotentResponse, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, expireBefore); err != nil {
+		return nil, fmt.Errorf("error pruning idempotency keys: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO idempotency_keys (tenant, key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+	tag, err := tx.Exec(ctx, insertQuery, tenant, key, requestHash)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming idempotency key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		var storedHash string
+		var claimedAt time.Time
+		var statusCode *int32
+		response := &IdempotentResponse{}
+		selectQuery := `
+			SELECT request_hash, claimed_at, status_code, COALESCE(content_type, ''), response_body, tx_hash
+			FROM idempotency_keys
+			WHERE tenant = $1 AND key = $2
+			FOR UPDATE
+		`
+		err := tx.QueryRow(ctx, selectQuery, tenant, key).
+			Scan(&storedHash, &claimedAt, &statusCode, &response.ContentType, &response.Body, &response.TxHash)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrIdempotencyKeyInFlight
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error querying idempotency key: %w", err)
+		}
+		switch {
+		case storedHash != requestHash:
+			return nil, ErrIdempotencyKeyReused
+		case statusCode != nil:
+			response.StatusCode = int(*statusCode)
+			return response, nil
+		case claimedAt.After(staleBefore):
+			return nil, ErrIdempotencyKeyInFlight
+		}
+		if _, err := tx.Exec(ctx, `UPDATE idempotency_keys SET claimed_at = NOW() WHERE tenant = $1 AND key = $2`, tenant, key); err != nil {
+			return nil, fmt.Errorf("error claiming idempotency key: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing idempotency key: %w", err)
+	}
+	return nil, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request holding a key
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, tenant, key string, response IdempotentResponse) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = NULLIF($4, ''), response_body = $5, tx_hash = $6, completed_at = NOW()
+		WHERE tenant = $1 AND key = $2
+	`
+	if _, err := db.Pool.Exec(ctx, query, tenant, key, int32(response.StatusCode), response.ContentType, response.Body, response.TxHash); err != nil {
+		return fmt.Errorf("error storing idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed before it
+// changed anything, so a retry is handled afresh
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, tenant, key string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := db.Pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE tenant = $1 AND key = $2 AND status_code IS NULL`, tenant, key); err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
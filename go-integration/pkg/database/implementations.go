@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordContractImplementation notes the implementation a proxy currently
+// delegates to and returns the implementation recorded before it, or nil if
+// this is the first record or the implementation has not changed
+func (db *DB) RecordContractImplementation(ctx context.Context, proxy, implementation string) (*string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previous string
+	err = tx.QueryRow(ctx, `
+		SELECT implementation_address FROM contract_implementations
+		WHERE proxy_address = $1
+		ORDER BY last_seen_at DESC, id DESC
+		LIMIT 1
+		FOR UPDATE
+	`, proxy).Scan(&previous)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("error getting contract implementation: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO contract_implementations (proxy_address, implementation_address)
+		VALUES ($1, $2)
+		ON CONFLICT (proxy_address, implementation_address) DO UPDATE SET last_seen_at = NOW()
+	`, proxy, implementation)
+	if err != nil {
+		return nil, fmt.Errorf("error recording contract implementation: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing contract implementation: %w", err)
+	}
+
+	if previous == "" || previous == implementation {
+		return nil, nil
+	}
+	return &previous, nil
+}
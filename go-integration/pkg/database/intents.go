@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Chain intent outcomes. An intent without one was written before its
+// submission and never heard back from, so the process may have crashed.
+const (
+	IntentSent      = "sent"      // broadcast, with the status change recording its hash
+	IntentFailed    = "failed"    // refused before anything was broadcast
+	IntentRecovered = "recovered" // found on-chain by recovery after a crash
+	IntentRequeued  = "requeued"  // not found on-chain, so handed to the deferred queue
+)
+
+// ErrIntentPending is returned when beginning an operation that already has
+// an intent without an outcome: it is in flight, or recovery must settle it
+var ErrIntentPending = errors.New("an earlier submission of this operation has no recorded outcome")
+
+// ChainIntent is the write-ahead record of a chain operation, written before
+// it is submitted
+type ChainIntent struct {
+	ID             int64
+	ApplicationID  int32
+	Operation      string
+	Params         OperationParams
+	IdempotencyKey string // one intent per key may be without an outcome
+	Outcome        *string
+	TxHash         *string
+	Error          *string
+	CreatedAt      time.Time
+	ResolvedAt     *time.Time
+}
+
+// IntentKey is the idempotency key of an operation on an escrow job
+func IntentKey(operation string, jobID uint64) string {
+	return fmt.Sprintf("%s:%d", operation, jobID)
+}
+
+// BeginChainIntent durably records that operation is about to be submitted.
+// The commit waits for the WAL flush even where synchronous_commit is off, so
+// the intent survives a crash of the database as well as of the gateway.
+func (db *DB) BeginChainIntent(ctx context.Context, applicationID int32, operation string, params OperationParams) (*ChainIntent, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding chain intent params: %w", err)
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SET LOCAL synchronous_commit = on`); err != nil {
+		return nil, fmt.Errorf("error setting synchronous commit: %w", err)
+	}
+
+	intent := &ChainIntent{
+		ApplicationID:  applicationID,
+		Operation:      operation,
+		Params:         params,
+		IdempotencyKey: IntentKey(operation, params.JobID),
+	}
+	query := `
+		INSERT INTO chain_intents (application_id, operation, params, idempotency_key)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (idempotency_key) WHERE outcome IS NULL DO NOTHING
+		RETURNING id, created_at
+	`
+	err = tx.QueryRow(ctx, query, applicationID, operation, paramsJSON, intent.IdempotencyKey).Scan(&intent.ID, &intent.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrIntentPending, intent.IdempotencyKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error recording chain intent: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing chain intent: %w", err)
+	}
+	return intent, nil
+}
+
+// ResolveChainIntent records an intent's outcome, with the transaction it
+// sent or the error that stopped it. An intent that already has an outcome
+// keeps it.
+func (db *DB) ResolveChainIntent(ctx context.Context, id int64, outcome string, txHash *string, errMsg string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := resolveChainIntent(ctx, tx, id, outcome, txHash, errMsg); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing chain intent outcome: %w", err)
+	}
+	return nil
+}
+
+func resolveChainIntent(ctx context.Context, e execer, id int64, outcome string, txHash *string, errMsg string) error {
+	if _, err := e.Exec(ctx, `SET LOCAL synchronous_commit = on`); err != nil {
+		return fmt.Errorf("error setting synchronous commit: %w", err)
+	}
+	query := `
+		UPDATE chain_intents
+		SET outcome = $1, tx_hash = $2, error = NULLIF($3, ''), resolved_at = NOW()
+		WHERE id = $4 AND outcome IS NULL
+	`
+	if _, err := e.Exec(ctx, query, outcome, txHash, errMsg, id); err != nil {
+		return fmt.Errorf("error resolving chain intent: %w", err)
+	}
+	return nil
+}
+
+// ListUnresolvedChainIntents returns the intents created before createdBefore
+// that have no outcome, oldest first
+func (db *DB) ListUnresolvedChainIntents(ctx context.Context, createdBefore time.Time) ([]*ChainIntent, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, application_id, operation, params, idempotency_key, created_at
+		FROM chain_intents
+		WHERE outcome IS NULL AND created_at < $1
+		ORDER BY id
+	`
+	rows, err := db.Pool.Query(ctx, query, createdBefore)
+	if err != nil {
+		return nil, fmt.Errorf("error querying chain intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []*ChainIntent
+	for rows.Next() {
+		var intent ChainIntent
+		var paramsJSON []byte
+		if err := rows.Scan(&intent.ID, &intent.ApplicationID, &intent.Operation, &paramsJSON, &intent.IdempotencyKey, &intent.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning chain intent: %w", err)
+		}
+		if err := json.Unmarshal(paramsJSON, &intent.Params); err != nil {
+			return nil, fmt.Errorf("error decoding chain intent params: %w", err)
+		}
+		intents = append(intents, &intent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying chain intents: %w", err)
+	}
+	return intents, nil
+}
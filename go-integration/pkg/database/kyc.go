@@ -0,0 +1,327 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// KYC hold states
+const (
+	KYCHoldOpen      = "open"
+	KYCHoldCleared   = "cleared"   // the freelancer passed KYC and the release was submitted
+	KYCHoldWithdrawn = "withdrawn" // an admin returned the job to deposited without releasing it
+)
+
+// ActorKYC records payment status changes made when a freelancer's KYC clears
+const ActorKYC = "kyc"
+
+// ErrKYCHoldResolved is returned by ResolveKYCHold for a hold that is no longer open
+var ErrKYCHoldResolved = errors.New("KYC hold is already resolved")
+
+// KYCHold is a release held until the freelancer passes KYC. The application
+// is kyc_pending while the hold is open and deposited again once it is
+// resolved.
+type KYCHold struct {
+	ID               int64           `json:"hold_id"`
+	ApplicationID    int32           `json:"application_id"`
+	FreelancerUserID int32           `json:"freelancer_user_id"`
+	USDAmount        int64           `json:"usd_amount"`
+	Operation        string          `json:"operation"`
+	Params           OperationParams `json:"params"`
+	KYCStatus        string          `json:"kyc_status"`           // as of CheckedAt
+	LastError        *string         `json:"last_error,omitempty"` // why the last check failed
+	Status           string          `json:"status"`
+	ResolvedBy       *string         `json:"resolved_by,omitempty"`
+	ResolutionReason *string         `json:"resolution_reason,omitempty"`
+	CheckedAt        time.Time       `json:"checked_at"`
+	ResolvedAt       *time.Time      `json:"resolved_at,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+const kycHoldColumns = `id, application_id, freelancer_user_id, usd_amount, operation, params, kyc_status, last_error,
+	status, resolved_by, resolution_reason, checked_at, resolved_at, created_at`
+
+// kycHoldAudit is the state of a KYC hold recorded in the audit log
+type kycHoldAudit struct {
+	HoldID        int64                `json:"hold_id"`
+	Status        string               `json:"status"`
+	KYCStatus     string               `json:"kyc_status"`
+	PaymentStatus paymentstatus.Status `json:"payment_status"`
+}
+
+// CreateKYCHold holds a deposited application's release, moving it to
+// kyc_pending in the same transaction, or returns ErrStatusConflict if it
+// is no longer deposited
+func (db *DB) CreateKYCHold(ctx context.Context, hold *KYCHold) (*KYCHold, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	params, err := json.Marshal(hold.Params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding KYC hold params: %w", err)
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setReviewPaymentStatus(ctx, tx, hold.ApplicationID, paymentstatus.Deposited, paymentstatus.KYCPending, ActorGateway); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO kyc_holds (application_id, freelancer_user_id, usd_amount, operation, params, kyc_status, last_error, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + kycHoldColumns
+	created, err := scanKYCHold(tx.QueryRow(ctx, query, hold.ApplicationID, hold.FreelancerUserID, hold.USDAmount, hold.Operation, params, hold.KYCStatus, hold.LastError, KYCHoldOpen))
+	if err != nil {
+		return nil, fmt.Errorf("error creating KYC hold: %w", err)
+	}
+
+	after, _ := json.Marshal(kycHoldAudit{HoldID: created.ID, Status: created.Status, KYCStatus: created.KYCStatus, PaymentStatus: paymentstatus.KYCPending})
+	entry := AuditEntry{
+		Action:        "kyc_hold.open",
+		ApplicationID: &created.ApplicationID,
+		Actor:         ActorGateway,
+		Reason:        fmt.Sprintf("$%d release to freelancer %d awaits KYC (%s)", created.USDAmount, created.FreelancerUserID, created.KYCStatus),
+		After:         after,
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing KYC hold: %w", err)
+	}
+	db.invalidateDetails(created.ApplicationID)
+
+	return created, nil
+}
+
+// GetKYCHold returns a KYC hold, or nil if it doesn't exist
+func (db *DB) GetKYCHold(ctx context.Context, id int64) (*KYCHold, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	hold, err := scanKYCHold(db.Pool.QueryRow(ctx, `SELECT `+kycHoldColumns+` FROM kyc_holds WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying KYC hold: %w", err)
+	}
+	return hold, nil
+}
+
+// ListKYCHolds returns the KYC holds in a status, oldest first
+func (db *DB) ListKYCHolds(ctx context.Context, status string) ([]*KYCHold, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.Pool.Query(ctx, `SELECT `+kycHoldColumns+` FROM kyc_holds WHERE status = $1 ORDER BY id`, status)
+	if err != nil {
+		return nil, fmt.Errorf("error listing KYC holds: %w", err)
+	}
+	defer rows.Close()
+
+	var holds []*KYCHold
+	for rows.Next() {
+		hold, err := scanKYCHold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning KYC hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing KYC holds: %w", err)
+	}
+	return holds, nil
+}
+
+// KYCHoldListSpec is what GET /admin/kyc-holds can sort and filter on
+var KYCHoldListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"id":                 {Column: "id", Kind: listquery.Int, Sortable: true, Ops: []listquery.Op{listquery.Gt, listquery.Lt}},
+		"application_id":     {Column: "application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"freelancer_user_id": {Column: "freelancer_user_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"usd_amount":         {Column: "usd_amount", Kind: listquery.Int, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"kyc_status":         {Column: "kyc_status", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"status":             {Column: "status", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"created_at":         {Column: "created_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+		"checked_at":         {Column: "checked_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+	},
+	ID:           "id",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// FindKYCHolds returns a page of KYC holds matching params and the cursor of
+// the next page, or "" if it is the last
+func (db *DB) FindKYCHolds(ctx context.Context, params listquery.Params) ([]*KYCHold, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var args []interface{}
+	rows, err := db.Pool.Query(ctx, `SELECT `+kycHoldColumns+` FROM kyc_holds WHERE TRUE`+listClause(params, &args), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error finding KYC holds: %w", err)
+	}
+	defer rows.Close()
+
+	var holds []*KYCHold
+	for rows.Next() {
+		hold, err := scanKYCHold(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("error scanning KYC hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error finding KYC holds: %w", err)
+	}
+
+	holds, next := listquery.Page(holds, params, kycHoldListValue)
+	return holds, next, nil
+}
+
+func kycHoldListValue(hold *KYCHold, field string) any {
+	switch field {
+	case "usd_amount":
+		return hold.USDAmount
+	case "created_at":
+		return hold.CreatedAt
+	case "checked_at":
+		return hold.CheckedAt
+	default:
+		return hold.ID
+	}
+}
+
+// RecordKYCCheck stores the outcome of checking an open hold's freelancer
+// again: their status, or why it couldn't be looked up
+func (db *DB) RecordKYCCheck(ctx context.Context, id int64, kycStatus string, lastError *string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE kyc_holds
+		SET kyc_status = $2, last_error = $3, checked_at = NOW()
+		WHERE id = $1 AND status = $4
+	`
+	if _, err := db.Pool.Exec(ctx, query, id, kycStatus, lastError, KYCHoldOpen); err != nil {
+		return fmt.Errorf("error recording KYC check: %w", err)
+	}
+	return nil
+}
+
+// ResolveKYCHold clears or withdraws an open hold, returning the application
+// to deposited, and records it in the audit log. It returns
+// ErrKYCHoldResolved if the hold isn't open.
+func (db *DB) ResolveKYCHold(ctx context.Context, id int64, status, kycStatus, actor, reason string) (*KYCHold, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE kyc_holds
+		SET status = $1, kyc_status = $2, last_error = NULL, resolved_by = $3, resolution_reason = $4,
+			checked_at = NOW(), resolved_at = NOW()
+		WHERE id = $5 AND status = $6
+		RETURNING ` + kycHoldColumns
+	hold, err := scanKYCHold(tx.QueryRow(ctx, query, status, kycStatus, actor, reason, id, KYCHoldOpen))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrKYCHoldResolved
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error resolving KYC hold: %w", err)
+	}
+
+	statusActor := actor
+	if status == KYCHoldCleared {
+		statusActor = ActorKYC
+	}
+	if err := setReviewPaymentStatus(ctx, tx, hold.ApplicationID, paymentstatus.KYCPending, paymentstatus.Deposited, statusActor); err != nil {
+		return nil, err
+	}
+
+	before, _ := json.Marshal(kycHoldAudit{HoldID: hold.ID, Status: KYCHoldOpen, PaymentStatus: paymentstatus.KYCPending})
+	after, _ := json.Marshal(kycHoldAudit{HoldID: hold.ID, Status: hold.Status, KYCStatus: hold.KYCStatus, PaymentStatus: paymentstatus.Deposited})
+	entry := AuditEntry{
+		Action:        "kyc_hold." + map[string]string{KYCHoldCleared: "clear", KYCHoldWithdrawn: "withdraw"}[status],
+		ApplicationID: &hold.ApplicationID,
+		Actor:         actor,
+		Reason:        reason,
+		Before:        before,
+		After:         after,
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing KYC hold: %w", err)
+	}
+	db.invalidateDetails(hold.ApplicationID)
+
+	return hold, nil
+}
+
+// GetUserKYCStatus reads a platform user's KYC status from column of the
+// users table, or "" when it is NULL
+func (db *DB) GetUserKYCStatus(ctx context.Context, userID int32, column string) (string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var status *string
+	query := `SELECT ` + pgx.Identifier{column}.Sanitize() + `::text FROM users WHERE id = $1`
+	if err := db.Pool.QueryRow(ctx, query, userID).Scan(&status); err != nil {
+		return "", fmt.Errorf("error querying KYC status of user %d: %w", userID, err)
+	}
+	if status == nil {
+		return "", nil
+	}
+	return *status, nil
+}
+
+func scanKYCHold(row pgx.Row) (*KYCHold, error) {
+	hold := &KYCHold{}
+	var params []byte
+	err := row.Scan(
+		&hold.ID,
+		&hold.ApplicationID,
+		&hold.FreelancerUserID,
+		&hold.USDAmount,
+		&hold.Operation,
+		&params,
+		&hold.KYCStatus,
+		&hold.LastError,
+		&hold.Status,
+		&hold.ResolvedBy,
+		&hold.ResolutionReason,
+		&hold.CheckedAt,
+		&hold.ResolvedAt,
+		&hold.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &hold.Params); err != nil {
+		return nil, fmt.Errorf("error decoding KYC hold params: %w", err)
+	}
+	return hold, nil
+}
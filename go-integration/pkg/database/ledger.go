@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+)
+
+// ErrInsufficientReserve is returned when a payout exceeds the reserve fund balance
+var ErrInsufficientReserve = errors.New("reserve fund balance is too low for this payout")
+
+// LedgerTransaction is a posted ledger transaction. Entry amounts are decimal
+// wei strings, positive for debits and negative for credits.
+type LedgerTransaction struct {
+	ID            int64
+	Kind          string
+	Reference     string
+	ApplicationID *int32
+	Counterparty  *string
+	Memo          string
+	Actor         string
+	CreatedAt     time.Time
+	Entries       []LedgerEntry
+}
+
+// LedgerEntry is one side of a posted ledger transaction
+type LedgerEntry struct {
+	Account   string
+	AmountWei string
+}
+
+// PostLedgerTransaction validates and stores a transaction with its entries.
+// It returns false if a transaction of the same kind and reference was already posted.
+func (db *DB) PostLedgerTransaction(ctx context.Context, t *ledger.Transaction) (bool, error) {
+	return db.postLedgerTransaction(ctx, t, nil)
+}
+
+// PostReservePayout posts a payout only if the reserve fund covers it, checked
+// under a lock so concurrent payouts cannot overdraw the reserve
+func (db *DB) PostReservePayout(ctx context.Context, t *ledger.Transaction, amount *big.Int) (bool, error) {
+	return db.postLedgerTransaction(ctx, t, func(tx pgx.Tx) error {
+		var balance string
+		query := `SELECT (-COALESCE(SUM(amount_wei), 0))::text FROM ledger_entries WHERE account = $1`
+		if err := tx.QueryRow(ctx, query, ledger.AccountReserveFund).Scan(&balance); err != nil {
+			return fmt.Errorf("error querying reserve balance: %w", err)
+		}
+		available, _ := new(big.Int).SetString(balance, 10)
+		if available == nil || available.Cmp(amount) < 0 {
+			return ErrInsufficientReserve
+		}
+		return nil
+	})
+}
+
+func (db *DB) postLedgerTransaction(ctx context.Context, t *ledger.Transaction, check func(pgx.Tx) error) (bool, error) {
+	if err := t.Validate(); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialise postings so a balance check sees every committed entry
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('ledger'))`); err != nil {
+		return false, fmt.Errorf("error locking ledger: %w", err)
+	}
+	if check != nil {
+		if err := check(tx); err != nil {
+			return false, err
+		}
+	}
+
+	var counterparty *string
+	if t.Counterparty != "" {
+		counterparty = &t.Counterparty
+	}
+
+	var id int64
+	query := `
+		INSERT INTO ledger_transactions (kind, reference, application_id, counterparty, memo, actor)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (kind, reference) DO NOTHING
+		RETURNING id
+	`
+	err = tx.QueryRow(ctx, query, t.Kind, t.Reference, t.ApplicationID, counterparty, t.Memo, t.Actor).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error posting ledger transaction: %w", err)
+	}
+
+	for _, entry := range t.Entries {
+		entryQuery := `INSERT INTO ledger_entries (transaction_id, account, amount_wei) VALUES ($1, $2, $3::numeric)`
+		if _, err := tx.Exec(ctx, entryQuery, id, entry.Account, entry.Amount.String()); err != nil {
+			return false, fmt.Errorf("error posting ledger entry: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("error committing ledger transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetLedgerBalances returns the net balance of every account as a decimal wei
+// string, positive when debits exceed credits
+func (db *DB) GetLedgerBalances(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.Pool.Query(ctx, `SELECT account, SUM(amount_wei)::text FROM ledger_entries GROUP BY account`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying ledger balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := make(map[string]string)
+	for rows.Next() {
+		var account, balance string
+		if err := rows.Scan(&account, &balance); err != nil {
+			return nil, fmt.Errorf("error scanning ledger balance: %w", err)
+		}
+		balances[account] = balance
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying ledger balances: %w", err)
+	}
+
+	return balances, nil
+}
+
+// ListUnbalancedLedgerTransactions returns the IDs of transactions whose entries
+// do not sum to zero, which should never happen
+func (db *DB) ListUnbalancedLedgerTransactions(ctx context.Context) ([]int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT t.id
+		FROM ledger_transactions t
+		LEFT JOIN ledger_entries e ON e.transaction_id = t.id
+		GROUP BY t.id
+		HAVING COALESCE(SUM(e.amount_wei), 0) <> 0 OR COUNT(e.id) < 2
+		ORDER BY t.id
+	`
+
+	rows, err := db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error checking ledger balance: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning ledger transaction: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error checking ledger balance: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ListLedgerTransactions returns the most recent transactions of a kind with their entries
+func (db *DB) ListLedgerTransactions(ctx context.Context, kind string, limit int) ([]*LedgerTransaction, error) {
+	return db.FindLedgerTransactions(ctx, LedgerFilter{Kind: kind, Limit: limit})
+}
+
+// LedgerFilter narrows FindLedgerTransactions; zero fields match everything
+type LedgerFilter struct {
+	Kind          string
+	ApplicationID int32
+	Account       string // transactions with an entry on this account
+	Since         time.Time
+	Limit         int
+}
+
+// FindLedgerTransactions returns up to filter.Limit transactions matching
+// filter with all their entries, newest first
+func (db *DB) FindLedgerTransactions(ctx context.Context, filter LedgerFilter) ([]*LedgerTransaction, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{filter.Since}
+	query := `
+		SELECT id, kind, reference, application_id, counterparty, memo, actor, created_at
+		FROM ledger_transactions t
+		WHERE created_at >= $1
+	`
+	if filter.Kind != "" {
+		args = append(args, filter.Kind)
+		query += fmt.Sprintf(" AND kind = $%d", len(args))
+	}
+	if filter.ApplicationID != 0 {
+		args = append(args, filter.ApplicationID)
+		query += fmt.Sprintf(" AND application_id = $%d", len(args))
+	}
+	if filter.Account != "" {
+		args = append(args, filter.Account)
+		query += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM ledger_entries e WHERE e.transaction_id = t.id AND e.account = $%d)", len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing ledger transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []*LedgerTransaction
+	byID := make(map[int64]*LedgerTransaction)
+	var ids []int64
+	for rows.Next() {
+		t := &LedgerTransaction{}
+		if err := rows.Scan(&t.ID, &t.Kind, &t.Reference, &t.ApplicationID, &t.Counterparty, &t.Memo, &t.Actor, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning ledger transaction: %w", err)
+		}
+		transactions = append(transactions, t)
+		byID[t.ID] = t
+		ids = append(ids, t.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing ledger transactions: %w", err)
+	}
+	if len(ids) == 0 {
+		return transactions, nil
+	}
+
+	entryRows, err := db.Pool.Query(ctx, `SELECT transaction_id, account, amount_wei::text FROM ledger_entries WHERE transaction_id = ANY($1) ORDER BY id`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error listing ledger entries: %w", err)
+	}
+	defer entryRows.Close()
+
+	for entryRows.Next() {
+		var transactionID int64
+		var entry LedgerEntry
+		if err := entryRows.Scan(&transactionID, &entry.Account, &entry.AmountWei); err != nil {
+			return nil, fmt.Errorf("error scanning ledger entry: %w", err)
+		}
+		byID[transactionID].Entries = append(byID[transactionID].Entries, entry)
+	}
+	if err := entryRows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing ledger entries: %w", err)
+	}
+
+	return transactions, nil
+}
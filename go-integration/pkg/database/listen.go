@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Listener receives NOTIFYs sent on one channel. It holds a pool connection
+// for as long as it is open, so it must be closed.
+type Listener struct {
+	conn *pgxpool.Conn
+	open *atomic.Int64
+}
+
+// Listen starts listening on channel over a dedicated connection. The
+// connection is long-lived, so QueryTimeout does not apply.
+func (db *DB) Listen(ctx context.Context, channel string) (*Listener, error) {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring listen connection: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("error listening on %s: %w", channel, err)
+	}
+
+	db.listeners.Add(1)
+	return &Listener{conn: conn, open: &db.listeners}, nil
+}
+
+// Wait blocks until a notification arrives and returns its payload. An error
+// other than ctx ending means the connection was lost and the listener must
+// be closed and reopened; notifications sent meanwhile are not delivered.
+func (l *Listener) Wait(ctx context.Context) (string, error) {
+	notification, err := l.conn.Conn().WaitForNotification(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error waiting for notification: %w", err)
+	}
+	return notification.Payload, nil
+}
+
+// Close stops listening and returns the connection to the pool
+func (l *Listener) Close() {
+	// A connection still subscribed would deliver notifications to whichever query borrows it next
+	if _, err := l.conn.Exec(context.Background(), "UNLISTEN *"); err != nil {
+		l.conn.Conn().Close(context.Background())
+	}
+	l.conn.Release()
+	l.open.Add(-1)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// Page sizes of list endpoints
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// sqlOps are the SQL comparisons of listquery's filter operators
+var sqlOps = map[listquery.Op]string{
+	listquery.Eq:  "=",
+	listquery.Ne:  "<>",
+	listquery.Lt:  "<",
+	listquery.Lte: "<=",
+	listquery.Gt:  ">",
+	listquery.Gte: ">=",
+}
+
+// listClause renders params after a query's WHERE: its filters, the keyset
+// of the cursor, then ORDER BY and a LIMIT one past the page, so
+// listquery.Page can tell whether another follows
+func listClause(params listquery.Params, args *[]interface{}) string {
+	var clause strings.Builder
+	clause.WriteString(filterClause(params.Conditions, args))
+
+	direction, after := "ASC", ">"
+	if params.Sort.Desc {
+		direction, after = "DESC", "<"
+	}
+	if params.After != nil {
+		*args = append(*args, params.After[0], params.After[1])
+		fmt.Fprintf(&clause, " AND (%s, %s) %s ($%d, $%d)", params.Sort.Column, params.ID.Column, after, len(*args)-1, len(*args))
+	}
+
+	*args = append(*args, params.Limit+1)
+	if params.Sort.Column == params.ID.Column {
+		fmt.Fprintf(&clause, " ORDER BY %s %s LIMIT $%d", params.ID.Column, direction, len(*args))
+	} else {
+		fmt.Fprintf(&clause, " ORDER BY %s %s, %s %s LIMIT $%d", params.Sort.Column, direction, params.ID.Column, direction, len(*args))
+	}
+	return clause.String()
+}
+
+// filterClause renders conditions after a query's WHERE, each ANDed on
+func filterClause(conditions []listquery.Condition, args *[]interface{}) string {
+	var clause strings.Builder
+	for _, condition := range conditions {
+		*args = append(*args, condition.Arg())
+		if condition.Op == listquery.In {
+			fmt.Fprintf(&clause, " AND %s = ANY($%d)", condition.Column, len(*args))
+			continue
+		}
+		fmt.Fprintf(&clause, " AND %s %s $%d", condition.Column, sqlOps[condition.Op], len(*args))
+	}
+	return clause.String()
+}
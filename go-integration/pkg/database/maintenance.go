@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MaintenanceWindow is a period in which the gateway is read-only
+type MaintenanceWindow struct {
+	ID          int64      `json:"id"`
+	Reason      string     `json:"reason"`
+	StartedBy   string     `json:"started_by"`
+	StartedAt   time.Time  `json:"started_at"`
+	ExpectedEnd *time.Time `json:"expected_end,omitempty"` // the operator's estimate, for Retry-After
+	EndedBy     *string    `json:"ended_by,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+}
+
+const maintenanceWindowColumns = `id, reason, started_by, started_at, expected_end, ended_by, ended_at`
+
+func scanMaintenanceWindow(row pgx.Row) (*MaintenanceWindow, error) {
+	var m MaintenanceWindow
+	err := row.Scan(&m.ID, &m.Reason, &m.StartedBy, &m.StartedAt, &m.ExpectedEnd, &m.EndedBy, &m.EndedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// StartMaintenance opens a maintenance window. It returns the window already
+// open and false if there is one, which is left unchanged.
+func (db *DB) StartMaintenance(ctx context.Context, reason, actor string, expectedEnd *time.Time) (*MaintenanceWindow, bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialise toggles so two operators cannot open a window each
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('maintenance'))`); err != nil {
+		return nil, false, fmt.Errorf("error locking maintenance windows: %w", err)
+	}
+
+	active, err := scanMaintenanceWindow(tx.QueryRow(ctx, `SELECT `+maintenanceWindowColumns+` FROM maintenance_windows WHERE ended_at IS NULL`))
+	if err == nil {
+		return active, false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("error querying maintenance window: %w", err)
+	}
+
+	query := `
+		INSERT INTO maintenance_windows (reason, started_by, expected_end)
+		VALUES ($1, $2, $3)
+		RETURNING ` + maintenanceWindowColumns
+	window, err := scanMaintenanceWindow(tx.QueryRow(ctx, query, reason, actor, expectedEnd))
+	if err != nil {
+		return nil, false, fmt.Errorf("error starting maintenance: %w", err)
+	}
+
+	after, _ := json.Marshal(window)
+	if err := insertAudit(ctx, tx, AuditEntry{Action: "maintenance.start", Actor: actor, Reason: reason, After: after}); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("error committing maintenance window: %w", err)
+	}
+	return window, true, nil
+}
+
+// EndMaintenance closes the open maintenance window and returns it, or nil if
+// the gateway was not in maintenance
+func (db *DB) EndMaintenance(ctx context.Context, actor, reason string) (*MaintenanceWindow, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE maintenance_windows
+		SET ended_by = $1, ended_at = NOW()
+		WHERE ended_at IS NULL
+		RETURNING ` + maintenanceWindowColumns
+	window, err := scanMaintenanceWindow(tx.QueryRow(ctx, query, actor))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error ending maintenance: %w", err)
+	}
+
+	after, _ := json.Marshal(window)
+	if err := insertAudit(ctx, tx, AuditEntry{Action: "maintenance.end", Actor: actor, Reason: reason, After: after}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing maintenance window: %w", err)
+	}
+	return window, nil
+}
+
+// GetActiveMaintenance returns the open maintenance window, or nil if the
+// gateway is not in maintenance
+func (db *DB) GetActiveMaintenance(ctx context.Context) (*MaintenanceWindow, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	window, err := scanMaintenanceWindow(db.Pool.QueryRow(ctx, `SELECT `+maintenanceWindowColumns+` FROM maintenance_windows WHERE ended_at IS NULL`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying maintenance window: %w", err)
+	}
+	return window, nil
+}
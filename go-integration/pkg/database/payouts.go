@@ -0,0 +1,265 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PayoutPreference is a freelancer's opt-in to batched payouts: releases are
+// held until their total reaches ThresholdUSD or the weekly payout hour
+// comes, whichever is first
+type PayoutPreference struct {
+	FreelancerUserID int32        `json:"freelancer_user_id"`
+	ThresholdUSD     int64        `json:"threshold_usd"`
+	Weekday          time.Weekday `json:"weekday"`  // of the weekly payout, in UTC
+	Hour             int          `json:"hour_utc"` // 0-23
+	UpdatedBy        string       `json:"updated_by"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+// NextPayout returns the first weekly payout hour after now
+func (p *PayoutPreference) NextPayout(now time.Time) time.Time {
+	now = now.UTC()
+	payout := time.Date(now.Year(), now.Month(), now.Day(), p.Hour, 0, 0, 0, time.UTC)
+	payout = payout.AddDate(0, 0, (int(p.Weekday)-int(now.Weekday())+7)%7)
+	if !payout.After(now) {
+		payout = payout.AddDate(0, 0, 7)
+	}
+	return payout
+}
+
+// HeldPayout is a release held for a batched payout, queued as a deferred
+// operation due at the payout hour
+type HeldPayout struct {
+	ApplicationID int32     `json:"application_id"`
+	USDAmount     int64     `json:"usd_amount"`
+	OperationID   int64     `json:"operation_id"`
+	PayoutAt      time.Time `json:"payout_at"`
+	HeldAt        time.Time `json:"held_at"`
+}
+
+// GetPayoutPreference returns a freelancer's payout preference, or nil if
+// they are paid out job by job
+func (db *DB) GetPayoutPreference(ctx context.Context, freelancerUserID int32) (*PayoutPreference, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return queryPayoutPreference(ctx, db.Pool, freelancerUserID)
+}
+
+// SetPayoutPreference opts a freelancer into batched payouts, or changes
+// their threshold and schedule, and records it in the audit log. Releases
+// already held keep the payout hour they were held for.
+func (db *DB) SetPayoutPreference(ctx context.Context, pref PayoutPreference) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := queryPayoutPreference(ctx, tx, pref.FreelancerUserID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO payout_preferences (freelancer_user_id, threshold_usd, weekday, hour, updated_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (freelancer_user_id) DO UPDATE
+		SET threshold_usd = EXCLUDED.threshold_usd,
+			weekday = EXCLUDED.weekday,
+			hour = EXCLUDED.hour,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+	`
+	if _, err := tx.Exec(ctx, query, pref.FreelancerUserID, pref.ThresholdUSD, int(pref.Weekday), pref.Hour, pref.UpdatedBy); err != nil {
+		return fmt.Errorf("error setting payout preference: %w", err)
+	}
+
+	if err := auditPayoutPreference(ctx, tx, "payout_preference.set", pref.UpdatedBy, before, &pref); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing payout preference: %w", err)
+	}
+	return nil
+}
+
+// DeletePayoutPreference opts a freelancer out of batched payouts. It
+// reports whether they had opted in.
+func (db *DB) DeletePayoutPreference(ctx context.Context, freelancerUserID int32, actor string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := queryPayoutPreference(ctx, tx, freelancerUserID)
+	if err != nil {
+		return false, err
+	}
+	if before == nil {
+		return false, nil
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM payout_preferences WHERE freelancer_user_id = $1`, freelancerUserID); err != nil {
+		return false, fmt.Errorf("error deleting payout preference: %w", err)
+	}
+
+	if err := auditPayoutPreference(ctx, tx, "payout_preference.delete", actor, before, nil); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("error committing payout preference: %w", err)
+	}
+	return true, nil
+}
+
+func queryPayoutPreference(ctx context.Context, q querier, freelancerUserID int32) (*PayoutPreference, error) {
+	query := `
+		SELECT freelancer_user_id, threshold_usd, weekday, hour, updated_by, updated_at
+		FROM payout_preferences
+		WHERE freelancer_user_id = $1
+	`
+	var pref PayoutPreference
+	var weekday, hour int16
+	err := q.QueryRow(ctx, query, freelancerUserID).Scan(&pref.FreelancerUserID, &pref.ThresholdUSD, &weekday, &hour, &pref.UpdatedBy, &pref.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying payout preference: %w", err)
+	}
+	pref.Weekday, pref.Hour = time.Weekday(weekday), int(hour)
+	return &pref, nil
+}
+
+func auditPayoutPreference(ctx context.Context, e execer, action, actor string, before, after *PayoutPreference) error {
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(after)
+	return insertAudit(ctx, e, AuditEntry{
+		Action: action,
+		Actor:  actor,
+		Before: beforeJSON,
+		After:  afterJSON,
+	})
+}
+
+// HoldPayout queues an application's release for a batched payout at
+// payoutAt, as a deferred operation, and records the hold against the
+// freelancer's claimable balance
+func (db *DB) HoldPayout(ctx context.Context, applicationID, freelancerUserID int32, usdAmount int64, operation string, params OperationParams, payoutAt, deadline time.Time, reason string) (*DeferredOperation, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding deferred operation params: %w", err)
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	op := &DeferredOperation{
+		ApplicationID: applicationID,
+		Operation:     operation,
+		Params:        params,
+		Status:        DeferredStatusDeferred,
+		Deadline:      deadline,
+		LastError:     &reason,
+		NextAttemptAt: &payoutAt,
+	}
+	query := `
+		INSERT INTO deferred_operations (application_id, operation, params, status, deadline, last_error, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(ctx, query, applicationID, op.Operation, paramsJSON, DeferredStatusDeferred, deadline, reason, payoutAt).Scan(&op.ID, &op.CreatedAt, &op.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error scheduling held payout: %w", err)
+	}
+
+	holdQuery := `
+		INSERT INTO payout_holds (application_id, freelancer_user_id, usd_amount, deferred_operation_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (application_id) DO UPDATE
+		SET freelancer_user_id = EXCLUDED.freelancer_user_id,
+			usd_amount = EXCLUDED.usd_amount,
+			deferred_operation_id = EXCLUDED.deferred_operation_id,
+			created_at = NOW()
+	`
+	if _, err := tx.Exec(ctx, holdQuery, applicationID, freelancerUserID, usdAmount, op.ID); err != nil {
+		return nil, fmt.Errorf("error recording held payout: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing held payout: %w", err)
+	}
+	return op, nil
+}
+
+// ListHeldPayouts returns a freelancer's releases still held for a batched
+// payout, oldest first
+func (db *DB) ListHeldPayouts(ctx context.Context, freelancerUserID int32) ([]HeldPayout, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT h.application_id, h.usd_amount, h.deferred_operation_id, COALESCE(d.next_attempt_at, d.created_at), h.created_at
+		FROM payout_holds h
+		JOIN deferred_operations d ON d.id = h.deferred_operation_id
+		WHERE h.freelancer_user_id = $1 AND d.status = $2
+		ORDER BY h.created_at, h.application_id
+	`
+	rows, err := db.Pool.Query(ctx, query, freelancerUserID, DeferredStatusDeferred)
+	if err != nil {
+		return nil, fmt.Errorf("error querying held payouts: %w", err)
+	}
+	defer rows.Close()
+
+	var held []HeldPayout
+	for rows.Next() {
+		var h HeldPayout
+		if err := rows.Scan(&h.ApplicationID, &h.USDAmount, &h.OperationID, &h.PayoutAt, &h.HeldAt); err != nil {
+			return nil, fmt.Errorf("error scanning held payout: %w", err)
+		}
+		held = append(held, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading held payouts: %w", err)
+	}
+	return held, nil
+}
+
+// ReleaseHeldPayouts makes a freelancer's held releases due now, for the
+// deferred operation worker to submit. It returns how many were brought
+// forward.
+func (db *DB) ReleaseHeldPayouts(ctx context.Context, freelancerUserID int32, reason string) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE deferred_operations
+		SET next_attempt_at = NOW(), last_error = $3, updated_at = NOW()
+		WHERE status = $2 AND next_attempt_at > NOW()
+			AND id IN (SELECT deferred_operation_id FROM payout_holds WHERE freelancer_user_id = $1)
+	`
+	tag, err := db.Pool.Exec(ctx, query, freelancerUserID, DeferredStatusDeferred, reason)
+	if err != nil {
+		return 0, fmt.Errorf("error releasing held payouts: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
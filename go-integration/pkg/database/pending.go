@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// InitiatedTransaction is an application whose escrow transaction has been
+// submitted but not yet confirmed
+type InitiatedTransaction struct {
+	ApplicationID int32
+	PaymentStatus paymentstatus.Status
+	TxType        string // "deposit", "release" or "refund"
+	TxHash        string
+	TraceID       string // of the request that submitted the transaction, if known
+}
+
+// ListInitiatedTransactions returns every application in a *_initiated status
+// along with the hash of the transaction it is waiting on and the trace of
+// the request that submitted it
+func (db *DB) ListInitiatedTransactions(ctx context.Context) ([]InitiatedTransaction, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, payment_status, tx_type, tx_hash,
+			(SELECT e.trace_id FROM payment_events e
+			WHERE e.application_id = initiated.id AND e.tx_hash = initiated.tx_hash AND e.trace_id IS NOT NULL
+			ORDER BY e.id DESC LIMIT 1)
+		FROM (
+			SELECT id, payment_status,
+				CASE payment_status
+					WHEN 'deposit_initiated' THEN 'deposit'
+					WHEN 'release_initiated' THEN 'release'
+					ELSE 'refund'
+				END AS tx_type,
+				CASE payment_status
+					WHEN 'deposit_initiated' THEN escrow_tx_hash_deposit
+					WHEN 'release_initiated' THEN escrow_tx_hash_release
+					ELSE escrow_tx_hash_refund
+				END AS tx_hash
+			FROM applications
+			WHERE payment_status IN ('deposit_initiated', 'release_initiated', 'refund_initiated')
+		) initiated
+		ORDER BY id
+	`
+
+	rows, err := db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing initiated transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []InitiatedTransaction
+	for rows.Next() {
+		var tx InitiatedTransaction
+		var txHash, traceID *string
+		if err := rows.Scan(&tx.ApplicationID, &tx.PaymentStatus, &tx.TxType, &txHash, &traceID); err != nil {
+			return nil, fmt.Errorf("error scanning initiated transaction: %w", err)
+		}
+		if txHash == nil || *txHash == "" {
+			continue
+		}
+		tx.TxHash = *txHash
+		if traceID != nil {
+			tx.TraceID = *traceID
+		}
+		pending = append(pending, tx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing initiated transactions: %w", err)
+	}
+
+	return pending, nil
+}
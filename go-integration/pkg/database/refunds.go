@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// RefundReportRow is the refund volume for one reason within one period
+type RefundReportRow struct {
+	Period   time.Time
+	Reason   string
+	Count    int64
+	TotalUSD int64
+}
+
+// RecordRefund stores the reason and amount of a refund submitted on-chain
+func (db *DB) RecordRefund(ctx context.Context, applicationID int32, reason string, usdAmount int32, txHash string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO payment_refunds (application_id, reason, usd_amount, tx_hash)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := db.Pool.Exec(ctx, query, applicationID, reason, usdAmount, txHash)
+	if err != nil {
+		return fmt.Errorf("error recording refund: %w", err)
+	}
+
+	return nil
+}
+
+// RefundReportSpec is what GET /reports/refunds can filter on
+var RefundReportSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"application_id": {Column: "application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"reason":         {Column: "reason", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+		"usd_amount":     {Column: "usd_amount", Kind: listquery.Int, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+	},
+}
+
+// GetRefundReport aggregates the refunds matching filters by reason for each
+// period of r
+func (db *DB) GetRefundReport(ctx context.Context, r listquery.Range, filters []listquery.Condition, tags []string) ([]RefundReportRow, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{r.Interval, r.From, r.To}
+	query := `
+		SELECT
+			date_trunc($1, created_at) as period,
+			reason,
+			COUNT(*) as refund_count,
+			COALESCE(SUM(usd_amount), 0) as total_usd
+		FROM payment_refunds
+		WHERE created_at >= $2 AND created_at < $3` + filterClause(filters, &args) + tagFilter("payment_refunds.application_id", tags, &args) + `
+		GROUP BY period, reason
+		ORDER BY period, reason
+	`
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying refund report: %w", err)
+	}
+	defer rows.Close()
+
+	var report []RefundReportRow
+	for rows.Next() {
+		var row RefundReportRow
+		if err := rows.Scan(&row.Period, &row.Reason, &row.Count, &row.TotalUSD); err != nil {
+			return nil, fmt.Errorf("error scanning refund report: %w", err)
+		}
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading refund report: %w", err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Release authorization states
+const (
+	ReleaseAuthorizationActive     = "active"     // the release may be submitted until it expires
+	ReleaseAuthorizationExecuted   = "executed"   // the release was confirmed on-chain
+	ReleaseAuthorizationLapsed     = "lapsed"     // expired before the release was confirmed
+	ReleaseAuthorizationSuperseded = "superseded" // replaced by a later approval
+)
+
+// ReleaseAuthorization is a client's approval to release a job's escrow,
+// valid until it expires
+type ReleaseAuthorization struct {
+	ID            int64
+	ApplicationID int32
+	Actor         string
+	Status        string
+	ExpiresAt     time.Time
+	TxHash        *string // the last release submitted under it
+	CreatedAt     time.Time
+	ResolvedAt    *time.Time
+}
+
+// Expired reports whether an active authorization is past its expiry
+func (a *ReleaseAuthorization) Expired(now time.Time) bool {
+	return a.Status == ReleaseAuthorizationActive && !now.Before(a.ExpiresAt)
+}
+
+const releaseAuthorizationColumns = `id, application_id, actor, status, expires_at, tx_hash, created_at, resolved_at`
+
+func scanReleaseAuthorization(row pgx.Row) (*ReleaseAuthorization, error) {
+	var a ReleaseAuthorization
+	err := row.Scan(&a.ID, &a.ApplicationID, &a.Actor, &a.Status, &a.ExpiresAt, &a.TxHash, &a.CreatedAt, &a.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateReleaseAuthorization records an approval to release an application's
+// escrow until expiresAt, superseding any earlier active one
+func (db *DB) CreateReleaseAuthorization(ctx context.Context, applicationID int32, actor string, expiresAt time.Time) (*ReleaseAuthorization, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	supersedeQuery := `
+		UPDATE release_authorizations
+		SET status = $1, resolved_at = NOW()
+		WHERE application_id = $2 AND status = $3
+	`
+	if _, err := tx.Exec(ctx, supersedeQuery, ReleaseAuthorizationSuperseded, applicationID, ReleaseAuthorizationActive); err != nil {
+		return nil, fmt.Errorf("error superseding release authorization: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO release_authorizations (application_id, actor, status, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + releaseAuthorizationColumns
+	authorization, err := scanReleaseAuthorization(tx.QueryRow(ctx, insertQuery, applicationID, actor, ReleaseAuthorizationActive, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error creating release authorization: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing release authorization: %w", err)
+	}
+	return authorization, nil
+}
+
+// GetReleaseAuthorization returns an application's latest release
+// authorization, or nil if the release was never approved
+func (db *DB) GetReleaseAuthorization(ctx context.Context, applicationID int32) (*ReleaseAuthorization, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + releaseAuthorizationColumns + ` FROM release_authorizations WHERE application_id = $1 ORDER BY id DESC LIMIT 1`
+	authorization, err := scanReleaseAuthorization(db.Pool.QueryRow(ctx, query, applicationID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying release authorization: %w", err)
+	}
+	return authorization, nil
+}
+
+// RecordReleaseAuthorizationTx records the release transaction submitted
+// under an active authorization
+func (db *DB) RecordReleaseAuthorizationTx(ctx context.Context, id int64, txHash string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE release_authorizations SET tx_hash = $1 WHERE id = $2 AND status = $3`
+	if _, err := db.Pool.Exec(ctx, query, txHash, id, ReleaseAuthorizationActive); err != nil {
+		return fmt.Errorf("error recording release authorization transaction: %w", err)
+	}
+	return nil
+}
+
+// LapseReleaseAuthorizations marks active authorizations past their expiry as
+// lapsed and returns them. One whose release is still in flight is left
+// active until its receipt is found, since the transaction can no longer be
+// recalled.
+func (db *DB) LapseReleaseAuthorizations(ctx context.Context, now time.Time) ([]*ReleaseAuthorization, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE release_authorizations r
+		SET status = $1, resolved_at = NOW()
+		FROM applications a
+		WHERE a.id = r.application_id AND r.status = $2 AND r.expires_at <= $3
+		AND COALESCE(a.payment_status, 'pending_deposit') <> 'release_initiated'
+		RETURNING r.id, r.application_id, r.actor, r.status, r.expires_at, r.tx_hash, r.created_at, r.resolved_at
+	`
+	rows, err := db.Pool.Query(ctx, query, ReleaseAuthorizationLapsed, ReleaseAuthorizationActive, now)
+	if err != nil {
+		return nil, fmt.Errorf("error lapsing release authorizations: %w", err)
+	}
+	defer rows.Close()
+
+	var lapsed []*ReleaseAuthorization
+	for rows.Next() {
+		authorization, err := scanReleaseAuthorization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning release authorization: %w", err)
+		}
+		lapsed = append(lapsed, authorization)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error lapsing release authorizations: %w", err)
+	}
+
+	return lapsed, nil
+}
+
+// executeReleaseAuthorization marks an application's active authorization as
+// executed once its release is confirmed
+func executeReleaseAuthorization(ctx context.Context, q execer, applicationID int32) error {
+	query := `
+		UPDATE release_authorizations
+		SET status = $1, resolved_at = NOW()
+		WHERE application_id = $2 AND status = $3
+	`
+	if _, err := q.Exec(ctx, query, ReleaseAuthorizationExecuted, applicationID, ReleaseAuthorizationActive); err != nil {
+		return fmt.Errorf("error executing release authorization: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Retainer states
+const (
+	RetainerStatusActive    = "active"
+	RetainerStatusEnded     = "ended"
+	RetainerStatusCancelled = "cancelled"
+)
+
+// Retainer period states
+const (
+	PeriodStatusPending        = "pending"         // created, not yet funded
+	PeriodStatusFunding        = "funding"         // deposit broadcast but unconfirmed
+	PeriodStatusAwaitingClient = "awaiting_client" // client asked to fund the escrow
+	PeriodStatusUnderfunded    = "underfunded"     // client deposited less than quoted
+	PeriodStatusFunded         = "funded"
+	PeriodStatusReleased       = "released"
+	PeriodStatusRefunded       = "refunded"
+	PeriodStatusFailed         = "failed"
+)
+
+// Retainer is a recurring contract that funds a new escrow job every period
+type Retainer struct {
+	ID             int64
+	ApplicationID  int32
+	USDAmount      int32
+	Interval       string
+	Mode           string
+	Status         string
+	StartAt        time.Time
+	EndAt          time.Time
+	PeriodsCreated int
+	NextPeriodAt   time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// RetainerPeriod is one billing period of a retainer and its escrow job
+type RetainerPeriod struct {
+	ID            int64
+	RetainerID    int64
+	PeriodNumber  int
+	USDAmount     int32
+	Status        string
+	PeriodStart   time.Time
+	TxHashDeposit *string
+	TxHashRelease *string
+	TxHashRefund  *string
+	LastError     *string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	// Client-funded periods only. Wei amounts are decimal strings because they overflow int64.
+	QuotedETHUSDPrice *string // Chainlink answer with 8 decimals when the client was asked to fund
+	RequiredWei       *string
+	DepositedWei      *string
+	TopUpWei          *string // still owed by an underfunded deposit
+	OverfundedWei     *string // sent beyond the quote, to be refunded as dust
+}
+
+const retainerColumns = `id, application_id, usd_amount, billing_interval, mode, status, start_at, end_at,
+	periods_created, next_period_at, created_at, updated_at`
+
+const retainerPeriodColumns = `id, retainer_id, period_number, usd_amount, status, period_start,
+	tx_hash_deposit, tx_hash_release, tx_hash_refund, last_error, created_at, updated_at,
+	quoted_eth_usd_price::text, required_wei::text, deposited_wei::text, topup_wei::text, overfunded_wei::text`
+
+// CreateRetainer stores a new active retainer whose first period starts at StartAt
+func (db *DB) CreateRetainer(ctx context.Context, retainer *Retainer) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO retainers (application_id, usd_amount, billing_interval, mode, status, start_at, end_at, next_period_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $6)
+		RETURNING ` + retainerColumns
+
+	row := db.Pool.QueryRow(ctx, query,
+		retainer.ApplicationID,
+		retainer.USDAmount,
+		retainer.Interval,
+		retainer.Mode,
+		RetainerStatusActive,
+		retainer.StartAt,
+		retainer.EndAt,
+	)
+	created, err := scanRetainer(row)
+	if err != nil {
+		return fmt.Errorf("error creating retainer: %w", err)
+	}
+
+	*retainer = *created
+	return nil
+}
+
+// GetRetainer returns a retainer by ID, or nil if it does not exist
+func (db *DB) GetRetainer(ctx context.Context, id int64) (*Retainer, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + retainerColumns + ` FROM retainers WHERE id = $1`
+
+	retainer, err := scanRetainer(db.Pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying retainer: %w", err)
+	}
+
+	return retainer, nil
+}
+
+// ListApplicationRetainerIDs returns the retainers created for an application
+func (db *DB) ListApplicationRetainerIDs(ctx context.Context, applicationID int32) ([]int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.Pool.Query(ctx, `SELECT id FROM retainers WHERE application_id = $1 ORDER BY id`, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing retainers: %w", err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("error scanning retainers: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ListDueRetainers returns active retainers whose next period has started
+func (db *DB) ListDueRetainers(ctx context.Context, now time.Time) ([]*Retainer, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + retainerColumns + `
+		FROM retainers
+		WHERE status = $1 AND next_period_at <= $2
+		ORDER BY next_period_at
+	`
+
+	rows, err := db.Pool.Query(ctx, query, RetainerStatusActive, now)
+	if err != nil {
+		return nil, fmt.Errorf("error listing due retainers: %w", err)
+	}
+	defer rows.Close()
+
+	var retainers []*Retainer
+	for rows.Next() {
+		retainer, err := scanRetainer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning retainer: %w", err)
+		}
+		retainers = append(retainers, retainer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing due retainers: %w", err)
+	}
+
+	return retainers, nil
+}
+
+// SetRetainerStatus ends or cancels a retainer so no further periods are created
+func (db *DB) SetRetainerStatus(ctx context.Context, id int64, status string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE retainers SET status = $1, updated_at = NOW() WHERE id = $2`
+
+	if _, err := db.Pool.Exec(ctx, query, status, id); err != nil {
+		return fmt.Errorf("error updating retainer: %w", err)
+	}
+
+	return nil
+}
+
+// StartRetainerPeriod creates the next period of a retainer and advances its
+// schedule in one transaction. It returns nil if the period already exists.
+func (db *DB) StartRetainerPeriod(ctx context.Context, retainer *Retainer, periodStart, nextPeriodAt time.Time) (*RetainerPeriod, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	periodNumber := retainer.PeriodsCreated + 1
+	query := `
+		INSERT INTO retainer_periods (retainer_id, period_number, usd_amount, status, period_start)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (retainer_id, period_number) DO NOTHING
+		RETURNING ` + retainerPeriodColumns
+
+	period, err := scanRetainerPeriod(tx.QueryRow(ctx, query, retainer.ID, periodNumber, retainer.USDAmount, PeriodStatusPending, periodStart))
+	if errors.Is(err, pgx.ErrNoRows) {
+		period = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error creating retainer period: %w", err)
+	}
+
+	advance := `
+		UPDATE retainers
+		SET periods_created = $1, next_period_at = $2, updated_at = NOW()
+		WHERE id = $3
+	`
+	if _, err := tx.Exec(ctx, advance, periodNumber, nextPeriodAt, retainer.ID); err != nil {
+		return nil, fmt.Errorf("error advancing retainer schedule: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing retainer period: %w", err)
+	}
+
+	return period, nil
+}
+
+// GetRetainerPeriod returns one period of a retainer, or nil if it does not exist
+func (db *DB) GetRetainerPeriod(ctx context.Context, retainerID int64, periodNumber int) (*RetainerPeriod, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + retainerPeriodColumns + ` FROM retainer_periods WHERE retainer_id = $1 AND period_number = $2`
+
+	period, err := scanRetainerPeriod(db.Pool.QueryRow(ctx, query, retainerID, periodNumber))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying retainer period: %w", err)
+	}
+
+	return period, nil
+}
+
+// ListRetainerPeriods returns every period of a retainer in order
+func (db *DB) ListRetainerPeriods(ctx context.Context, retainerID int64) ([]*RetainerPeriod, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + retainerPeriodColumns + ` FROM retainer_periods WHERE retainer_id = $1 ORDER BY period_number`
+	return db.queryRetainerPeriods(ctx, query, retainerID)
+}
+
+// ListRetainerPeriodsByStatus returns periods in the given states across all retainers
+func (db *DB) ListRetainerPeriodsByStatus(ctx context.Context, statuses ...string) ([]*RetainerPeriod, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + retainerPeriodColumns + ` FROM retainer_periods WHERE status = ANY($1) ORDER BY id`
+	return db.queryRetainerPeriods(ctx, query, statuses)
+}
+
+// UpdateRetainerPeriod stores a period's new status and, for "deposit",
+// "release" or "refund", the transaction hash that produced it
+func (db *DB) UpdateRetainerPeriod(ctx context.Context, id int64, status string, txType string, txHash *string, lastError *string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	column := map[string]string{
+		"deposit": "tx_hash_deposit",
+		"release": "tx_hash_release",
+		"refund":  "tx_hash_refund",
+	}[txType]
+
+	query := `UPDATE retainer_periods SET status = $1, last_error = $2, updated_at = NOW() WHERE id = $3`
+	args := []interface{}{status, lastError, id}
+	if column != "" {
+		query = `UPDATE retainer_periods SET status = $1, last_error = $2, ` + column + ` = $4, updated_at = NOW() WHERE id = $3`
+		args = append(args, txHash)
+	}
+
+	if _, err := db.Pool.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("error updating retainer period: %w", err)
+	}
+
+	return nil
+}
+
+// QuoteRetainerPeriod asks the client to fund a period, pinning the ETH/USD
+// price and the wei the deposit is checked against
+func (db *DB) QuoteRetainerPeriod(ctx context.Context, id int64, ethUSDPrice, requiredWei string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE retainer_periods
+		SET status = $1, quoted_eth_usd_price = $2::numeric, required_wei = $3::numeric, last_error = NULL, updated_at = NOW()
+		WHERE id = $4
+	`
+	if _, err := db.Pool.Exec(ctx, query, PeriodStatusAwaitingClient, ethUSDPrice, requiredWei, id); err != nil {
+		return fmt.Errorf("error quoting retainer period: %w", err)
+	}
+
+	return nil
+}
+
+// RecordRetainerPeriodDeposit stores a client's deposit and how it compared
+// with the quote; topUpWei and overfundedWei are nil when they don't apply
+func (db *DB) RecordRetainerPeriodDeposit(ctx context.Context, id int64, status string, txHash string, depositedWei string, topUpWei, overfundedWei *string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE retainer_periods
+		SET status = $1, tx_hash_deposit = $2, deposited_wei = $3::numeric,
+			topup_wei = $4::numeric, overfunded_wei = $5::numeric, last_error = NULL, updated_at = NOW()
+		WHERE id = $6
+	`
+	if _, err := db.Pool.Exec(ctx, query, status, txHash, depositedWei, topUpWei, overfundedWei, id); err != nil {
+		return fmt.Errorf("error recording retainer period deposit: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) queryRetainerPeriods(ctx context.Context, query string, args ...interface{}) ([]*RetainerPeriod, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing retainer periods: %w", err)
+	}
+	defer rows.Close()
+
+	var periods []*RetainerPeriod
+	for rows.Next() {
+		period, err := scanRetainerPeriod(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning retainer period: %w", err)
+		}
+		periods = append(periods, period)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing retainer periods: %w", err)
+	}
+
+	return periods, nil
+}
+
+func scanRetainer(row pgx.Row) (*Retainer, error) {
+	retainer := &Retainer{}
+	err := row.Scan(
+		&retainer.ID,
+		&retainer.ApplicationID,
+		&retainer.USDAmount,
+		&retainer.Interval,
+		&retainer.Mode,
+		&retainer.Status,
+		&retainer.StartAt,
+		&retainer.EndAt,
+		&retainer.PeriodsCreated,
+		&retainer.NextPeriodAt,
+		&retainer.CreatedAt,
+		&retainer.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return retainer, nil
+}
+
+func scanRetainerPeriod(row pgx.Row) (*RetainerPeriod, error) {
+	period := &RetainerPeriod{}
+	err := row.Scan(
+		&period.ID,
+		&period.RetainerID,
+		&period.PeriodNumber,
+		&period.USDAmount,
+		&period.Status,
+		&period.PeriodStart,
+		&period.TxHashDeposit,
+		&period.TxHashRelease,
+		&period.TxHashRefund,
+		&period.LastError,
+		&period.CreatedAt,
+		&period.UpdatedAt,
+		&period.QuotedETHUSDPrice,
+		&period.RequiredWei,
+		&period.DepositedWei,
+		&period.TopUpWei,
+		&period.OverfundedWei,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
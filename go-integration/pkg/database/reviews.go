@@ -0,0 +1,304 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Review states
+const (
+	ReviewStatusOpen     = "open"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
+// ActorReviewer records payment status changes made by resolving a review
+const ActorReviewer = "reviewer"
+
+// ErrReviewResolved is returned by ResolveReview for a review that is no longer open
+var ErrReviewResolved = errors.New("review is already resolved")
+
+// Review is an operation flagged by a velocity rule and held until an admin
+// approves or rejects it. Held escrows and refunds move the application to
+// pending_review; PreviousStatus is restored when the review is resolved.
+// Held top-ups leave the application's status alone.
+type Review struct {
+	ID               int64
+	ApplicationID    int32
+	Operation        string
+	Params           OperationParams
+	PreviousStatus   paymentstatus.Status // empty for top-ups
+	Rule             string               // comma-separated when several rules flagged the operation
+	Detail           string
+	Status           string
+	ResolvedBy       *string
+	ResolutionReason *string
+	ResolvedAt       *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+const reviewColumns = `id, application_id, operation, params, COALESCE(previous_status, ''), rule, detail, status,
+	resolved_by, resolution_reason, resolved_at, created_at, updated_at`
+
+// reviewAudit is the state of a review recorded in the audit log
+type reviewAudit struct {
+	ReviewID      int64                `json:"review_id"`
+	Operation     string               `json:"operation"`
+	Status        string               `json:"status"`
+	PaymentStatus paymentstatus.Status `json:"payment_status,omitempty"`
+}
+
+// CreateReview holds an operation for review. When review.PreviousStatus is
+// set the application moves from it to pending_review in the same
+// transaction, or ErrStatusConflict is returned if its status has moved on.
+func (db *DB) CreateReview(ctx context.Context, review *Review) (*Review, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if review.PreviousStatus != "" {
+		if err := setReviewPaymentStatus(ctx, tx, review.ApplicationID, review.PreviousStatus, paymentstatus.PendingReview, ActorGateway); err != nil {
+			return nil, err
+		}
+	}
+
+	params, err := json.Marshal(review.Params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding review params: %w", err)
+	}
+	var previousStatus *paymentstatus.Status
+	if review.PreviousStatus != "" {
+		previousStatus = &review.PreviousStatus
+	}
+
+	query := `
+		INSERT INTO payment_reviews (application_id, operation, params, previous_status, rule, detail, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + reviewColumns
+	created, err := scanReview(tx.QueryRow(ctx, query, review.ApplicationID, review.Operation, params, previousStatus, review.Rule, review.Detail, ReviewStatusOpen))
+	if err != nil {
+		return nil, fmt.Errorf("error creating review: %w", err)
+	}
+
+	after, _ := json.Marshal(reviewAudit{ReviewID: created.ID, Operation: created.Operation, Status: created.Status, PaymentStatus: reviewPaymentStatus(created, paymentstatus.PendingReview)})
+	entry := AuditEntry{
+		Action:        "review.open",
+		ApplicationID: &created.ApplicationID,
+		Actor:         ActorGateway,
+		Reason:        created.Detail,
+		After:         after,
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing review: %w", err)
+	}
+	db.invalidateDetails(created.ApplicationID)
+
+	return created, nil
+}
+
+// GetReview returns a review, or nil if it doesn't exist
+func (db *DB) GetReview(ctx context.Context, id int64) (*Review, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + reviewColumns + ` FROM payment_reviews WHERE id = $1`
+	review, err := scanReview(db.Pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying review: %w", err)
+	}
+
+	return review, nil
+}
+
+// ReviewListSpec is what GET /admin/reviews can sort and filter on
+var ReviewListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"id":             {Column: "id", Kind: listquery.Int, Sortable: true, Ops: []listquery.Op{listquery.Gt, listquery.Lt}},
+		"application_id": {Column: "application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"operation":      {Column: "operation", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"status":         {Column: "status", Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"created_at":     {Column: "created_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+		"updated_at":     {Column: "updated_at", Kind: listquery.Time, Sortable: true, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+	},
+	ID:           "id",
+	DefaultLimit: defaultListLimit,
+	MaxLimit:     maxListLimit,
+}
+
+// FindReviews returns a page of reviews matching params and the cursor of
+// the next page, or "" if it is the last
+func (db *DB) FindReviews(ctx context.Context, params listquery.Params) ([]*Review, string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var args []interface{}
+	query := `SELECT ` + reviewColumns + ` FROM payment_reviews WHERE TRUE` + listClause(params, &args)
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error finding reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var reviews []*Review
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("error scanning review: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error finding reviews: %w", err)
+	}
+
+	reviews, next := listquery.Page(reviews, params, reviewListValue)
+	return reviews, next, nil
+}
+
+func reviewListValue(review *Review, field string) any {
+	switch field {
+	case "created_at":
+		return review.CreatedAt
+	case "updated_at":
+		return review.UpdatedAt
+	default:
+		return review.ID
+	}
+}
+
+// ResolveReview approves or rejects an open review, restoring the held
+// application's previous payment status, and records the decision in the
+// audit log. It returns ErrReviewResolved if the review isn't open.
+func (db *DB) ResolveReview(ctx context.Context, id int64, status, actor, reason string) (*Review, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE payment_reviews
+		SET status = $1, resolved_by = $2, resolution_reason = $3, resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $4 AND status = $5
+		RETURNING ` + reviewColumns
+	review, err := scanReview(tx.QueryRow(ctx, query, status, actor, reason, id, ReviewStatusOpen))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewResolved
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error resolving review: %w", err)
+	}
+
+	if review.PreviousStatus != "" {
+		if err := setReviewPaymentStatus(ctx, tx, review.ApplicationID, paymentstatus.PendingReview, review.PreviousStatus, ActorReviewer); err != nil {
+			return nil, err
+		}
+	}
+
+	before, _ := json.Marshal(reviewAudit{ReviewID: review.ID, Operation: review.Operation, Status: ReviewStatusOpen, PaymentStatus: reviewPaymentStatus(review, paymentstatus.PendingReview)})
+	after, _ := json.Marshal(reviewAudit{ReviewID: review.ID, Operation: review.Operation, Status: review.Status, PaymentStatus: reviewPaymentStatus(review, review.PreviousStatus)})
+	entry := AuditEntry{
+		Action:        "review." + map[string]string{ReviewStatusApproved: "approve", ReviewStatusRejected: "reject"}[status],
+		ApplicationID: &review.ApplicationID,
+		Actor:         actor,
+		Reason:        reason,
+		Before:        before,
+		After:         after,
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing review: %w", err)
+	}
+	db.invalidateDetails(review.ApplicationID)
+
+	return review, nil
+}
+
+// setReviewPaymentStatus moves an application between from and to, recording
+// the transition, or returns ErrStatusConflict if it isn't in from
+func setReviewPaymentStatus(ctx context.Context, tx pgx.Tx, applicationID int32, from, to paymentstatus.Status, actor string) error {
+	query := `
+		UPDATE applications
+		SET payment_status = $1
+		WHERE id = $2 AND COALESCE(payment_status, 'pending_deposit') = $3
+	`
+	tag, err := tx.Exec(ctx, query, to, applicationID, from)
+	if err != nil {
+		return fmt.Errorf("error updating payment status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+
+	eventQuery := `
+		INSERT INTO payment_events (application_id, status, actor)
+		VALUES ($1, $2, $3)
+	`
+	if _, err := tx.Exec(ctx, eventQuery, applicationID, to, actor); err != nil {
+		return fmt.Errorf("error recording payment event: %w", err)
+	}
+	return nil
+}
+
+// reviewPaymentStatus is the application's payment status to audit for a
+// review, or empty when the review doesn't hold the application
+func reviewPaymentStatus(review *Review, status paymentstatus.Status) paymentstatus.Status {
+	if review.PreviousStatus == "" {
+		return ""
+	}
+	return status
+}
+
+func scanReview(row pgx.Row) (*Review, error) {
+	review := &Review{}
+	var params []byte
+	err := row.Scan(
+		&review.ID,
+		&review.ApplicationID,
+		&review.Operation,
+		&params,
+		&review.PreviousStatus,
+		&review.Rule,
+		&review.Detail,
+		&review.Status,
+		&review.ResolvedBy,
+		&review.ResolutionReason,
+		&review.ResolvedAt,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &review.Params); err != nil {
+		return nil, fmt.Errorf("error decoding review params: %w", err)
+	}
+	return review, nil
+}
@@ -0,0 +1,584 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// schemaStatements creates the tables owned by the payment gateway. The
+// platform's own tables (applications, jobs, users) are managed elsewhere, so
+// every statement here must be idempotent and safe to run on each startup.
+var schemaStatements = []string{
+	`CREATE TABLE IF NOT EXISTS payment_refunds (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		reason VARCHAR(50) NOT NULL,
+		usd_amount INTEGER NOT NULL DEFAULT 0,
+		tx_hash VARCHAR(66),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_payment_refunds_created_at ON payment_refunds(created_at)`,
+	`CREATE TABLE IF NOT EXISTS deferred_operations (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		operation VARCHAR(20) NOT NULL,
+		params JSONB NOT NULL DEFAULT '{}',
+		status VARCHAR(20) NOT NULL DEFAULT 'deferred',
+		deadline TIMESTAMPTZ NOT NULL,
+		tx_hash VARCHAR(66),
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_deferred_operations_status ON deferred_operations(status)`,
+	`ALTER TABLE deferred_operations ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE deferred_operations ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ`,
+	`CREATE TABLE IF NOT EXISTS payment_events (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		status VARCHAR(50) NOT NULL,
+		tx_hash VARCHAR(66),
+		block_number BIGINT,
+		actor VARCHAR(50) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_payment_events_application_id ON payment_events(application_id, id)`,
+	// The writing transaction's ID lets the change feed skip rows whose transaction is still open
+	`ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS txid xid8 NOT NULL DEFAULT pg_current_xact_id()`,
+	`CREATE INDEX IF NOT EXISTS idx_payment_events_txid ON payment_events(txid, id)`,
+	`CREATE TABLE IF NOT EXISTS transaction_costs (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		operation VARCHAR(20) NOT NULL,
+		tx_hash VARCHAR(66) NOT NULL UNIQUE,
+		gas_used BIGINT NOT NULL,
+		effective_gas_price NUMERIC(78, 0) NOT NULL,
+		cost_wei NUMERIC(78, 0) NOT NULL,
+		eth_usd_price NUMERIC(78, 0),
+		cost_usd NUMERIC(20, 6),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_transaction_costs_application_id ON transaction_costs(application_id)`,
+	`CREATE TABLE IF NOT EXISTS address_book (
+		address VARCHAR(42) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		role VARCHAR(20) NOT NULL,
+		label TEXT,
+		first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_address_book_user_id ON address_book(user_id)`,
+	`CREATE TABLE IF NOT EXISTS retainers (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		usd_amount INTEGER NOT NULL,
+		billing_interval VARCHAR(10) NOT NULL,
+		mode VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'active',
+		start_at TIMESTAMPTZ NOT NULL,
+		end_at TIMESTAMPTZ NOT NULL,
+		periods_created INTEGER NOT NULL DEFAULT 0,
+		next_period_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_retainers_due ON retainers(status, next_period_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_retainers_application_id ON retainers(application_id)`,
+	`CREATE TABLE IF NOT EXISTS retainer_periods (
+		id BIGSERIAL PRIMARY KEY,
+		retainer_id BIGINT NOT NULL REFERENCES retainers(id),
+		period_number INTEGER NOT NULL,
+		usd_amount INTEGER NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		period_start TIMESTAMPTZ NOT NULL,
+		tx_hash_deposit VARCHAR(66),
+		tx_hash_release VARCHAR(66),
+		tx_hash_refund VARCHAR(66),
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (retainer_id, period_number)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_retainer_periods_status ON retainer_periods(status)`,
+	`ALTER TABLE retainer_periods ADD COLUMN IF NOT EXISTS quoted_eth_usd_price NUMERIC(78, 0)`,
+	`ALTER TABLE retainer_periods ADD COLUMN IF NOT EXISTS required_wei NUMERIC(78, 0)`,
+	`ALTER TABLE retainer_periods ADD COLUMN IF NOT EXISTS deposited_wei NUMERIC(78, 0)`,
+	`ALTER TABLE retainer_periods ADD COLUMN IF NOT EXISTS topup_wei NUMERIC(78, 0)`,
+	`ALTER TABLE retainer_periods ADD COLUMN IF NOT EXISTS overfunded_wei NUMERIC(78, 0)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		action VARCHAR(50) NOT NULL,
+		application_id INTEGER REFERENCES applications(id),
+		actor VARCHAR(100) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		before JSONB,
+		after JSONB,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_application_id ON audit_log(application_id, id)`,
+	`CREATE TABLE IF NOT EXISTS ledger_transactions (
+		id BIGSERIAL PRIMARY KEY,
+		kind VARCHAR(30) NOT NULL,
+		reference VARCHAR(100) NOT NULL,
+		application_id INTEGER REFERENCES applications(id),
+		counterparty VARCHAR(42),
+		memo TEXT NOT NULL DEFAULT '',
+		actor VARCHAR(100) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (kind, reference)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ledger_entries (
+		id BIGSERIAL PRIMARY KEY,
+		transaction_id BIGINT NOT NULL REFERENCES ledger_transactions(id),
+		account VARCHAR(50) NOT NULL,
+		amount_wei NUMERIC(78, 0) NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries(account)`,
+	`CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries(transaction_id)`,
+	`CREATE TABLE IF NOT EXISTS contract_implementations (
+		id BIGSERIAL PRIMARY KEY,
+		proxy_address VARCHAR(42) NOT NULL,
+		implementation_address VARCHAR(42) NOT NULL,
+		first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (proxy_address, implementation_address)
+	)`,
+	`CREATE TABLE IF NOT EXISTS feature_flags (
+		flag VARCHAR(50) NOT NULL,
+		tenant VARCHAR(100) NOT NULL DEFAULT '',
+		network_id BIGINT NOT NULL DEFAULT 0,
+		enabled BOOLEAN NOT NULL,
+		actor VARCHAR(100) NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (flag, tenant, network_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS escrow_top_ups (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		usd_amount INTEGER NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		actor VARCHAR(100) NOT NULL,
+		tx_hash_deposit VARCHAR(66),
+		tx_hash_release VARCHAR(66),
+		tx_hash_refund VARCHAR(66),
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_escrow_top_ups_application_id ON escrow_top_ups(application_id)`,
+	`CREATE TABLE IF NOT EXISTS fee_overrides (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		fee_bps INTEGER NOT NULL CHECK (fee_bps >= 0 AND fee_bps <= 10000),
+		reason TEXT NOT NULL,
+		approver VARCHAR(100) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS job_webhooks (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		url TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS payment_reviews (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		operation VARCHAR(20) NOT NULL,
+		rule VARCHAR(50) NOT NULL,
+		detail TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_payment_reviews_status ON payment_reviews(status, id)`,
+	`ALTER TABLE payment_reviews ADD COLUMN IF NOT EXISTS params JSONB NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE payment_reviews ADD COLUMN IF NOT EXISTS previous_status VARCHAR(50)`,
+	`ALTER TABLE payment_reviews ADD COLUMN IF NOT EXISTS resolved_by VARCHAR(100)`,
+	`ALTER TABLE payment_reviews ADD COLUMN IF NOT EXISTS resolution_reason TEXT`,
+	`ALTER TABLE payment_reviews ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ`,
+	`CREATE INDEX IF NOT EXISTS idx_payment_refunds_application_id ON payment_refunds(application_id, created_at)`,
+	`CREATE TABLE IF NOT EXISTS contract_updates (
+		id BIGSERIAL PRIMARY KEY,
+		network_id BIGINT NOT NULL,
+		contract_address VARCHAR(42) NOT NULL,
+		previous_address VARCHAR(42) NOT NULL,
+		payload BYTEA NOT NULL,
+		signature VARCHAR(132) NOT NULL,
+		issued_at TIMESTAMPTZ NOT NULL,
+		actor VARCHAR(100) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_contract_updates_network ON contract_updates(network_id, id)`,
+	`CREATE TABLE IF NOT EXISTS health_snapshots (
+		id BIGSERIAL PRIMARY KEY,
+		taken_at TIMESTAMPTZ NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		rpc_latency_ms BIGINT,
+		db_latency_ms BIGINT,
+		last_block BIGINT,
+		workers JSONB NOT NULL DEFAULT '[]',
+		problems JSONB NOT NULL DEFAULT '[]'
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_health_snapshots_taken_at ON health_snapshots(taken_at)`,
+	// Filters of the /graphql queries
+	`CREATE INDEX IF NOT EXISTS idx_ledger_transactions_application_id ON ledger_transactions(application_id, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_payment_events_created_at ON payment_events(created_at)`,
+	`CREATE TABLE IF NOT EXISTS archived_applications (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		batch VARCHAR(100) NOT NULL,
+		object_key TEXT NOT NULL,
+		archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_archived_applications_batch ON archived_applications(batch)`,
+	`CREATE TABLE IF NOT EXISTS discovered_escrows (
+		job_id NUMERIC(20, 0) PRIMARY KEY,
+		payment_status VARCHAR(50) NOT NULL,
+		client_address VARCHAR(42) NOT NULL,
+		freelancer_address VARCHAR(42) NOT NULL,
+		usd_amount NUMERIC(78, 0) NOT NULL,
+		eth_amount_wei NUMERIC(78, 0) NOT NULL,
+		tx_hash_deposit VARCHAR(66),
+		tx_hash_release VARCHAR(66),
+		tx_hash_refund VARCHAR(66),
+		block_number BIGINT NOT NULL,
+		application_id INTEGER REFERENCES applications(id),
+		outcome VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		discovered_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		linked_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS escrow_jobs (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		job_id NUMERIC(20, 0) NOT NULL UNIQUE CHECK (job_id > 0),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	// Escrows funded before escrow_jobs existed were always posted under the application ID
+	`INSERT INTO escrow_jobs (application_id, job_id)
+		SELECT id, id FROM applications WHERE escrow_tx_hash_deposit IS NOT NULL
+		ON CONFLICT DO NOTHING`,
+	`CREATE TABLE IF NOT EXISTS client_limits (
+		scope VARCHAR(20) NOT NULL,
+		subject VARCHAR(100) NOT NULL,
+		max_open_usd BIGINT CHECK (max_open_usd >= 0),
+		max_transaction_usd BIGINT CHECK (max_transaction_usd >= 0),
+		actor VARCHAR(100) NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (scope, subject)
+	)`,
+	// A numeric key to page GET /client-limits by; existing rows are numbered as the column is added
+	`ALTER TABLE client_limits ADD COLUMN IF NOT EXISTS id BIGSERIAL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_client_limits_id ON client_limits(id)`,
+	`CREATE TABLE IF NOT EXISTS escrow_tenants (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		tenant VARCHAR(100) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_escrow_tenants_tenant ON escrow_tenants(tenant)`,
+	`CREATE TABLE IF NOT EXISTS release_authorizations (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		actor VARCHAR(100) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		tx_hash VARCHAR(66),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		resolved_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_release_authorizations_application_id ON release_authorizations(application_id, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_release_authorizations_active ON release_authorizations(expires_at) WHERE status = 'active'`,
+	// The API request a status change traces back to; see pkg/trace
+	`ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS trace_id VARCHAR(64)`,
+	`CREATE INDEX IF NOT EXISTS idx_payment_events_trace_id ON payment_events(trace_id) WHERE trace_id IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS settlement_summaries (
+		day DATE PRIMARY KEY,
+		deposit_count BIGINT NOT NULL,
+		deposit_usd BIGINT NOT NULL,
+		release_count BIGINT NOT NULL,
+		release_usd BIGINT NOT NULL,
+		refund_count BIGINT NOT NULL,
+		refund_usd BIGINT NOT NULL,
+		fee_wei NUMERIC(78, 0) NOT NULL,
+		reserve_wei NUMERIC(78, 0) NOT NULL,
+		reserve_paid_wei NUMERIC(78, 0) NOT NULL,
+		gas_transactions BIGINT NOT NULL,
+		gas_wei NUMERIC(78, 0) NOT NULL,
+		gas_usd NUMERIC(20, 6) NOT NULL,
+		net_escrow_usd BIGINT NOT NULL,
+		net_treasury_wei NUMERIC(78, 0) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ledger_transactions_created_at ON ledger_transactions(created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_transaction_costs_created_at ON transaction_costs(created_at)`,
+	`CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id BIGSERIAL PRIMARY KEY,
+		reason TEXT NOT NULL DEFAULT '',
+		started_by VARCHAR(100) NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		expected_end TIMESTAMPTZ,
+		ended_by VARCHAR(100),
+		ended_at TIMESTAMPTZ
+	)`,
+	// At most one window is open at a time
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_maintenance_windows_open ON maintenance_windows((ended_at IS NULL)) WHERE ended_at IS NULL`,
+	`ALTER TABLE health_snapshots ADD COLUMN IF NOT EXISTS maintenance BOOLEAN NOT NULL DEFAULT false`,
+	`CREATE TABLE IF NOT EXISTS application_tags (
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		tag VARCHAR(50) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (application_id, tag)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_application_tags_tag ON application_tags(tag, application_id)`,
+	`CREATE TABLE IF NOT EXISTS job_disputes (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		reason TEXT NOT NULL,
+		opened_by VARCHAR(100) NOT NULL,
+		opened_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		outcome VARCHAR(20),
+		justification TEXT,
+		resolved_by VARCHAR(100),
+		resolved_at TIMESTAMPTZ
+	)`,
+	// At most one open dispute per application
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_job_disputes_open ON job_disputes(application_id) WHERE resolved_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS dispute_evidence (
+		id BIGSERIAL PRIMARY KEY,
+		dispute_id BIGINT NOT NULL REFERENCES job_disputes(id),
+		kind VARCHAR(30) NOT NULL,
+		uri TEXT,
+		sha256 CHAR(64),
+		description TEXT NOT NULL DEFAULT '',
+		submitted_by VARCHAR(100) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (uri IS NOT NULL OR sha256 IS NOT NULL)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_dispute_evidence_dispute_id ON dispute_evidence(dispute_id, id)`,
+	// Evidence is what a resolution was justified with, so not even the
+	// gateway's own role may change or remove it once submitted
+	`CREATE OR REPLACE FUNCTION reject_dispute_evidence_change() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'dispute evidence is immutable';
+	END;
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS dispute_evidence_immutable ON dispute_evidence`,
+	`CREATE TRIGGER dispute_evidence_immutable BEFORE UPDATE OR DELETE ON dispute_evidence
+		FOR EACH ROW EXECUTE FUNCTION reject_dispute_evidence_change()`,
+	// Every transaction the gateway signed, by account and nonce; a nonce
+	// without a row was used by someone else
+	`CREATE TABLE IF NOT EXISTS signed_transactions (
+		address VARCHAR(42) NOT NULL,
+		nonce BIGINT NOT NULL,
+		tx_hash VARCHAR(66) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (address, nonce)
+	)`,
+	// Every transaction signed for an operation, written before it is sent
+	`CREATE TABLE IF NOT EXISTS broadcast_transactions (
+		tx_hash VARCHAR(66) PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		intent_key VARCHAR(100) NOT NULL,
+		operation VARCHAR(50) NOT NULL,
+		address VARCHAR(42) NOT NULL,
+		nonce BIGINT NOT NULL,
+		cancels VARCHAR(66),
+		outcome VARCHAR(20),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		resolved_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_broadcast_transactions_unresolved ON broadcast_transactions(address, nonce) WHERE outcome IS NULL`,
+	// The nonce up to which each signer's activity has been checked
+	`CREATE TABLE IF NOT EXISTS wallet_watermarks (
+		address VARCHAR(42) PRIMARY KEY,
+		nonce BIGINT NOT NULL,
+		checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS signing_halts (
+		id BIGSERIAL PRIMARY KEY,
+		reason TEXT NOT NULL,
+		tripped_by VARCHAR(100) NOT NULL,
+		tripped_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		rearmed_by VARCHAR(100),
+		rearmed_at TIMESTAMPTZ
+	)`,
+	// At most one halt is in force at a time
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_halts_open ON signing_halts((rearmed_at IS NULL)) WHERE rearmed_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS kill_switch_approvals (
+		id BIGSERIAL PRIMARY KEY,
+		action VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL,
+		proposed_by VARCHAR(100) NOT NULL,
+		proposed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		expires_at TIMESTAMPTZ NOT NULL,
+		approved_by VARCHAR(100),
+		approved_at TIMESTAMPTZ
+	)`,
+	// The audit log is hash chained: each entry's hash covers its content and
+	// the previous entry's hash, so an edited, removed or reordered entry
+	// breaks every hash after it. Entries written before the chain have no
+	// chain_seq and are not covered.
+	`ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS chain_seq BIGINT`,
+	`ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS prev_hash CHAR(64)`,
+	`ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS entry_hash CHAR(64)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_log_chain_seq ON audit_log(chain_seq)`,
+	`CREATE OR REPLACE FUNCTION audit_entry_hash(prev_hash TEXT, action TEXT, application_id INTEGER, actor TEXT, reason TEXT, before JSONB, after JSONB, created_at TIMESTAMPTZ)
+	RETURNS TEXT AS $$
+		SELECT encode(sha256(convert_to(concat_ws(E'\x1f',
+			COALESCE(prev_hash, ''), action, COALESCE(application_id::TEXT, ''), actor, reason,
+			COALESCE(before::TEXT, ''), COALESCE(after::TEXT, ''),
+			(EXTRACT(EPOCH FROM created_at) * 1000000)::BIGINT::TEXT), 'UTF8')), 'hex')
+	$$ LANGUAGE sql IMMUTABLE`,
+	`CREATE OR REPLACE FUNCTION chain_audit_entry() RETURNS trigger AS $$
+	DECLARE
+		last_seq BIGINT;
+		last_hash TEXT;
+	BEGIN
+		PERFORM pg_advisory_xact_lock(hashtext('audit_log'));
+		SELECT chain_seq, entry_hash INTO last_seq, last_hash FROM audit_log WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1;
+		NEW.chain_seq := COALESCE(last_seq, 0) + 1;
+		NEW.prev_hash := last_hash;
+		NEW.entry_hash := audit_entry_hash(last_hash, NEW.action, NEW.application_id, NEW.actor, NEW.reason, NEW.before, NEW.after, NEW.created_at);
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_log_chain ON audit_log`,
+	`CREATE TRIGGER audit_log_chain BEFORE INSERT ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION chain_audit_entry()`,
+	`CREATE OR REPLACE FUNCTION reject_audit_log_change() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'the audit log is append-only';
+	END;
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log`,
+	`CREATE TRIGGER audit_log_immutable BEFORE UPDATE OR DELETE ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION reject_audit_log_change()`,
+	`CREATE TABLE IF NOT EXISTS base_fee_samples (
+		block_number BIGINT PRIMARY KEY,
+		base_fee NUMERIC(78, 0) NOT NULL,
+		sampled_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_base_fee_samples_sampled_at ON base_fee_samples(sampled_at)`,
+	`CREATE TABLE IF NOT EXISTS job_display_currencies (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		client_currency CHAR(3),
+		freelancer_currency CHAR(3),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS funding_links (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		usd_amount INTEGER NOT NULL,
+		eth_usd_price NUMERIC(78, 0) NOT NULL,
+		value_wei NUMERIC(78, 0) NOT NULL,
+		uri TEXT NOT NULL,
+		actor VARCHAR(100) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		tx_hash VARCHAR(66),
+		funded_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		resolved_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_funding_links_application_id ON funding_links(application_id, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_funding_links_watched ON funding_links(expires_at) WHERE status IN ('open', 'expired')`,
+	`CREATE TABLE IF NOT EXISTS chain_intents (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		operation VARCHAR(50) NOT NULL,
+		params JSONB NOT NULL,
+		idempotency_key VARCHAR(100) NOT NULL,
+		outcome VARCHAR(20),
+		tx_hash VARCHAR(66),
+		error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		resolved_at TIMESTAMPTZ
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_chain_intents_unresolved ON chain_intents(idempotency_key) WHERE outcome IS NULL`,
+	`CREATE INDEX IF NOT EXISTS idx_chain_intents_application_id ON chain_intents(application_id, id)`,
+	`CREATE TABLE IF NOT EXISTS payout_preferences (
+		freelancer_user_id INTEGER PRIMARY KEY,
+		threshold_usd BIGINT NOT NULL,
+		weekday SMALLINT NOT NULL,
+		hour SMALLINT NOT NULL,
+		updated_by VARCHAR(100) NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS payout_holds (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		freelancer_user_id INTEGER NOT NULL,
+		usd_amount BIGINT NOT NULL,
+		deferred_operation_id BIGINT NOT NULL REFERENCES deferred_operations(id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_payout_holds_freelancer ON payout_holds(freelancer_user_id)`,
+	`CREATE TABLE IF NOT EXISTS event_checkpoints (
+		name VARCHAR(50) PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		block_hash VARCHAR(66) NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS indexed_events (
+		tx_hash VARCHAR(66) NOT NULL,
+		log_index INTEGER NOT NULL,
+		block_number BIGINT NOT NULL,
+		block_hash VARCHAR(66) NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		job_id NUMERIC(20, 0) NOT NULL,
+		client_address VARCHAR(42),
+		freelancer_address VARCHAR(42),
+		usd_amount NUMERIC(78, 0),
+		eth_amount_wei NUMERIC(78, 0) NOT NULL,
+		indexed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (tx_hash, log_index)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_indexed_events_block ON indexed_events(block_number)`,
+	`CREATE INDEX IF NOT EXISTS idx_indexed_events_job ON indexed_events(job_id, block_number, log_index)`,
+	`CREATE TABLE IF NOT EXISTS kyc_holds (
+		id BIGSERIAL PRIMARY KEY,
+		application_id INTEGER NOT NULL REFERENCES applications(id),
+		freelancer_user_id INTEGER NOT NULL,
+		usd_amount BIGINT NOT NULL,
+		operation VARCHAR(20) NOT NULL,
+		params JSONB NOT NULL,
+		kyc_status VARCHAR(20) NOT NULL,
+		last_error TEXT,
+		status VARCHAR(20) NOT NULL,
+		resolved_by VARCHAR(100),
+		resolution_reason TEXT,
+		checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		resolved_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_kyc_holds_open ON kyc_holds(application_id) WHERE status = 'open'`,
+	`CREATE INDEX IF NOT EXISTS idx_kyc_holds_status ON kyc_holds(status, id)`,
+	`CREATE TABLE IF NOT EXISTS idempotency_keys (
+		tenant VARCHAR(100) NOT NULL,
+		key VARCHAR(255) NOT NULL,
+		request_hash CHAR(64) NOT NULL,
+		status_code INTEGER,
+		content_type VARCHAR(100),
+		response_body BYTEA,
+		tx_hash VARCHAR(66),
+		claimed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		completed_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (tenant, key)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at)`,
+	`CREATE TABLE IF NOT EXISTS application_status_seen (
+		application_id INTEGER PRIMARY KEY REFERENCES applications(id),
+		status VARCHAR(50) NOT NULL,
+		seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+}
+
+// Migrate creates any missing gateway-owned tables
+func (db *DB) Migrate(ctx context.Context) error {
+	for _, stmt := range schemaStatements {
+		if _, err := db.Pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("error applying schema: %w", err)
+		}
+	}
+	return nil
+}
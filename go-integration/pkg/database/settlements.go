@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/ledger"
+)
+
+// SettlementSummary is the money that moved through the gateway on one UTC
+// day. USD amounts are whole dollars at each job's agreed price; wei amounts
+// are decimal strings because they overflow int64.
+type SettlementSummary struct {
+	Day             time.Time
+	DepositCount    int64
+	DepositUSD      int64
+	ReleaseCount    int64
+	ReleaseUSD      int64
+	RefundCount     int64
+	RefundUSD       int64
+	FeeWei          string // platform fees received from releases
+	ReserveWei      string // the part of FeeWei set aside in the reserve fund
+	ReservePaidWei  string // reserve payouts to users
+	GasTransactions int64
+	GasWei          string
+	GasUSD          string
+	NetEscrowUSD    int64  // deposits less releases and refunds: the change in funds held in escrow
+	NetTreasuryWei  string // fees less reserve payouts and gas: the change in the platform wallet
+	CreatedAt       time.Time
+}
+
+const settlementSummaryColumns = `day, deposit_count, deposit_usd, release_count, release_usd, refund_count, refund_usd,
+	fee_wei::text, reserve_wei::text, reserve_paid_wei::text, gas_transactions, gas_wei::text, gas_usd::text,
+	net_escrow_usd, net_treasury_wei::text, created_at`
+
+func scanSettlementSummary(row pgx.Row) (*SettlementSummary, error) {
+	var s SettlementSummary
+	err := row.Scan(&s.Day, &s.DepositCount, &s.DepositUSD, &s.ReleaseCount, &s.ReleaseUSD, &s.RefundCount, &s.RefundUSD,
+		&s.FeeWei, &s.ReserveWei, &s.ReservePaidWei, &s.GasTransactions, &s.GasWei, &s.GasUSD,
+		&s.NetEscrowUSD, &s.NetTreasuryWei, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// BuildSettlementSummary totals the deposits, releases and refunds confirmed
+// on a UTC day with the fees, reserve payouts and gas booked that day, and
+// stores the result, replacing any earlier summary of the day
+func (db *DB) BuildSettlementSummary(ctx context.Context, day time.Time) (*SettlementSummary, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	query := `
+		WITH payments AS (
+			SELECT
+				COUNT(*) FILTER (WHERE e.status = 'deposited') AS deposit_count,
+				COALESCE(SUM(a.agreed_usd_amount) FILTER (WHERE e.status = 'deposited'), 0) AS deposit_usd,
+				COUNT(*) FILTER (WHERE e.status = 'released') AS release_count,
+				COALESCE(SUM(a.agreed_usd_amount) FILTER (WHERE e.status = 'released'), 0) AS release_usd,
+				COUNT(*) FILTER (WHERE e.status = 'refunded') AS refund_count,
+				COALESCE(SUM(a.agreed_usd_amount) FILTER (WHERE e.status = 'refunded'), 0) AS refund_usd
+			FROM payment_events e
+			JOIN applications a ON a.id = e.application_id
+			WHERE e.created_at >= $1 AND e.created_at < $2
+		), fees AS (
+			SELECT
+				COALESCE(SUM(le.amount_wei) FILTER (WHERE lt.kind = $3 AND le.account = $5), 0) AS fee_wei,
+				COALESCE(-SUM(le.amount_wei) FILTER (WHERE lt.kind = $3 AND le.account = $6), 0) AS reserve_wei,
+				COALESCE(SUM(le.amount_wei) FILTER (WHERE lt.kind = $4 AND le.account = $6), 0) AS reserve_paid_wei
+			FROM ledger_transactions lt
+			JOIN ledger_entries le ON le.transaction_id = lt.id
+			WHERE lt.created_at >= $1 AND lt.created_at < $2
+		), gas AS (
+			SELECT COUNT(*) AS gas_transactions, COALESCE(SUM(cost_wei), 0) AS gas_wei, COALESCE(SUM(cost_usd), 0) AS gas_usd
+			FROM transaction_costs
+			WHERE created_at >= $1 AND created_at < $2
+		)
+		INSERT INTO settlement_summaries
+			(day, deposit_count, deposit_usd, release_count, release_usd, refund_count, refund_usd,
+			 fee_wei, reserve_wei, reserve_paid_wei, gas_transactions, gas_wei, gas_usd, net_escrow_usd, net_treasury_wei)
+		SELECT $7::date, deposit_count, deposit_usd, release_count, release_usd, refund_count, refund_usd,
+			fee_wei, reserve_wei, reserve_paid_wei, gas_transactions, gas_wei, gas_usd,
+			deposit_usd - release_usd - refund_usd, fee_wei - reserve_paid_wei - gas_wei
+		FROM payments, fees, gas
+		ON CONFLICT (day) DO UPDATE SET
+			deposit_count = EXCLUDED.deposit_count,
+			deposit_usd = EXCLUDED.deposit_usd,
+			release_count = EXCLUDED.release_count,
+			release_usd = EXCLUDED.release_usd,
+			refund_count = EXCLUDED.refund_count,
+			refund_usd = EXCLUDED.refund_usd,
+			fee_wei = EXCLUDED.fee_wei,
+			reserve_wei = EXCLUDED.reserve_wei,
+			reserve_paid_wei = EXCLUDED.reserve_paid_wei,
+			gas_transactions = EXCLUDED.gas_transactions,
+			gas_wei = EXCLUDED.gas_wei,
+			gas_usd = EXCLUDED.gas_usd,
+			net_escrow_usd = EXCLUDED.net_escrow_usd,
+			net_treasury_wei = EXCLUDED.net_treasury_wei,
+			created_at = NOW()
+		RETURNING ` + settlementSummaryColumns
+
+	summary, err := scanSettlementSummary(db.Pool.QueryRow(ctx, query, from, to,
+		ledger.KindFeeAccrual, ledger.KindReservePayout, ledger.AccountPlatformWallet, ledger.AccountReserveFund, from.Format(time.DateOnly)))
+	if err != nil {
+		return nil, fmt.Errorf("error building settlement summary: %w", err)
+	}
+	return summary, nil
+}
+
+// GetSettlementSummary returns the stored summary of a UTC day, or nil if the
+// day has not been summarised
+func (db *DB) GetSettlementSummary(ctx context.Context, day time.Time) (*SettlementSummary, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + settlementSummaryColumns + ` FROM settlement_summaries WHERE day = $1::date`
+	summary, err := scanSettlementSummary(db.Pool.QueryRow(ctx, query, day.UTC().Format(time.DateOnly)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying settlement summary: %w", err)
+	}
+	return summary, nil
+}
+
+// ListSettlementSummaries returns the stored summaries of the days from from
+// up to but excluding to, oldest first
+func (db *DB) ListSettlementSummaries(ctx context.Context, from, to time.Time) ([]*SettlementSummary, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + settlementSummaryColumns + ` FROM settlement_summaries WHERE day >= $1::date AND day < $2::date ORDER BY day`
+	rows, err := db.Pool.Query(ctx, query, from.UTC().Format(time.DateOnly), to.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("error listing settlement summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []*SettlementSummary
+	for rows.Next() {
+		summary, err := scanSettlementSummary(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning settlement summary: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing settlement summaries: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// LatestSettlementDay returns the most recent summarised day, or nil if no
+// day has been summarised yet
+func (db *DB) LatestSettlementDay(ctx context.Context) (*time.Time, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var day *time.Time
+	if err := db.Pool.QueryRow(ctx, `SELECT MAX(day) FROM settlement_summaries`).Scan(&day); err != nil {
+		return nil, fmt.Errorf("error querying latest settlement day: %w", err)
+	}
+	return day, nil
+}
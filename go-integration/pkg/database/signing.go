@@ -0,0 +1,352 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SigningHalt is a period in which the gateway refuses to sign transactions
+type SigningHalt struct {
+	ID        int64      `json:"id"`
+	Reason    string     `json:"reason"`
+	TrippedBy string     `json:"tripped_by"` // "wallet_monitor" when tripped automatically
+	TrippedAt time.Time  `json:"tripped_at"`
+	RearmedBy *string    `json:"rearmed_by,omitempty"`
+	RearmedAt *time.Time `json:"rearmed_at,omitempty"`
+}
+
+const signingHaltColumns = `id, reason, tripped_by, tripped_at, rearmed_by, rearmed_at`
+
+func scanSigningHalt(row pgx.Row) (*SigningHalt, error) {
+	var h SigningHalt
+	err := row.Scan(&h.ID, &h.Reason, &h.TrippedBy, &h.TrippedAt, &h.RearmedBy, &h.RearmedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// RecordSignedTransaction records that the gateway signed txHash with
+// address at nonce. A replacement for the same nonce overwrites the hash.
+func (db *DB) RecordSignedTransaction(ctx context.Context, address string, nonce uint64, txHash string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO signed_transactions (address, nonce, tx_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (address, nonce) DO UPDATE SET tx_hash = EXCLUDED.tx_hash
+	`
+	if _, err := db.Pool.Exec(ctx, query, address, int64(nonce), txHash); err != nil {
+		return fmt.Errorf("error recording signed transaction: %w", err)
+	}
+	return nil
+}
+
+// ListUnsignedNonces returns the nonces of address in [from, to) the gateway
+// has no record of signing, in order
+func (db *DB) ListUnsignedNonces(ctx context.Context, address string, from, to uint64) ([]uint64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if to <= from {
+		return nil, nil
+	}
+	query := `
+		SELECT n FROM generate_series($2::BIGINT, $3::BIGINT - 1) AS n
+		WHERE NOT EXISTS (SELECT 1 FROM signed_transactions WHERE address = $1 AND nonce = n)
+		ORDER BY n
+	`
+	rows, err := db.Pool.Query(ctx, query, address, int64(from), int64(to))
+	if err != nil {
+		return nil, fmt.Errorf("error querying signed transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var nonces []uint64
+	for rows.Next() {
+		var nonce int64
+		if err := rows.Scan(&nonce); err != nil {
+			return nil, fmt.Errorf("error scanning nonce: %w", err)
+		}
+		nonces = append(nonces, uint64(nonce))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating nonces: %w", err)
+	}
+	return nonces, nil
+}
+
+// GetWalletWatermark returns the nonce up to which address has been checked,
+// and false if it never has
+func (db *DB) GetWalletWatermark(ctx context.Context, address string) (uint64, bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var nonce int64
+	err := db.Pool.QueryRow(ctx, `SELECT nonce FROM wallet_watermarks WHERE address = $1`, address).Scan(&nonce)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error querying wallet watermark: %w", err)
+	}
+	return uint64(nonce), true, nil
+}
+
+// SetWalletWatermark records that address has been checked up to nonce
+func (db *DB) SetWalletWatermark(ctx context.Context, address string, nonce uint64) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO wallet_watermarks (address, nonce)
+		VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET nonce = GREATEST(wallet_watermarks.nonce, EXCLUDED.nonce), checked_at = NOW()
+	`
+	if _, err := db.Pool.Exec(ctx, query, address, int64(nonce)); err != nil {
+		return fmt.Errorf("error setting wallet watermark: %w", err)
+	}
+	return nil
+}
+
+// Kill switch actions
+const (
+	KillSwitchActivate   = "activate"
+	KillSwitchDeactivate = "deactivate"
+)
+
+// ErrSameApprover is returned when the admin key that proposed a kill switch
+// action tries to approve it too
+var ErrSameApprover = errors.New("the second approval must come from another admin key")
+
+// KillSwitchApproval is an admin's proposal to activate or deactivate the kill
+// switch, carried out once a second admin approves it before it expires
+type KillSwitchApproval struct {
+	ID         int64      `json:"id"`
+	Action     string     `json:"action"` // KillSwitchActivate or KillSwitchDeactivate
+	Reason     string     `json:"reason"`
+	ProposedBy string     `json:"proposed_by"`
+	ProposedAt time.Time  `json:"proposed_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ApprovedBy *string    `json:"approved_by,omitempty"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+}
+
+const killSwitchApprovalColumns = `id, action, reason, proposed_by, proposed_at, expires_at, approved_by, approved_at`
+
+func scanKillSwitchApproval(row pgx.Row) (*KillSwitchApproval, error) {
+	var a KillSwitchApproval
+	err := row.Scan(&a.ID, &a.Action, &a.Reason, &a.ProposedBy, &a.ProposedAt, &a.ExpiresAt, &a.ApprovedBy, &a.ApprovedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// TripSigningHalt stops the gateway signing until it is re-armed, recording
+// details in the audit log. It returns the halt already in force and false if
+// there is one, which is left unchanged.
+func (db *DB) TripSigningHalt(ctx context.Context, reason, actor string, details json.RawMessage) (*SigningHalt, bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockSigningHalts(ctx, tx); err != nil {
+		return nil, false, err
+	}
+	halt, tripped, err := tripSigningHalt(ctx, tx, reason, actor, details)
+	if err != nil || !tripped {
+		return halt, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("error committing signing halt: %w", err)
+	}
+	return halt, true, nil
+}
+
+// RearmSigning lifts the halt in force and returns it, or nil if signing was
+// not halted
+func (db *DB) RearmSigning(ctx context.Context, actor, reason string, details json.RawMessage) (*SigningHalt, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockSigningHalts(ctx, tx); err != nil {
+		return nil, err
+	}
+	halt, err := rearmSigning(ctx, tx, actor, reason, details)
+	if err != nil || halt == nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing signing re-arm: %w", err)
+	}
+	return halt, nil
+}
+
+// ApproveKillSwitch records actor's approval of a kill switch action. The
+// first approval opens a proposal that expires at expiresAt and is returned
+// unapproved. An approval by another admin before then carries the action out
+// in the same transaction and returns the halt it tripped or lifted, which is
+// nil when deactivating with no halt in force.
+func (db *DB) ApproveKillSwitch(ctx context.Context, action, reason, actor string, expiresAt time.Time) (*KillSwitchApproval, *SigningHalt, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockSigningHalts(ctx, tx); err != nil {
+		return nil, nil, err
+	}
+
+	openQuery := `
+		SELECT ` + killSwitchApprovalColumns + `
+		FROM kill_switch_approvals
+		WHERE action = $1 AND approved_at IS NULL AND expires_at > NOW()
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	proposal, err := scanKillSwitchApproval(tx.QueryRow(ctx, openQuery, action))
+	if errors.Is(err, pgx.ErrNoRows) {
+		insertQuery := `
+			INSERT INTO kill_switch_approvals (action, reason, proposed_by, expires_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING ` + killSwitchApprovalColumns
+		proposal, err := scanKillSwitchApproval(tx.QueryRow(ctx, insertQuery, action, reason, actor, expiresAt))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error proposing kill switch %s: %w", action, err)
+		}
+		after, _ := json.Marshal(proposal)
+		if err := insertAudit(ctx, tx, AuditEntry{Action: "kill_switch.propose", Actor: actor, Reason: reason, After: after}); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, nil, fmt.Errorf("error committing kill switch proposal: %w", err)
+		}
+		return proposal, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying kill switch proposal: %w", err)
+	}
+	if proposal.ProposedBy == actor {
+		return nil, nil, ErrSameApprover
+	}
+
+	approveQuery := `
+		UPDATE kill_switch_approvals
+		SET approved_by = $1, approved_at = NOW()
+		WHERE id = $2
+		RETURNING ` + killSwitchApprovalColumns
+	proposal, err = scanKillSwitchApproval(tx.QueryRow(ctx, approveQuery, actor, proposal.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error approving kill switch %s: %w", action, err)
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{"approval": proposal, "approval_reason": reason})
+	approvers := proposal.ProposedBy + "," + actor
+	var halt *SigningHalt
+	if action == KillSwitchActivate {
+		halt, _, err = tripSigningHalt(ctx, tx, proposal.Reason, approvers, details)
+	} else {
+		halt, err = rearmSigning(ctx, tx, approvers, proposal.Reason, details)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("error committing kill switch approval: %w", err)
+	}
+	return proposal, halt, nil
+}
+
+// lockSigningHalts serialises kill switch changes so two cannot race
+func lockSigningHalts(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('signing_halt'))`); err != nil {
+		return fmt.Errorf("error locking signing halts: %w", err)
+	}
+	return nil
+}
+
+func tripSigningHalt(ctx context.Context, tx pgx.Tx, reason, actor string, details json.RawMessage) (*SigningHalt, bool, error) {
+	active, err := scanSigningHalt(tx.QueryRow(ctx, `SELECT `+signingHaltColumns+` FROM signing_halts WHERE rearmed_at IS NULL`))
+	if err == nil {
+		return active, false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("error querying signing halt: %w", err)
+	}
+
+	query := `
+		INSERT INTO signing_halts (reason, tripped_by)
+		VALUES ($1, $2)
+		RETURNING ` + signingHaltColumns
+	halt, err := scanSigningHalt(tx.QueryRow(ctx, query, reason, actor))
+	if err != nil {
+		return nil, false, fmt.Errorf("error tripping signing halt: %w", err)
+	}
+
+	after, _ := json.Marshal(map[string]interface{}{"halt": halt, "details": details})
+	if err := insertAudit(ctx, tx, AuditEntry{Action: "signing.halt", Actor: actor, Reason: reason, After: after}); err != nil {
+		return nil, false, err
+	}
+	return halt, true, nil
+}
+
+func rearmSigning(ctx context.Context, tx pgx.Tx, actor, reason string, details json.RawMessage) (*SigningHalt, error) {
+	query := `
+		UPDATE signing_halts
+		SET rearmed_by = $1, rearmed_at = NOW()
+		WHERE rearmed_at IS NULL
+		RETURNING ` + signingHaltColumns
+	halt, err := scanSigningHalt(tx.QueryRow(ctx, query, actor))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error re-arming signing: %w", err)
+	}
+
+	after, _ := json.Marshal(map[string]interface{}{"halt": halt, "details": details})
+	if err := insertAudit(ctx, tx, AuditEntry{Action: "signing.rearm", Actor: actor, Reason: reason, After: after}); err != nil {
+		return nil, err
+	}
+	return halt, nil
+}
+
+// GetActiveSigningHalt returns the halt in force, or nil if the gateway may sign
+func (db *DB) GetActiveSigningHalt(ctx context.Context) (*SigningHalt, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	halt, err := scanSigningHalt(db.Pool.QueryRow(ctx, `SELECT `+signingHaltColumns+` FROM signing_halts WHERE rearmed_at IS NULL`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying signing halt: %w", err)
+	}
+	return halt, nil
+}
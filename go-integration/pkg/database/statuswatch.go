@@ -0,0 +1,42 @@
+package database
+
+import "sync"
+
+// statusWatch wakes readers waiting for an application's payment record to
+// change. Only writes made through this DB are seen; waiters must re-read now
+// and then for changes from other gateways or made directly in the database.
+type statusWatch struct {
+	mu      sync.Mutex
+	changed map[int32]chan struct{}
+}
+
+// StatusChanged returns a channel that is closed the next time this DB
+// writes applicationID's payment record. Take it before reading the status,
+// so a change between the read and the wait is not missed.
+func (db *DB) StatusChanged(applicationID int32) <-chan struct{} {
+	db.watch.mu.Lock()
+	defer db.watch.mu.Unlock()
+
+	if db.watch.changed == nil {
+		db.watch.changed = make(map[int32]chan struct{})
+	}
+	changed, ok := db.watch.changed[applicationID]
+	if !ok {
+		changed = make(chan struct{})
+		db.watch.changed[applicationID] = changed
+	}
+	return changed
+}
+
+// statusWritten wakes everyone waiting on applicationID. A channel nobody
+// waits on any longer stays until the next write; there is one per
+// application at most.
+func (db *DB) statusWritten(applicationID int32) {
+	db.watch.mu.Lock()
+	defer db.watch.mu.Unlock()
+
+	if changed, ok := db.watch.changed[applicationID]; ok {
+		close(changed)
+		delete(db.watch.changed, applicationID)
+	}
+}
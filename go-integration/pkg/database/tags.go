@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetJobTags returns an application's tags, sorted
+func (db *DB) GetJobTags(ctx context.Context, applicationID int32) ([]string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	return queryJobTags(ctx, db.Pool, applicationID)
+}
+
+// SetJobTags replaces an application's tags and records the change in the
+// audit log. Tags must already be normalized.
+func (db *DB) SetJobTags(ctx context.Context, applicationID int32, tags []string, actor string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := queryJobTags(ctx, tx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM application_tags WHERE application_id = $1 AND tag <> ALL($2)`, applicationID, tags); err != nil {
+		return fmt.Errorf("error removing job tags: %w", err)
+	}
+	query := `
+		INSERT INTO application_tags (application_id, tag)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT (application_id, tag) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, query, applicationID, tags); err != nil {
+		return fmt.Errorf("error adding job tags: %w", err)
+	}
+
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(tags)
+	if err := insertAudit(ctx, tx, AuditEntry{
+		Action:        "tags.set",
+		ApplicationID: &applicationID,
+		Actor:         actor,
+		Before:        beforeJSON,
+		After:         afterJSON,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing job tags: %w", err)
+	}
+	return nil
+}
+
+// tagQuerier is satisfied by both the pool and a transaction
+type tagQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func queryJobTags(ctx context.Context, q tagQuerier, applicationID int32) ([]string, error) {
+	rows, err := q.Query(ctx, `SELECT tag FROM application_tags WHERE application_id = $1 ORDER BY tag`, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying job tags: %w", err)
+	}
+	tags, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("error querying job tags: %w", err)
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags, nil
+}
+
+// tagFilter returns a condition that holds for the application whose ID is
+// in column when it carries every one of tags, appending its argument to
+// args. Without tags it is an empty string.
+func tagFilter(column string, tags []string, args *[]interface{}) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	*args = append(*args, tags)
+	n := len(*args)
+	return fmt.Sprintf(` AND (SELECT COUNT(*) FROM application_tags t WHERE t.application_id = %s AND t.tag = ANY($%d)) = cardinality($%d::text[])`, column, n, n)
+}
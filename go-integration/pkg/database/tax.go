@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/listquery"
+)
+
+// Kinds of payout in a tax export
+const (
+	PayoutKindJob            = "job"             // an application's escrow
+	PayoutKindTopUp          = "top_up"          // an amount added to a funded escrow
+	PayoutKindRetainerPeriod = "retainer_period" // one period of a recurring escrow
+)
+
+// TaxPayout is one release of escrowed funds to a freelancer. USD amounts are
+// whole dollars at the agreed price.
+type TaxPayout struct {
+	ApplicationID int32
+	JobID         int32
+	Kind          string
+	USDAmount     int64
+	TxHash        string // empty for releases recorded without a transaction hash
+	ReleasedAt    time.Time
+}
+
+// FreelancerTaxSummary is what one freelancer was paid through the gateway in
+// a calendar year, for generating tax documents
+type FreelancerTaxSummary struct {
+	UserID        int32
+	WalletAddress *string
+	TotalUSD      int64
+	ContractCount int64 // applications with at least one payout
+	Payouts       []TaxPayout
+}
+
+// TaxPayoutSpec is what GET /admin/tax-exports/{year} can filter payouts on
+var TaxPayoutSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"application_id": {Column: "p.application_id", Kind: listquery.Int, Ops: []listquery.Op{listquery.Eq, listquery.In}},
+		"kind":           {Column: "p.kind", Ops: []listquery.Op{listquery.Eq, listquery.Ne, listquery.In}},
+		"usd_amount":     {Column: "p.usd_amount", Kind: listquery.Int, Ops: []listquery.Op{listquery.Gte, listquery.Lt}},
+	},
+}
+
+// ListFreelancerTaxSummaries totals the releases to each freelancer in a UTC
+// calendar year, including top-ups and retainer periods, ordered by user ID.
+// A userID other than 0 limits the export to that freelancer, filters to the
+// payouts matching them, and tags to the payouts of applications carrying
+// every one of them.
+func (db *DB) ListFreelancerTaxSummaries(ctx context.Context, year int, userID int32, filters []listquery.Condition, tags []string) ([]*FreelancerTaxSummary, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	args := []interface{}{from, to,
+		PayoutKindJob, PayoutKindTopUp, PayoutKindRetainerPeriod, TopUpStatusReleased, PeriodStatusReleased, userID}
+
+	// A job counts once, when it was first released; a resync that records
+	// the release again doesn't move it into another year
+	query := `
+		WITH payouts AS (
+			SELECT * FROM (
+				SELECT DISTINCT ON (e.application_id)
+					a.user_id, a.id AS application_id, a.job_id, $3::text AS kind,
+					COALESCE(a.agreed_usd_amount, 0)::bigint AS usd_amount, e.tx_hash, e.created_at AS released_at
+				FROM payment_events e
+				JOIN applications a ON a.id = e.application_id
+				WHERE e.status = 'released'
+				ORDER BY e.application_id, e.id
+			) jobs
+			UNION ALL
+			SELECT a.user_id, a.id, a.job_id, $4::text, t.usd_amount, t.tx_hash_release, t.updated_at
+			FROM escrow_top_ups t
+			JOIN applications a ON a.id = t.application_id
+			WHERE t.status = $6
+			UNION ALL
+			SELECT a.user_id, a.id, a.job_id, $5::text, p.usd_amount, p.tx_hash_release, p.updated_at
+			FROM retainer_periods p
+			JOIN retainers r ON r.id = p.retainer_id
+			JOIN applications a ON a.id = r.application_id
+			WHERE p.status = $7
+		)
+		SELECT p.user_id, u.wallet_address, p.application_id, p.job_id, p.kind, p.usd_amount,
+			COALESCE(p.tx_hash, ''), p.released_at
+		FROM payouts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.released_at >= $1 AND p.released_at < $2 AND ($8 = 0 OR p.user_id = $8)` + filterClause(filters, &args) + tagFilter("p.application_id", tags, &args) + `
+		ORDER BY p.user_id, p.released_at, p.application_id
+	`
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing freelancer payouts: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []*FreelancerTaxSummary
+	var current *FreelancerTaxSummary
+	contracts := make(map[int32]bool)
+	for rows.Next() {
+		var payoutUserID int32
+		var wallet *string
+		var payout TaxPayout
+		if err := rows.Scan(&payoutUserID, &wallet, &payout.ApplicationID, &payout.JobID, &payout.Kind, &payout.USDAmount,
+			&payout.TxHash, &payout.ReleasedAt); err != nil {
+			return nil, fmt.Errorf("error scanning freelancer payout: %w", err)
+		}
+
+		if current == nil || current.UserID != payoutUserID {
+			current = &FreelancerTaxSummary{UserID: payoutUserID, WalletAddress: wallet}
+			summaries = append(summaries, current)
+			clear(contracts)
+		}
+		current.TotalUSD += payout.USDAmount
+		current.Payouts = append(current.Payouts, payout)
+		if !contracts[payout.ApplicationID] {
+			contracts[payout.ApplicationID] = true
+			current.ContractCount++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing freelancer payouts: %w", err)
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Escrow top-up states
+const (
+	TopUpStatusPending  = "pending"        // created, deposit not yet submitted
+	TopUpStatusReview   = "pending_review" // held by a velocity rule until reviewed
+	TopUpStatusFunding  = "funding"        // deposit broadcast but unconfirmed
+	TopUpStatusFunded   = "funded"
+	TopUpStatusReleased = "released"
+	TopUpStatusRefunded = "refunded"
+	TopUpStatusFailed   = "failed"
+)
+
+// TopUp is an additional deposit agreed mid-contract. The contract can't grow
+// an existing escrow, so each top-up is funded as its own escrow job and is
+// released or refunded together with the application's job.
+type TopUp struct {
+	ID            int64
+	ApplicationID int32
+	USDAmount     int32
+	Status        string
+	Reason        string
+	Actor         string
+	TxHashDeposit *string
+	TxHashRelease *string
+	TxHashRefund  *string
+	LastError     *string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+const topUpColumns = `id, application_id, usd_amount, status, reason, actor,
+	tx_hash_deposit, tx_hash_release, tx_hash_refund, last_error, created_at, updated_at`
+
+// CreateTopUp stores a pending top-up for an application
+func (db *DB) CreateTopUp(ctx context.Context, applicationID int32, usdAmount int32, reason, actor string) (*TopUp, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO escrow_top_ups (application_id, usd_amount, status, reason, actor)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + topUpColumns
+
+	topUp, err := scanTopUp(db.Pool.QueryRow(ctx, query, applicationID, usdAmount, TopUpStatusPending, reason, actor))
+	if err != nil {
+		return nil, fmt.Errorf("error creating top-up: %w", err)
+	}
+
+	return topUp, nil
+}
+
+// ListTopUps returns an application's top-ups, oldest first
+func (db *DB) ListTopUps(ctx context.Context, applicationID int32) ([]*TopUp, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + topUpColumns + ` FROM escrow_top_ups WHERE application_id = $1 ORDER BY id`
+	rows, err := db.Pool.Query(ctx, query, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing top-ups: %w", err)
+	}
+	defer rows.Close()
+
+	var topUps []*TopUp
+	for rows.Next() {
+		topUp, err := scanTopUp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning top-up: %w", err)
+		}
+		topUps = append(topUps, topUp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing top-ups: %w", err)
+	}
+
+	return topUps, nil
+}
+
+// UpdateTopUp stores a top-up's new status and, for "deposit", "release" or
+// "refund", the transaction hash that produced it
+func (db *DB) UpdateTopUp(ctx context.Context, id int64, status string, txType string, txHash *string, lastError *string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	column := map[string]string{
+		"deposit": "tx_hash_deposit",
+		"release": "tx_hash_release",
+		"refund":  "tx_hash_refund",
+	}[txType]
+
+	query := `UPDATE escrow_top_ups SET status = $1, last_error = $2, updated_at = NOW() WHERE id = $3`
+	args := []interface{}{status, lastError, id}
+	if column != "" {
+		query = `UPDATE escrow_top_ups SET status = $1, last_error = $2, ` + column + ` = $4, updated_at = NOW() WHERE id = $3`
+		args = append(args, txHash)
+	}
+
+	if _, err := db.Pool.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("error updating top-up: %w", err)
+	}
+
+	return nil
+}
+
+func scanTopUp(row pgx.Row) (*TopUp, error) {
+	topUp := &TopUp{}
+	err := row.Scan(
+		&topUp.ID,
+		&topUp.ApplicationID,
+		&topUp.USDAmount,
+		&topUp.Status,
+		&topUp.Reason,
+		&topUp.Actor,
+		&topUp.TxHashDeposit,
+		&topUp.TxHashRelease,
+		&topUp.TxHashRefund,
+		&topUp.LastError,
+		&topUp.CreatedAt,
+		&topUp.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return topUp, nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GetClientEscrowUSD returns the USD a poster has escrowed since a time, across
+// job deposits and top-ups, excluding one application's own deposit
+func (db *DB) GetClientEscrowUSD(ctx context.Context, posterUserID int32, excludeApplicationID int32, since time.Time) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT
+			COALESCE((
+				SELECT SUM(a.agreed_usd_amount)
+				FROM applications a
+				JOIN jobs j ON a.job_id = j.id
+				WHERE j.user_id = $1 AND a.id <> $2
+				AND EXISTS (
+					SELECT 1 FROM payment_events e
+					WHERE e.application_id = a.id
+					AND e.status IN ('deposit_initiated', 'deposited')
+					AND e.created_at >= $3
+				)
+			), 0) +
+			COALESCE((
+				SELECT SUM(t.usd_amount)
+				FROM escrow_top_ups t
+				JOIN applications a ON t.application_id = a.id
+				JOIN jobs j ON a.job_id = j.id
+				WHERE j.user_id = $1 AND t.status <> ALL($4)
+				AND t.created_at >= $3
+			), 0)
+	`
+
+	var total int64
+	err := db.Pool.QueryRow(ctx, query, posterUserID, excludeApplicationID, since, []string{TopUpStatusPending, TopUpStatusReview, TopUpStatusFailed}).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("error querying client escrow volume: %w", err)
+	}
+
+	return total, nil
+}
+
+// CountFreelancerRefunds returns how many refunds were submitted on a
+// freelancer's applications since a time
+func (db *DB) CountFreelancerRefunds(ctx context.Context, applicantUserID int32, since time.Time) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*)
+		FROM payment_refunds r
+		JOIN applications a ON r.application_id = a.id
+		WHERE a.user_id = $1 AND r.created_at >= $2
+	`
+
+	var count int64
+	if err := db.Pool.QueryRow(ctx, query, applicantUserID, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting freelancer refunds: %w", err)
+	}
+
+	return count, nil
+}
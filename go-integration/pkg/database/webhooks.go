@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetJobWebhook registers the callback URL that receives an application's
+// events in addition to the global webhook, replacing any earlier one
+func (db *DB) SetJobWebhook(ctx context.Context, applicationID int32, url string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO job_webhooks (application_id, url)
+		VALUES ($1, $2)
+		ON CONFLICT (application_id) DO UPDATE
+		SET url = EXCLUDED.url, updated_at = NOW()
+	`
+
+	if _, err := db.Pool.Exec(ctx, query, applicationID, url); err != nil {
+		return fmt.Errorf("error setting job webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetJobWebhook returns an application's callback URL, or "" if none is registered
+func (db *DB) GetJobWebhook(ctx context.Context, applicationID int32) (string, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var url string
+	err := db.Pool.QueryRow(ctx, `SELECT url FROM job_webhooks WHERE application_id = $1`, applicationID).Scan(&url)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error querying job webhook: %w", err)
+	}
+
+	return url, nil
+}
@@ -0,0 +1,192 @@
+// Package deploy deploys the EthJobEscrow contract with checked constructor
+// arguments and describes the result as a config.Deployment manifest.
+//
+// The contract takes its ETH/USD price feed and owner at construction. Its
+// fee is the compiled-in FEE_PERCENT, so a requested fee is checked against
+// the deployed contract rather than passed to it, and it has no arbitrator:
+// only a job's client can release or cancel it.
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+)
+
+// ErrFeeMismatch is returned when the deployed contract charges a fee other
+// than Params.FeeBPS
+var ErrFeeMismatch = errors.New("deployed contract charges a different fee")
+
+// Backend is what deploying needs of a chain connection
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// Params are the escrow contract's constructor arguments and the fee the
+// deployment is expected to charge
+type Params struct {
+	PriceFeed common.Address // Chainlink ETH/USD aggregator
+	Owner     common.Address
+	FeeBPS    int64 // checked against FEE_PERCENT once deployed
+}
+
+// Validate checks the parameters before anything is sent. The contract uses
+// the feed's raw answer as dollars with amounts.PriceDecimals, so a feed with
+// other decimals or an implausible price would misprice every escrow.
+func (p Params) Validate(ctx context.Context, backend Backend) error {
+	if p.Owner == (common.Address{}) {
+		return errors.New("owner is the zero address")
+	}
+	if p.FeeBPS < 0 || p.FeeBPS > 10000 || p.FeeBPS%100 != 0 {
+		return fmt.Errorf("fee %d bps is not a whole percent between 0 and 100", p.FeeBPS)
+	}
+
+	code, err := backend.CodeAt(ctx, p.PriceFeed, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read price feed code: %w", err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("price feed %s has no contract code", p.PriceFeed.Hex())
+	}
+
+	feed, err := oracle.NewFeed(p.PriceFeed, backend)
+	if err != nil {
+		return err
+	}
+	decimals, err := feed.Decimals(ctx)
+	if err != nil {
+		return err
+	}
+	if decimals != amounts.PriceDecimals {
+		return fmt.Errorf("price feed %s reports %d decimals; the contract needs %d", p.PriceFeed.Hex(), decimals, amounts.PriceDecimals)
+	}
+	round, err := feed.LatestRound(ctx)
+	if err != nil {
+		return err
+	}
+	if err := oracle.CheckPrice(round.Answer); err != nil {
+		return fmt.Errorf("price feed %s: %w", p.PriceFeed.Hex(), err)
+	}
+	return nil
+}
+
+// Deploy validates params, deploys bytecode with them from auth and waits for
+// the contract to be mined. The manifest describes the contract as deployed:
+// its owner and fee are read back from it. A fee other than params.FeeBPS
+// returns the manifest with ErrFeeMismatch, since the contract exists but
+// the gateway should not be pointed at it.
+func Deploy(ctx context.Context, backend Backend, auth *bind.TransactOpts, bytecode []byte, params Params) (*config.Deployment, error) {
+	if err := params.Validate(ctx, backend); err != nil {
+		return nil, err
+	}
+	chainID, err := backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	parsed, err := contracts.EthJobEscrowMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
+	}
+
+	opts := *auth
+	opts.Context = ctx
+	address, tx, _, err := bind.DeployContract(&opts, *parsed, bytecode, backend, params.PriceFeed, params.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send deployment: %w", err)
+	}
+	receipt, err := bind.WaitMined(ctx, backend, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for deployment %s: %w", tx.Hash().Hex(), err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("deployment %s reverted", tx.Hash().Hex())
+	}
+
+	code, err := backend.CodeAt(ctx, address, receipt.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deployed code: %w", err)
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("deployment %s left no code at %s", tx.Hash().Hex(), address.Hex())
+	}
+	header, err := backend.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", receipt.BlockNumber, err)
+	}
+
+	escrow, err := contracts.NewEthJobEscrow(address, backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind contract: %w", err)
+	}
+	call := &bind.CallOpts{Context: ctx, BlockNumber: receipt.BlockNumber}
+	feePercent, err := escrow.FEEPERCENT(call)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FEE_PERCENT: %w", err)
+	}
+	owner, err := escrow.Owner(call)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read owner: %w", err)
+	}
+
+	deployment := &config.Deployment{
+		NetworkID:       chainID.Int64(),
+		ContractAddress: address.Hex(),
+		DeployBlock:     receipt.BlockNumber.Uint64(),
+		DeployTx:        tx.Hash().Hex(),
+		Deployer:        auth.From.Hex(),
+		Owner:           owner.Hex(),
+		ETHUSDPriceFeed: params.PriceFeed.Hex(),
+		FeeBPS:          feePercent.Int64() * 100,
+		CodeHash:        crypto.Keccak256Hash(code).Hex(),
+		DeployedAt:      time.Unix(int64(header.Time), 0).UTC(),
+	}
+	if deployment.FeeBPS != params.FeeBPS {
+		return deployment, fmt.Errorf("%w: %d bps, not %d", ErrFeeMismatch, deployment.FeeBPS, params.FeeBPS)
+	}
+	return deployment, nil
+}
+
+// ReadBytecode reads the contract's creation bytecode from a Foundry build
+// artifact (out/PaymentGateway.sol/EthJobEscrow.json) or a file of hex
+func ReadBytecode(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bytecode: %w", err)
+	}
+
+	hex := strings.TrimSpace(string(data))
+	if strings.HasPrefix(hex, "{") {
+		var artifact struct {
+			Bytecode struct {
+				Object string `json:"object"`
+			} `json:"bytecode"`
+		}
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return nil, fmt.Errorf("failed to parse build artifact %s: %w", path, err)
+		}
+		hex = artifact.Bytecode.Object
+	}
+
+	bytecode := common.FromHex(hex)
+	if len(bytecode) == 0 {
+		return nil, fmt.Errorf("%s holds no bytecode", path)
+	}
+	return bytecode, nil
+}
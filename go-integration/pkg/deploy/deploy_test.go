@@ -0,0 +1,195 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+)
+
+var (
+	feedAddress      = common.HexToAddress("0x00000000000000000000000000000000000fee0d")
+	badDecimalsFeed  = common.HexToAddress("0x00000000000000000000000000000000000fee0e")
+	noCodeFeed       = common.HexToAddress("0x00000000000000000000000000000000000fee0f")
+	bytecodeFilePath = filepath.Join("..", "..", "contracts", "testdata", "EthJobEscrow.bin")
+)
+
+// mockFeed answers decimals() with decimals and every other call with
+// latestRoundData-shaped output: roundId 1, answer 3000e8, startedAt 1,
+// updatedAt 1, answeredInRound 1
+func mockFeed(decimals string) []byte {
+	return common.FromHex(
+		"600035" + "60e01c" + "63313ce567" + "14" + "603157" + // jump to 0x31 for decimals()
+			"600160005264" + "45d964b800" + "602052" + // roundId, answer
+			"6001604052" + "6001606052" + "6001608052" + // startedAt, updatedAt, answeredInRound
+			"60a06000f3" + // return 160 bytes
+			"5b" + "60" + decimals + "600052" + "60206000f3") // return decimals
+}
+
+func newBackend(t *testing.T) (*simulated.Backend, *bind.TransactOpts) {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	deployer := crypto.PubkeyToAddress(key.PublicKey)
+
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		deployer:        {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))},
+		feedAddress:     {Code: mockFeed("08")},
+		badDecimalsFeed: {Code: mockFeed("12")},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	chainID, err := backend.Client().ChainID(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get chain ID: %v", err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		t.Fatalf("Failed to create transactor: %v", err)
+	}
+	return backend, auth
+}
+
+// mineUntilDone commits blocks while a deployment waits to be mined. stop
+// returns once the last commit has finished, so the backend can be closed.
+func mineUntilDone(backend *simulated.Backend) (stop func()) {
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				backend.Commit()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+func TestValidate(t *testing.T) {
+	backend, auth := newBackend(t)
+	ctx := context.Background()
+	owner := auth.From
+
+	tests := []struct {
+		name   string
+		params Params
+		want   string
+	}{
+		{"valid", Params{PriceFeed: feedAddress, Owner: owner, FeeBPS: 500}, ""},
+		{"zero owner", Params{PriceFeed: feedAddress, FeeBPS: 500}, "zero address"},
+		{"fee over 100%", Params{PriceFeed: feedAddress, Owner: owner, FeeBPS: 10100}, "whole percent"},
+		{"fractional fee", Params{PriceFeed: feedAddress, Owner: owner, FeeBPS: 250}, "whole percent"},
+		{"feed without code", Params{PriceFeed: noCodeFeed, Owner: owner, FeeBPS: 500}, "no contract code"},
+		{"feed decimals", Params{PriceFeed: badDecimalsFeed, Owner: owner, FeeBPS: 500}, "18 decimals"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate(ctx, backend.Client())
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	backend, auth := newBackend(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	bytecode, err := ReadBytecode(bytecodeFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := common.HexToAddress("0x000000000000000000000000000000000000beef")
+
+	stop := mineUntilDone(backend)
+	deployment, err := Deploy(ctx, backend.Client(), auth, bytecode, Params{PriceFeed: feedAddress, Owner: owner, FeeBPS: 500})
+	stop()
+	if err != nil {
+		t.Fatalf("Deploy() = %v", err)
+	}
+
+	if deployment.Owner != owner.Hex() || deployment.Deployer != auth.From.Hex() {
+		t.Errorf("owner %s, deployer %s; want %s and %s", deployment.Owner, deployment.Deployer, owner.Hex(), auth.From.Hex())
+	}
+	if deployment.FeeBPS != 500 || deployment.ETHUSDPriceFeed != feedAddress.Hex() || deployment.DeployBlock == 0 {
+		t.Errorf("deployment = %+v", deployment)
+	}
+	code, err := backend.Client().CodeAt(ctx, common.HexToAddress(deployment.ContractAddress), nil)
+	if err != nil || crypto.Keccak256Hash(code).Hex() != deployment.CodeHash {
+		t.Errorf("code hash %s does not match the deployed code (%v)", deployment.CodeHash, err)
+	}
+
+	// The manifest round-trips through the file config.Load reads
+	path := filepath.Join(t.TempDir(), "deployment.json")
+	if err := deployment.Write(path); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	read, err := config.ReadDeployment(path)
+	if err != nil {
+		t.Fatalf("ReadDeployment() = %v", err)
+	}
+	if *read != *deployment {
+		t.Errorf("read back %+v, wrote %+v", read, deployment)
+	}
+
+	// The contract's fee is compiled in, so asking for another is caught
+	stop = mineUntilDone(backend)
+	deployment, err = Deploy(ctx, backend.Client(), auth, bytecode, Params{PriceFeed: feedAddress, Owner: owner, FeeBPS: 300})
+	stop()
+	if !errors.Is(err, ErrFeeMismatch) || deployment == nil || deployment.FeeBPS != 500 {
+		t.Errorf("Deploy() with 300 bps = %+v, %v; want the deployment and ErrFeeMismatch", deployment, err)
+	}
+}
+
+func TestReadBytecode(t *testing.T) {
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "EthJobEscrow.json")
+	if err := os.WriteFile(artifact, []byte(`{"abi":[],"bytecode":{"object":"0x6080604052"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	raw := filepath.Join(dir, "EthJobEscrow.bin")
+	if err := os.WriteFile(raw, []byte("6080604052\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{artifact, raw} {
+		bytecode, err := ReadBytecode(path)
+		if err != nil {
+			t.Fatalf("ReadBytecode(%s) = %v", path, err)
+		}
+		if common.Bytes2Hex(bytecode) != "6080604052" {
+			t.Errorf("ReadBytecode(%s) = %x", path, bytecode)
+		}
+	}
+}
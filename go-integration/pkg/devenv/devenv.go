@@ -0,0 +1,215 @@
+// Package devenv provisions a local development stack for the gateway: a
+// Postgres and an anvil node run by docker compose, a stub Chainlink feed
+// installed on the node, and the platform tables the gateway reads, seeded
+// with sample applications.
+//
+// Everything here is for a throwaway environment. The keys are anvil's
+// well-known development accounts and must never hold real funds.
+package devenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+)
+
+// ChainID is anvil's default chain ID, registered in config.Networks as "local"
+const ChainID = 31337
+
+// DeployerKey is anvil's first development account, funded with 10000 ETH
+// at genesis. It deploys the contract and signs for the gateway, so it is
+// every sample job's client.
+const DeployerKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+// Accounts of the sample users: the client is DeployerKey's account and the
+// freelancer anvil's second account
+var (
+	ClientAddress     = common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	FreelancerAddress = common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+)
+
+// FeedAddress is where InstallFeed puts the stub ETH/USD feed
+var FeedAddress = common.HexToAddress("0x00000000000000000000000000000000000fee0d")
+
+// FeedCode is a stub Chainlink aggregator. decimals() answers 8 and every
+// other call latestRoundData-shaped output: roundId 1, answer 3000e8 (ETH at
+// $3000), startedAt and updatedAt the current block's timestamp so the feed
+// never looks stale, and answeredInRound 1.
+var FeedCode = common.FromHex(
+	"600035" + "60e01c" + "63313ce567" + "14" + "602f57" + // jump to 0x2f for decimals()
+		"600160005264" + "45d964b800" + "602052" + // roundId, answer
+		"42604052" + "42606052" + "6001608052" + // startedAt, updatedAt, answeredInRound
+		"60a06000f3" + // return 160 bytes
+		"5b" + "6008600052" + "60206000f3") // return 8
+
+// Options configures the generated compose project
+type Options struct {
+	Project       string // docker compose project name
+	PostgresPort  int    // host port of Postgres
+	AnvilPort     int    // host port of the anvil node's RPC
+	PostgresImage string
+	AnvilImage    string
+	BlockTime     int // seconds between anvil blocks; 0 mines each transaction at once
+}
+
+// DefaultOptions keep off the ports a Postgres or node already running on
+// the host would take
+var DefaultOptions = Options{
+	Project:       "pgw-dev",
+	PostgresPort:  55432,
+	AnvilPort:     58545,
+	PostgresImage: "postgres:16-alpine",
+	AnvilImage:    "ghcr.io/foundry-rs/foundry:latest",
+}
+
+// Database credentials of the generated Postgres
+const (
+	dbUser     = "gateway"
+	dbPassword = "gateway"
+	dbName     = "gateway"
+)
+
+// DatabaseURL is the generated Postgres as seen from the host
+func (o Options) DatabaseURL() string {
+	return fmt.Sprintf("postgres://%s:%s@localhost:%d/%s?sslmode=disable", dbUser, dbPassword, o.PostgresPort, dbName)
+}
+
+// RPCURL is the anvil node as seen from the host
+func (o Options) RPCURL() string {
+	return fmt.Sprintf("http://localhost:%d", o.AnvilPort)
+}
+
+// GatewayEnv is the environment that points the gateway at the stack and
+// the deployment manifest written by deploying to it. The contract settings
+// the manifest carries are cleared, so ones left in the shell for another
+// network don't conflict with it.
+func (o Options) GatewayEnv(manifest string, serverPort int) []string {
+	return []string{
+		"NETWORK_ID=",
+		"CONTRACT_ADDRESS=",
+		"CONTRACT_DEPLOY_BLOCK=",
+		"ETH_USD_PRICE_FEED=",
+		"FEE_PERCENTAGE=",
+		"ETHEREUM_RPC_URL=" + o.RPCURL(),
+		"PRIVATE_KEY=" + DeployerKey,
+		"DEPLOYMENT_MANIFEST=" + manifest,
+		"DB_HOST=localhost",
+		fmt.Sprintf("DB_PORT=%d", o.PostgresPort),
+		"DB_USER=" + dbUser,
+		"DB_PASSWORD=" + dbPassword,
+		"DB_NAME=" + dbName,
+		fmt.Sprintf("SERVER_PORT=%d", serverPort),
+	}
+}
+
+// Compose renders the docker compose file of the stack. Nothing is kept in
+// volumes: 'docker compose down' leaves no state behind.
+func (o Options) Compose() []byte {
+	anvil := []string{"anvil", "--host", "0.0.0.0", "--chain-id", fmt.Sprint(ChainID)}
+	if o.BlockTime > 0 {
+		anvil = append(anvil, "--block-time", fmt.Sprint(o.BlockTime))
+	}
+	quoted := make([]string, len(anvil))
+	for i, arg := range anvil {
+		quoted[i] = fmt.Sprintf("%q", arg)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by pgwctl dev up; changes are overwritten on the next run\n")
+	fmt.Fprintf(&b, "name: %s\n\nservices:\n", o.Project)
+	fmt.Fprintf(&b, "  postgres:\n")
+	fmt.Fprintf(&b, "    image: %s\n", o.PostgresImage)
+	fmt.Fprintf(&b, "    environment:\n")
+	fmt.Fprintf(&b, "      POSTGRES_USER: %s\n      POSTGRES_PASSWORD: %s\n      POSTGRES_DB: %s\n", dbUser, dbPassword, dbName)
+	fmt.Fprintf(&b, "    ports:\n      - \"%d:5432\"\n", o.PostgresPort)
+	fmt.Fprintf(&b, "    healthcheck:\n")
+	fmt.Fprintf(&b, "      test: [\"CMD-SHELL\", \"pg_isready -U %s -d %s\"]\n", dbUser, dbName)
+	fmt.Fprintf(&b, "      interval: 1s\n      retries: 30\n")
+	fmt.Fprintf(&b, "  anvil:\n")
+	fmt.Fprintf(&b, "    image: %s\n", o.AnvilImage)
+	fmt.Fprintf(&b, "    entrypoint: [%s]\n", strings.Join(quoted, ", "))
+	fmt.Fprintf(&b, "    ports:\n      - \"%d:8545\"\n", o.AnvilPort)
+	return []byte(b.String())
+}
+
+// WaitForChain dials the node until it answers with anvil's chain ID
+func WaitForChain(ctx context.Context, rpcURL string) (*ethclient.Client, error) {
+	for {
+		client, err := ethclient.DialContext(ctx, rpcURL)
+		if err == nil {
+			chainID, err := client.ChainID(ctx)
+			if err == nil && chainID.Int64() == ChainID {
+				return client, nil
+			}
+			if err == nil {
+				client.Close()
+				return nil, fmt.Errorf("node at %s is chain %s, not anvil's %d", rpcURL, chainID, ChainID)
+			}
+			client.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("node at %s did not come up: %w", rpcURL, ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// WaitForDatabase connects to Postgres until it accepts the connection
+func WaitForDatabase(ctx context.Context, databaseURL string) (*pgx.Conn, error) {
+	for {
+		conn, err := pgx.Connect(ctx, databaseURL)
+		if err == nil {
+			if err = conn.Ping(ctx); err == nil {
+				return conn, nil
+			}
+			conn.Close(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("database did not come up: %w", ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// InstallFeed puts FeedCode at FeedAddress with anvil_setCode
+func InstallFeed(ctx context.Context, client *ethclient.Client) error {
+	if err := client.Client().CallContext(ctx, nil, "anvil_setCode", FeedAddress, hexutil.Bytes(FeedCode)); err != nil {
+		return fmt.Errorf("failed to install the price feed: %w", err)
+	}
+	return nil
+}
+
+// ExistingDeployment returns the deployment in manifest if its contract is
+// still on the node with the code it was deployed with, or nil when there is
+// no manifest or the node has restarted without it
+func ExistingDeployment(ctx context.Context, client bind.ContractCaller, manifest string) (*config.Deployment, error) {
+	if _, err := os.Stat(manifest); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	deployment, err := config.ReadDeployment(manifest)
+	if err != nil {
+		return nil, err
+	}
+	code, err := client.CodeAt(ctx, common.HexToAddress(deployment.ContractAddress), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract code: %w", err)
+	}
+	if len(code) == 0 || crypto.Keccak256Hash(code).Hex() != deployment.CodeHash {
+		return nil, nil
+	}
+	return deployment, nil
+}
@@ -0,0 +1,116 @@
+package devenv
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
+)
+
+func TestFeedCode(t *testing.T) {
+	backend := simulated.NewBackend(types.GenesisAlloc{FeedAddress: {Code: FeedCode}})
+	defer backend.Close()
+	backend.Commit()
+
+	feed, err := oracle.NewFeed(FeedAddress, backend.Client())
+	if err != nil {
+		t.Fatalf("NewFeed = %v", err)
+	}
+	ctx := context.Background()
+	if decimals, err := feed.Decimals(ctx); err != nil || decimals != 8 {
+		t.Fatalf("decimals = %d, %v; want 8", decimals, err)
+	}
+	round, err := feed.LatestRound(ctx)
+	if err != nil {
+		t.Fatalf("LatestRound = %v", err)
+	}
+	if round.Answer.Cmp(big.NewInt(3000e8)) != 0 {
+		t.Errorf("answer = %s, want 3000e8", round.Answer)
+	}
+	if age := time.Since(round.UpdatedAt); age < -time.Minute || age > time.Minute {
+		t.Errorf("updatedAt = %s, want the current block's time", round.UpdatedAt)
+	}
+}
+
+func TestDevelopmentAccounts(t *testing.T) {
+	key, err := crypto.HexToECDSA(DeployerKey)
+	if err != nil {
+		t.Fatalf("DeployerKey = %v", err)
+	}
+	if got := crypto.PubkeyToAddress(key.PublicKey); got != ClientAddress {
+		t.Errorf("DeployerKey is %s's, not ClientAddress %s", got.Hex(), ClientAddress.Hex())
+	}
+	if ClientAddress == FreelancerAddress {
+		t.Error("The sample client and freelancer share an account")
+	}
+}
+
+func TestCompose(t *testing.T) {
+	opts := DefaultOptions
+	opts.BlockTime = 2
+	compose := string(opts.Compose())
+	for _, want := range []string{
+		"name: pgw-dev",
+		"image: postgres:16-alpine",
+		`"55432:5432"`,
+		`"58545:8545"`,
+		`entrypoint: ["anvil", "--host", "0.0.0.0", "--chain-id", "31337", "--block-time", "2"]`,
+	} {
+		if !strings.Contains(compose, want) {
+			t.Errorf("compose file lacks %s:\n%s", want, compose)
+		}
+	}
+
+	env := strings.Join(opts.GatewayEnv("/tmp/deployment.json", 9090), "\n")
+	for _, want := range []string{"DB_PORT=55432", "ETHEREUM_RPC_URL=http://localhost:58545", "DEPLOYMENT_MANIFEST=/tmp/deployment.json", "SERVER_PORT=9090", "CONTRACT_ADDRESS=\n"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("gateway environment lacks %q:\n%s", want, env)
+		}
+	}
+}
+
+func TestExistingDeployment(t *testing.T) {
+	backend := simulated.NewBackend(types.GenesisAlloc{FeedAddress: {Code: FeedCode}})
+	defer backend.Close()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if deployment, err := ExistingDeployment(ctx, backend.Client(), filepath.Join(dir, "missing.json")); deployment != nil || err != nil {
+		t.Errorf("missing manifest = %v, %v; want nil", deployment, err)
+	}
+
+	manifest := &config.Deployment{
+		NetworkID:       ChainID,
+		ContractAddress: FeedAddress.Hex(),
+		DeployBlock:     1,
+		Owner:           ClientAddress.Hex(),
+		ETHUSDPriceFeed: FeedAddress.Hex(),
+		FeeBPS:          500,
+		CodeHash:        crypto.Keccak256Hash(FeedCode).Hex(),
+	}
+	path := filepath.Join(dir, "deployment.json")
+	if err := manifest.Write(path); err != nil {
+		t.Fatalf("Write = %v", err)
+	}
+	if deployment, err := ExistingDeployment(ctx, backend.Client(), path); err != nil || deployment == nil || deployment.ContractAddress != FeedAddress.Hex() {
+		t.Errorf("deployed manifest = %v, %v; want it reused", deployment, err)
+	}
+
+	// A restarted node has lost the contract
+	manifest.ContractAddress = FreelancerAddress.Hex()
+	if err := manifest.Write(path); err != nil {
+		t.Fatalf("Write = %v", err)
+	}
+	if deployment, err := ExistingDeployment(ctx, backend.Client(), path); deployment != nil || err != nil {
+		t.Errorf("manifest without code = %v, %v; want nil", deployment, err)
+	}
+}
@@ -0,0 +1,111 @@
+package devenv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PlatformSchema is the least of the platform's users, jobs and applications
+// tables the gateway reads and writes. In production they belong to the
+// platform; the gateway creates only its own tables when it starts.
+var PlatformSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		wallet_address VARCHAR(42),
+		kyc_status VARCHAR(20)
+	)`,
+	`CREATE TABLE IF NOT EXISTS jobs (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		title VARCHAR(200) NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS applications (
+		id SERIAL PRIMARY KEY,
+		job_id INTEGER NOT NULL REFERENCES jobs(id),
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		status VARCHAR(50) NOT NULL DEFAULT 'pending',
+		agreed_usd_amount INTEGER,
+		payment_status VARCHAR(50),
+		escrow_job_id INTEGER,
+		escrow_tx_hash_deposit VARCHAR(66),
+		escrow_tx_hash_release VARCHAR(66),
+		escrow_tx_hash_refund VARCHAR(66)
+	)`,
+}
+
+// SampleApplication is one seeded application, in pending_deposit with the
+// sample freelancer on a job posted by the sample client
+type SampleApplication struct {
+	ID        int32
+	Title     string
+	USDAmount int32
+	Status    string // applications.status; "approved" is releasable in a batch
+}
+
+// SampleApplications are what Seed writes: a small, an approved and a large
+// job, the last above the default KYC_THRESHOLD_USD
+var SampleApplications = []SampleApplication{
+	{ID: 1, Title: "Logo design", USDAmount: 250, Status: "accepted"},
+	{ID: 2, Title: "Landing page", USDAmount: 800, Status: "approved"},
+	{ID: 3, Title: "Mobile app", USDAmount: 4500, Status: "accepted"},
+}
+
+// Sample users
+const (
+	ClientUserID     = 1
+	FreelancerUserID = 2
+)
+
+// Seed creates the platform tables and the sample users, jobs and
+// applications. Rows that already exist are left alone, so running it again
+// keeps any payment progress.
+func Seed(ctx context.Context, conn *pgx.Conn) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, statement := range PlatformSchema {
+		if _, err := tx.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("error creating platform tables: %w", err)
+		}
+	}
+
+	users := `
+		INSERT INTO users (id, name, wallet_address, kyc_status)
+		VALUES ($1, 'Sample Client', $2, 'verified'), ($3, 'Sample Freelancer', $4, 'verified')
+		ON CONFLICT (id) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, users, ClientUserID, ClientAddress.Hex(), FreelancerUserID, FreelancerAddress.Hex()); err != nil {
+		return fmt.Errorf("error seeding users: %w", err)
+	}
+	for _, app := range SampleApplications {
+		if _, err := tx.Exec(ctx, `INSERT INTO jobs (id, user_id, title) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING`, app.ID, ClientUserID, app.Title); err != nil {
+			return fmt.Errorf("error seeding job %d: %w", app.ID, err)
+		}
+		query := `
+			INSERT INTO applications (id, job_id, user_id, status, agreed_usd_amount, payment_status)
+			VALUES ($1, $1, $2, $3, $4, 'pending_deposit')
+			ON CONFLICT (id) DO NOTHING
+		`
+		if _, err := tx.Exec(ctx, query, app.ID, FreelancerUserID, app.Status, app.USDAmount); err != nil {
+			return fmt.Errorf("error seeding application %d: %w", app.ID, err)
+		}
+	}
+
+	// Rows inserted by ID leave the sequences behind them
+	for _, table := range []string{"users", "jobs", "applications"} {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), (SELECT MAX(id) FROM %s))`, table, table)); err != nil {
+			return fmt.Errorf("error advancing %s sequence: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing seed data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Schema describes an event type for consumers building an integration
+type Schema struct {
+	Type        Type        `json:"type"`
+	Version     int         `json:"version"`
+	Description string      `json:"description"`
+	Payload     string      `json:"payload"` // the Go payload type, e.g. Operation
+	Fields      []Field     `json:"fields"`
+	Example     interface{} `json:"example"`
+}
+
+// Field is one JSON field of a payload
+type Field struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`     // string, integer, number, boolean or timestamp
+	Optional bool   `json:"optional"` // omitted when empty
+}
+
+// descriptions says when each event type is published
+var descriptions = map[Type]string{
+	OperationDeferred:  "A chain operation was queued until gas prices drop or a transient failure clears",
+	OperationSubmitted: "A queued operation was submitted on-chain",
+	OperationExpired:   "A queued operation was not submitted before its deadline",
+	OperationFailed:    "A queued operation failed and will not be retried",
+	OperationPaused:    "A queued escrow waits for the client to confirm a moved ETH/USD rate",
+
+	TransactionConfirmed: "An escrow transaction was mined successfully",
+	TransactionFailed:    "An escrow transaction reverted",
+
+	RetainerPeriodDue:         "A retainer period's escrow is due from the client",
+	RetainerPeriodFunded:      "A retainer period's escrow was funded",
+	RetainerPeriodUnderfunded: "A retainer period's escrow was funded below its quote",
+	RetainerPeriodFailed:      "A retainer period's escrow could not be funded",
+	RetainerEnded:             "A retainer reached its end date after its last period",
+
+	ContractImplementationChanged: "The implementation behind the escrow contract's proxy changed",
+	ContractUpdated:               "The gateway moved onto a redeployed escrow contract",
+
+	PriceFeedStalled:   "The ETH/USD feed missed its heartbeat",
+	PriceFeedRecovered: "The ETH/USD feed is updating again",
+
+	ReleaseAuthorizationLapsed: "A client's approval expired before its release was confirmed, so the work must be approved again",
+
+	SettlementSummarized: "A UTC day's deposits, releases, refunds, fees and gas were totalled for reconciliation",
+
+	WalletAnomalyDetected: "A signer wallet sent transactions the gateway did not sign, so its key may be compromised",
+
+	FundingLinkFunded:  "A client funded a job's escrow through a funding link, before or after it expired",
+	FundingLinkExpired: "A funding link expired before the client funded the escrow",
+
+	KYCPending:  "A release over KYC_THRESHOLD_USD was held because the freelancer has not passed KYC",
+	KYCRejected: "A held release's freelancer failed KYC; the release stays held until they pass",
+	KYCCleared:  "A held release's freelancer passed KYC and the release was submitted",
+
+	ApplicationStatusChanged: "An application's status changed on the platform and an AUTOMATION_RULES rule asked to be notified",
+}
+
+var sampleTime = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+var sampleDeviation = 1.25
+
+var sampleFundedAt = sampleTime.Add(-3 * time.Hour)
+
+var sampleClearedAt = sampleTime.Add(26 * time.Hour)
+
+// samples holds one fully populated payload per event type. Its golden file
+// pins the wire format consumers depend on, and test deliveries send it.
+var samples = map[Type]interface{}{
+	OperationDeferred:         Operation{OperationID: 1, ApplicationID: 42, Operation: "post_job", Status: "deferred", Deadline: sampleTime.Add(6 * time.Hour), Attempts: 0, Error: "gas price too high"},
+	OperationSubmitted:        Operation{OperationID: 1, ApplicationID: 42, Operation: "post_job", Status: "submitted", Deadline: sampleTime.Add(6 * time.Hour), Attempts: 1, TxHash: "0xabc", TxURL: "https://sepolia.etherscan.io/tx/0xabc"},
+	OperationExpired:          Operation{OperationID: 1, ApplicationID: 42, Operation: "post_job", Status: "expired", Deadline: sampleTime, Attempts: 3, Error: "operation could not be submitted before its deadline"},
+	OperationFailed:           Operation{OperationID: 1, ApplicationID: 42, Operation: "cancel_job", Status: "failed", Deadline: sampleTime, Attempts: 5, Error: "reverted"},
+	OperationPaused:           Operation{OperationID: 1, ApplicationID: 42, Operation: "post_job", Status: "paused", Deadline: sampleTime.Add(6 * time.Hour), Attempts: 0, Error: "ETH/USD rate 330000000000 is 10.00% from the quoted 300000000000, more than the 2.00% allowed"},
+	TransactionConfirmed:      Transaction{ApplicationID: 42, TxHash: "0xabc", TxURL: "https://sepolia.etherscan.io/tx/0xabc", BlockNumber: 100, Status: paymentstatus.Deposited, FreelancerDisplay: &DisplayAmount{USDAmount: 250, Currency: "PKR", Amount: "69612.50", Display: "PKR 69,612.50", RatePerUSD: "278.45", RateAsOf: sampleTime}},
+	TransactionFailed:         Transaction{ApplicationID: 42, TxHash: "0xabc", TxURL: "https://sepolia.etherscan.io/tx/0xabc", BlockNumber: 100, Status: paymentstatus.DepositFailed},
+	RetainerPeriodDue:         RetainerPeriod{RetainerID: 3, PeriodNumber: 2, EscrowJobID: 1099511627781, USDAmount: 500, Status: "awaiting_client", PeriodStart: sampleTime, RequiredWei: "1666666666"},
+	RetainerPeriodFunded:      RetainerPeriod{RetainerID: 3, PeriodNumber: 2, EscrowJobID: 1099511627781, USDAmount: 500, Status: "funded", PeriodStart: sampleTime, TxHashDeposit: "0xdef", TxURLDeposit: "https://sepolia.etherscan.io/tx/0xdef", RequiredWei: "1666666666", DepositedWei: "1666667000", OverfundedWei: "334"},
+	RetainerPeriodUnderfunded: RetainerPeriod{RetainerID: 3, PeriodNumber: 2, EscrowJobID: 1099511627781, USDAmount: 500, Status: "underfunded", PeriodStart: sampleTime, TxHashDeposit: "0xdef", TxURLDeposit: "https://sepolia.etherscan.io/tx/0xdef", RequiredWei: "1666666666", DepositedWei: "1600000000", TopUpWei: "66666666"},
+	RetainerPeriodFailed:      RetainerPeriod{RetainerID: 3, PeriodNumber: 2, EscrowJobID: 1099511627781, USDAmount: 500, Status: "failed", PeriodStart: sampleTime, Error: "insufficient funds"},
+	RetainerEnded:             Retainer{RetainerID: 3, ApplicationID: 42, USDAmount: 500, Interval: "week", Mode: "custodial", Status: "ended", StartAt: sampleTime, EndAt: sampleTime.AddDate(0, 3, 0), PeriodsCreated: 13},
+
+	ContractImplementationChanged: ContractImplementation{ContractAddress: "0x1111111111111111111111111111111111111111", Implementation: "0x3333333333333333333333333333333333333333", PreviousImplementation: "0x2222222222222222222222222222222222222222", Expected: false},
+	ContractUpdated:               ContractUpdate{ContractAddress: "0x5555555555555555555555555555555555555555", PreviousAddress: "0x1111111111111111111111111111111111111111", IssuedAt: sampleTime.Add(-5 * time.Minute), Actor: "ops@example.com", Reason: "patched cancelJob reentrancy"},
+
+	PriceFeedStalled:   PriceFeed{FeedAddress: "0x694AA1769357215DE4FAC081bf1f309aDC325306", FallbackAddress: "0x4444444444444444444444444444444444444444", Source: "fallback", RoundID: "18446744073709556000", UpdatedAt: sampleTime.Add(-2 * time.Hour), StalenessSeconds: 7200, HeartbeatSeconds: 3600, DeviationPercent: &sampleDeviation, Reason: "no round for 2h0m0s, heartbeat is 1h0m0s"},
+	PriceFeedRecovered: PriceFeed{FeedAddress: "0x694AA1769357215DE4FAC081bf1f309aDC325306", Source: "primary", RoundID: "18446744073709556001", UpdatedAt: sampleTime, StalenessSeconds: 0, HeartbeatSeconds: 3600},
+
+	ReleaseAuthorizationLapsed: ReleaseAuthorization{AuthorizationID: 9, ApplicationID: 42, ApprovedBy: "client@example.com", ApprovedAt: sampleTime.Add(-7 * 24 * time.Hour), ExpiresAt: sampleTime, Status: "lapsed", TxHash: "0xabc", TxURL: "https://sepolia.etherscan.io/tx/0xabc"},
+
+	SettlementSummarized: Settlement{Day: "2025-05-31", DepositCount: 3, DepositUSD: 1500, ReleaseCount: 2, ReleaseUSD: 900, RefundCount: 1, RefundUSD: 100, FeeWei: "450000000", ReserveWei: "45000000", ReservePaidWei: "0", GasTransactions: 6, GasWei: "1260000000000000", GasUSD: "4.410000", NetEscrowUSD: 500, NetTreasuryWei: "-1259999550000000"},
+
+	WalletAnomalyDetected: WalletAnomaly{Address: "0x00000000000000000000000000000000000000a0", AddressURL: "https://sepolia.etherscan.io/address/0x00000000000000000000000000000000000000a0", Signer: "hot", FirstNonce: 41, UnexpectedCount: 2, OnChainNonce: 43, KillSwitchTripped: true, DetectedAt: sampleTime},
+
+	FundingLinkFunded:  FundingLink{LinkID: 4, ApplicationID: 42, USDAmount: 250, ValueWei: "833333333", ExpiresAt: sampleTime, Status: "funded", DepositedWei: "833333333", TxHash: "0xdef", TxURL: "https://sepolia.etherscan.io/tx/0xdef", FundedAt: &sampleFundedAt},
+	FundingLinkExpired: FundingLink{LinkID: 4, ApplicationID: 42, USDAmount: 250, ValueWei: "833333333", ExpiresAt: sampleTime, Status: "expired"},
+	KYCPending:         KYCHold{HoldID: 6, ApplicationID: 42, FreelancerUserID: 17, USDAmount: 5000, KYCStatus: "pending", Status: "open", HeldAt: sampleTime},
+	KYCRejected:        KYCHold{HoldID: 6, ApplicationID: 42, FreelancerUserID: 17, USDAmount: 5000, KYCStatus: "rejected", Status: "open", HeldAt: sampleTime},
+	KYCCleared:         KYCHold{HoldID: 6, ApplicationID: 42, FreelancerUserID: 17, USDAmount: 5000, KYCStatus: "verified", Status: "cleared", HeldAt: sampleTime, ResolvedAt: &sampleClearedAt},
+
+	ApplicationStatusChanged: ApplicationStatus{ApplicationID: 42, Tenant: "acme", PreviousStatus: "in_progress", Status: "cancelled", PaymentStatus: "deposited", Actions: []string{"refund", "notify"}},
+}
+
+// Sample returns a fully populated example payload of an event type
+func Sample(t Type) (interface{}, bool) {
+	payload, ok := samples[t]
+	return payload, ok
+}
+
+// Catalog describes every event type the gateway publishes, sorted by type
+func Catalog() []Schema {
+	catalog := make([]Schema, 0, len(payloadTypes))
+	for t, payloadType := range payloadTypes {
+		catalog = append(catalog, Schema{
+			Type:        t,
+			Version:     Version,
+			Description: descriptions[t],
+			Payload:     payloadType.Name(),
+			Fields:      fields(payloadType),
+			Example:     samples[t],
+		})
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Type < catalog[j].Type })
+	return catalog
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fields lists the JSON fields of a payload struct in declaration order
+func fields(payloadType reflect.Type) []Field {
+	var fields []Field
+	for i := 0; i < payloadType.NumField(); i++ {
+		field := payloadType.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		fields = append(fields, Field{
+			Name:     name,
+			Type:     jsonType(fieldType),
+			Optional: strings.Contains(options, "omitempty"),
+		})
+	}
+	return fields
+}
+
+func jsonType(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "timestamp"
+	case t.Kind() == reflect.String:
+		return "string"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "number"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "integer"
+	default:
+		return "object"
+	}
+}
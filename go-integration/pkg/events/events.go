@@ -0,0 +1,181 @@
+// Package events defines the versioned payloads the gateway publishes to
+// webhooks and any other outbound sink, decoupled from the structs the HTTP
+// API and database use internally.
+//
+// Compatibility: within a schema version, fields are never removed, renamed or
+// retyped and event types are never repurposed. New optional fields may be
+// added at any time, so consumers must ignore fields they do not recognise.
+// A breaking change introduces a new version and both versions are published
+// side by side until consumers have migrated. The golden files in testdata pin
+// the wire format of every payload.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Version is the schema version of every payload in this package
+const Version = 1
+
+// Type identifies what happened; consumers dispatch on it
+type Type string
+
+// Event types
+const (
+	OperationDeferred  Type = "operation.deferred"  // Operation
+	OperationSubmitted Type = "operation.submitted" // Operation
+	OperationExpired   Type = "operation.expired"   // Operation
+	OperationFailed    Type = "operation.failed"    // Operation
+	OperationPaused    Type = "operation.paused"    // Operation
+
+	TransactionConfirmed Type = "transaction.confirmed" // Transaction
+	TransactionFailed    Type = "transaction.failed"    // Transaction
+
+	RetainerPeriodDue         Type = "retainer.period_due"         // RetainerPeriod
+	RetainerPeriodFunded      Type = "retainer.period_funded"      // RetainerPeriod
+	RetainerPeriodUnderfunded Type = "retainer.period_underfunded" // RetainerPeriod
+	RetainerPeriodFailed      Type = "retainer.period_failed"      // RetainerPeriod
+	RetainerEnded             Type = "retainer.ended"              // Retainer
+
+	ContractImplementationChanged Type = "contract.implementation_changed" // ContractImplementation
+	ContractUpdated               Type = "contract.updated"                // ContractUpdate
+
+	PriceFeedStalled   Type = "price_feed.stalled"   // PriceFeed
+	PriceFeedRecovered Type = "price_feed.recovered" // PriceFeed
+
+	ReleaseAuthorizationLapsed Type = "release_authorization.lapsed" // ReleaseAuthorization
+
+	SettlementSummarized Type = "settlement.summarized" // Settlement
+
+	WalletAnomalyDetected Type = "wallet.anomaly_detected" // WalletAnomaly
+
+	FundingLinkFunded  Type = "funding_link.funded"  // FundingLink
+	FundingLinkExpired Type = "funding_link.expired" // FundingLink
+
+	KYCPending  Type = "kyc.pending"  // KYCHold
+	KYCRejected Type = "kyc.rejected" // KYCHold
+	KYCCleared  Type = "kyc.cleared"  // KYCHold
+
+	ApplicationStatusChanged Type = "application.status_changed" // ApplicationStatus
+)
+
+// payloadTypes maps each event type to the payload it carries
+var payloadTypes = map[Type]reflect.Type{
+	OperationDeferred:         reflect.TypeOf(Operation{}),
+	OperationSubmitted:        reflect.TypeOf(Operation{}),
+	OperationExpired:          reflect.TypeOf(Operation{}),
+	OperationFailed:           reflect.TypeOf(Operation{}),
+	OperationPaused:           reflect.TypeOf(Operation{}),
+	TransactionConfirmed:      reflect.TypeOf(Transaction{}),
+	TransactionFailed:         reflect.TypeOf(Transaction{}),
+	RetainerPeriodDue:         reflect.TypeOf(RetainerPeriod{}),
+	RetainerPeriodFunded:      reflect.TypeOf(RetainerPeriod{}),
+	RetainerPeriodUnderfunded: reflect.TypeOf(RetainerPeriod{}),
+	RetainerPeriodFailed:      reflect.TypeOf(RetainerPeriod{}),
+	RetainerEnded:             reflect.TypeOf(Retainer{}),
+
+	ContractImplementationChanged: reflect.TypeOf(ContractImplementation{}),
+	ContractUpdated:               reflect.TypeOf(ContractUpdate{}),
+
+	PriceFeedStalled:   reflect.TypeOf(PriceFeed{}),
+	PriceFeedRecovered: reflect.TypeOf(PriceFeed{}),
+
+	ReleaseAuthorizationLapsed: reflect.TypeOf(ReleaseAuthorization{}),
+
+	SettlementSummarized: reflect.TypeOf(Settlement{}),
+
+	WalletAnomalyDetected: reflect.TypeOf(WalletAnomaly{}),
+
+	FundingLinkFunded:  reflect.TypeOf(FundingLink{}),
+	FundingLinkExpired: reflect.TypeOf(FundingLink{}),
+
+	KYCPending:  reflect.TypeOf(KYCHold{}),
+	KYCRejected: reflect.TypeOf(KYCHold{}),
+	KYCCleared:  reflect.TypeOf(KYCHold{}),
+
+	ApplicationStatusChanged: reflect.TypeOf(ApplicationStatus{}),
+}
+
+// Types returns every event type the gateway publishes
+func Types() []Type {
+	types := make([]Type, 0, len(payloadTypes))
+	for t := range payloadTypes {
+		types = append(types, t)
+	}
+	return types
+}
+
+// Envelope wraps a payload with the metadata every consumer needs
+type Envelope struct {
+	ID         string          `json:"id"` // unique per event, for consumer-side deduplication
+	Type       Type            `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	TraceID    string          `json:"trace_id,omitempty"` // the X-Trace-ID of the API request that led to the event
+	Data       json.RawMessage `json:"data"`
+}
+
+// New wraps a payload in an envelope, checking it is the payload type registered for t
+func New(t Type, payload interface{}) (*Envelope, error) {
+	expected, ok := payloadTypes[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", t)
+	}
+	if got := reflect.TypeOf(payload); got != expected {
+		return nil, fmt.Errorf("event %s carries %s, got %v", t, expected.Name(), got)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", t, err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate event ID: %w", err)
+	}
+
+	return &Envelope{
+		ID:         hex.EncodeToString(id),
+		Type:       t,
+		Version:    Version,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}, nil
+}
+
+// Marshal encodes the envelope for delivery
+func (e *Envelope) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Unmarshal decodes a delivered envelope without decoding its payload
+func Unmarshal(body []byte) (*Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	return &e, nil
+}
+
+// Decode returns the typed payload, e.g. Operation for operation.* events
+func (e *Envelope) Decode() (interface{}, error) {
+	if e.Version != Version {
+		return nil, fmt.Errorf("unsupported event version %d", e.Version)
+	}
+	payloadType, ok := payloadTypes[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", e.Type)
+	}
+
+	payload := reflect.New(payloadType)
+	if err := json.Unmarshal(e.Data, payload.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s payload: %w", e.Type, err)
+	}
+	return payload.Elem().Interface(), nil
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+var occurredAt = sampleTime
+
+func TestGoldenPayloads(t *testing.T) {
+	for _, eventType := range Types() {
+		t.Run(string(eventType), func(t *testing.T) {
+			payload, ok := samples[eventType]
+			if !ok {
+				t.Fatalf("No sample payload for %s", eventType)
+			}
+
+			envelope, err := New(eventType, payload)
+			if err != nil {
+				t.Fatalf("Failed to create event: %v", err)
+			}
+			envelope.ID = "00000000000000000000000000000000"
+			envelope.OccurredAt = occurredAt
+
+			body, err := json.MarshalIndent(envelope, "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to marshal event: %v", err)
+			}
+
+			golden := filepath.Join("testdata", strings.ReplaceAll(string(eventType), ".", "_")+".v1.json")
+			if *update {
+				if err := os.WriteFile(golden, append(body, '\n'), 0o644); err != nil {
+					t.Fatalf("Failed to write golden file: %v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+			if !bytes.Equal(bytes.TrimSpace(expected), body) {
+				t.Errorf("Payload for %s no longer matches %s; this breaks consumers unless the schema version is bumped\n got: %s", eventType, golden, body)
+			}
+		})
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	for eventType, payload := range samples {
+		envelope, err := New(eventType, payload)
+		if err != nil {
+			t.Fatalf("Failed to create %s event: %v", eventType, err)
+		}
+
+		body, err := envelope.Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal %s event: %v", eventType, err)
+		}
+		decoded, err := Unmarshal(body)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal %s event: %v", eventType, err)
+		}
+
+		got, err := decoded.Decode()
+		if err != nil {
+			t.Fatalf("Failed to decode %s payload: %v", eventType, err)
+		}
+		if !reflect.DeepEqual(got, payload) {
+			t.Errorf("Expected %s payload %+v, got %+v", eventType, payload, got)
+		}
+	}
+}
+
+func TestNewRejectsMismatchedPayload(t *testing.T) {
+	if _, err := New(OperationDeferred, Transaction{}); err == nil {
+		t.Errorf("Expected error for wrong payload type")
+	}
+	if _, err := New("job.unknown", Operation{}); err == nil {
+		t.Errorf("Expected error for unknown event type")
+	}
+}
+
+func TestDecodeIgnoresUnknownFields(t *testing.T) {
+	body := []byte(`{"id":"1","type":"transaction.confirmed","version":1,"occurred_at":"2025-06-01T12:00:00Z","data":{"application_id":7,"tx_hash":"0x1","block_number":5,"status":"released","added_later":true}}`)
+
+	envelope, err := Unmarshal(body)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	payload, err := envelope.Decode()
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if tx := payload.(Transaction); tx.ApplicationID != 7 || tx.Status != "released" {
+		t.Errorf("Expected application 7 released, got %+v", tx)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	catalog := Catalog()
+	if len(catalog) != len(Types()) {
+		t.Fatalf("Expected every event type in the catalog, got %d of %d", len(catalog), len(Types()))
+	}
+	for i, schema := range catalog {
+		if schema.Description == "" || schema.Example == nil || len(schema.Fields) == 0 {
+			t.Errorf("Incomplete schema for %s: %+v", schema.Type, schema)
+		}
+		if i > 0 && catalog[i-1].Type >= schema.Type {
+			t.Errorf("Expected the catalog sorted by type, got %s before %s", catalog[i-1].Type, schema.Type)
+		}
+	}
+
+	for _, schema := range catalog {
+		if schema.Type != PriceFeedStalled {
+			continue
+		}
+		expected := map[string]Field{
+			"feed_address":      {Name: "feed_address", Type: "string"},
+			"updated_at":        {Name: "updated_at", Type: "timestamp"},
+			"staleness_seconds": {Name: "staleness_seconds", Type: "integer"},
+			"deviation_percent": {Name: "deviation_percent", Type: "number", Optional: true},
+		}
+		for _, field := range schema.Fields {
+			if want, ok := expected[field.Name]; ok && field != want {
+				t.Errorf("Expected field %+v, got %+v", want, field)
+			}
+		}
+	}
+
+	if _, ok := Sample("job.unknown"); ok {
+		t.Error("Expected no sample for an unknown event type")
+	}
+}
@@ -0,0 +1,195 @@
+package events
+
+import (
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Operation describes a chain operation queued until gas prices drop or a
+// transient RPC failure clears
+type Operation struct {
+	OperationID   int64     `json:"operation_id"`
+	ApplicationID int32     `json:"application_id"`
+	Operation     string    `json:"operation"` // post_job, complete_job or cancel_job
+	Status        string    `json:"status"`    // deferred, submitted, expired or failed
+	Deadline      time.Time `json:"deadline"`
+	Attempts      int       `json:"attempts"`
+	TxHash        string    `json:"tx_hash,omitempty"`
+	TxURL         string    `json:"tx_url,omitempty"` // block explorer page for TxHash
+	Error         string    `json:"error,omitempty"`
+}
+
+// Transaction describes an initiated escrow transaction whose outcome was found on-chain
+type Transaction struct {
+	ApplicationID int32                `json:"application_id"`
+	TxHash        string               `json:"tx_hash"`
+	TxURL         string               `json:"tx_url,omitempty"` // block explorer page for TxHash
+	BlockNumber   uint64               `json:"block_number"`
+	Status        paymentstatus.Status `json:"status"` // the application's new payment status
+
+	// The job's amount in the client's and freelancer's preferred currencies, when set
+	ClientDisplay     *DisplayAmount `json:"client_display,omitempty"`
+	FreelancerDisplay *DisplayAmount `json:"freelancer_display,omitempty"`
+}
+
+// DisplayAmount is a job's USD amount converted for display. It is never what
+// the escrow holds.
+type DisplayAmount struct {
+	USDAmount  int32     `json:"usd_amount"`
+	Currency   string    `json:"currency"` // ISO 4217, e.g. PKR
+	Amount     string    `json:"amount"`   // decimal, two places
+	Display    string    `json:"display"`  // e.g. "PKR 69,612.50"
+	RatePerUSD string    `json:"rate_per_usd"`
+	RateAsOf   time.Time `json:"rate_as_of"`
+}
+
+// RetainerPeriod describes one billing period of a retainer and its escrow job
+type RetainerPeriod struct {
+	RetainerID    int64     `json:"retainer_id"`
+	PeriodNumber  int       `json:"period_number"`
+	EscrowJobID   uint64    `json:"escrow_job_id"`
+	USDAmount     int32     `json:"usd_amount"`
+	Status        string    `json:"status"`
+	PeriodStart   time.Time `json:"period_start"`
+	TxHashDeposit string    `json:"tx_hash_deposit,omitempty"`
+	TxURLDeposit  string    `json:"tx_url_deposit,omitempty"` // block explorer page for TxHashDeposit
+	Error         string    `json:"error,omitempty"`
+
+	// Client-funded periods only, as decimal wei strings
+	RequiredWei   string `json:"required_wei,omitempty"`   // quoted amount the client must deposit
+	DepositedWei  string `json:"deposited_wei,omitempty"`  // amount actually sent
+	TopUpWei      string `json:"topup_wei,omitempty"`      // still owed when underfunded
+	OverfundedWei string `json:"overfunded_wei,omitempty"` // sent beyond the quote
+}
+
+// Retainer describes a recurring escrow schedule
+type Retainer struct {
+	RetainerID     int64     `json:"retainer_id"`
+	ApplicationID  int32     `json:"application_id"`
+	USDAmount      int32     `json:"usd_amount"`
+	Interval       string    `json:"interval"`
+	Mode           string    `json:"mode"`
+	Status         string    `json:"status"`
+	StartAt        time.Time `json:"start_at"`
+	EndAt          time.Time `json:"end_at"`
+	PeriodsCreated int       `json:"periods_created"`
+}
+
+// ContractImplementation describes the implementation behind the escrow
+// contract's EIP-1967 proxy
+type ContractImplementation struct {
+	ContractAddress        string `json:"contract_address"`
+	Implementation         string `json:"implementation"`
+	PreviousImplementation string `json:"previous_implementation,omitempty"`
+	Expected               bool   `json:"expected"` // matches EXPECTED_IMPLEMENTATION_ADDRESS
+}
+
+// ContractUpdate describes a signed move onto a redeployed escrow contract
+type ContractUpdate struct {
+	ContractAddress string    `json:"contract_address"`
+	PreviousAddress string    `json:"previous_address"`
+	IssuedAt        time.Time `json:"issued_at"`
+	Actor           string    `json:"actor"`
+	Reason          string    `json:"reason,omitempty"`
+}
+
+// PriceFeed describes the Chainlink ETH/USD feed's latest round when the
+// heartbeat monitor finds it stalled or recovered
+type PriceFeed struct {
+	FeedAddress      string    `json:"feed_address"`
+	FallbackAddress  string    `json:"fallback_address,omitempty"`
+	Source           string    `json:"source"` // "primary" or "fallback": where market prices are read from
+	RoundID          string    `json:"round_id"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	StalenessSeconds int64     `json:"staleness_seconds"`
+	HeartbeatSeconds int64     `json:"heartbeat_seconds"`
+	DeviationPercent *float64  `json:"deviation_percent,omitempty"` // from the fallback feed
+	Reason           string    `json:"reason,omitempty"`
+}
+
+// ReleaseAuthorization describes a client's approval to release a job's
+// escrow that expired before the release was confirmed, so the work must be
+// approved again
+type ReleaseAuthorization struct {
+	AuthorizationID int64     `json:"authorization_id"`
+	ApplicationID   int32     `json:"application_id"`
+	ApprovedBy      string    `json:"approved_by"`
+	ApprovedAt      time.Time `json:"approved_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	Status          string    `json:"status"`            // "lapsed"
+	TxHash          string    `json:"tx_hash,omitempty"` // the last release submitted under it, which failed
+	TxURL           string    `json:"tx_url,omitempty"`  // block explorer page for TxHash
+}
+
+// Settlement totals the money that moved through the gateway on one UTC day.
+// USD amounts are whole dollars at each job's agreed price and wei amounts
+// are decimal strings.
+type Settlement struct {
+	Day             string `json:"day"` // YYYY-MM-DD
+	DepositCount    int64  `json:"deposit_count"`
+	DepositUSD      int64  `json:"deposit_usd"`
+	ReleaseCount    int64  `json:"release_count"`
+	ReleaseUSD      int64  `json:"release_usd"`
+	RefundCount     int64  `json:"refund_count"`
+	RefundUSD       int64  `json:"refund_usd"`
+	FeeWei          string `json:"fee_wei"`
+	ReserveWei      string `json:"reserve_wei"`      // the part of fee_wei set aside in the reserve fund
+	ReservePaidWei  string `json:"reserve_paid_wei"` // reserve payouts to users
+	GasTransactions int64  `json:"gas_transactions"`
+	GasWei          string `json:"gas_wei"`
+	GasUSD          string `json:"gas_usd"`
+	NetEscrowUSD    int64  `json:"net_escrow_usd"`   // deposits less releases and refunds
+	NetTreasuryWei  string `json:"net_treasury_wei"` // fees less reserve payouts and gas
+}
+
+// WalletAnomaly reports transactions mined from a signer account that the
+// gateway has no record of signing, a sign its key is in other hands
+type WalletAnomaly struct {
+	Address           string    `json:"address"`
+	AddressURL        string    `json:"address_url,omitempty"` // block explorer page for Address
+	Signer            string    `json:"signer"`                // "hot" or "admin"
+	FirstNonce        uint64    `json:"first_nonce"`           // lowest nonce the gateway didn't use
+	UnexpectedCount   int       `json:"unexpected_count"`
+	OnChainNonce      uint64    `json:"on_chain_nonce"`
+	KillSwitchTripped bool      `json:"kill_switch_tripped"` // signing is halted until an admin re-arms it
+	DetectedAt        time.Time `json:"detected_at"`
+}
+
+// FundingLink reports what became of a funding link sent to a client
+type FundingLink struct {
+	LinkID        int64      `json:"link_id"`
+	ApplicationID int32      `json:"application_id"`
+	USDAmount     int32      `json:"usd_amount"`
+	ValueWei      string     `json:"value_wei"` // the amount the link asked for
+	ExpiresAt     time.Time  `json:"expires_at"`
+	Status        string     `json:"status"`                  // "funded", "funded_late" or "expired"
+	DepositedWei  string     `json:"deposited_wei,omitempty"` // what the client actually sent
+	TxHash        string     `json:"tx_hash,omitempty"`
+	TxURL         string     `json:"tx_url,omitempty"`
+	FundedAt      *time.Time `json:"funded_at,omitempty"` // when the deposit was mined
+}
+
+// KYCHold reports a release held until the freelancer passes KYC, and what
+// became of it
+type KYCHold struct {
+	HoldID           int64      `json:"hold_id"`
+	ApplicationID    int32      `json:"application_id"`
+	FreelancerUserID int32      `json:"freelancer_user_id"`
+	USDAmount        int64      `json:"usd_amount"`
+	KYCStatus        string     `json:"kyc_status"` // "verified", "pending" or "rejected"
+	Status           string     `json:"status"`     // "open", "cleared" or "withdrawn"
+	HeldAt           time.Time  `json:"held_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ApplicationStatus reports an application's platform status changing, and
+// the actions the automation rules took on it
+type ApplicationStatus struct {
+	ApplicationID  int32    `json:"application_id"`
+	Tenant         string   `json:"tenant,omitempty"`
+	PreviousStatus string   `json:"previous_status,omitempty"` // empty the first time the gateway saw the application
+	Status         string   `json:"status"`
+	PaymentStatus  string   `json:"payment_status"` // when the change was seen, before any action
+	Actions        []string `json:"actions"`        // "release", "refund" and "notify", in the order taken
+}
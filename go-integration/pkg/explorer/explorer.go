@@ -0,0 +1,96 @@
+// Package explorer builds block explorer links for transactions and addresses
+// so API consumers don't have to know which explorer serves which network.
+package explorer
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Defaults are the explorers of the networks the gateway is commonly run on,
+// keyed by chain ID. All follow the Etherscan /tx/ and /address/ layout.
+var Defaults = map[int64]string{
+	1:        "https://etherscan.io",
+	11155111: "https://sepolia.etherscan.io",
+	17000:    "https://holesky.etherscan.io",
+	10:       "https://optimistic.etherscan.io",
+	11155420: "https://sepolia-optimism.etherscan.io",
+	42161:    "https://arbiscan.io",
+	421614:   "https://sepolia.arbiscan.io",
+	8453:     "https://basescan.org",
+	84532:    "https://sepolia.basescan.org",
+	137:      "https://polygonscan.com",
+}
+
+// ParseURLs parses a comma-separated list of chainID=baseURL pairs, e.g.
+// "8453=https://basescan.org,31337=". An empty URL disables links for that
+// network.
+func ParseURLs(spec string) (map[int64]string, error) {
+	urls := make(map[int64]string)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, base, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not chainID=url", pair)
+		}
+		chainID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if err != nil || chainID <= 0 {
+			return nil, fmt.Errorf("invalid chain ID %q", id)
+		}
+
+		base = strings.TrimRight(strings.TrimSpace(base), "/")
+		if base != "" {
+			u, err := url.Parse(base)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("invalid explorer URL %q for chain %d", base, chainID)
+			}
+		}
+		urls[chainID] = base
+	}
+	return urls, nil
+}
+
+// Links builds explorer URLs for one network. The zero value builds none.
+type Links struct {
+	base string
+}
+
+// ForNetwork returns the links for chainID, preferring overrides to Defaults
+func ForNetwork(chainID int64, overrides map[int64]string) Links {
+	if base, ok := overrides[chainID]; ok {
+		return Links{base: base}
+	}
+	return Links{base: Defaults[chainID]}
+}
+
+// Enabled reports whether the network has an explorer
+func (l Links) Enabled() bool {
+	return l.base != ""
+}
+
+// Base returns the explorer's base URL, or "" when there is none
+func (l Links) Base() string {
+	return l.base
+}
+
+// Tx returns the page for a transaction hash, or "" when there is no hash or explorer
+func (l Links) Tx(hash string) string {
+	if l.base == "" || hash == "" {
+		return ""
+	}
+	return l.base + "/tx/" + hash
+}
+
+// Address returns the page for an address, or "" when there is no address or explorer
+func (l Links) Address(address string) string {
+	if l.base == "" || address == "" {
+		return ""
+	}
+	return l.base + "/address/" + address
+}
@@ -0,0 +1,48 @@
+package explorer
+
+import "testing"
+
+func TestParseURLs(t *testing.T) {
+	urls, err := ParseURLs(" 8453=https://basescan.org/ , 31337= ")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if urls[8453] != "https://basescan.org" {
+		t.Errorf("Expected the trailing slash trimmed, got %q", urls[8453])
+	}
+	if base, ok := urls[31337]; !ok || base != "" {
+		t.Errorf("Expected an empty URL to disable chain 31337, got %q, %v", base, ok)
+	}
+
+	for _, spec := range []string{"8453", "base=https://basescan.org", "1=ftp://example.com", "1=https://"} {
+		if _, err := ParseURLs(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	sepolia := ForNetwork(11155111, nil)
+	if got := sepolia.Tx("0xabc"); got != "https://sepolia.etherscan.io/tx/0xabc" {
+		t.Errorf("Unexpected tx URL %q", got)
+	}
+	if got := sepolia.Address("0xdef"); got != "https://sepolia.etherscan.io/address/0xdef" {
+		t.Errorf("Unexpected address URL %q", got)
+	}
+	if sepolia.Tx("") != "" {
+		t.Error("Expected no URL without a hash")
+	}
+
+	overridden := ForNetwork(1, map[int64]string{1: "https://explorer.example"})
+	if got := overridden.Tx("0xabc"); got != "https://explorer.example/tx/0xabc" {
+		t.Errorf("Expected the override to win, got %q", got)
+	}
+
+	disabled := ForNetwork(1, map[int64]string{1: ""})
+	if disabled.Enabled() || disabled.Tx("0xabc") != "" {
+		t.Error("Expected an empty override to disable links")
+	}
+	if (Links{}).Tx("0xabc") != "" || ForNetwork(31337, nil).Enabled() {
+		t.Error("Expected no links for an unknown network")
+	}
+}
@@ -0,0 +1,169 @@
+// Package features decides which optional capabilities are enabled for a
+// tenant on a network, so they can be rolled out gradually without separate
+// deployments. Each flag has a deployment-wide default (FEATURE_FLAGS), which
+// rules stored in the database override for a tenant, a network, or both.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flag names an optional capability
+type Flag string
+
+// Flags gated in handlers. A new capability adds its flag here and checks it
+// in the handlers that expose it.
+const (
+	Retainers         Flag = "retainers"           // POST /retainers
+	ReservePayouts    Flag = "reserve_payouts"     // POST /reserve/payouts
+	PublicStatusLinks Flag = "public_status_links" // POST /jobs/{id}/status-token, GET /public/job-status
+	Quotes            Flag = "quotes"              // GET /quote
+	GraphQL           Flag = "graphql"             // /graphql
+	Disputes          Flag = "disputes"            // POST /jobs/{id}/dispute, its evidence and resolution
+	TopUps            Flag = "top_ups"             // POST /jobs/{id}/top-up
+	FundingLinks      Flag = "funding_links"       // POST /jobs/{id}/funding-link
+	ReleaseBatches    Flag = "release_batches"     // POST /release-batch
+	JobClones         Flag = "job_clones"          // POST /jobs/{id}/clone
+)
+
+// defaults are the built-in states; capabilities that shipped before flags
+// existed stay on unless turned off
+var defaults = map[Flag]bool{
+	Retainers:         true,
+	ReservePayouts:    true,
+	PublicStatusLinks: true,
+	Quotes:            true,
+	GraphQL:           true,
+	Disputes:          true,
+	TopUps:            true,
+	FundingLinks:      true,
+	ReleaseBatches:    true,
+	JobClones:         true,
+}
+
+// Known reports whether flag is defined
+func Known(flag Flag) bool {
+	_, ok := defaults[flag]
+	return ok
+}
+
+// All returns every defined flag in name order
+func All() []Flag {
+	flags := make([]Flag, 0, len(defaults))
+	for flag := range defaults {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+	return flags
+}
+
+// ParseDefaults reads a FEATURE_FLAGS value such as "retainers=off,quotes=on"
+// over the built-in defaults
+func ParseDefaults(spec string) (map[Flag]bool, error) {
+	result := make(map[Flag]bool, len(defaults))
+	for flag, enabled := range defaults {
+		result[flag] = enabled
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		flag := Flag(strings.TrimSpace(name))
+		if !ok || !Known(flag) {
+			return nil, fmt.Errorf("unknown feature flag %q", part)
+		}
+		enabled, err := parseState(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("feature flag %s: %w", flag, err)
+		}
+		result[flag] = enabled
+	}
+	return result, nil
+}
+
+func parseState(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid state %q, expected on or off", value)
+	}
+	return enabled, nil
+}
+
+// Rule overrides a flag's default. An empty Tenant or a zero NetworkID
+// matches any tenant or network.
+type Rule struct {
+	Flag      Flag   `json:"flag"`
+	Tenant    string `json:"tenant"`
+	NetworkID int64  `json:"network_id"`
+	Enabled   bool   `json:"enabled"`
+}
+
+func (r Rule) matches(tenant string, networkID int64) bool {
+	return (r.Tenant == "" || r.Tenant == tenant) && (r.NetworkID == 0 || r.NetworkID == networkID)
+}
+
+// specificity ranks matching rules: a tenant rule beats a network rule, and a
+// rule naming both beats either
+func (r Rule) specificity() int {
+	s := 0
+	if r.Tenant != "" {
+		s += 2
+	}
+	if r.NetworkID != 0 {
+		s++
+	}
+	return s
+}
+
+// Set evaluates flags from defaults and rules
+type Set struct {
+	defaults map[Flag]bool
+	rules    []Rule
+}
+
+// NewSet creates a set; defaults normally comes from ParseDefaults
+func NewSet(defaults map[Flag]bool, rules []Rule) *Set {
+	return &Set{defaults: defaults, rules: rules}
+}
+
+// Enabled reports whether flag is on for a tenant on a network, using the most
+// specific matching rule or else the default. Unknown flags are off.
+func (s *Set) Enabled(flag Flag, tenant string, networkID int64) bool {
+	enabled, ok := s.defaults[flag]
+	if !ok {
+		return false
+	}
+
+	best := -1
+	for _, rule := range s.rules {
+		if rule.Flag != flag || !rule.matches(tenant, networkID) {
+			continue
+		}
+		if sp := rule.specificity(); sp > best {
+			best = sp
+			enabled = rule.Enabled
+		}
+	}
+	return enabled
+}
+
+// Evaluate returns every flag's state for a tenant on a network
+func (s *Set) Evaluate(tenant string, networkID int64) map[Flag]bool {
+	states := make(map[Flag]bool, len(s.defaults))
+	for flag := range s.defaults {
+		states[flag] = s.Enabled(flag, tenant, networkID)
+	}
+	return states
+}
@@ -0,0 +1,62 @@
+package features
+
+import "testing"
+
+func TestParseDefaults(t *testing.T) {
+	flags, err := ParseDefaults("retainers=off, quotes=true")
+	if err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if flags[Retainers] || !flags[Quotes] || !flags[ReservePayouts] {
+		t.Errorf("Unexpected defaults: %v", flags)
+	}
+
+	if _, err := ParseDefaults("invoicing=on"); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+	if _, err := ParseDefaults("retainers=maybe"); err == nil {
+		t.Error("Expected an error for an invalid state")
+	}
+	if _, err := ParseDefaults("retainers"); err == nil {
+		t.Error("Expected an error for a flag without a state")
+	}
+}
+
+func TestSetPrecedence(t *testing.T) {
+	defaults, _ := ParseDefaults("")
+	set := NewSet(defaults, []Rule{
+		{Flag: Retainers, NetworkID: 1, Enabled: false},
+		{Flag: Retainers, Tenant: "acme", Enabled: true},
+		{Flag: Retainers, Tenant: "acme", NetworkID: 1, Enabled: false},
+		{Flag: Quotes, Tenant: "beta", Enabled: false},
+	})
+
+	tests := []struct {
+		name     string
+		flag     Flag
+		tenant   string
+		network  int64
+		expected bool
+	}{
+		{"default", Retainers, "", 11155111, true},
+		{"network rule", Retainers, "", 1, false},
+		{"tenant rule beats network rule", Retainers, "acme", 11155111, true},
+		{"tenant and network rule", Retainers, "acme", 1, false},
+		{"other tenant on network", Retainers, "other", 1, false},
+		{"tenant rule only for that tenant", Quotes, "acme", 1, true},
+		{"tenant rule", Quotes, "beta", 1, false},
+		{"unknown flag", Flag("invoicing"), "", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := set.Enabled(tt.flag, tt.tenant, tt.network); got != tt.expected {
+				t.Errorf("Expected %s for %q on %d to be %v, got %v", tt.flag, tt.tenant, tt.network, tt.expected, got)
+			}
+		})
+	}
+
+	if states := set.Evaluate("acme", 1); len(states) != len(All()) || states[Retainers] {
+		t.Errorf("Unexpected evaluated flags: %v", states)
+	}
+}
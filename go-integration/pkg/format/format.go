@@ -0,0 +1,162 @@
+// Package format renders amounts as display strings, e.g. "$1,250.00" or
+// "0.4213 ETH", so every response rounds and groups digits the same way.
+// Raw values stay in responses alongside these strings; display strings are
+// for people and must never be parsed back.
+package format
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+)
+
+// Decimal places shown for each unit
+const (
+	USDPlaces = 2
+	ETHPlaces = 4
+)
+
+// Locale describes how numbers and currency are written in one locale
+type Locale struct {
+	Tag          string
+	Decimal      string // decimal separator
+	Group        string // thousands separator
+	SymbolBefore bool   // "$1.00" rather than "1,00 $"
+}
+
+// DefaultLocale is used when no supported locale is requested
+var DefaultLocale = Locale{Tag: "en-US", Decimal: ".", Group: ",", SymbolBefore: true}
+
+// locales are the supported locales keyed by lower-case tag
+var locales = map[string]Locale{
+	"en-us": DefaultLocale,
+	"en-gb": {Tag: "en-GB", Decimal: ".", Group: ",", SymbolBefore: true},
+	"en-pk": {Tag: "en-PK", Decimal: ".", Group: ",", SymbolBefore: true},
+	"de-de": {Tag: "de-DE", Decimal: ",", Group: "."},
+	"es-es": {Tag: "es-ES", Decimal: ",", Group: "."},
+	"fr-fr": {Tag: "fr-FR", Decimal: ",", Group: " "},
+}
+
+// languageDefaults maps a bare language to the locale used for it
+var languageDefaults = map[string]string{
+	"en": "en-us",
+	"de": "de-de",
+	"es": "es-es",
+	"fr": "fr-fr",
+}
+
+// Lookup returns the locale for a BCP 47 tag such as "de-DE". An unsupported
+// region falls back to the language's default locale, and an unsupported
+// language to DefaultLocale.
+func Lookup(tag string) Locale {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if l, ok := locales[tag]; ok {
+		return l
+	}
+	language, _, _ := strings.Cut(tag, "-")
+	if key, ok := languageDefaults[language]; ok {
+		return locales[key]
+	}
+	return DefaultLocale
+}
+
+// FromAcceptLanguage picks the locale for an Accept-Language header. Tags are
+// tried in the order given, ignoring quality values, and the first supported
+// language wins.
+func FromAcceptLanguage(header string) Locale {
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		language, _, _ := strings.Cut(tag, "-")
+		if _, ok := locales[tag]; ok {
+			return locales[tag]
+		}
+		if _, ok := languageDefaults[language]; ok {
+			return Lookup(tag)
+		}
+	}
+	return DefaultLocale
+}
+
+// USD formats a dollar amount rounded half away from zero to cents
+func (l Locale) USD(amount *big.Rat) string {
+	negative := amount.Sign() < 0
+	number := l.Number(new(big.Rat).Abs(amount), USDPlaces)
+
+	var out string
+	if l.SymbolBefore {
+		out = "$" + number
+	} else {
+		out = number + " $"
+	}
+	if negative {
+		out = "-" + out
+	}
+	return out
+}
+
+// USDInt formats a whole-dollar amount
+func (l Locale) USDInt(dollars int64) string {
+	return l.USD(new(big.Rat).SetInt64(dollars))
+}
+
+// Money formats an amount of any currency by its ISO 4217 code, e.g.
+// "PKR 69,612.50", rounded half away from zero to two places. USD is
+// formatted as USD would be.
+func (l Locale) Money(amount *big.Rat, currency string) string {
+	if currency == "USD" {
+		return l.USD(amount)
+	}
+	negative := amount.Sign() < 0
+	number := l.Number(new(big.Rat).Abs(amount), USDPlaces)
+
+	var out string
+	if l.SymbolBefore {
+		out = currency + " " + number
+	} else {
+		out = number + " " + currency
+	}
+	if negative {
+		out = "-" + out
+	}
+	return out
+}
+
+// ETH formats a wei amount in ether, rounded half away from zero
+func (l Locale) ETH(wei *big.Int) string {
+	return l.Number(amounts.WeiToETH(wei), ETHPlaces) + " ETH"
+}
+
+// Number formats r with the given decimal places, grouping the integer digits
+func (l Locale) Number(r *big.Rat, places int) string {
+	digits := amounts.Scale(r, places, amounts.HalfUp).String()
+
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	// Left-pad so there is always at least one integer digit
+	for len(digits) <= places {
+		digits = "0" + digits
+	}
+	integer, fraction := digits[:len(digits)-places], digits[len(digits)-places:]
+
+	var b strings.Builder
+	if negative {
+		b.WriteString("-")
+	}
+	for i, d := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(d)
+	}
+	if places > 0 {
+		b.WriteString(l.Decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package format
+
+import (
+	"math/big"
+	"testing"
+)
+
+func rat(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic(s)
+	}
+	return r
+}
+
+func wei(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic(s)
+	}
+	return i
+}
+
+func TestUSD(t *testing.T) {
+	tests := []struct {
+		locale   string
+		amount   string
+		expected string
+	}{
+		{"en-US", "1250", "$1,250.00"},
+		{"en-US", "0.005", "$0.01"},
+		{"en-US", "0.0049", "$0.00"},
+		{"en-US", "1234567.891", "$1,234,567.89"},
+		{"en-US", "-12.345", "-$12.35"},
+		{"en-US", "999.999", "$1,000.00"},
+		{"de-DE", "1250.5", "1.250,50 $"},
+		{"fr-FR", "1250", "1 250,00 $"},
+	}
+
+	for _, tt := range tests {
+		got := Lookup(tt.locale).USD(rat(tt.amount))
+		if got != tt.expected {
+			t.Errorf("Expected %s USD(%s) = %q, got %q", tt.locale, tt.amount, tt.expected, got)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		locale   string
+		amount   string
+		currency string
+		expected string
+	}{
+		{"en-PK", "69612.5", "PKR", "PKR 69,612.50"},
+		{"en-US", "-0.125", "EUR", "-EUR 0.13"},
+		{"de-DE", "1250.5", "EUR", "1.250,50 EUR"},
+		{"en-US", "1250", "USD", "$1,250.00"},
+	}
+
+	for _, tt := range tests {
+		got := Lookup(tt.locale).Money(rat(tt.amount), tt.currency)
+		if got != tt.expected {
+			t.Errorf("Expected %s Money(%s, %s) = %q, got %q", tt.locale, tt.amount, tt.currency, tt.expected, got)
+		}
+	}
+}
+
+func TestETH(t *testing.T) {
+	tests := []struct {
+		locale   string
+		wei      string
+		expected string
+	}{
+		{"en-US", "421300000000000000", "0.4213 ETH"},
+		{"en-US", "421350000000000000", "0.4214 ETH"},
+		{"en-US", "421349999999999999", "0.4213 ETH"},
+		{"en-US", "1500000000000000000000", "1,500.0000 ETH"},
+		{"en-US", "1", "0.0000 ETH"},
+		{"de-DE", "421300000000000000", "0,4213 ETH"},
+	}
+
+	for _, tt := range tests {
+		got := Lookup(tt.locale).ETH(wei(tt.wei))
+		if got != tt.expected {
+			t.Errorf("Expected %s ETH(%s) = %q, got %q", tt.locale, tt.wei, tt.expected, got)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := map[string]string{
+		"de-DE":  "de-DE",
+		"de_de":  "de-DE",
+		"de-AT":  "de-DE",
+		"en":     "en-US",
+		"ja-JP":  "en-US",
+		"":       "en-US",
+		"EN-gb ": "en-GB",
+	}
+
+	for tag, expected := range tests {
+		if got := Lookup(tag).Tag; got != expected {
+			t.Errorf("Expected Lookup(%q) = %s, got %s", tag, expected, got)
+		}
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	tests := map[string]string{
+		"fr-CH, fr;q=0.9, en;q=0.8": "fr-FR",
+		"ja-JP, de;q=0.5":           "de-DE",
+		"*":                         "en-US",
+		"":                          "en-US",
+	}
+
+	for header, expected := range tests {
+		if got := FromAcceptLanguage(header).Tag; got != expected {
+			t.Errorf("Expected FromAcceptLanguage(%q) = %s, got %s", header, expected, got)
+		}
+	}
+}
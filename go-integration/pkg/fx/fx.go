@@ -0,0 +1,178 @@
+// Package fx converts USD amounts into the currencies clients and freelancers
+// prefer to see them in. Rates come from an HTTP endpoint serving USD-based
+// rates, or from a fixed list, and are for display only: escrows are always
+// priced in USD.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/cache"
+)
+
+// ErrUnknownCurrency is returned for a currency the provider has no rate for
+var ErrUnknownCurrency = errors.New("no exchange rate for currency")
+
+// Rate is what one US dollar buys in a currency
+type Rate struct {
+	Currency string
+	PerUSD   *big.Rat
+	AsOf     time.Time // when the provider last updated the rate
+}
+
+// Convert returns usd in the rate's currency
+func (r *Rate) Convert(usd *big.Rat) *big.Rat {
+	return new(big.Rat).Mul(usd, r.PerUSD)
+}
+
+// Provider looks up USD exchange rates
+type Provider interface {
+	Rate(ctx context.Context, currency string) (*Rate, error)
+}
+
+// ParseCurrency normalizes an ISO 4217 code such as "pkr" to "PKR"
+func ParseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 || strings.IndexFunc(code, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		return "", fmt.Errorf("%q is not a three-letter currency code", code)
+	}
+	return code, nil
+}
+
+// usd is the rate every provider serves without a lookup
+func usd(asOf time.Time) *Rate {
+	return &Rate{Currency: "USD", PerUSD: big.NewRat(1, 1), AsOf: asOf}
+}
+
+// Static serves fixed rates, e.g. FX_RATES=PKR=278.50,EUR=0.92
+type Static struct {
+	rates map[string]*big.Rat
+	asOf  time.Time
+}
+
+// ParseStatic reads a comma-separated list of CURRENCY=units-per-dollar pairs
+func ParseStatic(spec string) (*Static, error) {
+	static := &Static{rates: make(map[string]*big.Rat), asOf: time.Now().UTC()}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not CURRENCY=rate", entry)
+		}
+		currency, err := ParseCurrency(code)
+		if err != nil {
+			return nil, err
+		}
+		rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("rate of %s must be a positive number, got %q", currency, value)
+		}
+		static.rates[currency] = rate
+	}
+	return static, nil
+}
+
+// Rate returns the configured rate of currency
+func (s *Static) Rate(ctx context.Context, currency string) (*Rate, error) {
+	if currency == "USD" {
+		return usd(s.asOf), nil
+	}
+	rate, ok := s.rates[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownCurrency, currency)
+	}
+	return &Rate{Currency: currency, PerUSD: rate, AsOf: s.asOf}, nil
+}
+
+// HTTP fetches USD-based rates from a URL serving
+// {"base_code": "USD", "time_last_update_unix": 1700000000, "rates": {"PKR": 278.5}},
+// as open.er-api.com/v6/latest/USD does. The whole table is cached for TTL.
+type HTTP struct {
+	URL        string
+	HTTPClient *http.Client
+
+	tables *cache.TTL[string, *table]
+}
+
+type table struct {
+	rates map[string]*big.Rat
+	asOf  time.Time
+}
+
+// NewHTTP creates a provider that refetches url at most once per ttl
+func NewHTTP(url string, ttl time.Duration) *HTTP {
+	return &HTTP{
+		URL: url,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		tables: cache.NewTTL[string, *table](ttl),
+	}
+}
+
+// Rate returns the latest rate of currency
+func (h *HTTP) Rate(ctx context.Context, currency string) (*Rate, error) {
+	rates, err := h.tables.Get(h.URL, func() (*table, error) { return h.fetch(ctx) })
+	if err != nil {
+		return nil, err
+	}
+	if currency == "USD" {
+		return usd(rates.asOf), nil
+	}
+	rate, ok := rates.rates[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownCurrency, currency)
+	}
+	return &Rate{Currency: currency, PerUSD: rate, AsOf: rates.asOf}, nil
+}
+
+func (h *HTTP) fetch(ctx context.Context) (*table, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exchange rate request: %w", err)
+	}
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchange rate request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange rate provider returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Base      string                 `json:"base_code"`
+		UpdatedAt int64                  `json:"time_last_update_unix"`
+		Rates     map[string]json.Number `json:"rates"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid exchange rate response: %w", err)
+	}
+	if body.Base != "" && body.Base != "USD" {
+		return nil, fmt.Errorf("exchange rates are based on %s, expected USD", body.Base)
+	}
+
+	rates := &table{rates: make(map[string]*big.Rat, len(body.Rates)), asOf: time.Now().UTC()}
+	if body.UpdatedAt > 0 {
+		rates.asOf = time.Unix(body.UpdatedAt, 0).UTC()
+	}
+	for code, value := range body.Rates {
+		rate, ok := new(big.Rat).SetString(value.String())
+		if ok && rate.Sign() > 0 {
+			rates.rates[strings.ToUpper(code)] = rate
+		}
+	}
+	return rates, nil
+}
@@ -0,0 +1,73 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTP(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"result":"success","base_code":"USD","time_last_update_unix":1700000000,"rates":{"USD":1,"PKR":278.45,"EUR":0.9213}}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTP(server.URL, time.Hour)
+	ctx := context.Background()
+	rate, err := provider.Rate(ctx, "PKR")
+	if err != nil {
+		t.Fatalf("Failed to get rate: %v", err)
+	}
+	if rate.PerUSD.Cmp(big.NewRat(27845, 100)) != 0 || !rate.AsOf.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Unexpected rate %s as of %s", rate.PerUSD.FloatString(2), rate.AsOf)
+	}
+	if got := rate.Convert(big.NewRat(250, 1)).FloatString(2); got != "69612.50" {
+		t.Errorf("Expected $250 to be PKR 69612.50, got %s", got)
+	}
+
+	if _, err := provider.Rate(ctx, "EUR"); err != nil {
+		t.Errorf("Failed to get rate: %v", err)
+	}
+	if _, err := provider.Rate(ctx, "XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Expected ErrUnknownCurrency, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the rate table fetched once, got %d requests", requests)
+	}
+}
+
+func TestHTTPRejectsOtherBase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"base_code":"EUR","rates":{"PKR":300}}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewHTTP(server.URL, time.Hour).Rate(context.Background(), "PKR"); err == nil {
+		t.Error("Expected rates based on another currency to be rejected")
+	}
+}
+
+func TestParseStatic(t *testing.T) {
+	static, err := ParseStatic(" pkr=278.5, EUR=0.92 ,")
+	if err != nil {
+		t.Fatalf("Failed to parse rates: %v", err)
+	}
+	if rate, err := static.Rate(context.Background(), "PKR"); err != nil || rate.PerUSD.FloatString(1) != "278.5" {
+		t.Errorf("Expected PKR at 278.5, got %v (%v)", rate, err)
+	}
+	if rate, err := static.Rate(context.Background(), "USD"); err != nil || rate.PerUSD.Cmp(big.NewRat(1, 1)) != 0 {
+		t.Errorf("Expected USD at 1, got %v (%v)", rate, err)
+	}
+
+	for _, spec := range []string{"PKR", "PKR=-1", "PKR=abc", "RUPEE=1"} {
+		if _, err := ParseStatic(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package gaswindow finds the hours of the day when the base fee is usually
+// lowest, from base fees sampled over recent days, so operations that can
+// wait are sent then instead of at whatever the fee happens to be.
+package gaswindow
+
+import "time"
+
+// MinSamples is how many samples an hour needs before its average is trusted
+const MinSamples = 3
+
+// Hour is the average base fee sampled in one UTC hour of the day
+type Hour struct {
+	Hour        int     `json:"hour"` // 0-23
+	AverageGwei float64 `json:"average_gwei"`
+	Samples     int     `json:"samples"`
+}
+
+// Profile is the average base fee by UTC hour of the day. Hours without
+// samples are missing.
+type Profile []Hour
+
+// Window is the start of an hour an operation is scheduled into
+type Window struct {
+	Start       time.Time
+	AverageGwei float64 // the base fee the hour usually has
+	CurrentGwei float64 // the base fee the current hour usually has
+}
+
+// Saving is the fraction of the current hour's base fee the window saves
+func (w Window) Saving() float64 {
+	if w.CurrentGwei <= 0 {
+		return 0
+	}
+	return 1 - w.AverageGwei/w.CurrentGwei
+}
+
+func (p Profile) hour(h int) (Hour, bool) {
+	for _, hour := range p {
+		if hour.Hour == h && hour.Samples >= MinSamples {
+			return hour, true
+		}
+	}
+	return Hour{}, false
+}
+
+// Cheapest returns the hour starting within maxDelay of now whose base fee is
+// usually lowest, the earliest of equally cheap hours. ok is false when now is
+// already as cheap as it gets, or when the current hour has too few samples
+// to compare against, so the operation should be sent now.
+func (p Profile) Cheapest(now time.Time, maxDelay time.Duration) (window Window, ok bool) {
+	now = now.UTC()
+	current, known := p.hour(now.Hour())
+	if !known {
+		return Window{}, false
+	}
+
+	best := Window{Start: now, AverageGwei: current.AverageGwei, CurrentGwei: current.AverageGwei}
+	start := now.Truncate(time.Hour)
+	// Later than a day ahead the hours repeat, and the earliest is preferred
+	for i := 1; i <= 24; i++ {
+		start = start.Add(time.Hour)
+		if start.Sub(now) > maxDelay {
+			break
+		}
+		if hour, known := p.hour(start.Hour()); known && hour.AverageGwei < best.AverageGwei {
+			best.Start, best.AverageGwei = start, hour.AverageGwei
+		}
+	}
+	if best.Start.Equal(now) {
+		return Window{}, false
+	}
+	return best, true
+}
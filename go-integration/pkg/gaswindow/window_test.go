@@ -0,0 +1,50 @@
+package gaswindow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheapest(t *testing.T) {
+	// Fees peak in the afternoon and bottom out at 03:00 UTC
+	profile := Profile{
+		{Hour: 1, AverageGwei: 12, Samples: 10},
+		{Hour: 3, AverageGwei: 8, Samples: 10},
+		{Hour: 5, AverageGwei: 8, Samples: 10},
+		{Hour: 14, AverageGwei: 40, Samples: 10},
+		{Hour: 15, AverageGwei: 20, Samples: 10},
+		{Hour: 22, AverageGwei: 4, Samples: 1}, // too few samples to trust
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name     string
+		now      time.Time
+		maxDelay time.Duration
+		ok       bool
+		start    time.Time
+	}{
+		{"cheapest hour within the delay", at(14, 20), 14 * time.Hour, true, at(3, 0).AddDate(0, 0, 1)},
+		{"earliest of equally cheap hours", at(1, 10), 12 * time.Hour, true, at(3, 0)},
+		{"cheapest hour beyond the delay", at(14, 20), 2 * time.Hour, true, at(15, 0)},
+		{"no cheaper hour starts in time", at(14, 20), 30 * time.Minute, false, time.Time{}},
+		{"already the cheapest hour", at(3, 30), 24 * time.Hour, false, time.Time{}},
+		{"current hour unsampled", at(9, 0), 24 * time.Hour, false, time.Time{}},
+		{"current hour with too few samples", at(22, 10), 24 * time.Hour, false, time.Time{}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			window, ok := profile.Cheapest(tt.now, tt.maxDelay)
+			if ok != tt.ok || !window.Start.Equal(tt.start) {
+				t.Errorf("Expected %v at %s, got %v at %s", tt.ok, tt.start, ok, window.Start)
+			}
+		})
+	}
+
+	window, _ := profile.Cheapest(at(14, 20), 14*time.Hour)
+	if window.CurrentGwei != 40 || window.AverageGwei != 8 || window.Saving() != 0.8 {
+		t.Errorf("Unexpected window %+v saving %v", window, window.Saving())
+	}
+}
@@ -0,0 +1,511 @@
+// Package graphql executes read-only GraphQL queries against a schema of Go
+// resolvers. It covers what dashboards use: fields with arguments and
+// aliases, variables, named and inline fragments, and __typename. Mutations,
+// subscriptions, directives and introspection are refused.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// MaxDepth caps how deeply selections may nest, so one query can't fan out
+// into an unbounded number of lookups
+const MaxDepth = 8
+
+// ArgType is the type of a field argument: a scalar name, with a trailing !
+// when the argument is required
+type ArgType string
+
+// Argument types
+const (
+	Int     ArgType = "Int"
+	Float   ArgType = "Float"
+	String  ArgType = "String"
+	Boolean ArgType = "Boolean"
+)
+
+// NonNull marks an argument type as required
+func NonNull(t ArgType) ArgType { return t + "!" }
+
+// Schema is the root of the queries a handler serves
+type Schema struct {
+	Query *Object
+}
+
+// Object is a type whose fields are selected
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is one field of an object. A field of an object type resolves to a
+// value for that type's resolvers, a slice of them for a list, or nil; a
+// scalar field resolves to its JSON value. Every field is nullable.
+type Field struct {
+	Type    *Object // nil for a scalar
+	Args    map[string]ArgType
+	Resolve func(ctx context.Context, source interface{}, args Args) (interface{}, error)
+}
+
+// Args holds a field's arguments that were given, coerced to int, float64,
+// string or bool
+type Args map[string]interface{}
+
+// Int returns the named argument, or fallback if it wasn't given
+func (a Args) Int(name string, fallback int) int {
+	if v, ok := a[name].(int); ok {
+		return v
+	}
+	return fallback
+}
+
+// String returns the named argument, or "" if it wasn't given
+func (a Args) String(name string) string {
+	v, _ := a[name].(string)
+	return v
+}
+
+// Bool returns the named argument, or false if it wasn't given
+func (a Args) Bool(name string) bool {
+	v, _ := a[name].(bool)
+	return v
+}
+
+// Has reports whether the named argument was given a non-null value
+func (a Args) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// Request is a GraphQL request as POSTed in JSON
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is nil when the request could
+// not be executed at all; otherwise fields that failed are null and listed
+// in Errors.
+type Response struct {
+	Data   *Result `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is one failure, with the response path of the field that failed
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Result is a selected object's fields in the order they were selected
+type Result struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// Get returns the value under key
+func (r *Result) Get(key string) interface{} {
+	return r.values[key]
+}
+
+// MarshalJSON encodes the fields in selection order
+func (r *Result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (r *Result) set(key string, value interface{}) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = value
+}
+
+// Prepare parses and validates a request, returning what Run executes. It
+// does no I/O, so any error means the request itself is invalid.
+func (s *Schema) Prepare(req Request) (*Prepared, error) {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return nil, err
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return nil, err
+	}
+	if op.Type != "query" {
+		return nil, fmt.Errorf("%s operations are not supported", op.Type)
+	}
+	variables, err := coerceVariables(op.Variables, req.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	prepared := &Prepared{schema: s, doc: doc, op: op, variables: variables}
+	if err := prepared.validate(s.Query, op.Selections, 1, nil); err != nil {
+		return nil, err
+	}
+	return prepared, nil
+}
+
+// Execute prepares and runs a request
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	prepared, err := s.Prepare(req)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	return prepared.Run(ctx)
+}
+
+// Prepared is a validated operation ready to run
+type Prepared struct {
+	schema    *Schema
+	doc       *Document
+	op        *Operation
+	variables map[string]interface{}
+}
+
+// Run executes the operation. Resolver errors null their field and are
+// reported with its path; the rest of the query still runs.
+func (p *Prepared) Run(ctx context.Context) *Response {
+	e := &execution{prepared: p}
+	data := e.object(ctx, p.schema.Query, nil, p.op.Selections, nil)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func (d *Document) operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has more than one operation")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// collect flattens fragments into the fields selected on object, grouping
+// fields that share a response key so their selections merge
+func (p *Prepared) collect(object *Object, selections []Selection, visiting map[string]bool, into *[]*fieldGroup, byKey map[string]*fieldGroup) error {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldSelection:
+			key := s.ResponseKey()
+			group, ok := byKey[key]
+			if !ok {
+				group = &fieldGroup{key: key, name: s.Name}
+				byKey[key] = group
+				*into = append(*into, group)
+			} else if group.name != s.Name {
+				return fmt.Errorf("%q selects both %s and %s", key, group.name, s.Name)
+			}
+			group.fields = append(group.fields, s)
+		case *FragmentSpread:
+			fragment, ok := p.doc.Fragments[s.Name]
+			if !ok {
+				return fmt.Errorf("unknown fragment %q", s.Name)
+			}
+			if visiting[s.Name] {
+				return fmt.Errorf("fragment %q spreads itself", s.Name)
+			}
+			if fragment.TypeCondition != object.Name {
+				return fmt.Errorf("fragment %q on %s can't be spread in %s", s.Name, fragment.TypeCondition, object.Name)
+			}
+			visiting[s.Name] = true
+			err := p.collect(object, fragment.Selections, visiting, into, byKey)
+			delete(visiting, s.Name)
+			if err != nil {
+				return err
+			}
+		case *InlineFragment:
+			if s.TypeCondition != "" && s.TypeCondition != object.Name {
+				return fmt.Errorf("fragment on %s can't be used in %s", s.TypeCondition, object.Name)
+			}
+			if err := p.collect(object, s.Selections, visiting, into, byKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldGroup is every selection of one response key
+type fieldGroup struct {
+	key    string
+	name   string
+	fields []*FieldSelection
+}
+
+func (g *fieldGroup) selections() []Selection {
+	var selections []Selection
+	for _, field := range g.fields {
+		selections = append(selections, field.Selections...)
+	}
+	return selections
+}
+
+func (p *Prepared) groups(object *Object, selections []Selection) ([]*fieldGroup, error) {
+	var groups []*fieldGroup
+	err := p.collect(object, selections, map[string]bool{}, &groups, map[string]*fieldGroup{})
+	return groups, err
+}
+
+func (p *Prepared) validate(object *Object, selections []Selection, depth int, path []string) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("query nests deeper than %d levels at %s", MaxDepth, strings.Join(path, "."))
+	}
+	groups, err := p.groups(object, selections)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		fieldPath := append(path[:len(path):len(path)], group.key)
+		if group.name == "__typename" {
+			if len(group.selections()) > 0 {
+				return fmt.Errorf("__typename has no fields to select")
+			}
+			continue
+		}
+		def, ok := object.Fields[group.name]
+		if !ok {
+			return fmt.Errorf("%s has no field %q", object.Name, group.name)
+		}
+
+		var previous Args
+		for i, field := range group.fields {
+			args, err := p.arguments(def, field)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", object.Name, field.Name, err)
+			}
+			if i > 0 && !reflect.DeepEqual(args, previous) {
+				return fmt.Errorf("%q is selected more than once with different arguments", group.key)
+			}
+			previous = args
+		}
+
+		selected := group.selections()
+		switch {
+		case def.Type == nil && len(selected) > 0:
+			return fmt.Errorf("%s.%s is a scalar and has no fields to select", object.Name, group.name)
+		case def.Type != nil && len(selected) == 0:
+			return fmt.Errorf("%s.%s needs a selection of %s fields", object.Name, group.name, def.Type.Name)
+		case def.Type != nil:
+			if err := p.validate(def.Type, selected, depth+1, fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// arguments resolves and coerces a field's arguments against its definition
+func (p *Prepared) arguments(def *Field, field *FieldSelection) (Args, error) {
+	args := Args{}
+	for _, arg := range field.Arguments {
+		typ, ok := def.Args[arg.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown argument %q", arg.Name)
+		}
+		if _, dup := args[arg.Name]; dup {
+			return nil, fmt.Errorf("argument %q is given more than once", arg.Name)
+		}
+		value, err := p.resolve(arg.Value)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		coerced, err := coerce(typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", arg.Name, err)
+		}
+		args[arg.Name] = coerced
+	}
+	for name, typ := range def.Args {
+		if strings.HasSuffix(string(typ), "!") && !args.Has(name) {
+			return nil, fmt.Errorf("argument %q is required", name)
+		}
+	}
+	return args, nil
+}
+
+// resolve substitutes variables in an argument value
+func (p *Prepared) resolve(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case Variable:
+		resolved, ok := p.variables[string(v)]
+		if !ok {
+			if !p.declares(string(v)) {
+				return nil, fmt.Errorf("variable $%s is not declared", v)
+			}
+			return nil, nil
+		}
+		return resolved, nil
+	case []interface{}, map[string]interface{}:
+		return nil, fmt.Errorf("list and object arguments are not supported")
+	}
+	return value, nil
+}
+
+func (p *Prepared) declares(name string) bool {
+	for _, def := range p.op.Variables {
+		if def.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// coerceVariables applies defaults and checks required variables are given
+func coerceVariables(defs []*VariableDefinition, given map[string]interface{}) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(defs))
+	for _, def := range defs {
+		value, ok := given[def.Name]
+		if !ok || value == nil {
+			value = def.Default
+		}
+		if value == nil {
+			if strings.HasSuffix(def.Type, "!") {
+				return nil, fmt.Errorf("variable $%s of type %s is required", def.Name, def.Type)
+			}
+			continue
+		}
+		coerced, err := coerce(ArgType(def.Type), value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.Name, err)
+		}
+		variables[def.Name] = coerced
+	}
+	return variables, nil
+}
+
+// coerce converts a literal or JSON variable to typ's Go value
+func coerce(typ ArgType, value interface{}) (interface{}, error) {
+	switch ArgType(strings.TrimSuffix(string(typ), "!")) {
+	case Int:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case int64:
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		case float64:
+			// JSON variables decode as float64
+			if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		case json.Number:
+			if n, err := v.Int64(); err == nil && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+		return nil, fmt.Errorf("expected a 32-bit Int, got %v", value)
+	case Float:
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a Float, got %v", value)
+	case String:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected a String, got %v", value)
+	case Boolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected a Boolean, got %v", value)
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+type execution struct {
+	prepared *Prepared
+	errors   []Error
+}
+
+func (e *execution) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, Error{Message: err.Error(), Path: append([]interface{}(nil), path...)})
+}
+
+func (e *execution) object(ctx context.Context, object *Object, source interface{}, selections []Selection, path []interface{}) *Result {
+	// Validation has already checked every group
+	groups, _ := e.prepared.groups(object, selections)
+	result := &Result{values: make(map[string]interface{}, len(groups))}
+	for _, group := range groups {
+		fieldPath := append(path[:len(path):len(path)], group.key)
+		if group.name == "__typename" {
+			result.set(group.key, object.Name)
+			continue
+		}
+		def := object.Fields[group.name]
+		args, _ := e.prepared.arguments(def, group.fields[0])
+		value, err := def.Resolve(ctx, source, args)
+		if err != nil {
+			e.fail(fieldPath, err)
+			result.set(group.key, nil)
+			continue
+		}
+		result.set(group.key, e.complete(ctx, def.Type, value, group.selections(), fieldPath))
+	}
+	return result
+}
+
+// complete shapes a resolved value for the response. A nil slice is an
+// empty list, so resolvers can return a query's rows as they come.
+func (e *execution) complete(ctx context.Context, object *Object, value interface{}, selections []Selection, path []interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	switch {
+	case value == nil, (v.Kind() == reflect.Ptr || v.Kind() == reflect.Map) && v.IsNil():
+		return nil
+	case v.Kind() == reflect.Slice && v.IsNil():
+		return []interface{}{}
+	}
+	if object == nil {
+		return value
+	}
+	if v.Kind() != reflect.Slice {
+		return e.object(ctx, object, value, selections, path)
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = e.complete(ctx, object, v.Index(i).Interface(), selections, append(path[:len(path):len(path)], i))
+	}
+	return items
+}
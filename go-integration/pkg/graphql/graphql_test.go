@@ -0,0 +1,209 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testBook struct {
+	ID     int
+	Title  string
+	Author *testAuthor
+}
+
+type testAuthor struct {
+	Name  string
+	Books []*testBook
+}
+
+func testSchema() *Schema {
+	tolkien := &testAuthor{Name: "Tolkien"}
+	books := []*testBook{{ID: 1, Title: "The Hobbit", Author: tolkien}, {ID: 2, Title: "Silmarillion", Author: tolkien}}
+	tolkien.Books = books
+
+	book := &Object{Name: "Book"}
+	author := &Object{Name: "Author"}
+	book.Fields = map[string]*Field{
+		"id": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return source.(*testBook).ID, nil
+		}},
+		"title": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return source.(*testBook).Title, nil
+		}},
+		"author": {Type: author, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return source.(*testBook).Author, nil
+		}},
+		"review": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return nil, errors.New("reviews are unavailable")
+		}},
+	}
+	author.Fields = map[string]*Field{
+		"name": {Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return source.(*testAuthor).Name, nil
+		}},
+		"books": {Type: book, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			return source.(*testAuthor).Books, nil
+		}},
+	}
+
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"book": {
+			Type: book,
+			Args: map[string]ArgType{"id": NonNull(Int)},
+			Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				for _, b := range books {
+					if b.ID == args.Int("id", 0) {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		"books": {
+			Type: book,
+			Args: map[string]ArgType{"limit": Int, "title": String},
+			Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+				var matched []*testBook
+				for _, b := range books {
+					if (!args.Has("title") || b.Title == args.String("title")) && len(matched) < args.Int("limit", 10) {
+						matched = append(matched, b)
+					}
+				}
+				return matched, nil
+			},
+		},
+	}}}
+}
+
+func execute(t *testing.T, query string, variables map[string]interface{}) string {
+	t.Helper()
+	out, err := json.Marshal(testSchema().Execute(context.Background(), Request{Query: query, Variables: variables}))
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	return string(out)
+}
+
+func TestExecute(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		expected  string
+	}{
+		{
+			name:     "nested selection in query order",
+			query:    `{ book(id: 1) { title id author { name } } }`,
+			expected: `{"data":{"book":{"title":"The Hobbit","id":1,"author":{"name":"Tolkien"}}}}`,
+		},
+		{
+			name:     "aliases and lists",
+			query:    `query { first: books(limit: 1) { id } all: books { id } }`,
+			expected: `{"data":{"first":[{"id":1}],"all":[{"id":1},{"id":2}]}}`,
+		},
+		{
+			name:      "variables from JSON with a default",
+			query:     `query Book($id: Int!, $limit: Int = 1) { book(id: $id) { author { books { title } } } books(limit: $limit) { id } }`,
+			variables: map[string]interface{}{"id": float64(2)},
+			expected:  `{"data":{"book":{"author":{"books":[{"title":"The Hobbit"},{"title":"Silmarillion"}]}},"books":[{"id":1}]}}`,
+		},
+		{
+			name:     "fragments and __typename",
+			query:    `{ book(id: 2) { ...bookFields ... on Book { author { __typename } } } } fragment bookFields on Book { id __typename }`,
+			expected: `{"data":{"book":{"id":2,"__typename":"Book","author":{"__typename":"Author"}}}}`,
+		},
+		{
+			name:     "fields of the same key merge",
+			query:    `{ book(id: 1) { author { name } author { books { id } } } }`,
+			expected: `{"data":{"book":{"author":{"name":"Tolkien","books":[{"id":1},{"id":2}]}}}}`,
+		},
+		{
+			name:     "missing object and empty list",
+			query:    `{ book(id: 9) { id } books(title: "Dune") { id } }`,
+			expected: `{"data":{"book":null,"books":[]}}`,
+		},
+		{
+			name:     "resolver error nulls only its field",
+			query:    `{ books { id review } }`,
+			expected: `{"data":{"books":[{"id":1,"review":null},{"id":2,"review":null}]},"errors":[{"message":"reviews are unavailable","path":["books",0,"review"]},{"message":"reviews are unavailable","path":["books",1,"review"]}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		if got := execute(t, c.query, c.variables); got != c.expected {
+			t.Errorf("%s:\nexpected %s\n     got %s", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestExecuteRejectsInvalidQueries(t *testing.T) {
+	cases := map[string]string{
+		`{ book(id: 1) { isbn } }`:                               `Book has no field \"isbn\"`,
+		`{ book { id } }`:                                        `argument \"id\" is required`,
+		`{ book(id: "one") { id } }`:                             `expected a 32-bit Int`,
+		`{ book(id: 1, format: PAPER) { id } }`:                  `unknown argument \"format\"`,
+		`{ book(id: 1) }`:                                        `needs a selection of Book fields`,
+		`{ book(id: 1) { title { length } } }`:                   `is a scalar`,
+		`mutation { book(id: 1) { id } }`:                        `mutation operations are not supported`,
+		`{ book(id: 1) { id @include(if: true) } }`:              `directives are not supported`,
+		`{ book(id: $id) { id } }`:                               `variable $id is not declared`,
+		`{ book(id: 1) { ...missing } }`:                         `unknown fragment`,
+		`{ book(id: 1) { ...a } } fragment a on Book { ...a }`:   `spreads itself`,
+		`{ book(id: 1) { ...a } } fragment a on Author { name }`: `can't be spread in Book`,
+		`{ book(id: 1) { x: id x: title } }`:                     `selects both id and title`,
+		`{ book(id: 1) { id } `:                                  `unexpected end of query`,
+		`{ book(id: 1) { author { books { author { books { author { books { author { books { id } } } } } } } } } }`: `deeper than 8 levels`,
+	}
+	for query, expected := range cases {
+		if got := execute(t, query, nil); !strings.Contains(got, expected) || strings.Contains(got, `"data"`) {
+			t.Errorf("%s: expected only an error containing %s, got %s", query, expected, got)
+		}
+	}
+
+	schema := testSchema()
+	if _, err := schema.Prepare(Request{Query: `query A { books { id } } query B { books { id } }`}); err == nil {
+		t.Error("Expected operationName to be required with two operations")
+	}
+	if _, err := schema.Prepare(Request{Query: `query A { books { id } } query B { books { id } }`, OperationName: "B"}); err != nil {
+		t.Errorf("Expected the named operation to be chosen, got %v", err)
+	}
+	if _, err := schema.Prepare(Request{Query: `query($id: Int!) { book(id: $id) { id } }`}); err == nil || !strings.Contains(err.Error(), "is required") {
+		t.Errorf("Expected a missing required variable to be rejected, got %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`
+		# Comments and commas are ignored
+		query Jobs($since: String = "2025-01-01", $status: [String!]) {
+			recent: jobs(since: $since, limit: 10, ratio: -1.5e2, open: true, note: "tab\there \u00e9", state: OPEN, missing: null) {
+				id,
+			}
+		}`)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	op := doc.Operations[0]
+	if op.Name != "Jobs" || len(op.Variables) != 2 || op.Variables[0].Default != "2025-01-01" || op.Variables[1].Type != "[String!]" {
+		t.Fatalf("Unexpected operation: %+v", op)
+	}
+	field := op.Selections[0].(*FieldSelection)
+	if field.ResponseKey() != "recent" || field.Name != "jobs" {
+		t.Errorf("Unexpected field: %+v", field)
+	}
+	expected := []interface{}{Variable("since"), int64(10), -150.0, true, "tab\there é", Enum("OPEN"), nil}
+	for i, arg := range field.Arguments {
+		if arg.Value != expected[i] {
+			t.Errorf("Argument %s: expected %#v, got %#v", arg.Name, expected[i], arg.Value)
+		}
+	}
+
+	for _, query := range []string{``, `{}`, `{ a(b: "unterminated) }`, `{ a(b: 1.) }`, `{ a(b: """block""") }`, `fragment on on X { a }`} {
+		if _, err := Parse(query); err == nil {
+			t.Errorf("Expected %q to fail to parse", query)
+		}
+	}
+}
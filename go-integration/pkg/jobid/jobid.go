@@ -0,0 +1,44 @@
+// Package jobid maps escrow job IDs, which the contract stores as uint256 and
+// the gateway handles as uint64, to the int32 application IDs they stand for.
+package jobid
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MaxApplicationID is the largest application ID, the limit of the INTEGER
+// applications.id column
+const MaxApplicationID = math.MaxInt32
+
+// RangeError is a job ID that doesn't name an application
+type RangeError struct {
+	JobID uint64
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("job ID %d is outside the application ID range 1 to %d", e.JobID, MaxApplicationID)
+}
+
+// ApplicationID returns the application a job ID belongs to, or a
+// *RangeError if it is 0 or too large for an application ID
+func ApplicationID(jobID uint64) (int32, error) {
+	if jobID == 0 || jobID > MaxApplicationID {
+		return 0, &RangeError{JobID: jobID}
+	}
+	return int32(jobID), nil
+}
+
+// Parse reads a decimal job ID and maps it to its application
+func Parse(raw string) (uint64, int32, error) {
+	jobID, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("job ID %q is not a non-negative integer", raw)
+	}
+	applicationID, err := ApplicationID(jobID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return jobID, applicationID, nil
+}
@@ -0,0 +1,46 @@
+package jobid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw           string
+		applicationID int32
+		outOfRange    bool
+		invalid       bool
+	}{
+		{raw: "7", applicationID: 7},
+		{raw: "2147483647", applicationID: MaxApplicationID},
+		{raw: "2147483648", outOfRange: true},
+		{raw: "4294967303", outOfRange: true},    // would wrap to 7
+		{raw: "1099511627777", outOfRange: true}, // a retainer period
+		{raw: "0", outOfRange: true},
+		{raw: "-7", invalid: true},
+		{raw: "7x", invalid: true},
+		{raw: "18446744073709551616", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			jobID, applicationID, err := Parse(tt.raw)
+			var rangeErr *RangeError
+			switch {
+			case tt.outOfRange:
+				if !errors.As(err, &rangeErr) {
+					t.Errorf("Expected a *RangeError, got %v", err)
+				}
+			case tt.invalid:
+				if err == nil || errors.As(err, &rangeErr) {
+					t.Errorf("Expected a parse error, got %v", err)
+				}
+			case err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			case applicationID != tt.applicationID || jobID != uint64(tt.applicationID):
+				t.Errorf("Expected application %d, got job %d and application %d", tt.applicationID, jobID, applicationID)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package killswitch verifies signed approvals to halt or resume the
+// gateway's transaction signing. An approval names the action, the network
+// and, to resume, the halt being lifted, and is signed with EIP-191
+// personal_sign by one of the approver keys the gateway is configured with.
+// Together with the admin key on the request, it is the second person the
+// kill switch needs.
+package killswitch
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ApprovalHeader carries the 65-byte hex signature of the request body
+const ApprovalHeader = "X-Kill-Switch-Approval"
+
+// Actions an approval can authorise
+const (
+	ActionActivate   = "activate"
+	ActionDeactivate = "deactivate"
+)
+
+var (
+	ErrBadSignature = errors.New("approval is not signed by a configured approver")
+	ErrWrongNetwork = errors.New("approval is for another network")
+	ErrWrongAction  = errors.New("approval is for another action")
+	ErrStale        = errors.New("approval is outside the allowed window")
+)
+
+// Approval is the signed body of a kill switch request
+type Approval struct {
+	Action    string    `json:"action"`
+	NetworkID int64     `json:"network_id"`
+	HaltID    int64     `json:"halt_id,omitempty"` // the halt being lifted, for ActionDeactivate
+	Reason    string    `json:"reason"`
+	IssuedAt  time.Time `json:"issued_at"`
+}
+
+// Verifier checks approvals for one network against a set of approvers
+type Verifier struct {
+	Approvers []common.Address
+	NetworkID int64
+	MaxAge    time.Duration // how long after IssuedAt an approval is accepted
+}
+
+// ParseApprovers reads KILL_SWITCH_APPROVERS, a comma-separated list of addresses
+func ParseApprovers(spec string) ([]common.Address, error) {
+	var approvers []common.Address
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("%q is not an address", entry)
+		}
+		approvers = append(approvers, common.HexToAddress(entry))
+	}
+	return approvers, nil
+}
+
+// Verify checks that body is signed by one of the approvers for action on
+// the verifier's network within MaxAge of now, and returns it with the
+// approver who signed it
+func (v *Verifier) Verify(body []byte, signature, action string, now time.Time) (*Approval, common.Address, error) {
+	approver, err := v.recover(body, signature)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	var approval Approval
+	if err := json.Unmarshal(body, &approval); err != nil {
+		return nil, common.Address{}, fmt.Errorf("invalid approval: %w", err)
+	}
+	if approval.Action != action {
+		return nil, common.Address{}, fmt.Errorf("%w: approval is to %s", ErrWrongAction, approval.Action)
+	}
+	if approval.NetworkID != v.NetworkID {
+		return nil, common.Address{}, fmt.Errorf("%w: approval is for %d, gateway runs on %d", ErrWrongNetwork, approval.NetworkID, v.NetworkID)
+	}
+	if age := now.Sub(approval.IssuedAt); age > v.MaxAge || age < -v.MaxAge {
+		return nil, common.Address{}, ErrStale
+	}
+	return &approval, approver, nil
+}
+
+func (v *Verifier) recover(body []byte, signature string) (common.Address, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return common.Address{}, ErrBadSignature
+	}
+	// personal_sign produces a recovery ID of 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(body), sig)
+	if err != nil {
+		return common.Address{}, ErrBadSignature
+	}
+	approver := crypto.PubkeyToAddress(*pub)
+	if !slices.Contains(v.Approvers, approver) {
+		return common.Address{}, ErrBadSignature
+	}
+	return approver, nil
+}
+
+// Sign returns the personal_sign signature of body, as `cast wallet sign`
+// would produce it
+func Sign(body []byte, key *ecdsa.PrivateKey) (string, error) {
+	sig, err := crypto.Sign(accounts.TextHash(body), key)
+	if err != nil {
+		return "", err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(sig), nil
+}
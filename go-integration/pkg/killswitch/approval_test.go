@@ -0,0 +1,80 @@
+package killswitch
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVerify(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	second, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	verifier := &Verifier{
+		Approvers: []common.Address{crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(second.PublicKey)},
+		NetworkID: 11155111,
+		MaxAge:    15 * time.Minute,
+	}
+	now := time.Unix(1700000000, 0).UTC()
+
+	body := func(action string, networkID int64, issuedAt time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"action":%q,"network_id":%d,"halt_id":3,"reason":"key rotated","issued_at":%q}`,
+			action, networkID, issuedAt.Format(time.RFC3339)))
+	}
+	sign := func(body []byte, signer *ecdsa.PrivateKey) string {
+		sig, err := Sign(body, signer)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return sig
+	}
+
+	valid := body(ActionDeactivate, 11155111, now.Add(-time.Minute))
+	approval, approver, err := verifier.Verify(valid, sign(valid, second), ActionDeactivate, now)
+	if err != nil {
+		t.Fatalf("Expected a valid approval, got %v", err)
+	}
+	if approver != crypto.PubkeyToAddress(second.PublicKey) || approval.HaltID != 3 || approval.Reason != "key rotated" {
+		t.Errorf("Unexpected approval %+v from %s", approval, approver.Hex())
+	}
+
+	tampered := body(ActionDeactivate, 11155111, now)
+	stale := body(ActionDeactivate, 11155111, now.Add(-time.Hour))
+	wrongNetwork := body(ActionDeactivate, 1, now)
+	cases := []struct {
+		name      string
+		body      []byte
+		signature string
+		action    string
+		expected  error
+	}{
+		{"other signer", valid, sign(valid, other), ActionDeactivate, ErrBadSignature},
+		{"body changed after signing", tampered, sign(valid, key), ActionDeactivate, ErrBadSignature},
+		{"garbage signature", valid, "0x1234", ActionDeactivate, ErrBadSignature},
+		{"used for another action", valid, sign(valid, key), ActionActivate, ErrWrongAction},
+		{"stale", stale, sign(stale, key), ActionDeactivate, ErrStale},
+		{"other network", wrongNetwork, sign(wrongNetwork, key), ActionDeactivate, ErrWrongNetwork},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := verifier.Verify(tt.body, tt.signature, tt.action, now); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestParseApprovers(t *testing.T) {
+	approvers, err := ParseApprovers(" 0x00000000000000000000000000000000000000a1, 0x00000000000000000000000000000000000000a2 ,")
+	if err != nil || len(approvers) != 2 || approvers[1] != common.HexToAddress("0xa2") {
+		t.Errorf("Expected two approvers, got %v (%v)", approvers, err)
+	}
+	if _, err := ParseApprovers("ops"); err == nil {
+		t.Error("Expected a non-address to be rejected")
+	}
+}
@@ -0,0 +1,102 @@
+// Package kyc reports whether a freelancer has passed identity verification,
+// so large releases can wait until they have. Statuses come from a column of
+// the platform's users table or from an external provider's HTTP API.
+package kyc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is a freelancer's verification state
+type Status string
+
+// KYC statuses
+const (
+	StatusVerified Status = "verified" // releases may go ahead
+	StatusPending  Status = "pending"  // not yet submitted, or still being checked
+	StatusRejected Status = "rejected" // failed verification; held until it is redone
+)
+
+// ParseStatus maps the wording of a platform or provider to a Status.
+// Anything it doesn't recognise, including an empty value, is pending, so
+// an unexpected answer never clears a release.
+func ParseStatus(raw string) Status {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "verified", "approved", "cleared", "passed", "completed":
+		return StatusVerified
+	case "rejected", "declined", "denied", "failed":
+		return StatusRejected
+	default:
+		return StatusPending
+	}
+}
+
+// Checker looks up a freelancer's KYC status by platform user ID
+type Checker interface {
+	Status(ctx context.Context, userID int32) (Status, error)
+}
+
+// CheckerFunc adapts a function to Checker
+type CheckerFunc func(ctx context.Context, userID int32) (Status, error)
+
+// Status calls f
+func (f CheckerFunc) Status(ctx context.Context, userID int32) (Status, error) {
+	return f(ctx, userID)
+}
+
+// HTTP asks a provider's API at GET {URL}/{user_id}, which answers
+// {"status": "verified"}. APIKey, when set, is sent as a bearer token.
+type HTTP struct {
+	URL        string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewHTTP creates a checker for the provider at baseURL
+func NewHTTP(baseURL, apiKey string) *HTTP {
+	return &HTTP{
+		URL:    strings.TrimRight(baseURL, "/"),
+		APIKey: apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Status fetches userID's status. A user the provider doesn't know (404) is
+// pending.
+func (h *HTTP) Status(ctx context.Context, userID int32) (Status, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL+"/"+url.PathEscape(strconv.Itoa(int(userID))), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create KYC request: %w", err)
+	}
+	if h.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.APIKey)
+	}
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("KYC request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return StatusPending, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("KYC provider returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid KYC response: %w", err)
+	}
+	return ParseStatus(body.Status), nil
+}
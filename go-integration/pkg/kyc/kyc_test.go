@@ -0,0 +1,56 @@
+package kyc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	statuses := map[string]string{"/users/7": "approved", "/users/8": "in_review", "/users/9": "declined"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		status, ok := statuses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"status":"` + status + `"}`))
+	}))
+	defer server.Close()
+
+	checker := NewHTTP(server.URL+"/users/", "secret")
+	ctx := context.Background()
+	for userID, want := range map[int32]Status{7: StatusVerified, 8: StatusPending, 9: StatusRejected, 10: StatusPending} {
+		got, err := checker.Status(ctx, userID)
+		if err != nil {
+			t.Fatalf("Status(%d) = %v", userID, err)
+		}
+		if got != want {
+			t.Errorf("Status(%d) = %s, want %s", userID, got, want)
+		}
+	}
+
+	checker.APIKey = "wrong"
+	if _, err := checker.Status(ctx, 7); err == nil {
+		t.Error("Expected an error when the provider refuses the request")
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for raw, want := range map[string]Status{
+		"verified":  StatusVerified,
+		" Cleared ": StatusVerified,
+		"REJECTED":  StatusRejected,
+		"":          StatusPending,
+		"expired":   StatusPending,
+	} {
+		if got := ParseStatus(raw); got != want {
+			t.Errorf("ParseStatus(%q) = %s, want %s", raw, got, want)
+		}
+	}
+}
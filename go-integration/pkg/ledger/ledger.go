@@ -0,0 +1,183 @@
+// Package ledger builds the double-entry transactions behind the gateway's
+// reserve fund, fee overrides and wasted gas. Amounts are wei; a positive amount debits an account and a
+// negative amount credits it, so every transaction sums to zero.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Accounts
+const (
+	AccountPlatformWallet = "platform_wallet" // fees received by the contract owner (asset)
+	AccountFeeRevenue     = "fee_revenue"     // fees the platform keeps (income)
+	AccountReserveFund    = "reserve_fund"    // fees set aside to compensate users (liability)
+	AccountFeeRebates     = "fee_rebates"     // fees the contract took beyond a job's override, owed to its freelancer (liability)
+	AccountSignerWallet   = "signer_wallet"   // ETH the signers pay gas from (asset)
+	AccountGasWaste       = "gas_waste"       // gas paid for transactions that achieved nothing (expense)
+)
+
+var accounts = map[string]bool{
+	AccountPlatformWallet: true,
+	AccountFeeRevenue:     true,
+	AccountReserveFund:    true,
+	AccountFeeRebates:     true,
+	AccountSignerWallet:   true,
+	AccountGasWaste:       true,
+}
+
+// Transaction kinds
+const (
+	KindFeeAccrual    = "fee_accrual"    // a release paid the platform fee
+	KindReservePayout = "reserve_payout" // the reserve compensated a user
+	KindFeeOverride   = "fee_override"   // a release of a job with a fee override paid the contract's fee
+	KindGasWaste      = "gas_waste"      // a transaction's gas was spent without the operation taking effect
+)
+
+// Why gas was wasted, recorded as a gas waste transaction's memo
+const (
+	WasteReverted  = "reverted"  // the transaction was mined but failed, so its operation has to be sent again
+	WasteCancelled = "cancelled" // an empty transfer mined in place of a stuck transaction, whose operation never took effect
+)
+
+// MaxBasisPoints is 100% expressed in basis points
+const MaxBasisPoints = 10000
+
+// Entry moves an amount into (debit) or out of (credit) one account
+type Entry struct {
+	Account string
+	Amount  *big.Int
+}
+
+// Transaction is a balanced set of entries. Reference identifies what caused
+// it, such as a release transaction hash, and is unique per kind so replaying
+// the same event never posts twice.
+type Transaction struct {
+	Kind          string
+	Reference     string
+	ApplicationID *int32
+	Counterparty  string // recipient address of a payout
+	Memo          string
+	Actor         string
+	Entries       []Entry
+}
+
+// Validate checks the transaction uses known accounts and balances to zero
+func (t *Transaction) Validate() error {
+	if t.Kind == "" || t.Reference == "" {
+		return errors.New("ledger transaction needs a kind and a reference")
+	}
+	if len(t.Entries) < 2 {
+		return errors.New("ledger transaction needs at least two entries")
+	}
+
+	sum := new(big.Int)
+	for _, entry := range t.Entries {
+		if !accounts[entry.Account] {
+			return fmt.Errorf("unknown ledger account %q", entry.Account)
+		}
+		if entry.Amount == nil || entry.Amount.Sign() == 0 {
+			return fmt.Errorf("ledger entry for %s has no amount", entry.Account)
+		}
+		sum.Add(sum, entry.Amount)
+	}
+	if sum.Sign() != 0 {
+		return fmt.Errorf("ledger transaction is unbalanced by %s wei", sum)
+	}
+	return nil
+}
+
+// ReserveShare returns the slice of a fee that accrues to the reserve, rounded down
+func ReserveShare(fee *big.Int, basisPoints int64) *big.Int {
+	share := new(big.Int).Mul(fee, big.NewInt(basisPoints))
+	return share.Quo(share, big.NewInt(MaxBasisPoints))
+}
+
+// FeeAccrual records a platform fee received on release, setting aside
+// basisPoints of it in the reserve fund. It returns nil when the fee is zero.
+func FeeAccrual(applicationID int32, releaseTxHash string, fee *big.Int, basisPoints int64) *Transaction {
+	if fee.Sign() <= 0 {
+		return nil
+	}
+
+	share := ReserveShare(fee, basisPoints)
+	kept := new(big.Int).Sub(fee, share)
+
+	entries := []Entry{{Account: AccountPlatformWallet, Amount: new(big.Int).Set(fee)}}
+	if kept.Sign() > 0 {
+		entries = append(entries, Entry{Account: AccountFeeRevenue, Amount: kept.Neg(kept)})
+	}
+	if share.Sign() > 0 {
+		entries = append(entries, Entry{Account: AccountReserveFund, Amount: share.Neg(share)})
+	}
+
+	return &Transaction{
+		Kind:          KindFeeAccrual,
+		Reference:     releaseTxHash,
+		ApplicationID: &applicationID,
+		Entries:       entries,
+	}
+}
+
+// ReservePayout records compensation paid from the reserve out of the platform wallet
+func ReservePayout(reference, recipient string, amount *big.Int, applicationID *int32, memo, actor string) *Transaction {
+	return &Transaction{
+		Kind:          KindReservePayout,
+		Reference:     reference,
+		ApplicationID: applicationID,
+		Counterparty:  recipient,
+		Memo:          memo,
+		Actor:         actor,
+		Entries: []Entry{
+			{Account: AccountReserveFund, Amount: new(big.Int).Set(amount)},
+			{Account: AccountPlatformWallet, Amount: new(big.Int).Neg(amount)},
+		},
+	}
+}
+
+// FeeOverride records the part of a release's fee that a job's fee override
+// waives: the contract paid charged to the platform wallet, but only fee is
+// the platform's, so the rest is owed back to the freelancer. The reason and
+// approver of the override go in the memo and actor. It returns nil when
+// nothing is waived.
+func FeeOverride(applicationID int32, releaseTxHash, freelancer string, charged, fee *big.Int, reason, approver string) *Transaction {
+	rebate := new(big.Int).Sub(charged, fee)
+	if rebate.Sign() <= 0 {
+		return nil
+	}
+
+	return &Transaction{
+		Kind:          KindFeeOverride,
+		Reference:     releaseTxHash,
+		ApplicationID: &applicationID,
+		Counterparty:  freelancer,
+		Memo:          reason,
+		Actor:         approver,
+		Entries: []Entry{
+			{Account: AccountPlatformWallet, Amount: rebate},
+			{Account: AccountFeeRebates, Amount: new(big.Int).Neg(rebate)},
+		},
+	}
+}
+
+// GasWaste records the gas a signer paid for a transaction that achieved
+// nothing, such as one that reverted or cancelled another, so what retries
+// cost is kept apart from the gas of operations that took effect. It returns nil when no gas was paid.
+func GasWaste(applicationID int32, txHash string, cost *big.Int, reason string) *Transaction {
+	if cost.Sign() <= 0 {
+		return nil
+	}
+
+	return &Transaction{
+		Kind:          KindGasWaste,
+		Reference:     txHash,
+		ApplicationID: &applicationID,
+		Memo:          reason,
+		Entries: []Entry{
+			{Account: AccountGasWaste, Amount: new(big.Int).Set(cost)},
+			{Account: AccountSignerWallet, Amount: new(big.Int).Neg(cost)},
+		},
+	}
+}
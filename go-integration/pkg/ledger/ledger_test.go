@@ -0,0 +1,108 @@
+package ledger
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestReserveShare(t *testing.T) {
+	tests := []struct {
+		fee      int64
+		bps      int64
+		expected int64
+	}{
+		{1000, 2000, 200},
+		{999, 2500, 249}, // rounds down
+		{1000, 0, 0},
+		{1000, MaxBasisPoints, 1000},
+	}
+
+	for _, tt := range tests {
+		if share := ReserveShare(big.NewInt(tt.fee), tt.bps); share.Int64() != tt.expected {
+			t.Errorf("Expected %d bps of %d to be %d, got %s", tt.bps, tt.fee, tt.expected, share)
+		}
+	}
+}
+
+func TestFeeAccrualBalances(t *testing.T) {
+	for _, bps := range []int64{0, 1, 2000, MaxBasisPoints} {
+		tx := FeeAccrual(42, "0xabc", big.NewInt(999), bps)
+		if err := tx.Validate(); err != nil {
+			t.Errorf("Expected accrual at %d bps to balance, got %v", bps, err)
+		}
+	}
+
+	tx := FeeAccrual(42, "0xabc", big.NewInt(1000), 2000)
+	if len(tx.Entries) != 3 || tx.Entries[2].Account != AccountReserveFund || tx.Entries[2].Amount.Int64() != -200 {
+		t.Errorf("Expected 200 wei credited to the reserve, got %+v", tx.Entries)
+	}
+
+	if tx := FeeAccrual(42, "0xabc", big.NewInt(0), 2000); tx != nil {
+		t.Errorf("Expected no accrual for a zero fee, got %+v", tx)
+	}
+}
+
+func TestReservePayoutBalances(t *testing.T) {
+	tx := ReservePayout("0xdef", "0x0000000000000000000000000000000000000001", big.NewInt(500), nil, "exploit compensation", "ops")
+	if err := tx.Validate(); err != nil {
+		t.Errorf("Expected payout to balance, got %v", err)
+	}
+}
+
+func TestFeeOverrideBalances(t *testing.T) {
+	tx := FeeOverride(42, "0xabc", "0x0000000000000000000000000000000000000001", big.NewInt(500), big.NewInt(0), "launch promotion", "finance")
+	if err := tx.Validate(); err != nil {
+		t.Errorf("Expected fee override to balance, got %v", err)
+	}
+	if tx.Entries[1].Account != AccountFeeRebates || tx.Entries[1].Amount.Int64() != -500 || tx.Memo != "launch promotion" || tx.Actor != "finance" {
+		t.Errorf("Expected the whole fee owed back with the reason and approver, got %+v", tx)
+	}
+
+	if tx := FeeOverride(42, "0xabc", "", big.NewInt(500), big.NewInt(500), "", "finance"); tx != nil {
+		t.Errorf("Expected nothing recorded when the override waives nothing, got %+v", tx)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := map[string]Transaction{
+		"unbalanced": {Kind: KindReservePayout, Reference: "r", Entries: []Entry{
+			{Account: AccountReserveFund, Amount: big.NewInt(5)},
+			{Account: AccountPlatformWallet, Amount: big.NewInt(-4)},
+		}},
+		"unknown account": {Kind: KindReservePayout, Reference: "r", Entries: []Entry{
+			{Account: "cash", Amount: big.NewInt(5)},
+			{Account: AccountPlatformWallet, Amount: big.NewInt(-5)},
+		}},
+		"single entry": {Kind: KindReservePayout, Reference: "r", Entries: []Entry{
+			{Account: AccountReserveFund, Amount: big.NewInt(5)},
+		}},
+		"zero amount": {Kind: KindReservePayout, Reference: "r", Entries: []Entry{
+			{Account: AccountReserveFund, Amount: big.NewInt(0)},
+			{Account: AccountPlatformWallet, Amount: big.NewInt(0)},
+		}},
+		"no reference": {Kind: KindReservePayout, Entries: []Entry{
+			{Account: AccountReserveFund, Amount: big.NewInt(5)},
+			{Account: AccountPlatformWallet, Amount: big.NewInt(-5)},
+		}},
+	}
+
+	for name, tx := range tests {
+		if err := tx.Validate(); err == nil {
+			t.Errorf("Expected %s transaction to be rejected", name)
+		}
+	}
+}
+
+func TestGasWasteBalances(t *testing.T) {
+	tx := GasWaste(42, "0xabc", big.NewInt(21000), WasteReverted)
+	if err := tx.Validate(); err != nil {
+		t.Errorf("Expected gas waste to balance, got %v", err)
+	}
+	if tx.Entries[0].Account != AccountGasWaste || tx.Entries[1].Amount.Int64() != -21000 || tx.Memo != WasteReverted {
+		t.Errorf("Expected the gas charged to waste from the signer wallet, got %+v", tx)
+	}
+
+	if tx := GasWaste(42, "0xabc", big.NewInt(0), WasteReverted); tx != nil {
+		t.Errorf("Expected no transaction without gas paid, got %+v", tx)
+	}
+}
@@ -0,0 +1,344 @@
+// Package listquery parses the paging, sorting and filtering parameters
+// shared by list endpoints, checked against the fields each endpoint allows:
+//
+//	?limit=50&cursor=...&sort=-created_at&status[in]=open,approved&usd_amount[gte]=500
+//
+// Pages are keyset paged: a cursor holds the sort value and ID of the last
+// row of the previous page, so rows written while paging are neither skipped
+// nor repeated. Reports, which aggregate rather than page, take the same
+// filters plus a date Range. The SQL is generated from the parsed Params by
+// the database package.
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of a field's values
+type Kind int
+
+// Field kinds
+const (
+	String Kind = iota
+	Int
+	Time // RFC 3339 in query strings
+)
+
+// Op is a filter comparison, written field[op]=value; field=value is Eq
+type Op string
+
+// Filter operators
+const (
+	Eq  Op = "eq"
+	Ne  Op = "ne"
+	Lt  Op = "lt"
+	Lte Op = "lte"
+	Gt  Op = "gt"
+	Gte Op = "gte"
+	In  Op = "in" // comma-separated values
+)
+
+// Field is a field of a list that can be sorted or filtered on
+type Field struct {
+	Column   string // SQL column or expression; must be NOT NULL if Sortable
+	Kind     Kind
+	Sortable bool
+	Ops      []Op // filters allowed on the field; none when empty
+}
+
+// Spec is what one list endpoint allows
+type Spec struct {
+	Fields       map[string]Field
+	ID           string // a unique, sortable Int field that breaks ties between equal sort values
+	DefaultSort  string // e.g. "-created_at"; ID ascending when empty
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Sort is the field a page is ordered by, then by ID in the same direction
+type Sort struct {
+	Field  string
+	Column string
+	Desc   bool
+}
+
+// Condition is one parsed filter
+type Condition struct {
+	Field  string
+	Column string
+	Kind   Kind
+	Op     Op
+	Values []any // one value, or several for In
+}
+
+// Params is a parsed list query
+type Params struct {
+	Limit      int
+	Sort       Sort
+	ID         Sort  // the tie-breaker, ordered like Sort
+	After      []any // sort value and ID of the previous page's last row; nil on the first page
+	Conditions []Condition
+}
+
+// Reserved query parameters; every other parameter naming a field is a filter
+const (
+	paramLimit  = "limit"
+	paramCursor = "cursor"
+	paramSort   = "sort"
+)
+
+// cursor is the decoded form of a page cursor
+type cursor struct {
+	Sort  string            `json:"s"`
+	After []json.RawMessage `json:"a"`
+}
+
+// Parse reads limit, cursor, sort and filters from query. Parameters that
+// don't name a field are left to the endpoint, but field[op] for a field or
+// operator the spec doesn't allow is an error, as is a cursor issued for a
+// different sort.
+func (s Spec) Parse(query url.Values) (Params, error) {
+	params := Params{Limit: s.DefaultLimit}
+	if raw := query.Get(paramLimit); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > s.MaxLimit {
+			return Params{}, fmt.Errorf("limit must be between 1 and %d", s.MaxLimit)
+		}
+		params.Limit = limit
+	}
+
+	sortParam := query.Get(paramSort)
+	if sortParam == "" {
+		sortParam = s.DefaultSort
+	}
+	if sortParam == "" {
+		sortParam = s.ID
+	}
+	sort, err := s.parseSort(sortParam)
+	if err != nil {
+		return Params{}, err
+	}
+	params.Sort = sort
+	params.ID = Sort{Field: s.ID, Column: s.Fields[s.ID].Column, Desc: sort.Desc}
+
+	if raw := query.Get(paramCursor); raw != "" {
+		after, err := s.parseCursor(raw, sortParam)
+		if err != nil {
+			return Params{}, err
+		}
+		params.After = after
+	}
+
+	conditions, err := s.ParseFilters(query)
+	if err != nil {
+		return Params{}, err
+	}
+	params.Conditions = conditions
+	return params, nil
+}
+
+// ParseFilters reads only the filters from query, for endpoints such as
+// reports that aggregate rather than page. Like Parse, it leaves parameters
+// that don't name a field to the endpoint.
+func (s Spec) ParseFilters(query url.Values) ([]Condition, error) {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var conditions []Condition
+	for _, key := range keys {
+		if key == paramLimit || key == paramCursor || key == paramSort {
+			continue
+		}
+		name, op := key, Eq
+		if open := strings.IndexByte(key, '['); open > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:open], Op(key[open+1:len(key)-1])
+		} else if _, ok := s.Fields[name]; !ok {
+			continue
+		}
+		field, ok := s.Fields[name]
+		if !ok || len(field.Ops) == 0 {
+			return nil, fmt.Errorf("cannot filter on %q", name)
+		}
+		if !slices.Contains(field.Ops, op) {
+			return nil, fmt.Errorf("cannot filter %s with %q; use %s", name, op, joinOps(field.Ops))
+		}
+		for _, raw := range query[key] {
+			condition, err := s.condition(name, field, op, raw)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions, nil
+}
+
+// Filters reports whether the query filtered on field
+func (p Params) Filters(field string) bool {
+	return slices.ContainsFunc(p.Conditions, func(c Condition) bool { return c.Field == field })
+}
+
+// Where builds a condition on field, for a filter the endpoint applies when
+// the query has none of its own
+func (s Spec) Where(field string, op Op, values ...any) Condition {
+	f := s.Fields[field]
+	return Condition{Field: field, Column: f.Column, Kind: f.Kind, Op: op, Values: values}
+}
+
+// Arg is the condition's SQL argument: its value, or a typed slice for In
+func (c Condition) Arg() any {
+	if c.Op != In {
+		return c.Values[0]
+	}
+	switch c.Kind {
+	case Int:
+		return typedSlice[int64](c.Values)
+	case Time:
+		return typedSlice[time.Time](c.Values)
+	default:
+		return typedSlice[string](c.Values)
+	}
+}
+
+// Page trims items, fetched one past p.Limit, to the page and returns the
+// cursor of the next page, or "" if this is the last. value returns an
+// item's value of a field, which must be of the field's kind: string, int64
+// or time.Time.
+func Page[T any](items []T, p Params, value func(item T, field string) any) ([]T, string) {
+	if len(items) <= p.Limit {
+		return items, ""
+	}
+	items = items[:p.Limit]
+	last := items[len(items)-1]
+	return items, p.Cursor(value(last, p.Sort.Field), value(last, p.ID.Field))
+}
+
+// Cursor is the cursor of the page after a row with sortValue and id, for
+// lists that hand each row its own cursor rather than one per page
+func (p Params) Cursor(sortValue, id any) string {
+	sort := p.Sort.Field
+	if p.Sort.Desc {
+		sort = "-" + sort
+	}
+	c := cursor{Sort: sort}
+	for _, v := range []any{sortValue, id} {
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339Nano)
+		}
+		encoded, _ := json.Marshal(v)
+		c.After = append(c.After, encoded)
+	}
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func (s Spec) parseSort(raw string) (Sort, error) {
+	name, desc := strings.TrimPrefix(raw, "-"), strings.HasPrefix(raw, "-")
+	field, ok := s.Fields[name]
+	if !ok || !field.Sortable {
+		var sortable []string
+		for name, field := range s.Fields {
+			if field.Sortable {
+				sortable = append(sortable, name)
+			}
+		}
+		slices.Sort(sortable)
+		return Sort{}, fmt.Errorf("cannot sort by %q; use one of %s, prefixed with - for descending", name, strings.Join(sortable, ", "))
+	}
+	return Sort{Field: name, Column: field.Column, Desc: desc}, nil
+}
+
+func (s Spec) parseCursor(raw, sort string) ([]any, error) {
+	invalid := fmt.Errorf("invalid cursor: use a next cursor returned with the same sort")
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(decoded, &c); err != nil || len(c.After) != 2 {
+		return nil, invalid
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("cursor was issued for sort=%s, not sort=%s", c.Sort, sort)
+	}
+
+	kinds := []Kind{s.Fields[strings.TrimPrefix(sort, "-")].Kind, Int}
+	after := make([]any, 2)
+	for i, kind := range kinds {
+		var text string
+		if kind == Int {
+			var n json.Number
+			if err := json.Unmarshal(c.After[i], &n); err != nil {
+				return nil, invalid
+			}
+			text = n.String()
+		} else if err := json.Unmarshal(c.After[i], &text); err != nil {
+			return nil, invalid
+		}
+		if after[i], err = parseValue(kind, text); err != nil {
+			return nil, invalid
+		}
+	}
+	return after, nil
+}
+
+func (s Spec) condition(name string, field Field, op Op, raw string) (Condition, error) {
+	texts := []string{raw}
+	if op == In {
+		texts = strings.Split(raw, ",")
+	}
+	condition := Condition{Field: name, Column: field.Column, Kind: field.Kind, Op: op}
+	for _, text := range texts {
+		value, err := parseValue(field.Kind, strings.TrimSpace(text))
+		if err != nil {
+			return Condition{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		condition.Values = append(condition.Values, value)
+	}
+	return condition, nil
+}
+
+func parseValue(kind Kind, text string) (any, error) {
+	switch kind {
+	case Int:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", text)
+		}
+		return n, nil
+	case Time:
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an RFC 3339 time", text)
+		}
+		return t, nil
+	default:
+		return text, nil
+	}
+}
+
+func typedSlice[T any](values []any) []T {
+	typed := make([]T, len(values))
+	for i, v := range values {
+		typed[i] = v.(T)
+	}
+	return typed
+}
+
+func joinOps(ops []Op) string {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = string(op)
+	}
+	return strings.Join(names, ", ")
+}
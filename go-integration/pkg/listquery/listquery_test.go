@@ -0,0 +1,153 @@
+package listquery
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testSpec = Spec{
+	Fields: map[string]Field{
+		"id":         {Column: "id", Kind: Int, Sortable: true},
+		"status":     {Column: "status", Ops: []Op{Eq, In}},
+		"usd_amount": {Column: "usd_amount", Kind: Int, Sortable: true, Ops: []Op{Gte, Lte}},
+		"created_at": {Column: "created_at", Kind: Time, Sortable: true, Ops: []Op{Gte}},
+		"note":       {Column: "note"},
+	},
+	ID:           "id",
+	DefaultLimit: 2,
+	MaxLimit:     10,
+}
+
+func TestParse(t *testing.T) {
+	query, _ := url.ParseQuery("status[in]=open,cleared&usd_amount[gte]=500&created_at[gte]=2026-01-02T03:04:05Z&sort=-usd_amount&limit=5&from=2026-01-01")
+	params, err := testSpec.Parse(query)
+	if err != nil {
+		t.Fatalf("Parse = %v", err)
+	}
+	if params.Limit != 5 || params.Sort != (Sort{Field: "usd_amount", Column: "usd_amount", Desc: true}) || params.ID != (Sort{Field: "id", Column: "id", Desc: true}) {
+		t.Errorf("limit and sort = %d, %+v, %+v", params.Limit, params.Sort, params.ID)
+	}
+	if len(params.Conditions) != 3 || !params.Filters("status") || params.Filters("note") {
+		t.Fatalf("conditions = %+v", params.Conditions)
+	}
+	for _, c := range params.Conditions {
+		switch c.Field {
+		case "status":
+			if arg, ok := c.Arg().([]string); !ok || !slices.Equal(arg, []string{"open", "cleared"}) {
+				t.Errorf("status arg = %#v", c.Arg())
+			}
+		case "usd_amount":
+			if c.Op != Gte || c.Arg() != int64(500) {
+				t.Errorf("usd_amount = %+v", c)
+			}
+		case "created_at":
+			if c.Arg() != time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) {
+				t.Errorf("created_at arg = %v", c.Arg())
+			}
+		}
+	}
+
+	defaults, err := testSpec.Parse(url.Values{})
+	if err != nil || defaults.Limit != 2 || defaults.Sort.Field != "id" || defaults.Sort.Desc || defaults.After != nil {
+		t.Errorf("defaults = %+v, %v", defaults, err)
+	}
+
+	for _, raw := range []string{
+		"limit=11",
+		"limit=x",
+		"sort=note",
+		"sort=-missing",
+		"status[gt]=open",
+		"note[eq]=x",
+		"note=x",
+		"missing[eq]=1",
+		"usd_amount[gte]=lots",
+		"created_at[gte]=yesterday",
+		"cursor=not-a-cursor",
+	} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := testSpec.Parse(query); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	type row struct {
+		id        int64
+		createdAt time.Time
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC)
+	rows := []row{{1, base}, {2, base.Add(time.Hour)}, {3, base.Add(2 * time.Hour)}}
+	value := func(r row, field string) any {
+		if field == "created_at" {
+			return r.createdAt
+		}
+		return r.id
+	}
+
+	query := url.Values{"sort": {"-created_at"}}
+	params, _ := testSpec.Parse(query)
+	page, next := Page(rows, params, value)
+	if len(page) != 2 || next == "" {
+		t.Fatalf("Page = %d rows, cursor %q; want 2 and a cursor", len(page), next)
+	}
+
+	query.Set("cursor", next)
+	params, err := testSpec.Parse(query)
+	if err != nil {
+		t.Fatalf("Parse of the next cursor = %v", err)
+	}
+	if len(params.After) != 2 || params.After[0] != base.Add(time.Hour) || params.After[1] != int64(2) {
+		t.Errorf("after = %#v, want row 2's created_at and ID", params.After)
+	}
+
+	// A cursor only makes sense with the sort it was issued for
+	query.Set("sort", "created_at")
+	if _, err := testSpec.Parse(query); err == nil {
+		t.Error("Expected a cursor issued for another sort to be refused")
+	}
+
+	if page, next := Page(rows[:2], params, value); len(page) != 2 || next != "" {
+		t.Errorf("last page = %d rows, cursor %q; want 2 and no cursor", len(page), next)
+	}
+
+	// A row's own cursor is the one Page returns when it ends the page
+	if cursor := params.Cursor(base.Add(time.Hour), int64(2)); cursor != next {
+		t.Errorf("Cursor = %q, want %q", cursor, next)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	query, _ := url.ParseQuery("from=2025-06-01&to=2025-06-30&interval=week&status=open")
+	r, err := ParseRange(query)
+	if err != nil {
+		t.Fatalf("ParseRange = %v", err)
+	}
+	if want := (Range{From: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), Interval: "week"}); r != want {
+		t.Errorf("range = %+v, want %+v", r, want)
+	}
+	if r.Last() != time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("last day = %v", r.Last())
+	}
+
+	defaults, err := ParseRange(url.Values{})
+	if err != nil || defaults.Interval != "day" || defaults.To.Sub(defaults.From) != 30*24*time.Hour {
+		t.Errorf("defaults = %+v, %v", defaults, err)
+	}
+
+	for _, raw := range []string{"from=June", "to=2025-13-01", "from=2025-06-02&to=2025-06-01", "interval=year"} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := ParseRange(query); err == nil {
+			t.Errorf("ParseRange(%s) succeeded, want an error", raw)
+		}
+	}
+
+	// Reports read the filters alone and leave from, to and interval to ParseRange
+	conditions, err := testSpec.ParseFilters(query)
+	if err != nil || len(conditions) != 1 || conditions[0].Field != "status" {
+		t.Errorf("ParseFilters = %+v, %v", conditions, err)
+	}
+}
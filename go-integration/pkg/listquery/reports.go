@@ -0,0 +1,61 @@
+package listquery
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Intervals a report can group its rows by, as date_trunc fields
+var Intervals = []string{"day", "week", "month"}
+
+// Range is the period a report covers, read from from, to and interval:
+//
+//	?from=2025-06-01&to=2025-06-30&interval=week
+type Range struct {
+	From     time.Time
+	To       time.Time // exclusive: the day after the last day asked for
+	Interval string
+}
+
+// Last is the last day the range covers
+func (r Range) Last() time.Time {
+	return r.To.AddDate(0, 0, -1)
+}
+
+// ParseRange reads a report's range from query. Dates are YYYY-MM-DD, and
+// the range defaults to the last 30 days grouped by day.
+func ParseRange(query url.Values) (Range, error) {
+	to := time.Now().UTC()
+	if raw := query.Get("to"); raw != "" {
+		parsed, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid 'to' date, expected YYYY-MM-DD")
+		}
+		to = parsed.AddDate(0, 0, 1) // include the whole end day
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if raw := query.Get("from"); raw != "" {
+		parsed, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid 'from' date, expected YYYY-MM-DD")
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return Range{}, fmt.Errorf("'from' must be before 'to'")
+	}
+
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = Intervals[0]
+	}
+	if !slices.Contains(Intervals, interval) {
+		return Range{}, fmt.Errorf("invalid interval, expected day, week or month")
+	}
+
+	return Range{From: from, To: to, Interval: interval}, nil
+}
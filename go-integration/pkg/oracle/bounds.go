@@ -0,0 +1,36 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+)
+
+// Sanity bounds on the ETH/USD rate, in whole dollars. An answer outside them
+// comes from a misconfigured feed, such as another pair or unexpected
+// decimals, and would compute absurd escrow amounts.
+const (
+	MinETHUSD = 50
+	MaxETHUSD = 100_000
+)
+
+// PriceOutOfBoundsError is returned for an answer outside MinETHUSD and MaxETHUSD
+type PriceOutOfBoundsError struct {
+	Answer *big.Int // with amounts.PriceDecimals
+}
+
+func (e *PriceOutOfBoundsError) Error() string {
+	return fmt.Sprintf("ETH/USD answer %s ($%s) is outside the $%d-$%d sanity bounds",
+		e.Answer, amounts.Decimal(amounts.PriceUSD(e.Answer), 2, amounts.HalfUp), MinETHUSD, MaxETHUSD)
+}
+
+// CheckPrice returns a *PriceOutOfBoundsError unless answer, with
+// amounts.PriceDecimals, is within the sanity bounds
+func CheckPrice(answer *big.Int) error {
+	usd := amounts.PriceUSD(answer)
+	if usd.Cmp(big.NewRat(MinETHUSD, 1)) < 0 || usd.Cmp(big.NewRat(MaxETHUSD, 1)) > 0 {
+		return &PriceOutOfBoundsError{Answer: answer}
+	}
+	return nil
+}
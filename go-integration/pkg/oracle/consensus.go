@@ -0,0 +1,204 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+)
+
+// Source is an independent ETH/USD price a rate can be checked against
+type Source interface {
+	Name() string
+	// Price returns the rate with amounts.PriceDecimals
+	Price(ctx context.Context) (*big.Int, error)
+}
+
+// SourcePrice is what one source answered during a consensus check
+type SourcePrice struct {
+	Source           string   `json:"source"`
+	Price            *big.Int `json:"price,omitempty"`
+	DeviationPercent *float64 `json:"deviation_percent,omitempty"` // from the reference rate
+	Agrees           bool     `json:"agrees"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// Consensus is how many independent sources agree with a reference rate
+type Consensus struct {
+	Reference        *big.Int
+	TolerancePercent float64
+	Prices           []SourcePrice
+	Agreeing         int // sources within the tolerance, counting the reference
+}
+
+// CheckConsensus asks every source for its rate at once and compares each
+// with reference. A source that fails or answers outside the sanity bounds
+// doesn't agree.
+func CheckConsensus(ctx context.Context, reference *big.Int, sources []Source, tolerancePercent float64) *Consensus {
+	c := &Consensus{Reference: reference, TolerancePercent: tolerancePercent, Prices: make([]SourcePrice, len(sources)), Agreeing: 1}
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			quote := SourcePrice{Source: source.Name()}
+			price, err := source.Price(ctx)
+			if err == nil {
+				err = CheckPrice(price)
+			}
+			if err != nil {
+				quote.Error = err.Error()
+			} else {
+				deviation := Deviation(price, reference)
+				quote.Price = price
+				quote.DeviationPercent = &deviation
+				quote.Agrees = deviation <= tolerancePercent
+			}
+			c.Prices[i] = quote
+		}()
+	}
+	wg.Wait()
+
+	for _, quote := range c.Prices {
+		if quote.Agrees {
+			c.Agreeing++
+		}
+	}
+	return c
+}
+
+// Reached reports whether at least minSources agree, counting the reference
+func (c *Consensus) Reached(minSources int) bool {
+	return c.Agreeing >= minSources
+}
+
+// String describes each source's answer, e.g. for a reviewer
+func (c *Consensus) String() string {
+	parts := make([]string, 0, len(c.Prices))
+	for _, quote := range c.Prices {
+		if quote.Error != "" {
+			parts = append(parts, fmt.Sprintf("%s failed: %s", quote.Source, quote.Error))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s $%s (%.2f%% off)", quote.Source, amounts.Decimal(amounts.PriceUSD(quote.Price), 2, amounts.HalfUp), *quote.DeviationPercent))
+	}
+	return fmt.Sprintf("%d of %d sources agree within %.2f%% of the contract's $%s; %s",
+		c.Agreeing, len(c.Prices)+1, c.TolerancePercent, amounts.Decimal(amounts.PriceUSD(c.Reference), 2, amounts.HalfUp), strings.Join(parts, ", "))
+}
+
+// FeedSource checks against a Chainlink feed's latest round, refusing one
+// older than MaxAge
+type FeedSource struct {
+	Label  string
+	Latest func(ctx context.Context) (*RoundData, error)
+	MaxAge time.Duration
+}
+
+// Name returns the source's label
+func (f *FeedSource) Name() string {
+	return f.Label
+}
+
+// Price returns the latest round's answer
+func (f *FeedSource) Price(ctx context.Context) (*big.Int, error) {
+	round, err := f.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if f.MaxAge > 0 && round.Age() > f.MaxAge {
+		return nil, fmt.Errorf("latest round is %s old, limit is %s", round.Age().Round(time.Second), f.MaxAge)
+	}
+	return round.Answer, nil
+}
+
+// HTTPSource reads an off-chain ETH/USD price from a JSON endpoint. Field is
+// the dotted path to the price, e.g. "data.amount" for Coinbase's
+// https://api.coinbase.com/v2/prices/ETH-USD/spot; it may be a number or a
+// decimal string.
+type HTTPSource struct {
+	URL        string
+	Field      string
+	HTTPClient *http.Client
+}
+
+// NewHTTPSource creates a source reading field from url
+func NewHTTPSource(url, field string) *HTTPSource {
+	return &HTTPSource{
+		URL:   url,
+		Field: field,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Name returns the endpoint's host
+func (h *HTTPSource) Name() string {
+	host := h.URL
+	if _, rest, ok := strings.Cut(host, "://"); ok {
+		host = rest
+	}
+	host, _, _ = strings.Cut(host, "/")
+	return host
+}
+
+// Price fetches the endpoint and returns the price at Field
+func (h *HTTPSource) Price(ctx context.Context) (*big.Int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create price request: %w", err)
+	}
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("price request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price source returned status %d", resp.StatusCode)
+	}
+
+	var body interface{}
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid price response: %w", err)
+	}
+	return priceAt(body, h.Field)
+}
+
+// priceAt reads the decimal price at a dotted path of a decoded JSON body
+func priceAt(body interface{}, path string) (*big.Int, error) {
+	value := body
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("price response has no %s", path)
+		}
+		if value, ok = object[key]; !ok {
+			return nil, fmt.Errorf("price response has no %s", path)
+		}
+	}
+
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = v
+	default:
+		return nil, fmt.Errorf("price response's %s is not a number", path)
+	}
+	price, ok := new(big.Rat).SetString(text)
+	if !ok || price.Sign() <= 0 {
+		return nil, fmt.Errorf("price response's %s must be a positive number, got %q", path, text)
+	}
+	return amounts.Scale(price, amounts.PriceDecimals, amounts.HalfUp), nil
+}
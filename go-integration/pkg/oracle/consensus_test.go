@@ -0,0 +1,97 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedSource struct {
+	name  string
+	price int64
+	err   error
+}
+
+func (s fixedSource) Name() string { return s.name }
+
+func (s fixedSource) Price(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(s.price), s.err
+}
+
+func TestCheckConsensus(t *testing.T) {
+	reference := big.NewInt(300000000000)
+	sources := []Source{
+		fixedSource{name: "fallback", price: 301500000000},               // 0.5% off
+		fixedSource{name: "exchange", price: 315000000000},               // 5% off
+		fixedSource{name: "down", err: errors.New("connection refused")}, // unreachable
+		fixedSource{name: "broken", price: 10_00000000},                  // outside the sanity bounds
+	}
+
+	consensus := CheckConsensus(context.Background(), reference, sources, 1)
+	if consensus.Agreeing != 2 || !consensus.Reached(2) || consensus.Reached(3) {
+		t.Fatalf("Agreeing = %d, want the contract's rate and the fallback", consensus.Agreeing)
+	}
+	if quote := consensus.Prices[0]; !quote.Agrees || quote.DeviationPercent == nil || *quote.DeviationPercent != 0.5 {
+		t.Errorf("Expected the fallback to agree 0.5%% off, got %+v", quote)
+	}
+	if quote := consensus.Prices[1]; quote.Agrees {
+		t.Errorf("Expected the exchange 5%% off to disagree, got %+v", quote)
+	}
+	for _, quote := range consensus.Prices[2:] {
+		if quote.Agrees || quote.Error == "" {
+			t.Errorf("Expected %s to fail, got %+v", quote.Source, quote)
+		}
+	}
+
+	detail := consensus.String()
+	for _, want := range []string{"2 of 5 sources agree within 1.00% of the contract's $3000.00", "exchange $3150.00 (5.00% off)", "down failed: connection refused"} {
+		if !strings.Contains(detail, want) {
+			t.Errorf("Detail %q is missing %q", detail, want)
+		}
+	}
+}
+
+func TestFeedSourceMaxAge(t *testing.T) {
+	source := &FeedSource{Label: "fallback", MaxAge: time.Hour, Latest: func(ctx context.Context) (*RoundData, error) {
+		return &RoundData{Answer: big.NewInt(300000000000), UpdatedAt: time.Now().Add(-2 * time.Hour)}, nil
+	}}
+	if _, err := source.Price(context.Background()); err == nil {
+		t.Errorf("Expected a round older than MaxAge to be refused")
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spot":
+			w.Write([]byte(`{"data": {"base": "ETH", "currency": "USD", "amount": "3012.345678915"}}`))
+		case "/number":
+			w.Write([]byte(`{"ethereum": {"usd": 3012.5}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	if price, err := NewHTTPSource(server.URL+"/spot", "data.amount").Price(ctx); err != nil || price.Int64() != 301234567892 {
+		t.Errorf("Price = %v, %v; want 301234567892", price, err)
+	}
+	if price, err := NewHTTPSource(server.URL+"/number", "ethereum.usd").Price(ctx); err != nil || price.Int64() != 301250000000 {
+		t.Errorf("Price = %v, %v; want 301250000000", price, err)
+	}
+	if _, err := NewHTTPSource(server.URL+"/spot", "data.price").Price(ctx); err == nil {
+		t.Errorf("Expected a missing field to fail")
+	}
+	if _, err := NewHTTPSource(server.URL+"/missing", "data.amount").Price(ctx); err == nil {
+		t.Errorf("Expected a 404 to fail")
+	}
+	if name := NewHTTPSource("https://api.coinbase.com/v2/prices/ETH-USD/spot", "data.amount").Name(); name != "api.coinbase.com" {
+		t.Errorf("Name = %q, want the host", name)
+	}
+}
@@ -0,0 +1,142 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+)
+
+// aggregatorV3ABI is the subset of Chainlink's AggregatorV3Interface used by the gateway
+const aggregatorV3ABI = `[
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"description","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"latestRoundData","stateMutability":"view","inputs":[],"outputs":[
+		{"name":"roundId","type":"uint80"},{"name":"answer","type":"int256"},{"name":"startedAt","type":"uint256"},
+		{"name":"updatedAt","type":"uint256"},{"name":"answeredInRound","type":"uint80"}]},
+	{"type":"function","name":"getRoundData","stateMutability":"view","inputs":[{"name":"_roundId","type":"uint80"}],"outputs":[
+		{"name":"roundId","type":"uint80"},{"name":"answer","type":"int256"},{"name":"startedAt","type":"uint256"},
+		{"name":"updatedAt","type":"uint256"},{"name":"answeredInRound","type":"uint80"}]}
+]`
+
+// RoundData is a single Chainlink price round
+type RoundData struct {
+	RoundID         *big.Int
+	Answer          *big.Int
+	StartedAt       time.Time
+	UpdatedAt       time.Time
+	AnsweredInRound *big.Int
+}
+
+// Age returns how long ago the round was updated
+func (r *RoundData) Age() time.Duration {
+	return time.Since(r.UpdatedAt)
+}
+
+// Feed reads a Chainlink price feed contract. Answers are rescaled to
+// amounts.PriceDecimals whatever decimals the feed reports.
+type Feed struct {
+	address  common.Address
+	contract *bind.BoundContract
+
+	mu       sync.Mutex
+	decimals uint8
+	phase    uint16 // aggregator phase decimals was read in; 0 before the first read
+}
+
+// NewFeed binds to the aggregator at address
+func NewFeed(address common.Address, backend bind.ContractBackend) (*Feed, error) {
+	parsed, err := abi.JSON(strings.NewReader(aggregatorV3ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse aggregator ABI: %w", err)
+	}
+
+	return &Feed{
+		address:  address,
+		contract: bind.NewBoundContract(address, parsed, backend, backend, backend),
+		decimals: amounts.PriceDecimals,
+	}, nil
+}
+
+// Address returns the aggregator contract address
+func (f *Feed) Address() common.Address {
+	return f.address
+}
+
+// Decimals returns the number of decimals in the feed's answers
+func (f *Feed) Decimals(ctx context.Context) (uint8, error) {
+	var out []interface{}
+	if err := f.contract.Call(&bind.CallOpts{Context: ctx}, &out, "decimals"); err != nil {
+		return 0, fmt.Errorf("failed to read feed decimals: %w", err)
+	}
+	return *abi.ConvertType(out[0], new(uint8)).(*uint8), nil
+}
+
+// LoadDecimals reads the feed's decimals and rescales answers with them from
+// now on
+func (f *Feed) LoadDecimals(ctx context.Context) (uint8, error) {
+	decimals, err := f.Decimals(ctx)
+	if err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	f.decimals = decimals
+	f.mu.Unlock()
+	return decimals, nil
+}
+
+// LatestRound returns the most recent price round. Decimals can only change
+// with a new aggregator, so they are read again when the round's phase differs
+// from the one they were read in.
+func (f *Feed) LatestRound(ctx context.Context) (*RoundData, error) {
+	var out []interface{}
+	if err := f.contract.Call(&bind.CallOpts{Context: ctx}, &out, "latestRoundData"); err != nil {
+		return nil, fmt.Errorf("failed to read latest round: %w", err)
+	}
+	round := unpackRound(out)
+
+	phase, _ := SplitRoundID(round.RoundID)
+	f.mu.Lock()
+	known := f.phase == phase
+	f.mu.Unlock()
+	if !known {
+		if _, err := f.LoadDecimals(ctx); err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		f.phase = phase
+		f.mu.Unlock()
+	}
+	return f.normalize(round), nil
+}
+
+// normalize rescales round's answer from the feed's decimals
+func (f *Feed) normalize(round *RoundData) *RoundData {
+	f.mu.Lock()
+	decimals := f.decimals
+	f.mu.Unlock()
+	round.Answer = amounts.NormalizePrice(round.Answer, decimals)
+	return round
+}
+
+// unpackRound converts the raw outputs of latestRoundData/getRoundData
+func unpackRound(out []interface{}) *RoundData {
+	startedAt := *abi.ConvertType(out[2], new(*big.Int)).(**big.Int)
+	updatedAt := *abi.ConvertType(out[3], new(*big.Int)).(**big.Int)
+
+	return &RoundData{
+		RoundID:         *abi.ConvertType(out[0], new(*big.Int)).(**big.Int),
+		Answer:          *abi.ConvertType(out[1], new(*big.Int)).(**big.Int),
+		StartedAt:       time.Unix(startedAt.Int64(), 0).UTC(),
+		UpdatedAt:       time.Unix(updatedAt.Int64(), 0).UTC(),
+		AnsweredInRound: *abi.ConvertType(out[4], new(*big.Int)).(**big.Int),
+	}
+}
@@ -0,0 +1,56 @@
+package oracle
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestUnpackRound(t *testing.T) {
+	updated := time.Now().Add(-10 * time.Minute).Unix()
+	round := unpackRound([]interface{}{
+		big.NewInt(42),
+		big.NewInt(300000000000),
+		big.NewInt(updated - 5),
+		big.NewInt(updated),
+		big.NewInt(42),
+	})
+
+	if round.RoundID.Int64() != 42 {
+		t.Errorf("Expected round 42, got %s", round.RoundID)
+	}
+	if round.Answer.Cmp(big.NewInt(300000000000)) != 0 {
+		t.Errorf("Expected answer 300000000000, got %s", round.Answer)
+	}
+	if round.UpdatedAt.Unix() != updated {
+		t.Errorf("Expected updatedAt %d, got %d", updated, round.UpdatedAt.Unix())
+	}
+	if age := round.Age(); age < 9*time.Minute || age > 11*time.Minute {
+		t.Errorf("Expected age around 10m, got %s", age)
+	}
+}
+
+func TestCheckPrice(t *testing.T) {
+	cases := []struct {
+		answer int64
+		ok     bool
+	}{
+		{3000_00000000, true},
+		{50_00000000, true},
+		{100_000_00000000, true},
+		{49_99999999, false},
+		{100_000_00000001, false},
+		{3000, false}, // an answer with no decimals
+	}
+	for _, c := range cases {
+		err := CheckPrice(big.NewInt(c.answer))
+		if (err == nil) != c.ok {
+			t.Errorf("CheckPrice(%d): expected ok=%v, got %v", c.answer, c.ok, err)
+		}
+		var bounds *PriceOutOfBoundsError
+		if err != nil && !errors.As(err, &bounds) {
+			t.Errorf("Expected a *PriceOutOfBoundsError, got %T", err)
+		}
+	}
+}
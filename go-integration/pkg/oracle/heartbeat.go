@@ -0,0 +1,65 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Heartbeat is the update policy of a Chainlink feed: a new round at least
+// every Interval, and sooner whenever the answer moves by DeviationPercent
+type Heartbeat struct {
+	Interval         time.Duration
+	DeviationPercent float64
+	Grace            time.Duration // allowance for rounds still being published
+}
+
+// Assessment is the result of checking a round against a Heartbeat
+type Assessment struct {
+	Staleness        time.Duration
+	DeviationPercent *float64 // from the reference round; nil without one
+	Stalled          bool
+	Reason           string
+}
+
+// Assess reports whether round shows the feed has stalled at now. A feed has
+// stalled when its latest answer is outside the sanity bounds, when its latest
+// round is older than the heartbeat interval, or when a reference feed has
+// moved beyond the deviation threshold from it and the feed has not published
+// a round since.
+func (h Heartbeat) Assess(round, reference *RoundData, now time.Time) Assessment {
+	a := Assessment{Staleness: now.Sub(round.UpdatedAt)}
+	if a.Staleness < 0 {
+		a.Staleness = 0
+	}
+
+	if reference != nil && reference.Answer.Sign() > 0 {
+		deviation := Deviation(round.Answer, reference.Answer)
+		a.DeviationPercent = &deviation
+	}
+
+	bounds := CheckPrice(round.Answer)
+	switch {
+	case round.Answer.Sign() <= 0:
+		a.Stalled = true
+		a.Reason = fmt.Sprintf("round %s has non-positive answer %s", round.RoundID, round.Answer)
+	case bounds != nil:
+		a.Stalled = true
+		a.Reason = fmt.Sprintf("round %s: %v", round.RoundID, bounds)
+	case a.Staleness > h.Interval+h.Grace:
+		a.Stalled = true
+		a.Reason = fmt.Sprintf("no round for %s, heartbeat is %s", a.Staleness.Round(time.Second), h.Interval)
+	case a.DeviationPercent != nil && *a.DeviationPercent > h.DeviationPercent &&
+		reference.UpdatedAt.Sub(round.UpdatedAt) > h.Grace:
+		a.Stalled = true
+		a.Reason = fmt.Sprintf("answer is %.2f%% from the reference feed, beyond the %.2f%% deviation threshold", *a.DeviationPercent, h.DeviationPercent)
+	}
+	return a
+}
+
+// Deviation returns how far answer is from reference, in percent of reference
+func Deviation(answer, reference *big.Int) float64 {
+	diff := new(big.Int).Sub(answer, reference)
+	percent, _ := new(big.Rat).SetFrac(new(big.Int).Mul(diff.Abs(diff), big.NewInt(100)), reference).Float64()
+	return percent
+}
@@ -0,0 +1,61 @@
+package oracle
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatAssess(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	heartbeat := Heartbeat{Interval: time.Hour, DeviationPercent: 0.5, Grace: 5 * time.Minute}
+	round := func(answer int64, age time.Duration) *RoundData {
+		return &RoundData{RoundID: big.NewInt(1), Answer: big.NewInt(answer), UpdatedAt: now.Add(-age)}
+	}
+
+	cases := []struct {
+		name      string
+		round     *RoundData
+		reference *RoundData
+		stalled   bool
+		reason    string
+	}{
+		{"fresh", round(300000000000, 10*time.Minute), nil, false, ""},
+		{"within grace", round(300000000000, 64*time.Minute), nil, false, ""},
+		{"past heartbeat", round(300000000000, 2*time.Hour), nil, true, "heartbeat is 1h0m0s"},
+		{"non-positive answer", round(0, time.Minute), nil, true, "non-positive"},
+		{"below sanity bounds", round(30_00000000, time.Minute), nil, true, "sanity bounds"},
+		{"above sanity bounds", round(3000_0000000000, time.Minute), nil, true, "sanity bounds"},
+		{"deviation within threshold", round(300000000000, 30*time.Minute), round(301000000000, time.Minute), false, ""},
+		{"deviated without update", round(300000000000, 30*time.Minute), round(310000000000, time.Minute), true, "deviation threshold"},
+		{"deviated but reference is older", round(300000000000, time.Minute), round(310000000000, 30*time.Minute), false, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := heartbeat.Assess(c.round, c.reference, now)
+			if a.Stalled != c.stalled {
+				t.Errorf("Expected stalled=%v, got %v (%s)", c.stalled, a.Stalled, a.Reason)
+			}
+			if !strings.Contains(a.Reason, c.reason) {
+				t.Errorf("Expected reason containing %q, got %q", c.reason, a.Reason)
+			}
+			if a.Staleness != now.Sub(c.round.UpdatedAt) {
+				t.Errorf("Expected staleness %s, got %s", now.Sub(c.round.UpdatedAt), a.Staleness)
+			}
+			if (c.reference != nil) != (a.DeviationPercent != nil) {
+				t.Errorf("Expected a deviation only with a reference round")
+			}
+		})
+	}
+}
+
+func TestDeviation(t *testing.T) {
+	if got := Deviation(big.NewInt(297), big.NewInt(300)); got != 1 {
+		t.Errorf("Expected 1%%, got %v", got)
+	}
+	if got := Deviation(big.NewInt(303), big.NewInt(300)); got != 1 {
+		t.Errorf("Expected 1%%, got %v", got)
+	}
+}
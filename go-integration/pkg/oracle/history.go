@@ -0,0 +1,107 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+)
+
+// ErrBeforeCurrentPhase is returned for timestamps older than the first round
+// of the feed's current aggregator phase, which getRoundData cannot reach
+var ErrBeforeCurrentPhase = errors.New("timestamp precedes the feed's current phase")
+
+// phaseOffset is the bit position of the phase ID inside a proxy round ID
+const phaseOffset = 64
+
+// RoundAtBlock returns the round latestRoundData reported at a block, which is
+// the rate the escrow contract converted with in that block. The answer is
+// rescaled with the decimals the feed had at that block. Blocks older than
+// the node's state history need an archive node.
+func (f *Feed) RoundAtBlock(ctx context.Context, blockNumber uint64) (*RoundData, error) {
+	var out []interface{}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(blockNumber)}
+	if err := f.contract.Call(opts, &out, "latestRoundData"); err != nil {
+		return nil, fmt.Errorf("failed to read round at block %d: %w", blockNumber, err)
+	}
+	round := unpackRound(out)
+
+	var decimals []interface{}
+	if err := f.contract.Call(opts, &decimals, "decimals"); err != nil {
+		return nil, fmt.Errorf("failed to read feed decimals at block %d: %w", blockNumber, err)
+	}
+	round.Answer = amounts.NormalizePrice(round.Answer, *abi.ConvertType(decimals[0], new(uint8)).(*uint8))
+	return round, nil
+}
+
+// Round returns a round by its proxy round ID
+func (f *Feed) Round(ctx context.Context, roundID *big.Int) (*RoundData, error) {
+	var out []interface{}
+	if err := f.contract.Call(&bind.CallOpts{Context: ctx}, &out, "getRoundData", roundID); err != nil {
+		return nil, fmt.Errorf("failed to read round %s: %w", roundID, err)
+	}
+	return f.normalize(unpackRound(out)), nil
+}
+
+// RoundAtTime returns the last round updated at or before at, searching the
+// rounds of the feed's current phase
+func (f *Feed) RoundAtTime(ctx context.Context, at time.Time) (*RoundData, error) {
+	latest, err := f.LatestRound(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !latest.UpdatedAt.After(at) {
+		return latest, nil
+	}
+
+	phase, last := SplitRoundID(latest.RoundID)
+	return searchRound(1, last, at, func(aggregatorRound uint64) (*RoundData, error) {
+		return f.Round(ctx, RoundID(phase, aggregatorRound))
+	})
+}
+
+// RoundID combines a phase and an aggregator round into a proxy round ID
+func RoundID(phase uint16, aggregatorRound uint64) *big.Int {
+	id := new(big.Int).Lsh(big.NewInt(int64(phase)), phaseOffset)
+	return id.Or(id, new(big.Int).SetUint64(aggregatorRound))
+}
+
+// SplitRoundID separates a proxy round ID into its phase and aggregator round
+func SplitRoundID(roundID *big.Int) (uint16, uint64) {
+	phase := new(big.Int).Rsh(roundID, phaseOffset)
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), phaseOffset), big.NewInt(1))
+	return uint16(phase.Uint64()), new(big.Int).And(roundID, mask).Uint64()
+}
+
+// searchRound binary searches aggregator rounds lo..hi, whose update times
+// increase, for the last one updated at or before at
+func searchRound(lo, hi uint64, at time.Time, get func(uint64) (*RoundData, error)) (*RoundData, error) {
+	var found *RoundData
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+		round, err := get(mid)
+		if err != nil {
+			return nil, err
+		}
+		if round.UpdatedAt.After(at) {
+			if mid == 0 {
+				break
+			}
+			hi = mid - 1
+		} else {
+			found = round
+			lo = mid + 1
+		}
+	}
+
+	if found == nil {
+		return nil, ErrBeforeCurrentPhase
+	}
+	return found, nil
+}
@@ -0,0 +1,62 @@
+package oracle
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestRoundIDRoundTrip(t *testing.T) {
+	id := RoundID(3, 12345)
+	expected, _ := new(big.Int).SetString("55340232221128667193", 10) // 3<<64 | 12345
+	if id.Cmp(expected) != 0 {
+		t.Errorf("Expected round ID %s, got %s", expected, id)
+	}
+
+	phase, round := SplitRoundID(id)
+	if phase != 3 || round != 12345 {
+		t.Errorf("Expected phase 3 round 12345, got phase %d round %d", phase, round)
+	}
+}
+
+func TestSearchRound(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	// Rounds 1..10 updated every 10 minutes
+	calls := 0
+	get := func(n uint64) (*RoundData, error) {
+		calls++
+		return &RoundData{RoundID: big.NewInt(int64(n)), UpdatedAt: base.Add(time.Duration(n-1) * 10 * time.Minute)}, nil
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected int64
+	}{
+		{"exact update", base.Add(30 * time.Minute), 4},
+		{"between updates", base.Add(35 * time.Minute), 4},
+		{"first round", base, 1},
+		{"last round", base.Add(time.Hour), 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			round, err := searchRound(1, 10, tt.at, get)
+			if err != nil {
+				t.Fatalf("Expected a round, got %v", err)
+			}
+			if round.RoundID.Int64() != tt.expected {
+				t.Errorf("Expected round %d, got %s", tt.expected, round.RoundID)
+			}
+		})
+	}
+
+	if calls > 4*5 {
+		t.Errorf("Expected a binary search, made %d lookups", calls)
+	}
+
+	if _, err := searchRound(1, 10, base.Add(-time.Second), get); !errors.Is(err, ErrBeforeCurrentPhase) {
+		t.Errorf("Expected ErrBeforeCurrentPhase, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// ErrNoRounds is returned by TWAP when there are no rounds to average
+var ErrNoRounds = errors.New("no price rounds to average")
+
+// RecentRounds returns up to n of the latest rounds, oldest first. Rounds of
+// earlier aggregator phases are not read, so fewer than n may be returned
+// shortly after a phase change.
+func (f *Feed) RecentRounds(ctx context.Context, n int) ([]*RoundData, error) {
+	latest, err := f.LatestRound(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	phase, last := SplitRoundID(latest.RoundID)
+	rounds := []*RoundData{latest}
+	for round := last - 1; round >= 1 && len(rounds) < n; round-- {
+		data, err := f.Round(ctx, RoundID(phase, round))
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, data)
+	}
+
+	for i, j := 0, len(rounds)-1; i < j; i, j = i+1, j-1 {
+		rounds[i], rounds[j] = rounds[j], rounds[i]
+	}
+	return rounds, nil
+}
+
+// TWAP returns the time-weighted average answer of rounds, oldest first. Each
+// answer is weighted by how long it stood: until the next round's update, or
+// until now for the latest. The result is rounded down. If no time has passed
+// since the first update the latest answer is returned.
+func TWAP(rounds []*RoundData, now time.Time) (*big.Int, error) {
+	if len(rounds) == 0 {
+		return nil, ErrNoRounds
+	}
+
+	sum := new(big.Int)
+	var total int64
+	for i, round := range rounds {
+		end := now
+		if i+1 < len(rounds) {
+			end = rounds[i+1].UpdatedAt
+		}
+		seconds := int64(end.Sub(round.UpdatedAt) / time.Second)
+		if seconds <= 0 {
+			continue
+		}
+		sum.Add(sum, new(big.Int).Mul(round.Answer, big.NewInt(seconds)))
+		total += seconds
+	}
+
+	if total == 0 {
+		return new(big.Int).Set(rounds[len(rounds)-1].Answer), nil
+	}
+	return sum.Quo(sum, big.NewInt(total)), nil
+}
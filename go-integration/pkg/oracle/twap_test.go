@@ -0,0 +1,40 @@
+package oracle
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestTWAP(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	round := func(answer int64, age time.Duration) *RoundData {
+		return &RoundData{RoundID: big.NewInt(1), Answer: big.NewInt(answer), UpdatedAt: now.Add(-age)}
+	}
+
+	// $3,000 for 50 minutes, then a $3,600 spike for the last 10
+	spike := []*RoundData{round(300000000000, time.Hour), round(360000000000, 10*time.Minute)}
+	twap, err := TWAP(spike, now)
+	if err != nil {
+		t.Fatalf("Failed to average rounds: %v", err)
+	}
+	if twap.Int64() != 310000000000 {
+		t.Errorf("Expected $3,100, got %s", twap)
+	}
+
+	// A round updated at the same time as the next carries no weight
+	same := []*RoundData{round(100000000000, 20*time.Minute), round(300000000000, 20*time.Minute)}
+	if twap, _ := TWAP(same, now); twap.Int64() != 300000000000 {
+		t.Errorf("Expected the superseded round ignored, got %s", twap)
+	}
+
+	// Nothing has stood yet: the latest answer
+	if twap, _ := TWAP([]*RoundData{round(300000000000, 0)}, now); twap.Int64() != 300000000000 {
+		t.Errorf("Expected the latest answer, got %s", twap)
+	}
+
+	if _, err := TWAP(nil, now); !errors.Is(err, ErrNoRounds) {
+		t.Errorf("Expected ErrNoRounds, got %v", err)
+	}
+}
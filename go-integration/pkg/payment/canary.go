@@ -0,0 +1,108 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// errCanaryNotSent releases the nonce of a canary transfer that wasn't sent
+var errCanaryNotSent = errors.New("canary transfer not sent")
+
+// CanaryResult is what a canary cycle found
+type CanaryResult struct {
+	Nonce       uint64             // the signer's pending nonce the transfer was built at
+	GasEstimate uint64             // gas the node estimated for the transfer
+	TxHash      string             // of the signed transfer
+	Sent        *TransactionResult // nil unless the transfer was sent
+}
+
+// Canary signs a zero-value transfer from the gateway's account to itself at
+// its pending nonce and has the node estimate it. That exercises the signer,
+// the RPC node and the nonce the next payment would use without touching the
+// contract. With send the transfer is also submitted and waited for, proving
+// the node accepts what the signer produces. The transfer offers the standard
+// strategy's fees regardless of MAX_GAS_PRICE: a gas spike says nothing about
+// whether the gateway can sign. A transfer to be sent holds its nonce in the
+// client's NonceManager, so a payment sent meanwhile can't take it.
+func (c *Client) Canary(ctx context.Context, send bool) (*CanaryResult, error) {
+	self := c.signer.Address()
+	var lease *NonceLease
+	var nonce uint64
+	var err error
+	if send {
+		if lease, err = c.nonces.Acquire(ctx, self); err != nil {
+			return nil, err
+		}
+		// Returning before the node accepts the transfer gives the nonce back
+		defer lease.Done(errCanaryNotSent)
+		nonce = lease.Nonce
+	} else if nonce, err = c.ethClient.PendingNonceAt(ctx, self); err != nil {
+		return nil, err
+	}
+	fees, err := c.SuggestFees(ctx, FeeStandard)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := c.ethClient.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	gas, err := c.ethClient.EstimateGas(ctx, ethereum.CallMsg{From: self, To: &self, Value: big.NewInt(0)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate the canary transfer: %w", err)
+	}
+	var tx *types.Transaction
+	if fees.GasPrice != nil {
+		tx = types.NewTx(&types.LegacyTx{Nonce: nonce, To: &self, Value: big.NewInt(0), Gas: gas, GasPrice: fees.GasPrice})
+	} else {
+		tx = types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: nonce, To: &self, Value: big.NewInt(0), Gas: gas, GasTipCap: fees.TipCap, GasFeeCap: fees.FeeCap})
+	}
+	signed, err := c.signer.SignTx(ctx, tx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the canary transfer: %w", err)
+	}
+	if err := verifyCanary(signed, chainID, self); err != nil {
+		return nil, err
+	}
+
+	result := &CanaryResult{Nonce: signed.Nonce(), GasEstimate: gas, TxHash: signed.Hash().Hex()}
+	if !send {
+		return result, nil
+	}
+	err = c.ethClient.SendTransaction(ctx, signed)
+	lease.Done(err)
+	if err != nil {
+		return result, fmt.Errorf("node refused the canary transfer: %w", err)
+	}
+	result.Sent, err = c.waitForTransaction(ctx, signed)
+	if err != nil {
+		return result, err
+	}
+	if !result.Sent.Success {
+		return result, fmt.Errorf("canary transfer %s reverted", result.TxHash)
+	}
+	return result, nil
+}
+
+// verifyCanary checks a signed transfer recovers to the account that was
+// meant to sign it, on the chain it is meant for
+func verifyCanary(signed *types.Transaction, chainID *big.Int, want common.Address) error {
+	if signed.ChainId().Cmp(chainID) != 0 {
+		return fmt.Errorf("canary transfer was signed for chain %s, not %s", signed.ChainId(), chainID)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		return fmt.Errorf("canary transfer has an invalid signature: %w", err)
+	}
+	if from != want {
+		return fmt.Errorf("canary transfer was signed by %s, not the gateway account %s", from.Hex(), want.Hex())
+	}
+	return nil
+}
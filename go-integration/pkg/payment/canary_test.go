@@ -0,0 +1,38 @@
+package payment
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestVerifyCanary(t *testing.T) {
+	signer, err := NewKeySigner("abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890")
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	other, _ := NewKeySigner("1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef")
+	chainID := big.NewInt(11155111)
+	self := signer.Address()
+	tx := types.NewTx(&types.LegacyTx{Nonce: 4, To: &self, Value: big.NewInt(0), Gas: 21000, GasPrice: big.NewInt(1e9)})
+
+	signed, err := signer.SignTx(context.Background(), tx, chainID)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if err := verifyCanary(signed, chainID, self); err != nil {
+		t.Errorf("verifyCanary = %v, want nil", err)
+	}
+
+	// A signer holding the wrong key, or one configured for the wrong chain
+	wrongKey, _ := other.SignTx(context.Background(), tx, chainID)
+	if err := verifyCanary(wrongKey, chainID, self); err == nil {
+		t.Error("Expected a transfer signed by another account to fail")
+	}
+	wrongChain, _ := signer.SignTx(context.Background(), tx, big.NewInt(1))
+	if err := verifyCanary(wrongChain, chainID, self); err == nil {
+		t.Error("Expected a transfer signed for another chain to fail")
+	}
+}
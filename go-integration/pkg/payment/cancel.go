@@ -0,0 +1,112 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrNotPending is returned when cancelling a transaction the node has
+// already mined or doesn't know
+var ErrNotPending = errors.New("transaction is not pending")
+
+// replacementBump is the percentage by which a replacement must raise each of
+// the fees of the transaction it replaces for nodes to accept it; geth's
+// default price bump
+const replacementBump = 10
+
+// CancelTransaction replaces a pending transaction of one of the gateway's
+// signers with a zero-value transfer from the account to itself at the same
+// nonce, and waits for whichever of the two is mined. If the transfer is, the
+// stuck transaction's operation never takes effect. The transfer offers the
+// fast strategy's fees, or the stuck transaction's raised by the minimum
+// replacement bump when those are higher, regardless of MAX_GAS_PRICE: the
+// stuck nonce holds up every later transaction of the account.
+func (c *Client) CancelTransaction(ctx context.Context, hash common.Hash) (*TransactionResult, error) {
+	stuck, pending, err := c.ethClient.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) || (err == nil && !pending) {
+		return nil, fmt.Errorf("%w: %s", ErrNotPending, hash.Hex())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := c.ethClient.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), stuck)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover the sender of %s: %w", hash.Hex(), err)
+	}
+	signer := c.signer
+	if from != signer.Address() {
+		if c.adminSigner == nil || from != c.adminSigner.Address() {
+			return nil, fmt.Errorf("transaction %s was sent by %s, not a gateway signer", hash.Hex(), from.Hex())
+		}
+		signer = c.adminSigner
+	}
+
+	fees, err := c.SuggestFees(ctx, FeeFast)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signer.SignTx(ctx, cancelTransaction(stuck, from, fees), chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the cancel transaction: %w", err)
+	}
+	reportSigned(ctx, signed)
+	if err := c.ethClient.SendTransaction(ctx, signed); err != nil {
+		return &TransactionResult{Success: false, Error: err}, fmt.Errorf("node refused the cancel transaction: %w", err)
+	}
+	return c.waitForTransaction(ctx, signed)
+}
+
+// cancelTransaction builds the zero-value transfer from from to itself that
+// replaces stuck, offering fees or stuck's fees raised by replacementBump,
+// whichever is higher
+func cancelTransaction(stuck *types.Transaction, from common.Address, fees *Fees) *types.Transaction {
+	if fees.GasPrice != nil || stuck.Type() == types.LegacyTxType {
+		price := fees.GasPrice
+		if price == nil {
+			price = fees.FeeCap
+		}
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    stuck.Nonce(),
+			To:       &from,
+			Value:    big.NewInt(0),
+			Gas:      params.TxGas,
+			GasPrice: maxBig(price, bumped(stuck.GasPrice())),
+		})
+	}
+	tip := maxBig(fees.TipCap, bumped(stuck.GasTipCap()))
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   stuck.ChainId(),
+		Nonce:     stuck.Nonce(),
+		To:        &from,
+		Value:     big.NewInt(0),
+		Gas:       params.TxGas,
+		GasTipCap: tip,
+		GasFeeCap: maxBig(maxBig(fees.FeeCap, bumped(stuck.GasFeeCap())), tip),
+	})
+}
+
+// bumped is fee raised by replacementBump, rounded up
+func bumped(fee *big.Int) *big.Int {
+	raised := new(big.Int).Mul(fee, big.NewInt(100+replacementBump))
+	raised.Add(raised, big.NewInt(99))
+	return raised.Quo(raised, big.NewInt(100))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
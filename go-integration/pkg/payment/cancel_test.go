@@ -0,0 +1,40 @@
+package payment
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCancelTransaction(t *testing.T) {
+	from := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	escrow := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	stuck := types.NewTx(&types.DynamicFeeTx{
+		ChainID: big.NewInt(11155111), Nonce: 42, To: &escrow, Value: big.NewInt(5), Data: []byte{0x01},
+		Gas: 300000, GasTipCap: big.NewInt(2000), GasFeeCap: big.NewInt(50000),
+	})
+
+	// A quiet network still has to outbid the stuck transaction
+	cancel := cancelTransaction(stuck, from, &Fees{TipCap: big.NewInt(1000), FeeCap: big.NewInt(30000)})
+	if cancel.Nonce() != 42 || *cancel.To() != from || cancel.Value().Sign() != 0 || len(cancel.Data()) != 0 || cancel.Gas() != params.TxGas {
+		t.Fatalf("Expected an empty self-transfer at nonce 42, got %+v", cancel)
+	}
+	if cancel.GasTipCap().Int64() != 2200 || cancel.GasFeeCap().Int64() != 55000 {
+		t.Errorf("Expected fees bumped 10%%, got tip %s and cap %s", cancel.GasTipCap(), cancel.GasFeeCap())
+	}
+
+	// A busier one pays the going rate
+	cancel = cancelTransaction(stuck, from, &Fees{TipCap: big.NewInt(9000), FeeCap: big.NewInt(90000)})
+	if cancel.GasTipCap().Int64() != 9000 || cancel.GasFeeCap().Int64() != 90000 {
+		t.Errorf("Expected the suggested fees, got tip %s and cap %s", cancel.GasTipCap(), cancel.GasFeeCap())
+	}
+
+	// Legacy chains bump the gas price, rounding up
+	legacy := types.NewTx(&types.LegacyTx{Nonce: 7, To: &escrow, Gas: 300000, GasPrice: big.NewInt(1001)})
+	if cancel := cancelTransaction(legacy, from, &Fees{GasPrice: big.NewInt(900)}); cancel.Type() != types.LegacyTxType || cancel.GasPrice().Int64() != 1102 {
+		t.Errorf("Expected a legacy cancel at 1102 wei, got type %d at %s", cancel.Type(), cancel.GasPrice())
+	}
+}
@@ -1,31 +1,44 @@
 package payment
 
 import (
+	"bytes"
 	"context"
-	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"log"
 	"math/big"
+	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 
 	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
 	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/amounts"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/calldata"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/oracle"
 )
 
 type Client struct {
-	ethClient       *ethclient.Client
-	contract        *contracts.EthJobEscrow
-	contractAddress common.Address
-	privateKey      *ecdsa.PrivateKey
-	publicAddress   common.Address
-	config          *config.Config
+	ethClient     *ethclient.Client
+	providers     *failoverTransport            // nil without ETHEREUM_RPC_FALLBACK_URLS
+	escrow        atomic.Pointer[escrowBinding] // replaced by SwapContract
+	signer        Signer                        // hot key for routine operations
+	adminSigner   Signer                        // optional hardware signer for high-value and admin operations
+	publicAddress common.Address
+	nonces        *NonceManager // shared by both signers' accounts
+	priceFeed     *oracle.Feed
+	fallbackFeed  *oracle.Feed // nil without ORACLE_FALLBACK_FEED
+	config        *config.Config
 }
 
+// ErrNoFallbackFeed is returned by FallbackPriceRound when ORACLE_FALLBACK_FEED is unset
+var ErrNoFallbackFeed = errors.New("no fallback price feed configured")
+
 type JobDetails struct {
 	Client      common.Address
 	Freelancer  common.Address
@@ -36,34 +49,109 @@ type JobDetails struct {
 }
 
 type TransactionResult struct {
-	TxHash      string
-	BlockNumber uint64
-	GasUsed     uint64
-	Success     bool
-	Error       error
+	TxHash            string
+	From              common.Address // the account that signed, zero if nothing was sent
+	Nonce             uint64
+	BlockNumber       uint64
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int      // wei per gas actually paid
+	InclusionTime     time.Duration // from sending until the receipt was seen, zero if not mined
+	Success           bool
+	Error             error
+}
+
+// GasCost returns the wei spent on gas, or nil if the transaction was not mined
+func (r *TransactionResult) GasCost() *big.Int {
+	if r.EffectiveGasPrice == nil {
+		return nil
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(r.GasUsed), r.EffectiveGasPrice)
+}
+
+// GasPriceTooHighError is returned when the suggested gas price exceeds the configured ceiling
+type GasPriceTooHighError struct {
+	GasPrice *big.Int // suggested price in wei
+	Ceiling  *big.Int // configured ceiling in wei
+}
+
+func (e *GasPriceTooHighError) Error() string {
+	return fmt.Sprintf("gas price %s wei exceeds ceiling of %s wei", e.GasPrice, e.Ceiling)
+}
+
+// ClientOption customises a Client created by NewClient
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	signer Signer
+}
+
+// WithSigner signs everyday transactions with signer instead of PRIVATE_KEY
+func WithSigner(signer Signer) ClientOption {
+	return func(o *clientOptions) {
+		o.signer = signer
+	}
 }
 
 // NewClient creates a new blockchain client instance
-func NewClient(cfg *config.Config) (*Client, error) {
-	// Connect to Ethereum client
-	ethClient, err := ethclient.Dial(cfg.EthereumRPCURL)
-	if err != nil {
-		return nil, err
+func NewClient(cfg *config.Config, opts ...ClientOption) (*Client, error) {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	// Parse private key
-	privateKey, err := crypto.HexToECDSA(cfg.PrivateKey)
+	// Connect to Ethereum client, failing over across providers if several are configured
+	ethClient, providers, err := dialRPC(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get public address from private key
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, errors.New("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+	// Connect to the remote signer, or parse the private key, unless a signer was supplied
+	signer := options.signer
+	if signer == nil && cfg.RemoteSignerURL != "" {
+		remoteConfig := RemoteSignerConfig{
+			URL:            cfg.RemoteSignerURL,
+			API:            cfg.RemoteSignerAPI,
+			Timeout:        cfg.RemoteSignerTimeout,
+			CAFile:         cfg.RemoteSignerCAFile,
+			ClientCertFile: cfg.RemoteSignerClientCert,
+			ClientKeyFile:  cfg.RemoteSignerClientKey,
+		}
+		if cfg.RemoteSignerAddress != "" {
+			if !common.IsHexAddress(cfg.RemoteSignerAddress) {
+				return nil, fmt.Errorf("invalid REMOTE_SIGNER_ADDRESS %q", cfg.RemoteSignerAddress)
+			}
+			remoteConfig.Address = common.HexToAddress(cfg.RemoteSignerAddress)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		remote, err := NewRemoteSigner(ctx, remoteConfig)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Transactions will be signed by %s account %s", remoteConfig.API, remote.Address().Hex())
+		signer = remote
+	}
+	if signer == nil {
+		keySigner, err := NewKeySigner(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		signer = keySigner
+	}
+
+	// Open the hardware signer for privileged operations
+	var adminSigner Signer
+	switch cfg.AdminSigner {
+	case "":
+	case "ledger":
+		adminSigner, err = NewLedgerSigner(cfg.LedgerDerivationPath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Privileged operations will be signed by ledger account %s", adminSigner.Address().Hex())
+	default:
+		return nil, fmt.Errorf("unknown admin signer %q", cfg.AdminSigner)
 	}
-	publicAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 
 	// Connect to smart contract
 	contractAddress := common.HexToAddress(cfg.ContractAddress)
@@ -72,56 +160,191 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{
-		ethClient:       ethClient,
-		contract:        contract,
-		contractAddress: contractAddress,
-		privateKey:      privateKey,
-		publicAddress:   publicAddress,
-		config:          cfg,
-	}, nil
+	// Connect to the Chainlink price feed the contract converts with
+	priceFeed, err := oracle.NewFeed(common.HexToAddress(cfg.ETHUSDPriceFeed), ethClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallbackFeed *oracle.Feed
+	if cfg.OracleFallbackFeed != "" {
+		fallbackFeed, err = oracle.NewFeed(common.HexToAddress(cfg.OracleFallbackFeed), ethClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The contract converts with the primary feed's raw answer, so it must
+	// have the decimals the gateway's math assumes. The fallback's answers are
+	// rescaled to match.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	decimals, err := priceFeed.LoadDecimals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if decimals != amounts.PriceDecimals {
+		return nil, fmt.Errorf("price feed %s has %d decimals; the escrow contract assumes %d", cfg.ETHUSDPriceFeed, decimals, amounts.PriceDecimals)
+	}
+	if fallbackFeed != nil {
+		decimals, err := fallbackFeed.LoadDecimals(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if decimals != amounts.PriceDecimals {
+			log.Printf("Fallback price feed %s has %d decimals; its answers are rescaled to %d", cfg.OracleFallbackFeed, decimals, amounts.PriceDecimals)
+		}
+	}
+
+	client := &Client{
+		ethClient:     ethClient,
+		providers:     providers,
+		signer:        signer,
+		adminSigner:   adminSigner,
+		publicAddress: signer.Address(),
+		nonces:        NewNonceManager(ethClient, cfg.NonceResyncAfter),
+		priceFeed:     priceFeed,
+		fallbackFeed:  fallbackFeed,
+		config:        cfg,
+	}
+	client.escrow.Store(&escrowBinding{contract: contract, address: contractAddress})
+	return client, nil
 }
 
-// GetAuth creates a new transactor for sending transactions
+// GetAuth creates a new transactor for sending transactions with the hot key.
+// Like GetAdminAuth it leaves the nonce to the node: the client's own
+// transactions take theirs from its NonceManager.
 func (c *Client) GetAuth(ctx context.Context) (*bind.TransactOpts, error) {
-	nonce, err := c.ethClient.PendingNonceAt(ctx, c.publicAddress)
+	return c.authFor(ctx, c.signer)
+}
+
+// GetAdminAuth creates a transactor for privileged operations. It uses the
+// hardware signer when one is configured and the hot key otherwise.
+func (c *Client) GetAdminAuth(ctx context.Context) (*bind.TransactOpts, error) {
+	if c.adminSigner != nil {
+		return c.authFor(ctx, c.adminSigner)
+	}
+	return c.authFor(ctx, c.signer)
+}
+
+// authForAmount picks the admin signer for jobs at or above the privileged USD threshold
+func (c *Client) authForAmount(ctx context.Context, usdAmount *big.Int) (*bind.TransactOpts, error) {
+	if c.adminSigner != nil && c.config.PrivilegedUSDThreshold > 0 &&
+		usdAmount != nil && usdAmount.Cmp(big.NewInt(c.config.PrivilegedUSDThreshold)) >= 0 {
+		return c.authFor(ctx, c.adminSigner)
+	}
+	return c.authFor(ctx, c.signer)
+}
+
+// authForJob picks the signer for an existing job based on its on-chain USD amount
+func (c *Client) authForJob(ctx context.Context, jobID uint64) (*bind.TransactOpts, error) {
+	if c.adminSigner == nil {
+		return c.authFor(ctx, c.signer)
+	}
+
+	job, err := c.GetJobDetails(ctx, jobID)
 	if err != nil {
 		return nil, err
 	}
+	return c.authForAmount(ctx, job.USDAmount)
+}
 
-	gasPrice, err := c.ethClient.SuggestGasPrice(ctx)
+func (c *Client) authFor(ctx context.Context, signer Signer) (*bind.TransactOpts, error) {
+	suggested, err := c.SuggestFees(ctx, c.feeStrategy(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	chainID, err := c.ethClient.NetworkID(ctx)
+	preset, _ := ctx.Value(gasPresetKey{}).(GasPreset)
+	fees, err := preset.fees(suggested, c.GasPriceCeiling())
 	if err != nil {
 		return nil, err
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(c.privateKey, chainID)
+	chainID, err := c.ethClient.NetworkID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	auth.Nonce = big.NewInt(int64(nonce))
+	auth := transactOpts(ctx, signer, chainID)
 	auth.Value = big.NewInt(0)
 	auth.GasLimit = c.config.GasLimit
-	auth.GasPrice = gasPrice
+	if fees.GasPrice != nil {
+		auth.GasPrice = fees.GasPrice
+	} else {
+		auth.GasFeeCap = fees.FeeCap
+		auth.GasTipCap = fees.TipCap
+	}
 
 	return auth, nil
 }
 
+// GasPriceCeiling returns the configured maximum gas price in wei, or nil when no ceiling is set
+func (c *Client) GasPriceCeiling() *big.Int {
+	if c.config.MaxGasPrice <= 0 {
+		return nil
+	}
+	return gweiToWei(c.config.MaxGasPrice)
+}
+
+func gweiToWei(gwei int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(gwei), big.NewInt(1e9))
+}
+
+// GasPreset prices the transactions sent under a context, for operations
+// that must not wait on the network
+type GasPreset struct {
+	PricePercent int64 // of the suggested tip, or legacy gas price, offered under the ceiling; 0 offers it unchanged
+	MaxGasPrice  int64 // ceiling in Gwei replacing MAX_GAS_PRICE; 0 keeps MAX_GAS_PRICE
+}
+
+type gasPresetKey struct{}
+
+// WithGasPreset returns a context whose transactions are priced by preset
+func WithGasPreset(ctx context.Context, preset GasPreset) context.Context {
+	return context.WithValue(ctx, gasPresetKey{}, preset)
+}
+
+// price returns the gas price offered when the network suggests suggested
+// and MAX_GAS_PRICE is ceiling, or a *GasPriceTooHighError when the
+// suggestion is over the preset's ceiling. A premium is capped at the
+// ceiling rather than refused.
+func (p GasPreset) price(suggested, ceiling *big.Int) (*big.Int, error) {
+	if p.MaxGasPrice > 0 {
+		ceiling = gweiToWei(p.MaxGasPrice)
+	}
+	if ceiling != nil && suggested.Cmp(ceiling) > 0 {
+		return nil, &GasPriceTooHighError{GasPrice: suggested, Ceiling: ceiling}
+	}
+	if p.PricePercent <= 0 {
+		return suggested, nil
+	}
+	offered := new(big.Int).Mul(suggested, big.NewInt(p.PricePercent))
+	offered.Quo(offered, big.NewInt(100))
+	if ceiling != nil && offered.Cmp(ceiling) > 0 {
+		offered.Set(ceiling)
+	}
+	return offered, nil
+}
+
+// SuggestGasPrice returns the network's currently suggested gas price in wei
+func (c *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return c.ethClient.SuggestGasPrice(ctx)
+}
+
 // PostJob creates a new job on the blockchain
 func (c *Client) PostJob(ctx context.Context, jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address) (*TransactionResult, error) {
+	// Convert and post on the same contract even if it is swapped meanwhile
+	contract := c.escrow.Load().contract
+
 	// Get current ETH price and calculate required ETH
-	ethAmount, err := c.contract.ConvertUsdToEth(&bind.CallOpts{Context: ctx}, usdAmount)
+	ethAmount, err := contract.ConvertUsdToEth(&bind.CallOpts{Context: ctx}, usdAmount)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get transaction options
-	auth, err := c.GetAuth(ctx)
+	auth, err := c.authForAmount(ctx, usdAmount)
 	if err != nil {
 		return nil, err
 	}
@@ -129,58 +352,41 @@ func (c *Client) PostJob(ctx context.Context, jobID uint64, freelancer common.Ad
 	// Set the value to send (ETH amount)
 	auth.Value = ethAmount
 
-	// Execute transaction
-	tx, err := c.contract.PostJob(auth, big.NewInt(int64(jobID)), freelancer, usdAmount, client)
-	if err != nil {
-		return &TransactionResult{
-			Success: false,
-			Error:   err,
-		}, err
-	}
-
-	// Wait for transaction confirmation
-	return c.waitForTransaction(ctx, tx)
+	// Execute transaction and wait for confirmation
+	return c.transact(ctx, auth, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.PostJob(auth, big.NewInt(int64(jobID)), freelancer, usdAmount, client)
+	})
 }
 
 // MarkJobCompleted marks a job as completed and releases payment
 func (c *Client) MarkJobCompleted(ctx context.Context, jobID uint64) (*TransactionResult, error) {
-	auth, err := c.GetAuth(ctx)
+	auth, err := c.authForJob(ctx, jobID)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := c.contract.MarkJobCompleted(auth, big.NewInt(int64(jobID)))
-	if err != nil {
-		return &TransactionResult{
-			Success: false,
-			Error:   err,
-		}, err
-	}
-
-	return c.waitForTransaction(ctx, tx)
+	contract := c.escrow.Load().contract
+	return c.transact(ctx, auth, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.MarkJobCompleted(auth, big.NewInt(int64(jobID)))
+	})
 }
 
 // CancelJob cancels a job and refunds the client
 func (c *Client) CancelJob(ctx context.Context, jobID uint64) (*TransactionResult, error) {
-	auth, err := c.GetAuth(ctx)
+	auth, err := c.authForJob(ctx, jobID)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := c.contract.CancelJob(auth, big.NewInt(int64(jobID)))
-	if err != nil {
-		return &TransactionResult{
-			Success: false,
-			Error:   err,
-		}, err
-	}
-
-	return c.waitForTransaction(ctx, tx)
+	contract := c.escrow.Load().contract
+	return c.transact(ctx, auth, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.CancelJob(auth, big.NewInt(int64(jobID)))
+	})
 }
 
 // GetJobDetails retrieves job information from the blockchain
 func (c *Client) GetJobDetails(ctx context.Context, jobID uint64) (*JobDetails, error) {
-	result, err := c.contract.GetJobDetails(
+	result, err := c.escrow.Load().contract.GetJobDetails(
 		&bind.CallOpts{Context: ctx},
 		big.NewInt(int64(jobID)),
 	)
@@ -198,47 +404,285 @@ func (c *Client) GetJobDetails(ctx context.Context, jobID uint64) (*JobDetails,
 	}, nil
 }
 
-// GetETHUSDPrice gets the current ETH/USD price from Chainlink
+// GetETHUSDPrice gets the current ETH/USD price the contract converts with,
+// or a *oracle.PriceOutOfBoundsError when it fails the sanity bounds
 func (c *Client) GetETHUSDPrice(ctx context.Context) (*big.Int, error) {
-	return c.contract.GetLatestEthUsd(&bind.CallOpts{Context: ctx})
+	price, err := c.escrow.Load().contract.GetLatestEthUsd(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	if err := oracle.CheckPrice(price); err != nil {
+		return nil, err
+	}
+	return price, nil
 }
 
 // ConvertUSDToETH converts USD amount to ETH using current price
 func (c *Client) ConvertUSDToETH(ctx context.Context, usdAmount *big.Int) (*big.Int, error) {
-	return c.contract.ConvertUsdToEth(&bind.CallOpts{Context: ctx}, usdAmount)
+	return c.escrow.Load().contract.ConvertUsdToEth(&bind.CallOpts{Context: ctx}, usdAmount)
+}
+
+type signedFuncKey struct{}
+
+// WithSignedFunc returns a context whose transactions are passed to fn once
+// signed and before they are sent, so the caller can keep every hash an
+// operation may leave on-chain, including one a failed send still broadcast
+func WithSignedFunc(ctx context.Context, fn func(tx *types.Transaction)) context.Context {
+	return context.WithValue(ctx, signedFuncKey{}, fn)
+}
+
+func reportSigned(ctx context.Context, tx *types.Transaction) {
+	if fn, _ := ctx.Value(signedFuncKey{}).(func(*types.Transaction)); fn != nil {
+		fn(tx)
+	}
+}
+
+// transact sends the transaction send builds at the next nonce of auth's
+// account and waits for it to be mined. The nonce is held only until the
+// transaction is sent, so other transactions of the account can follow it
+// while it is pending. A send the node refuses because the transaction, or
+// an earlier signing of the same call at the nonce, is already pending waits
+// for that one instead.
+func (c *Client) transact(ctx context.Context, auth *bind.TransactOpts, send func(*bind.TransactOpts) (*types.Transaction, error)) (*TransactionResult, error) {
+	lease, err := c.nonces.Acquire(ctx, auth.From)
+	if err != nil {
+		return nil, err
+	}
+	auth.Nonce = new(big.Int).SetUint64(lease.Nonce)
+	sign := auth.Signer
+	var signed *types.Transaction
+	auth.Signer = func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		tx, err := sign(address, tx)
+		if err == nil {
+			signed = tx
+			lease.Signed(tx)
+			reportSigned(ctx, tx)
+		}
+		return tx, err
+	}
+	tx, err := send(auth)
+	if err != nil {
+		tx, err = pendingAt(lease.Nonce, err, signed, lease.Unsent())
+	}
+	var taken *NonceTakenError
+	if errors.As(err, &taken) {
+		lease.Done(nil)
+	} else {
+		lease.Done(err)
+	}
+	if err != nil {
+		return &TransactionResult{
+			Success: false,
+			Error:   err,
+		}, err
+	}
+	if tx != signed {
+		log.Printf("Transaction at nonce %d is already pending as %s; waiting for it", lease.Nonce, tx.Hash().Hex())
+	}
+	return c.waitForTransaction(ctx, tx)
+}
+
+// pendingAt finds the transaction already pending at nonce when the node
+// refused signed with sendErr: signed itself if the node already has it, or
+// unsent, an earlier signing at the nonce whose send errored here, if it
+// makes the same call. Refused as underpriced by anything else, the nonce is
+// taken. Other errors are returned as they are.
+func pendingAt(nonce uint64, sendErr error, signed, unsent *types.Transaction) (*types.Transaction, error) {
+	switch {
+	case signed == nil:
+		return nil, sendErr
+	case isAlreadyKnown(sendErr):
+		return signed, nil
+	case !isUnderpriced(sendErr):
+		return nil, sendErr
+	case unsent != nil && sameCall(unsent, signed):
+		return unsent, nil
+	}
+	return nil, &NonceTakenError{Nonce: nonce, Err: sendErr}
+}
+
+// sameCall reports whether a and b call the same account with the same data
+// and value, differing at most in fees
+func sameCall(a, b *types.Transaction) bool {
+	return a.To() != nil && b.To() != nil && *a.To() == *b.To() &&
+		bytes.Equal(a.Data(), b.Data()) && a.Value().Cmp(b.Value()) == 0
 }
 
 // waitForTransaction waits for transaction confirmation and returns result
 func (c *Client) waitForTransaction(ctx context.Context, tx *types.Transaction) (*TransactionResult, error) {
 	log.Printf("Transaction sent: %s", tx.Hash().Hex())
+	from, _ := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
 
 	// Wait for transaction to be mined
+	sent := time.Now()
 	receipt, err := bind.WaitMined(ctx, c.ethClient, tx)
 	if err != nil {
+		pendingErr := &TransactionPendingError{TxHash: tx.Hash().Hex(), Err: err}
 		return &TransactionResult{
 			TxHash:  tx.Hash().Hex(),
+			From:    from,
+			Nonce:   tx.Nonce(),
 			Success: false,
-			Error:   err,
-		}, err
+			Error:   pendingErr,
+		}, pendingErr
 	}
 
 	success := receipt.Status == types.ReceiptStatusSuccessful
 
 	return &TransactionResult{
-		TxHash:      tx.Hash().Hex(),
-		BlockNumber: receipt.BlockNumber.Uint64(),
-		GasUsed:     receipt.GasUsed,
-		Success:     success,
-		Error:       nil,
+		TxHash:            tx.Hash().Hex(),
+		From:              from,
+		Nonce:             tx.Nonce(),
+		BlockNumber:       receipt.BlockNumber.Uint64(),
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: receipt.EffectiveGasPrice,
+		InclusionTime:     time.Since(sent),
+		Success:           success,
+		Error:             nil,
 	}, nil
 }
 
+// Address returns the account the gateway signs transactions with
+func (c *Client) Address() common.Address {
+	return c.publicAddress
+}
+
+// AdminAddress returns the hardware signer's account, or the zero address when none is configured
+func (c *Client) AdminAddress() common.Address {
+	if c.adminSigner == nil {
+		return common.Address{}
+	}
+	return c.adminSigner.Address()
+}
+
+// LatestPriceRound returns the latest round of the configured Chainlink
+// ETH/USD feed. The heartbeat monitor applies the sanity bounds to it.
+func (c *Client) LatestPriceRound(ctx context.Context) (*oracle.RoundData, error) {
+	return c.priceFeed.LatestRound(ctx)
+}
+
+// FallbackPriceRound returns the latest round of the fallback ETH/USD feed,
+// or ErrNoFallbackFeed when none is configured. Market prices are read from
+// it, so an answer outside the sanity bounds is an error.
+func (c *Client) FallbackPriceRound(ctx context.Context) (*oracle.RoundData, error) {
+	if c.fallbackFeed == nil {
+		return nil, ErrNoFallbackFeed
+	}
+	round, err := c.fallbackFeed.LatestRound(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := oracle.CheckPrice(round.Answer); err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// RecentPriceRounds returns up to n of the latest ETH/USD rounds, oldest
+// first, for averaging. Any answer outside the sanity bounds is an error.
+func (c *Client) RecentPriceRounds(ctx context.Context, n int) ([]*oracle.RoundData, error) {
+	rounds, err := c.priceFeed.RecentRounds(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	for _, round := range rounds {
+		if err := oracle.CheckPrice(round.Answer); err != nil {
+			return nil, fmt.Errorf("round %s: %w", round.RoundID, err)
+		}
+	}
+	return rounds, nil
+}
+
+// PriceRoundAtBlock returns the ETH/USD round the contract would have read at a block
+func (c *Client) PriceRoundAtBlock(ctx context.Context, blockNumber uint64) (*oracle.RoundData, error) {
+	return c.priceFeed.RoundAtBlock(ctx, blockNumber)
+}
+
+// PriceRoundAtTime returns the last ETH/USD round updated at or before a time
+func (c *Client) PriceRoundAtTime(ctx context.Context, at time.Time) (*oracle.RoundData, error) {
+	return c.priceFeed.RoundAtTime(ctx, at)
+}
+
+// EstimateGas estimates the gas a contract call would use if sent by the gateway account
+func (c *Client) EstimateGas(ctx context.Context, value *big.Int, method string, args ...interface{}) (uint64, error) {
+	data, err := calldata.Pack(method, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	address := c.ContractAddress()
+	return c.ethClient.EstimateGas(ctx, ethereum.CallMsg{
+		From:  c.publicAddress,
+		To:    &address,
+		Value: value,
+		Data:  data,
+	})
+}
+
+// BlockNumber returns the latest block the RPC node has seen
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.ethClient.BlockNumber(ctx)
+}
+
+// LatestBaseFee returns the latest block and its base fee in wei. It fails on
+// networks without EIP-1559.
+func (c *Client) LatestBaseFee(ctx context.Context) (uint64, *big.Int, error) {
+	header, err := c.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if header.BaseFee == nil {
+		return 0, nil, fmt.Errorf("block %d has no base fee", header.Number.Uint64())
+	}
+	return header.Number.Uint64(), header.BaseFee, nil
+}
+
+// BlockGas is how full a block was and the base fee it set
+type BlockGas struct {
+	Number   uint64
+	BaseFee  *big.Int // nil on networks without EIP-1559
+	GasUsed  uint64
+	GasLimit uint64
+}
+
+// LatestBlockGas returns the latest block's gas usage against its limit
+func (c *Client) LatestBlockGas(ctx context.Context) (*BlockGas, error) {
+	header, err := c.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockGas{Number: header.Number.Uint64(), BaseFee: header.BaseFee, GasUsed: header.GasUsed, GasLimit: header.GasLimit}, nil
+}
+
+// PendingTransactionCount returns how many transactions the node holds in
+// its pending pool
+func (c *Client) PendingTransactionCount(ctx context.Context) (uint, error) {
+	return c.ethClient.PendingTransactionCount(ctx)
+}
+
 // GetBalance gets ETH balance for an address
 func (c *Client) GetBalance(ctx context.Context, address common.Address) (*big.Int, error) {
 	return c.ethClient.BalanceAt(ctx, address, nil)
 }
 
+// NonceAt returns how many transactions address has had mined
+func (c *Client) NonceAt(ctx context.Context, address common.Address) (uint64, error) {
+	return c.ethClient.NonceAt(ctx, address, nil)
+}
+
+// PendingNonceAt returns the next nonce of address counting transactions
+// still in the mempool
+func (c *Client) PendingNonceAt(ctx context.Context, address common.Address) (uint64, error) {
+	return c.ethClient.PendingNonceAt(ctx, address)
+}
+
 // Close closes the Ethereum client connection
 func (c *Client) Close() {
+	if ledger, ok := c.adminSigner.(*LedgerSigner); ok {
+		ledger.Close()
+	}
+	if remote, ok := c.signer.(*RemoteSigner); ok {
+		remote.Close()
+	}
 	c.ethClient.Close()
 }
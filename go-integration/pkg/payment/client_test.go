@@ -1,6 +1,7 @@
 package payment
 
 import (
+	"errors"
 	"math/big"
 	"testing"
 
@@ -69,6 +70,56 @@ func TestTransactionResult(t *testing.T) {
 	}
 }
 
+func TestGasCostInUSD(t *testing.T) {
+	result := &TransactionResult{
+		GasUsed:           100000,
+		EffectiveGasPrice: big.NewInt(20000000000), // 20 Gwei
+	}
+
+	cost := result.GasCost()
+	if cost.Cmp(big.NewInt(2000000000000000)) != 0 {
+		t.Errorf("Expected gas cost 0.002 ETH in wei, got %s", cost.String())
+	}
+
+	if (&TransactionResult{}).GasCost() != nil {
+		t.Errorf("Expected no gas cost for unmined transaction")
+	}
+}
+
+func TestGasPresetPrice(t *testing.T) {
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+	tests := []struct {
+		name      string
+		preset    GasPreset
+		suggested *big.Int
+		ceiling   *big.Int
+		want      *big.Int
+		tooHigh   bool
+	}{
+		{name: "unchanged", suggested: gwei(20), ceiling: gwei(50), want: gwei(20)},
+		{name: "premium", preset: GasPreset{PricePercent: 150}, suggested: gwei(20), want: gwei(30)},
+		{name: "premium capped", preset: GasPreset{PricePercent: 200}, suggested: gwei(40), ceiling: gwei(50), want: gwei(50)},
+		{name: "over the ceiling", suggested: gwei(60), ceiling: gwei(50), tooHigh: true},
+		{name: "own ceiling", preset: GasPreset{PricePercent: 150, MaxGasPrice: 100}, suggested: gwei(60), ceiling: gwei(50), want: gwei(90)},
+		{name: "over its own ceiling", preset: GasPreset{MaxGasPrice: 100}, suggested: gwei(120), ceiling: gwei(50), tooHigh: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.preset.price(tt.suggested, tt.ceiling)
+			var tooHigh *GasPriceTooHighError
+			if tt.tooHigh {
+				if !errors.As(err, &tooHigh) {
+					t.Fatalf("price = %v, %v; want a GasPriceTooHighError", got, err)
+				}
+				return
+			}
+			if err != nil || got.Cmp(tt.want) != 0 {
+				t.Errorf("price = %v, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
 // Integration test - only run with valid configuration
 func TestNewClientIntegration(t *testing.T) {
 	if testing.Short() {
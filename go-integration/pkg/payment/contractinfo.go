@@ -0,0 +1,99 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// priceFeedSlot is the storage slot of the escrow contract's internal
+// priceFeed, its first state variable. The contract has no getter for it.
+var priceFeedSlot = common.Hash{}
+
+// pausedSelector is paused() as declared by OpenZeppelin's Pausable
+var pausedSelector = []byte{0x5c, 0x97, 0x5a, 0xbb}
+
+// ContractConfig is the escrow contract's configuration as read from the chain
+// at one block
+type ContractConfig struct {
+	Address     common.Address
+	BlockNumber uint64
+	Owner       common.Address
+	FeePercent  *big.Int
+	PriceFeed   common.Address // the Chainlink feed the contract converts with
+	Paused      *bool          // nil when the contract has no paused()
+	CodeHash    common.Hash    // keccak256 of the runtime bytecode at Address
+	CodeSize    int
+
+	Proxy                  *ProxyInfo
+	ImplementationCodeHash *common.Hash // for a proxy, of the code it delegates to
+}
+
+// GetContractConfig reads the escrow contract's owner, fee, price feed,
+// paused state and bytecode, all at the latest block so they agree
+func (c *Client) GetContractConfig(ctx context.Context) (*ContractConfig, error) {
+	binding := c.escrow.Load()
+
+	blockNumber, err := c.ethClient.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block number: %w", err)
+	}
+	block := new(big.Int).SetUint64(blockNumber)
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: block}
+
+	info := &ContractConfig{Address: binding.address, BlockNumber: blockNumber}
+
+	code, err := c.ethClient.CodeAt(ctx, binding.address, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract code: %w", err)
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no contract is deployed at %s", binding.address.Hex())
+	}
+	info.CodeHash = crypto.Keccak256Hash(code)
+	info.CodeSize = len(code)
+
+	if info.Owner, err = binding.contract.Owner(opts); err != nil {
+		return nil, fmt.Errorf("failed to read contract owner: %w", err)
+	}
+	if info.FeePercent, err = binding.contract.FEEPERCENT(opts); err != nil {
+		return nil, fmt.Errorf("failed to read contract fee: %w", err)
+	}
+
+	feed, err := c.ethClient.StorageAt(ctx, binding.address, priceFeedSlot, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract price feed: %w", err)
+	}
+	info.PriceFeed = addressFromSlot(feed)
+
+	out, err := c.ethClient.CallContract(ctx, ethereum.CallMsg{To: &binding.address, Data: pausedSelector}, block)
+	switch {
+	case err != nil && !strings.Contains(err.Error(), "revert"):
+		return nil, fmt.Errorf("failed to read paused state: %w", err)
+	case err == nil && len(out) == 32:
+		paused := new(big.Int).SetBytes(out).Sign() != 0
+		info.Paused = &paused
+	}
+
+	proxy, err := c.GetProxyInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.Proxy = proxy
+	if proxy.Implementation != (common.Address{}) {
+		implementationCode, err := c.ethClient.CodeAt(ctx, proxy.Implementation, block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read implementation code: %w", err)
+		}
+		hash := crypto.Keccak256Hash(implementationCode)
+		info.ImplementationCodeHash = &hash
+	}
+
+	return info, nil
+}
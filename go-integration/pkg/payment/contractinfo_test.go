@@ -0,0 +1,13 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestPausedSelector(t *testing.T) {
+	if got := crypto.Keccak256([]byte("paused()"))[:4]; string(got) != string(pausedSelector) {
+		t.Errorf("Expected paused() selector %x, got %x", got, pausedSelector)
+	}
+}
@@ -0,0 +1,91 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EscrowEvent is an escrow event with the job it belongs to and the fields the
+// event carries. Client and USDAmount are unset on PaymentReleased, Freelancer
+// and USDAmount on JobCancelled.
+type EscrowEvent struct {
+	JobEvent
+	BlockHash  string // of the block the event was mined in, to tell a reorganised copy apart
+	JobID      uint64
+	Client     common.Address
+	Freelancer common.Address
+	USDAmount  *big.Int
+	ETHAmount  *big.Int
+}
+
+// Escrow is one job's escrow as reconstructed from its events
+type Escrow struct {
+	JobID       uint64
+	Record      ChainRecord
+	Client      common.Address // of the latest posting
+	Freelancer  common.Address
+	USDAmount   *big.Int
+	ETHAmount   *big.Int // wei the contract required at posting
+	BlockNumber uint64   // of the latest posting
+	Events      int
+}
+
+// ScanEscrowEvents returns every escrow event between fromBlock and toBlock,
+// for all jobs. A toBlock of 0 scans to the latest block.
+func (c *Client) ScanEscrowEvents(ctx context.Context, fromBlock, toBlock uint64) ([]EscrowEvent, error) {
+	var end *uint64
+	if toBlock > 0 {
+		end = &toBlock
+	}
+	return c.scanEscrowEvents(ctx, fromBlock, end, nil)
+}
+
+// BlockHash returns the hash of the canonical block at blockNumber
+func (c *Client) BlockHash(ctx context.Context, blockNumber uint64) (string, error) {
+	header, err := c.ethClient.HeaderByNumber(ctx, new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return "", fmt.Errorf("failed to get block %d: %w", blockNumber, err)
+	}
+	return header.Hash().Hex(), nil
+}
+
+// DiscoverEscrows groups escrow events by job and derives each job's record,
+// ordered by job ID. Jobs with no JobPosted event in the range are left out,
+// since their terms are unknown.
+func DiscoverEscrows(events []EscrowEvent) []Escrow {
+	byJob := make(map[uint64][]EscrowEvent)
+	for _, event := range events {
+		byJob[event.JobID] = append(byJob[event.JobID], event)
+	}
+
+	var escrows []Escrow
+	for jobID, jobEvents := range byJob {
+		history := make([]JobEvent, len(jobEvents))
+		for i, event := range jobEvents {
+			history[i] = event.JobEvent
+		}
+		latest := latestPosted(history)
+		if latest == nil {
+			continue
+		}
+
+		escrow := Escrow{JobID: jobID, Record: DeriveChainRecord(history, nil), Events: len(jobEvents)}
+		for _, event := range jobEvents {
+			if event.JobEvent == *latest {
+				escrow.Client = event.Client
+				escrow.Freelancer = event.Freelancer
+				escrow.USDAmount = event.USDAmount
+				escrow.ETHAmount = event.ETHAmount
+				escrow.BlockNumber = event.BlockNumber
+			}
+		}
+		escrows = append(escrows, escrow)
+	}
+
+	sort.Slice(escrows, func(i, j int) bool { return escrows[i].JobID < escrows[j].JobID })
+	return escrows
+}
@@ -0,0 +1,56 @@
+package payment
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDiscoverEscrows(t *testing.T) {
+	client := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	freelancer := common.HexToAddress("0xabcdefabcdefabcdefabcdefabcdefabcdefabcd")
+	posted := func(jobID uint64, tx string, block uint64, usd int64) EscrowEvent {
+		return EscrowEvent{
+			JobEvent:   JobEvent{Kind: JobEventPosted, TxHash: tx, BlockNumber: block},
+			JobID:      jobID,
+			Client:     client,
+			Freelancer: freelancer,
+			USDAmount:  big.NewInt(usd),
+			ETHAmount:  big.NewInt(usd * 1e15),
+		}
+	}
+
+	escrows := DiscoverEscrows([]EscrowEvent{
+		{JobEvent: JobEvent{Kind: JobEventReleased, TxHash: "0xb", BlockNumber: 30}, JobID: 9, Freelancer: freelancer},
+		posted(9, "0xa", 20, 100),
+		posted(4, "0xc", 10, 50),
+		{JobEvent: JobEvent{Kind: JobEventCancelled, TxHash: "0xd", BlockNumber: 11}, JobID: 4, Client: client},
+		posted(4, "0xe", 12, 75),
+		// Released without a posting in range: terms unknown
+		{JobEvent: JobEvent{Kind: JobEventReleased, TxHash: "0xf", BlockNumber: 40}, JobID: 5},
+	})
+
+	if len(escrows) != 2 {
+		t.Fatalf("Expected 2 escrows, got %+v", escrows)
+	}
+	if escrows[0].JobID != 4 || escrows[1].JobID != 9 {
+		t.Errorf("Expected escrows ordered by job ID, got %d and %d", escrows[0].JobID, escrows[1].JobID)
+	}
+
+	reposted := escrows[0]
+	if reposted.Record != (ChainRecord{PaymentStatus: "deposited", DepositTxHash: "0xe"}) {
+		t.Errorf("Expected the repost to be the live deposit, got %+v", reposted.Record)
+	}
+	if reposted.USDAmount.Int64() != 75 || reposted.BlockNumber != 12 || reposted.Events != 3 {
+		t.Errorf("Expected the latest posting's terms, got $%s at block %d over %d events", reposted.USDAmount, reposted.BlockNumber, reposted.Events)
+	}
+
+	released := escrows[1]
+	if released.Record.PaymentStatus != "released" || released.Record.ReleaseTxHash != "0xb" {
+		t.Errorf("Expected job 9 to be released, got %+v", released.Record)
+	}
+	if released.Client != client || released.Freelancer != freelancer {
+		t.Errorf("Expected the posting's wallets, got %s and %s", released.Client.Hex(), released.Freelancer.Hex())
+	}
+}
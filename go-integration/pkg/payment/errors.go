@@ -0,0 +1,247 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrorClass tells callers whether a failed chain operation is worth retrying
+type ErrorClass string
+
+const (
+	ErrorClassRetryable ErrorClass = "retryable"
+	ErrorClassPermanent ErrorClass = "permanent"
+)
+
+// Error reasons reported by ClassifyError
+const (
+	ReasonTimeout           = "timeout"
+	ReasonRateLimited       = "rate_limited"
+	ReasonNonceGap          = "nonce_gap"
+	ReasonUnavailable       = "provider_unavailable"
+	ReasonGasPriceTooHigh   = "gas_price_too_high"
+	ReasonReverted          = "reverted"
+	ReasonInsufficientFunds = "insufficient_funds"
+	ReasonInvalidParams     = "invalid_params"
+	ReasonPending           = "transaction_pending"
+	ReasonJobConflict       = "job_conflict"
+	ReasonPriceDeviation    = "price_deviation"
+	ReasonUnknown           = "unknown"
+)
+
+// rpcRateLimitCode is the JSON-RPC error code Infura and others use for request limits
+const rpcRateLimitCode = -32005
+
+// ClassifiedError wraps a chain error with its retry class and an actionable message
+type ClassifiedError struct {
+	Class    ErrorClass
+	Reason   string
+	Message  string
+	Contract *ContractError // the decoded revert, for ReasonReverted when recognisable
+	Err      error
+}
+
+func (e *ClassifiedError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the operation can safely be attempted again
+func (e *ClassifiedError) Retryable() bool {
+	return e.Class == ErrorClassRetryable
+}
+
+// TransactionPendingError is returned when a transaction was broadcast but its
+// receipt could not be obtained. The transaction must not be resubmitted.
+type TransactionPendingError struct {
+	TxHash string
+	Err    error
+}
+
+func (e *TransactionPendingError) Error() string {
+	return fmt.Sprintf("transaction %s sent but not confirmed: %v", e.TxHash, e.Err)
+}
+
+func (e *TransactionPendingError) Unwrap() error {
+	return e.Err
+}
+
+// NonceTakenError is returned when the node refuses a transaction because a
+// different one is already pending at its nonce. The nonce is given up, so a
+// retry is built on the next one.
+type NonceTakenError struct {
+	Nonce uint64
+	Err   error
+}
+
+func (e *NonceTakenError) Error() string {
+	return fmt.Sprintf("nonce %d is taken by another pending transaction: %v", e.Nonce, e.Err)
+}
+
+func (e *NonceTakenError) Unwrap() error {
+	return e.Err
+}
+
+// JobConflictError is returned when a job ID is already posted on-chain with
+// different terms, or can no longer be funded, so a retried post cannot be
+// treated as the original one
+type JobConflictError struct {
+	JobID  uint64
+	Reason string
+}
+
+func (e *JobConflictError) Error() string {
+	return fmt.Sprintf("job %d already exists on-chain: %s", e.JobID, e.Reason)
+}
+
+// PriceDeviationError is returned when the ETH/USD rate at submission has moved
+// further from the rate the operation was quoted at than is allowed
+type PriceDeviationError struct {
+	Quoted    *big.Int // rate when the funds were requested, 8 decimals
+	Current   *big.Int // rate at submission, 8 decimals
+	Percent   float64  // how far Current is from Quoted
+	Threshold float64  // largest deviation allowed, in percent
+}
+
+func (e *PriceDeviationError) Error() string {
+	return fmt.Sprintf("ETH/USD rate %s is %.2f%% from the quoted %s, more than the %.2f%% allowed", e.Current, e.Percent, e.Quoted, e.Threshold)
+}
+
+// PriceDeviation returns how far current is from quoted, in percent of quoted
+func PriceDeviation(quoted, current *big.Int) float64 {
+	diff := new(big.Float).SetInt(new(big.Int).Sub(current, quoted))
+	percent, _ := diff.Quo(diff, new(big.Float).SetInt(quoted)).Float64()
+	if percent < 0 {
+		percent = -percent
+	}
+	return percent * 100
+}
+
+// ClassifyError decides whether a chain error is transient (timeouts, rate
+// limits, nonce gaps, provider outages) or permanent (reverts, insufficient
+// funds, invalid parameters). Unrecognised errors are treated as retryable so
+// the retry queue's attempt limit bounds them. It returns nil for a nil error.
+func ClassifyError(err error) *ClassifiedError {
+	if err == nil {
+		return nil
+	}
+
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified
+	}
+
+	retryable := func(reason, message string) *ClassifiedError {
+		return &ClassifiedError{Class: ErrorClassRetryable, Reason: reason, Message: message, Err: err}
+	}
+	permanent := func(reason, message string) *ClassifiedError {
+		return &ClassifiedError{Class: ErrorClassPermanent, Reason: reason, Message: message, Err: err}
+	}
+
+	// A broadcast transaction must never be resubmitted, whatever went wrong afterwards
+	var pending *TransactionPendingError
+	if errors.As(err, &pending) {
+		return permanent(ReasonPending, fmt.Sprintf("transaction %s was broadcast; check /job-status for confirmation instead of retrying", pending.TxHash))
+	}
+
+	var taken *NonceTakenError
+	if errors.As(err, &taken) {
+		return retryable(ReasonNonceGap, "another transaction is pending at the signer's nonce; retrying builds on the next one")
+	}
+
+	var conflict *JobConflictError
+	if errors.As(err, &conflict) {
+		return permanent(ReasonJobConflict, "the job ID is already used on-chain by a different escrow; resync the job instead of posting it again")
+	}
+
+	var deviation *PriceDeviationError
+	if errors.As(err, &deviation) {
+		return permanent(ReasonPriceDeviation, "the ETH/USD rate has moved too far from the quote; confirm the current rate before funding")
+	}
+
+	var gasErr *GasPriceTooHighError
+	if errors.As(err, &gasErr) {
+		return retryable(ReasonGasPriceTooHigh, "network gas price is above the configured ceiling")
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return retryable(ReasonTimeout, "Ethereum RPC request timed out")
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return retryable(ReasonTimeout, "Ethereum RPC request timed out")
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode == http.StatusTooManyRequests {
+			return retryable(ReasonRateLimited, "Ethereum RPC provider rate limit reached")
+		}
+		if httpErr.StatusCode >= 500 {
+			return retryable(ReasonUnavailable, "Ethereum RPC provider is unavailable")
+		}
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.ErrorCode() {
+		case rpcRateLimitCode:
+			return retryable(ReasonRateLimited, "Ethereum RPC provider rate limit reached")
+		case -32602:
+			return permanent(ReasonInvalidParams, "the transaction parameters were rejected by the node; check addresses and amounts")
+		}
+	}
+
+	if contractErr := DecodeContractError(err); contractErr != nil {
+		classified := permanent(ReasonReverted, contractErr.Message)
+		classified.Contract = contractErr
+		return classified
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "execution reverted") || strings.Contains(msg, "revert"):
+		return permanent(ReasonReverted, "the escrow contract rejected the transaction; check the job's on-chain state")
+	case strings.Contains(msg, "insufficient funds"):
+		return permanent(ReasonInsufficientFunds, "the gateway wallet cannot cover gas and value; top up the signer account")
+	case strings.Contains(msg, "invalid argument") || strings.Contains(msg, "invalid params"):
+		return permanent(ReasonInvalidParams, "the transaction parameters were rejected by the node; check addresses and amounts")
+	case strings.Contains(msg, "nonce too low") || strings.Contains(msg, "nonce too high"):
+		return retryable(ReasonNonceGap, "the signer's nonce is out of sync with the network")
+	case isAlreadyKnown(err) || isUnderpriced(err):
+		// A transaction at this nonce is in the node's pool; resending would only race it
+		return permanent(ReasonPending, "a transaction with the signer's nonce is already pending; wait for it instead of retrying")
+	case strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests"):
+		return retryable(ReasonRateLimited, "Ethereum RPC provider rate limit reached")
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out"):
+		return retryable(ReasonTimeout, "Ethereum RPC request timed out")
+	case strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "eof") || strings.Contains(msg, "no such host"):
+		return retryable(ReasonUnavailable, "Ethereum RPC provider is unavailable")
+	}
+
+	return retryable(ReasonUnknown, "unexpected Ethereum RPC error")
+}
+
+// isAlreadyKnown reports whether the node refused a transaction because it
+// already has the very same one
+func isAlreadyKnown(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "already known")
+}
+
+// isUnderpriced reports whether the node refused a transaction because
+// another is pending at its nonce and this one doesn't pay enough more to
+// replace it
+func isUnderpriced(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "replacement transaction underpriced")
+}
@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type fakeRPCError struct {
+	code int
+	msg  string
+}
+
+func (e fakeRPCError) Error() string  { return e.msg }
+func (e fakeRPCError) ErrorCode() int { return e.code }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		class  ErrorClass
+		reason string
+	}{
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), ErrorClassRetryable, ReasonTimeout},
+		{"rate limit code", fakeRPCError{-32005, "daily request count exceeded"}, ErrorClassRetryable, ReasonRateLimited},
+		{"http 429", rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}, ErrorClassRetryable, ReasonRateLimited},
+		{"http 502", rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, ErrorClassRetryable, ReasonUnavailable},
+		{"nonce too low", errors.New("nonce too low"), ErrorClassRetryable, ReasonNonceGap},
+		{"nonce too high", errors.New("nonce too high"), ErrorClassRetryable, ReasonNonceGap},
+		{"already known", errors.New("already known"), ErrorClassPermanent, ReasonPending},
+		{"underpriced", errors.New("replacement transaction underpriced"), ErrorClassPermanent, ReasonPending},
+		{"nonce taken", &NonceTakenError{Nonce: 4, Err: errors.New("replacement transaction underpriced")}, ErrorClassRetryable, ReasonNonceGap},
+		{"gas ceiling", &GasPriceTooHighError{GasPrice: big.NewInt(2), Ceiling: big.NewInt(1)}, ErrorClassRetryable, ReasonGasPriceTooHigh},
+		{"revert", errors.New("execution reverted: Job already exists"), ErrorClassPermanent, ReasonReverted},
+		{"insufficient funds", errors.New("insufficient funds for gas * price + value"), ErrorClassPermanent, ReasonInsufficientFunds},
+		{"invalid params code", fakeRPCError{-32602, "invalid argument 0"}, ErrorClassPermanent, ReasonInvalidParams},
+		{"pending tx", &TransactionPendingError{TxHash: "0xabc", Err: context.DeadlineExceeded}, ErrorClassPermanent, ReasonPending},
+		{"job conflict", &JobConflictError{JobID: 7, Reason: "usd amount 300 does not match 250"}, ErrorClassPermanent, ReasonJobConflict},
+		{"price deviation", &PriceDeviationError{Quoted: big.NewInt(300000000000), Current: big.NewInt(330000000000), Percent: 10, Threshold: 2}, ErrorClassPermanent, ReasonPriceDeviation},
+		{"unknown", errors.New("something odd"), ErrorClassRetryable, ReasonUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classified := ClassifyError(tt.err)
+			if classified.Class != tt.class {
+				t.Errorf("Expected class %s, got %s", tt.class, classified.Class)
+			}
+			if classified.Reason != tt.reason {
+				t.Errorf("Expected reason %s, got %s", tt.reason, classified.Reason)
+			}
+		})
+	}
+
+	sentinel := errors.New("nonce too high")
+	if !errors.Is(ClassifyError(sentinel), sentinel) {
+		t.Errorf("Expected classified error to wrap the original")
+	}
+
+	if ClassifyError(nil) != nil {
+		t.Errorf("Expected nil for nil error")
+	}
+}
+
+func TestPriceDeviation(t *testing.T) {
+	quoted := big.NewInt(300000000000)
+	if got := PriceDeviation(quoted, big.NewInt(306000000000)); got < 1.999 || got > 2.001 {
+		t.Errorf("Expected a 2%% rise, got %f", got)
+	}
+	if got := PriceDeviation(quoted, big.NewInt(285000000000)); got < 4.999 || got > 5.001 {
+		t.Errorf("Expected a 5%% fall, got %f", got)
+	}
+	if got := PriceDeviation(quoted, quoted); got != 0 {
+		t.Errorf("Expected no deviation, got %f", got)
+	}
+}
@@ -0,0 +1,197 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// FeeStrategy is how eagerly a transaction bids for inclusion
+type FeeStrategy string
+
+// Fee strategies. Each tips at a percentile of the priority fees recent
+// blocks paid and caps the fee per gas at a multiple of the next block's base
+// fee plus that tip, so a transaction survives the base fee rising for a few
+// blocks while it waits.
+const (
+	FeeSlow     FeeStrategy = "slow"     // 10th percentile tip; 1.25x base fee headroom
+	FeeStandard FeeStrategy = "standard" // median tip; 2x base fee headroom
+	FeeFast     FeeStrategy = "fast"     // 90th percentile tip; 2x base fee headroom
+)
+
+// FeeStrategies lists the accepted fee strategies
+var FeeStrategies = []FeeStrategy{FeeSlow, FeeStandard, FeeFast}
+
+type feeStrategyParams struct {
+	percentile     int   // index into feeHistoryPercentiles
+	baseFeePercent int64 // of the next block's base fee the fee cap allows for
+}
+
+var (
+	feeHistoryPercentiles = []float64{10, 50, 90}
+	feeStrategyTable      = map[FeeStrategy]feeStrategyParams{
+		FeeSlow:     {percentile: 0, baseFeePercent: 125},
+		FeeStandard: {percentile: 1, baseFeePercent: 200},
+		FeeFast:     {percentile: 2, baseFeePercent: 200},
+	}
+)
+
+// feeHistoryBlocks is how many recent blocks tips are learned from
+const feeHistoryBlocks = 20
+
+// ParseFeeStrategy parses a fee strategy name. An empty name returns "",
+// leaving the choice to the caller's default.
+func ParseFeeStrategy(s string) (FeeStrategy, error) {
+	strategy := FeeStrategy(s)
+	if _, ok := feeStrategyTable[strategy]; ok || s == "" {
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid fee strategy %q: expected one of %v", s, FeeStrategies)
+}
+
+// Fees is what a transaction offers for gas. On chains with EIP-1559 the
+// base fee, tip and fee cap are set; on older chains only GasPrice is.
+type Fees struct {
+	Strategy FeeStrategy
+	BaseFee  *big.Int // of the next block
+	TipCap   *big.Int // maxPriorityFeePerGas
+	FeeCap   *big.Int // maxFeePerGas
+	GasPrice *big.Int // legacy chains only
+}
+
+// Expected is the price per gas the transaction pays if the base fee holds
+func (f *Fees) Expected() *big.Int {
+	if f.GasPrice != nil {
+		return f.GasPrice
+	}
+	return new(big.Int).Add(f.BaseFee, f.TipCap)
+}
+
+type feeStrategyKey struct{}
+
+// WithFeeStrategy returns a context whose transactions are priced by strategy
+func WithFeeStrategy(ctx context.Context, strategy FeeStrategy) context.Context {
+	return context.WithValue(ctx, feeStrategyKey{}, strategy)
+}
+
+// feeStrategy is the strategy ctx asks for, or FEE_STRATEGY
+func (c *Client) feeStrategy(ctx context.Context) FeeStrategy {
+	if strategy, _ := ctx.Value(feeStrategyKey{}).(FeeStrategy); strategy != "" {
+		return strategy
+	}
+	if strategy := FeeStrategy(c.config.FeeStrategy); strategy != "" {
+		return strategy
+	}
+	return FeeStandard
+}
+
+// SuggestFees prices a transaction under strategy from the network's recent
+// fee history, falling back to the legacy gas price on chains without a base
+// fee
+func (c *Client) SuggestFees(ctx context.Context, strategy FeeStrategy) (*Fees, error) {
+	if _, ok := feeStrategyTable[strategy]; !ok {
+		return nil, fmt.Errorf("unknown fee strategy %q", strategy)
+	}
+
+	history, err := c.ethClient.FeeHistory(ctx, feeHistoryBlocks, nil, feeHistoryPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	fees := feesFromHistory(history, strategy)
+	if fees == nil {
+		gasPrice, err := c.ethClient.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Fees{Strategy: strategy, GasPrice: gasPrice}, nil
+	}
+	if fees.TipCap.Sign() == 0 {
+		// Recent blocks were empty or tipless; ask the node what it would accept
+		tip, err := c.ethClient.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fees.TipCap = tip
+		fees.FeeCap = feeCap(fees.BaseFee, tip, feeStrategyTable[strategy].baseFeePercent)
+	}
+	return fees, nil
+}
+
+// feesFromHistory prices strategy from a fee history, or returns nil when the
+// chain has no base fee. The tip is the median across blocks of the
+// strategy's percentile, so one block of outliers doesn't set it.
+func feesFromHistory(history *ethereum.FeeHistory, strategy FeeStrategy) *Fees {
+	if history == nil || len(history.BaseFee) == 0 {
+		return nil
+	}
+	baseFee := history.BaseFee[len(history.BaseFee)-1] // the next block's
+	if baseFee == nil || baseFee.Sign() == 0 {
+		return nil
+	}
+
+	params := feeStrategyTable[strategy]
+	var tips []*big.Int
+	for _, rewards := range history.Reward {
+		if params.percentile < len(rewards) && rewards[params.percentile] != nil {
+			tips = append(tips, rewards[params.percentile])
+		}
+	}
+	tip := new(big.Int)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		tip.Set(tips[len(tips)/2])
+	}
+
+	return &Fees{
+		Strategy: strategy,
+		BaseFee:  new(big.Int).Set(baseFee),
+		TipCap:   tip,
+		FeeCap:   feeCap(baseFee, tip, params.baseFeePercent),
+	}
+}
+
+// feeCap allows for baseFeePercent of baseFee plus tip
+func feeCap(baseFee, tip *big.Int, baseFeePercent int64) *big.Int {
+	maxFee := new(big.Int).Mul(baseFee, big.NewInt(baseFeePercent))
+	maxFee.Quo(maxFee, big.NewInt(100))
+	return maxFee.Add(maxFee, tip)
+}
+
+// fees applies the preset to suggested fees when MAX_GAS_PRICE is ceiling.
+// It returns a *GasPriceTooHighError when the expected price is over the
+// preset's ceiling; otherwise the fee cap is held to the ceiling, so a rising
+// base fee can't make the transaction pay more than it allows. PricePercent
+// raises the tip, capped like the fee cap.
+func (p GasPreset) fees(suggested *Fees, ceiling *big.Int) (*Fees, error) {
+	if suggested.GasPrice != nil {
+		gasPrice, err := p.price(suggested.GasPrice, ceiling)
+		if err != nil {
+			return nil, err
+		}
+		return &Fees{Strategy: suggested.Strategy, GasPrice: gasPrice}, nil
+	}
+
+	if p.MaxGasPrice > 0 {
+		ceiling = gweiToWei(p.MaxGasPrice)
+	}
+	if expected := suggested.Expected(); ceiling != nil && expected.Cmp(ceiling) > 0 {
+		return nil, &GasPriceTooHighError{GasPrice: expected, Ceiling: ceiling}
+	}
+
+	tip := new(big.Int).Set(suggested.TipCap)
+	if p.PricePercent > 0 {
+		tip.Mul(tip, big.NewInt(p.PricePercent))
+		tip.Quo(tip, big.NewInt(100))
+	}
+	maxFee := feeCap(suggested.BaseFee, tip, feeStrategyTable[suggested.Strategy].baseFeePercent)
+	if ceiling != nil && maxFee.Cmp(ceiling) > 0 {
+		maxFee.Set(ceiling)
+	}
+	if tip.Cmp(maxFee) > 0 {
+		tip.Set(maxFee)
+	}
+	return &Fees{Strategy: suggested.Strategy, BaseFee: suggested.BaseFee, TipCap: tip, FeeCap: maxFee}, nil
+}
@@ -0,0 +1,101 @@
+package payment
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+func TestFeesFromHistory(t *testing.T) {
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+	history := &ethereum.FeeHistory{
+		BaseFee: []*big.Int{gwei(8), gwei(9), gwei(10), gwei(10)},
+		Reward: [][]*big.Int{
+			{gwei(1), gwei(2), gwei(5)},
+			{gwei(1), gwei(3), gwei(40)}, // one block of outliers
+			{gwei(1), gwei(2), gwei(6)},
+		},
+	}
+
+	tests := []struct {
+		strategy FeeStrategy
+		tip      *big.Int
+		feeCap   *big.Int
+	}{
+		{strategy: FeeSlow, tip: gwei(1), feeCap: new(big.Int).Add(big.NewInt(12_500_000_000), gwei(1))},
+		{strategy: FeeStandard, tip: gwei(2), feeCap: gwei(22)},
+		{strategy: FeeFast, tip: gwei(6), feeCap: gwei(26)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			fees := feesFromHistory(history, tt.strategy)
+			if fees == nil {
+				t.Fatalf("Expected EIP-1559 fees")
+			}
+			if fees.BaseFee.Cmp(gwei(10)) != 0 || fees.TipCap.Cmp(tt.tip) != 0 || fees.FeeCap.Cmp(tt.feeCap) != 0 {
+				t.Errorf("fees = base %s tip %s cap %s; want base 10 Gwei tip %s cap %s", fees.BaseFee, fees.TipCap, fees.FeeCap, tt.tip, tt.feeCap)
+			}
+			if fees.GasPrice != nil {
+				t.Errorf("Expected no legacy gas price, got %s", fees.GasPrice)
+			}
+		})
+	}
+
+	if fees := feesFromHistory(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(0)}}, FeeStandard); fees != nil {
+		t.Errorf("Expected nil fees on a chain without a base fee, got %+v", fees)
+	}
+}
+
+func TestGasPresetFees(t *testing.T) {
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+	standard := &Fees{Strategy: FeeStandard, BaseFee: gwei(20), TipCap: gwei(2), FeeCap: gwei(42)}
+	tests := []struct {
+		name      string
+		preset    GasPreset
+		suggested *Fees
+		ceiling   *big.Int
+		tip       *big.Int
+		feeCap    *big.Int
+		tooHigh   bool
+	}{
+		{name: "unchanged", suggested: standard, tip: gwei(2), feeCap: gwei(42)},
+		{name: "fee cap held to the ceiling", suggested: standard, ceiling: gwei(30), tip: gwei(2), feeCap: gwei(30)},
+		{name: "premium tip", preset: GasPreset{PricePercent: 150}, suggested: standard, tip: gwei(3), feeCap: gwei(43)},
+		{name: "over the ceiling", suggested: standard, ceiling: gwei(21), tooHigh: true},
+		{name: "own ceiling", preset: GasPreset{MaxGasPrice: 25}, suggested: standard, ceiling: gwei(21), tip: gwei(2), feeCap: gwei(25)},
+		{name: "tip within the cap", preset: GasPreset{PricePercent: 1000}, suggested: standard, ceiling: gwei(30), tip: gwei(20), feeCap: gwei(30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.preset.fees(tt.suggested, tt.ceiling)
+			if tt.tooHigh {
+				var tooHigh *GasPriceTooHighError
+				if !errors.As(err, &tooHigh) || tooHigh.GasPrice.Cmp(gwei(22)) != 0 {
+					t.Fatalf("fees = %+v, %v; want a GasPriceTooHighError at 22 Gwei", got, err)
+				}
+				return
+			}
+			if err != nil || got.TipCap.Cmp(tt.tip) != 0 || got.FeeCap.Cmp(tt.feeCap) != 0 {
+				t.Errorf("fees = %+v, %v; want tip %s cap %s", got, err, tt.tip, tt.feeCap)
+			}
+		})
+	}
+
+	legacy, err := GasPreset{PricePercent: 150}.fees(&Fees{Strategy: FeeStandard, GasPrice: gwei(20)}, nil)
+	if err != nil || legacy.GasPrice.Cmp(gwei(30)) != 0 || legacy.FeeCap != nil {
+		t.Errorf("Expected a legacy gas price of 30 Gwei, got %+v, %v", legacy, err)
+	}
+}
+
+func TestParseFeeStrategy(t *testing.T) {
+	for _, name := range []string{"", "slow", "standard", "fast"} {
+		if strategy, err := ParseFeeStrategy(name); err != nil || string(strategy) != name {
+			t.Errorf("ParseFeeStrategy(%q) = %q, %v", name, strategy, err)
+		}
+	}
+	if _, err := ParseFeeStrategy("turbo"); err == nil {
+		t.Errorf("Expected an unknown strategy to be refused")
+	}
+}
@@ -0,0 +1,145 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Funding outcomes of a client deposit measured against its quote
+const (
+	FundingExact       = "exact"
+	FundingUnderfunded = "underfunded"
+	FundingOverfunded  = "overfunded"
+)
+
+// FundingCheck compares what a client deposited with what they were quoted
+type FundingCheck struct {
+	Status    string
+	Required  *big.Int
+	Deposited *big.Int
+	TopUp     *big.Int // wei still owed; zero unless underfunded
+	Excess    *big.Int // wei sent beyond the quote; zero unless overfunded
+}
+
+// Deposit is the transaction that funded a job's escrow
+type Deposit struct {
+	TxHash      string
+	BlockNumber uint64
+	Value       *big.Int // wei sent with the postJob call
+}
+
+// TopUpJobIDOffset separates escrow top-up job IDs from application IDs and
+// from retainer periods, which start at 1<<40
+const TopUpJobIDOffset uint64 = 1 << 41
+
+// TopUpJobID returns the on-chain job ID used for an escrow top-up
+func TopUpJobID(topUpID int64) uint64 {
+	return TopUpJobIDOffset + uint64(topUpID)
+}
+
+// CheckFunding classifies a deposit against the required amount
+func CheckFunding(deposited, required *big.Int) FundingCheck {
+	check := FundingCheck{
+		Status:    FundingExact,
+		Required:  required,
+		Deposited: deposited,
+		TopUp:     new(big.Int),
+		Excess:    new(big.Int),
+	}
+
+	switch deposited.Cmp(required) {
+	case -1:
+		check.Status = FundingUnderfunded
+		check.TopUp.Sub(required, deposited)
+	case 1:
+		check.Status = FundingOverfunded
+		check.Excess.Sub(deposited, required)
+	}
+	return check
+}
+
+// GetJobDeposit returns the transaction that posted a job's current escrow and
+// the wei it carried, or nil if the job has not been posted since the deploy
+// block. The contract stores only the amount it required, so the value sent
+// is read from the transaction itself; deposits made through another
+// contract's internal call are not visible this way.
+func (c *Client) GetJobDeposit(ctx context.Context, jobID uint64) (*Deposit, error) {
+	history, err := c.GetJobHistory(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	posted := latestPosted(history)
+	if posted == nil {
+		return nil, nil
+	}
+
+	tx, _, err := c.ethClient.TransactionByHash(ctx, common.HexToHash(posted.TxHash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deposit transaction %s: %w", posted.TxHash, err)
+	}
+
+	return &Deposit{TxHash: posted.TxHash, BlockNumber: posted.BlockNumber, Value: tx.Value()}, nil
+}
+
+// BlockTime returns when a block was mined
+func (c *Client) BlockTime(ctx context.Context, blockNumber uint64) (time.Time, error) {
+	header, err := c.ethClient.HeaderByNumber(ctx, new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get block %d: %w", blockNumber, err)
+	}
+	return time.Unix(int64(header.Time), 0).UTC(), nil
+}
+
+// PostJobURI returns an EIP-681 payment request for a client to fund a job
+// themselves, calling postJob on contract with value wei. Wallets that scan it
+// as a QR code fill in the call and the amount.
+func PostJobURI(contract common.Address, chainID int64, jobID uint64, freelancer common.Address, usdAmount *big.Int, client common.Address, value *big.Int) string {
+	params := []string{
+		"uint256=" + new(big.Int).SetUint64(jobID).String(),
+		"address=" + freelancer.Hex(),
+		"uint256=" + usdAmount.String(),
+		"address=" + client.Hex(),
+		"value=" + value.String(),
+	}
+	return fmt.Sprintf("ethereum:%s@%d/postJob?%s", contract.Hex(), chainID, strings.Join(params, "&"))
+}
+
+// latestPosted returns the most recent JobPosted event, since a cancelled job can be posted again
+func latestPosted(events []JobEvent) *JobEvent {
+	var latest *JobEvent
+	for i := range events {
+		event := &events[i]
+		if event.Kind != JobEventPosted {
+			continue
+		}
+		if latest == nil || event.BlockNumber > latest.BlockNumber ||
+			(event.BlockNumber == latest.BlockNumber && event.LogIndex > latest.LogIndex) {
+			latest = event
+		}
+	}
+	return latest
+}
+
+// MatchExistingJob compares a job already stored on-chain with a post that
+// would create it again. It returns nil when the post is a retry of the one
+// that funded the job, and a *JobConflictError when the terms differ or the
+// escrow has already been released.
+func MatchExistingJob(jobID uint64, job *JobDetails, freelancer common.Address, usdAmount *big.Int, client common.Address) error {
+	switch {
+	case job.Client != client:
+		return &JobConflictError{JobID: jobID, Reason: fmt.Sprintf("client %s does not match %s", job.Client.Hex(), client.Hex())}
+	case job.Freelancer != freelancer:
+		return &JobConflictError{JobID: jobID, Reason: fmt.Sprintf("freelancer %s does not match %s", job.Freelancer.Hex(), freelancer.Hex())}
+	case job.USDAmount == nil || job.USDAmount.Cmp(usdAmount) != 0:
+		return &JobConflictError{JobID: jobID, Reason: fmt.Sprintf("usd amount %s does not match %s", job.USDAmount, usdAmount)}
+	case job.IsCompleted || job.IsPaid:
+		return &JobConflictError{JobID: jobID, Reason: "escrow has already been released"}
+	}
+	return nil
+}
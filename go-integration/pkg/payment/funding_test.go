@@ -0,0 +1,104 @@
+package payment
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCheckFunding(t *testing.T) {
+	tests := []struct {
+		name      string
+		deposited int64
+		required  int64
+		status    string
+		topUp     int64
+		excess    int64
+	}{
+		{"exact", 1000, 1000, FundingExact, 0, 0},
+		{"underfunded", 900, 1000, FundingUnderfunded, 100, 0},
+		{"overfunded", 1003, 1000, FundingOverfunded, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := CheckFunding(big.NewInt(tt.deposited), big.NewInt(tt.required))
+			if check.Status != tt.status {
+				t.Errorf("Expected status %s, got %s", tt.status, check.Status)
+			}
+			if check.TopUp.Int64() != tt.topUp {
+				t.Errorf("Expected top-up %d, got %s", tt.topUp, check.TopUp)
+			}
+			if check.Excess.Int64() != tt.excess {
+				t.Errorf("Expected excess %d, got %s", tt.excess, check.Excess)
+			}
+		})
+	}
+}
+
+func TestLatestPosted(t *testing.T) {
+	events := []JobEvent{
+		{Kind: JobEventPosted, TxHash: "0x1", BlockNumber: 10},
+		{Kind: JobEventCancelled, TxHash: "0x2", BlockNumber: 12},
+		{Kind: JobEventPosted, TxHash: "0x3", BlockNumber: 15, LogIndex: 2},
+		{Kind: JobEventPosted, TxHash: "0x4", BlockNumber: 15, LogIndex: 1},
+	}
+
+	if latest := latestPosted(events); latest == nil || latest.TxHash != "0x3" {
+		t.Errorf("Expected latest deposit 0x3, got %+v", latest)
+	}
+	if latest := latestPosted(events[1:2]); latest != nil {
+		t.Errorf("Expected no deposit, got %+v", latest)
+	}
+}
+
+func TestMatchExistingJob(t *testing.T) {
+	client := common.HexToAddress("0xc1")
+	freelancer := common.HexToAddress("0xf1")
+	stored := func() *JobDetails {
+		return &JobDetails{Client: client, Freelancer: freelancer, USDAmount: big.NewInt(250), ETHAmount: big.NewInt(83333333)}
+	}
+
+	if err := MatchExistingJob(7, stored(), freelancer, big.NewInt(250), client); err != nil {
+		t.Errorf("Expected a retry of the same post to match, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(job *JobDetails)
+	}{
+		{"client", func(job *JobDetails) { job.Client = common.HexToAddress("0xc2") }},
+		{"freelancer", func(job *JobDetails) { job.Freelancer = common.HexToAddress("0xf2") }},
+		{"usd amount", func(job *JobDetails) { job.USDAmount = big.NewInt(300) }},
+		{"released", func(job *JobDetails) { job.IsCompleted, job.IsPaid = true, true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := stored()
+			tt.modify(job)
+			var conflict *JobConflictError
+			if err := MatchExistingJob(7, job, freelancer, big.NewInt(250), client); !errors.As(err, &conflict) {
+				t.Fatalf("Expected a job conflict, got %v", err)
+			}
+			if conflict.JobID != 7 {
+				t.Errorf("Expected job 7 in the conflict, got %d", conflict.JobID)
+			}
+		})
+	}
+}
+
+func TestPostJobURI(t *testing.T) {
+	uri := PostJobURI(
+		common.HexToAddress("0x00000000000000000000000000000000000000e1"), 11155111, 7,
+		common.HexToAddress("0x00000000000000000000000000000000000000f1"), big.NewInt(250),
+		common.HexToAddress("0x00000000000000000000000000000000000000c1"), big.NewInt(833333333),
+	)
+	expected := "ethereum:0x00000000000000000000000000000000000000e1@11155111/postJob" +
+		"?uint256=7&address=0x00000000000000000000000000000000000000f1&uint256=250" +
+		"&address=0x00000000000000000000000000000000000000C1&value=833333333"
+	if uri != expected {
+		t.Errorf("Expected %s, got %s", expected, uri)
+	}
+}
@@ -0,0 +1,161 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Kinds of escrow events in a job's history
+const (
+	JobEventPosted    = "posted"
+	JobEventReleased  = "released"
+	JobEventCancelled = "cancelled"
+)
+
+// JobEvent is one escrow event emitted for a job
+type JobEvent struct {
+	Kind        string
+	TxHash      string
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// ChainRecord is the payment status and transaction hashes implied by chain state
+type ChainRecord struct {
+	PaymentStatus paymentstatus.Status
+	DepositTxHash string
+	ReleaseTxHash string
+	RefundTxHash  string
+}
+
+// DeriveChainRecord reconstructs a job's payment record from its events, using
+// the current on-chain details when the events don't cover the job (e.g. it
+// was posted before the configured deploy block). A cancelled job is deleted
+// on-chain and may be posted again, so only the latest cycle counts.
+func DeriveChainRecord(events []JobEvent, details *JobDetails) ChainRecord {
+	sorted := append([]JobEvent(nil), events...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].BlockNumber != sorted[j].BlockNumber {
+			return sorted[i].BlockNumber < sorted[j].BlockNumber
+		}
+		return sorted[i].LogIndex < sorted[j].LogIndex
+	})
+
+	record := ChainRecord{PaymentStatus: paymentstatus.PendingDeposit}
+	for _, event := range sorted {
+		switch event.Kind {
+		case JobEventPosted:
+			record = ChainRecord{PaymentStatus: paymentstatus.Deposited, DepositTxHash: event.TxHash}
+		case JobEventReleased:
+			record.PaymentStatus = paymentstatus.Released
+			record.ReleaseTxHash = event.TxHash
+		case JobEventCancelled:
+			record.PaymentStatus = paymentstatus.Refunded
+			record.RefundTxHash = event.TxHash
+		}
+	}
+
+	if len(sorted) == 0 && details != nil && details.Client != (common.Address{}) {
+		record.PaymentStatus = paymentstatus.Deposited
+		if details.IsPaid {
+			record.PaymentStatus = paymentstatus.Released
+		}
+	}
+
+	return record
+}
+
+// GetJobHistory returns every JobPosted, PaymentReleased and JobCancelled event
+// for a job since the contract's deploy block
+func (c *Client) GetJobHistory(ctx context.Context, jobID uint64) ([]JobEvent, error) {
+	escrowEvents, err := c.scanEscrowEvents(ctx, c.config.ContractDeployBlock, nil, new(big.Int).SetUint64(jobID))
+	if err != nil {
+		return nil, err
+	}
+	events := make([]JobEvent, len(escrowEvents))
+	for i, event := range escrowEvents {
+		events[i] = event.JobEvent
+	}
+	return events, nil
+}
+
+// scanEscrowEvents reads the escrow events between start and end (nil for the
+// latest block), keeping only those of jobID when it is set
+func (c *Client) scanEscrowEvents(ctx context.Context, start uint64, end *uint64, jobID *big.Int) ([]EscrowEvent, error) {
+	opts := &bind.FilterOpts{Start: start, End: end, Context: ctx}
+	contract := c.escrow.Load().contract
+	// IDs beyond uint64 can't belong to an application or a retainer, so they are skipped
+	matches := func(id *big.Int) bool { return id.IsUint64() && (jobID == nil || id.Cmp(jobID) == 0) }
+	var events []EscrowEvent
+
+	posted, err := contract.FilterJobPosted(opts, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter JobPosted events: %w", err)
+	}
+	for posted.Next() {
+		if e := posted.Event; matches(e.JobId) {
+			events = append(events, EscrowEvent{
+				JobEvent:   JobEvent{JobEventPosted, e.Raw.TxHash.Hex(), e.Raw.BlockNumber, e.Raw.Index},
+				BlockHash:  e.Raw.BlockHash.Hex(),
+				JobID:      e.JobId.Uint64(),
+				Client:     e.Client,
+				Freelancer: e.Freelancer,
+				USDAmount:  e.UsdAmount,
+				ETHAmount:  e.EthAmount,
+			})
+		}
+	}
+	if err := posted.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read JobPosted events: %w", err)
+	}
+	posted.Close()
+
+	released, err := contract.FilterPaymentReleased(opts, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter PaymentReleased events: %w", err)
+	}
+	for released.Next() {
+		if e := released.Event; matches(e.JobId) {
+			events = append(events, EscrowEvent{
+				JobEvent:   JobEvent{JobEventReleased, e.Raw.TxHash.Hex(), e.Raw.BlockNumber, e.Raw.Index},
+				BlockHash:  e.Raw.BlockHash.Hex(),
+				JobID:      e.JobId.Uint64(),
+				Freelancer: e.Freelancer,
+				ETHAmount:  e.EthAmount,
+			})
+		}
+	}
+	if err := released.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read PaymentReleased events: %w", err)
+	}
+	released.Close()
+
+	cancelled, err := contract.FilterJobCancelled(opts, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter JobCancelled events: %w", err)
+	}
+	for cancelled.Next() {
+		if e := cancelled.Event; matches(e.JobId) {
+			events = append(events, EscrowEvent{
+				JobEvent:  JobEvent{JobEventCancelled, e.Raw.TxHash.Hex(), e.Raw.BlockNumber, e.Raw.Index},
+				BlockHash: e.Raw.BlockHash.Hex(),
+				JobID:     e.JobId.Uint64(),
+				Client:    e.Client,
+				ETHAmount: e.EthAmount,
+			})
+		}
+	}
+	if err := cancelled.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read JobCancelled events: %w", err)
+	}
+	cancelled.Close()
+
+	return events, nil
+}
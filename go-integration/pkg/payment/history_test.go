@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDeriveChainRecord(t *testing.T) {
+	client := common.HexToAddress("0x1234567890123456789012345678901234567890")
+
+	tests := []struct {
+		name     string
+		events   []JobEvent
+		details  *JobDetails
+		expected ChainRecord
+	}{
+		{
+			name:     "never posted",
+			details:  &JobDetails{},
+			expected: ChainRecord{PaymentStatus: "pending_deposit"},
+		},
+		{
+			name:     "posted",
+			events:   []JobEvent{{Kind: JobEventPosted, TxHash: "0xa", BlockNumber: 10}},
+			expected: ChainRecord{PaymentStatus: "deposited", DepositTxHash: "0xa"},
+		},
+		{
+			name: "released out of order",
+			events: []JobEvent{
+				{Kind: JobEventReleased, TxHash: "0xb", BlockNumber: 20},
+				{Kind: JobEventPosted, TxHash: "0xa", BlockNumber: 10},
+			},
+			expected: ChainRecord{PaymentStatus: "released", DepositTxHash: "0xa", ReleaseTxHash: "0xb"},
+		},
+		{
+			name: "reposted after refund",
+			events: []JobEvent{
+				{Kind: JobEventPosted, TxHash: "0xa", BlockNumber: 10},
+				{Kind: JobEventCancelled, TxHash: "0xc", BlockNumber: 11},
+				{Kind: JobEventPosted, TxHash: "0xd", BlockNumber: 12},
+			},
+			expected: ChainRecord{PaymentStatus: "deposited", DepositTxHash: "0xd"},
+		},
+		{
+			name:     "paid before deploy block",
+			details:  &JobDetails{Client: client, IsCompleted: true, IsPaid: true},
+			expected: ChainRecord{PaymentStatus: "released"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeriveChainRecord(tt.events, tt.details); got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// NonceSource reports an account's next nonce counting the transactions in
+// the node's pending pool
+type NonceSource interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// NonceManager hands out each signer account's nonces so concurrent
+// transactions never build on the same one. A nonce is held from Acquire
+// until the transaction built with it is sent or abandoned, and only a sent
+// transaction moves the account on; the wait for it to be mined doesn't hold
+// the account.
+//
+// Nothing is kept across restarts: an account's first nonce comes from the
+// node, whose pending count includes whatever a crashed process broadcast.
+// Each Acquire also asks the node and takes its nonce when it is ahead, as it
+// is after a send that failed here but reached the node, or after another
+// process used the key. While the node is behind, as it may be just after a
+// send or behind a lagging failover provider, the manager's own count is
+// trusted for resyncAfter since the last send. Past that the missing
+// transactions are taken to have been dropped and the node's nonce is used
+// again, so a lost transaction doesn't leave a gap that stalls the account.
+//
+// A send that errors may still have reached the node, so the transaction last
+// signed with a nonce that is handed out again is kept as Unsent for the next
+// lease. A retry that the node refuses as underpriced can then be matched to
+// it and waited for rather than failed.
+type NonceManager struct {
+	source      NonceSource
+	resyncAfter time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	accounts map[common.Address]*accountNonces
+}
+
+type accountNonces struct {
+	held     chan struct{} // full while a lease is out; a channel so Acquire can give up with its context
+	next     uint64
+	known    bool // next has been read from the node
+	lastSent time.Time
+	unsent   map[uint64]*types.Transaction // by nonce, signed but refused or lost on the way to the node
+}
+
+// NewNonceManager returns a manager syncing from source. resyncAfter of 0
+// or less defaults to one minute.
+func NewNonceManager(source NonceSource, resyncAfter time.Duration) *NonceManager {
+	if resyncAfter <= 0 {
+		resyncAfter = time.Minute
+	}
+	return &NonceManager{source: source, resyncAfter: resyncAfter, now: time.Now, accounts: make(map[common.Address]*accountNonces)}
+}
+
+func (m *NonceManager) account(address common.Address) *accountNonces {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accounts[address]
+	if !ok {
+		a = &accountNonces{held: make(chan struct{}, 1)}
+		m.accounts[address] = a
+	}
+	return a
+}
+
+// NonceLease is a nonce held for one transaction. Done must be called once
+// the transaction is sent or abandoned, or the account can't send again.
+type NonceLease struct {
+	Nonce uint64
+
+	manager  *NonceManager
+	account  *accountNonces
+	signed   *types.Transaction
+	released bool
+}
+
+// Acquire holds account's next nonce, waiting while another transaction of
+// the account holds one
+func (m *NonceManager) Acquire(ctx context.Context, address common.Address) (*NonceLease, error) {
+	a := m.account(address)
+	select {
+	case a.held <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	pending, err := m.source.PendingNonceAt(ctx, address)
+	if err != nil {
+		<-a.held
+		return nil, err
+	}
+	switch {
+	case !a.known || pending >= a.next:
+		a.next, a.known = pending, true
+	case m.now().Sub(a.lastSent) >= m.resyncAfter:
+		log.Printf("Warning: Node still reports nonce %d for %s, %s after nonce %d was sent; re-syncing from the node", pending, address.Hex(), m.resyncAfter, a.next-1)
+		a.next = pending
+	}
+	for nonce := range a.unsent {
+		if nonce < a.next {
+			delete(a.unsent, nonce)
+		}
+	}
+	return &NonceLease{Nonce: a.next, manager: m, account: a}, nil
+}
+
+// Signed records tx as signed with the lease's nonce, so that if its send
+// fails the next lease of the nonce reports it as Unsent
+func (l *NonceLease) Signed(tx *types.Transaction) {
+	l.signed = tx
+}
+
+// Unsent is the transaction last signed with the lease's nonce by a lease
+// whose send failed, or nil
+func (l *NonceLease) Unsent() *types.Transaction {
+	return l.account.unsent[l.Nonce]
+}
+
+// Done releases the lease. A nil sendErr means a transaction was broadcast
+// with the nonce, so the next lease takes the one after; otherwise the nonce
+// is handed out again. Calls after the first do nothing.
+func (l *NonceLease) Done(sendErr error) {
+	if l.released {
+		return
+	}
+	l.released = true
+	if sendErr == nil {
+		l.account.next = l.Nonce + 1
+		l.account.lastSent = l.manager.now()
+	} else if l.signed != nil {
+		if l.account.unsent == nil {
+			l.account.unsent = make(map[uint64]*types.Transaction)
+		}
+		l.account.unsent[l.Nonce] = l.signed
+	}
+	<-l.account.held
+}
@@ -0,0 +1,197 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// staticNonces reports a fixed pending nonce, as a node that hasn't seen
+// the manager's transactions yet would
+type staticNonces struct {
+	mu      sync.Mutex
+	pending uint64
+	err     error
+}
+
+func (s *staticNonces) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending, s.err
+}
+
+func (s *staticNonces) set(pending uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = pending
+}
+
+func TestNonceManagerConcurrentSends(t *testing.T) {
+	source := &staticNonces{pending: 5}
+	manager := NewNonceManager(source, time.Minute)
+	account := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+
+	var mu sync.Mutex
+	var nonces []uint64
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lease, err := manager.Acquire(context.Background(), account)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			nonces = append(nonces, lease.Nonce)
+			mu.Unlock()
+			lease.Done(nil)
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(nonces)
+	for i, nonce := range nonces {
+		if nonce != uint64(5+i) {
+			t.Fatalf("Expected nonces 5 to 24 once each, got %v", nonces)
+		}
+	}
+
+	// Another account starts from its own pending nonce
+	lease, err := manager.Acquire(context.Background(), common.HexToAddress("0x00000000000000000000000000000000000000a2"))
+	if err != nil || lease.Nonce != 5 {
+		t.Errorf("Expected a second account to start at 5, got %+v, %v", lease, err)
+	}
+}
+
+func TestNonceManagerResync(t *testing.T) {
+	source := &staticNonces{pending: 3}
+	manager := NewNonceManager(source, time.Minute)
+	now := time.Unix(1700000000, 0)
+	manager.now = func() time.Time { return now }
+	account := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+
+	acquire := func() *NonceLease {
+		t.Helper()
+		lease, err := manager.Acquire(context.Background(), account)
+		if err != nil {
+			t.Fatalf("Failed to acquire a nonce: %v", err)
+		}
+		return lease
+	}
+
+	// A failed send hands the nonce out again
+	lease := acquire()
+	lease.Done(errors.New("replacement transaction underpriced"))
+	lease.Done(nil) // ignored
+	if lease := acquire(); lease.Nonce != 3 {
+		t.Fatalf("Expected nonce 3 again after a failed send, got %d", lease.Nonce)
+	} else {
+		lease.Done(nil)
+	}
+
+	// The node lagging behind a send doesn't roll the manager back
+	if lease := acquire(); lease.Nonce != 4 {
+		t.Fatalf("Expected nonce 4 while the node catches up, got %d", lease.Nonce)
+	} else {
+		lease.Done(nil)
+	}
+
+	// The key used elsewhere moves the node ahead
+	source.set(9)
+	if lease := acquire(); lease.Nonce != 9 {
+		t.Fatalf("Expected the node's nonce 9, got %d", lease.Nonce)
+	} else {
+		lease.Done(nil)
+	}
+
+	// Nonce 9 was dropped: after resyncAfter the node's nonce is used again
+	now = now.Add(2 * time.Minute)
+	if lease := acquire(); lease.Nonce != 9 {
+		t.Fatalf("Expected a re-sync to nonce 9, got %d", lease.Nonce)
+	} else {
+		lease.Done(errors.New("not sent"))
+	}
+
+	// A restarted gateway starts from the node, counting what it broadcast
+	source.set(12)
+	if lease, err := NewNonceManager(source, time.Minute).Acquire(context.Background(), account); err != nil || lease.Nonce != 12 {
+		t.Errorf("Expected a new manager to start at 12, got %+v, %v", lease, err)
+	}
+
+	// An unreachable node releases the account
+	source.err = errors.New("connection refused")
+	if _, err := manager.Acquire(context.Background(), account); err == nil {
+		t.Fatal("Expected the node's error")
+	}
+	source.err = nil
+	if lease := acquire(); lease.Nonce != 12 {
+		t.Errorf("Expected nonce 12 once the node answers, got %d", lease.Nonce)
+	}
+
+	// A held nonce makes others wait, until their context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := manager.Acquire(ctx, account); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Acquire to give up with its context, got %v", err)
+	}
+}
+
+func TestNonceManagerUnsent(t *testing.T) {
+	manager := NewNonceManager(&staticNonces{pending: 5}, time.Minute)
+	account := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	escrow := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	call := func(tip int64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{Nonce: 5, To: &escrow, Value: big.NewInt(0), Data: []byte{0x01}, GasTipCap: big.NewInt(tip), GasFeeCap: big.NewInt(100)})
+	}
+
+	// A send that errored leaves its transaction for the nonce's next lease
+	lease, _ := manager.Acquire(context.Background(), account)
+	first := call(1)
+	lease.Signed(first)
+	lease.Done(errors.New("connection reset"))
+	lease, _ = manager.Acquire(context.Background(), account)
+	if lease.Nonce != 5 || lease.Unsent() != first {
+		t.Fatalf("Expected nonce 5 with the failed send unsent, got nonce %d and %v", lease.Nonce, lease.Unsent())
+	}
+
+	// Matched to the same call, the underpriced retry waits for it
+	refused := errors.New("replacement transaction underpriced")
+	retry := call(2)
+	if tx, err := pendingAt(lease.Nonce, refused, retry, lease.Unsent()); err != nil || tx != first {
+		t.Errorf("Expected the retry to wait for the earlier signing, got %v, %v", tx, err)
+	}
+	if tx, err := pendingAt(lease.Nonce, errors.New("already known"), retry, nil); err != nil || tx != retry {
+		t.Errorf("Expected an already known transaction to be waited for, got %v, %v", tx, err)
+	}
+
+	// Refused for anything else at the nonce, the nonce is taken
+	other := types.NewTx(&types.DynamicFeeTx{Nonce: 5, To: &escrow, Value: big.NewInt(0), Data: []byte{0x02}, GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(100)})
+	var taken *NonceTakenError
+	if _, err := pendingAt(lease.Nonce, refused, other, lease.Unsent()); !errors.As(err, &taken) || taken.Nonce != 5 {
+		t.Errorf("Expected nonce 5 to be taken, got %v", err)
+	}
+	if _, err := pendingAt(lease.Nonce, refused, other, nil); !errors.As(err, &taken) {
+		t.Errorf("Expected nonce 5 to be taken with nothing unsent, got %v", err)
+	}
+	if _, err := pendingAt(lease.Nonce, errors.New("insufficient funds"), other, nil); err == nil || errors.As(err, &taken) {
+		t.Errorf("Expected other errors returned as they are, got %v", err)
+	}
+
+	// Once the nonce is used it's forgotten
+	lease.Done(nil)
+	if lease, _ := manager.Acquire(context.Background(), account); lease.Nonce != 6 || lease.Unsent() != nil {
+		t.Errorf("Expected nonce 6 with nothing unsent, got %d and %v", lease.Nonce, lease.Unsent())
+	}
+	if len(manager.account(account).unsent) != 0 {
+		t.Errorf("Expected unsent transactions below the next nonce to be dropped")
+	}
+}
@@ -0,0 +1,358 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+)
+
+// RPCProviderStatus is one RPC provider of a failover client as the
+// gateway reports it
+type RPCProviderStatus struct {
+	URL                 string     `json:"url"`     // scheme and host only; provider paths usually carry API keys
+	Primary             bool       `json:"primary"` // ETHEREUM_RPC_URL rather than a fallback
+	Healthy             bool       `json:"healthy"` // its last request or probe succeeded
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+	BackoffUntil        *time.Time `json:"backoff_until,omitempty"` // skipped until then while another provider is available
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	Requests            uint64     `json:"requests"`
+	Failures            uint64     `json:"failures"`
+}
+
+// rpcProvider is one endpoint of a failoverTransport
+type rpcProvider struct {
+	url     *url.URL
+	primary bool
+
+	mu          sync.Mutex
+	consecutive int
+	retryAt     time.Time
+	lastErr     string
+	lastFailure time.Time
+	requests    uint64
+	failures    uint64
+}
+
+// failoverTransport sends each JSON-RPC request to the first provider, in
+// configured order, that isn't backing off, and moves on to the next when one
+// can't be reached, rate-limits (429) or answers with a server error. A
+// failing provider is skipped for the backoff, doubled after each further
+// failure up to maxBackoff or whatever longer Retry-After it sent. When every
+// provider is backing off they are all tried anyway, soonest to recover first,
+// so a request is never refused just for bad history. JSON-RPC errors such as
+// reverts come from the chain, not the provider, and are returned as they are.
+type failoverTransport struct {
+	providers  []*rpcProvider
+	base       http.RoundTripper
+	backoff    time.Duration
+	maxBackoff time.Duration
+	now        func() time.Time
+}
+
+func newFailoverTransport(urls []string, backoff, maxBackoff time.Duration) (*failoverTransport, error) {
+	t := &failoverTransport{base: http.DefaultTransport, backoff: backoff, maxBackoff: maxBackoff, now: time.Now}
+	if t.backoff <= 0 {
+		t.backoff = 5 * time.Second
+	}
+	if t.maxBackoff < t.backoff {
+		t.maxBackoff = t.backoff
+	}
+	for i, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RPC URL %q: %w", redactURL(raw), err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("RPC failover needs http or https URLs, got %s", redactURL(raw))
+		}
+		t.providers = append(t.providers, &rpcProvider{url: u, primary: i == 0})
+	}
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	order := t.order()
+	for i, p := range order {
+		last := i == len(order)-1
+		resp, err := t.base.RoundTrip(p.request(req, body))
+		if err != nil {
+			if req.Context().Err() != nil {
+				return nil, err
+			}
+			t.failed(p, err.Error(), 0)
+			if last {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			t.failed(p, resp.Status, retryAfter(resp))
+			if last {
+				return resp, nil
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			continue
+		}
+		t.succeeded(p)
+		return resp, nil
+	}
+	return nil, errors.New("no RPC providers configured")
+}
+
+// order lists the providers to try: those not backing off in configured
+// order, then the rest by when their backoff ends
+func (t *failoverTransport) order() []*rpcProvider {
+	now := t.now()
+	var ready, waiting []*rpcProvider
+	retryAt := make(map[*rpcProvider]time.Time)
+	for _, p := range t.providers {
+		p.mu.Lock()
+		at := p.retryAt
+		p.mu.Unlock()
+		if at.After(now) {
+			retryAt[p] = at
+			waiting = append(waiting, p)
+		} else {
+			ready = append(ready, p)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool { return retryAt[waiting[i]].Before(retryAt[waiting[j]]) })
+	return append(ready, waiting...)
+}
+
+// request is req addressed to p, with its own copy of body
+func (p *rpcProvider) request(req *http.Request, body []byte) *http.Request {
+	out := req.Clone(req.Context())
+	target := *p.url
+	out.URL = &target
+	out.Host = target.Host
+	if req.URL.User != nil {
+		// http.Client turned the dialled URL's credentials into this header
+		out.Header.Del("Authorization")
+	}
+	if target.User != nil {
+		password, _ := target.User.Password()
+		out.SetBasicAuth(target.User.Username(), password)
+	}
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	out.ContentLength = int64(len(body))
+	return out
+}
+
+func (t *failoverTransport) failed(p *rpcProvider, reason string, retryAfter time.Duration) {
+	now := t.now()
+	p.mu.Lock()
+	p.requests++
+	p.failures++
+	p.consecutive++
+	backoff := t.backoff
+	for i := 1; i < p.consecutive && backoff < t.maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, t.maxBackoff)
+	backoff = max(backoff, retryAfter)
+	p.retryAt = now.Add(backoff)
+	p.lastErr = reason
+	p.lastFailure = now
+	first := p.consecutive == 1
+	p.mu.Unlock()
+
+	if first && len(t.providers) > 1 {
+		log.Printf("Warning: RPC provider %s failed (%s); skipping it for %s", redactURL(p.url.String()), reason, backoff)
+	}
+}
+
+func (t *failoverTransport) succeeded(p *rpcProvider) {
+	p.mu.Lock()
+	p.requests++
+	recovered := p.consecutive > 0
+	p.consecutive = 0
+	p.retryAt = time.Time{}
+	p.mu.Unlock()
+
+	if recovered {
+		log.Printf("RPC provider %s recovered", redactURL(p.url.String()))
+	}
+}
+
+// probe asks every provider for its chain ID, recording a failure for one
+// that doesn't answer or is on another chain, and a success otherwise
+func (t *failoverTransport) probe(ctx context.Context, chainID int64) {
+	var wg sync.WaitGroup
+	for _, p := range t.providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := t.chainID(ctx, p)
+			switch {
+			case ctx.Err() != nil:
+			case err != nil:
+				t.failed(p, err.Error(), 0)
+			case got != chainID:
+				t.failed(p, fmt.Sprintf("serves chain %d, not %d", got, chainID), t.maxBackoff)
+			default:
+				t.succeeded(p)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// chainID sends eth_chainId to p alone, bypassing failover
+func (t *failoverTransport) chainID(ctx context.Context, p *rpcProvider) (int64, error) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.base.RoundTrip(p.request(req, body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s", resp.Status)
+	}
+
+	var reply struct {
+		Result *hexutil.Big `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&reply); err != nil {
+		return 0, fmt.Errorf("invalid eth_chainId response: %w", err)
+	}
+	if reply.Error != nil {
+		return 0, errors.New(reply.Error.Message)
+	}
+	if reply.Result == nil {
+		return 0, errors.New("empty eth_chainId response")
+	}
+	return (*big.Int)(reply.Result).Int64(), nil
+}
+
+func (t *failoverTransport) statuses() []RPCProviderStatus {
+	now := t.now()
+	statuses := make([]RPCProviderStatus, 0, len(t.providers))
+	for _, p := range t.providers {
+		p.mu.Lock()
+		status := RPCProviderStatus{
+			URL:                 redactURL(p.url.String()),
+			Primary:             p.primary,
+			Healthy:             p.consecutive == 0,
+			ConsecutiveFailures: p.consecutive,
+			LastError:           p.lastErr,
+			Requests:            p.requests,
+			Failures:            p.failures,
+		}
+		if p.retryAt.After(now) {
+			retryAt := p.retryAt
+			status.BackoffUntil = &retryAt
+		}
+		if !p.lastFailure.IsZero() {
+			lastFailure := p.lastFailure
+			status.LastFailure = &lastFailure
+		}
+		p.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// retryAfter is a 429 or 503 response's Retry-After in seconds, 0 if absent
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// redactURL keeps only a URL's scheme and host
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// dialRPC connects to ETHEREUM_RPC_URL, through a failoverTransport when
+// ETHEREUM_RPC_FALLBACK_URLS lists more providers. The transport is nil
+// without fallbacks.
+func dialRPC(cfg *config.Config) (*ethclient.Client, *failoverTransport, error) {
+	urls := []string{cfg.EthereumRPCURL}
+	for _, fallback := range strings.Split(cfg.EthereumRPCFallbackURLs, ",") {
+		if fallback = strings.TrimSpace(fallback); fallback != "" {
+			urls = append(urls, fallback)
+		}
+	}
+	if len(urls) == 1 {
+		ethClient, err := ethclient.Dial(cfg.EthereumRPCURL)
+		return ethClient, nil, err
+	}
+
+	transport, err := newFailoverTransport(urls, cfg.RPCFailoverBackoff, cfg.RPCFailoverMaxBackoff)
+	if err != nil {
+		return nil, nil, err
+	}
+	rpcClient, err := rpc.DialOptions(context.Background(), urls[0], rpc.WithHTTPClient(&http.Client{Transport: transport}))
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("RPC requests fail over across %d providers", len(urls))
+	return ethclient.NewClient(rpcClient), transport, nil
+}
+
+// RPCProviders reports the health of each RPC provider, nil without
+// ETHEREUM_RPC_FALLBACK_URLS
+func (c *Client) RPCProviders() []RPCProviderStatus {
+	if c.providers == nil {
+		return nil
+	}
+	return c.providers.statuses()
+}
+
+// ProbeRPCProviders checks every RPC provider answers on NETWORK_ID's chain,
+// so traffic moves off a failing provider before a request meets it and back
+// to a recovered one. It does nothing without ETHEREUM_RPC_FALLBACK_URLS.
+func (c *Client) ProbeRPCProviders(ctx context.Context) {
+	if c.providers == nil {
+		return
+	}
+	c.providers.probe(ctx, c.config.NetworkID)
+}
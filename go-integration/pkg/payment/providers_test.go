@@ -0,0 +1,168 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+)
+
+// fakeProviderService answers the eth_ methods a failover test calls
+type fakeProviderService struct {
+	chainID uint64
+	head    uint64
+}
+
+func (s *fakeProviderService) ChainId() hexutil.Uint64 { return hexutil.Uint64(s.chainID) }
+
+func (s *fakeProviderService) BlockNumber() hexutil.Uint64 { return hexutil.Uint64(s.head) }
+
+// fakeProvider serves a fakeProviderService over HTTP, answering with status
+// instead while it is non-zero
+type fakeProvider struct {
+	*httptest.Server
+	status atomic.Int32
+	hits   atomic.Int32
+}
+
+func newFakeProvider(t *testing.T, chainID, head uint64) *fakeProvider {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &fakeProviderService{chainID: chainID, head: head}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	p := &fakeProvider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.hits.Add(1)
+		if status := p.status.Load(); status != 0 {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(int(status))
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestRPCFailover(t *testing.T) {
+	primary := newFakeProvider(t, 11155111, 100)
+	fallback := newFakeProvider(t, 11155111, 200)
+	cfg := &config.Config{
+		EthereumRPCURL:          primary.URL + "/v3/secret-key",
+		EthereumRPCFallbackURLs: fallback.URL,
+		NetworkID:               11155111,
+		RPCFailoverBackoff:      time.Second,
+		RPCFailoverMaxBackoff:   time.Minute,
+	}
+	ethClient, providers, err := dialRPC(cfg)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer ethClient.Close()
+	now := time.Now()
+	providers.now = func() time.Time { return now }
+	client := &Client{ethClient: ethClient, providers: providers, config: cfg}
+	ctx := context.Background()
+
+	if head, err := client.BlockNumber(ctx); err != nil || head != 100 {
+		t.Fatalf("BlockNumber = %d, %v; want 100 from the primary", head, err)
+	}
+
+	// A rate-limited primary fails over and is skipped for its Retry-After
+	primary.status.Store(http.StatusTooManyRequests)
+	if head, err := client.BlockNumber(ctx); err != nil || head != 200 {
+		t.Fatalf("BlockNumber = %d, %v; want 200 from the fallback", head, err)
+	}
+	hits := primary.hits.Load()
+	if _, err := client.BlockNumber(ctx); err != nil {
+		t.Fatalf("BlockNumber failed: %v", err)
+	}
+	if primary.hits.Load() != hits {
+		t.Errorf("Expected the primary to be skipped while backing off")
+	}
+	status := client.RPCProviders()[0]
+	if status.Healthy || status.ConsecutiveFailures != 1 || status.BackoffUntil == nil || !status.BackoffUntil.Equal(now.Add(120*time.Second)) {
+		t.Errorf("Expected the primary unhealthy until its Retry-After, got %+v", status)
+	}
+	if status.URL != primary.URL || !status.Primary {
+		t.Errorf("Expected the primary's URL without its path, got %q", status.URL)
+	}
+
+	// With every provider down, requests still try them and return the error
+	fallback.status.Store(http.StatusBadGateway)
+	if _, err := client.BlockNumber(ctx); err == nil {
+		t.Fatalf("Expected an error with every provider down")
+	}
+	if status := client.RPCProviders()[1]; status.Healthy || status.LastError != "502 Bad Gateway" {
+		t.Errorf("Expected the fallback unhealthy with its status, got %+v", status)
+	}
+
+	// Probing brings recovered providers back before live traffic reaches them
+	primary.status.Store(0)
+	fallback.status.Store(0)
+	client.ProbeRPCProviders(ctx)
+	for _, status := range client.RPCProviders() {
+		if !status.Healthy || status.BackoffUntil != nil {
+			t.Errorf("Expected %s healthy after a probe, got %+v", status.URL, status)
+		}
+	}
+	if head, err := client.BlockNumber(ctx); err != nil || head != 100 {
+		t.Errorf("BlockNumber = %d, %v; want 100 from the recovered primary", head, err)
+	}
+}
+
+func TestRPCFailoverBackoff(t *testing.T) {
+	transport, err := newFailoverTransport([]string{"https://primary.example", "https://fallback.example"}, time.Second, 5*time.Second)
+	if err != nil {
+		t.Fatalf("newFailoverTransport: %v", err)
+	}
+	now := time.Now()
+	transport.now = func() time.Time { return now }
+	p := transport.providers[0]
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		transport.failed(p, "503 Service Unavailable", 0)
+		if got := p.retryAt.Sub(now); got != want {
+			t.Errorf("After %d failures backoff = %s, want %s", p.consecutive, got, want)
+		}
+	}
+	if order := transport.order(); order[0] != transport.providers[1] {
+		t.Errorf("Expected the fallback first while the primary backs off")
+	}
+
+	transport.succeeded(p)
+	if order := transport.order(); order[0] != p || p.consecutive != 0 {
+		t.Errorf("Expected the primary first again after a success")
+	}
+}
+
+func TestRPCProbeWrongChain(t *testing.T) {
+	primary := newFakeProvider(t, 11155111, 100)
+	mainnet := newFakeProvider(t, 1, 100)
+	transport, err := newFailoverTransport([]string{primary.URL, mainnet.URL}, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("newFailoverTransport: %v", err)
+	}
+
+	transport.probe(context.Background(), 11155111)
+	statuses := transport.statuses()
+	if !statuses[0].Healthy {
+		t.Errorf("Expected the primary healthy, got %+v", statuses[0])
+	}
+	if statuses[1].Healthy || statuses[1].LastError != "serves chain 1, not 11155111" || statuses[1].BackoffUntil == nil {
+		t.Errorf("Expected the provider on another chain to back off, got %+v", statuses[1])
+	}
+}
+
+func TestRPCFailoverNeedsHTTP(t *testing.T) {
+	if _, err := newFailoverTransport([]string{"wss://sepolia.example/ws/key", "https://fallback.example"}, time.Second, time.Minute); err == nil {
+		t.Errorf("Expected websocket providers to be refused")
+	}
+}
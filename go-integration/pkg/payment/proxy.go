@@ -0,0 +1,69 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EIP-1967 storage slots, each keccak256 of its label minus one
+var (
+	implementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc") // eip1967.proxy.implementation
+	adminSlot          = common.HexToHash("0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103") // eip1967.proxy.admin
+	beaconSlot         = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50") // eip1967.proxy.beacon
+)
+
+// beaconImplementationSelector is implementation() on an EIP-1967 beacon
+var beaconImplementationSelector = []byte{0x5c, 0x60, 0xda, 0x1b}
+
+// ProxyInfo is what the escrow address's EIP-1967 slots say about it. All
+// fields other than Address are zero when it is not a proxy.
+type ProxyInfo struct {
+	Address        common.Address
+	Implementation common.Address // resolved through the beacon for beacon proxies
+	Admin          common.Address
+	Beacon         common.Address
+}
+
+// IsProxy reports whether the address delegates to an implementation
+func (p *ProxyInfo) IsProxy() bool {
+	return p.Implementation != (common.Address{}) || p.Beacon != (common.Address{})
+}
+
+// GetProxyInfo reads the escrow contract's EIP-1967 slots
+func (c *Client) GetProxyInfo(ctx context.Context) (*ProxyInfo, error) {
+	info := &ProxyInfo{Address: c.ContractAddress()}
+
+	slots := map[common.Hash]*common.Address{
+		implementationSlot: &info.Implementation,
+		adminSlot:          &info.Admin,
+		beaconSlot:         &info.Beacon,
+	}
+	for slot, field := range slots {
+		value, err := c.ethClient.StorageAt(ctx, info.Address, slot, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proxy slot %s: %w", slot.Hex(), err)
+		}
+		*field = addressFromSlot(value)
+	}
+
+	if info.Implementation == (common.Address{}) && info.Beacon != (common.Address{}) {
+		out, err := c.ethClient.CallContract(ctx, ethereum.CallMsg{To: &info.Beacon, Data: beaconImplementationSelector}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read beacon implementation: %w", err)
+		}
+		info.Implementation = addressFromSlot(out)
+	}
+
+	return info, nil
+}
+
+// addressFromSlot returns the address right-aligned in a 32-byte word
+func addressFromSlot(value []byte) common.Address {
+	if len(value) < common.AddressLength {
+		return common.Address{}
+	}
+	return common.BytesToAddress(value[len(value)-common.AddressLength:])
+}
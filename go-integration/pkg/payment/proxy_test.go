@@ -0,0 +1,51 @@
+package payment
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestEIP1967Slots(t *testing.T) {
+	slots := map[string]common.Hash{
+		"eip1967.proxy.implementation": implementationSlot,
+		"eip1967.proxy.admin":          adminSlot,
+		"eip1967.proxy.beacon":         beaconSlot,
+	}
+
+	for label, slot := range slots {
+		hash := new(big.Int).SetBytes(crypto.Keccak256([]byte(label)))
+		expected := common.BigToHash(hash.Sub(hash, big.NewInt(1)))
+		if slot != expected {
+			t.Errorf("Expected %s slot %s, got %s", label, expected.Hex(), slot.Hex())
+		}
+	}
+
+	if got := crypto.Keccak256([]byte("implementation()"))[:4]; string(got) != string(beaconImplementationSelector) {
+		t.Errorf("Expected implementation() selector %x, got %x", got, beaconImplementationSelector)
+	}
+}
+
+func TestAddressFromSlot(t *testing.T) {
+	addr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	if got := addressFromSlot(common.LeftPadBytes(addr.Bytes(), 32)); got != addr {
+		t.Errorf("Expected %s, got %s", addr.Hex(), got.Hex())
+	}
+	if got := addressFromSlot(make([]byte, 32)); got != (common.Address{}) {
+		t.Errorf("Expected zero address for an empty slot, got %s", got.Hex())
+	}
+	if got := addressFromSlot(nil); got != (common.Address{}) {
+		t.Errorf("Expected zero address for no data, got %s", got.Hex())
+	}
+
+	info := &ProxyInfo{}
+	if info.IsProxy() {
+		t.Error("Expected a contract without slots not to be a proxy")
+	}
+	info.Implementation = addr
+	if !info.IsProxy() {
+		t.Error("Expected a contract with an implementation slot to be a proxy")
+	}
+}
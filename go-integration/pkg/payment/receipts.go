@@ -0,0 +1,83 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DefaultReceiptBatchSize is the number of receipts requested per JSON-RPC batch
+const DefaultReceiptBatchSize = 100
+
+// ReceiptStatus is the on-chain state of a submitted transaction
+type ReceiptStatus struct {
+	TxHash            common.Hash
+	Mined             bool
+	Success           bool
+	BlockNumber       uint64
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int
+	Confirmations     uint64 // blocks including and after the one the transaction was mined in
+	Err               error  // set when the node rejected this lookup
+}
+
+// GetReceiptStatuses looks up many transaction receipts with batched JSON-RPC
+// requests, each carrying the current block number so confirmations are
+// consistent within a batch
+func (c *Client) GetReceiptStatuses(ctx context.Context, hashes []common.Hash) (map[common.Hash]*ReceiptStatus, error) {
+	batchSize := c.config.ReceiptBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultReceiptBatchSize
+	}
+	return batchReceiptStatuses(ctx, c.ethClient.Client(), hashes, batchSize)
+}
+
+func batchReceiptStatuses(ctx context.Context, rpcClient *rpc.Client, hashes []common.Hash, batchSize int) (map[common.Hash]*ReceiptStatus, error) {
+	statuses := make(map[common.Hash]*ReceiptStatus, len(hashes))
+
+	for start := 0; start < len(hashes); start += batchSize {
+		end := min(start+batchSize, len(hashes))
+		chunk := hashes[start:end]
+
+		var head hexutil.Uint64
+		receipts := make([]*types.Receipt, len(chunk))
+		batch := make([]rpc.BatchElem, 0, len(chunk)+1)
+		batch = append(batch, rpc.BatchElem{Method: "eth_blockNumber", Result: &head})
+		for i, hash := range chunk {
+			batch = append(batch, rpc.BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Args:   []interface{}{hash},
+				Result: &receipts[i],
+			})
+		}
+
+		if err := rpcClient.BatchCallContext(ctx, batch); err != nil {
+			return nil, fmt.Errorf("failed to fetch receipts: %w", err)
+		}
+		if batch[0].Error != nil {
+			return nil, fmt.Errorf("failed to get block number: %w", batch[0].Error)
+		}
+
+		for i, hash := range chunk {
+			status := &ReceiptStatus{TxHash: hash, Err: batch[i+1].Error}
+			if receipt := receipts[i]; status.Err == nil && receipt != nil && receipt.BlockNumber != nil {
+				status.Mined = true
+				status.Success = receipt.Status == types.ReceiptStatusSuccessful
+				status.BlockNumber = receipt.BlockNumber.Uint64()
+				status.GasUsed = receipt.GasUsed
+				status.EffectiveGasPrice = receipt.EffectiveGasPrice
+				if uint64(head) >= status.BlockNumber {
+					status.Confirmations = uint64(head) - status.BlockNumber + 1
+				}
+			}
+			statuses[hash] = status
+		}
+	}
+
+	return statuses, nil
+}
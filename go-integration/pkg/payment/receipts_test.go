@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeEthService answers the eth_ methods used for receipt batches
+type fakeEthService struct {
+	head     uint64
+	receipts map[common.Hash]*types.Receipt
+	batches  int
+}
+
+func (s *fakeEthService) BlockNumber() hexutil.Uint64 {
+	s.batches++
+	return hexutil.Uint64(s.head)
+}
+
+func (s *fakeEthService) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	return s.receipts[hash], nil
+}
+
+func TestBatchReceiptStatuses(t *testing.T) {
+	mined := common.HexToHash("0x01")
+	reverted := common.HexToHash("0x02")
+	unknown := common.HexToHash("0x03")
+
+	service := &fakeEthService{
+		head: 110,
+		receipts: map[common.Hash]*types.Receipt{
+			mined:    {Status: types.ReceiptStatusSuccessful, TxHash: mined, BlockNumber: big.NewInt(100), GasUsed: 50000, EffectiveGasPrice: big.NewInt(2e9), Logs: []*types.Log{}},
+			reverted: {Status: types.ReceiptStatusFailed, TxHash: reverted, BlockNumber: big.NewInt(110), GasUsed: 30000, EffectiveGasPrice: big.NewInt(2e9), Logs: []*types.Log{}},
+		},
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	statuses, err := batchReceiptStatuses(context.Background(), client, []common.Hash{mined, reverted, unknown}, 2)
+	if err != nil {
+		t.Fatalf("Failed to fetch receipts: %v", err)
+	}
+
+	if service.batches != 2 {
+		t.Errorf("Expected 2 batches, got %d", service.batches)
+	}
+
+	if s := statuses[mined]; !s.Mined || !s.Success || s.Confirmations != 11 || s.GasUsed != 50000 {
+		t.Errorf("Expected mined receipt with 11 confirmations, got %+v", s)
+	}
+	if s := statuses[reverted]; !s.Mined || s.Success || s.Confirmations != 1 {
+		t.Errorf("Expected reverted receipt with 1 confirmation, got %+v", s)
+	}
+	if s := statuses[unknown]; s.Mined || s.Err != nil {
+		t.Errorf("Expected unmined receipt without error, got %+v", s)
+	}
+}
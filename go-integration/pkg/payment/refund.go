@@ -0,0 +1,33 @@
+package payment
+
+import "fmt"
+
+// RefundReason classifies why an escrow was cancelled and refunded
+type RefundReason string
+
+const (
+	RefundReasonClientCancelled   RefundReason = "client_cancelled"
+	RefundReasonFreelancerNoShow  RefundReason = "freelancer_no_show"
+	RefundReasonDisputeResolution RefundReason = "dispute_resolution"
+	RefundReasonDuplicate         RefundReason = "duplicate"
+	RefundReasonOther             RefundReason = "other"
+)
+
+// RefundReasons lists every accepted refund reason code
+var RefundReasons = []RefundReason{
+	RefundReasonClientCancelled,
+	RefundReasonFreelancerNoShow,
+	RefundReasonDisputeResolution,
+	RefundReasonDuplicate,
+	RefundReasonOther,
+}
+
+// ParseRefundReason validates a refund reason code
+func ParseRefundReason(value string) (RefundReason, error) {
+	for _, reason := range RefundReasons {
+		if string(reason) == value {
+			return reason, nil
+		}
+	}
+	return "", fmt.Errorf("invalid refund reason %q", value)
+}
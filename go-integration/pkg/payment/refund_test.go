@@ -0,0 +1,21 @@
+package payment
+
+import "testing"
+
+func TestParseRefundReason(t *testing.T) {
+	for _, reason := range RefundReasons {
+		parsed, err := ParseRefundReason(string(reason))
+		if err != nil {
+			t.Errorf("Expected %s to be valid, got %v", reason, err)
+		}
+		if parsed != reason {
+			t.Errorf("Expected %s, got %s", reason, parsed)
+		}
+	}
+
+	for _, value := range []string{"", "Duplicate", "changed_mind"} {
+		if _, err := ParseRefundReason(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package payment
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+)
+
+// Codes of the escrow contract's reverts, as reported to API consumers
+const (
+	CodeInsufficientEthSent    = "insufficient_eth_sent"
+	CodeOnlyClient             = "only_client"
+	CodeJobAlreadyCompleted    = "job_already_completed"
+	CodePaymentAlreadyReleased = "payment_already_released"
+	CodeJobNotCompleted        = "job_not_completed"
+	CodeJobNotCancelable       = "job_not_cancelable"
+	CodeJobExists              = "job_exists"
+	CodeContractError          = "contract_error" // a revert the gateway does not recognise
+)
+
+// ContractError is a revert decoded from the escrow contract: one of its
+// Solidity custom errors, or a require/revert reason string
+type ContractError struct {
+	Name    string // the custom error, e.g. JobAlreadyCompleted, or "Error" for a reason string
+	Reason  string // the reason string, for Error only
+	Code    string
+	Message string
+}
+
+// contractErrors describes each custom error the contract declares
+var contractErrors = map[string]struct{ code, message string }{
+	"InsufficientEthSent":        {CodeInsufficientEthSent, "the deposit did not cover the job's ETH amount at the contract's price; quote the job again"},
+	"NotJobClient":               {CodeOnlyClient, "only the job's client can do this; check the poster's wallet"},
+	"OnlyClientCanMarkCompleted": {CodeOnlyClient, "only the job's client can release or complete it; check the poster's wallet"},
+	"JobAlreadyCompleted":        {CodeJobAlreadyCompleted, "the job is already completed on-chain; resync it instead of retrying"},
+	"PaymentAlreadyReleased":     {CodePaymentAlreadyReleased, "the payment was already released on-chain; resync the job instead of retrying"},
+	"JobNotCompleted":            {CodeJobNotCompleted, "the job must be marked completed on-chain before its payment is released"},
+	"JobNotCancelable":           {CodeJobNotCancelable, "the job can no longer be cancelled on-chain"},
+}
+
+// reasonErrors maps the contract's revert reason strings to codes
+var reasonErrors = map[string]struct{ code, message string }{
+	"Job already exists": {CodeJobExists, "the job ID is already posted on-chain; resync the job instead of posting it again"},
+}
+
+// revertSelector is the selector of Error(string), used by require and revert with a reason
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// DecodeContractError returns the escrow contract revert carried by err, or
+// nil if err is not one. The revert data a node attaches to a failed call or
+// gas estimate is preferred; without it the "execution reverted: ..."
+// message is matched against the contract's error names and reasons.
+func DecodeContractError(err error) *ContractError {
+	if err == nil {
+		return nil
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := revertData(dataErr.ErrorData()); ok {
+			if decoded := decodeRevertData(data); decoded != nil {
+				return decoded
+			}
+		}
+	}
+
+	_, detail, found := strings.Cut(err.Error(), "execution reverted: ")
+	if !found {
+		return nil
+	}
+	detail = strings.TrimSpace(detail)
+	if known, ok := contractErrors[detail]; ok {
+		return &ContractError{Name: detail, Code: known.code, Message: known.message}
+	}
+	return reasonError(detail)
+}
+
+// revertData reads the hex revert data from an RPC error's data field
+func revertData(data interface{}) ([]byte, bool) {
+	s, ok := data.(string)
+	if !ok {
+		return nil, false
+	}
+	decoded, err := hexutil.Decode(s)
+	if err != nil || len(decoded) < 4 {
+		return nil, false
+	}
+	return decoded, true
+}
+
+func decodeRevertData(data []byte) *ContractError {
+	if bytes.Equal(data[:4], revertSelector) {
+		reason, err := abi.UnpackRevert(data)
+		if err != nil {
+			return nil
+		}
+		return reasonError(reason)
+	}
+
+	parsed, err := contracts.EthJobEscrowMetaData.GetAbi()
+	if err != nil {
+		return nil
+	}
+	for name, declared := range parsed.Errors {
+		if !bytes.Equal(declared.ID[:4], data[:4]) {
+			continue
+		}
+		if known, ok := contractErrors[name]; ok {
+			return &ContractError{Name: name, Code: known.code, Message: known.message}
+		}
+		return &ContractError{Name: name, Code: CodeContractError, Message: "the escrow contract rejected the transaction with " + name}
+	}
+	return &ContractError{Name: hexutil.Encode(data[:4]), Code: CodeContractError, Message: "the escrow contract rejected the transaction with an unrecognised error"}
+}
+
+func reasonError(reason string) *ContractError {
+	if known, ok := reasonErrors[reason]; ok {
+		return &ContractError{Name: "Error", Reason: reason, Code: known.code, Message: known.message}
+	}
+	return &ContractError{Name: "Error", Reason: reason, Code: CodeContractError, Message: "the escrow contract rejected the transaction: " + reason}
+}
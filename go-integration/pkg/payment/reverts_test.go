@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeRevertError is an RPC error carrying revert data, as nodes return for
+// a reverted call or gas estimate
+type fakeRevertError struct {
+	data string
+}
+
+func (e fakeRevertError) Error() string          { return "execution reverted" }
+func (e fakeRevertError) ErrorCode() int         { return 3 }
+func (e fakeRevertError) ErrorData() interface{} { return e.data }
+
+func selector(signature string) string {
+	return hexutil.Encode(crypto.Keccak256([]byte(signature))[:4])
+}
+
+func TestDecodeContractError(t *testing.T) {
+	// Error("Job already exists"), as encoded by require and revert with a reason
+	reasonData := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000012" +
+		"4a6f6220616c7265616479206578697374730000000000000000000000000000"
+
+	tests := []struct {
+		name     string
+		err      error
+		wantName string
+		code     string
+	}{
+		{"custom error", fakeRevertError{selector("JobAlreadyCompleted()")}, "JobAlreadyCompleted", CodeJobAlreadyCompleted},
+		{"client only", fmt.Errorf("failed to estimate gas: %w", fakeRevertError{selector("OnlyClientCanMarkCompleted()")}), "OnlyClientCanMarkCompleted", CodeOnlyClient},
+		{"reason string", fakeRevertError{reasonData}, "Error", CodeJobExists},
+		{"unknown selector", fakeRevertError{"0xdeadbeef"}, "0xdeadbeef", CodeContractError},
+		{"message only", errors.New("execution reverted: JobNotCompleted"), "JobNotCompleted", CodeJobNotCompleted},
+		{"unknown reason", errors.New("execution reverted: paused"), "Error", CodeContractError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := DecodeContractError(tt.err)
+			if decoded == nil {
+				t.Fatal("Expected a contract error")
+			}
+			if decoded.Name != tt.wantName || decoded.Code != tt.code || decoded.Message == "" {
+				t.Errorf("Expected %s (%s), got %+v", tt.wantName, tt.code, decoded)
+			}
+		})
+	}
+
+	if decoded := DecodeContractError(fakeRevertError{reasonData}); decoded.Reason != "Job already exists" {
+		t.Errorf("Expected the reason string, got %q", decoded.Reason)
+	}
+	for _, err := range []error{nil, errors.New("nonce too low"), errors.New("execution reverted")} {
+		if decoded := DecodeContractError(err); decoded != nil {
+			t.Errorf("Expected no contract error for %v, got %+v", err, decoded)
+		}
+	}
+
+	classified := ClassifyError(fakeRevertError{selector("PaymentAlreadyReleased()")})
+	if classified.Reason != ReasonReverted || classified.Retryable() || classified.Contract == nil || classified.Contract.Code != CodePaymentAlreadyReleased {
+		t.Errorf("Expected a permanent decoded revert, got %+v", classified)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
 )
 
 // PaymentGatewayService provides a client interface to the payment gateway microservice
@@ -44,16 +46,26 @@ type TransactionResponse struct {
 
 // JobStatusResponse represents job status from the payment gateway
 type JobStatusResponse struct {
-	JobID             uint64 `json:"job_id"`
-	ApplicationID     int32  `json:"application_id"`
-	FreelancerAddress string `json:"freelancer_address"`
-	ClientAddress     string `json:"client_address"`
-	USDAmount         string `json:"usd_amount"`
-	PaymentStatus     string `json:"payment_status"`
-	ApplicationStatus string `json:"application_status"`
-	TxHashDeposit     string `json:"tx_hash_deposit,omitempty"`
-	TxHashRelease     string `json:"tx_hash_release,omitempty"`
-	TxHashRefund      string `json:"tx_hash_refund,omitempty"`
+	JobID             uint64               `json:"job_id"`
+	ApplicationID     int32                `json:"application_id"`
+	FreelancerAddress string               `json:"freelancer_address"`
+	ClientAddress     string               `json:"client_address"`
+	USDAmount         string               `json:"usd_amount"`
+	PaymentStatus     paymentstatus.Status `json:"payment_status"`
+	ApplicationStatus string               `json:"application_status"`
+	TxHashDeposit     string               `json:"tx_hash_deposit,omitempty"`
+	TxHashRelease     string               `json:"tx_hash_release,omitempty"`
+	TxHashRefund      string               `json:"tx_hash_refund,omitempty"`
+	Timeline          []TimelineEntry      `json:"timeline"`
+}
+
+// TimelineEntry is one payment status transition reported in JobStatusResponse
+type TimelineEntry struct {
+	Status      string    `json:"status"`
+	TxHash      string    `json:"tx_hash,omitempty"`
+	BlockNumber *int64    `json:"block_number,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Actor       string    `json:"actor"`
 }
 
 // PostJob initiates escrow funding when candidate accepts offer
@@ -115,8 +127,8 @@ func (s *PaymentGatewayService) CompleteJob(ctx context.Context, jobID uint64) (
 }
 
 // CancelJob initiates refund for cancelled jobs
-func (s *PaymentGatewayService) CancelJob(ctx context.Context, jobID uint64) (*TransactionResponse, error) {
-	url := fmt.Sprintf("%s/cancel-job?job_id=%d", s.BaseURL, jobID)
+func (s *PaymentGatewayService) CancelJob(ctx context.Context, jobID uint64, reason RefundReason) (*TransactionResponse, error) {
+	url := fmt.Sprintf("%s/cancel-job?job_id=%d&reason=%s", s.BaseURL, jobID, reason)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
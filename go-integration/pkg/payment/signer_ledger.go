@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultLedgerDerivationPath is the first account of Ledger Live's Ethereum derivation
+const DefaultLedgerDerivationPath = "m/44'/60'/0'/0/0"
+
+// LedgerSigner signs on a Ledger hardware wallet. Every signature needs to be
+// confirmed on the device, so it is only used for privileged operations.
+type LedgerSigner struct {
+	hub     *usbwallet.Hub
+	wallet  accounts.Wallet
+	account accounts.Account
+}
+
+// NewLedgerSigner opens the first connected Ledger and derives the account at path
+func NewLedgerSigner(path string) (*LedgerSigner, error) {
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ledger derivation path %q: %w", path, err)
+	}
+
+	hub, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ledger hub: %w", err)
+	}
+
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return nil, errors.New("no ledger device connected")
+	}
+
+	wallet := wallets[0]
+	if err := wallet.Open(""); err != nil {
+		return nil, fmt.Errorf("failed to open ledger (is the Ethereum app running?): %w", err)
+	}
+
+	account, err := wallet.Derive(derivationPath, true)
+	if err != nil {
+		wallet.Close()
+		return nil, fmt.Errorf("failed to derive ledger account: %w", err)
+	}
+
+	return &LedgerSigner{hub: hub, wallet: wallet, account: account}, nil
+}
+
+func (s *LedgerSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *LedgerSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.wallet.SignTx(s.account, tx, chainID)
+}
+
+// Close releases the USB device
+func (s *LedgerSigner) Close() error {
+	return s.wallet.Close()
+}
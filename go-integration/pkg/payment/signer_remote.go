@@ -0,0 +1,237 @@
+package payment
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Remote signer APIs
+const (
+	RemoteSignerWeb3Signer = "web3signer" // Web3Signer's eth1 JSON-RPC: eth_accounts, eth_signTransaction
+	RemoteSignerClef       = "clef"       // Clef's external API: account_list, account_signTransaction
+)
+
+// RemoteSignerConfig locates a remote signing service and the account it signs for
+type RemoteSignerConfig struct {
+	URL     string
+	API     string         // RemoteSignerWeb3Signer when empty
+	Address common.Address // the zero address uses the service's only account
+	Timeout time.Duration  // per request; Clef waits for its rules or an operator to approve
+
+	// TLS, for services that only accept known clients
+	CAFile         string // CA the service's certificate is checked against; empty uses the system roots
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// RemoteSigner delegates signing to a Web3Signer or Clef instance, so the
+// key stays in a dedicated signing enclave whose own policy engine decides
+// what is signed. Each signed transaction is checked against the one sent.
+type RemoteSigner struct {
+	rpc     *rpc.Client
+	api     string
+	address common.Address
+	timeout time.Duration
+}
+
+// remoteTxArgs is the transaction object both APIs take, hex encoded
+type remoteTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to,omitempty"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// clefSignResponse is what account_signTransaction returns
+type clefSignResponse struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// NewRemoteSigner connects to the signing service and checks it holds the account
+func NewRemoteSigner(ctx context.Context, cfg RemoteSignerConfig) (*RemoteSigner, error) {
+	api := cfg.API
+	if api == "" {
+		api = RemoteSignerWeb3Signer
+	}
+	if api != RemoteSignerWeb3Signer && api != RemoteSignerClef {
+		return nil, fmt.Errorf("unknown remote signer API %q, expected %s or %s", api, RemoteSignerWeb3Signer, RemoteSignerClef)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := remoteSignerTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	client, err := rpc.DialOptions(ctx, cfg.URL, rpc.WithHTTPClient(&http.Client{Transport: transport}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+	}
+	s := &RemoteSigner{rpc: client, api: api, address: cfg.Address, timeout: cfg.Timeout}
+
+	accounts, err := s.accounts(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	switch {
+	case s.address == (common.Address{}) && len(accounts) == 1:
+		s.address = accounts[0]
+	case s.address == (common.Address{}):
+		client.Close()
+		return nil, fmt.Errorf("remote signer holds %d accounts; set the address to sign with", len(accounts))
+	case !slices.Contains(accounts, s.address):
+		client.Close()
+		return nil, fmt.Errorf("remote signer does not hold account %s", s.address.Hex())
+	}
+	return s, nil
+}
+
+func remoteSignerTLS(cfg RemoteSignerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read remote signer CA: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.New("remote signer CA file holds no certificates")
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load remote signer client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func (s *RemoteSigner) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+func (s *RemoteSigner) accounts(ctx context.Context) ([]common.Address, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	method := "eth_accounts"
+	if s.api == RemoteSignerClef {
+		method = "account_list"
+	}
+	var accounts []common.Address
+	if err := s.rpc.CallContext(ctx, &accounts, method); err != nil {
+		return nil, fmt.Errorf("failed to list remote signer accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+// SignTx asks the service to sign tx. A refusal by its policy engine is
+// returned as an error and nothing is sent.
+func (s *RemoteSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := remoteTxArgs{
+		From:    s.address,
+		To:      tx.To(),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   (*hexutil.Big)(tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	switch tx.Type() {
+	case types.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	case types.DynamicFeeTxType:
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	default:
+		return nil, fmt.Errorf("remote signer cannot sign transaction type %d", tx.Type())
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var raw hexutil.Bytes
+	switch s.api {
+	case RemoteSignerClef:
+		var response clefSignResponse
+		if err := s.rpc.CallContext(ctx, &response, "account_signTransaction", args); err != nil {
+			return nil, fmt.Errorf("remote signer refused to sign: %w", err)
+		}
+		raw = response.Raw
+	default:
+		if err := s.rpc.CallContext(ctx, &raw, "eth_signTransaction", args); err != nil {
+			return nil, fmt.Errorf("remote signer refused to sign: %w", err)
+		}
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("remote signer returned an invalid transaction: %w", err)
+	}
+	if err := checkRemoteSignature(tx, signed, chainID, s.address); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// checkRemoteSignature makes sure the service signed the transaction it was
+// given, for this chain and with the expected account
+func checkRemoteSignature(want, signed *types.Transaction, chainID *big.Int, address common.Address) error {
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		return fmt.Errorf("remote signer returned a transaction for another chain: %w", err)
+	}
+	if sender != address {
+		return fmt.Errorf("remote signer signed with %s instead of %s", sender.Hex(), address.Hex())
+	}
+
+	same := signed.Type() == want.Type() &&
+		signed.Nonce() == want.Nonce() &&
+		signed.Gas() == want.Gas() &&
+		signed.GasFeeCap().Cmp(want.GasFeeCap()) == 0 &&
+		signed.GasTipCap().Cmp(want.GasTipCap()) == 0 &&
+		signed.Value().Cmp(want.Value()) == 0 &&
+		slices.Equal(signed.Data(), want.Data()) &&
+		(signed.To() == nil) == (want.To() == nil) &&
+		(want.To() == nil || *signed.To() == *want.To())
+	if !same {
+		return errors.New("remote signer returned a transaction that differs from the one sent")
+	}
+	return nil
+}
+
+// Close disconnects from the signing service
+func (s *RemoteSigner) Close() {
+	s.rpc.Close()
+}
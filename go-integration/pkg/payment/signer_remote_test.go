@@ -0,0 +1,123 @@
+package payment
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeSigningService signs with an in-memory key the way Web3Signer and Clef do
+type fakeSigningService struct {
+	key    *KeySigner
+	tamper bool // sign a different value than requested
+}
+
+func (s *fakeSigningService) sign(args remoteTxArgs) (hexutil.Bytes, error) {
+	value := args.Value.ToInt()
+	if s.tamper {
+		value = new(big.Int).Add(value, big.NewInt(1))
+	}
+	var tx *types.Transaction
+	if args.MaxFeePerGas != nil {
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID: args.ChainID.ToInt(), Nonce: uint64(args.Nonce), GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap: args.MaxFeePerGas.ToInt(), Gas: uint64(args.Gas), To: args.To, Value: value, Data: args.Data,
+		})
+	} else {
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce: uint64(args.Nonce), GasPrice: args.GasPrice.ToInt(), Gas: uint64(args.Gas), To: args.To, Value: value, Data: args.Data,
+		})
+	}
+	signed, err := s.key.SignTx(context.Background(), tx, args.ChainID.ToInt())
+	if err != nil {
+		return nil, err
+	}
+	return signed.MarshalBinary()
+}
+
+type web3signerAPI struct{ *fakeSigningService }
+
+func (a web3signerAPI) Accounts() []common.Address { return []common.Address{a.key.Address()} }
+
+func (a web3signerAPI) SignTransaction(args remoteTxArgs) (hexutil.Bytes, error) { return a.sign(args) }
+
+type clefAPI struct{ *fakeSigningService }
+
+func (a clefAPI) List() []common.Address { return []common.Address{a.key.Address()} }
+
+func (a clefAPI) SignTransaction(args remoteTxArgs) (*clefSignResponse, error) {
+	raw, err := a.sign(args)
+	if err != nil {
+		return nil, err
+	}
+	return &clefSignResponse{Raw: raw}, nil
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, err := NewKeySigner("abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890")
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	chainID := big.NewInt(11155111)
+	to := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	txs := map[string]*types.Transaction{
+		"legacy":      types.NewTransaction(3, to, big.NewInt(1e15), 21000, big.NewInt(1e9), []byte{0x01}),
+		"dynamic fee": types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 4, GasTipCap: big.NewInt(1e9), GasFeeCap: big.NewInt(3e9), Gas: 50000, To: &to, Value: big.NewInt(5)}),
+	}
+
+	for _, api := range []string{RemoteSignerWeb3Signer, RemoteSignerClef} {
+		t.Run(api, func(t *testing.T) {
+			service := &fakeSigningService{key: key}
+			server := rpc.NewServer()
+			if api == RemoteSignerClef {
+				server.RegisterName("account", clefAPI{service})
+			} else {
+				server.RegisterName("eth", web3signerAPI{service})
+			}
+			endpoint := httptest.NewServer(server)
+			defer endpoint.Close()
+
+			signer, err := NewRemoteSigner(context.Background(), RemoteSignerConfig{URL: endpoint.URL, API: api})
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer signer.Close()
+			if signer.Address() != key.Address() {
+				t.Fatalf("Expected the service's only account %s, got %s", key.Address().Hex(), signer.Address().Hex())
+			}
+
+			for name, tx := range txs {
+				signed, err := transactOpts(context.Background(), signer, chainID).Signer(signer.Address(), tx)
+				if err != nil {
+					t.Fatalf("%s: failed to sign: %v", name, err)
+				}
+				if sender, _ := types.Sender(types.LatestSignerForChainID(chainID), signed); sender != key.Address() || signed.Nonce() != tx.Nonce() {
+					t.Errorf("%s: unexpected signed transaction from %s", name, sender.Hex())
+				}
+			}
+
+			service.tamper = true
+			if _, err := signer.SignTx(context.Background(), txs["legacy"], chainID); err == nil || !strings.Contains(err.Error(), "differs") {
+				t.Errorf("Expected a changed transaction to be rejected, got %v", err)
+			}
+		})
+	}
+
+	server := rpc.NewServer()
+	server.RegisterName("eth", web3signerAPI{&fakeSigningService{key: key}})
+	endpoint := httptest.NewServer(server)
+	defer endpoint.Close()
+	if _, err := NewRemoteSigner(context.Background(), RemoteSignerConfig{URL: endpoint.URL, Address: to}); err == nil {
+		t.Error("Expected an account the service doesn't hold to be rejected")
+	}
+	if _, err := NewRemoteSigner(context.Background(), RemoteSignerConfig{URL: endpoint.URL, API: "vault"}); err == nil {
+		t.Error("Expected an unknown API to be rejected")
+	}
+}
@@ -0,0 +1,39 @@
+package payment
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestKeySignerSignsForItsAddress(t *testing.T) {
+	signer, err := NewKeySigner("abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890")
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	chainID := big.NewInt(11155111)
+	to := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	tx := types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1e9), nil)
+
+	opts := transactOpts(context.Background(), signer, chainID)
+	signed, err := opts.Signer(signer.Address(), tx)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		t.Fatalf("Failed to recover sender: %v", err)
+	}
+	if sender != signer.Address() {
+		t.Errorf("Expected sender %s, got %s", signer.Address().Hex(), sender.Hex())
+	}
+
+	if _, err := opts.Signer(to, tx); err == nil {
+		t.Errorf("Expected signing for another address to fail")
+	}
+}
@@ -0,0 +1,122 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+)
+
+// ErrNoContractCode is returned by SwapContract for an address without code
+var ErrNoContractCode = errors.New("no contract code at address")
+
+// escrowBinding is the escrow contract the client sends to
+type escrowBinding struct {
+	contract *contracts.EthJobEscrow
+	address  common.Address
+}
+
+// ContractAddress returns the escrow contract the client currently sends to
+func (c *Client) ContractAddress() common.Address {
+	return c.escrow.Load().address
+}
+
+// SwapContract points the client at a redeployed escrow contract. abiJSON is
+// the new contract's ABI, which must be compatible with the compiled binding
+// (see CheckABICompatible). Calls already in flight finish on the previous
+// contract. It returns the previous address.
+func (c *Client) SwapContract(ctx context.Context, address common.Address, abiJSON []byte) (common.Address, error) {
+	if err := CheckABICompatible(abiJSON); err != nil {
+		return common.Address{}, err
+	}
+
+	code, err := c.ethClient.CodeAt(ctx, address, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to read code at %s: %w", address.Hex(), err)
+	}
+	if len(code) == 0 {
+		return common.Address{}, fmt.Errorf("%w %s", ErrNoContractCode, address.Hex())
+	}
+
+	contract, err := contracts.NewEthJobEscrow(address, c.ethClient)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	previous := c.escrow.Swap(&escrowBinding{contract: contract, address: address})
+	log.Printf("Escrow contract swapped from %s to %s", previous.address.Hex(), address.Hex())
+	return previous.address, nil
+}
+
+// CheckABICompatible reports whether a contract with the given ABI can be
+// driven by the compiled EthJobEscrow binding: every function, event and
+// custom error the binding knows must exist with the same selector, the same
+// outputs and, for events, the same indexed inputs. The new ABI may add more.
+func CheckABICompatible(abiJSON []byte) error {
+	compiled, err := contracts.EthJobEscrowMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	updated, err := abi.JSON(strings.NewReader(string(abiJSON)))
+	if err != nil {
+		return fmt.Errorf("invalid ABI: %w", err)
+	}
+
+	var problems []string
+	for name, method := range compiled.Methods {
+		other, ok := updated.Methods[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("function %s is missing", method.Sig))
+		case other.Sig != method.Sig:
+			problems = append(problems, fmt.Sprintf("function %s changed to %s", method.Sig, other.Sig))
+		case argumentTypes(other.Outputs, false) != argumentTypes(method.Outputs, false):
+			problems = append(problems, fmt.Sprintf("function %s returns (%s), expected (%s)", method.Sig, argumentTypes(other.Outputs, false), argumentTypes(method.Outputs, false)))
+		case other.Payable != method.Payable:
+			problems = append(problems, fmt.Sprintf("function %s changed payability", method.Sig))
+		}
+	}
+	for name, event := range compiled.Events {
+		other, ok := updated.Events[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("event %s is missing", event.Sig))
+		case other.ID != event.ID:
+			problems = append(problems, fmt.Sprintf("event %s changed to %s", event.Sig, other.Sig))
+		case argumentTypes(other.Inputs, true) != argumentTypes(event.Inputs, true):
+			problems = append(problems, fmt.Sprintf("event %s changed its indexed inputs", event.Sig))
+		}
+	}
+	for name, contractErr := range compiled.Errors {
+		other, ok := updated.Errors[name]
+		if !ok || other.ID != contractErr.ID {
+			problems = append(problems, fmt.Sprintf("error %s is missing", contractErr.Sig))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("ABI is not compatible with the gateway: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// argumentTypes writes the types of args, marking indexed ones when asked
+func argumentTypes(args abi.Arguments, indexed bool) string {
+	types := make([]string, 0, len(args))
+	for _, arg := range args {
+		t := arg.Type.String()
+		if indexed && arg.Indexed {
+			t += " indexed"
+		}
+		types = append(types, t)
+	}
+	return strings.Join(types, ",")
+}
@@ -0,0 +1,66 @@
+package payment
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/contracts"
+)
+
+// editABI returns the compiled ABI with edit applied to its entries
+func editABI(t *testing.T, edit func([]map[string]interface{}) []map[string]interface{}) []byte {
+	t.Helper()
+	var entries []map[string]interface{}
+	if err := json.Unmarshal([]byte(contracts.EthJobEscrowMetaData.ABI), &entries); err != nil {
+		t.Fatalf("Failed to decode compiled ABI: %v", err)
+	}
+	out, err := json.Marshal(edit(entries))
+	if err != nil {
+		t.Fatalf("Failed to encode ABI: %v", err)
+	}
+	return out
+}
+
+func TestCheckABICompatible(t *testing.T) {
+	if err := CheckABICompatible([]byte(contracts.EthJobEscrowMetaData.ABI)); err != nil {
+		t.Errorf("Expected the compiled ABI to be compatible, got %v", err)
+	}
+
+	added := editABI(t, func(entries []map[string]interface{}) []map[string]interface{} {
+		return append(entries, map[string]interface{}{"type": "function", "name": "pause", "inputs": []interface{}{}, "outputs": []interface{}{}, "stateMutability": "nonpayable"})
+	})
+	if err := CheckABICompatible(added); err != nil {
+		t.Errorf("Expected a new function to be compatible, got %v", err)
+	}
+
+	removed := editABI(t, func(entries []map[string]interface{}) []map[string]interface{} {
+		var kept []map[string]interface{}
+		for _, entry := range entries {
+			if entry["name"] != "cancelJob" {
+				kept = append(kept, entry)
+			}
+		}
+		return kept
+	})
+	if err := CheckABICompatible(removed); err == nil || !strings.Contains(err.Error(), "function cancelJob(uint256) is missing") {
+		t.Errorf("Expected the missing cancelJob to be reported, got %v", err)
+	}
+
+	// Indexing jobId moves it into the topics, which FilterJobPosted can't decode
+	reindexed := editABI(t, func(entries []map[string]interface{}) []map[string]interface{} {
+		for _, entry := range entries {
+			if entry["name"] == "JobPosted" {
+				entry["inputs"].([]interface{})[0].(map[string]interface{})["indexed"] = true
+			}
+		}
+		return entries
+	})
+	if err := CheckABICompatible(reindexed); err == nil || !strings.Contains(err.Error(), "JobPosted") {
+		t.Errorf("Expected the reindexed JobPosted to be reported, got %v", err)
+	}
+
+	if err := CheckABICompatible([]byte(`{"not": "an abi"}`)); err == nil {
+		t.Error("Expected an error for an invalid ABI")
+	}
+}
@@ -0,0 +1,127 @@
+// Package paymentstatus defines the payment statuses of an application, the
+// values of applications.payment_status, and the transitions between them.
+// The platform imports it so both services spell every status the same way.
+//
+// The usual path of an escrow is
+//
+//	pending_deposit → deposit_initiated → deposited → release_initiated → released
+//
+// or, when the job is cancelled, deposited → refund_initiated → refunded. A
+// reverted transaction leaves the payment in deposit_failed, release_failed
+// or refund_failed; a failed deposit can be posted again, while a failed
+// release or refund waits for a resync. Velocity rules hold escrows and
+// refunds in pending_review and the KYC gate holds releases in kyc_pending,
+// and both return the payment to the status it was held from.
+//
+// Transitions lists every edge. A deposit or release may also be confirmed
+// straight to deposited or released without its initiated status. A resync
+// overwrites the status with what the chain shows, outside the graph.
+package paymentstatus
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Status is a payment status. Its text form is the database value.
+type Status string
+
+// Payment statuses
+const (
+	PendingDeposit   Status = "pending_deposit"   // no escrow yet; also what a NULL payment_status means
+	DepositInitiated Status = "deposit_initiated" // postJob sent, not yet mined
+	DepositFailed    Status = "deposit_failed"    // postJob reverted
+	Deposited        Status = "deposited"         // escrow funded on-chain
+	ReleaseInitiated Status = "release_initiated" // markJobCompleted sent, not yet mined
+	ReleaseFailed    Status = "release_failed"    // markJobCompleted reverted
+	Released         Status = "released"          // escrow paid to the freelancer
+	RefundInitiated  Status = "refund_initiated"  // cancelJob sent, not yet mined
+	RefundFailed     Status = "refund_failed"     // cancelJob reverted
+	Refunded         Status = "refunded"          // escrow returned to the client
+	PendingReview    Status = "pending_review"    // escrow or refund held by a velocity rule
+	KYCPending       Status = "kyc_pending"       // release held until the freelancer passes KYC
+)
+
+// All lists every status in the order an escrow reaches them
+var All = []Status{
+	PendingDeposit, DepositInitiated, DepositFailed, Deposited,
+	ReleaseInitiated, ReleaseFailed, Released,
+	RefundInitiated, RefundFailed, Refunded,
+	PendingReview, KYCPending,
+}
+
+// Transitions maps each status to the statuses the gateway may move it to.
+// A review restores the status the application was held from, so
+// pending_review leads back to each status an escrow or refund can be held
+// in. A release or refund cancelled at its nonce leaves the escrow deposited.
+var Transitions = map[Status][]Status{
+	PendingDeposit:   {DepositInitiated, Deposited, PendingReview},
+	DepositInitiated: {Deposited, DepositFailed},
+	DepositFailed:    {DepositInitiated, PendingReview},
+	Deposited:        {ReleaseInitiated, Released, RefundInitiated, KYCPending, PendingReview},
+	ReleaseInitiated: {Released, ReleaseFailed, Deposited},
+	ReleaseFailed:    {},
+	Released:         {},
+	RefundInitiated:  {Refunded, RefundFailed, Deposited},
+	RefundFailed:     {},
+	Refunded:         {},
+	PendingReview:    {PendingDeposit, DepositFailed, Deposited},
+	KYCPending:       {Deposited},
+}
+
+// Parse returns the status s names, or an error for any other string
+func Parse(s string) (Status, error) {
+	status := Status(s)
+	if !status.Valid() {
+		return "", fmt.Errorf("unknown payment status %q", s)
+	}
+	return status, nil
+}
+
+// Valid reports whether s is one of the statuses in All
+func (s Status) Valid() bool {
+	_, ok := Transitions[s]
+	return ok
+}
+
+func (s Status) String() string {
+	return string(s)
+}
+
+// Initiated reports whether a transaction for s has been sent and not yet
+// mined
+func (s Status) Initiated() bool {
+	return s == DepositInitiated || s == ReleaseInitiated || s == RefundInitiated
+}
+
+// Failed reports whether the last transaction sent for s reverted
+func (s Status) Failed() bool {
+	return s == DepositFailed || s == ReleaseFailed || s == RefundFailed
+}
+
+// Settled reports whether the escrow has been paid out
+func (s Status) Settled() bool {
+	return s == Released || s == Refunded
+}
+
+// CanTransition reports whether the gateway may move a payment from one
+// status to another
+func CanTransition(from, to Status) bool {
+	return slices.Contains(Transitions[from], to)
+}
+
+// MarshalText writes the status as is, so a status read from a database
+// with values from before this package still marshals
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText parses a status, refusing unknown ones
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
@@ -0,0 +1,74 @@
+package paymentstatus
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, status := range All {
+		if parsed, err := Parse(string(status)); err != nil || parsed != status {
+			t.Errorf("Parse(%q) = %q, %v", status, parsed, err)
+		}
+	}
+	for _, raw := range []string{"", "payment_released", "Deposited", "released "} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	if len(Transitions) != len(All) {
+		t.Fatalf("Transitions has %d statuses, All %d", len(Transitions), len(All))
+	}
+	for from, targets := range Transitions {
+		for _, to := range targets {
+			if !to.Valid() {
+				t.Errorf("%s leads to unknown status %q", from, to)
+			}
+		}
+	}
+
+	// Every status is reachable from pending_deposit
+	seen := map[Status]bool{PendingDeposit: true}
+	queue := []Status{PendingDeposit}
+	for len(queue) > 0 {
+		for _, next := range Transitions[queue[0]] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+		queue = queue[1:]
+	}
+	for _, status := range All {
+		if !seen[status] {
+			t.Errorf("%s is unreachable from pending_deposit", status)
+		}
+	}
+
+	if !CanTransition(Deposited, ReleaseInitiated) || CanTransition(Released, Deposited) || CanTransition(PendingDeposit, Released) {
+		t.Error("CanTransition disagrees with the escrow lifecycle")
+	}
+	for _, status := range []Status{Released, Refunded} {
+		if !status.Settled() || len(Transitions[status]) != 0 {
+			t.Errorf("%s should be settled and final", status)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var got struct {
+		Status Status `json:"payment_status"`
+	}
+	if err := json.Unmarshal([]byte(`{"payment_status":"release_initiated"}`), &got); err != nil || got.Status != ReleaseInitiated {
+		t.Fatalf("Unmarshal = %q, %v", got.Status, err)
+	}
+	if err := json.Unmarshal([]byte(`{"payment_status":"payment_released"}`), &got); err == nil {
+		t.Error("Expected an unknown status to be refused")
+	}
+	if encoded, _ := json.Marshal(got); string(encoded) != `{"payment_status":"release_initiated"}` {
+		t.Errorf("Marshal = %s", encoded)
+	}
+}
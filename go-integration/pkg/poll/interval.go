@@ -0,0 +1,54 @@
+// Package poll schedules background polling that speeds up while there is
+// work in flight and backs off while idle.
+package poll
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Interval is an adaptive polling interval. It drops to Min while work is
+// pending and doubles up to Max each idle round. Jitter spreads the result by
+// up to ±Jitter of the interval so many gateways do not hit the node in lockstep.
+type Interval struct {
+	Min    time.Duration
+	Max    time.Duration
+	Jitter float64 // fraction of the interval, e.g. 0.2 for ±20%
+
+	current time.Duration
+}
+
+// NewInterval creates an interval that starts at min
+func NewInterval(min, max time.Duration, jitter float64) *Interval {
+	if max < min {
+		max = min
+	}
+	return &Interval{Min: min, Max: max, Jitter: jitter, current: min}
+}
+
+// Next returns how long to wait before the next poll. busy reports whether the
+// last poll found work still in flight.
+func (i *Interval) Next(busy bool) time.Duration {
+	if busy || i.current < i.Min {
+		i.current = i.Min
+	} else {
+		i.current *= 2
+		if i.current > i.Max {
+			i.current = i.Max
+		}
+	}
+	return i.jittered(i.current)
+}
+
+// Reset drops the interval back to Min, e.g. after new work was submitted
+func (i *Interval) Reset() {
+	i.current = i.Min
+}
+
+func (i *Interval) jittered(d time.Duration) time.Duration {
+	if i.Jitter <= 0 {
+		return d
+	}
+	spread := float64(d) * i.Jitter
+	return d + time.Duration((rand.Float64()*2-1)*spread)
+}
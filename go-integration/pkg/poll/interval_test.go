@@ -0,0 +1,38 @@
+package poll
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalBacksOffWhileIdle(t *testing.T) {
+	interval := NewInterval(10*time.Second, time.Minute, 0)
+
+	expected := []time.Duration{20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, want := range expected {
+		if got := interval.Next(false); got != want {
+			t.Errorf("Idle round %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+
+	if got := interval.Next(true); got != 10*time.Second {
+		t.Errorf("Expected busy round to drop to %s, got %s", 10*time.Second, got)
+	}
+
+	interval.Next(false)
+	interval.Reset()
+	if got := interval.Next(true); got != 10*time.Second {
+		t.Errorf("Expected %s after reset, got %s", 10*time.Second, got)
+	}
+}
+
+func TestIntervalJitterStaysInBounds(t *testing.T) {
+	interval := NewInterval(10*time.Second, time.Minute, 0.2)
+
+	for i := 0; i < 100; i++ {
+		got := interval.Next(true)
+		if got < 8*time.Second || got > 12*time.Second {
+			t.Fatalf("Expected jittered interval within ±20%% of 10s, got %s", got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "0123456789abcdef0123456789abcdef"
+	testTarget = "POST /confirm-release?job_id=1"
+)
+
+func newTestGuard(now time.Time) *Guard {
+	g, err := NewGuard(testSecret, 5*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	g.now = func() time.Time { return now }
+	return g
+}
+
+func signed(ts time.Time, nonce string, body []byte) (string, string) {
+	unix := ts.Unix()
+	return strconv.FormatInt(unix, 10), Sign(testSecret, unix, nonce, testTarget, body)
+}
+
+func TestGuardAcceptsFreshSignedRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	g := newTestGuard(now)
+	body := []byte(`{"job_id":"1"}`)
+
+	ts, sig := signed(now.Add(-time.Minute), "nonce-aaaaaaaaaaaa", body)
+	if err := g.Check(ts, "nonce-aaaaaaaaaaaa", sig, testTarget, body); err != nil {
+		t.Errorf("Expected request to be accepted, got %v", err)
+	}
+}
+
+func TestGuardRejections(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"job_id":"1"}`)
+	nonce := "nonce-bbbbbbbbbbbb"
+
+	g := newTestGuard(now)
+	ts, sig := signed(now, nonce, body)
+	if err := g.Check(ts, nonce, sig, testTarget, body); err != nil {
+		t.Fatalf("Expected first request to be accepted, got %v", err)
+	}
+	if err := g.Check(ts, nonce, sig, testTarget, body); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Expected ErrReplayed for a reused nonce, got %v", err)
+	}
+
+	staleTS, staleSig := signed(now.Add(-10*time.Minute), "nonce-cccccccccccc", body)
+	if err := g.Check(staleTS, "nonce-cccccccccccc", staleSig, testTarget, body); !errors.Is(err, ErrStale) {
+		t.Errorf("Expected ErrStale for an old timestamp, got %v", err)
+	}
+
+	futureTS, futureSig := signed(now.Add(10*time.Minute), "nonce-dddddddddddd", body)
+	if err := g.Check(futureTS, "nonce-dddddddddddd", futureSig, testTarget, body); !errors.Is(err, ErrStale) {
+		t.Errorf("Expected ErrStale for a future timestamp, got %v", err)
+	}
+
+	ts, sig = signed(now, "nonce-eeeeeeeeeeee", body)
+	if err := g.Check(ts, "nonce-eeeeeeeeeeee", sig, testTarget, []byte(`{"job_id":"2"}`)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for an altered body, got %v", err)
+	}
+	if err := g.Check(ts, "nonce-eeeeeeeeeeee", sig, "POST /confirm-release?job_id=2", body); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for an altered query, got %v", err)
+	}
+	// The rejected nonce was not recorded and can still be used
+	if err := g.Check(ts, "nonce-eeeeeeeeeeee", sig, testTarget, body); err != nil {
+		t.Errorf("Expected nonce of a rejected request to remain usable, got %v", err)
+	}
+
+	if err := g.Check(ts, "short", Sign(testSecret, now.Unix(), "short", testTarget, body), testTarget, body); !errors.Is(err, ErrMissing) {
+		t.Errorf("Expected ErrMissing for a short nonce, got %v", err)
+	}
+	if err := g.Check("", nonce, sig, testTarget, body); !errors.Is(err, ErrMissing) {
+		t.Errorf("Expected ErrMissing without a timestamp, got %v", err)
+	}
+}
+
+func TestGuardForgetsExpiredNonces(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	g := newTestGuard(now)
+	body := []byte("{}")
+
+	ts, sig := signed(now, "nonce-ffffffffffff", body)
+	if err := g.Check(ts, "nonce-ffffffffffff", sig, testTarget, body); err != nil {
+		t.Fatalf("Expected request to be accepted, got %v", err)
+	}
+
+	later := now.Add(6 * time.Minute)
+	g.now = func() time.Time { return later }
+	ts, sig = signed(later, "nonce-gggggggggggg", body)
+	if err := g.Check(ts, "nonce-gggggggggggg", sig, testTarget, body); err != nil {
+		t.Fatalf("Expected request to be accepted, got %v", err)
+	}
+	if g.Len() != 1 {
+		t.Errorf("Expected expired nonce to be swept, %d remembered", g.Len())
+	}
+}
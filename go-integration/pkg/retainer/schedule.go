@@ -0,0 +1,66 @@
+// Package retainer computes the billing schedule of recurring escrow contracts.
+package retainer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Billing intervals
+const (
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// Funding modes
+const (
+	ModeCustodial = "custodial" // the gateway wallet funds each period
+	ModePrompt    = "prompt"    // the client is asked to fund each period themselves
+)
+
+// JobIDOffset separates retainer period escrow IDs from application IDs, which
+// are used directly as escrow job IDs for one-off jobs and never exceed int32
+const JobIDOffset uint64 = 1 << 40
+
+// EscrowJobID returns the on-chain job ID used for a retainer period
+func EscrowJobID(periodID int64) uint64 {
+	return JobIDOffset + uint64(periodID)
+}
+
+// IsPeriodJobID reports whether an escrow job ID belongs to a retainer period.
+// IDs from 1<<41 up are escrow top-ups, see payment.TopUpJobID.
+func IsPeriodJobID(jobID uint64) bool {
+	return jobID >= JobIDOffset && jobID < JobIDOffset<<1
+}
+
+// ValidateSchedule checks the interval, mode and date range of a retainer
+func ValidateSchedule(interval, mode string, start, end time.Time) error {
+	if interval != IntervalWeek && interval != IntervalMonth {
+		return fmt.Errorf("interval must be '%s' or '%s'", IntervalWeek, IntervalMonth)
+	}
+	if mode != ModeCustodial && mode != ModePrompt {
+		return fmt.Errorf("mode must be '%s' or '%s'", ModeCustodial, ModePrompt)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("end date must be after start date")
+	}
+	return nil
+}
+
+// PeriodStart returns when period n (0-based) of a schedule begins. Monthly
+// periods are computed from the original start so that a retainer starting on
+// the 31st bills on the last day of shorter months instead of drifting.
+func PeriodStart(start time.Time, interval string, n int) time.Time {
+	if interval == IntervalWeek {
+		return start.AddDate(0, 0, 7*n)
+	}
+
+	year, month, day := start.Date()
+	target := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, start.Location())
+	lastDay := target.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	hour, minute, sec := start.Clock()
+	return time.Date(target.Year(), target.Month(), day, hour, minute, sec, start.Nanosecond(), start.Location())
+}
@@ -0,0 +1,63 @@
+package retainer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    time.Time
+		interval string
+		n        int
+		expected time.Time
+	}{
+		{"first week", time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), IntervalWeek, 0, time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)},
+		{"third week", time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), IntervalWeek, 2, time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)},
+		{"next month", time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), IntervalMonth, 1, time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC)},
+		{"clamped to short month", time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), IntervalMonth, 1, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{"no drift after short month", time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), IntervalMonth, 2, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"across year", time.Date(2025, 11, 30, 0, 0, 0, 0, time.UTC), IntervalMonth, 3, time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PeriodStart(tt.start, tt.interval, tt.n); !got.Equal(tt.expected) {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEscrowJobIDDoesNotCollideWithApplications(t *testing.T) {
+	const maxApplicationID = 1<<31 - 1
+
+	if IsPeriodJobID(maxApplicationID) {
+		t.Errorf("Expected application ID %d not to be a period job ID", maxApplicationID)
+	}
+	if jobID := EscrowJobID(1); !IsPeriodJobID(jobID) {
+		t.Errorf("Expected %d to be a period job ID", jobID)
+	}
+	if IsPeriodJobID(1<<41 + 1) {
+		t.Error("Expected a top-up job ID not to be a period job ID")
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 3, 0)
+
+	if err := ValidateSchedule(IntervalWeek, ModeCustodial, start, end); err != nil {
+		t.Errorf("Expected valid schedule, got %v", err)
+	}
+	if err := ValidateSchedule("day", ModeCustodial, start, end); err == nil {
+		t.Errorf("Expected error for unsupported interval")
+	}
+	if err := ValidateSchedule(IntervalMonth, "auto", start, end); err == nil {
+		t.Errorf("Expected error for unsupported mode")
+	}
+	if err := ValidateSchedule(IntervalMonth, ModePrompt, end, start); err == nil {
+		t.Errorf("Expected error for end before start")
+	}
+}
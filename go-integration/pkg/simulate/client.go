@@ -0,0 +1,203 @@
+// Package simulate drives payment lifecycles against a running gateway over
+// its HTTP API and checks the outcome of every step, so a deployment can be
+// smoke tested end to end.
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/replay"
+)
+
+// Client calls the gateway endpoints a lifecycle goes through
+type Client struct {
+	BaseURL       string
+	SigningSecret string // REQUEST_SIGNING_SECRET, for the confirm endpoints; empty sends them unsigned
+	Actor         string // sent as X-Actor
+	HTTP          *http.Client
+}
+
+// JobStatus is the part of GET /job-status a scenario checks
+type JobStatus struct {
+	ApplicationID     int32                `json:"application_id"`
+	FreelancerAddress string               `json:"freelancer_address"`
+	ClientAddress     string               `json:"client_address"`
+	USDAmount         string               `json:"usd_amount"`
+	PaymentStatus     paymentstatus.Status `json:"payment_status"`
+	TxHashDeposit     string               `json:"tx_hash_deposit"`
+	TxHashRelease     string               `json:"tx_hash_release"`
+	TxHashRefund      string               `json:"tx_hash_refund"`
+}
+
+// Transaction is the response of an endpoint that submits a transaction
+type Transaction struct {
+	TxHash  string `json:"tx_hash"`
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// StatusError is a response with an unexpected HTTP status
+type StatusError struct {
+	Method string
+	Path   string
+	Code   int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.Code, e.Body)
+}
+
+// JobStatus reads a job's payment status
+func (c *Client) JobStatus(ctx context.Context, jobID int32) (*JobStatus, error) {
+	var status JobStatus
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/job-status?job_id=%d", jobID), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// PostJob funds a job's escrow with the addresses and amount the gateway holds for it
+func (c *Client) PostJob(ctx context.Context, status *JobStatus) (*Transaction, error) {
+	body := map[string]interface{}{
+		"job_id":             status.ApplicationID,
+		"freelancer_address": status.FreelancerAddress,
+		"client_address":     status.ClientAddress,
+		"usd_amount":         status.USDAmount,
+	}
+	return c.transact(ctx, "/post-job", body)
+}
+
+// CompleteJob releases a job's escrow
+func (c *Client) CompleteJob(ctx context.Context, jobID int32) (*Transaction, error) {
+	return c.transact(ctx, fmt.Sprintf("/complete-job?job_id=%d", jobID), nil)
+}
+
+// CancelJob refunds a job's escrow for reason
+func (c *Client) CancelJob(ctx context.Context, jobID int32, reason string) (*Transaction, error) {
+	return c.transact(ctx, fmt.Sprintf("/cancel-job?job_id=%d&reason=%s", jobID, url.QueryEscape(reason)), nil)
+}
+
+// Confirm calls /confirm-deposit or /confirm-release, signed when SigningSecret is set
+func (c *Client) Confirm(ctx context.Context, kind string, jobID int32) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/confirm-%s?job_id=%d", kind, jobID), nil, nil)
+}
+
+// CloneJob starts a repeat engagement of a job at usdAmount and returns the
+// new application, left in pending_deposit
+func (c *Client) CloneJob(ctx context.Context, jobID int32, usdAmount int32) (int32, error) {
+	var clone struct {
+		ApplicationID int32 `json:"application_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/jobs/%d/clone", jobID), map[string]int32{"usd_amount": usdAmount}, &clone); err != nil {
+		return 0, err
+	}
+	return clone.ApplicationID, nil
+}
+
+// NetworkID returns the chain ID the gateway's escrow contract is on
+func (c *Client) NetworkID(ctx context.Context) (int64, error) {
+	var info struct {
+		NetworkID int64 `json:"network_id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/contract-info", nil, &info); err != nil {
+		return 0, err
+	}
+	return info.NetworkID, nil
+}
+
+// RefundCount returns how many refunds with reason the refund report counts over its default range
+func (c *Client) RefundCount(ctx context.Context, reason string) (int64, error) {
+	var report struct {
+		Totals []struct {
+			Reason string `json:"reason"`
+			Count  int64  `json:"count"`
+		} `json:"totals"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/reports/refunds", nil, &report); err != nil {
+		return 0, err
+	}
+	for _, total := range report.Totals {
+		if total.Reason == reason {
+			return total.Count, nil
+		}
+	}
+	return 0, nil
+}
+
+func (c *Client) transact(ctx context.Context, path string, body interface{}) (*Transaction, error) {
+	var tx Transaction
+	if err := c.do(ctx, http.MethodPost, path, body, &tx); err != nil {
+		return nil, err
+	}
+	if tx.TxHash == "" {
+		return &tx, fmt.Errorf("POST %s returned no transaction hash", path)
+	}
+	return &tx, nil
+}
+
+// do sends a request and decodes a 2xx JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Actor != "" {
+		req.Header.Set("X-Actor", c.Actor)
+	}
+	if c.SigningSecret != "" && strings.HasPrefix(path, "/confirm-") {
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		timestamp := time.Now().Unix()
+		req.Header.Set(replay.TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(replay.NonceHeader, hex.EncodeToString(nonce))
+		req.Header.Set(replay.SignatureHeader, replay.Sign(c.SigningSecret, timestamp, hex.EncodeToString(nonce), method+" "+req.URL.RequestURI(), payload))
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{Method: method, Path: path, Code: resp.StatusCode, Body: strings.TrimSpace(string(text))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding %s %s: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/internal/config"
+)
+
+// ProbeOptions configure the rounds of a synthetic probe
+type ProbeOptions struct {
+	Options
+	Scenario  string // scenario each round drives
+	Template  int32  // application whose client and freelancer each round engages again
+	USDAmount int32  // agreed amount of each round's engagement; keep it tiny
+}
+
+// ProbeResult is the outcome of one probe round
+type ProbeResult struct {
+	Scenario string        `json:"scenario"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	JobID    int32         `json:"job_id,omitempty"` // the round's engagement; 0 when it couldn't be created
+	Steps    []StepResult  `json:"steps"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Passed reports whether the round got through every step
+func (r ProbeResult) Passed() bool { return r.Error == "" }
+
+// Probe runs one round against a gateway: it checks the gateway's contract is
+// on a testnet, clones the template into a new engagement at USDAmount and
+// drives the scenario against it. Every round leaves its engagement behind,
+// settled or wherever it failed.
+func Probe(ctx context.Context, client *Client, options ProbeOptions) (result ProbeResult) {
+	result = ProbeResult{Scenario: options.Scenario, Started: time.Now()}
+	defer func() { result.Duration = time.Since(result.Started) }()
+
+	networkID, err := client.NetworkID(ctx)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to read the gateway's network: %v", err)
+		return result
+	}
+	if network, ok := config.Networks[networkID]; !ok || !network.Testnet {
+		result.Error = fmt.Sprintf("the gateway's contract is on chain %d, which isn't a known testnet", networkID)
+		return result
+	}
+
+	jobID, err := client.CloneJob(ctx, options.Template, options.USDAmount)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to clone job %d: %v", options.Template, err)
+		return result
+	}
+	result.JobID = jobID
+
+	result.Steps, err = Run(ctx, client, options.Options, map[string]int32{options.Scenario: jobID})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for _, step := range result.Steps {
+		if !step.Passed() {
+			result.Error = fmt.Sprintf("%s: %s", step.Step, step.Error)
+			break
+		}
+	}
+	return result
+}
+
+// ProbeMetrics counts the rounds of a probe and keeps the last one, for
+// scraping in the Prometheus text format
+type ProbeMetrics struct {
+	Env      string // the environment label of every metric
+	Runs     int
+	Failures int
+	Last     *ProbeResult
+}
+
+// Record counts a round and makes it the last
+func (m *ProbeMetrics) Record(result ProbeResult) {
+	m.Runs++
+	if !result.Passed() {
+		m.Failures++
+	}
+	m.Last = &result
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (m *ProbeMetrics) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	labels := fmt.Sprintf(`env="%s"`, labelEscaper.Replace(m.Env))
+	boolValue := func(ok bool) int {
+		if ok {
+			return 1
+		}
+		return 0
+	}
+
+	metric("pgw_probe_runs_total", "counter", "Probe rounds run.")
+	fmt.Fprintf(&b, "pgw_probe_runs_total{%s} %d\n", labels, m.Runs)
+	metric("pgw_probe_failures_total", "counter", "Probe rounds that failed.")
+	fmt.Fprintf(&b, "pgw_probe_failures_total{%s} %d\n", labels, m.Failures)
+
+	if last := m.Last; last != nil {
+		labels := fmt.Sprintf(`%s,scenario="%s"`, labels, labelEscaper.Replace(last.Scenario))
+		metric("pgw_probe_success", "gauge", "Whether the last probe round passed.")
+		fmt.Fprintf(&b, "pgw_probe_success{%s} %d\n", labels, boolValue(last.Passed()))
+		metric("pgw_probe_duration_seconds", "gauge", "How long the last probe round took.")
+		fmt.Fprintf(&b, "pgw_probe_duration_seconds{%s} %g\n", labels, last.Duration.Seconds())
+		metric("pgw_probe_last_run_timestamp_seconds", "gauge", "When the last probe round started.")
+		fmt.Fprintf(&b, "pgw_probe_last_run_timestamp_seconds{%s} %d\n", labels, last.Started.Unix())
+
+		if len(last.Steps) > 0 {
+			metric("pgw_probe_step_duration_seconds", "gauge", "How long each step of the last probe round took.")
+			for _, step := range last.Steps {
+				fmt.Fprintf(&b, "pgw_probe_step_duration_seconds{%s,step=\"%s\"} %g\n", labels, labelEscaper.Replace(step.Step), step.Duration.Seconds())
+			}
+			metric("pgw_probe_step_success", "gauge", "Whether each step of the last probe round passed.")
+			for _, step := range last.Steps {
+				fmt.Fprintf(&b, "pgw_probe_step_success{%s,step=\"%s\"} %d\n", labels, labelEscaper.Replace(step.Step), boolValue(step.Passed()))
+			}
+		}
+	}
+
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
@@ -0,0 +1,235 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+)
+
+// Scenario names
+const (
+	ScenarioRelease = "release" // post → confirm deposit → complete → confirm release
+	ScenarioCancel  = "cancel"  // post → confirm deposit → cancel → refunded
+	ScenarioDispute = "dispute" // post → confirm deposit → refund for dispute_resolution → refunded and reported
+)
+
+// Scenarios lists every scenario in the order Run drives them
+var Scenarios = []string{ScenarioRelease, ScenarioCancel, ScenarioDispute}
+
+// Options tune how long a scenario waits for the chain
+type Options struct {
+	SettleTimeout time.Duration // longest wait for a transaction to be confirmed
+	PollInterval  time.Duration // how often /job-status is read while waiting
+	LegacyConfirm bool          // call /confirm-deposit and /confirm-release instead of waiting on the status poller alone
+}
+
+// StepResult is the outcome of one step of a scenario
+type StepResult struct {
+	Scenario string        `json:"scenario"`
+	JobID    int32         `json:"job_id"`
+	Step     string        `json:"step"`
+	Duration time.Duration `json:"duration"`
+	Detail   string        `json:"detail,omitempty"` // e.g. the transaction hash
+	Error    string        `json:"error,omitempty"`
+}
+
+// Passed reports whether the step's assertions held
+func (r StepResult) Passed() bool { return r.Error == "" }
+
+// step is one call and the assertions on its result. It returns a detail
+// for the report.
+type step struct {
+	name string
+	run  func(ctx context.Context, s *run) (string, error)
+}
+
+// run is the state a scenario carries between its steps
+type run struct {
+	client  *Client
+	options Options
+	jobID   int32
+	status  *JobStatus
+	refunds int64 // dispute refunds reported before the refund
+}
+
+// Run drives each scenario against the job assigned to it, stopping a
+// scenario at its first failed step. Jobs must be applications the platform
+// has prepared in pending_deposit with both wallets set.
+func Run(ctx context.Context, client *Client, options Options, jobs map[string]int32) ([]StepResult, error) {
+	if options.PollInterval <= 0 {
+		options.PollInterval = 2 * time.Second
+	}
+	if options.SettleTimeout <= 0 {
+		options.SettleTimeout = 5 * time.Minute
+	}
+
+	var names []string
+	for name := range jobs {
+		if !slices.Contains(Scenarios, name) {
+			return nil, fmt.Errorf("unknown scenario %q, expected one of %v", name, Scenarios)
+		}
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return slices.Index(Scenarios, names[i]) < slices.Index(Scenarios, names[j]) })
+
+	var results []StepResult
+	for _, name := range names {
+		s := &run{client: client, options: options, jobID: jobs[name]}
+		for _, st := range steps(name) {
+			start := time.Now()
+			detail, err := st.run(ctx, s)
+			result := StepResult{Scenario: name, JobID: s.jobID, Step: st.name, Duration: time.Since(start), Detail: detail}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+			if err != nil {
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+func steps(scenario string) []step {
+	funded := []step{
+		{"check job", checkJob},
+		{"post job", postJob},
+		{"confirm deposit", confirm("deposit", paymentstatus.Deposited, paymentstatus.DepositFailed)},
+	}
+	switch scenario {
+	case ScenarioRelease:
+		return append(funded,
+			step{"complete job", completeJob},
+			step{"confirm release", confirm("release", paymentstatus.Released, paymentstatus.ReleaseFailed)},
+		)
+	case ScenarioCancel:
+		return append(funded,
+			step{"cancel job", cancelJob("client_cancelled")},
+			step{"confirm refund", confirm("", paymentstatus.Refunded, paymentstatus.RefundFailed)},
+		)
+	default:
+		return append(funded,
+			step{"count dispute refunds", countDisputeRefunds},
+			step{"refund dispute", cancelJob("dispute_resolution")},
+			step{"confirm refund", confirm("", paymentstatus.Refunded, paymentstatus.RefundFailed)},
+			step{"check refund report", checkDisputeReported},
+		)
+	}
+}
+
+func checkJob(ctx context.Context, s *run) (string, error) {
+	status, err := s.client.JobStatus(ctx, s.jobID)
+	if err != nil {
+		return "", err
+	}
+	if status.PaymentStatus != paymentstatus.PendingDeposit {
+		return "", fmt.Errorf("payment status is %q, expected pending_deposit", status.PaymentStatus)
+	}
+	if status.FreelancerAddress == "" || status.ClientAddress == "" {
+		return "", fmt.Errorf("job needs both a freelancer and a client wallet")
+	}
+	s.status = status
+	return fmt.Sprintf("$%s from %s to %s", status.USDAmount, status.ClientAddress, status.FreelancerAddress), nil
+}
+
+func postJob(ctx context.Context, s *run) (string, error) {
+	tx, err := s.client.PostJob(ctx, s.status)
+	if err != nil {
+		return "", err
+	}
+	return tx.TxHash, nil
+}
+
+func completeJob(ctx context.Context, s *run) (string, error) {
+	tx, err := s.client.CompleteJob(ctx, s.jobID)
+	if err != nil {
+		return "", err
+	}
+	return tx.TxHash, nil
+}
+
+func cancelJob(reason string) func(context.Context, *run) (string, error) {
+	return func(ctx context.Context, s *run) (string, error) {
+		tx, err := s.client.CancelJob(ctx, s.jobID, reason)
+		if err != nil {
+			return "", err
+		}
+		return tx.TxHash, nil
+	}
+}
+
+// confirm waits for the job to reach want, failing on failed. With
+// LegacyConfirm it first confirms through /confirm-<kind> when kind is set.
+func confirm(kind string, want, failed paymentstatus.Status) func(context.Context, *run) (string, error) {
+	return func(ctx context.Context, s *run) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.options.SettleTimeout)
+		defer cancel()
+
+		confirmed := !s.options.LegacyConfirm || kind == ""
+		for {
+			if !confirmed {
+				// Refused while the transaction is still unmined, so it is retried
+				if err := s.client.Confirm(ctx, kind, s.jobID); err == nil {
+					confirmed = true
+				}
+			}
+
+			status, err := s.client.JobStatus(ctx, s.jobID)
+			if err != nil {
+				return "", err
+			}
+			switch status.PaymentStatus {
+			case want:
+				s.status = status
+				if hash := txHashFor(status, want); hash != "" {
+					return hash, nil
+				}
+				return "", fmt.Errorf("payment status is %s without a transaction hash", want)
+			case failed:
+				return "", fmt.Errorf("payment status is %s", failed)
+			}
+
+			select {
+			case <-ctx.Done():
+				return "", fmt.Errorf("payment status is still %s after %s, expected %s", status.PaymentStatus, s.options.SettleTimeout, want)
+			case <-time.After(s.options.PollInterval):
+			}
+		}
+	}
+}
+
+func txHashFor(status *JobStatus, settled paymentstatus.Status) string {
+	switch settled {
+	case paymentstatus.Deposited:
+		return status.TxHashDeposit
+	case paymentstatus.Released:
+		return status.TxHashRelease
+	}
+	return status.TxHashRefund
+}
+
+func countDisputeRefunds(ctx context.Context, s *run) (string, error) {
+	count, err := s.client.RefundCount(ctx, "dispute_resolution")
+	if err != nil {
+		return "", err
+	}
+	s.refunds = count
+	return fmt.Sprintf("%d reported", count), nil
+}
+
+func checkDisputeReported(ctx context.Context, s *run) (string, error) {
+	count, err := s.client.RefundCount(ctx, "dispute_resolution")
+	if err != nil {
+		return "", err
+	}
+	// Other refunds may land meanwhile, so only an increase is required
+	if count <= s.refunds {
+		return "", fmt.Errorf("refund report still counts %d dispute refunds", count)
+	}
+	return fmt.Sprintf("%d reported", count), nil
+}
@@ -0,0 +1,290 @@
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/paymentstatus"
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/replay"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// fakeGateway moves jobs through their statuses the way the gateway and its
+// status poller would, settling each transaction on the next status read
+type fakeGateway struct {
+	guard   *replay.Guard
+	mu      sync.Mutex
+	jobs    map[int32]*JobStatus
+	refunds map[string]int64
+	fail    map[paymentstatus.Status]paymentstatus.Status // settled status -> failed status a job lands in instead
+	actors  []string
+	network int64
+}
+
+func newFakeGateway(t *testing.T) *fakeGateway {
+	guard, err := replay.NewGuard(testSecret, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &fakeGateway{guard: guard, jobs: make(map[int32]*JobStatus), refunds: map[string]int64{"dispute_resolution": 3}, fail: make(map[paymentstatus.Status]paymentstatus.Status), network: 11155111}
+	for _, id := range []int32{1, 2, 3} {
+		g.jobs[id] = &JobStatus{ApplicationID: id, FreelancerAddress: "0xf", ClientAddress: "0xc", USDAmount: "25", PaymentStatus: "pending_deposit"}
+	}
+	return g
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.actors = append(g.actors, r.Header.Get("X-Actor"))
+
+	body, _ := io.ReadAll(r.Body)
+	if r.URL.Path == "/contract-info" {
+		json.NewEncoder(w).Encode(map[string]int64{"network_id": g.network})
+		return
+	}
+	if source, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/clone"); ok {
+		var req struct {
+			USDAmount int32 `json:"usd_amount"`
+		}
+		json.Unmarshal(body, &req)
+		id, _ := strconv.ParseInt(source, 10, 32)
+		template := g.jobs[int32(id)]
+		if template == nil {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		clone := *template
+		clone.ApplicationID, clone.USDAmount, clone.PaymentStatus = int32(len(g.jobs)+1), strconv.Itoa(int(req.USDAmount)), "pending_deposit"
+		clone.TxHashDeposit, clone.TxHashRelease, clone.TxHashRefund = "", "", ""
+		g.jobs[clone.ApplicationID] = &clone
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int32{"application_id": clone.ApplicationID})
+		return
+	}
+	id64, _ := strconv.ParseInt(r.URL.Query().Get("job_id"), 10, 32)
+	if r.URL.Path == "/post-job" {
+		var req struct {
+			JobID int32 `json:"job_id"`
+		}
+		json.Unmarshal(body, &req)
+		id64 = int64(req.JobID)
+	}
+	job := g.jobs[int32(id64)]
+	if job == nil && r.URL.Path != "/reports/refunds" {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	submit := func(from, to paymentstatus.Status) {
+		if job.PaymentStatus != from {
+			http.Error(w, "Job is "+string(job.PaymentStatus), http.StatusConflict)
+			return
+		}
+		job.PaymentStatus = to
+		json.NewEncoder(w).Encode(Transaction{TxHash: fmt.Sprintf("0x%s%d", to, job.ApplicationID), Success: true})
+	}
+
+	switch r.URL.Path {
+	case "/job-status":
+		switch job.PaymentStatus {
+		case "deposit_initiated":
+			job.PaymentStatus, job.TxHashDeposit = g.settled("deposited"), "0xdeposit"
+		case "release_initiated":
+			job.PaymentStatus, job.TxHashRelease = g.settled("released"), "0xrelease"
+		case "refund_initiated":
+			job.PaymentStatus, job.TxHashRefund = g.settled("refunded"), "0xrefund"
+			g.refunds["dispute_resolution"]++
+		}
+		json.NewEncoder(w).Encode(job)
+	case "/post-job":
+		submit("pending_deposit", "deposit_initiated")
+	case "/complete-job":
+		submit("deposited", "release_initiated")
+	case "/cancel-job":
+		submit("deposited", "refund_initiated")
+	case "/confirm-deposit", "/confirm-release":
+		err := g.guard.Check(r.Header.Get(replay.TimestampHeader), r.Header.Get(replay.NonceHeader),
+			r.Header.Get(replay.SignatureHeader), r.Method+" "+r.URL.RequestURI(), body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "confirmed"})
+	case "/reports/refunds":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"totals": []map[string]interface{}{{"reason": "dispute_resolution", "count": g.refunds["dispute_resolution"]}},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (g *fakeGateway) settled(status paymentstatus.Status) paymentstatus.Status {
+	if failed, ok := g.fail[status]; ok {
+		return failed
+	}
+	return status
+}
+
+func runScenarios(t *testing.T, g *fakeGateway, options Options, jobs map[string]int32) []StepResult {
+	t.Helper()
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, SigningSecret: testSecret, Actor: "simulate-test"}
+	options.PollInterval = time.Millisecond
+	options.SettleTimeout = time.Second
+	results, err := Run(context.Background(), client, options, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestRunScenariosPass(t *testing.T) {
+	g := newFakeGateway(t)
+	results := runScenarios(t, g, Options{LegacyConfirm: true}, map[string]int32{
+		ScenarioDispute: 3,
+		ScenarioRelease: 1,
+		ScenarioCancel:  2,
+	})
+
+	var steps []string
+	for _, r := range results {
+		if !r.Passed() {
+			t.Errorf("%s %q failed: %s", r.Scenario, r.Step, r.Error)
+		}
+		steps = append(steps, r.Scenario+"/"+r.Step)
+	}
+	want := []string{
+		"release/check job", "release/post job", "release/confirm deposit", "release/complete job", "release/confirm release",
+		"cancel/check job", "cancel/post job", "cancel/confirm deposit", "cancel/cancel job", "cancel/confirm refund",
+		"dispute/check job", "dispute/post job", "dispute/confirm deposit", "dispute/count dispute refunds",
+		"dispute/refund dispute", "dispute/confirm refund", "dispute/check refund report",
+	}
+	if strings.Join(steps, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected steps %v, got %v", want, steps)
+	}
+	if results[4].Detail != "0xrelease" {
+		t.Errorf("Expected release hash as the detail, got %q", results[4].Detail)
+	}
+	if g.jobs[1].PaymentStatus != "released" || g.jobs[2].PaymentStatus != "refunded" || g.jobs[3].PaymentStatus != "refunded" {
+		t.Errorf("Unexpected final statuses %s, %s, %s", g.jobs[1].PaymentStatus, g.jobs[2].PaymentStatus, g.jobs[3].PaymentStatus)
+	}
+	for _, actor := range g.actors {
+		if actor != "simulate-test" {
+			t.Fatalf("Expected every request to carry X-Actor, got %q", actor)
+		}
+	}
+}
+
+func TestRunStopsAtFirstFailure(t *testing.T) {
+	g := newFakeGateway(t)
+	g.fail["released"] = "release_failed"
+	results := runScenarios(t, g, Options{}, map[string]int32{ScenarioRelease: 1})
+
+	last := results[len(results)-1]
+	if len(results) != 5 || last.Step != "confirm release" || !strings.Contains(last.Error, "release_failed") {
+		t.Errorf("Expected the release confirmation to fail on release_failed, got %+v", last)
+	}
+}
+
+func TestRunRequiresPendingDeposit(t *testing.T) {
+	g := newFakeGateway(t)
+	g.jobs[1].PaymentStatus = "released"
+	results := runScenarios(t, g, Options{}, map[string]int32{ScenarioRelease: 1, ScenarioCancel: 9})
+
+	if len(results) != 2 {
+		t.Fatalf("Expected each scenario to stop at its check, got %+v", results)
+	}
+	if !strings.Contains(results[0].Error, "expected pending_deposit") {
+		t.Errorf("Expected a status assertion failure, got %q", results[0].Error)
+	}
+	if !strings.Contains(results[1].Error, "404") {
+		t.Errorf("Expected a 404 for an unknown job, got %q", results[1].Error)
+	}
+}
+
+func TestRunRejectsUnknownScenario(t *testing.T) {
+	if _, err := Run(context.Background(), &Client{}, Options{}, map[string]int32{"refund": 1}); err == nil {
+		t.Error("Expected an error for an unknown scenario")
+	}
+}
+
+func TestConfirmSignsRequests(t *testing.T) {
+	g := newFakeGateway(t)
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL}
+	if err := client.Confirm(context.Background(), "deposit", 1); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected an unsigned confirm to be refused, got %v", err)
+	}
+	client.SigningSecret = testSecret
+	if err := client.Confirm(context.Background(), "deposit", 1); err != nil {
+		t.Errorf("Expected a signed confirm to be accepted, got %v", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	g := newFakeGateway(t)
+	g.jobs[1].PaymentStatus = "released"
+	server := httptest.NewServer(g)
+	defer server.Close()
+	client := &Client{BaseURL: server.URL, Actor: "pgwctl-probe"}
+	options := ProbeOptions{
+		Options:   Options{PollInterval: time.Millisecond, SettleTimeout: time.Second},
+		Scenario:  ScenarioRelease,
+		Template:  1,
+		USDAmount: 1,
+	}
+	metrics := &ProbeMetrics{Env: "staging"}
+
+	// Each round engages the template's parties again, however the template ended
+	result := Probe(context.Background(), client, options)
+	metrics.Record(result)
+	if !result.Passed() || result.JobID != 4 || len(result.Steps) != 5 {
+		t.Fatalf("Expected a passing round on a clone, got %+v", result)
+	}
+	if job := g.jobs[4]; job.PaymentStatus != "released" || job.USDAmount != "1" {
+		t.Errorf("Expected the $1 clone released, got %+v", job)
+	}
+
+	g.fail["released"] = "release_failed"
+	result = Probe(context.Background(), client, options)
+	metrics.Record(result)
+	if result.Passed() || !strings.Contains(result.Error, "confirm release") {
+		t.Errorf("Expected the round to fail at the release, got %q", result.Error)
+	}
+
+	var b strings.Builder
+	metrics.WriteTo(&b)
+	for _, line := range []string{
+		`pgw_probe_runs_total{env="staging"} 2`,
+		`pgw_probe_failures_total{env="staging"} 1`,
+		`pgw_probe_success{env="staging",scenario="release"} 0`,
+		`pgw_probe_step_success{env="staging",scenario="release",step="post job"} 1`,
+		`pgw_probe_step_success{env="staging",scenario="release",step="confirm release"} 0`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Expected the metrics to have %s, got\n%s", line, b.String())
+		}
+	}
+
+	// Nothing is cloned on a network whose ETH is worth something
+	g.network = 1
+	if result := Probe(context.Background(), client, options); !strings.Contains(result.Error, "isn't a known testnet") || len(g.jobs) != 5 {
+		t.Errorf("Expected a mainnet gateway to be refused before cloning, got %q with %d jobs", result.Error, len(g.jobs))
+	}
+}
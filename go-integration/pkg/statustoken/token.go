@@ -0,0 +1,83 @@
+// Package statustoken issues short-lived signed tokens that grant read-only
+// access to one job's payment status, for links embedded in emails.
+package statustoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid status token")
+	ErrExpired = errors.New("status token has expired")
+)
+
+// version prefixes the signed payload so the format can change without
+// accepting old tokens under new rules
+const version = "v1"
+
+// Signer creates and verifies tokens with an HMAC-SHA256 secret
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner creates a signer; secrets shorter than 32 bytes are rejected
+func NewSigner(secret string) (*Signer, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("status token secret must be at least 32 bytes")
+	}
+	return &Signer{secret: []byte(secret)}, nil
+}
+
+// Sign returns a token for jobID that is valid until expiresAt
+func (s *Signer) Sign(jobID uint64, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%s.%d.%d", version, jobID, expiresAt.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + s.signature(payload)
+}
+
+// Verify checks a token's signature and expiry and returns the job it grants access to
+func (s *Signer) Verify(token string, now time.Time) (uint64, time.Time, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, time.Time{}, ErrInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalid
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(signature), []byte(s.signature(payload))) {
+		return 0, time.Time{}, ErrInvalid
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 || parts[0] != version {
+		return 0, time.Time{}, ErrInvalid
+	}
+	jobID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalid
+	}
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalid
+	}
+
+	expiresAt := time.Unix(expiry, 0)
+	if !now.Before(expiresAt) {
+		return 0, time.Time{}, ErrExpired
+	}
+	return jobID, expiresAt, nil
+}
+
+func (s *Signer) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package statustoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestSignVerify(t *testing.T) {
+	signer, err := NewSigner(testSecret)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	token := signer.Sign(42, now.Add(time.Hour))
+
+	jobID, expiresAt, err := signer.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Expected token to verify, got %v", err)
+	}
+	if jobID != 42 {
+		t.Errorf("Expected job 42, got %d", jobID)
+	}
+	if !expiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected expiry %v, got %v", now.Add(time.Hour), expiresAt)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	signer, _ := NewSigner(testSecret)
+	now := time.Unix(1700000000, 0)
+	token := signer.Sign(42, now)
+
+	if _, _, err := signer.Verify(token, now); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	signer, _ := NewSigner(testSecret)
+	other, _ := NewSigner(strings.Repeat("x", 32))
+	now := time.Unix(1700000000, 0)
+	token := signer.Sign(42, now.Add(time.Hour))
+	forged := signer.Sign(43, now.Add(time.Hour))
+
+	encoded, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+
+	tests := map[string]string{
+		"other secret":      other.Sign(42, now.Add(time.Hour)),
+		"swapped payload":   encoded + "." + signature,
+		"missing signature": encoded,
+		"garbage":           "not-a-token",
+		"empty":             "",
+	}
+	for name, candidate := range tests {
+		if _, _, err := signer.Verify(candidate, now); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected %s token to be invalid, got %v", name, err)
+		}
+	}
+}
+
+func TestNewSignerRejectsShortSecret(t *testing.T) {
+	if _, err := NewSigner("short"); err == nil {
+		t.Error("Expected short secret to be rejected")
+	}
+}
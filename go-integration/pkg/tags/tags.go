@@ -0,0 +1,77 @@
+// Package tags validates the labels operators attach to payment records, such
+// as "design", "urgent" or "enterprise-client", and the tag filters of list,
+// export and report endpoints.
+package tags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxLength is the longest tag, the limit of the application_tags.tag column
+const MaxLength = 50
+
+// MaxPerJob bounds how many tags one payment record carries
+const MaxPerJob = 20
+
+// Normalize lower-cases and trims a tag and checks it is made of letters,
+// digits and the separators - _ . : starting with a letter or digit
+func Normalize(raw string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(raw))
+	if tag == "" {
+		return "", fmt.Errorf("tag is empty")
+	}
+	if len(tag) > MaxLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", tag, MaxLength)
+	}
+	for i, c := range tag {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case i > 0 && strings.ContainsRune("-_.:", c):
+		default:
+			return "", fmt.Errorf("tag %q may only contain letters, digits and - _ . : after a letter or digit", tag)
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeAll normalizes a record's tags, dropping duplicates, and returns
+// them sorted
+func NormalizeAll(raw []string) ([]string, error) {
+	seen := make(map[string]bool)
+	tags := []string{}
+	for _, r := range raw {
+		tag, err := Normalize(r)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxPerJob {
+		return nil, fmt.Errorf("at most %d tags are allowed, got %d", MaxPerJob, len(tags))
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// ParseFilter reads the tag filter of a query, given as repeated ?tag=
+// parameters, comma-separated values or both. A record matches the filter
+// when it carries every tag in it; an empty filter matches every record.
+func ParseFilter(values []string) ([]string, error) {
+	var raw []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if strings.TrimSpace(tag) != "" {
+				raw = append(raw, tag)
+			}
+		}
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	return NormalizeAll(raw)
+}
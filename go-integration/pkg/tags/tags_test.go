@@ -0,0 +1,75 @@
+package tags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw     string
+		tag     string
+		invalid bool
+	}{
+		{raw: "design", tag: "design"},
+		{raw: " Enterprise-Client ", tag: "enterprise-client"},
+		{raw: "q3:2025", tag: "q3:2025"},
+		{raw: "team_a.b", tag: "team_a.b"},
+		{raw: "", invalid: true},
+		{raw: "-urgent", invalid: true},
+		{raw: "two words", invalid: true},
+		{raw: "a,b", invalid: true},
+		{raw: strings.Repeat("x", MaxLength+1), invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			tag, err := Normalize(tt.raw)
+			switch {
+			case tt.invalid:
+				if err == nil {
+					t.Errorf("Expected %q to be rejected, got %q", tt.raw, tag)
+				}
+			case err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			case tag != tt.tag:
+				t.Errorf("Expected %q, got %q", tt.tag, tag)
+			}
+		})
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	tags, err := NormalizeAll([]string{"urgent", "Design", "design"})
+	if err != nil || !reflect.DeepEqual(tags, []string{"design", "urgent"}) {
+		t.Errorf("Expected sorted unique tags, got %v: %v", tags, err)
+	}
+
+	tags, err = NormalizeAll(nil)
+	if err != nil || tags == nil || len(tags) != 0 {
+		t.Errorf("Expected no tags to be an empty set, got %v: %v", tags, err)
+	}
+
+	many := make([]string, MaxPerJob+1)
+	for i := range many {
+		many[i] = strings.Repeat("t", i+1)
+	}
+	if _, err := NormalizeAll(many); err == nil {
+		t.Errorf("Expected more than %d tags to be rejected", MaxPerJob)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter([]string{"urgent,design", "enterprise-client", ""})
+	if err != nil || !reflect.DeepEqual(filter, []string{"design", "enterprise-client", "urgent"}) {
+		t.Errorf("Unexpected filter %v: %v", filter, err)
+	}
+
+	if filter, err := ParseFilter(nil); err != nil || filter != nil {
+		t.Errorf("Expected no filter, got %v: %v", filter, err)
+	}
+	if _, err := ParseFilter([]string{"bad tag"}); err == nil {
+		t.Error("Expected an invalid tag to be rejected")
+	}
+}
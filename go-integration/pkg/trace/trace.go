@@ -0,0 +1,54 @@
+// Package trace identifies the chain of work one mutating API request
+// starts: the request's logs, the database rows it writes, the transaction
+// it submits and the webhooks that report the outcome all carry its trace ID,
+// so a platform can follow a job across the gateway boundary.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header carries the trace ID on requests and responses. A caller may send
+// its own ID to join the gateway's records to its own.
+const Header = "X-Trace-ID"
+
+// maxLength bounds caller-supplied IDs, which are stored and logged
+const maxLength = 64
+
+type contextKey struct{}
+
+// NewID returns a random trace ID
+func NewID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Valid reports whether a caller-supplied trace ID can be used: 1 to 64
+// letters, digits, '-', '_' or '.'
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithID returns a context carrying the trace ID
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// ID returns the context's trace ID, or "" outside a traced request
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
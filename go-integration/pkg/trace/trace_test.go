@@ -0,0 +1,32 @@
+package trace
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	for _, id := range []string{NewID(), "req-42", "platform.job_7"} {
+		if !Valid(id) {
+			t.Errorf("Expected %q to be valid", id)
+		}
+	}
+	for _, id := range []string{"", "has space", "line\nbreak", strings.Repeat("a", 65)} {
+		if Valid(id) {
+			t.Errorf("Expected %q to be rejected", id)
+		}
+	}
+	if NewID() == NewID() {
+		t.Error("Expected distinct trace IDs")
+	}
+}
+
+func TestContext(t *testing.T) {
+	if id := ID(context.Background()); id != "" {
+		t.Errorf("Expected no trace ID, got %q", id)
+	}
+	if id := ID(WithID(context.Background(), "req-42")); id != "req-42" {
+		t.Errorf("Expected req-42, got %q", id)
+	}
+}
@@ -0,0 +1,149 @@
+// Package velocity holds the anti-fraud limits checked before the gateway
+// submits an escrow or a refund: how much a client escrows in a day, how often
+// a freelancer is refunded in a week, and how much a wallet first seen
+// recently may move. Each limit either blocks the operation or flags it for
+// manual review.
+package velocity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule names a velocity limit
+type Rule string
+
+const (
+	ClientDailyUSD          Rule = "client_daily_usd"          // escrowed USD per client in 24 hours
+	FreelancerWeeklyRefunds Rule = "freelancer_weekly_refunds" // refunds per freelancer in 7 days
+	NewWalletUSD            Rule = "new_wallet_usd"            // USD per escrow involving a new wallet
+)
+
+// Windows the limits are counted over
+const (
+	ClientWindow     = 24 * time.Hour
+	FreelancerWindow = 7 * 24 * time.Hour
+)
+
+// Action says what happens to an operation that exceeds a limit
+type Action string
+
+const (
+	Block Action = "block" // rejected before submission
+	Flag  Action = "flag"  // held in the review queue until an admin decides
+)
+
+// Limit is a configured maximum and what exceeding it does
+type Limit struct {
+	Max    int64
+	Action Action
+}
+
+// Limits are the configured rules; a rule without an entry is not checked
+type Limits map[Rule]Limit
+
+// ParseLimits reads a VELOCITY_LIMITS value such as
+// "client_daily_usd=5000:block,new_wallet_usd=1000". The action defaults to flag.
+func ParseLimits(spec string) (Limits, error) {
+	limits := Limits{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		rule := Rule(strings.TrimSpace(name))
+		if !ok || !known(rule) {
+			return nil, fmt.Errorf("unknown velocity rule %q", part)
+		}
+
+		maxValue, action, _ := strings.Cut(strings.TrimSpace(value), ":")
+		limit := Limit{Action: Action(action)}
+		if limit.Action == "" {
+			limit.Action = Flag
+		}
+		if limit.Action != Block && limit.Action != Flag {
+			return nil, fmt.Errorf("velocity rule %s: invalid action %q, expected block or flag", rule, action)
+		}
+		max, err := strconv.ParseInt(maxValue, 10, 64)
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("velocity rule %s: invalid limit %q", rule, maxValue)
+		}
+		limit.Max = max
+		limits[rule] = limit
+	}
+	return limits, nil
+}
+
+func known(rule Rule) bool {
+	switch rule {
+	case ClientDailyUSD, FreelancerWeeklyRefunds, NewWalletUSD:
+		return true
+	}
+	return false
+}
+
+// Violation is a limit an operation would exceed
+type Violation struct {
+	Rule   Rule   `json:"rule"`
+	Action Action `json:"action"`
+	Detail string `json:"detail"`
+}
+
+// Escrow is a job about to be funded
+type Escrow struct {
+	USDAmount       int64
+	ClientDailyUSD  int64 // escrowed by the same client within ClientWindow, excluding this job
+	NewWallets      []string
+	NewWalletMaxAge time.Duration
+}
+
+// Refund is a refund about to be submitted
+type Refund struct {
+	FreelancerWeeklyRefunds int64 // refunds of the same freelancer within FreelancerWindow, excluding this one
+}
+
+// CheckEscrow returns the limits funding e would exceed
+func (l Limits) CheckEscrow(e Escrow) []Violation {
+	var violations []Violation
+	if limit, ok := l[ClientDailyUSD]; ok && e.ClientDailyUSD+e.USDAmount > limit.Max {
+		violations = append(violations, Violation{
+			Rule:   ClientDailyUSD,
+			Action: limit.Action,
+			Detail: fmt.Sprintf("client would escrow $%d in 24h, limit is $%d", e.ClientDailyUSD+e.USDAmount, limit.Max),
+		})
+	}
+	if limit, ok := l[NewWalletUSD]; ok && len(e.NewWallets) > 0 && e.USDAmount > limit.Max {
+		violations = append(violations, Violation{
+			Rule:   NewWalletUSD,
+			Action: limit.Action,
+			Detail: fmt.Sprintf("$%d escrow involves %s first seen within %s, limit is $%d", e.USDAmount, strings.Join(e.NewWallets, ", "), e.NewWalletMaxAge, limit.Max),
+		})
+	}
+	return violations
+}
+
+// CheckRefund returns the limits submitting r would exceed
+func (l Limits) CheckRefund(r Refund) []Violation {
+	var violations []Violation
+	if limit, ok := l[FreelancerWeeklyRefunds]; ok && r.FreelancerWeeklyRefunds+1 > limit.Max {
+		violations = append(violations, Violation{
+			Rule:   FreelancerWeeklyRefunds,
+			Action: limit.Action,
+			Detail: fmt.Sprintf("freelancer would have %d refunds in 7 days, limit is %d", r.FreelancerWeeklyRefunds+1, limit.Max),
+		})
+	}
+	return violations
+}
+
+// Blocked reports whether any violation blocks the operation
+func Blocked(violations []Violation) bool {
+	for _, v := range violations {
+		if v.Action == Block {
+			return true
+		}
+	}
+	return false
+}
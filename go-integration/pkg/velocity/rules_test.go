@@ -0,0 +1,63 @@
+package velocity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("client_daily_usd=5000:block, new_wallet_usd=1000")
+	if err != nil {
+		t.Fatalf("Failed to parse limits: %v", err)
+	}
+	if limits[ClientDailyUSD] != (Limit{Max: 5000, Action: Block}) {
+		t.Errorf("Unexpected client limit %+v", limits[ClientDailyUSD])
+	}
+	if limits[NewWalletUSD] != (Limit{Max: 1000, Action: Flag}) {
+		t.Errorf("Expected new wallet limit to default to flag, got %+v", limits[NewWalletUSD])
+	}
+	if _, ok := limits[FreelancerWeeklyRefunds]; ok {
+		t.Error("Expected unconfigured rule to be absent")
+	}
+
+	for _, spec := range []string{"daily=5", "client_daily_usd", "client_daily_usd=lots", "client_daily_usd=5:warn", "client_daily_usd=-1"} {
+		if _, err := ParseLimits(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestCheckEscrow(t *testing.T) {
+	limits := Limits{
+		ClientDailyUSD: {Max: 1000, Action: Block},
+		NewWalletUSD:   {Max: 200, Action: Flag},
+	}
+
+	if v := limits.CheckEscrow(Escrow{USDAmount: 400, ClientDailyUSD: 600}); len(v) != 0 {
+		t.Errorf("Expected no violation at the limit, got %+v", v)
+	}
+
+	v := limits.CheckEscrow(Escrow{USDAmount: 400, ClientDailyUSD: 700, NewWallets: []string{"0xc1"}, NewWalletMaxAge: 72 * time.Hour})
+	if len(v) != 2 || v[0].Rule != ClientDailyUSD || v[1].Rule != NewWalletUSD {
+		t.Fatalf("Expected both rules violated, got %+v", v)
+	}
+	if !Blocked(v) {
+		t.Error("Expected the client limit to block")
+	}
+	if Blocked(v[1:]) {
+		t.Error("Expected the new wallet limit only to flag")
+	}
+}
+
+func TestCheckRefund(t *testing.T) {
+	limits := Limits{FreelancerWeeklyRefunds: {Max: 2, Action: Flag}}
+	if v := limits.CheckRefund(Refund{FreelancerWeeklyRefunds: 1}); len(v) != 0 {
+		t.Errorf("Expected the second refund to pass, got %+v", v)
+	}
+	if v := limits.CheckRefund(Refund{FreelancerWeeklyRefunds: 2}); len(v) != 1 || v[0].Action != Flag {
+		t.Errorf("Expected the third refund flagged, got %+v", v)
+	}
+	if v := (Limits{}).CheckRefund(Refund{FreelancerWeeklyRefunds: 100}); len(v) != 0 {
+		t.Errorf("Expected no limits to pass everything, got %+v", v)
+	}
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body when a secret is configured
+const SignatureHeader = "X-Webhook-Signature"
+
+// TestHeader is set to "true" on test deliveries, whose sample payloads
+// describe no real job and must not be acted on
+const TestHeader = "X-Webhook-Test"
+
+// maxResponseBody bounds how much of an endpoint's response a test delivery reports
+const maxResponseBody = 4096
+
+// Delivery is how an endpoint responded to an event
+type Delivery struct {
+	StatusCode int
+	Body       string // truncated to 4 KiB
+	Duration   time.Duration
+}
+
+// OK reports whether the endpoint accepted the event
+func (d *Delivery) OK() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// Notifier delivers events to the platform's webhook endpoint
+type Notifier struct {
+	URL        string
+	Secret     string
+	HTTPClient *http.Client
+}
+
+// NewNotifier creates a notifier. An empty URL disables delivery.
+func NewNotifier(url, secret string) *Notifier {
+	return &Notifier{
+		URL:    url,
+		Secret: secret,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Enabled reports whether a webhook endpoint is configured
+func (n *Notifier) Enabled() bool {
+	return n != nil && n.URL != ""
+}
+
+// Notify sends an event to the configured endpoint
+func (n *Notifier) Notify(ctx context.Context, event *events.Envelope) error {
+	if !n.Enabled() {
+		return nil
+	}
+	return n.NotifyURL(ctx, n.URL, event)
+}
+
+// NotifyURL sends an event to endpoint instead of the configured URL, signed
+// with the same secret. It delivers even when no global URL is configured.
+func (n *Notifier) NotifyURL(ctx context.Context, endpoint string, event *events.Envelope) error {
+	delivery, err := n.deliver(ctx, endpoint, event, false)
+	if err != nil {
+		return err
+	}
+	if !delivery.OK() {
+		return fmt.Errorf("webhook endpoint returned status %d", delivery.StatusCode)
+	}
+	return nil
+}
+
+// Test sends event to endpoint marked with TestHeader and reports the
+// response, whatever its status. It fails only when no response was received.
+func (n *Notifier) Test(ctx context.Context, endpoint string, event *events.Envelope) (*Delivery, error) {
+	return n.deliver(ctx, endpoint, event, true)
+}
+
+func (n *Notifier) deliver(ctx context.Context, endpoint string, event *events.Envelope, test bool) (*Delivery, error) {
+	body, err := event.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.Secret, body))
+	}
+	if test {
+		req.Header.Set(TestHeader, "true")
+	}
+
+	start := time.Now()
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deliver webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return &Delivery{
+		StatusCode: resp.StatusCode,
+		Body:       string(responseBody),
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// ValidateURL checks that raw is an absolute http or https URL a notifier can deliver to
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: expected an http or https URL", raw)
+	}
+	return nil
+}
+
+// Sign computes the signature header value for a webhook body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
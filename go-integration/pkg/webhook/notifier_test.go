@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Fahedafzaal/Freelance-Payment-Gateway/go-integration/pkg/events"
+)
+
+func testEvent(t *testing.T) *events.Envelope {
+	event, err := events.New(events.OperationDeferred, events.Operation{ApplicationID: 7})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	return event
+}
+
+func TestNotifySignsPayload(t *testing.T) {
+	var gotSignature string
+	var gotEvent events.Envelope
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(SignatureHeader)
+		if gotSignature != Sign("secret", body) {
+			t.Errorf("Signature does not match body")
+		}
+		json.Unmarshal(body, &gotEvent)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(server.URL, "secret")
+	if err := notifier.Notify(context.Background(), testEvent(t)); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	if gotSignature == "" {
+		t.Errorf("Expected signature header to be set")
+	}
+	if gotEvent.Type != "operation.deferred" {
+		t.Errorf("Expected event type operation.deferred, got %s", gotEvent.Type)
+	}
+}
+
+func TestNotifyReportsFailureStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := NewNotifier(server.URL, "").Notify(context.Background(), testEvent(t)); err == nil {
+		t.Errorf("Expected error for non-2xx response")
+	}
+}
+
+func TestDisabledNotifier(t *testing.T) {
+	notifier := NewNotifier("", "")
+	if notifier.Enabled() {
+		t.Errorf("Expected notifier without URL to be disabled")
+	}
+	if err := notifier.Notify(context.Background(), testEvent(t)); err != nil {
+		t.Errorf("Expected disabled notifier to be a no-op, got %v", err)
+	}
+}
+
+func TestNotifyURL(t *testing.T) {
+	var delivered bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("secret", body) {
+			t.Errorf("Signature does not match body")
+		}
+		delivered = true
+	}))
+	defer server.Close()
+
+	// A job endpoint receives events even without a global URL
+	if err := NewNotifier("", "secret").NotifyURL(context.Background(), server.URL, testEvent(t)); err != nil {
+		t.Fatalf("NotifyURL failed: %v", err)
+	}
+	if !delivered {
+		t.Error("Expected the event delivered to the job endpoint")
+	}
+}
+
+func TestTestReportsResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(TestHeader) != "true" {
+			t.Errorf("Expected the delivery marked as a test")
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "unknown event type")
+	}))
+	defer server.Close()
+
+	// A rejected test delivery is reported, not returned as an error
+	delivery, err := NewNotifier("", "").Test(context.Background(), server.URL, testEvent(t))
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if delivery.OK() || delivery.StatusCode != http.StatusBadRequest || delivery.Body != "unknown event type" {
+		t.Errorf("Unexpected delivery %+v", delivery)
+	}
+
+	if _, err := NewNotifier("", "").Test(context.Background(), "http://127.0.0.1:1", testEvent(t)); err == nil {
+		t.Error("Expected an error when the endpoint is unreachable")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	if err := ValidateURL("https://disputes.example/hooks"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, raw := range []string{"", "disputes.example/hooks", "ftp://disputes.example", "https://"} {
+		if err := ValidateURL(raw); err == nil {
+			t.Errorf("Expected an error for %q", raw)
+		}
+	}
+}
+
+func TestSlackPost(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := NewSlack(server.URL).Post(context.Background(), "*Settlement* 2025-06-01"); err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	if got["text"] != "*Settlement* 2025-06-01" {
+		t.Errorf("Expected the message text, got %v", got)
+	}
+
+	if err := NewSlack("").Post(context.Background(), "ignored"); err != nil {
+		t.Errorf("Expected a disabled poster to do nothing, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Slack posts plain-text messages to a Slack incoming webhook
+type Slack struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewSlack creates a Slack poster. An empty URL disables posting.
+func NewSlack(url string) *Slack {
+	return &Slack{
+		URL: url,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Enabled reports whether a Slack webhook is configured
+func (s *Slack) Enabled() bool {
+	return s != nil && s.URL != ""
+}
+
+// Post sends text as a message. Slack renders its mrkdwn, so *bold* and
+// `code` spans are formatted.
+func (s *Slack) Post(ctx context.Context, text string) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack delivery failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
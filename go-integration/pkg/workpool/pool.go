@@ -0,0 +1,148 @@
+// Package workpool runs chain submissions on a fixed number of workers so a
+// burst of requests queues for the signer instead of all hitting it at once.
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrQueueFull is returned when every worker is busy and the queue is at its
+// depth; callers should shed the request rather than wait
+var ErrQueueFull = errors.New("submission queue is full")
+
+// ErrClosed is returned for work submitted after Close
+var ErrClosed = errors.New("worker pool is closed")
+
+// Task states; a queued task is claimed by exactly one of its worker or a
+// caller that gave up waiting
+const (
+	taskQueued int32 = iota
+	taskRunning
+	taskAbandoned
+)
+
+type task struct {
+	fn    func()
+	state atomic.Int32
+	done  chan struct{}
+}
+
+// Stats is a snapshot of the pool's load
+type Stats struct {
+	Workers    int `json:"workers"`
+	QueueDepth int `json:"queue_depth"`
+	Queued     int `json:"queued"`
+	Running    int `json:"running"`
+	Rejected   int `json:"rejected"` // submissions refused with ErrQueueFull since start
+}
+
+// Pool is a bounded set of workers fed from a bounded queue
+type Pool struct {
+	workers    int
+	queueDepth int
+	tasks      chan *task
+
+	admitted atomic.Int64 // tasks accepted and not yet finished or dropped
+	running  atomic.Int64
+	rejected atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New starts workers goroutines with room for queueDepth waiting tasks.
+// workers is at least 1; a queueDepth of 0 rejects any task that arrives
+// while every worker is busy.
+func New(workers, queueDepth int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+
+	p := &Pool{workers: workers, queueDepth: queueDepth, tasks: make(chan *task, workers+queueDepth)}
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for t := range p.tasks {
+		if !t.state.CompareAndSwap(taskQueued, taskRunning) {
+			p.admitted.Add(-1) // the caller stopped waiting before a worker was free
+			continue
+		}
+		p.running.Add(1)
+		t.fn()
+		p.running.Add(-1)
+		p.admitted.Add(-1)
+		close(t.done)
+	}
+}
+
+// Do runs fn on a worker and waits for it to return. It fails fast with
+// ErrQueueFull instead of blocking when the pool is saturated. If ctx ends
+// while fn is still queued, fn is dropped and ctx's error returned; once fn
+// has started Do waits for it, so fn should honour the same ctx.
+func (p *Pool) Do(ctx context.Context, fn func()) error {
+	t := &task{fn: fn, done: make(chan struct{})}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrClosed
+	}
+	if p.admitted.Add(1) > int64(p.workers+p.queueDepth) {
+		p.admitted.Add(-1)
+		p.mu.RUnlock()
+		p.rejected.Add(1)
+		return ErrQueueFull
+	}
+	// Admission bounds the tasks in flight to the channel's capacity, so this never blocks
+	p.tasks <- t
+	p.mu.RUnlock()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		if t.state.CompareAndSwap(taskQueued, taskAbandoned) {
+			return ctx.Err()
+		}
+		<-t.done
+		return nil
+	}
+}
+
+// Stats returns the pool's current load
+func (p *Pool) Stats() Stats {
+	running := p.running.Load()
+	return Stats{
+		Workers:    p.workers,
+		QueueDepth: p.queueDepth,
+		Queued:     int(max(p.admitted.Load()-running, 0)),
+		Running:    int(running),
+		Rejected:   int(p.rejected.Load()),
+	}
+}
+
+// Close stops accepting work and waits for queued and running tasks to finish
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.tasks)
+	p.mu.Unlock()
+	p.wg.Wait()
+}
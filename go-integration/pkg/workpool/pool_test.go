@@ -0,0 +1,112 @@
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	pool := New(2, 8)
+	defer pool.Close()
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := pool.Do(context.Background(), func() {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+			})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 2 {
+		t.Errorf("Expected at most 2 tasks at once, got %d", peak.Load())
+	}
+}
+
+func TestPoolRejectsWhenFull(t *testing.T) {
+	pool := New(1, 1)
+	defer pool.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go pool.Do(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// One task waits in the queue, the next is shed
+	queued := make(chan error, 1)
+	go func() { queued <- pool.Do(context.Background(), func() {}) }()
+	for pool.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := pool.Do(context.Background(), func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	if stats := pool.Stats(); stats.Rejected != 1 || stats.Running != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	close(release)
+	if err := <-queued; err != nil {
+		t.Errorf("Expected the queued task to run, got %v", err)
+	}
+}
+
+func TestPoolDropsAbandonedTasks(t *testing.T) {
+	pool := New(1, 2)
+	defer pool.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go pool.Do(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var ran atomic.Bool
+	if err := pool.Do(ctx, func() { ran.Store(true) }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline error, got %v", err)
+	}
+
+	close(release)
+	if err := pool.Do(context.Background(), func() {}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if ran.Load() {
+		t.Error("Expected the abandoned task not to run")
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	pool := New(1, 0)
+	pool.Close()
+	pool.Close()
+
+	if err := pool.Do(context.Background(), func() {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
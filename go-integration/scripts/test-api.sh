@@ -7,7 +7,7 @@ set -e
 BASE_URL="http://localhost:8081"
 
 echo "🧪 Testing Freelance Payment Gateway API..."
-echo "Make sure the server is running with: go run cmd/main.go"
+echo "Make sure the server is running with: go run ./cmd"
 echo ""
 
 # Colors for output
@@ -60,6 +60,6 @@ echo ""
 echo "To run actual tests:"
 echo "1. Deploy your smart contract"
 echo "2. Update .env with contract address and private key"
-echo "3. Start the server: go run cmd/main.go"
+echo "3. Start the server: go run ./cmd"
 echo "4. Uncomment the test calls in this script"
 echo "5. Run this script again" 
\ No newline at end of file